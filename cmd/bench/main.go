@@ -0,0 +1,147 @@
+// Command bench measures the RTSP ingest pipeline (RTSP client + H.264
+// depacketizer) without Nest cameras or an SFU, by pointing N clients at
+// in-process rtsptest servers.
+//
+//	go run ./cmd/bench -cameras 20 -duration 30s
+//	go run ./cmd/bench -sample clip.h264 -fps 30
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtp"
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtsp"
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtsp/rtsptest"
+	pionRTP "github.com/pion/rtp"
+)
+
+// result is what one simulated camera measured
+type result struct {
+	packets   atomic.Uint64
+	frames    atomic.Uint64
+	keyframes atomic.Uint64
+	bytes     atomic.Uint64
+	maxGap    atomic.Int64 // Longest wait between frames, nanoseconds
+	err       error
+}
+
+func main() {
+	cameras := flag.Int("cameras", 10, "number of simulated cameras")
+	duration := flag.Duration("duration", 15*time.Second, "how long to stream")
+	fps := flag.Int("fps", 15, "frame rate served by each mock camera")
+	sample := flag.String("sample", "", "Annex-B .h264 file to loop instead of the synthetic stream")
+	verbose := flag.Bool("v", false, "log RTSP client output")
+	flag.Parse()
+
+	opts := rtsptest.Options{FPS: *fps}
+	if *sample != "" {
+		data, err := os.ReadFile(*sample)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "read sample:", err)
+			os.Exit(1)
+		}
+		if opts.Frames = rtsptest.AccessUnits(data); len(opts.Frames) == 0 {
+			fmt.Fprintln(os.Stderr, "sample contains no H.264 access units")
+			os.Exit(1)
+		}
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if *verbose {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+
+	results := make([]*result, *cameras)
+	var wg sync.WaitGroup
+	for i := range results {
+		results[i] = &result{}
+		srv := rtsptest.NewServer(opts)
+		defer srv.Close()
+
+		wg.Add(1)
+		go func(res *result) {
+			defer wg.Done()
+			res.err = run(ctx, srv.URL, res, logger)
+		}(results[i])
+	}
+
+	start := time.Now()
+	wg.Wait()
+	elapsed := time.Since(start).Seconds()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	fmt.Printf("%-6s %9s %8s %9s %6s %10s %9s\n", "camera", "packets", "frames", "keyframes", "fps", "kbit/s", "max_gap")
+	var totalFrames, totalBytes uint64
+	for i, res := range results {
+		frames := res.frames.Load()
+		totalFrames += frames
+		totalBytes += res.bytes.Load()
+		line := fmt.Sprintf("%-6d %9d %8d %9d %6.1f %10.0f %9s",
+			i, res.packets.Load(), frames, res.keyframes.Load(), float64(frames)/elapsed,
+			float64(res.bytes.Load())*8/1000/elapsed,
+			time.Duration(res.maxGap.Load()).Round(time.Millisecond))
+		if res.err != nil {
+			line += "  error: " + res.err.Error()
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("\ntotal: %d frames (%.1f fps), %.1f Mbit/s, %d goroutines, %.1f MiB heap\n",
+		totalFrames, float64(totalFrames)/elapsed,
+		float64(totalBytes)*8/1e6/elapsed,
+		runtime.NumGoroutine(), float64(mem.HeapAlloc)/(1<<20))
+}
+
+// run connects one RTSP client and counts what the depacketizer produces
+func run(ctx context.Context, url string, res *result, logger *slog.Logger) error {
+	client := rtsp.NewClient(url, logger)
+	defer client.Close()
+
+	if err := client.Connect(ctx); err != nil {
+		return err
+	}
+	if err := client.SetupTracks(ctx); err != nil {
+		return err
+	}
+
+	proc := rtp.NewH264Processor()
+	last := time.Now()
+	proc.OnFrame = func(au []byte, _ uint32, keyframe bool) {
+		now := time.Now()
+		if gap := now.Sub(last); res.frames.Load() > 0 && int64(gap) > res.maxGap.Load() {
+			res.maxGap.Store(int64(gap))
+		}
+		last = now
+
+		res.frames.Add(1)
+		res.bytes.Add(uint64(len(au)))
+		if keyframe {
+			res.keyframes.Add(1)
+		}
+	}
+	client.OnRTPPacket = func(_ byte, pkt *pionRTP.Packet) {
+		res.packets.Add(1)
+		proc.ProcessPacket(pkt)
+	}
+
+	if err := client.Play(ctx); err != nil {
+		return err
+	}
+	if err := client.ReadPackets(ctx); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
@@ -170,7 +170,7 @@ func main() {
 	}()
 
 	// Create WebRTC bridge to Cloudflare with camera ID for unique track naming
-	webrtcBridge, err := bridge.NewBridge(ctx, firstCamera.DeviceID, cfClient, log.With("component", "bridge").Logger)
+	webrtcBridge, err := bridge.NewBridge(ctx, firstCamera.DeviceID, cloudflare.NewBackend(cfClient), log.With("component", "bridge").Logger)
 	if err != nil {
 		log.Error("failed to create bridge", "error", err)
 		os.Exit(1)
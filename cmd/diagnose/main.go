@@ -162,6 +162,20 @@ func main() {
 		"url", stream.URL,
 		"expires_at", stream.ExpiresAt.Format(time.RFC3339))
 
+	// Probe stream capabilities before committing to a session
+	probe, err := rtsp.ProbeStream(ctx, stream.URL, rtsp.ClientOptions{}, lgr.With("component", "rtsp_probe").Logger)
+	if err != nil {
+		log.Fatalf("Failed to probe RTSP stream: %v", err)
+	}
+	lgr.Info("RTSP capabilities", "methods", probe.Methods)
+	for _, m := range probe.Media {
+		lgr.Info("RTSP media",
+			"type", m.MediaType,
+			"codec", m.Codec,
+			"clock_rate", m.ClockRate,
+			"payload_type", m.PayloadType)
+	}
+
 	// Create Cloudflare session
 	lgr.Info("creating Cloudflare session...")
 	session, err := cfClient.CreateSession(ctx)
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/config"
+	"github.com/ethan/nest-cloudflare-relay/pkg/nest"
+)
+
+// authTimeout bounds the wait for the user to finish the consent page
+const authTimeout = 10 * time.Minute
+
+// runAuth is `relay auth`: it runs Google's authorization-code flow with
+// PKCE against a local callback and writes the refresh token into the .env
+// file, whose client_id, client_secret and project_id it uses; those of
+// google.<account>.* with -account
+func runAuth(args []string) error {
+	fs := flag.NewFlagSet("relay auth", flag.ExitOnError)
+	envPath := fs.String("env", ".env", "Config file to read the OAuth client from and write refresh_token to")
+	listen := fs.String("listen", "localhost:8085", "Address for the OAuth callback; http://<listen>/callback must be an authorized redirect URI of the client")
+	pubsub := fs.Bool("pubsub", false, "Also request the Pub/Sub scope for camera events (implied by pubsub_subscription)")
+	account := fs.String("account", "", "Authorize the further account configured as google.<account>.* instead of the main one")
+	fs.Parse(args)
+
+	values, err := config.ReadValues(*envPath)
+	if err != nil {
+		return err
+	}
+	prefix := ""
+	if *account != "" {
+		prefix = "google." + *account + "."
+	}
+	for _, key := range []string{"client_id", "client_secret", "project_id"} {
+		if values[prefix+key] == "" {
+			return fmt.Errorf("missing %s in %s", prefix+key, *envPath)
+		}
+	}
+	scopes := []string{nest.ScopeSDM}
+	// Camera events are pulled with the main account's token only
+	if *pubsub || values["pubsub_subscription"] != "" && prefix == "" {
+		scopes = append(scopes, nest.ScopePubSub)
+	}
+
+	client := nest.NewClient(values[prefix+"client_id"], values[prefix+"client_secret"], "",
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	pkce, err := nest.NewPKCE()
+	if err != nil {
+		return err
+	}
+	state := make([]byte, 16)
+	if _, err := rand.Read(state); err != nil {
+		return fmt.Errorf("generate state: %w", err)
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return fmt.Errorf("listen for callback: %w", err)
+	}
+	redirectURI := "http://" + *listen + "/callback"
+
+	codes := make(chan string, 1)
+	failures := make(chan error, 1)
+	srv := &http.Server{Handler: callbackHandler(hex.EncodeToString(state), codes, failures)}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	fmt.Fprintf(os.Stderr, "Open this URL in a browser, choose the homes and cameras to share and allow access:\n\n  %s\n\nWaiting for the redirect to %s ...\n",
+		client.AuthCodeURL(values[prefix+"project_id"], redirectURI, hex.EncodeToString(state), pkce, scopes...), redirectURI)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, authTimeout)
+	defer cancel()
+
+	var code string
+	select {
+	case code = <-codes:
+	case err := <-failures:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("no authorization received: %w", ctx.Err())
+	}
+
+	exchangeCtx, exchangeCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer exchangeCancel()
+	refreshToken, err := client.ExchangeCode(exchangeCtx, code, redirectURI, pkce)
+	if err != nil {
+		return err
+	}
+	if err := config.SetValues(*envPath, map[string]string{prefix + "refresh_token": refreshToken}); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %srefresh_token to %s\n", prefix, *envPath)
+
+	// Confirm the grant reaches the project's devices
+	devices, err := client.ListDevices(exchangeCtx, values[prefix+"project_id"])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: listing devices failed: %v\n", err)
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Authorized for %d camera(s)\n", len(devices))
+	return nil
+}
+
+// callbackHandler receives the consent page's redirect, passing on the
+// authorization code once its state matches
+func callbackHandler(state string, codes chan<- string, failures chan<- error) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("state") != state {
+			http.Error(w, "state mismatch", http.StatusBadRequest)
+			return
+		}
+		if e := q.Get("error"); e != "" {
+			http.Error(w, "authorization failed: "+e, http.StatusBadRequest)
+			select {
+			case failures <- errors.New("authorization denied: " + e):
+			default:
+			}
+			return
+		}
+		code := q.Get("code")
+		if code == "" {
+			http.Error(w, "missing code", http.StatusBadRequest)
+			return
+		}
+		select {
+		case codes <- code:
+			fmt.Fprintln(w, "Authorized. You can close this tab and return to the terminal.")
+		default:
+			http.Error(w, "already authorized", http.StatusConflict)
+		}
+	})
+	return mux
+}
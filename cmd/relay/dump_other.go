@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// notifyDump does nothing; there is no SIGUSR1 on this platform
+func notifyDump(c chan<- os.Signal) {}
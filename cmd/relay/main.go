@@ -2,16 +2,21 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"log/slog"
 	"os"
 	"os/signal"
+	"runtime"
 	"syscall"
 	"time"
 
-	"github.com/ethan/nest-cloudflare-relay/pkg/api"
-	"github.com/ethan/nest-cloudflare-relay/pkg/cloudflare"
+	"github.com/ethan/nest-cloudflare-relay/pkg/camsrelay"
 	"github.com/ethan/nest-cloudflare-relay/pkg/config"
+	"github.com/ethan/nest-cloudflare-relay/pkg/egress"
+	"github.com/ethan/nest-cloudflare-relay/pkg/faults"
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+	logpkg "github.com/ethan/nest-cloudflare-relay/pkg/logger"
 	"github.com/ethan/nest-cloudflare-relay/pkg/nest"
 	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
 )
@@ -19,10 +24,25 @@ import (
 // Multi-camera relay example: Full pipeline for multiple cameras
 // Nest cameras → RTSP streams → RTP processing → WebRTC → Cloudflare
 func main() {
-	// Initialize logger
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+	// `relay auth` obtains the refresh token instead of running the relay
+	if len(os.Args) > 1 && os.Args[1] == "auth" {
+		if err := runAuth(os.Args[2:]); err != nil {
+			log.Fatalf("auth: %v", err)
+		}
+		return
+	}
+
+	// Parse command-line flags
+	fs := flag.NewFlagSet("relay", flag.ExitOnError)
+	chaosFlags := faults.RegisterFlags(fs)
+	allowOverQuota := fs.Bool("allow-over-quota", false, "Start even if stream extensions would exceed the SDM quota")
+	fs.Parse(os.Args[1:])
+
+	// Initialize logger; recent records are also kept for diagnostic bundles
+	logRing := logpkg.NewRing(logpkg.DefaultRingSize)
+	logger := slog.New(logRing.Handler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
-	}))
+	}), slog.LevelInfo))
 
 	logger.Info("starting multi-camera Nest → Cloudflare relay")
 
@@ -31,131 +51,76 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
-
-	// Create Nest API client
-	nestClient := nest.NewClient(
-		cfg.Google.ClientID,
-		cfg.Google.ClientSecret,
-		cfg.Google.RefreshToken,
-		logger.With("component", "nest"),
-	)
-
-	// Create Cloudflare client
-	cfClient := cloudflare.NewClient(
-		cfg.Cloudflare.AppID,
-		cfg.Cloudflare.APIToken,
-		logger.With("component", "cloudflare"),
-	)
-
-	// List available cameras
-	ctx := context.Background()
-	devices, err := nestClient.ListDevices(ctx, cfg.Google.ProjectID)
-	if err != nil {
-		log.Fatalf("Failed to list devices: %v", err)
+	if *allowOverQuota {
+		cfg.AllowOverQuota = true
 	}
 
-	logger.Info("discovered cameras", "count", len(devices))
-
-	// Extract camera IDs (limit to first 20 for rate limiting)
-	cameraIDs := make([]string, 0, 20)
-	cameraNames := make(map[string]string) // Map device ID to display name
-	for i, device := range devices {
-		if i >= 20 {
-			break
-		}
-		cameraIDs = append(cameraIDs, device.DeviceID)
-
-		displayName := device.Traits.Info.CustomName
-		if displayName == "" && len(device.Relations) > 0 {
-			displayName = device.Relations[0].DisplayName
+	// Each camera's records also go to its own rotating file
+	if cfg.CameraLogPath != "" {
+		cameraLogs, err := logpkg.NewCameraFiles(cfg.CameraLogPath)
+		if err != nil {
+			log.Fatalf("Invalid camera_log_path: %v", err)
 		}
-		if displayName == "" {
-			displayName = device.DeviceID
-		}
-		cameraNames[device.DeviceID] = displayName
-
-		logger.Info("camera available",
-			"index", i+1,
-			"device_id", device.DeviceID,
-			"name", displayName,
-			"protocols", device.Traits.CameraLiveStream.SupportedProtocols,
-			"video_codecs", device.Traits.CameraLiveStream.VideoCodecs,
-			"audio_codecs", device.Traits.CameraLiveStream.AudioCodecs,
-		)
-	}
-
-	if len(cameraIDs) == 0 {
-		log.Fatal("No cameras found")
+		defer cameraLogs.Close()
+		logger = slog.New(cameraLogs.Handler(logger.Handler(), slog.LevelInfo))
+		logger.Info("writing per-camera logs", "path", cfg.CameraLogPath)
 	}
 
-	// Configure multi-stream manager with defaults for 20 cameras @ 10 QPM
-	msmConfig := nest.DefaultMultiStreamConfig()
-
-	// Create multi-stream manager
-	streamMgr := nest.NewMultiStreamManager(
-		nestClient,
-		cfg.Google.ProjectID,
-		msmConfig,
-		logger.With("component", "stream_manager"),
-	)
-
-	// Create multi-camera relay orchestrator
-	multiRelay := relay.NewMultiCameraRelay(
-		streamMgr,
-		cfClient,
-		logger.With("component", "multi_relay"),
-	)
-
-	logger.Info("multi-camera relay initialized",
-		"cameras", len(cameraIDs),
-		"qpm_limit", msmConfig.QPM,
-		"stagger_interval", msmConfig.StaggerInterval)
+	// Chaos mode is off unless -chaos is given (injector is nil)
+	injector := faults.New(chaosFlags.ToConfig(), logger.With("component", "faults"))
 
-	// Create and start HTTP API server for viewer FIRST (before camera init)
-	apiServer := api.NewServer(
-		multiRelay,
-		cfClient,
-		cfg.Cloudflare.AppID,
-		logger.With("component", "api"),
+	// Build the relay service (Nest client, stream manager, relay, API server)
+	svc, err := camsrelay.New(
+		camsrelay.WithConfig(cfg),
+		camsrelay.WithListenAddr(":8080"),
+		camsrelay.WithLogger(logger),
+		camsrelay.WithLogRing(logRing),
+		camsrelay.WithFaultInjector(injector),
 	)
-
-	// Set camera display names in the API server
-	for deviceID, name := range cameraNames {
-		apiServer.SetCameraName(deviceID, name)
+	if err != nil {
+		log.Fatalf("Failed to create relay service: %v", err)
 	}
 
-	// Start HTTP server before cameras so viewer is available immediately
-	if err := apiServer.Start(ctx, ":8080"); err != nil {
-		log.Fatalf("Failed to start API server: %v", err)
+	// Cancelled on interrupt, including while waiting as a standby instance
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Discover cameras, start the API server and begin staggered camera startup
+	// Cameras come online over ~4 minutes for 20 cameras (20 * 12s stagger)
+	// With ha_lock_path set, this blocks until the active instance goes away
+	if err := svc.Start(ctx); err != nil {
+		if ctx.Err() != nil {
+			logger.Info("interrupted before becoming active")
+			return
+		}
+		// Release whatever started (a failed self-test leaves cameras running)
+		stopCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		svc.Stop(stopCtx)
+		cancel()
+		log.Fatalf("Failed to start relay service: %v", err)
 	}
 	logger.Info("API server started", "address", "http://localhost:8080")
 
-	// Start the multi-relay (starts stream manager internally)
-	if err := multiRelay.Start(ctx); err != nil {
-		log.Fatalf("Failed to start multi-relay: %v", err)
-	}
-
-	// Start all cameras with staggered initialization
-	// This will take ~4 minutes for 20 cameras (20 * 12s stagger)
-	startCtx, startCancel := context.WithTimeout(ctx, 10*time.Minute)
-	defer startCancel()
-
-	logger.Info("starting cameras with staggered initialization")
-	if err := streamMgr.StartCameras(startCtx, cameraIDs); err != nil {
-		log.Fatalf("Failed to start cameras: %v", err)
-	}
-
-	logger.Info("all cameras initialization triggered - relays will be created as streams become ready")
-
 	// Start monitoring goroutine
-	go monitorStatus(multiRelay, streamMgr, logger)
+	go monitorStatus(svc.Relay(), svc.StreamManager(), svc.Egress(), logger)
+
+	// SIGUSR1 dumps a diagnostics snapshot, for when the admin API is unreachable
+	dumps := make(chan os.Signal, 1)
+	notifyDump(dumps)
+	go func() {
+		for range dumps {
+			path, err := svc.DumpDiagnostics()
+			if err != nil {
+				logger.Error("failed to dump diagnostics", "error", err)
+				continue
+			}
+			logger.Info("dumped diagnostics", "path", path)
+		}
+	}()
 
 	// Wait for interrupt signal
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-
 	logger.Info("running... press Ctrl+C to stop")
-	<-sigChan
+	<-ctx.Done()
 
 	logger.Info("shutdown signal received, stopping all relays")
 
@@ -163,13 +128,7 @@ func main() {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
 
-	// Stop API server
-	if err := apiServer.Stop(shutdownCtx); err != nil {
-		logger.Error("error stopping API server", "error", err)
-	}
-
-	// Stop relay
-	if err := multiRelay.Stop(); err != nil {
+	if err := svc.Stop(shutdownCtx); err != nil {
 		logger.Error("error during shutdown", "error", err)
 	}
 
@@ -177,7 +136,7 @@ func main() {
 }
 
 // monitorStatus periodically logs stream and relay status
-func monitorStatus(multiRelay *relay.MultiCameraRelay, streamMgr *nest.MultiStreamManager, logger *slog.Logger) {
+func monitorStatus(multiRelay *relay.MultiCameraRelay, streamMgr *nest.MultiStreamManager, meter *egress.Meter, logger *slog.Logger) {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
@@ -206,6 +165,7 @@ func monitorStatus(multiRelay *relay.MultiCameraRelay, streamMgr *nest.MultiStre
 			"streams_failed", streamStates[nest.StateFailed],
 			"streams_degraded", streamStates[nest.StateDegraded],
 			"streams_stopped", streamStates[nest.StateStopped],
+			"streams_offline", streamStates[nest.StateOffline],
 			// Relay states
 			"total_relays", aggStats.TotalRelays,
 			"relays_connected", aggStats.ConnectedRelays,
@@ -217,6 +177,15 @@ func monitorStatus(multiRelay *relay.MultiCameraRelay, streamMgr *nest.MultiStre
 			"total_video_frames", aggStats.TotalVideoFrames,
 			"total_audio_packets", aggStats.TotalAudioPackets,
 			"total_audio_frames", aggStats.TotalAudioFrames,
+			"max_video_loss", aggStats.MaxVideoLoss,
+			"max_video_rtt_ms", aggStats.MaxVideoRTT.Milliseconds(),
+			"max_video_latency_p95_ms", aggStats.MaxVideoLatencyP95.Milliseconds(),
+			"state_drifts", aggStats.StateDrifts,
+			"relay_panics", aggStats.Panics,
+			"stalled_writes", aggStats.StalledWrites,
+			"over_budget_frames", aggStats.OverBudgetFrames,
+			"relays_silent", aggStats.SilentRelays,
+			"max_ice_rtt_ms", aggStats.MaxICERTT.Milliseconds(),
 			// Queue statistics
 			"queue_depth", queueStats.QueueDepth,
 			"total_executed", queueStats.TotalExecuted,
@@ -224,11 +193,18 @@ func monitorStatus(multiRelay *relay.MultiCameraRelay, streamMgr *nest.MultiStre
 			"extend_count", queueStats.ExtendCount,
 			"generate_count", queueStats.GenerateCount,
 			"avg_wait_time_ms", queueStats.AvgWaitTime.Milliseconds(),
+			"quota_errors", queueStats.QuotaErrors,
+			"effective_qpm", queueStats.EffectiveQPM,
+			// Goroutine accounting
+			"goroutines", runtime.NumGoroutine(),
+			"goroutines_tracked", goroutines.Take().Tracked,
+			// Egress this month
+			"egress_month_bytes", meter.Usage(time.Now()).Bytes,
 		)
 
 		// Log individual camera issues
 		for _, streamStatus := range streamStatuses {
-			if streamStatus.State == nest.StateFailed || streamStatus.State == nest.StateDegraded {
+			if streamStatus.State == nest.StateFailed || streamStatus.State == nest.StateDegraded || streamStatus.State == nest.StateOffline {
 				logger.Warn("camera stream issue",
 					"camera_id", streamStatus.CameraID,
 					"state", streamStatus.State.String(),
@@ -248,6 +224,14 @@ func monitorStatus(multiRelay *relay.MultiCameraRelay, streamMgr *nest.MultiStre
 					"webrtc_state", stat.WebRTCState,
 					"uptime", stat.Uptime,
 				)
+			} else if stat.VideoSilent {
+				logger.Warn("relay connected but sending no video",
+					"camera_id", stat.CameraID,
+					"session_id", stat.SessionID,
+					"video_frames_received", stat.VideoFrames,
+					"video_frames_sent", stat.Peer.VideoFramesSent,
+					"candidate", stat.Peer.LocalCandidate+"/"+stat.Peer.RemoteCandidate,
+				)
 			}
 		}
 	}
@@ -256,6 +240,7 @@ func monitorStatus(multiRelay *relay.MultiCameraRelay, streamMgr *nest.MultiStre
 // Architecture Notes:
 //
 // COMPONENT HIERARCHY:
+// camsrelay.Service (embeddable wiring, see pkg/camsrelay)
 // MultiCameraRelay (orchestrator)
 //   ├─ MultiStreamManager (Nest stream lifecycle)
 //   │   ├─ CommandQueue (10 QPM rate limiting)
@@ -0,0 +1,90 @@
+// Command replay feeds a recorded RTP capture through the relay pipeline
+// (H.264 depacketizer, pacer, packetizer) without Nest or an SFU, and reports
+// the frames and timestamps that would have been sent. Captures come from
+// capture_dir or any pcap of RTP over UDP.
+//
+//	go run ./cmd/replay captures/AVPHwEtYJ6xxxx-20251215T161627Z.pcap
+//	go run ./cmd/replay -video-port 40000 -realtime -v user-report.pcap
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/replay"
+	"github.com/pion/rtp"
+)
+
+// frameLog records the timestamp of every frame the bridge finished sending
+type frameLog struct {
+	timestamps []uint32
+	packets    int
+}
+
+func (l *frameLog) WriteRTP(p *rtp.Packet) error {
+	l.packets++
+	if p.Marker {
+		l.timestamps = append(l.timestamps, p.Timestamp)
+	}
+	return nil
+}
+
+func main() {
+	videoPort := flag.Uint("video-port", replay.VideoPort, "UDP port carrying H.264 RTP")
+	audioPort := flag.Uint("audio-port", replay.AudioPort, "UDP port carrying AAC RTP")
+	realtime := flag.Bool("realtime", false, "feed packets at their captured spacing")
+	verbose := flag.Bool("v", false, "log pipeline output")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: replay [flags] capture.pcap")
+		os.Exit(2)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if *verbose {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var sent frameLog
+	res, err := replay.RunFile(ctx, "replay", flag.Arg(0), &sent, replay.Options{
+		VideoPort: uint16(*videoPort),
+		AudioPort: uint16(*audioPort),
+		Realtime:  *realtime,
+		Logger:    logger,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "replay:", err)
+		os.Exit(1)
+	}
+
+	var backwards, gaps int
+	for i := 1; i < len(sent.timestamps); i++ {
+		delta := int32(sent.timestamps[i] - sent.timestamps[i-1])
+		switch {
+		case delta < 0:
+			backwards++
+			fmt.Printf("frame %d: timestamp went backwards by %d (%d → %d)\n",
+				i, -delta, sent.timestamps[i-1], sent.timestamps[i])
+		case delta > 90000/30*3:
+			gaps++
+		}
+	}
+
+	fmt.Printf("received: %d video packets, %d audio packets, %d skipped\n", res.VideoPackets, res.AudioPackets, res.Skipped)
+	fmt.Printf("depacketized: %d video frames (%d keyframes), %d audio frames, %d oversized NALUs dropped\n",
+		res.VideoFrames, res.Keyframes, res.AudioFrames, res.Dropped)
+	fmt.Printf("sent: %d RTP packets, %d frames, %d timestamp regressions, %d gaps over 100ms\n",
+		sent.packets, len(sent.timestamps), backwards, gaps)
+	if backwards > 0 {
+		os.Exit(1)
+	}
+}
@@ -18,6 +18,7 @@ require (
 	github.com/sigurn/crc16 v0.0.0-20240131213347-83fcde1e29d1
 	github.com/sigurn/crc8 v0.0.0-20220107193325-2243fe600f9f
 	github.com/stretchr/testify v1.11.1
+	go.etcd.io/bbolt v1.3.11
 	golang.org/x/time v0.14.0
 )
 
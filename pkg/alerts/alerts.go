@@ -0,0 +1,267 @@
+// Package alerts evaluates health rules against the relay's stream and
+// pipeline statistics and dispatches notifications when they fire and
+// resolve.
+//
+// Each rule produces findings keyed by subject (a camera ID, or "project"
+// for fleet-wide rules). A finding must persist for the rule's For duration
+// before it fires, fires exactly once, and sends a single resolve
+// notification when it clears.
+package alerts
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/nest"
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+)
+
+const (
+	// DefaultInterval is how often rules are evaluated
+	DefaultInterval = 10 * time.Second
+
+	notifyTimeout = 15 * time.Second
+	dispatchQueue = 64
+)
+
+// Status of an alert notification
+type Status string
+
+const (
+	StatusFiring   Status = "firing"
+	StatusResolved Status = "resolved"
+)
+
+// Alert is the payload delivered to notifiers
+type Alert struct {
+	Rule       string    `json:"rule"`
+	Subject    string    `json:"subject"` // Camera ID or "project"
+	Name       string    `json:"name,omitempty"`
+	Status     Status    `json:"status"`
+	Message    string    `json:"message"`
+	StartedAt  time.Time `json:"startedAt"`
+	ResolvedAt time.Time `json:"resolvedAt,omitempty"`
+}
+
+// Notifier delivers alerts to an external system
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// alertKey identifies one rule firing for one subject
+type alertKey struct {
+	rule    string
+	subject string
+}
+
+// alertState tracks a finding from first sighting until it resolves
+type alertState struct {
+	since   time.Time
+	message string
+	fired   bool
+}
+
+// Engine periodically evaluates rules and notifies on transitions
+type Engine struct {
+	streamMgr *nest.MultiStreamManager
+	relay     *relay.MultiCameraRelay
+	rules     []Rule
+	notifiers []Notifier
+	interval  time.Duration
+	logger    *slog.Logger
+
+	mu     sync.Mutex
+	active map[alertKey]*alertState
+	names  map[string]string // Camera ID -> display name
+	sample sampler
+
+	queue  chan Alert
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewEngine creates an alert engine for the given rules and notifiers
+func NewEngine(
+	streamMgr *nest.MultiStreamManager,
+	relay *relay.MultiCameraRelay,
+	rules []Rule,
+	notifiers []Notifier,
+	logger *slog.Logger,
+) *Engine {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Engine{
+		streamMgr: streamMgr,
+		relay:     relay,
+		rules:     rules,
+		notifiers: notifiers,
+		interval:  DefaultInterval,
+		logger:    logger,
+		active:    make(map[alertKey]*alertState),
+		names:     make(map[string]string),
+		queue:     make(chan Alert, dispatchQueue),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// SetCameraName sets the display name included in a camera's alerts
+func (e *Engine) SetCameraName(cameraID, name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.names[cameraID] = name
+}
+
+// Start begins evaluating rules
+func (e *Engine) Start() {
+	e.wg.Add(2)
+	go e.evaluateLoop()
+	go e.dispatchLoop()
+
+	e.logger.Info("alert engine started",
+		"rules", len(e.rules),
+		"notifiers", len(e.notifiers),
+		"interval", e.interval)
+}
+
+// Stop halts evaluation and drops undelivered notifications
+func (e *Engine) Stop() error {
+	e.cancel()
+	e.wg.Wait()
+	return nil
+}
+
+// Active returns the alerts currently firing
+func (e *Engine) Active() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(e.active))
+	for key, st := range e.active {
+		if st.fired {
+			alerts = append(alerts, e.alert(key, st, StatusFiring, time.Time{}))
+		}
+	}
+	return alerts
+}
+
+func (e *Engine) evaluateLoop() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case now := <-ticker.C:
+			e.evaluate(now)
+		}
+	}
+}
+
+// evaluate collects a snapshot and runs every rule against it
+func (e *Engine) evaluate(now time.Time) {
+	snap := e.sample.next(now, e.streamMgr.GetStreamStatus(), e.relay.GetRelayStats(), e.streamMgr.GetQueueStats())
+
+	findings := make(map[alertKey]string)
+	forDurations := make(map[string]time.Duration, len(e.rules))
+	for _, rule := range e.rules {
+		forDurations[rule.Name] = rule.For
+		for _, f := range rule.Check(snap) {
+			findings[alertKey{rule.Name, f.Subject}] = f.Message
+		}
+	}
+
+	for _, a := range e.transition(now, findings, forDurations) {
+		select {
+		case e.queue <- a:
+		default:
+			e.logger.Warn("alert dispatch queue full, dropping notification",
+				"rule", a.Rule, "subject", a.Subject, "status", a.Status)
+		}
+	}
+}
+
+// transition updates alert state from the current findings and returns the
+// notifications to send. Firing alerts are deduplicated until they resolve.
+func (e *Engine) transition(now time.Time, findings map[alertKey]string, forDurations map[string]time.Duration) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var out []Alert
+
+	for key, msg := range findings {
+		st, ok := e.active[key]
+		if !ok {
+			st = &alertState{since: now}
+			e.active[key] = st
+		}
+		st.message = msg
+
+		if !st.fired && now.Sub(st.since) >= forDurations[key.rule] {
+			st.fired = true
+			out = append(out, e.alert(key, st, StatusFiring, time.Time{}))
+		}
+	}
+
+	for key, st := range e.active {
+		if _, still := findings[key]; still {
+			continue
+		}
+		delete(e.active, key)
+		if st.fired {
+			out = append(out, e.alert(key, st, StatusResolved, now))
+		}
+	}
+
+	return out
+}
+
+// alert builds a notification; callers hold e.mu
+func (e *Engine) alert(key alertKey, st *alertState, status Status, resolvedAt time.Time) Alert {
+	return Alert{
+		Rule:       key.rule,
+		Subject:    key.subject,
+		Name:       e.names[key.subject],
+		Status:     status,
+		Message:    st.message,
+		StartedAt:  st.since,
+		ResolvedAt: resolvedAt,
+	}
+}
+
+// dispatchLoop delivers notifications in order so a resolve never
+// overtakes its firing
+func (e *Engine) dispatchLoop() {
+	defer e.wg.Done()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case a := <-e.queue:
+			e.logger.Info("alert "+string(a.Status),
+				"rule", a.Rule,
+				"subject", a.Subject,
+				"message", a.Message)
+
+			for _, n := range e.notifiers {
+				ctx, cancel := context.WithTimeout(e.ctx, notifyTimeout)
+				if err := n.Notify(ctx, a); err != nil {
+					e.logger.Error("failed to deliver alert",
+						"notifier", n.Name(),
+						"rule", a.Rule,
+						"subject", a.Subject,
+						"error", err)
+				}
+				cancel()
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package alerts
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// TestTransition checks the pending → firing → resolved lifecycle and that
+// a persistent finding is only reported once
+func TestTransition(t *testing.T) {
+	e := NewEngine(nil, nil, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	key := alertKey{"camera_degraded", "cam1"}
+	forDurations := map[string]time.Duration{"camera_degraded": time.Minute}
+	findings := map[alertKey]string{key: "degraded"}
+
+	t0 := time.Now()
+	steps := []struct {
+		at       time.Duration
+		findings map[alertKey]string
+		want     []Status
+	}{
+		{0, findings, nil},                // Pending
+		{30 * time.Second, findings, nil}, // Still pending
+		{time.Minute, findings, []Status{StatusFiring}},
+		{2 * time.Minute, findings, nil}, // Deduplicated
+		{3 * time.Minute, nil, []Status{StatusResolved}},
+		{4 * time.Minute, nil, nil},
+		{5 * time.Minute, findings, nil}, // Pending again
+	}
+
+	for i, step := range steps {
+		got := e.transition(t0.Add(step.at), step.findings, forDurations)
+		if len(got) != len(step.want) {
+			t.Fatalf("step %d: got %d alerts, want %d", i, len(got), len(step.want))
+		}
+		for j, a := range got {
+			if a.Status != step.want[j] {
+				t.Errorf("step %d: status = %s, want %s", i, a.Status, step.want[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/httpx"
+)
+
+// WebhookNotifier POSTs each alert as JSON
+type WebhookNotifier struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to url
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:        url,
+		httpClient: httpx.NewClient(httpx.Config{Timeout: notifyTimeout}),
+	}
+}
+
+// Name identifies the notifier in logs
+func (n *WebhookNotifier) Name() string { return "webhook" }
+
+// Notify sends the alert
+func (n *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("marshal alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook failed: %s (status %d)", respBody, resp.StatusCode)
+	}
+	return nil
+}
+
+// EmailNotifier sends alerts through an SMTP relay
+type EmailNotifier struct {
+	addr     string // host:port
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+// NewEmailNotifier creates an SMTP notifier. Authentication is skipped when
+// username is empty.
+func NewEmailNotifier(addr, username, password, from string, to []string) *EmailNotifier {
+	return &EmailNotifier{
+		addr:     addr,
+		username: username,
+		password: password,
+		from:     from,
+		to:       to,
+	}
+}
+
+// Name identifies the notifier in logs
+func (n *EmailNotifier) Name() string { return "email" }
+
+// Notify sends the alert as a plain-text email
+func (n *EmailNotifier) Notify(ctx context.Context, alert Alert) error {
+	var auth smtp.Auth
+	if n.username != "" {
+		host, _, err := net.SplitHostPort(n.addr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP address: %w", err)
+		}
+		auth = smtp.PlainAuth("", n.username, n.password, host)
+	}
+
+	// net/smtp has no context support; run it aside so ctx bounds the wait
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- smtp.SendMail(n.addr, auth, n.from, n.to, n.message(alert))
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("send mail: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// message formats an RFC 5322 message for the alert
+func (n *EmailNotifier) message(alert Alert) []byte {
+	subject := alert.Subject
+	if alert.Name != "" {
+		subject = alert.Name
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&b, "Subject: [%s] %s: %s\r\n", strings.ToUpper(string(alert.Status)), alert.Rule, subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	fmt.Fprintf(&b, "Rule:    %s\r\n", alert.Rule)
+	fmt.Fprintf(&b, "Subject: %s\r\n", alert.Subject)
+	fmt.Fprintf(&b, "Status:  %s\r\n", alert.Status)
+	fmt.Fprintf(&b, "Message: %s\r\n", alert.Message)
+	fmt.Fprintf(&b, "Since:   %s\r\n", alert.StartedAt.Format(time.RFC3339))
+	if !alert.ResolvedAt.IsZero() {
+		fmt.Fprintf(&b, "Resolved: %s\r\n", alert.ResolvedAt.Format(time.RFC3339))
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,200 @@
+package alerts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/nest"
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+)
+
+// ProjectSubject is the subject of fleet-wide findings
+const ProjectSubject = "project"
+
+// quotaWindow is the trailing window used to measure SDM command usage
+const quotaWindow = time.Minute
+
+// warmup suppresses pipeline rules while a relay is still starting up
+const warmup = 30 * time.Second
+
+// Rule is a named health check. Findings must persist for For before they fire.
+type Rule struct {
+	Name  string
+	For   time.Duration
+	Check func(s *Snapshot) []Finding
+}
+
+// Finding is one subject currently violating a rule
+type Finding struct {
+	Subject string
+	Message string
+}
+
+// Thresholds configures the built-in rules. Zero values disable a rule.
+type Thresholds struct {
+	DegradedFor     time.Duration // Camera stuck degraded or offline (default 5m)
+	MinFPS          float64       // Video frame rate floor (default 5)
+	KeyframeTimeout time.Duration // Maximum gap between keyframes (default 30s)
+	QuotaUsage      float64       // Fraction of the SDM QPM budget (default 0.9)
+}
+
+// DefaultThresholds returns the thresholds used when none are configured
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		DegradedFor:     5 * time.Minute,
+		MinFPS:          5,
+		KeyframeTimeout: 30 * time.Second,
+		QuotaUsage:      0.9,
+	}
+}
+
+// CameraSnapshot combines a camera's stream state with its relay statistics
+type CameraSnapshot struct {
+	CameraID     string
+	State        nest.CameraState
+	HasRelay     bool
+	Uptime       time.Duration
+	FPS          float64 // Video frames per second since the previous evaluation
+	LastKeyframe time.Time
+	AudioOnly    bool // No video relayed; video rules don't apply
+}
+
+// Snapshot is the input to every rule
+type Snapshot struct {
+	Time       time.Time
+	Cameras    []CameraSnapshot
+	QuotaUsage float64 // Commands in the last minute / QPM; -1 until a full window is sampled
+	QPM        float64
+}
+
+// DefaultRules builds the built-in rules from thresholds
+func DefaultRules(t Thresholds) []Rule {
+	var rules []Rule
+
+	if t.DegradedFor > 0 {
+		rules = append(rules, Rule{
+			Name: "camera_degraded",
+			For:  t.DegradedFor,
+			Check: func(s *Snapshot) []Finding {
+				var out []Finding
+				for _, c := range s.Cameras {
+					switch c.State {
+					case nest.StateDegraded:
+						out = append(out, Finding{c.CameraID, fmt.Sprintf("camera degraded for more than %s", t.DegradedFor)})
+					case nest.StateOffline:
+						out = append(out, Finding{c.CameraID, fmt.Sprintf("camera offline for more than %s", t.DegradedFor)})
+					}
+				}
+				return out
+			},
+		})
+	}
+
+	if t.MinFPS > 0 {
+		rules = append(rules, Rule{
+			Name: "low_fps",
+			For:  3 * DefaultInterval, // Ride out brief stalls
+			Check: func(s *Snapshot) []Finding {
+				var out []Finding
+				for _, c := range s.Cameras {
+					if c.HasRelay && !c.AudioOnly && c.Uptime > warmup && c.FPS >= 0 && c.FPS < t.MinFPS {
+						out = append(out, Finding{c.CameraID, fmt.Sprintf("video at %.1f fps (threshold %.1f)", c.FPS, t.MinFPS)})
+					}
+				}
+				return out
+			},
+		})
+	}
+
+	if t.KeyframeTimeout > 0 {
+		rules = append(rules, Rule{
+			Name: "no_keyframe",
+			Check: func(s *Snapshot) []Finding {
+				var out []Finding
+				for _, c := range s.Cameras {
+					if !c.HasRelay || c.AudioOnly || c.Uptime <= t.KeyframeTimeout {
+						continue
+					}
+					if c.LastKeyframe.IsZero() {
+						out = append(out, Finding{c.CameraID, "no keyframe received since relay start"})
+					} else if gap := s.Time.Sub(c.LastKeyframe); gap > t.KeyframeTimeout {
+						out = append(out, Finding{c.CameraID, fmt.Sprintf("no keyframe for %s", gap.Round(time.Second))})
+					}
+				}
+				return out
+			},
+		})
+	}
+
+	if t.QuotaUsage > 0 {
+		rules = append(rules, Rule{
+			Name: "quota_usage",
+			Check: func(s *Snapshot) []Finding {
+				if s.QuotaUsage < t.QuotaUsage {
+					return nil
+				}
+				return []Finding{{ProjectSubject, fmt.Sprintf("SDM commands at %.0f%% of %.0f QPM", s.QuotaUsage*100, s.QPM)}}
+			},
+		})
+	}
+
+	return rules
+}
+
+// sampler derives rates from consecutive raw statistics
+type sampler struct {
+	lastTime   time.Time
+	lastFrames map[string]uint64
+	executed   []executedSample
+}
+
+type executedSample struct {
+	at    time.Time
+	total int64
+}
+
+// next builds a Snapshot, computing frame rates and quota usage against
+// earlier samples
+func (s *sampler) next(now time.Time, streams []nest.StreamStatus, relays []relay.RelayStats, queue nest.QueueStats) *Snapshot {
+	snap := &Snapshot{Time: now, QuotaUsage: -1, QPM: queue.QPM}
+
+	byCamera := make(map[string]relay.RelayStats, len(relays))
+	for _, r := range relays {
+		byCamera[r.CameraID] = r
+	}
+
+	elapsed := now.Sub(s.lastTime).Seconds()
+	frames := make(map[string]uint64, len(relays))
+
+	for _, st := range streams {
+		c := CameraSnapshot{CameraID: st.CameraID, State: st.State, FPS: -1}
+		if r, ok := byCamera[st.CameraID]; ok {
+			c.HasRelay = true
+			c.Uptime = r.Uptime
+			c.LastKeyframe = r.LastKeyframe
+			c.AudioOnly = r.AudioOnly
+			frames[st.CameraID] = r.VideoFrames
+
+			// A relay restart resets its counter; skip the rate until the next sample
+			if prev, ok := s.lastFrames[st.CameraID]; ok && r.VideoFrames >= prev && elapsed > 0 {
+				c.FPS = float64(r.VideoFrames-prev) / elapsed
+			}
+		}
+		snap.Cameras = append(snap.Cameras, c)
+	}
+
+	s.lastTime = now
+	s.lastFrames = frames
+
+	// Keep one sample at or beyond the window so usage covers a full minute
+	s.executed = append(s.executed, executedSample{now, queue.TotalExecuted})
+	for len(s.executed) > 1 && now.Sub(s.executed[1].at) >= quotaWindow {
+		s.executed = s.executed[1:]
+	}
+	if oldest := s.executed[0]; queue.QPM > 0 && now.Sub(oldest.at) >= quotaWindow {
+		perMinute := float64(queue.TotalExecuted-oldest.total) * quotaWindow.Seconds() / now.Sub(oldest.at).Seconds()
+		snap.QuotaUsage = perMinute / queue.QPM
+	}
+
+	return snap
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// CameraAudioFunc switches a camera's audio forwarding. A nil enabled clears
+// the override, reverting to the config file.
+type CameraAudioFunc func(cameraID string, enabled *bool) error
+
+// CameraAudioRequest is the body of PUT /api/admin/cameras/{cameraId}/audio
+type CameraAudioRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// SetCameraAudio enables PUT and DELETE /api/admin/cameras/{cameraId}/audio,
+// which turn a camera's audio on or off through fn. The endpoint requires
+// the admin token.
+func (s *Server) SetCameraAudio(fn CameraAudioFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cameraAudio = fn
+}
+
+// handleCameraAudio switches a camera's audio: PUT stores an override,
+// DELETE reverts to the config file. Callers check the admin token.
+func (s *Server) handleCameraAudio(w http.ResponseWriter, r *http.Request, cameraID string) {
+	s.mu.RLock()
+	fn := s.cameraAudio
+	s.mu.RUnlock()
+	if fn == nil {
+		http.Error(w, "camera audio not available", http.StatusNotFound)
+		return
+	}
+
+	var req CameraAudioRequest
+	switch r.Method {
+	case http.MethodPut:
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Enabled == nil {
+			http.Error(w, "enabled required", http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := fn(cameraID, req.Enabled); err != nil {
+		if errors.Is(err, ErrUnknownCamera) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.logger.Error("failed to set camera audio", "camera_id", cameraID, "error", err)
+		http.Error(w, "failed to set camera audio", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
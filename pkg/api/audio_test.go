@@ -0,0 +1,40 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCameraAudio(t *testing.T) {
+	s := NewServer(nil, nil, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.SetAdminToken("secret")
+
+	var gotID string
+	var got *bool
+	s.SetCameraAudio(func(cameraID string, enabled *bool) error {
+		gotID, got = cameraID, enabled
+		return nil
+	})
+
+	do := func(method, body string) int {
+		req := httptest.NewRequest(method, "/api/admin/cameras/door/audio", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		s.handleCameraName(w, req)
+		return w.Code
+	}
+
+	if code := do(http.MethodPut, `{"enabled":true}`); code != http.StatusNoContent || gotID != "door" || got == nil || !*got {
+		t.Errorf("PUT = %d, camera %q, enabled %v", code, gotID, got)
+	}
+	if code := do(http.MethodDelete, ""); code != http.StatusNoContent || got != nil {
+		t.Errorf("DELETE = %d, enabled %v", code, got)
+	}
+	if code := do(http.MethodPut, `{}`); code != http.StatusBadRequest {
+		t.Errorf("PUT without enabled = %d", code)
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/nest/events"
+)
+
+// CameraEventsFunc returns a camera's latest events, newest first and at
+// most limit; every camera's when cameraID is empty
+type CameraEventsFunc func(cameraID string, limit int) []events.Event
+
+// SetCameraEvents enables GET /api/events, the cameras' recent motion,
+// person, sound and doorbell events
+func (s *Server) SetCameraEvents(fn CameraEventsFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = fn
+}
+
+// handleCameraEvents lists recent camera events:
+// GET /api/events?camera=<device-id>&limit=<n>. Without camera, every
+// camera's events are listed; limit defaults to 50.
+func (s *Server) handleCameraEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.RLock()
+	fn := s.events
+	s.mu.RUnlock()
+	if fn == nil {
+		http.Error(w, "camera events not enabled", http.StatusNotFound)
+		return
+	}
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	evs := fn(r.URL.Query().Get("camera"), limit)
+	if evs == nil {
+		evs = []events.Event{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(evs)
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/nest/events"
+)
+
+func TestHandleCameraEvents(t *testing.T) {
+	s := NewServer(nil, nil, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	rec := httptest.NewRecorder()
+	s.handleCameraEvents(rec, httptest.NewRequest(http.MethodGet, "/api/events", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("without a subscription: status %d, want 404", rec.Code)
+	}
+
+	var gotCamera string
+	var gotLimit int
+	s.SetCameraEvents(func(cameraID string, limit int) []events.Event {
+		gotCamera, gotLimit = cameraID, limit
+		return []events.Event{{ID: "e1", Type: events.Person, DeviceID: cameraID, Timestamp: time.Now()}}
+	})
+
+	rec = httptest.NewRecorder()
+	s.handleCameraEvents(rec, httptest.NewRequest(http.MethodGet, "/api/events?camera=cam1&limit=5", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var evs []events.Event
+	if err := json.NewDecoder(rec.Body).Decode(&evs); err != nil {
+		t.Fatal(err)
+	}
+	if gotCamera != "cam1" || gotLimit != 5 || len(evs) != 1 || evs[0].Type != events.Person {
+		t.Errorf("camera %q limit %d events %+v", gotCamera, gotLimit, evs)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleCameraEvents(rec, httptest.NewRequest(http.MethodGet, "/api/events?limit=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("bad limit: status %d, want 400", rec.Code)
+	}
+}
+
+func TestNotifyCameraEventDropsForSlowViewers(t *testing.T) {
+	s := NewServer(nil, nil, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	sub := make(chan ViewerEvent, viewerEventQueue)
+	s.eventSubs[sub] = struct{}{}
+
+	for range viewerEventQueue + 2 {
+		s.NotifyCameraEvent(events.Event{ID: "e", Type: events.Motion, DeviceID: "cam1"})
+	}
+	if len(sub) != viewerEventQueue {
+		t.Fatalf("queued %d events, want %d", len(sub), viewerEventQueue)
+	}
+
+	// Shutdown still reaches a viewer whose queue is full
+	s.NotifyShutdown("restarting", time.Second)
+	var last ViewerEvent
+	for ev := range sub {
+		last = ev
+	}
+	if last.Type != "shutdown" {
+		t.Errorf("last event = %+v, want shutdown", last)
+	}
+}
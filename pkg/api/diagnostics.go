@@ -0,0 +1,58 @@
+package api
+
+import (
+	"archive/zip"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DiagnosticsFunc writes the files of a diagnostic bundle into zw
+type DiagnosticsFunc func(zw *zip.Writer) error
+
+// SetDiagnostics enables GET /api/admin/diagnostics, which streams a zip
+// built by fn. The endpoint requires the admin token.
+func (s *Server) SetDiagnostics(fn DiagnosticsFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.diagnostics = fn
+}
+
+// handleDiagnostics streams a diagnostic bundle: GET /api/admin/diagnostics
+func (s *Server) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.isAdmin(r) {
+		http.Error(w, "admin token required", http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.RLock()
+	fn := s.diagnostics
+	s.mu.RUnlock()
+	if fn == nil {
+		http.Error(w, "diagnostics not available", http.StatusNotFound)
+		return
+	}
+
+	name := fmt.Sprintf("camsrelay-diagnostics-%s.zip", time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Header().Set("Cache-Control", "no-store")
+
+	// The status line is already sent once the zip starts streaming, so a
+	// failure part way through can only be logged; the client sees a
+	// truncated archive.
+	zw := zip.NewWriter(w)
+	if err := fn(zw); err != nil {
+		s.logger.Error("failed to write diagnostics bundle", "error", err)
+		return
+	}
+	if err := zw.Close(); err != nil {
+		s.logger.Error("failed to finish diagnostics bundle", "error", err)
+		return
+	}
+	s.logger.Info("served diagnostics bundle", "remote_addr", r.RemoteAddr)
+}
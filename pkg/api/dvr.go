@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/recording"
+)
+
+// DVRSegmentInfo describes one rewindable segment on a camera's timeline
+type DVRSegmentInfo struct {
+	Sequence uint64    `json:"sequence"`
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration"` // Seconds
+	URL      string    `json:"url"`
+}
+
+// DVRTimelineResponse lists a camera's buffered footage
+type DVRTimelineResponse struct {
+	CameraID    string           `json:"cameraId"`
+	PlaylistURL string           `json:"playlistUrl"`
+	Segments    []DVRSegmentInfo `json:"segments"`
+}
+
+// SetDVR enables the rewind endpoints under /api/dvr/
+func (s *Server) SetDVR(dvr *recording.DVR) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dvr = dvr
+}
+
+// handleDVR routes /api/dvr/{cameraId}/{timeline|index.m3u8|<seq>.ts}
+func (s *Server) handleDVR(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.RLock()
+	dvr := s.dvr
+	s.mu.RUnlock()
+	if dvr == nil {
+		http.Error(w, "DVR not enabled", http.StatusNotFound)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/dvr/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.Error(w, "invalid DVR path", http.StatusBadRequest)
+		return
+	}
+	cameraID, resource := parts[0], parts[1]
+
+	switch {
+	case resource == "timeline":
+		s.handleDVRTimeline(w, dvr, cameraID)
+
+	case resource == "index.m3u8":
+		playlist, ok := dvr.Playlist(cameraID)
+		if !ok {
+			http.Error(w, "no buffered footage", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(playlist)
+
+	case strings.HasSuffix(resource, ".ts"):
+		seq, err := strconv.ParseUint(strings.TrimSuffix(resource, ".ts"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid segment", http.StatusBadRequest)
+			return
+		}
+		data, ok := dvr.Segment(cameraID, seq)
+		if !ok {
+			http.Error(w, "segment expired", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "video/mp2t")
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write(data)
+
+	default:
+		http.Error(w, "unknown DVR resource", http.StatusNotFound)
+	}
+}
+
+// handleDVRTimeline returns the buffered segments as JSON
+func (s *Server) handleDVRTimeline(w http.ResponseWriter, dvr *recording.DVR, cameraID string) {
+	base := "/api/dvr/" + cameraID + "/"
+
+	resp := DVRTimelineResponse{
+		CameraID:    cameraID,
+		PlaylistURL: base + "index.m3u8",
+		Segments:    make([]DVRSegmentInfo, 0),
+	}
+	for _, seg := range dvr.Timeline(cameraID) {
+		resp.Segments = append(resp.Segments, DVRSegmentInfo{
+			Sequence: seg.Sequence,
+			Start:    seg.Start,
+			Duration: seg.Duration.Seconds(),
+			URL:      base + strconv.FormatUint(seg.Sequence, 10) + ".ts",
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.logger.Error("failed to encode DVR timeline", "error", err)
+	}
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/egress"
+)
+
+// EgressFunc reports the month's egress
+type EgressFunc func() egress.Usage
+
+// SetEgress enables GET /api/admin/egress. The endpoint requires the admin
+// token.
+func (s *Server) SetEgress(fn EgressFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.egress = fn
+}
+
+// handleEgress reports bytes sent to the SFU per camera by hour, day and
+// month, against the monthly budget: GET /api/admin/egress
+func (s *Server) handleEgress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.isAdmin(r) {
+		http.Error(w, "admin token required", http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.RLock()
+	fn := s.egress
+	s.mu.RUnlock()
+	if fn == nil {
+		http.Error(w, "egress accounting not available", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(fn())
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/nest/events"
+)
+
+// eventsHeartbeat keeps idle event streams open through proxies
+const eventsHeartbeat = 30 * time.Second
+
+// viewerEventQueue is how many events a viewer may fall behind; camera
+// events past it are dropped for that viewer
+const viewerEventQueue = 8
+
+// ViewerEvent is pushed to connected viewers on /api/viewer/events
+type ViewerEvent struct {
+	Type       string        `json:"type"`                 // "shutdown" or "camera"
+	Reason     string        `json:"reason,omitempty"`     // Shown to the viewer
+	RetryAfter int           `json:"retryAfter,omitempty"` // Seconds before reconnecting
+	Camera     *events.Event `json:"camera,omitempty"`     // A camera's motion, person, sound or chime, for overlays
+}
+
+// NotifyShutdown tells every connected viewer the relay is going away and
+// ends their event streams, so Stop does not wait on them. Viewers stop
+// pulling tracks and reconnect after retryAfter.
+func (s *Server) NotifyShutdown(reason string, retryAfter time.Duration) {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+
+	s.shuttingDown = true
+	ev := ViewerEvent{Type: "shutdown", Reason: reason, RetryAfter: int(retryAfter.Seconds())}
+	for sub := range s.eventSubs {
+		// Make room behind any queued camera events; each subscriber gets
+		// exactly one shutdown
+		select {
+		case sub <- ev:
+		default:
+			<-sub
+			sub <- ev
+		}
+		close(sub)
+		delete(s.eventSubs, sub)
+	}
+	s.logger.Info("notified viewers of shutdown", "reason", reason)
+}
+
+// NotifyCameraEvent pushes a camera event to connected viewers. A viewer
+// too far behind misses it rather than holding up the others.
+func (s *Server) NotifyCameraEvent(ev events.Event) {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+
+	if s.shuttingDown {
+		return
+	}
+	for sub := range s.eventSubs {
+		select {
+		case sub <- ViewerEvent{Type: "camera", Camera: &ev}:
+		default:
+		}
+	}
+}
+
+// handleViewerEvents streams ViewerEvents as server-sent events:
+// GET /api/viewer/events
+func (s *Server) handleViewerEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sub := make(chan ViewerEvent, viewerEventQueue)
+	s.eventsMu.Lock()
+	if s.shuttingDown {
+		s.eventsMu.Unlock()
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	s.eventSubs[sub] = struct{}{}
+	s.eventsMu.Unlock()
+
+	defer func() {
+		s.eventsMu.Lock()
+		delete(s.eventSubs, sub)
+		s.eventsMu.Unlock()
+	}()
+
+	// The stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case ev, ok := <-sub:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
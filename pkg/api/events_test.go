@@ -0,0 +1,56 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifyShutdown(t *testing.T) {
+	s := NewServer(nil, nil, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ts := httptest.NewServer(http.HandlerFunc(s.handleViewerEvents))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	for deadline := time.Now().Add(time.Second); ; {
+		s.eventsMu.Lock()
+		n := len(s.eventSubs)
+		s.eventsMu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("viewer not subscribed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	s.NotifyShutdown("restarting", 10*time.Second)
+
+	// The stream carries the shutdown event and then ends
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(body); !strings.Contains(got, "event: shutdown\n") || !strings.Contains(got, `"retryAfter":10`) {
+		t.Errorf("stream = %q", got)
+	}
+
+	resp, err = http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("subscribe after shutdown: status %d, want 503", resp.StatusCode)
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+)
+
+// GoroutineReport is the goroutine accounting for admins
+type GoroutineReport struct {
+	goroutines.Snapshot
+
+	// Cameras the relay no longer streams that still have goroutines running
+	Orphaned []string `json:"orphaned"`
+}
+
+// GoroutinesFunc reports the running goroutines
+type GoroutinesFunc func() GoroutineReport
+
+// SetGoroutines enables GET /api/admin/goroutines. The endpoint requires
+// the admin token.
+func (s *Server) SetGoroutines(fn GoroutinesFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.goroutines = fn
+}
+
+// handleGoroutines reports goroutines per subsystem and camera:
+// GET /api/admin/goroutines
+func (s *Server) handleGoroutines(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.isAdmin(r) {
+		http.Error(w, "admin token required", http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.RLock()
+	fn := s.goroutines
+	s.mu.RUnlock()
+	if fn == nil {
+		http.Error(w, "goroutine accounting not available", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(fn())
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/store"
+)
+
+const (
+	maxLayoutName = 64
+	maxLayoutSpan = 4
+)
+
+// Layout is a named camera grid shared by the viewer and external dashboards
+type Layout struct {
+	Name      string         `json:"name"`
+	Columns   int            `json:"columns,omitempty"` // Grid width; zero lets the client decide
+	Cameras   []LayoutCamera `json:"cameras"`           // In display order
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+// LayoutCamera places one camera in a layout
+type LayoutCamera struct {
+	CameraID string `json:"cameraId"`
+	ColSpan  int    `json:"colSpan,omitempty"` // Default 1
+	RowSpan  int    `json:"rowSpan,omitempty"` // Default 1
+}
+
+// SetStore enables the layout presets under /api/layouts, persisted in st
+func (s *Server) SetStore(st store.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = st
+}
+
+// handleLayouts routes /api/layouts and /api/layouts/{name}. Reads are open
+// like /api/cameras; saving and deleting require a viewer token when tokens
+// are enabled.
+func (s *Server) handleLayouts(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	st := s.store
+	s.mu.RUnlock()
+	if st == nil {
+		http.Error(w, "layouts not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/layouts"), "/")
+	if name == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.listLayouts(w, st)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var layout Layout
+		found, err := st.Get(store.BucketLayouts, name, &layout)
+		if err != nil {
+			s.logger.Error("failed to read layout", "name", name, "error", err)
+			http.Error(w, "failed to read layout", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "layout not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, layout)
+
+	case http.MethodPut:
+		s.requireViewerToken(func(w http.ResponseWriter, r *http.Request) {
+			s.saveLayout(w, r, st, name)
+		})(w, r)
+
+	case http.MethodDelete:
+		s.requireViewerToken(func(w http.ResponseWriter, r *http.Request) {
+			if err := st.Delete(store.BucketLayouts, name); err != nil {
+				s.logger.Error("failed to delete layout", "name", name, "error", err)
+				http.Error(w, "failed to delete layout", http.StatusInternalServerError)
+				return
+			}
+			s.logger.Info("deleted layout", "name", name)
+			w.WriteHeader(http.StatusNoContent)
+		})(w, r)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// listLayouts returns every saved layout in name order
+func (s *Server) listLayouts(w http.ResponseWriter, st store.Store) {
+	layouts := make([]Layout, 0)
+	err := st.List(store.BucketLayouts, func(_ string, data []byte) error {
+		var layout Layout
+		if err := json.Unmarshal(data, &layout); err != nil {
+			return err
+		}
+		layouts = append(layouts, layout)
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to list layouts", "error", err)
+		http.Error(w, "failed to list layouts", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, layouts)
+}
+
+// saveLayout creates or replaces a layout: PUT /api/layouts/{name}
+func (s *Server) saveLayout(w http.ResponseWriter, r *http.Request, st store.Store, name string) {
+	var layout Layout
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&layout); err != nil {
+		http.Error(w, "invalid layout: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	layout.Name = name
+	layout.UpdatedAt = time.Now().UTC()
+
+	if err := validateLayout(&layout); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := st.Put(store.BucketLayouts, name, layout); err != nil {
+		s.logger.Error("failed to save layout", "name", name, "error", err)
+		http.Error(w, "failed to save layout", http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.Info("saved layout", "name", name, "cameras", len(layout.Cameras))
+	writeJSON(w, layout)
+}
+
+// validateLayout checks the name and cameras and fills in default spans
+func validateLayout(l *Layout) error {
+	if len(l.Name) > maxLayoutName {
+		return fmt.Errorf("layout name longer than %d characters", maxLayoutName)
+	}
+	for _, c := range l.Name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return fmt.Errorf("layout name may only contain letters, digits, '-' and '_'")
+		}
+	}
+	if l.Columns < 0 {
+		return fmt.Errorf("invalid columns %d", l.Columns)
+	}
+
+	seen := make(map[string]bool, len(l.Cameras))
+	for i := range l.Cameras {
+		c := &l.Cameras[i]
+		if c.CameraID == "" {
+			return fmt.Errorf("camera %d has no cameraId", i)
+		}
+		if seen[c.CameraID] {
+			return fmt.Errorf("camera %s appears twice", c.CameraID)
+		}
+		seen[c.CameraID] = true
+
+		if c.ColSpan == 0 {
+			c.ColSpan = 1
+		}
+		if c.RowSpan == 0 {
+			c.RowSpan = 1
+		}
+		if c.ColSpan < 1 || c.ColSpan > maxLayoutSpan || c.RowSpan < 1 || c.RowSpan > maxLayoutSpan {
+			return fmt.Errorf("camera %s: spans must be between 1 and %d", c.CameraID, maxLayoutSpan)
+		}
+		if l.Columns > 0 && c.ColSpan > l.Columns {
+			return fmt.Errorf("camera %s spans more than %d columns", c.CameraID, l.Columns)
+		}
+	}
+	return nil
+}
+
+// writeJSON encodes v as the response body
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/store"
+)
+
+func TestLayouts(t *testing.T) {
+	s := NewServer(nil, nil, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.SetStore(store.NewMemory())
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.handleLayouts(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := do(http.MethodPut, "/api/layouts/front", `{"columns":3,"cameras":[{"cameraId":"cam1","colSpan":2},{"cameraId":"cam2"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("save: %d %s", rec.Code, rec.Body)
+	}
+
+	var got Layout
+	rec = do(http.MethodGet, "/api/layouts/front", "")
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "front" || len(got.Cameras) != 2 || got.Cameras[0].ColSpan != 2 || got.Cameras[1].RowSpan != 1 {
+		t.Errorf("layout = %+v", got)
+	}
+
+	for _, body := range []string{
+		`{"cameras":[{"cameraId":"cam1"},{"cameraId":"cam1"}]}`,
+		`{"cameras":[{"cameraId":"cam1","rowSpan":9}]}`,
+		`{"columns":1,"cameras":[{"cameraId":"cam1","colSpan":2}]}`,
+	} {
+		if rec := do(http.MethodPut, "/api/layouts/bad", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, rec.Code)
+		}
+	}
+	if rec := do(http.MethodPut, "/api/layouts/no%20spaces", `{"cameras":[]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid name: status %d, want 400", rec.Code)
+	}
+
+	var list []Layout
+	json.NewDecoder(do(http.MethodGet, "/api/layouts", "").Body).Decode(&list)
+	if len(list) != 1 {
+		t.Errorf("list = %+v", list)
+	}
+
+	if rec := do(http.MethodDelete, "/api/layouts/front", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: status %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/layouts/front", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("after delete: status %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+)
+
+// mediaProbeTimeout bounds an OPTIONS/DESCRIBE probe for /api/cameras/{id}/media
+const mediaProbeTimeout = 10 * time.Second
+
+// handleCameraMedia returns a camera's RTSP capabilities (methods and
+// DESCRIBE media) without starting playback: GET /api/cameras/{id}/media.
+// GET /api/cameras/{id}/snapshot is passed on to handleCameraSnapshot.
+func (s *Server) handleCameraMedia(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cameraID, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/cameras/"), "/")
+	if cameraID != "" && rest == "snapshot" {
+		s.handleCameraSnapshot(w, r, cameraID)
+		return
+	}
+	if cameraID == "" || rest != "media" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if s.relay == nil {
+		http.Error(w, "relay not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), mediaProbeTimeout)
+	defer cancel()
+
+	probe, err := s.relay.CameraMedia(ctx, cameraID)
+	if errors.Is(err, relay.ErrNoStream) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to probe camera", "camera_id", cameraID, "error", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, probe)
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ErrUnknownCamera is returned by a CameraNameFunc for cameras the relay
+// doesn't know
+var ErrUnknownCamera = errors.New("unknown camera")
+
+// CameraNameFunc stores a camera's display name and position. An empty name
+// and zero order clear the override.
+type CameraNameFunc func(cameraID, name string, order int) error
+
+// CameraNameRequest is the body of PUT /api/admin/cameras/{cameraId}
+type CameraNameRequest struct {
+	Name  string `json:"name"`
+	Order int    `json:"order,omitempty"`
+}
+
+// SetCameraOrder sets a camera's display position; lower sorts first and
+// zero sorts after every positioned camera
+func (s *Server) SetCameraOrder(cameraID string, order int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if order == 0 {
+		delete(s.cameraOrder, cameraID)
+		return
+	}
+	s.cameraOrder[cameraID] = order
+}
+
+// SetCameraNamer enables PUT and DELETE /api/admin/cameras/{cameraId},
+// which rename and reorder cameras through fn. The endpoint requires the
+// admin token.
+func (s *Server) SetCameraNamer(fn CameraNameFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.namer = fn
+}
+
+// sortCameras orders cameras by position, then name. Callers hold s.mu.
+func (s *Server) sortCameras(cameras []CameraInfo) {
+	slices.SortStableFunc(cameras, func(a, b CameraInfo) int {
+		oa, oka := s.cameraOrder[a.CameraID]
+		ob, okb := s.cameraOrder[b.CameraID]
+		if oka != okb {
+			if oka {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Or(cmp.Compare(oa, ob), cmp.Compare(a.Name, b.Name), cmp.Compare(a.CameraID, b.CameraID))
+	})
+}
+
+// handleCameraName renames a camera: PUT /api/admin/cameras/{cameraId}
+// stores an override, DELETE reverts to the configured or Nest name.
+// /api/admin/cameras/{cameraId}/audio goes to handleCameraAudio, and
+// .../talkback to handleCameraTalkback.
+func (s *Server) handleCameraName(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		http.Error(w, "admin token required", http.StatusUnauthorized)
+		return
+	}
+	if cameraID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/admin/cameras/"), "/audio"); ok {
+		if cameraID == "" || strings.Contains(cameraID, "/") {
+			http.Error(w, "invalid camera path", http.StatusBadRequest)
+			return
+		}
+		s.handleCameraAudio(w, r, cameraID)
+		return
+	}
+	if cameraID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/admin/cameras/"), "/talkback"); ok {
+		if cameraID == "" || strings.Contains(cameraID, "/") {
+			http.Error(w, "invalid camera path", http.StatusBadRequest)
+			return
+		}
+		s.handleCameraTalkback(w, r, cameraID)
+		return
+	}
+
+	s.mu.RLock()
+	fn := s.namer
+	s.mu.RUnlock()
+	if fn == nil {
+		http.Error(w, "camera names not available", http.StatusNotFound)
+		return
+	}
+
+	cameraID := strings.TrimPrefix(r.URL.Path, "/api/admin/cameras/")
+	if cameraID == "" || strings.Contains(cameraID, "/") {
+		http.Error(w, "invalid camera path", http.StatusBadRequest)
+		return
+	}
+
+	var req CameraNameRequest
+	switch r.Method {
+	case http.MethodPut:
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" && req.Order == 0 {
+			http.Error(w, "name or order required", http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := fn(cameraID, req.Name, req.Order); err != nil {
+		if errors.Is(err, ErrUnknownCamera) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.logger.Error("failed to set camera name", "camera_id", cameraID, "error", err)
+		http.Error(w, "failed to set camera name", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestSortCameras(t *testing.T) {
+	s := NewServer(nil, nil, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.SetCameraOrder("yard", 2)
+	s.SetCameraOrder("door", 1)
+	s.SetCameraOrder("attic", 0) // Clears
+
+	cameras := []CameraInfo{
+		{CameraID: "attic", Name: "Attic"},
+		{CameraID: "yard", Name: "Yard"},
+		{CameraID: "garage", Name: "Garage"},
+		{CameraID: "door", Name: "Front door"},
+	}
+	s.sortCameras(cameras)
+
+	want := []string{"door", "yard", "attic", "garage"}
+	for i, cam := range cameras {
+		if cam.CameraID != want[i] {
+			t.Fatalf("order = %v, want %v", cameras, want)
+		}
+	}
+}
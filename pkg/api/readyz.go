@@ -0,0 +1,40 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ReadinessFunc returns nil once the service is ready to serve viewers, or
+// the reason it is not
+type ReadinessFunc func() error
+
+// SetReadiness sets the check behind GET /readyz
+func (s *Server) SetReadiness(fn ReadinessFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readiness = fn
+}
+
+// handleReadyz reports readiness for load balancers and orchestrators:
+// GET /readyz answers 200 when ready and 503 with the reason otherwise
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.RLock()
+	check := s.readiness
+	s.mu.RUnlock()
+
+	if check != nil {
+		if err := check(); err != nil {
+			http.Error(w, fmt.Sprintf("not ready: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
@@ -13,7 +13,12 @@ import (
 	"time"
 
 	"github.com/ethan/nest-cloudflare-relay/pkg/cloudflare"
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+	"github.com/ethan/nest-cloudflare-relay/pkg/recording"
 	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+	"github.com/ethan/nest-cloudflare-relay/pkg/store"
+	"github.com/ethan/nest-cloudflare-relay/pkg/thumbnail"
+	"github.com/ethan/nest-cloudflare-relay/pkg/timelapse"
 )
 
 //go:embed web/*
@@ -27,11 +32,40 @@ type Server struct {
 	logger      *slog.Logger
 	httpServer  *http.Server
 	mu          sync.RWMutex
-	cameraNames map[string]string // cameraID -> display name
+	cameraNames map[string]string  // cameraID -> display name
+	cameraOrder map[string]int     // cameraID -> display position, when set
+	dvr         *recording.DVR     // Optional rewind buffer
+	thumbnails  *thumbnail.Service // Optional camera thumbnails
+	timelapse   *timelapse.Service // Optional time-lapse keyframes
+	store       store.Store        // Layout presets; nil until the service opens its store
+	tokens      *tokenSigner       // Viewer token enforcement, nil when disabled
+	adminToken  string
+	diagnostics DiagnosticsFunc  // Optional support bundle builder
+	readiness   ReadinessFunc    // Reports whether the service is ready; nil is always ready
+	streams     StreamsFunc      // Live RTSP streams for trusted tools
+	namer       CameraNameFunc   // Stores display name overrides
+	cameraAudio CameraAudioFunc  // Switches audio forwarding per camera
+	goroutines  GoroutinesFunc   // Goroutine accounting for leak hunting
+	egress      EgressFunc       // Bytes sent to the SFU against the monthly budget
+	events      CameraEventsFunc // Recent Pub/Sub camera events
+	snapshots   SnapshotFunc     // Poster frames for the viewer
+	talkback    TalkbackFunc     // Plays audio through camera speakers
+
+	// Viewer event streams, ended by NotifyShutdown
+	eventsMu     sync.Mutex
+	eventSubs    map[chan ViewerEvent]struct{}
+	shuttingDown bool
 
 	// Viewer session management for reuse across refreshes
 	viewerMu       sync.RWMutex
 	viewerSessions map[string]*viewerSession // viewerId -> session info
+
+	// Sessions created through the Cloudflare proxy, by session ID
+	sessionsMu      sync.Mutex
+	proxySessions   map[string]*proxySession
+	pendingSessions map[string]int // Sessions being created, per client
+	sessionLimit    int            // Open sessions per client
+	idleTimeout     time.Duration  // Idle sessions are closed after this
 }
 
 // viewerSession tracks a viewer's Cloudflare session for reuse
@@ -48,6 +82,145 @@ type CameraInfo struct {
 	TrackName string `json:"trackName"`
 	Name      string `json:"name"`
 	Kind      string `json:"kind"` // "video" or "audio"
+
+	ThumbnailURL string `json:"thumbnailUrl,omitempty"` // Set when thumbnails are enabled
+	SnapshotURL  string `json:"snapshotUrl,omitempty"`  // Poster frame, set when snapshots are enabled
+
+	Video *VideoFormat `json:"video,omitempty"` // What the camera sends, once known
+
+	Reception *Reception `json:"reception,omitempty"` // How the camera's stream is reaching the relay, once it arrives
+	Delivery  *Delivery  `json:"delivery,omitempty"`  // How the track is reaching the SFU, once sent
+	Pacer     *Pacer     `json:"pacer,omitempty"`     // How the pacer is smoothing the camera's bursts
+}
+
+// VideoFormat describes a camera's video as signalled in its stream
+type VideoFormat struct {
+	Codec     string  `json:"codec"`
+	Width     int     `json:"width,omitempty"`
+	Height    int     `json:"height,omitempty"`
+	Profile   string  `json:"profile,omitempty"`
+	Level     string  `json:"level,omitempty"`
+	FrameRate float64 `json:"frameRate,omitempty"` // Nominal, from the SPS timing info
+}
+
+// Reception is the camera's RTSP stream as the relay received it, before
+// anything downstream touched it
+type Reception struct {
+	BitrateKbps     float64 `json:"bitrateKbps"` // Over the last second
+	BytesReceived   uint64  `json:"bytesReceived"`
+	PacketsReceived uint64  `json:"packetsReceived"`
+	PacketsLost     int64   `json:"packetsLost"`
+	Gaps            uint64  `json:"gaps"` // Times the sequence skipped ahead
+	JitterMs        float64 `json:"jitterMs"`
+	ReadTimeouts    uint64  `json:"readTimeouts"` // Read timeouts that passed without RTP, all tracks
+}
+
+// reception returns the reception of the camera's published track, or nil
+// before any of it arrived
+func reception(stat relay.RelayStats) *Reception {
+	kind := "video"
+	if stat.AudioOnly {
+		kind = "audio"
+	}
+	for _, ch := range stat.RTSP.Channels {
+		if ch.MediaType != kind || ch.Packets == 0 {
+			continue
+		}
+		return &Reception{
+			BitrateKbps:     ch.Bitrate / 1000,
+			BytesReceived:   ch.Bytes,
+			PacketsReceived: ch.Packets,
+			PacketsLost:     ch.Lost,
+			Gaps:            ch.Gaps,
+			JitterMs:        float64(ch.Jitter.Microseconds()) / 1000,
+			ReadTimeouts:    stat.RTSP.ReadTimeouts,
+		}
+	}
+	return nil
+}
+
+// Delivery is the sent track's outbound-rtp view: bytes and repairs sent,
+// and the loss and round trip from the SFU's receiver reports
+type Delivery struct {
+	BytesSent            uint64  `json:"bytesSent"`
+	PacketsSent          uint64  `json:"packetsSent"`
+	RetransmittedPackets uint64  `json:"retransmittedPackets"`
+	NACKs                uint64  `json:"nacks"`
+	PLIs                 uint64  `json:"plis"`
+	PacketsLost          int64   `json:"packetsLost"`
+	FractionLost         float64 `json:"fractionLost"`
+	JitterMs             float64 `json:"jitterMs"`
+	RTTMs                float64 `json:"rttMs,omitempty"` // Omitted until a receiver report carries one
+}
+
+// delivery returns the stats of the camera's published track, or nil before
+// anything was sent
+func delivery(stat relay.RelayStats) *Delivery {
+	out := stat.WebRTC.Video
+	if stat.AudioOnly {
+		out = stat.WebRTC.Audio
+	}
+	if out.PacketsSent == 0 {
+		return nil
+	}
+	return &Delivery{
+		BytesSent:            out.BytesSent,
+		PacketsSent:          out.PacketsSent,
+		RetransmittedPackets: out.RetransmittedPackets,
+		NACKs:                out.NACKs,
+		PLIs:                 out.PLIs,
+		PacketsLost:          out.PacketsLost,
+		FractionLost:         out.FractionLost,
+		JitterMs:             float64(out.Jitter.Microseconds()) / 1000,
+		RTTMs:                float64(out.RTT.Microseconds()) / 1000,
+	}
+}
+
+// Pacer is the camera's pacer: what is queued, and how often bursts filled
+// the queue, were drained faster than real time, or cost frames
+type Pacer struct {
+	Bypass           bool   `json:"bypass,omitempty"` // Pacing is off; only the sent counts apply
+	VideoQueueDepth  int    `json:"videoQueueDepth"`
+	AudioQueueDepth  int    `json:"audioQueueDepth"`
+	VideoPacketsSent uint64 `json:"videoPacketsSent"`
+	AudioPacketsSent uint64 `json:"audioPacketsSent"`
+	BurstsAbsorbed   uint64 `json:"burstsAbsorbed"` // Enqueues that found a track's queue full
+	CatchupEvents    uint64 `json:"catchupEvents"`  // Packets sent faster than real time to drain a queue
+	DroppedFrames    uint64 `json:"droppedFrames"`  // Video frames dropped on a full queue, GOP remainders included
+	FastForwards     uint64 `json:"fastForwards"`   // Skips to a keyframe over the latency budget
+	SkippedFrames    uint64 `json:"skippedFrames"`  // Frames those skips dropped
+}
+
+// pacer returns the pacer stats reported in a relay's stats
+func pacer(stat relay.RelayStats) *Pacer {
+	ps := stat.Pacer
+	return &Pacer{
+		Bypass:           ps.Bypass,
+		VideoQueueDepth:  ps.VideoQueueDepth,
+		AudioQueueDepth:  ps.AudioQueueDepth,
+		VideoPacketsSent: ps.VideoPacketsSent,
+		AudioPacketsSent: ps.AudioPacketsSent,
+		BurstsAbsorbed:   ps.VideoBurstsAbsorbed + ps.AudioBurstsAbsorbed,
+		CatchupEvents:    ps.VideoCatchupEvents + ps.AudioCatchupEvents,
+		DroppedFrames:    ps.VideoDroppedFrames + ps.VideoDroppedKeyframes + ps.VideoDroppedGOP,
+		FastForwards:     ps.VideoFastForwards,
+		SkippedFrames:    ps.VideoSkipped,
+	}
+}
+
+// videoFormat returns the video format reported in a relay's stats, or nil
+// for audio-only cameras
+func videoFormat(stat relay.RelayStats) *VideoFormat {
+	if stat.AudioOnly || stat.VideoCodec == "" {
+		return nil
+	}
+	vf := &VideoFormat{Codec: stat.VideoCodec}
+	if sps := stat.VideoFormat; sps != nil {
+		vf.Width, vf.Height = sps.Width, sps.Height
+		vf.Profile, vf.Level = sps.Profile(), sps.Level()
+		vf.FrameRate = sps.FrameRate
+	}
+	return vf
 }
 
 // ConfigResponse provides Cloudflare configuration for the viewer
@@ -79,7 +252,14 @@ func NewServer(
 		appID:          appID,
 		logger:         logger,
 		cameraNames:    make(map[string]string),
+		cameraOrder:    make(map[string]int),
+		eventSubs:      make(map[chan ViewerEvent]struct{}),
 		viewerSessions: make(map[string]*viewerSession),
+
+		proxySessions:   make(map[string]*proxySession),
+		pendingSessions: make(map[string]int),
+		sessionLimit:    DefaultViewerSessionLimit,
+		idleTimeout:     DefaultViewerIdleTimeout,
 	}
 }
 
@@ -96,15 +276,32 @@ func (s *Server) Start(ctx context.Context, addr string) error {
 
 	// API endpoints
 	mux.HandleFunc("/api/cameras", s.handleGetCameras)
+	mux.HandleFunc("/api/cameras/", s.requireViewerToken(s.handleCameraMedia))
 	mux.HandleFunc("/api/config", s.handleGetConfig)
-	mux.HandleFunc("/api/debug/session", s.handleDebugSession)
+	mux.HandleFunc("/api/tracks", s.handleTrackNames)
+	mux.HandleFunc("/api/debug/session", s.requireViewerToken(s.handleDebugSession))
+	mux.HandleFunc("/api/dvr/", s.requireViewerToken(s.handleDVR))
+	mux.HandleFunc("/api/thumbnails/", s.requireViewerToken(s.handleThumbnail))
+	mux.HandleFunc("/api/timelapse/", s.requireViewerToken(s.handleTimeLapse))
+	mux.HandleFunc("/api/events", s.requireViewerToken(s.handleCameraEvents))
+	mux.HandleFunc("/api/layouts", s.handleLayouts)
+	mux.HandleFunc("/api/layouts/", s.handleLayouts)
+	mux.HandleFunc("/api/admin/diagnostics", s.handleDiagnostics)
+	mux.HandleFunc("/api/admin/streams", s.handleStreams)
+	mux.HandleFunc("/api/admin/cameras/", s.handleCameraName)
+	mux.HandleFunc("/api/admin/goroutines", s.handleGoroutines)
+	mux.HandleFunc("/api/admin/egress", s.handleEgress)
+	mux.HandleFunc("/readyz", s.handleReadyz)
 
 	// Viewer session management
-	mux.HandleFunc("/api/viewer/session", s.handleViewerSession)
+	mux.HandleFunc("/api/viewer/session", s.requireViewerToken(s.handleViewerSession))
+	mux.HandleFunc("/api/viewer/token", s.handleViewerToken)
+	mux.HandleFunc("/api/viewer/events", s.handleViewerEvents)
 
-	// Cloudflare proxy endpoints (authenticated on backend)
-	mux.HandleFunc("/api/cf/sessions/new", s.handleCreateSession)
-	mux.HandleFunc("/api/cf/sessions/", s.handleSessionOperation)
+	// Cloudflare proxy endpoints (authenticated on backend, gated by viewer tokens)
+	mux.HandleFunc("/api/cf/sessions/new", s.requireViewerToken(s.handleCreateSession))
+	mux.HandleFunc("/api/cf/sessions/", s.requireViewerToken(s.handleSessionOperation))
+	mux.HandleFunc("/api/sessions/", s.requireViewerToken(s.handleSessionTracks))
 
 	// Static file server for viewer using embedded filesystem
 	staticFS, err := fs.Sub(webFS, "web/static")
@@ -216,16 +413,29 @@ func (s *Server) handleGetCameras(w http.ResponseWriter, r *http.Request) {
 					name = stat.CameraID
 				}
 
-				// Video track only (audio not currently populated)
-				// TrackName must match what bridge registers with Cloudflare: "{cameraID}-video"
+				// One track per camera: video, or audio for audio-only cameras,
+				// named as the bridge published it
+				kind, trackName, thumbnailURL, snapshotURL := "video", stat.VideoTrack, s.thumbnailURL(stat.CameraID), s.snapshotURL(stat.CameraID)
+				if stat.AudioOnly {
+					kind, trackName, thumbnailURL, snapshotURL = "audio", stat.AudioTrack, "", ""
+				}
 				cameras = append(cameras, CameraInfo{
 					CameraID:  stat.CameraID,
 					SessionID: stat.SessionID,
-					TrackName: fmt.Sprintf("%s-video", stat.CameraID),
+					TrackName: trackName,
 					Name:      name,
-					Kind:      "video",
+					Kind:      kind,
+
+					ThumbnailURL: thumbnailURL,
+					SnapshotURL:  snapshotURL,
+
+					Video:     videoFormat(stat),
+					Reception: reception(stat),
+					Delivery:  delivery(stat),
+					Pacer:     pacer(stat),
 				})
 			}
+			s.sortCameras(cameras)
 			s.mu.RUnlock()
 		}
 	}
@@ -316,6 +526,11 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // handleCreateSession proxies session creation requests to Cloudflare
 func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -325,13 +540,22 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
+	client := s.clientID(r)
+	release, err := s.reserveSession(client)
+	if err != nil {
+		s.sessionError(w, r, "", client, err)
+		return
+	}
+
 	// Create session via Cloudflare client (authenticated)
 	resp, err := s.cfClient.CreateSession(ctx)
 	if err != nil {
+		release("")
 		s.logger.Error("failed to create session", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	release(resp.SessionID)
 
 	// Return response to frontend
 	w.Header().Set("Content-Type", "application/json")
@@ -351,6 +575,12 @@ func (s *Server) handleSessionOperation(w http.ResponseWriter, r *http.Request)
 	sessionID := parts[0]
 	operation := parts[1]
 
+	client := s.clientID(r)
+	if err := s.authorizeSession(client, sessionID); err != nil {
+		s.sessionError(w, r, sessionID, client, err)
+		return
+	}
+
 	switch operation {
 	case "tracks":
 		if len(parts) >= 3 {
@@ -369,6 +599,8 @@ func (s *Server) handleSessionOperation(w http.ResponseWriter, r *http.Request)
 		}
 	case "renegotiate":
 		s.handleRenegotiate(w, r, sessionID)
+	case "keepalive":
+		s.handleKeepalive(w, r, sessionID)
 	default:
 		http.Error(w, "unknown operation", http.StatusNotFound)
 	}
@@ -391,6 +623,13 @@ func (s *Server) handleAddTracks(w http.ResponseWriter, r *http.Request, session
 		return
 	}
 
+	// Resolve "everything this session publishes" into named tracks
+	if err := expandAutoDiscover(ctx, &req, s.sessionTracks); err != nil {
+		s.logger.Error("failed to discover tracks", "session_id", sessionID, "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Log the request for debugging
 	s.logger.Info("viewer pulling tracks",
 		"viewer_session_id", sessionID,
@@ -406,6 +645,10 @@ func (s *Server) handleAddTracks(w http.ResponseWriter, r *http.Request, session
 		return
 	}
 
+	for _, t := range resp.Tracks {
+		s.trackMids(sessionID, true, t.Mid)
+	}
+
 	// Log the response for debugging
 	s.logger.Info("Cloudflare AddTracks response",
 		"viewer_session_id", sessionID,
@@ -519,6 +762,10 @@ func (s *Server) handleCloseTracks(w http.ResponseWriter, r *http.Request, sessi
 		return
 	}
 
+	for _, t := range req.Tracks {
+		s.trackMids(sessionID, false, t.Mid)
+	}
+
 	s.logger.Info("Cloudflare CloseTracks response",
 		"viewer_session_id", sessionID,
 		"requires_renegotiation", resp.RequiresImmediateRenegotiation,
@@ -580,11 +827,18 @@ func (s *Server) handleViewerSession(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
+	client := s.clientID(r)
 
-	// Check for existing session
+	// Check for existing session; another client presenting the same viewer
+	// ID gets a session of its own
 	s.viewerMu.RLock()
 	existing, exists := s.viewerSessions[req.ViewerID]
 	s.viewerMu.RUnlock()
+	if exists {
+		if owner, ok := s.sessionOwner(existing.sessionID); ok && owner != client {
+			exists = false
+		}
+	}
 
 	if exists {
 		// Validate session still exists in Cloudflare
@@ -593,6 +847,7 @@ func (s *Server) handleViewerSession(w http.ResponseWriter, r *http.Request) {
 			s.viewerMu.Lock()
 			existing.lastUsed = time.Now()
 			s.viewerMu.Unlock()
+			s.authorizeSession(client, existing.sessionID)
 
 			s.logger.Info("reusing viewer session",
 				"viewer_id", req.ViewerID,
@@ -616,15 +871,23 @@ func (s *Server) handleViewerSession(w http.ResponseWriter, r *http.Request) {
 			"old_session_id", existing.sessionID)
 	}
 
+	release, err := s.reserveSession(client)
+	if err != nil {
+		s.sessionError(w, r, "", client, err)
+		return
+	}
+
 	// Create new session
 	resp, err := s.cfClient.CreateSession(ctx)
 	if err != nil {
+		release("")
 		s.logger.Error("failed to create viewer session",
 			"viewer_id", req.ViewerID,
 			"error", err)
 		http.Error(w, "failed to create session", http.StatusInternalServerError)
 		return
 	}
+	release(resp.SessionID)
 
 	// Store mapping
 	s.viewerMu.Lock()
@@ -637,7 +900,8 @@ func (s *Server) handleViewerSession(w http.ResponseWriter, r *http.Request) {
 
 	s.logger.Info("created new viewer session",
 		"viewer_id", req.ViewerID,
-		"session_id", resp.SessionID)
+		"session_id", resp.SessionID,
+		"client", client)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(FindViewerSessionResponse{
@@ -652,29 +916,37 @@ func (s *Server) isViewerSessionValid(ctx context.Context, sessionID string) boo
 	return err == nil
 }
 
-// startViewerCleanup starts a background goroutine to clean up stale viewer sessions
+// startViewerCleanup starts a background goroutine to close idle viewer
+// sessions and clean up stale viewer IDs
 func (s *Server) startViewerCleanup(ctx context.Context) {
-	ticker := time.NewTicker(5 * time.Minute)
+	ticker := time.NewTicker(time.Minute)
 	go func() {
+		defer goroutines.Track("api.viewerCleanup", "")()
 		defer ticker.Stop()
 		for {
 			select {
 			case <-ctx.Done():
 				s.logger.Info("stopping viewer session cleanup")
 				return
-			case <-ticker.C:
+			case now := <-ticker.C:
+				s.closeIdleSessions(ctx, now)
 				s.cleanupStaleViewerSessions()
 			}
 		}
 	}()
 }
 
-// cleanupStaleViewerSessions removes sessions that haven't been used in 30 minutes
+// cleanupStaleViewerSessions removes viewer IDs whose sessions haven't been
+// used within the idle timeout
 func (s *Server) cleanupStaleViewerSessions() {
+	s.sessionsMu.Lock()
+	idle := s.idleTimeout
+	s.sessionsMu.Unlock()
+
 	s.viewerMu.Lock()
 	defer s.viewerMu.Unlock()
 
-	threshold := time.Now().Add(-30 * time.Minute)
+	threshold := time.Now().Add(-idle)
 	cleaned := 0
 	for viewerID, session := range s.viewerSessions {
 		if session.lastUsed.Before(threshold) {
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/cloudflare"
+)
+
+// Defaults for viewer sessions created through the Cloudflare proxy
+const (
+	DefaultViewerSessionLimit = 8                // Open sessions per client
+	DefaultViewerIdleTimeout  = 30 * time.Minute // Without proxy requests or keepalives
+)
+
+var (
+	errSessionLimit    = errors.New("too many open viewer sessions for this client")
+	errSessionNotOwned = errors.New("session belongs to another client")
+	errSessionCamera   = errors.New("camera sessions cannot be modified through the proxy")
+)
+
+// proxySession is a viewer session created (or first used) through the
+// Cloudflare proxy. Nothing on Cloudflare's side ends an abandoned session,
+// so the proxy closes its tracks once it goes idle.
+type proxySession struct {
+	client    string // Token fingerprint or remote address of the creator
+	createdAt time.Time
+	lastUsed  time.Time
+	mids      map[string]bool // Pulled tracks not yet closed
+}
+
+// SetViewerSessionLimits caps the sessions each client can hold open through
+// the proxy and closes sessions idle for longer than idle. Zero values use
+// DefaultViewerSessionLimit and DefaultViewerIdleTimeout.
+func (s *Server) SetViewerSessionLimits(perClient int, idle time.Duration) {
+	if perClient <= 0 {
+		perClient = DefaultViewerSessionLimit
+	}
+	if idle <= 0 {
+		idle = DefaultViewerIdleTimeout
+	}
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	s.sessionLimit = perClient
+	s.idleTimeout = idle
+}
+
+// clientID identifies who is calling the proxy: the viewer token when tokens
+// are in use (hashed, so tokens never sit in memory or logs), otherwise the
+// remote address
+func (s *Server) clientID(r *http.Request) string {
+	if s.isAdmin(r) {
+		return "admin"
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+		sum := sha256.Sum256([]byte(token))
+		return "token:" + hex.EncodeToString(sum[:6])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+// reserveSession holds one of client's session slots while a session is
+// created. The returned release records the new session, or frees the slot
+// when sessionID is empty.
+func (s *Server) reserveSession(client string) (release func(sessionID string), err error) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	open := s.pendingSessions[client]
+	for _, ps := range s.proxySessions {
+		if ps.client == client {
+			open++
+		}
+	}
+	if open >= s.sessionLimit {
+		return nil, errSessionLimit
+	}
+	s.pendingSessions[client]++
+
+	return func(sessionID string) {
+		s.sessionsMu.Lock()
+		defer s.sessionsMu.Unlock()
+		if s.pendingSessions[client]--; s.pendingSessions[client] <= 0 {
+			delete(s.pendingSessions, client)
+		}
+		if sessionID != "" {
+			now := time.Now()
+			s.proxySessions[sessionID] = &proxySession{client: client, createdAt: now, lastUsed: now, mids: make(map[string]bool)}
+		}
+	}, nil
+}
+
+// authorizeSession lets an operation on sessionID through if client owns
+// it, and marks the session used. Camera sessions are refused outright.
+// Sessions the proxy doesn't know, such as ones created before a restart,
+// are adopted by their first caller.
+func (s *Server) authorizeSession(client, sessionID string) error {
+	if s.isCameraSession(sessionID) {
+		return errSessionCamera
+	}
+
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	now := time.Now()
+	ps, ok := s.proxySessions[sessionID]
+	if !ok {
+		s.proxySessions[sessionID] = &proxySession{client: client, createdAt: now, lastUsed: now, mids: make(map[string]bool)}
+		s.logger.Info("adopted viewer session", "session_id", sessionID, "client", client)
+		return nil
+	}
+	if ps.client != client {
+		return errSessionNotOwned
+	}
+	ps.lastUsed = now
+	return nil
+}
+
+// isCameraSession reports whether a relay publishes on sessionID
+func (s *Server) isCameraSession(sessionID string) bool {
+	if s.relay == nil {
+		return false
+	}
+	for _, rs := range s.relay.GetRelayStats() {
+		if rs.SessionID == sessionID {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionOwner returns who owns sessionID, if the proxy knows it
+func (s *Server) sessionOwner(sessionID string) (string, bool) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	ps, ok := s.proxySessions[sessionID]
+	if !ok {
+		return "", false
+	}
+	return ps.client, true
+}
+
+// trackMids records the tracks a session pulled, or forgets closed ones
+func (s *Server) trackMids(sessionID string, open bool, mids ...string) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	ps, ok := s.proxySessions[sessionID]
+	if !ok {
+		return
+	}
+	for _, mid := range mids {
+		if mid == "" {
+			continue
+		}
+		if open {
+			ps.mids[mid] = true
+		} else {
+			delete(ps.mids, mid)
+		}
+	}
+}
+
+// sessionError writes the response for a refused session request
+func (s *Server) sessionError(w http.ResponseWriter, r *http.Request, sessionID, client string, err error) {
+	status := http.StatusForbidden
+	if errors.Is(err, errSessionLimit) {
+		status = http.StatusTooManyRequests
+	}
+	s.logger.Warn("refused viewer session request",
+		"path", r.URL.Path,
+		"session_id", sessionID,
+		"client", client,
+		"error", err)
+	http.Error(w, err.Error(), status)
+}
+
+// closeIdleSessions closes the tracks of sessions idle past the timeout and
+// forgets them, along with any viewer IDs mapped to them
+func (s *Server) closeIdleSessions(ctx context.Context, now time.Time) {
+	type idle struct {
+		sessionID string
+		client    string
+		mids      []string
+	}
+	var closing []idle
+
+	s.sessionsMu.Lock()
+	cutoff := now.Add(-s.idleTimeout)
+	for sessionID, ps := range s.proxySessions {
+		if ps.lastUsed.After(cutoff) {
+			continue
+		}
+		c := idle{sessionID: sessionID, client: ps.client}
+		for mid := range ps.mids {
+			c.mids = append(c.mids, mid)
+		}
+		closing = append(closing, c)
+		delete(s.proxySessions, sessionID)
+	}
+	s.sessionsMu.Unlock()
+
+	if len(closing) == 0 {
+		return
+	}
+
+	closed := make(map[string]bool, len(closing))
+	for _, c := range closing {
+		closed[c.sessionID] = true
+		if len(c.mids) > 0 && s.cfClient != nil {
+			req := &cloudflare.CloseTracksRequest{Force: true}
+			for _, mid := range c.mids {
+				req.Tracks = append(req.Tracks, cloudflare.CloseTrackObject{Mid: mid})
+			}
+			if _, err := s.cfClient.CloseTracks(ctx, c.sessionID, req); err != nil {
+				s.logger.Warn("failed to close idle viewer session tracks", "session_id", c.sessionID, "error", err)
+			}
+		}
+		s.logger.Info("closed idle viewer session",
+			"session_id", c.sessionID,
+			"client", c.client,
+			"tracks", len(c.mids))
+	}
+
+	s.viewerMu.Lock()
+	for viewerID, vs := range s.viewerSessions {
+		if closed[vs.sessionID] {
+			delete(s.viewerSessions, viewerID)
+		}
+	}
+	s.viewerMu.Unlock()
+}
+
+// handleKeepalive marks a viewer session in use, so a viewer that is only
+// watching isn't closed as idle: PUT /api/cf/sessions/{id}/keepalive
+func (s *Server) handleKeepalive(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
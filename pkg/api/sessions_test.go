@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestViewerSessionOwnership(t *testing.T) {
+	s := NewServer(nil, nil, "app", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.SetViewerSessionLimits(2, time.Minute)
+
+	alice := httptest.NewRequest("POST", "/api/cf/sessions/new", nil)
+	alice.Header.Set("Authorization", "Bearer alice-token")
+	bob := httptest.NewRequest("POST", "/api/cf/sessions/new", nil)
+	bob.RemoteAddr = "192.0.2.7:51234"
+	a, b := s.clientID(alice), s.clientID(bob)
+	if a == b || b != "addr:192.0.2.7" {
+		t.Fatalf("client IDs %q, %q", a, b)
+	}
+
+	// A slot held by a creation in flight counts toward the limit
+	release1, err := s.reserveSession(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release2, err := s.reserveSession(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.reserveSession(a); err != errSessionLimit {
+		t.Errorf("third session: err = %v, want %v", err, errSessionLimit)
+	}
+	release1("s1")
+	release2("") // Creation failed
+	if _, err := s.reserveSession(a); err != nil {
+		t.Errorf("slot of failed creation not freed: %v", err)
+	}
+
+	if err := s.authorizeSession(a, "s1"); err != nil {
+		t.Errorf("owner refused: %v", err)
+	}
+	if err := s.authorizeSession(b, "s1"); err != errSessionNotOwned {
+		t.Errorf("other client: err = %v, want %v", err, errSessionNotOwned)
+	}
+	if err := s.authorizeSession(b, "s2"); err != nil {
+		t.Errorf("unknown session not adopted: %v", err)
+	}
+	s.trackMids("s1", true, "0", "1")
+	s.trackMids("s1", false, "1")
+	s.viewerSessions["viewer1"] = &viewerSession{sessionID: "s1"}
+
+	// s2 stays in use; s1 idles out and takes its viewer ID along
+	s.sessionsMu.Lock()
+	s.proxySessions["s1"].lastUsed = time.Now().Add(-2 * time.Minute)
+	if mids := s.proxySessions["s1"].mids; len(mids) != 1 || !mids["0"] {
+		t.Errorf("open mids = %v", mids)
+	}
+	s.sessionsMu.Unlock()
+	s.closeIdleSessions(context.Background(), time.Now())
+
+	if _, ok := s.sessionOwner("s1"); ok {
+		t.Error("idle session not closed")
+	}
+	if _, ok := s.sessionOwner("s2"); !ok {
+		t.Error("active session closed")
+	}
+	if _, ok := s.viewerSessions["viewer1"]; ok {
+		t.Error("viewer ID of closed session kept")
+	}
+}
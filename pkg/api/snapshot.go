@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// snapshotTimeout bounds producing a snapshot, which may wait on the Nest
+// command queue
+const snapshotTimeout = 20 * time.Second
+
+// ErrNoSnapshot is returned by a SnapshotFunc that has no image of the camera
+var ErrNoSnapshot = errors.New("no snapshot available")
+
+// SnapshotFunc returns a JPEG of a camera, at most width pixels wide when
+// width is positive
+type SnapshotFunc func(ctx context.Context, cameraID string, width int) ([]byte, error)
+
+// SetSnapshots enables GET /api/cameras/{id}/snapshot, a poster frame for
+// the viewer to show until the stream plays
+func (s *Server) SetSnapshots(fn SnapshotFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots = fn
+}
+
+// handleCameraSnapshot serves a camera's snapshot:
+// GET /api/cameras/{id}/snapshot?width=<px>
+func (s *Server) handleCameraSnapshot(w http.ResponseWriter, r *http.Request, cameraID string) {
+	s.mu.RLock()
+	fn := s.snapshots
+	s.mu.RUnlock()
+	if fn == nil {
+		http.Error(w, "snapshots not enabled", http.StatusNotFound)
+		return
+	}
+
+	width := 0
+	if v := r.URL.Query().Get("width"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid width", http.StatusBadRequest)
+			return
+		}
+		width = n
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), snapshotTimeout)
+	defer cancel()
+
+	jpeg, err := fn(ctx, cameraID, width)
+	if errors.Is(err, ErrNoSnapshot) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to get snapshot", "camera_id", cameraID, "error", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(jpeg)
+}
+
+// snapshotURL returns the snapshot path for a camera, or "" when disabled.
+// The caller must hold s.mu.
+func (s *Server) snapshotURL(cameraID string) string {
+	if s.snapshots == nil {
+		return ""
+	}
+	return "/api/cameras/" + cameraID + "/snapshot"
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleCameraSnapshot(t *testing.T) {
+	s := NewServer(nil, nil, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	rec := httptest.NewRecorder()
+	s.handleCameraMedia(rec, httptest.NewRequest(http.MethodGet, "/api/cameras/cam1/snapshot", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("without snapshots: status %d, want 404", rec.Code)
+	}
+
+	var gotWidth int
+	s.SetSnapshots(func(ctx context.Context, cameraID string, width int) ([]byte, error) {
+		if cameraID != "cam1" {
+			return nil, ErrNoSnapshot
+		}
+		gotWidth = width
+		return []byte("\xff\xd8jpeg"), nil
+	})
+
+	rec = httptest.NewRecorder()
+	s.handleCameraMedia(rec, httptest.NewRequest(http.MethodGet, "/api/cameras/cam1/snapshot?width=320", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/jpeg" || rec.Body.String() != "\xff\xd8jpeg" || gotWidth != 320 {
+		t.Errorf("content type %q, body %q, width %d", ct, rec.Body, gotWidth)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleCameraMedia(rec, httptest.NewRequest(http.MethodGet, "/api/cameras/cam2/snapshot", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("no image yet: status %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleCameraMedia(rec, httptest.NewRequest(http.MethodGet, "/api/cameras/cam1/snapshot?width=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("bad width: status %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// StreamInfo is a camera's live Nest RTSP stream
+type StreamInfo struct {
+	CameraID  string    `json:"cameraId"`
+	Name      string    `json:"name"`
+	RTSPURL   string    `json:"rtspUrl"`   // Includes the stream token
+	ExpiresAt time.Time `json:"expiresAt"` // Moves forward each time the relay extends the stream
+}
+
+// StreamsFunc returns the live streams
+type StreamsFunc func() []StreamInfo
+
+// SetStreams enables GET /api/admin/streams, which hands the relay's live
+// RTSP URLs to trusted local tools. The endpoint requires the admin token.
+func (s *Server) SetStreams(fn StreamsFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams = fn
+}
+
+// handleStreams lists live streams: GET /api/admin/streams. Tools reusing a
+// stream must not extend or stop it; the relay owns its lifecycle, and the
+// URL changes whenever the relay has to generate a new stream.
+func (s *Server) handleStreams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.isAdmin(r) {
+		http.Error(w, "admin token required", http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.RLock()
+	fn := s.streams
+	s.mu.RUnlock()
+	if fn == nil {
+		http.Error(w, "streams not available", http.StatusNotFound)
+		return
+	}
+
+	streams := fn()
+	s.mu.RLock()
+	for i := range streams {
+		if name := s.cameraNames[streams[i].CameraID]; name != "" {
+			streams[i].Name = name
+		} else {
+			streams[i].Name = streams[i].CameraID
+		}
+	}
+	s.mu.RUnlock()
+
+	s.logger.Info("served stream URLs", "streams", len(streams), "remote_addr", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(streams)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// maxTalkbackSize bounds a talkback upload, minutes of speech at the
+	// bitrates Opus encoders use for voice
+	maxTalkbackSize = 2 << 20
+
+	// maxTalkbackTime bounds how long a talkback request may play; the
+	// server's read and write timeouts are lifted to it
+	maxTalkbackTime = 5 * time.Minute
+)
+
+// Errors a TalkbackFunc returns for the API to report
+var (
+	ErrTalkbackUnavailable = errors.New("talkback not available")
+	ErrInvalidAudio        = errors.New("invalid talkback audio")
+)
+
+// TalkbackFunc plays Ogg Opus audio through a camera's speaker, returning
+// once it has played or ctx ends
+type TalkbackFunc func(ctx context.Context, cameraID string, audio io.Reader) error
+
+// SetTalkback enables POST /api/admin/cameras/{cameraId}/talkback, which
+// plays the Ogg Opus body through the camera's speaker, for doorbell
+// intercoms. The endpoint requires the admin token: viewers may watch, but
+// not speak at the door.
+func (s *Server) SetTalkback(fn TalkbackFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.talkback = fn
+}
+
+// handleCameraTalkback plays an upload through a camera's speaker: POST
+// with an audio/ogg body. The response waits until the audio has played, so
+// a chunked upload plays live. Callers check the admin token.
+func (s *Server) handleCameraTalkback(w http.ResponseWriter, r *http.Request, cameraID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.mu.RLock()
+	fn := s.talkback
+	s.mu.RUnlock()
+	if fn == nil {
+		http.Error(w, "talkback not enabled", http.StatusNotFound)
+		return
+	}
+
+	// The audio plays in real time, longer than the server's timeouts allow
+	deadline := time.Now().Add(maxTalkbackTime)
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(deadline)
+	rc.SetWriteDeadline(deadline)
+	ctx, cancel := context.WithDeadline(r.Context(), deadline)
+	defer cancel()
+
+	err := fn(ctx, cameraID, http.MaxBytesReader(w, r.Body, maxTalkbackSize))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, ErrUnknownCamera):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrTalkbackUnavailable):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrInvalidAudio):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.As(err, &tooLarge):
+		http.Error(w, "talkback audio too large", http.StatusRequestEntityTooLarge)
+	case errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil:
+		http.Error(w, "talkback too long", http.StatusRequestEntityTooLarge)
+	case r.Context().Err() != nil:
+		// The client went away; the audio stopped with it
+	default:
+		s.logger.Error("talkback failed", "camera_id", cameraID, "error", err)
+		http.Error(w, "talkback failed", http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleCameraTalkback(t *testing.T) {
+	s := NewServer(nil, nil, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.SetAdminToken("secret")
+	s.SetViewerTokenKey([]byte("0123456789abcdef"), time.Hour)
+	viewer, _, err := s.MintViewerToken(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(method, cameraID, token, body string) int {
+		req := httptest.NewRequest(method, "/api/admin/cameras/"+cameraID+"/talkback", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.handleCameraName(rec, req)
+		return rec.Code
+	}
+	post := func(cameraID, body string) int {
+		return request(http.MethodPost, cameraID, "secret", body)
+	}
+
+	if code := post("door", "OggS"); code != http.StatusNotFound {
+		t.Errorf("without talkback: status %d, want 404", code)
+	}
+
+	var played string
+	s.SetTalkback(func(ctx context.Context, cameraID string, audio io.Reader) error {
+		switch cameraID {
+		case "door":
+		case "hall":
+			return fmt.Errorf("%w: camera streams over RTSP", ErrTalkbackUnavailable)
+		default:
+			return fmt.Errorf("%w: %s", ErrUnknownCamera, cameraID)
+		}
+		b, _ := io.ReadAll(audio)
+		if !strings.HasPrefix(string(b), "OggS") {
+			return ErrInvalidAudio
+		}
+		played = string(b)
+		return nil
+	})
+
+	// Viewers may watch the door, not speak at it
+	if code := request(http.MethodPost, "door", viewer, "OggS opus"); code != http.StatusUnauthorized || played != "" {
+		t.Errorf("viewer token: status %d, played %q", code, played)
+	}
+	if code := post("door", "OggS opus"); code != http.StatusNoContent || played != "OggS opus" {
+		t.Errorf("talkback: status %d, played %q", code, played)
+	}
+	if code := post("door", "RIFF"); code != http.StatusBadRequest {
+		t.Errorf("not Ogg: status %d, want 400", code)
+	}
+	if code := post("hall", "OggS"); code != http.StatusConflict {
+		t.Errorf("no talkback: status %d, want 409", code)
+	}
+	if code := post("attic", "OggS"); code != http.StatusNotFound {
+		t.Errorf("unknown camera: status %d, want 404", code)
+	}
+
+	if code := request(http.MethodGet, "door", "secret", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d, want 405", code)
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/thumbnail"
+)
+
+// SetThumbnails enables /api/thumbnails/ and adds thumbnail URLs to the
+// camera list
+func (s *Server) SetThumbnails(thumbs *thumbnail.Service) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.thumbnails = thumbs
+}
+
+// handleThumbnail serves a camera's latest thumbnail: GET /api/thumbnails/{cameraId}.jpg
+func (s *Server) handleThumbnail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.RLock()
+	thumbs := s.thumbnails
+	s.mu.RUnlock()
+	if thumbs == nil {
+		http.Error(w, "thumbnails not enabled", http.StatusNotFound)
+		return
+	}
+
+	cameraID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/thumbnails/"), ".jpg")
+	if cameraID == "" || strings.Contains(cameraID, "/") {
+		http.Error(w, "invalid thumbnail path", http.StatusBadRequest)
+		return
+	}
+
+	thumb, ok := thumbs.Get(cameraID)
+	if !ok {
+		http.Error(w, "no thumbnail yet", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, "", thumb.CapturedAt, bytes.NewReader(thumb.JPEG))
+}
+
+// thumbnailURL returns the thumbnail path for a camera, or "" when disabled.
+// The caller must hold s.mu.
+func (s *Server) thumbnailURL(cameraID string) string {
+	if s.thumbnails == nil {
+		return ""
+	}
+	return "/api/thumbnails/" + cameraID + ".jpg"
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/timelapse"
+)
+
+// timelapseTimeout bounds how long one time-lapse may take to assemble and
+// send; a week of 5-minute keyframes remuxes in well under a minute
+const timelapseTimeout = 5 * time.Minute
+
+// TimeLapseResponse lists a camera's kept keyframes
+type TimeLapseResponse struct {
+	CameraID string      `json:"cameraId"`
+	VideoURL string      `json:"videoUrl"`
+	Frames   []time.Time `json:"frames"`
+}
+
+// SetTimeLapse enables the time-lapse endpoints under /api/timelapse/
+func (s *Server) SetTimeLapse(tl *timelapse.Service) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timelapse = tl
+}
+
+// handleTimeLapse routes /api/timelapse/{cameraId} (frame list) and
+// /api/timelapse/{cameraId}.mp4?from=&to=&fps= (assembled video). from and
+// to are RFC 3339 and default to everything kept.
+func (s *Server) handleTimeLapse(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.RLock()
+	tl := s.timelapse
+	s.mu.RUnlock()
+	if tl == nil {
+		http.Error(w, "time-lapse not enabled", http.StatusNotFound)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/api/timelapse/")
+	cameraID, video := strings.CutSuffix(name, ".mp4")
+	if cameraID == "" || strings.ContainsAny(cameraID, `/\`) || strings.Contains(cameraID, "..") {
+		http.Error(w, "invalid time-lapse path", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	var from, to time.Time
+	for param, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		v := query.Get(param)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid "+param+": want RFC 3339", http.StatusBadRequest)
+			return
+		}
+		*t = parsed
+	}
+
+	if !video {
+		frames, err := tl.Frames(cameraID, from, to)
+		if err != nil {
+			s.logger.Error("failed to list time-lapse frames", "camera_id", cameraID, "error", err)
+			http.Error(w, "failed to list frames", http.StatusInternalServerError)
+			return
+		}
+		resp := TimeLapseResponse{
+			CameraID: cameraID,
+			VideoURL: "/api/timelapse/" + cameraID + ".mp4",
+			Frames:   make([]time.Time, len(frames)),
+		}
+		for i, f := range frames {
+			resp.Frames[i] = f.Time
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	fps := timelapse.DefaultFPS
+	if v := query.Get("fps"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > timelapse.MaxFPS {
+			http.Error(w, "invalid fps", http.StatusBadRequest)
+			return
+		}
+		fps = n
+	}
+
+	if frames, err := tl.Frames(cameraID, from, to); err != nil || len(frames) == 0 {
+		http.Error(w, timelapse.ErrNoFrames.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Assembly outlives the server's write timeout
+	ctx, cancel := context.WithTimeout(r.Context(), timelapseTimeout)
+	defer cancel()
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timelapseTimeout))
+
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().Set("Content-Disposition", `inline; filename="`+cameraID+`-timelapse.mp4"`)
+	if err := tl.Assemble(ctx, cameraID, from, to, fps, w); err != nil && !errors.Is(ctx.Err(), context.Canceled) {
+		// Headers are gone once ffmpeg has written; the client sees a short file
+		s.logger.Error("failed to assemble time-lapse", "camera_id", cameraID, "error", err)
+	}
+}
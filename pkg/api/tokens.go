@@ -0,0 +1,173 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultViewerTokenTTL is how long minted viewer tokens stay valid unless
+// configured otherwise
+const DefaultViewerTokenTTL = time.Hour
+
+var (
+	errTokenMissing = errors.New("viewer token required")
+	errTokenInvalid = errors.New("invalid viewer token")
+	errTokenExpired = errors.New("viewer token expired")
+)
+
+// tokenSigner mints and verifies viewer tokens of the form
+// "<expiry unix>.<nonce>.<signature>", where the signature is an HMAC-SHA256
+// over expiry and nonce. Tokens are stateless: nothing is stored server-side,
+// and rotating the key revokes every outstanding token.
+type tokenSigner struct {
+	key []byte
+	ttl time.Duration
+}
+
+// mint returns a token valid until now+ttl
+func (t *tokenSigner) mint(now time.Time, ttl time.Duration) (string, time.Time) {
+	if ttl <= 0 {
+		ttl = t.ttl
+	}
+	expires := now.Add(ttl).Truncate(time.Second)
+
+	nonce := make([]byte, 12)
+	rand.Read(nonce)
+
+	payload := strconv.FormatInt(expires.Unix(), 10) + "." + base64.RawURLEncoding.EncodeToString(nonce)
+	return payload + "." + t.sign(payload), expires
+}
+
+// verify checks the signature and expiry of a token
+func (t *tokenSigner) verify(token string, now time.Time) error {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return errTokenInvalid
+	}
+	payload, sig := token[:i], token[i+1:]
+	if subtle.ConstantTimeCompare([]byte(sig), []byte(t.sign(payload))) != 1 {
+		return errTokenInvalid
+	}
+
+	expStr, _, _ := strings.Cut(payload, ".")
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil {
+		return errTokenInvalid
+	}
+	if now.Unix() >= exp {
+		return errTokenExpired
+	}
+	return nil
+}
+
+func (t *tokenSigner) sign(payload string) string {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte("viewer:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ViewerTokenResponse is returned by /api/viewer/token
+type ViewerTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// SetViewerTokenKey requires a signed, expiring token on every endpoint that
+// creates or modifies Cloudflare sessions. Tokens are minted by
+// /api/viewer/token (admin token required) or MintViewerToken and are passed
+// to the viewer as ?token= in its URL. A ttl of zero uses DefaultViewerTokenTTL.
+func (s *Server) SetViewerTokenKey(key []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultViewerTokenTTL
+	}
+	s.tokens = &tokenSigner{key: key, ttl: ttl}
+}
+
+// SetAdminToken sets the bearer token for privileged endpoints such as
+// viewer token minting. Without one, those endpoints are disabled.
+func (s *Server) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+// MintViewerToken returns a viewer token valid for ttl (zero uses the
+// configured default), for embedders handing out viewer links themselves
+func (s *Server) MintViewerToken(ttl time.Duration) (string, time.Time, error) {
+	if s.tokens == nil {
+		return "", time.Time{}, fmt.Errorf("viewer tokens not enabled")
+	}
+	token, expires := s.tokens.mint(time.Now(), ttl)
+	return token, expires, nil
+}
+
+// handleViewerToken mints a viewer token: POST /api/viewer/token?ttl=30m
+func (s *Server) handleViewerToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.isAdmin(r) {
+		http.Error(w, "admin token required", http.StatusUnauthorized)
+		return
+	}
+	if s.tokens == nil {
+		http.Error(w, "viewer tokens not enabled", http.StatusNotFound)
+		return
+	}
+
+	var ttl time.Duration
+	if v := r.URL.Query().Get("ttl"); v != "" {
+		var err error
+		if ttl, err = time.ParseDuration(v); err != nil || ttl <= 0 {
+			http.Error(w, "invalid ttl", http.StatusBadRequest)
+			return
+		}
+	}
+
+	token, expires := s.tokens.mint(time.Now(), ttl)
+	s.logger.Info("minted viewer token", "expires_at", expires, "remote_addr", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ViewerTokenResponse{Token: token, ExpiresAt: expires})
+}
+
+// isAdmin checks the request's bearer token against the admin token
+func (s *Server) isAdmin(r *http.Request) bool {
+	if s.adminToken == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
+// requireViewerToken rejects requests without a valid viewer token when
+// tokens are enabled. The admin token is accepted as well.
+func (s *Server) requireViewerToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.tokens == nil || s.isAdmin(r) {
+			next(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		err := errTokenMissing
+		if ok && token != "" {
+			err = s.tokens.verify(token, time.Now())
+		}
+		if err != nil {
+			s.logger.Warn("rejected viewer request", "path", r.URL.Path, "error", err, "remote_addr", r.RemoteAddr)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestViewerTokens(t *testing.T) {
+	signer := &tokenSigner{key: []byte("0123456789abcdef"), ttl: time.Hour}
+	now := time.Unix(1700000000, 0)
+
+	token, expires := signer.mint(now, 0)
+	if want := now.Add(time.Hour); !expires.Equal(want) {
+		t.Errorf("expires = %v, want %v", expires, want)
+	}
+	if err := signer.verify(token, now.Add(59*time.Minute)); err != nil {
+		t.Errorf("fresh token rejected: %v", err)
+	}
+	if err := signer.verify(token, now.Add(time.Hour)); err != errTokenExpired {
+		t.Errorf("expired token: err = %v, want %v", err, errTokenExpired)
+	}
+
+	// Extending the expiry invalidates the signature
+	exp, rest, _ := strings.Cut(token, ".")
+	forged := exp + "9" + "." + rest
+	if err := signer.verify(forged, now); err != errTokenInvalid {
+		t.Errorf("forged token: err = %v, want %v", err, errTokenInvalid)
+	}
+
+	other := &tokenSigner{key: []byte("fedcba9876543210"), ttl: time.Hour}
+	if err := other.verify(token, now); err != errTokenInvalid {
+		t.Errorf("token from another key: err = %v, want %v", err, errTokenInvalid)
+	}
+	if err := signer.verify("garbage", now); err != errTokenInvalid {
+		t.Errorf("garbage: err = %v, want %v", err, errTokenInvalid)
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/cloudflare"
+)
+
+// SessionTrack is a track published by a Cloudflare session, normalized for
+// viewers deciding what to pull
+type SessionTrack struct {
+	Name   string `json:"trackName"`
+	Kind   string `json:"kind,omitempty"`   // "audio" or "video" when Cloudflare reports it
+	Mid    string `json:"mid,omitempty"`    // Publisher-side mid
+	Status string `json:"status,omitempty"` // "active", "inactive" or "waiting"
+}
+
+// TrackMapping is one track the relay publishes, as named on the SFU
+type TrackMapping struct {
+	CameraID   string `json:"cameraId"`
+	SessionID  string `json:"sessionId"`
+	Kind       string `json:"kind"`
+	TrackName  string `json:"trackName"`
+	Generation uint64 `json:"generation"` // Relays created for the camera so far; the name's suffix after the first
+}
+
+// handleTrackNames lists the tracks every running relay publishes:
+// GET /api/tracks. It is the authoritative camera → track name mapping;
+// names change when a camera's relay is recreated.
+func (s *Server) handleTrackNames(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mappings := make([]TrackMapping, 0)
+	if s.relay != nil {
+		for _, stat := range s.relay.GetRelayStats() {
+			for kind, name := range map[string]string{"video": stat.VideoTrack, "audio": stat.AudioTrack} {
+				if name == "" {
+					continue
+				}
+				mappings = append(mappings, TrackMapping{
+					CameraID:   stat.CameraID,
+					SessionID:  stat.SessionID,
+					Kind:       kind,
+					TrackName:  name,
+					Generation: stat.Generation,
+				})
+			}
+		}
+	}
+	slices.SortFunc(mappings, func(a, b TrackMapping) int {
+		return cmp.Or(cmp.Compare(a.CameraID, b.CameraID), cmp.Compare(b.Kind, a.Kind))
+	})
+	writeJSON(w, mappings)
+}
+
+// trackLister returns the tracks a session publishes
+type trackLister func(ctx context.Context, sessionID string) ([]SessionTrack, error)
+
+// handleSessionTracks lists the tracks a session publishes:
+// GET /api/sessions/{id}/tracks
+func (s *Server) handleSessionTracks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/sessions/")
+	sessionID, rest, _ := strings.Cut(path, "/")
+	if sessionID == "" || rest != "tracks" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	tracks, err := s.sessionTracks(r.Context(), sessionID)
+	if err != nil {
+		s.logger.Error("failed to list session tracks", "session_id", sessionID, "error", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, tracks)
+}
+
+// sessionTracks queries Cloudflare for the tracks sessionID publishes
+func (s *Server) sessionTracks(ctx context.Context, sessionID string) ([]SessionTrack, error) {
+	state, err := s.cfClient.GetSessionState(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return publishedTracks(state.Tracks), nil
+}
+
+// publishedTracks keeps the tracks a session pushes itself; tracks it pulls
+// from other sessions are listed by Cloudflare with location "remote"
+func publishedTracks(objs []cloudflare.TrackObject) []SessionTrack {
+	tracks := make([]SessionTrack, 0, len(objs))
+	for _, t := range objs {
+		if t.Location == "remote" || t.TrackName == "" {
+			continue
+		}
+		tracks = append(tracks, SessionTrack{
+			Name:   t.TrackName,
+			Kind:   t.Kind,
+			Mid:    t.Mid,
+			Status: t.Status,
+		})
+	}
+	return tracks
+}
+
+// expandAutoDiscover resolves the pull side of autoDiscover: every remote
+// track in req that names a session but no track is replaced by one remote
+// track per track that session publishes, skipping inactive ones. Cloudflare
+// only auto-discovers tracks from an offer, so the flag is dropped when no
+// local tracks remain to discover.
+func expandAutoDiscover(ctx context.Context, req *cloudflare.TracksRequest, list trackLister) error {
+	if !req.AutoDiscover {
+		return nil
+	}
+
+	expanded := make([]cloudflare.TrackObject, 0, len(req.Tracks))
+	hasLocal := false
+	for _, t := range req.Tracks {
+		if t.Location != "remote" || t.TrackName != "" {
+			hasLocal = hasLocal || t.Location == "local"
+			expanded = append(expanded, t)
+			continue
+		}
+		if t.SessionID == "" {
+			return fmt.Errorf("autoDiscover remote track needs a sessionId")
+		}
+
+		published, err := list(ctx, t.SessionID)
+		if err != nil {
+			return fmt.Errorf("list tracks of session %s: %w", t.SessionID, err)
+		}
+		for _, p := range published {
+			if p.Status == "inactive" {
+				continue
+			}
+			expanded = append(expanded, cloudflare.TrackObject{
+				Location:  "remote",
+				SessionID: t.SessionID,
+				TrackName: p.Name,
+			})
+		}
+	}
+
+	req.Tracks = expanded
+	req.AutoDiscover = hasLocal || len(req.Tracks) == 0
+	return nil
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/cloudflare"
+)
+
+func TestExpandAutoDiscover(t *testing.T) {
+	list := func(_ context.Context, sessionID string) ([]SessionTrack, error) {
+		return publishedTracks([]cloudflare.TrackObject{
+			{Location: "local", TrackName: sessionID + "-video", Kind: "video", Status: "active"},
+			{Location: "local", TrackName: sessionID + "-audio", Kind: "audio", Status: "inactive"},
+			{Location: "remote", TrackName: "pulled", SessionID: "other"},
+		}), nil
+	}
+
+	req := &cloudflare.TracksRequest{
+		AutoDiscover: true,
+		Tracks: []cloudflare.TrackObject{
+			{Location: "remote", SessionID: "camA"},
+			{Location: "remote", SessionID: "camB", TrackName: "camB-audio"},
+		},
+	}
+	if err := expandAutoDiscover(context.Background(), req, list); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"camA-video", "camB-audio"}
+	if len(req.Tracks) != len(want) {
+		t.Fatalf("tracks = %+v, want %v", req.Tracks, want)
+	}
+	for i, name := range want {
+		if req.Tracks[i].TrackName != name || req.Tracks[i].Location != "remote" {
+			t.Errorf("track %d = %+v, want remote %s", i, req.Tracks[i], name)
+		}
+	}
+	if req.AutoDiscover {
+		t.Error("autoDiscover forwarded for a pull-only request")
+	}
+
+	bad := &cloudflare.TracksRequest{AutoDiscover: true, Tracks: []cloudflare.TrackObject{{Location: "remote"}}}
+	if err := expandAutoDiscover(context.Background(), bad, list); err == nil {
+		t.Error("remote track without sessionId accepted")
+	}
+}
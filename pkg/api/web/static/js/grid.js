@@ -28,6 +28,21 @@ export class Grid {
         }
     }
 
+    applyLayout(layout) {
+        if (layout.columns) {
+            this.container.style.gridTemplateColumns = `repeat(${layout.columns}, 1fr)`;
+        }
+        layout.cameras.forEach((camera, index) => {
+            const tile = this.tiles.get(camera.cameraId);
+            if (!tile) {
+                return;
+            }
+            tile.element.style.order = index;
+            tile.element.style.gridColumn = `span ${camera.colSpan || 1}`;
+            tile.element.style.gridRow = `span ${camera.rowSpan || 1}`;
+        });
+    }
+
     clear() {
         for (const tile of this.tiles.values()) {
             tile.destroy();
@@ -104,6 +119,12 @@ class CameraTile {
 
         this.videoElement.srcObject = stream;
 
+        // Audio-only cameras: show controls so the viewer can unmute
+        if (stream.getVideoTracks().length === 0) {
+            this.element.classList.add('audio-only');
+            this.videoElement.controls = true;
+        }
+
         // Add all video element event handlers for diagnostics
         this.videoElement.onloadstart = () => {
             console.log(`[Tile ${this.cameraId}] Video loadstart event`);
@@ -189,6 +210,16 @@ class CameraTile {
         }
     }
 
+    setPoster(url) {
+        // Shown behind the spinner until the stream plays
+        this.posterUrl = url;
+        this.videoElement.poster = url;
+        const placeholder = this.element.querySelector('.video-placeholder');
+        if (placeholder) {
+            placeholder.style.background = `#000000 url(${url}) center / cover no-repeat`;
+        }
+    }
+
     setStatus(status) {
         this.statusElement.textContent = status;
         this.statusElement.className = `camera-status ${status}`;
@@ -242,6 +273,9 @@ class CameraTile {
             stream.getTracks().forEach(track => track.stop());
             this.videoElement.srcObject = null;
         }
+        if (this.posterUrl) {
+            URL.revokeObjectURL(this.posterUrl);
+        }
 
         // Remove from DOM
         this.element.remove();
@@ -21,6 +21,43 @@ export class Viewer {
 
         // Viewer identity for session reuse across refreshes
         this.viewerId = this.getOrCreateViewerId();
+
+        // Signed viewer token, required when the server has viewer_token_key set
+        this.token = this.getViewerToken();
+
+        // Saved layout preset (?layout=name): camera order, spans and columns
+        this.layoutName = new URLSearchParams(window.location.search).get('layout');
+        this.layout = null;
+    }
+
+    async fetchLayout() {
+        const response = await fetch(`/api/layouts/${encodeURIComponent(this.layoutName)}`);
+        if (!response.ok) {
+            throw new Error(`Failed to load layout "${this.layoutName}": ${response.statusText}`);
+        }
+        return response.json();
+    }
+
+    getViewerToken() {
+        const params = new URLSearchParams(window.location.search);
+        const token = params.get('token');
+        if (token) {
+            // Keep it for refreshes, but out of the address bar and history
+            sessionStorage.setItem('viewerToken', token);
+            params.delete('token');
+            const query = params.toString();
+            history.replaceState(null, '', window.location.pathname + (query ? '?' + query : ''));
+            return token;
+        }
+        return sessionStorage.getItem('viewerToken');
+    }
+
+    headers() {
+        const headers = { 'Content-Type': 'application/json' };
+        if (this.token) {
+            headers['Authorization'] = 'Bearer ' + this.token;
+        }
+        return headers;
     }
 
     getOrCreateViewerId() {
@@ -39,6 +76,9 @@ export class Viewer {
         console.log('[Viewer] Starting viewer');
 
         this.config = await this.fetchConfig();
+        if (this.layoutName) {
+            this.layout = await this.fetchLayout();
+        }
 
         // Create single viewer session
         await this.initSession();
@@ -48,8 +88,11 @@ export class Viewer {
 
         this.refreshInterval = setInterval(() => {
             this.refreshCameras();
+            this.keepalive();
         }, 30000);
 
+        this.subscribeEvents();
+
         this.updateStatus('Connected', 'connected');
     }
 
@@ -57,6 +100,10 @@ export class Viewer {
         if (this.refreshInterval) {
             clearInterval(this.refreshInterval);
         }
+        if (this.events) {
+            this.events.close();
+            this.events = null;
+        }
 
         // Close all camera tiles
         for (const tile of this.cameras.values()) {
@@ -83,10 +130,13 @@ export class Viewer {
         // Try to find/reuse existing session for this viewer
         const response = await fetch('/api/viewer/session', {
             method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
+            headers: this.headers(),
             body: JSON.stringify({ viewerId: this.viewerId })
         });
 
+        if (response.status === 401) {
+            throw new Error('Viewer link expired or invalid - request a new one');
+        }
         if (!response.ok) {
             throw new Error(`Failed to get session: ${response.statusText}`);
         }
@@ -112,9 +162,10 @@ export class Viewer {
             const mid = event.transceiver?.mid;
             console.log('[Viewer] Received track:', event.track.kind, 'mid:', mid);
 
-            // Find which camera this track belongs to
+            // Find which camera this track belongs to (audio-only cameras
+            // publish a single audio track instead of video)
             for (const [cameraId, cameraMid] of this.trackMids) {
-                if (cameraMid === mid && event.track.kind === 'video') {
+                if (cameraMid === mid) {
                     const tile = this.cameras.get(cameraId);
                     if (tile) {
                         tile.attachStream(event.streams[0]);
@@ -145,7 +196,15 @@ export class Viewer {
                 throw new Error(`Failed to fetch cameras: ${response.statusText}`);
             }
 
-            const cameras = await response.json();
+            let cameras = await response.json();
+
+            // A layout selects cameras and fixes their order
+            if (this.layout) {
+                const order = new Map(this.layout.cameras.map((c, i) => [c.cameraId, i]));
+                cameras = cameras
+                    .filter(c => order.has(c.cameraId))
+                    .sort((a, b) => order.get(a.cameraId) - order.get(b.cameraId));
+            }
 
             // Group by cameraId
             const cameraMap = new Map();
@@ -155,6 +214,7 @@ export class Viewer {
                         id: camera.cameraId,
                         name: camera.name,
                         sessionId: camera.sessionId,
+                        snapshotUrl: camera.snapshotUrl,
                         tracks: []
                     });
                 }
@@ -171,6 +231,9 @@ export class Viewer {
                     // Create tile immediately
                     const tile = new CameraTile(cameraId, cameraData.name, this.grid);
                     this.cameras.set(cameraId, tile);
+                    if (cameraData.snapshotUrl) {
+                        this.loadPoster(tile, cameraData.snapshotUrl);
+                    }
                     newCameras.push(cameraData);
                 }
             }
@@ -187,6 +250,10 @@ export class Viewer {
                 }
             }
 
+            if (this.layout) {
+                this.grid.applyLayout(this.layout);
+            }
+
             this.updateCameraCount(this.cameras.size);
             console.log(`[Viewer] Stats: 1 session, ${this.cameras.size} cameras, ${this.trackMids.size} tracks`);
 
@@ -196,6 +263,20 @@ export class Viewer {
         }
     }
 
+    async loadPoster(tile, url) {
+        // Fetched rather than linked: the endpoint wants the viewer token header
+        try {
+            const response = await fetch(url, { headers: this.headers() });
+            if (!response.ok) {
+                return; // No event image or thumbnail yet
+            }
+            const blob = await response.blob();
+            tile.setPoster(URL.createObjectURL(blob));
+        } catch (error) {
+            console.warn(`[Viewer] No poster for ${tile.cameraId}:`, error);
+        }
+    }
+
     async pullCameraTracks(camerasData) {
         // Build tracks array for ALL cameras at once
         const tracks = [];
@@ -208,7 +289,7 @@ export class Viewer {
                     trackName: track.trackName
                 });
                 // Map trackName to cameraId for ontrack routing
-                // Track names are now unique: "${cameraId}-video" or "${cameraId}-audio"
+                // Track names come from /api/cameras: "${cameraId}-video", with a "-N" suffix once a camera's relay has been recreated
                 this.pendingTracks.set(track.trackName, camera.id);
             }
         }
@@ -219,7 +300,7 @@ export class Viewer {
 
         const response = await fetch(`/api/cf/sessions/${this.sessionId}/tracks/new`, {
             method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
+            headers: this.headers(),
             body: JSON.stringify({ tracks })
         });
 
@@ -303,7 +384,7 @@ export class Viewer {
         // Send answer
         await fetch(`/api/cf/sessions/${this.sessionId}/renegotiate`, {
             method: 'PUT',
-            headers: { 'Content-Type': 'application/json' },
+            headers: this.headers(),
             body: JSON.stringify({
                 sessionDescription: { type: 'answer', sdp: answer.sdp }
             })
@@ -312,6 +393,18 @@ export class Viewer {
         console.log('[Viewer] Renegotiation complete');
     }
 
+    async keepalive() {
+        // The relay closes viewer sessions that go quiet; watching alone makes no requests
+        try {
+            await fetch(`/api/cf/sessions/${this.sessionId}/keepalive`, {
+                method: 'PUT',
+                headers: this.headers()
+            });
+        } catch (error) {
+            console.warn('[Viewer] Keepalive failed:', error);
+        }
+    }
+
     async removeCamera(cameraId) {
         const mid = this.trackMids.get(cameraId);
 
@@ -319,7 +412,7 @@ export class Viewer {
             // Close track in Cloudflare
             await fetch(`/api/cf/sessions/${this.sessionId}/tracks/close`, {
                 method: 'PUT',
-                headers: { 'Content-Type': 'application/json' },
+                headers: this.headers(),
                 body: JSON.stringify({
                     tracks: [{ mid }],
                     force: true  // Don't renegotiate, just stop data
@@ -348,7 +441,7 @@ export class Viewer {
 
         const response = await fetch(`/api/cf/sessions/${this.sessionId}/tracks/update`, {
             method: 'PUT',
-            headers: { 'Content-Type': 'application/json' },
+            headers: this.headers(),
             body: JSON.stringify({
                 tracks: [{
                     location: 'remote',
@@ -379,6 +472,37 @@ export class Viewer {
         }
     }
 
+    subscribeEvents() {
+        // Server-sent notices, e.g. the relay shutting down
+        this.events = new EventSource('/api/viewer/events');
+        this.events.addEventListener('shutdown', (e) => {
+            this.handleShutdown(JSON.parse(e.data));
+        });
+    }
+
+    async handleShutdown(event) {
+        console.log('[Viewer] Relay shutting down:', event.reason);
+        await this.stop();
+        this.updateStatus('Relay restarting…', 'disconnected');
+        this.waitForRelay((event.retryAfter || 15) * 1000);
+    }
+
+    waitForRelay(delay) {
+        // Reload once the relay answers again; the new instance has new sessions
+        setTimeout(async () => {
+            try {
+                const response = await fetch('/api/config');
+                if (response.ok) {
+                    window.location.reload();
+                    return;
+                }
+            } catch (error) {
+                // Still down
+            }
+            this.waitForRelay(delay);
+        }, delay);
+    }
+
     handleDisconnect() {
         console.log('[Viewer] Disconnected');
         this.updateStatus('Disconnected', 'disconnected');
@@ -456,6 +580,10 @@ class CameraTile {
         this.tile.setName(newName);
     }
 
+    setPoster(url) {
+        this.tile.setPoster(url);
+    }
+
     setStatus(status) {
         this.tile.setStatus(status);
     }
@@ -0,0 +1,169 @@
+package bridge
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/sfu"
+	"github.com/pion/sdp/v3"
+)
+
+// h264ProfileIDC is the profile_idc we send (Main), the first byte of the
+// profile-level-id registered in CreateSession
+const h264ProfileIDC = "4d"
+
+// validateAnswer checks that the SFU's answer accepted every published track
+// with a codec our packets can be decoded as. pion rewrites payload types to
+// the negotiated ones, so a different H.264 payload type is fine; a different
+// codec, packetization mode or profile would stream undecodable media.
+// videoCodec is the codec the video track was offered with, "H264" or "H265".
+func validateAnswer(answer string, tracks []sfu.Track, videoCodec string) error {
+	var sd sdp.SessionDescription
+	if err := sd.Unmarshal([]byte(answer)); err != nil {
+		return fmt.Errorf("parse SDP answer: %w", err)
+	}
+
+	byMid := make(map[string]*sdp.MediaDescription, len(sd.MediaDescriptions))
+	for _, md := range sd.MediaDescriptions {
+		if mid, ok := md.Attribute(sdp.AttrKeyMID); ok {
+			byMid[mid] = md
+		}
+	}
+
+	for _, t := range tracks {
+		md, ok := byMid[t.Mid]
+		if !ok {
+			return fmt.Errorf("SDP answer has no media section for %s track (mid %s)", t.Kind, t.Mid)
+		}
+		if md.MediaName.Port.Value == 0 {
+			return fmt.Errorf("SFU rejected %s track (mid %s)", t.Kind, t.Mid)
+		}
+		for _, dir := range []string{sdp.AttrKeyInactive, sdp.AttrKeySendOnly} {
+			if _, ok := md.Attribute(dir); ok {
+				return fmt.Errorf("SFU answered %s track (mid %s) as %s; it will not receive media", t.Kind, t.Mid, dir)
+			}
+		}
+
+		var err error
+		switch t.Kind {
+		case "video":
+			if videoCodec == "H265" {
+				err = checkH265(md)
+			} else {
+				err = checkH264(md)
+			}
+		case "audio":
+			err = checkOpus(md)
+		}
+		if err != nil {
+			return fmt.Errorf("%s track (mid %s): %w", t.Kind, t.Mid, err)
+		}
+	}
+	return nil
+}
+
+// checkH264 requires an H.264 format with packetization-mode=1 and our profile
+func checkH264(md *sdp.MediaDescription) error {
+	formats := mediaFormats(md)
+	var problems []string
+	for _, pt := range md.MediaName.Formats {
+		f, ok := formats[pt]
+		if !ok || !strings.EqualFold(f.codec, "H264/90000") {
+			continue
+		}
+		params := fmtpParams(f.fmtp)
+		if params["packetization-mode"] != "1" {
+			problems = append(problems, fmt.Sprintf("payload type %s uses packetization-mode=%s, we send 1", pt, orDefault(params["packetization-mode"], "0")))
+			continue
+		}
+		if pli := params["profile-level-id"]; len(pli) >= 2 && !strings.EqualFold(pli[:2], h264ProfileIDC) {
+			problems = append(problems, fmt.Sprintf("payload type %s uses profile-level-id=%s, we send profile %s", pt, pli, h264ProfileIDC))
+			continue
+		}
+		return nil
+	}
+	if len(problems) == 0 {
+		return fmt.Errorf("SFU did not accept H264 (answered %s)", describeFormats(md, formats))
+	}
+	return fmt.Errorf("no compatible H264 format: %s", strings.Join(problems, "; "))
+}
+
+// checkH265 requires an H.265 format
+func checkH265(md *sdp.MediaDescription) error {
+	formats := mediaFormats(md)
+	for _, pt := range md.MediaName.Formats {
+		if f, ok := formats[pt]; ok && strings.EqualFold(f.codec, "H265/90000") {
+			return nil
+		}
+	}
+	return fmt.Errorf("SFU did not accept H265 (answered %s)", describeFormats(md, formats))
+}
+
+// checkOpus requires Opus at 48kHz
+func checkOpus(md *sdp.MediaDescription) error {
+	formats := mediaFormats(md)
+	for _, pt := range md.MediaName.Formats {
+		if f, ok := formats[pt]; ok && strings.HasPrefix(strings.ToLower(f.codec), "opus/48000") {
+			return nil
+		}
+	}
+	return fmt.Errorf("SFU did not accept Opus (answered %s)", describeFormats(md, formats))
+}
+
+// mediaFormat is the rtpmap and fmtp of one payload type
+type mediaFormat struct {
+	codec string // e.g. "H264/90000"
+	fmtp  string
+}
+
+// mediaFormats indexes a media section's rtpmap and fmtp lines by payload type
+func mediaFormats(md *sdp.MediaDescription) map[string]mediaFormat {
+	formats := make(map[string]mediaFormat)
+	for _, a := range md.Attributes {
+		pt, rest, ok := strings.Cut(a.Value, " ")
+		if !ok {
+			continue
+		}
+		f := formats[pt]
+		switch a.Key {
+		case "rtpmap":
+			f.codec = strings.TrimSpace(rest)
+		case "fmtp":
+			f.fmtp = strings.TrimSpace(rest)
+		default:
+			continue
+		}
+		formats[pt] = f
+	}
+	return formats
+}
+
+// fmtpParams splits "a=1;b=2" into a map
+func fmtpParams(fmtp string) map[string]string {
+	params := make(map[string]string)
+	for _, kv := range strings.Split(fmtp, ";") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(kv), "="); ok {
+			params[strings.ToLower(k)] = v
+		}
+	}
+	return params
+}
+
+// describeFormats lists a media section's codecs for error messages
+func describeFormats(md *sdp.MediaDescription, formats map[string]mediaFormat) string {
+	var codecs []string
+	for _, pt := range md.MediaName.Formats {
+		codecs = append(codecs, pt+" "+orDefault(formats[pt].codec, "?"))
+	}
+	if len(codecs) == 0 {
+		return "no formats"
+	}
+	return strings.Join(codecs, ", ")
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
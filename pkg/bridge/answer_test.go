@@ -0,0 +1,52 @@
+package bridge
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/sfu"
+)
+
+const answerHeader = "v=0\r\no=- 1 2 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n"
+
+func videoSection(port, fmtp, dir string) string {
+	return "m=video " + port + " UDP/TLS/RTP/SAVPF 102\r\n" +
+		"c=IN IP4 0.0.0.0\r\n" +
+		"a=mid:0\r\n" +
+		"a=" + dir + "\r\n" +
+		"a=rtpmap:102 H264/90000\r\n" +
+		"a=fmtp:102 " + fmtp + "\r\n"
+}
+
+const audioSection = "m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=mid:1\r\n" +
+	"a=recvonly\r\n" +
+	"a=rtpmap:111 opus/48000/2\r\n"
+
+func TestValidateAnswer(t *testing.T) {
+	tracks := []sfu.Track{{Mid: "0", Kind: "video"}, {Mid: "1", Kind: "audio"}}
+	good := "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=4d001f"
+
+	tests := []struct {
+		name    string
+		answer  string
+		wantErr string
+	}{
+		{"compatible with remapped payload type", videoSection("9", good, "recvonly") + audioSection, ""},
+		{"rejected", videoSection("0", good, "recvonly") + audioSection, "rejected video"},
+		{"inactive", videoSection("9", good, "inactive") + audioSection, "as inactive"},
+		{"packetization mode", videoSection("9", "packetization-mode=0;profile-level-id=4d001f", "recvonly") + audioSection, "packetization-mode=0"},
+		{"profile", videoSection("9", "packetization-mode=1;profile-level-id=42e01f", "recvonly") + audioSection, "profile-level-id=42e01f"},
+		{"missing audio", videoSection("9", good, "recvonly"), "no media section for audio"},
+	}
+	for _, tt := range tests {
+		err := validateAnswer(answerHeader+tt.answer, tracks, "H264")
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package bridge
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// syncTolerance is the smoothed drift from the wall clock at which a
+	// track's timestamps start being corrected; well inside the ~45ms by
+	// which audio may lead video before viewers notice
+	syncTolerance = 40 * time.Millisecond
+
+	// syncSettled is the drift at which correction stops again
+	syncSettled = 10 * time.Millisecond
+
+	// syncMaxSlew is the largest correction, as a fraction of each
+	// timestamp step: 0.5% speeds or slows a track imperceptibly
+	syncMaxSlew = 0.005
+
+	// syncSmoothing is the EWMA weight of each packet's drift, so arrival
+	// jitter isn't mistaken for clock drift
+	syncSmoothing = 0.05
+
+	// syncMaxJump is the source timestamp jump treated as a new stream
+	// rather than elapsed media time
+	syncMaxJump = 5 * time.Second
+)
+
+// SyncStats reports how far each track's timestamps are from the shared
+// timeline
+type SyncStats struct {
+	Enabled    bool
+	VideoDrift time.Duration // Smoothed; positive runs ahead of the wall clock
+	AudioDrift time.Duration
+	Resyncs    uint64 // Source timestamp jumps re-anchored to the wall clock
+}
+
+// syncClock rebases one track's source timestamps
+type syncClock struct {
+	rate       float64
+	started    bool
+	lastSrc    uint32
+	base       uint32  // Random start of the output timestamps
+	out        int64   // Output ticks since the shared anchor
+	drift      float64 // Smoothed ticks ahead of the wall clock
+	correcting bool
+}
+
+// avSync rebases the audio (48 kHz) and video (90 kHz) timestamps from the
+// camera onto one timeline anchored to the wall clock at the first packet.
+// Each track keeps its source spacing, but when its smoothed position drifts
+// from the wall clock by syncTolerance it is slewed back, so the two clocks
+// can't drift apart however long the relay runs. Viewers then lip-sync
+// correctly from the sender reports, which map both to wall-clock time.
+type avSync struct {
+	mu      sync.Mutex
+	enabled bool
+	anchor  time.Time
+	video   syncClock
+	audio   syncClock
+	resyncs uint64
+}
+
+// videoTS returns the timestamp to send for a video source timestamp that
+// arrived at now
+func (s *avSync) videoTS(src uint32, now time.Time) uint32 {
+	return s.rebase(&s.video, 90000, src, now)
+}
+
+// audioTS is videoTS for the Opus track
+func (s *avSync) audioTS(src uint32, now time.Time) uint32 {
+	return s.rebase(&s.audio, 48000, src, now)
+}
+
+func (s *avSync) rebase(c *syncClock, rate float64, src uint32, now time.Time) uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.enabled {
+		return src
+	}
+	if s.anchor.IsZero() {
+		s.anchor = now
+	}
+	wall := now.Sub(s.anchor).Seconds() * rate
+
+	if !c.started {
+		c.started, c.rate, c.base = true, rate, rand.Uint32()
+		c.out = int64(wall)
+		c.lastSrc = src
+		return c.base + uint32(c.out)
+	}
+
+	delta := int64(int32(src - c.lastSrc))
+	c.lastSrc = src
+	if maxJump := int64(syncMaxJump.Seconds() * rate); delta > maxJump || delta < -maxJump {
+		c.out = max(int64(wall), c.out+1) // Never backwards
+		c.drift, c.correcting = 0, false
+		s.resyncs++
+		return c.base + uint32(c.out)
+	}
+
+	c.drift += syncSmoothing * (float64(c.out+delta) - wall - c.drift)
+	switch drift := math.Abs(c.drift) / rate; {
+	case drift > syncTolerance.Seconds():
+		c.correcting = true
+	case drift < syncSettled.Seconds():
+		c.correcting = false
+	}
+
+	// Packets of one frame share a timestamp, so only steps are corrected
+	var correction int64
+	if c.correcting && delta > 0 {
+		limit := max(int64(float64(delta)*syncMaxSlew), 1)
+		correction = min(max(int64(-c.drift), -limit), limit)
+	}
+	c.out += delta + correction
+	return c.base + uint32(c.out)
+}
+
+// stats returns the current drift of both tracks
+func (s *avSync) stats() SyncStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := SyncStats{Enabled: s.enabled, Resyncs: s.resyncs}
+	if s.video.started {
+		st.VideoDrift = time.Duration(s.video.drift / s.video.rate * float64(time.Second))
+	}
+	if s.audio.started {
+		st.AudioDrift = time.Duration(s.audio.drift / s.audio.rate * float64(time.Second))
+	}
+	return st
+}
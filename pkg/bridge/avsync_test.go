@@ -0,0 +1,54 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAVSync(t *testing.T) {
+	var s avSync
+	if got := s.videoTS(1234, time.Now()); got != 1234 {
+		t.Fatalf("disabled sync changed timestamp to %d", got)
+	}
+
+	s.enabled = true
+	start := time.Now()
+	// The camera's video clock runs 0.2% fast: 6 minutes of frames carry
+	// timestamps 720ms ahead of the wall clock
+	var video, audio uint32 = 5000, 70000
+	var firstVideo, lastVideo, firstAudio, lastAudio uint32
+	for ms := 0; ms <= 6*60*1000; ms += 20 {
+		now := start.Add(time.Duration(ms) * time.Millisecond)
+		if ms%40 == 0 {
+			lastVideo = s.videoTS(video, now)
+			if ms == 0 {
+				firstVideo = lastVideo
+			}
+			video += 3606 // 3600 plus 0.2%
+		}
+		lastAudio = s.audioTS(audio, now)
+		if ms == 0 {
+			firstAudio = lastAudio
+		}
+		audio += 960
+	}
+
+	elapsed := 6 * time.Minute
+	videoOff := time.Duration(lastVideo-firstVideo)*time.Second/90000 - elapsed
+	audioOff := time.Duration(lastAudio-firstAudio)*time.Second/48000 - elapsed
+	if videoOff > syncTolerance+10*time.Millisecond || videoOff < -syncTolerance {
+		t.Errorf("video %v off the wall clock after 6 minutes", videoOff)
+	}
+	if audioOff > time.Millisecond || audioOff < -time.Millisecond {
+		t.Errorf("audio %v off the wall clock", audioOff)
+	}
+
+	// A camera restart re-anchors rather than jumping the output
+	now := start.Add(elapsed + 40*time.Millisecond)
+	if got := s.videoTS(video+90000*60, now); got-lastVideo > 90000 {
+		t.Errorf("output jumped %d ticks across a source restart", got-lastVideo)
+	}
+	if st := s.stats(); st.Resyncs != 1 || !st.Enabled {
+		t.Errorf("stats %+v", st)
+	}
+}
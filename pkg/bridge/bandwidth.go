@@ -0,0 +1,192 @@
+package bridge
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+const (
+	// bitrateWindow is how long camera bytes are counted before the rate
+	// is updated
+	bitrateWindow = time.Second
+
+	// estimateMaxAge is how long a REMB estimate is trusted; the SFU sends
+	// them about once a second while it has something to say
+	estimateMaxAge = 5 * time.Second
+
+	// degradeHold is the least time between two degrade steps, so the
+	// estimate can react to the last one first
+	degradeHold = 2 * time.Second
+
+	// recoverHold is how long the estimate must show headroom before one
+	// degrade step is undone
+	recoverHold = 5 * time.Second
+
+	// recoverHeadroom is how far the estimate must exceed the camera
+	// bitrate before stepping back up
+	recoverHeadroom = 1.2
+)
+
+// DegradeLevel is how much video the bandwidth controller holds back
+type DegradeLevel int
+
+const (
+	DegradeNone          DegradeLevel = iota // Every frame forwarded
+	DegradeNonReference                      // Non-reference frames dropped
+	DegradeKeyframesOnly                     // Only keyframes forwarded
+)
+
+func (l DegradeLevel) String() string {
+	switch l {
+	case DegradeNonReference:
+		return "non_reference"
+	case DegradeKeyframesOnly:
+		return "keyframes_only"
+	}
+	return "none"
+}
+
+// BandwidthStats is the bandwidth controller's view of the video track
+type BandwidthStats struct {
+	EstimateBps uint64       // Last REMB estimate from the SFU; zero until one arrives
+	CameraBps   uint64       // Video bitrate arriving from the camera
+	Level       DegradeLevel // Current degrade step
+	Dropped     uint64       // Frames dropped to fit the estimate
+}
+
+// bandwidthController compares the SFU's REMB estimate with the camera's
+// bitrate and drops video frames when the path can't carry them all, rather
+// than letting the pacer and SFU queue up latency. It degrades a step at a
+// time: first non-reference frames, which nothing else decodes against, then
+// everything but keyframes. Nest streams have a single quality, so there is
+// no lower-bitrate stream to switch to.
+type bandwidthController struct {
+	mu         sync.Mutex
+	estimate   uint64
+	estimateAt time.Time
+
+	windowStart time.Time
+	windowBytes int
+	rate        uint64 // Camera bits per second over the last full window
+
+	level        DegradeLevel
+	changedAt    time.Time
+	headroomAt   time.Time // Since when the estimate has had headroom; zero if it hasn't
+	waitKeyframe bool      // A reference frame was dropped; later frames can't decode until a keyframe
+	dropped      uint64
+}
+
+// remb records a receiver estimated maximum bitrate
+func (c *bandwidthController) remb(bps float32, now time.Time) {
+	c.mu.Lock()
+	c.estimate = uint64(bps)
+	c.estimateAt = now
+	c.mu.Unlock()
+}
+
+// admit counts an access unit toward the camera bitrate and reports whether
+// it should be forwarded. The level change, if any, is returned for logging.
+func (c *bandwidthController) admit(codec string, au []byte, keyframe bool, now time.Time) (ok bool, changed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.windowBytes += len(au)
+	if c.windowStart.IsZero() {
+		c.windowStart = now
+	} else if elapsed := now.Sub(c.windowStart); elapsed >= bitrateWindow {
+		c.rate = uint64(float64(c.windowBytes*8) / elapsed.Seconds())
+		c.windowStart, c.windowBytes = now, 0
+		changed = c.adjust(now)
+	}
+
+	if keyframe {
+		c.waitKeyframe = false
+		return true, changed
+	}
+	drop := c.waitKeyframe
+	switch c.level {
+	case DegradeKeyframesOnly:
+		drop = true
+	case DegradeNonReference:
+		drop = drop || !isReference(codec, au)
+	}
+	if !drop {
+		return true, changed
+	}
+	if isReference(codec, au) {
+		c.waitKeyframe = true
+	}
+	c.dropped++
+	return false, changed
+}
+
+// adjust moves one degrade step toward what the estimate allows
+func (c *bandwidthController) adjust(now time.Time) bool {
+	if c.estimate == 0 || now.Sub(c.estimateAt) > estimateMaxAge {
+		// No estimate to go by: forward everything
+		c.headroomAt = time.Time{}
+		if c.level != DegradeNone {
+			c.level, c.changedAt = DegradeNone, now
+			return true
+		}
+		return false
+	}
+
+	switch {
+	case c.estimate < c.rate:
+		c.headroomAt = time.Time{}
+		if c.level < DegradeKeyframesOnly && now.Sub(c.changedAt) >= degradeHold {
+			c.level++
+			c.changedAt = now
+			return true
+		}
+	case float64(c.estimate) >= float64(c.rate)*recoverHeadroom:
+		if c.headroomAt.IsZero() {
+			c.headroomAt = now
+		}
+		if c.level > DegradeNone && now.Sub(c.headroomAt) >= recoverHold {
+			c.level--
+			c.changedAt, c.headroomAt = now, now
+			return true
+		}
+	default:
+		c.headroomAt = time.Time{}
+	}
+	return false
+}
+
+// stats returns the controller's current state
+func (c *bandwidthController) stats() BandwidthStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return BandwidthStats{
+		EstimateBps: c.estimate,
+		CameraBps:   c.rate,
+		Level:       c.level,
+		Dropped:     c.dropped,
+	}
+}
+
+// isReference reports whether other frames may be predicted from an
+// AVC-form access unit: an H.264 slice with nonzero nal_ref_idc, or an H.265
+// picture that isn't a sub-layer non-reference type
+func isReference(codec string, au []byte) bool {
+	for len(au) > 4 {
+		size := int(binary.BigEndian.Uint32(au))
+		au = au[4:]
+		if size == 0 || size > len(au) {
+			return true // Can't tell; assume the worst
+		}
+		if codec == "H265" {
+			// VCL types 0-14: even ones are sub-layer non-reference
+			if t := (au[0] >> 1) & 0x3F; t <= 14 {
+				return t%2 == 1
+			}
+		} else if t := au[0] & 0x1F; t >= 1 && t <= 5 {
+			return au[0]&0x60 != 0
+		}
+		au = au[size:]
+	}
+	return true
+}
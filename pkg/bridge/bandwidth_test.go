@@ -0,0 +1,76 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBandwidthController(t *testing.T) {
+	idr := []byte{0, 0, 0, 2, 0x65, 0}
+	ref := []byte{0, 0, 0, 2, 0x41, 0}    // nal_ref_idc 2
+	nonRef := []byte{0, 0, 0, 2, 0x01, 0} // nal_ref_idc 0
+	if !isReference("H264", ref) || isReference("H264", nonRef) {
+		t.Fatal("H.264 reference detection")
+	}
+	if isReference("H265", []byte{0, 0, 0, 3, 0 << 1, 1, 0}) || !isReference("H265", []byte{0, 0, 0, 3, 1 << 1, 1, 0}) {
+		t.Fatal("H.265 reference detection")
+	}
+
+	var c bandwidthController
+	now := time.Now()
+	// One 48-byte frame every 10ms: about 38 kbps from the camera
+	frame := func(au []byte) bool {
+		now = now.Add(10 * time.Millisecond)
+		ok, _ := c.admit("H264", append(au, make([]byte, 48-len(au))...), au[4] == 0x65, now)
+		return ok
+	}
+	// run sends P frames for d, with a REMB every second unless estimate is zero
+	run := func(d time.Duration, estimate float32) {
+		for end, next := now.Add(d), now; now.Before(end); {
+			if estimate > 0 && !now.Before(next) {
+				c.remb(estimate, now)
+				next = now.Add(time.Second)
+			}
+			frame(ref)
+		}
+	}
+
+	run(2*time.Second, 0)
+	if c.stats().Level != DegradeNone {
+		t.Fatal("degraded without an estimate")
+	}
+
+	run(1100*time.Millisecond, 20000)
+	if c.stats().Level != DegradeNonReference {
+		t.Fatalf("level %v after a low estimate", c.stats().Level)
+	}
+	if frame(nonRef) || !frame(ref) {
+		t.Error("non-reference frame forwarded, or reference dropped")
+	}
+
+	run(2*time.Second, 20000)
+	if c.stats().Level != DegradeKeyframesOnly {
+		t.Fatalf("level %v after a sustained low estimate", c.stats().Level)
+	}
+	if frame(ref) || !frame(idr) {
+		t.Error("keyframes-only let a P frame through, or dropped a keyframe")
+	}
+
+	// Headroom steps back up, but P frames wait for the next keyframe
+	run(6*time.Second, 100000)
+	if c.stats().Level != DegradeNonReference {
+		t.Fatalf("level %v after headroom", c.stats().Level)
+	}
+	if frame(ref) {
+		t.Error("P frame forwarded before a keyframe")
+	}
+	if !frame(idr) || !frame(ref) {
+		t.Error("stream not resumed at a keyframe")
+	}
+
+	// A stale estimate forwards everything
+	run(estimateMaxAge+time.Second, 0)
+	if st := c.stats(); st.Level != DegradeNone || st.Dropped == 0 {
+		t.Errorf("stats %+v after the estimate went stale", st)
+	}
+}
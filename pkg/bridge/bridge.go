@@ -5,38 +5,61 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/ethan/nest-cloudflare-relay/pkg/cloudflare"
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+	"github.com/ethan/nest-cloudflare-relay/pkg/membudget"
+	"github.com/ethan/nest-cloudflare-relay/pkg/recovery"
+	"github.com/ethan/nest-cloudflare-relay/pkg/sfu"
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/nack"
 	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
 	"github.com/pion/rtp/codecs"
 	"github.com/pion/webrtc/v4"
 )
 
-// Bridge connects RTSP streams to Cloudflare via WebRTC
+// nackBufferSize is how many sent video packets are kept for
+// retransmission; at 1200 bytes each, about a second of 8 Mbps video
+const nackBufferSize = 1024
+
+// Bridge connects RTSP streams to an SFU (Cloudflare by default) via WebRTC
 type Bridge struct {
-	logger      *slog.Logger
-	cfClient    *cloudflare.Client
-	cameraID    string // Unique camera identifier for track naming
-	sessionID   string
-	pc          *webrtc.PeerConnection
-	videoTrack  *webrtc.TrackLocalStaticRTP
-	audioTrack  *webrtc.TrackLocalStaticRTP
-	videoSender *webrtc.RTPSender // RTCP reader for video track
-	audioSender *webrtc.RTPSender // RTCP reader for audio track
-	ctx         context.Context
-	cancel      context.CancelFunc
-	wg          sync.WaitGroup
+	logger       *slog.Logger
+	backend      sfu.Backend
+	cameraID     string // Unique camera identifier for track naming
+	sessionID    string
+	resumeID     string // A session to adopt instead of creating one; see ResumeSession
+	pc           *webrtc.PeerConnection
+	videoTrack   *webrtc.TrackLocalStaticRTP
+	videoSample  *webrtc.TrackLocalStaticSample // Set instead of videoTrack with BridgeConfig.SampleTrack
+	audioTrack   *webrtc.TrackLocalStaticRTP
+	videoSender  *webrtc.RTPSender // RTCP reader for video track
+	audioSender  *webrtc.RTPSender // RTCP reader for audio track
+	videoWriter  *trackWriter      // Bounded, off-pacer WriteRTP for video
+	audioWriter  *trackWriter      // Bounded, off-pacer WriteRTP for audio
+	videoQuality *qualityTracker   // Receiver report gauges for video
+	audioQuality *qualityTracker   // Receiver report gauges for audio
+	ctx          context.Context
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
 
 	// Leaky bucket pacer (Section 8.2 from report)
 	pacer *Pacer
 
-	// H.264 RTP packetization
-	h264Payloader *codecs.H264Payloader
-	videoSeqNum   uint16
-	videoMu       sync.Mutex // Protects sequence number
+	// Video RTP packetization
+	videoCodec     string // "H264" or "H265"; set before CreateSession
+	videoPayloader rtp.Payloader
+	videoSeqNum    uint16
+	videoMu        sync.Mutex // Protects sequence number
+
+	// Sample track timing; used only by the pacer, one step at a time
+	sampleStarted bool
+	lastSampleTS  uint32
 
 	// Audio RTP packetization
 	audioSeqNum uint16
@@ -50,66 +73,236 @@ type Bridge struct {
 	connStateMu     sync.RWMutex
 	cachedConnState webrtc.PeerConnectionState
 
+	audioOnly bool // Publish only the audio track (set before CreateSession)
+
+	tracksMu sync.RWMutex
+	tracks   []sfu.Track // Published by Negotiate
+
+	peerStatsMu sync.RWMutex
+	peerStats   PeerStats // Refreshed by pollPeerStats
+	videoSilent bool      // Connected, but the last poll saw no new video bytes
+
+	// Set before CreateSession
+	videoSSRC, audioSSRC uint32            // Explicit SSRCs; zero picks a random one
+	videoName, audioName string            // Track names on the SFU
+	headerExts           []HeaderExtension // Offered RTP header extensions
+	writeTimeout         time.Duration     // Longest a track write may block
+	config               BridgeConfig      // MTU, payload types, fmtp and ICE servers
+
+	memory     *membudget.Account // Charged for video frames waiting in the pacer
+	overBudget atomic.Uint64      // Video frames dropped because the account was full
+	heldMu     sync.Mutex
+	heldVideo  []byte              // Last paced frame; the payloader may still reference its SPS/PPS
+	keyframes  keyframeCache       // Last keyframe, replayed for viewers that join
+	bandwidth  bandwidthController // Drops video when REMB says the path can't carry it
+
+	iceRestarts atomic.Uint64 // ICE restarts negotiated with the SFU
+	avsync      avSync        // Rebases audio and video onto one wall-clock timeline
+
 	// Connection ready signal (for pacer to wait before starting)
 	connectedChan chan struct{}
 	connectedOnce sync.Once
+
+	// OnKeyframeRequest is called from the RTCP reader for every PLI or FIR
+	// on the video track. It must not block.
+	OnKeyframeRequest func()
+
+	// OnPanic is called when a bridge goroutine or pacer step panics. The
+	// goroutine or track has stopped, so the bridge should be torn down. It
+	// must not block.
+	OnPanic func(err *recovery.PanicError)
 }
 
-// NewBridge creates a new WebRTC bridge to Cloudflare
-func NewBridge(ctx context.Context, cameraID string, cfClient *cloudflare.Client, logger *slog.Logger) (*Bridge, error) {
+// NewBridge creates a new WebRTC bridge publishing to the given SFU backend
+func NewBridge(ctx context.Context, cameraID string, backend sfu.Backend, logger *slog.Logger) (*Bridge, error) {
 	ctx, cancel := context.WithCancel(ctx)
 
 	b := &Bridge{
 		logger:          logger,
-		cfClient:        cfClient,
+		backend:         backend,
 		cameraID:        cameraID,
 		ctx:             ctx,
 		cancel:          cancel,
-		h264Payloader:   &codecs.H264Payloader{},
+		videoCodec:      "H264",
+		videoPayloader:  &codecs.H264Payloader{},
+		videoQuality:    newQualityTracker(90000),
+		audioQuality:    newQualityTracker(48000),
 		videoSeqNum:     uint16(time.Now().UnixNano() & 0xFFFF), // Random starting sequence number
 		cachedConnState: webrtc.PeerConnectionStateNew,          // Initial state
 		connectedChan:   make(chan struct{}),                    // Buffered to prevent blocking
+		headerExts:      DefaultHeaderExtensions,
+		writeTimeout:    DefaultWriteTimeout,
+		config:          DefaultBridgeConfig(),
+		videoName:       TrackName(cameraID, "video", 1),
+		audioName:       TrackName(cameraID, "audio", 1),
 	}
 
 	// Create pacer for smooth packet transmission (report Section 8.2)
 	b.pacer = NewPacer(ctx, logger)
+	b.pacer.cameraID = cameraID
+	b.pacer.OnPanic = b.panicked
 
 	return b, nil
 }
 
-// CreateSession creates a Cloudflare session and PeerConnection
-func (b *Bridge) CreateSession(ctx context.Context) error {
-	// Create Cloudflare session
-	session, err := b.cfClient.CreateSession(ctx)
-	if err != nil {
-		return fmt.Errorf("create Cloudflare session: %w", err)
+// TrackName is the SFU track name for a camera's relay of the given
+// generation: "{cameraID}-{kind}" for the first, "{cameraID}-{kind}-{n}" for
+// later ones, so a relay recreated while its predecessor is still closing
+// never publishes a name that is already live
+func TrackName(cameraID, kind string, generation uint64) string {
+	if generation <= 1 {
+		return fmt.Sprintf("%s-%s", cameraID, kind)
 	}
-	b.sessionID = session.SessionID
+	return fmt.Sprintf("%s-%s-%d", cameraID, kind, generation)
+}
 
-	b.logger.Info("created Cloudflare session", "session_id", b.sessionID)
+// SetGeneration names the tracks for the camera's nth relay; see TrackName.
+// Must be called before CreateSession.
+func (b *Bridge) SetGeneration(n uint64) {
+	b.videoName = TrackName(b.cameraID, "video", n)
+	b.audioName = TrackName(b.cameraID, "audio", n)
+}
 
-	// Create Pion PeerConnection
+// TrackNames returns the names the tracks are published under
+func (b *Bridge) TrackNames() (video, audio string) {
+	return b.videoName, b.audioName
+}
+
+// SetSSRCs assigns fixed SSRCs to the video and audio tracks instead of
+// random ones, so SFU-side logs and stats can be matched to cameras. Zero
+// keeps a random SSRC. Must be called before CreateSession.
+func (b *Bridge) SetSSRCs(video, audio uint32) {
+	b.videoSSRC = video
+	b.audioSSRC = audio
+}
+
+// SetHeaderExtensions replaces the RTP header extensions offered to the SFU
+// (DefaultHeaderExtensions); none disables them. Must be called before
+// CreateSession.
+func (b *Bridge) SetHeaderExtensions(exts ...HeaderExtension) {
+	b.headerExts = exts
+}
+
+// SetWriteTimeout sets how long a track write may block before it is
+// dropped and the transport reported stalled (DefaultWriteTimeout). Must be
+// called before CreateSession.
+func (b *Bridge) SetWriteTimeout(d time.Duration) {
+	b.writeTimeout = d
+}
+
+// SetMemoryAccount charges video frames queued in the pacer to a camera's
+// memory budget. Frames that don't fit are dropped rather than queued.
+func (b *Bridge) SetMemoryAccount(a *membudget.Account) {
+	b.memory = a
+	b.keyframes.memory = a
+}
+
+// SetConfig sets packetization and negotiation options; zero fields use the
+// defaults. It must be called before CreateSession.
+func (b *Bridge) SetConfig(cfg BridgeConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid bridge config: %w", err)
+	}
+	b.config = cfg.withDefaults()
+	return nil
+}
+
+// SetPacerConfig tunes the pacer's queues, catch-up and delay cap. Set before
+// the first write.
+func (b *Bridge) SetPacerConfig(cfg PacerConfig) error {
+	return b.pacer.SetConfig(cfg)
+}
+
+// SetAVSync rebases audio and video timestamps onto a shared wall-clock
+// timeline instead of passing the camera's through, so the tracks stay in
+// sync however far the camera's two clocks drift. Set before the first write.
+func (b *Bridge) SetAVSync(enabled bool) {
+	b.avsync.mu.Lock()
+	b.avsync.enabled = enabled
+	b.avsync.mu.Unlock()
+}
+
+// Sync returns the drift of each track from the A/V sync timeline
+func (b *Bridge) Sync() SyncStats {
+	return b.avsync.stats()
+}
+
+// SSRCs returns the SSRCs the tracks are sent with, zero for absent tracks
+func (b *Bridge) SSRCs() (video, audio uint32) {
+	return senderSSRC(b.videoSender), senderSSRC(b.audioSender)
+}
+
+// SetVideoCodec selects the codec of the published video track by SDP
+// encoding name: "H264" (the default) or "H265". Frames are passed through,
+// so it must match what the camera sends. Must be called before
+// CreateSession.
+func (b *Bridge) SetVideoCodec(codec string) error {
+	switch strings.ToUpper(codec) {
+	case "H264":
+		b.videoCodec, b.videoPayloader = "H264", &codecs.H264Payloader{}
+	case "H265", "HEVC":
+		b.videoCodec, b.videoPayloader = "H265", &codecs.H265Payloader{}
+	default:
+		return fmt.Errorf("unsupported video codec %q", codec)
+	}
+	return nil
+}
+
+// VideoCodec returns the codec of the published video track
+func (b *Bridge) VideoCodec() string {
+	return b.videoCodec
+}
+
+// SetAudioOnly makes CreateSession publish only the audio track, for cameras
+// relayed as microphones. Must be called before CreateSession.
+func (b *Bridge) SetAudioOnly() {
+	b.audioOnly = true
+}
+
+// ResumeSession makes CreateSession adopt an existing SFU session, left by
+// a previous leader, instead of creating one. If the session can't be
+// negotiated, Close releases it like any other. Must be called before
+// CreateSession.
+func (b *Bridge) ResumeSession(sessionID string) {
+	b.resumeID = sessionID
+}
+
+// CreateSession creates an SFU session and PeerConnection
+func (b *Bridge) CreateSession(ctx context.Context) error {
+	if b.resumeID != "" {
+		b.sessionID, b.resumeID = b.resumeID, ""
+		b.logger.Info("resumed SFU session", "backend", b.backend.Name(), "session_id", b.sessionID)
+	} else {
+		// Create SFU session
+		sessionID, err := b.backend.CreateSession(ctx, b.cameraID)
+		if err != nil {
+			return err
+		}
+		b.sessionID = sessionID
+
+		b.logger.Info("created SFU session", "backend", b.backend.Name(), "session_id", b.sessionID)
+	}
+
+	// Create Pion PeerConnection with the certificate every bridge shares
+	cert, fingerprint, err := certificates.get(b.logger)
+	if err != nil {
+		return err
+	}
 	config := webrtc.Configuration{
-		ICEServers: []webrtc.ICEServer{
-			{
-				URLs: []string{"stun:stun.l.google.com:19302"},
-			},
-		},
+		ICEServers:   b.iceServers(ctx),
+		Certificates: []webrtc.Certificate{cert},
 	}
 
-	// Create media engine with H264 and Opus
+	// Create media engine with the camera's video codec and Opus
 	m := &webrtc.MediaEngine{}
 
-	// Register H264 codec (Main Profile to match Nest camera output)
+	// Register H264 codec (Main Profile to match Nest camera output), or
+	// H265 for cameras that send it
 	if err := m.RegisterCodec(webrtc.RTPCodecParameters{
-		RTPCodecCapability: webrtc.RTPCodecCapability{
-			MimeType:    webrtc.MimeTypeH264,
-			ClockRate:   90000,
-			SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=4d001f",
-		},
-		PayloadType: 96,
+		RTPCodecCapability: b.videoCapability(),
+		PayloadType:        webrtc.PayloadType(b.config.VideoPayloadType),
 	}, webrtc.RTPCodecTypeVideo); err != nil {
-		return fmt.Errorf("register H264 codec: %w", err)
+		return fmt.Errorf("register %s codec: %w", b.videoCodec, err)
 	}
 
 	// Register Opus codec (we'll transcode AAC to Opus or use passthrough)
@@ -119,13 +312,34 @@ func (b *Bridge) CreateSession(ctx context.Context) error {
 			ClockRate: 48000,
 			Channels:  2,
 		},
-		PayloadType: 111,
+		PayloadType: webrtc.PayloadType(b.config.AudioPayloadType),
 	}, webrtc.RTPCodecTypeAudio); err != nil {
 		return fmt.Errorf("register Opus codec: %w", err)
 	}
 
+	// Send RTCP sender reports so receiver reports carry LSR/DLSR for RTT
+	registry := &interceptor.Registry{}
+	if err := webrtc.ConfigureRTCPReports(registry); err != nil {
+		return fmt.Errorf("configure RTCP reports: %w", err)
+	}
+	// Record outbound-rtp and remote-inbound-rtp stats for GetWebRTCStats
+	if err := webrtc.ConfigureStatsInterceptor(registry); err != nil {
+		return fmt.Errorf("configure stats interceptor: %w", err)
+	}
+	if err := configureHeaderExtensions(m, registry, b.headerExts); err != nil {
+		return err
+	}
+
+	// Answer NACKs for video from the last nackBufferSize packets sent, so
+	// minor loss is repaired without waiting for a keyframe
+	responder, err := nack.NewResponderInterceptor(nack.ResponderSize(nackBufferSize))
+	if err != nil {
+		return fmt.Errorf("create NACK responder: %w", err)
+	}
+	registry.Add(responder)
+
 	// Create API with custom media engine
-	api := webrtc.NewAPI(webrtc.WithMediaEngine(m))
+	api := webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithInterceptorRegistry(registry))
 
 	pc, err := api.NewPeerConnection(config)
 	if err != nil {
@@ -153,80 +367,161 @@ func (b *Bridge) CreateSession(ctx context.Context) error {
 		}
 	})
 
-	// Create video track with unique name based on camera ID
-	// This ensures viewer can map tracks back to cameras correctly
-	videoTrackName := fmt.Sprintf("%s-video", b.cameraID)
-	videoTrack, err := webrtc.NewTrackLocalStaticRTP(
-		webrtc.RTPCodecCapability{
-			MimeType:  webrtc.MimeTypeH264,
+	// Audio-only cameras publish no video track at all
+	if !b.audioOnly {
+		// Create video track with unique name based on camera ID
+		// This ensures viewer can map tracks back to cameras correctly
+		videoCapability := webrtc.RTPCodecCapability{
+			MimeType:  b.videoCapability().MimeType,
 			ClockRate: 90000,
-		},
-		videoTrackName,
-		"nest-camera-video",
-	)
-	if err != nil {
-		return fmt.Errorf("create video track: %w", err)
-	}
-	b.videoTrack = videoTrack
+		}
+		var videoTrack webrtc.TrackLocal
+		if b.config.SampleTrack {
+			b.videoSample, err = webrtc.NewTrackLocalStaticSample(videoCapability, b.videoName, "nest-camera-video")
+			if err != nil {
+				return fmt.Errorf("create video track: %w", err)
+			}
+			videoTrack = b.videoSample
+			b.videoWriter = newSampleTrackWriter(b.videoSample, "video", b.writeTimeout, b.logger)
+		} else {
+			b.videoTrack, err = webrtc.NewTrackLocalStaticRTP(videoCapability, b.videoName, "nest-camera-video")
+			if err != nil {
+				return fmt.Errorf("create video track: %w", err)
+			}
+			videoTrack = b.videoTrack
+			b.videoWriter = newTrackWriter(b.videoTrack, "video", b.writeTimeout, b.logger)
+		}
 
-	videoSender, err := pc.AddTrack(videoTrack)
-	if err != nil {
-		return fmt.Errorf("add video track: %w", err)
+		videoSender, err := b.addTrack(videoTrack, b.videoSSRC)
+		if err != nil {
+			return fmt.Errorf("add video track: %w", err)
+		}
+		b.videoSender = videoSender
 	}
-	b.videoSender = videoSender
 
 	// Create audio track with unique name based on camera ID
-	audioTrackName := fmt.Sprintf("%s-audio", b.cameraID)
 	audioTrack, err := webrtc.NewTrackLocalStaticRTP(
 		webrtc.RTPCodecCapability{
 			MimeType:  webrtc.MimeTypeOpus,
 			ClockRate: 48000,
 			Channels:  2,
 		},
-		audioTrackName,
+		b.audioName,
 		"nest-camera-audio",
 	)
 	if err != nil {
 		return fmt.Errorf("create audio track: %w", err)
 	}
 	b.audioTrack = audioTrack
+	b.audioWriter = newTrackWriter(audioTrack, "audio", b.writeTimeout, b.logger)
 
-	audioSender, err := pc.AddTrack(audioTrack)
+	audioSender, err := b.addTrack(audioTrack, b.audioSSRC)
 	if err != nil {
 		return fmt.Errorf("add audio track: %w", err)
 	}
 	b.audioSender = audioSender
 
-	b.logger.Info("WebRTC peer connection created with tracks")
+	b.logger.Info("WebRTC peer connection created with tracks", "dtls_fingerprint", fingerprint)
 
-	// Start RTCP reader goroutines
+	// Start RTCP reader and track writer goroutines
 	b.startRTCPReaders()
+	if b.videoWriter != nil {
+		goroutines.Go("bridge.writer.video", b.cameraID, func() { b.videoWriter.run(b.ctx, b.panicked) })
+	}
+	goroutines.Go("bridge.writer.audio", b.cameraID, func() { b.audioWriter.run(b.ctx, b.panicked) })
 
 	return nil
 }
 
-// Negotiate performs SDP negotiation with Cloudflare
-func (b *Bridge) Negotiate(ctx context.Context) error {
-	// Create offer
-	offer, err := b.pc.CreateOffer(nil)
+// iceServers returns the configured ICE servers plus any from the TURN
+// provider. Without TURN credentials the bridge still tries to connect, which
+// works from anywhere but behind symmetric NAT or strict egress rules.
+func (b *Bridge) iceServers(ctx context.Context) []webrtc.ICEServer {
+	if b.config.TURN == nil {
+		return b.config.ICEServers
+	}
+	turn, err := b.config.TURN.ICEServers(ctx)
 	if err != nil {
-		return fmt.Errorf("create offer: %w", err)
+		b.logger.Warn("TURN credentials unavailable, connecting without TURN", "error", err)
+		return b.config.ICEServers
 	}
+	return append(slices.Clip(b.config.ICEServers), turn...)
+}
 
-	// Set local description
-	if err := b.pc.SetLocalDescription(offer); err != nil {
-		return fmt.Errorf("set local description: %w", err)
+// videoFeedback is the RTCP feedback offered for the video track
+var videoFeedback = []webrtc.RTCPFeedback{{Type: "nack"}, {Type: "nack", Parameter: "pli"}}
+
+// videoCapability is the registered codec of the video track
+func (b *Bridge) videoCapability() webrtc.RTPCodecCapability {
+	if b.videoCodec == "H265" {
+		return webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH265, ClockRate: 90000, RTCPFeedback: videoFeedback}
+	}
+	return webrtc.RTPCodecCapability{
+		MimeType:     webrtc.MimeTypeH264,
+		ClockRate:    90000,
+		SDPFmtpLine:  b.config.H264FmtpLine,
+		RTCPFeedback: videoFeedback,
+	}
+}
+
+// addTrack adds a send track like pc.AddTrack, with a fixed SSRC when ssrc
+// is non-zero
+func (b *Bridge) addTrack(track webrtc.TrackLocal, ssrc uint32) (*webrtc.RTPSender, error) {
+	init := webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendrecv}
+	if ssrc != 0 {
+		init.SendEncodings = []webrtc.RTPEncodingParameters{
+			{RTPCodingParameters: webrtc.RTPCodingParameters{SSRC: webrtc.SSRC(ssrc)}},
+		}
 	}
 
-	// Wait for ICE gathering
+	transceiver, err := b.pc.AddTransceiverFromTrack(track, init)
+	if err != nil {
+		return nil, err
+	}
+	return transceiver.Sender(), nil
+}
+
+// senderSSRC returns the SSRC of a sender's first encoding
+func senderSSRC(sender *webrtc.RTPSender) uint32 {
+	if sender == nil {
+		return 0
+	}
+	if encodings := sender.GetParameters().Encodings; len(encodings) > 0 {
+		return uint32(encodings[0].SSRC)
+	}
+	return 0
+}
+
+// gatherCandidates waits for ICE gathering after SetLocalDescription, so the
+// offer sent to the SFU carries every candidate
+func (b *Bridge) gatherCandidates(ctx context.Context) error {
 	gatherComplete := webrtc.GatheringCompletePromise(b.pc)
 	select {
 	case <-gatherComplete:
+		return nil
 	case <-time.After(10 * time.Second):
 		return fmt.Errorf("ICE gathering timeout")
 	case <-ctx.Done():
 		return ctx.Err()
 	}
+}
+
+// Negotiate performs SDP negotiation with the SFU
+func (b *Bridge) Negotiate(ctx context.Context) error {
+	// Create offer
+	offer, err := b.pc.CreateOffer(nil)
+	if err != nil {
+		return fmt.Errorf("create offer: %w", err)
+	}
+
+	// Set local description
+	if err := b.pc.SetLocalDescription(offer); err != nil {
+		return fmt.Errorf("set local description: %w", err)
+	}
+
+	if err := b.gatherCandidates(ctx); err != nil {
+		return err
+	}
 
 	localSDP := b.pc.LocalDescription().SDP
 
@@ -248,49 +543,59 @@ func (b *Bridge) Negotiate(ctx context.Context) error {
 
 	b.logger.Info("transceivers ready", "video_mid", videoMid, "audio_mid", audioMid)
 
-	// Send offer to Cloudflare via AddTracks
+	// Send offer to the SFU
 	// Use unique track names so viewer can map tracks back to cameras
-	tracksReq := &cloudflare.TracksRequest{
-		SessionDescription: &cloudflare.SessionDescription{
-			SDP:  localSDP,
-			Type: "offer",
-		},
-		Tracks: []cloudflare.TrackObject{
-			{
-				Location:  "local",
-				Mid:       videoMid,
-				TrackName: fmt.Sprintf("%s-video", b.cameraID),
-			},
-			{
-				Location:  "local",
-				Mid:       audioMid,
-				TrackName: fmt.Sprintf("%s-audio", b.cameraID),
-			},
-		},
+	var tracks []sfu.Track
+	if videoMid != "" {
+		tracks = append(tracks, sfu.Track{Mid: videoMid, Name: b.videoName, Kind: "video"})
 	}
+	tracks = append(tracks, sfu.Track{Mid: audioMid, Name: b.audioName, Kind: "audio"})
 
-	tracksResp, err := b.cfClient.AddTracksWithRetry(ctx, b.sessionID, tracksReq, 3)
+	remote, err := b.backend.PublishTracks(ctx, b.sessionID, sfu.Description{Type: "offer", SDP: localSDP}, tracks)
 	if err != nil {
-		return fmt.Errorf("add tracks to Cloudflare: %w", err)
-	}
-
-	if tracksResp.SessionDescription == nil {
-		return fmt.Errorf("Cloudflare did not return SDP answer")
+		return err
 	}
+	b.tracksMu.Lock()
+	b.tracks = tracks
+	b.tracksMu.Unlock()
 
-	// Set remote description (answer from Cloudflare)
+	// Set remote description (answer from the SFU)
 	answer := webrtc.SessionDescription{
 		Type: webrtc.SDPTypeAnswer,
-		SDP:  tracksResp.SessionDescription.SDP,
+		SDP:  remote.SDP,
 	}
 
 	if err := b.pc.SetRemoteDescription(answer); err != nil {
 		return fmt.Errorf("set remote description: %w", err)
 	}
 
+	// Fail fast rather than stream media the SFU's viewers cannot decode
+	if err := validateAnswer(remote.SDP, tracks, b.videoCodec); err != nil {
+		b.logger.Error("incompatible SDP answer", "session_id", b.sessionID, "error", err)
+		b.logger.Debug("rejected SDP answer", "sdp", remote.SDP)
+		return fmt.Errorf("validate SDP answer: %w", err)
+	}
+
+	// Extensions the SFU accepted; unanswered ones are silently not sent
+	var negotiated []string
+	for _, sender := range []*webrtc.RTPSender{b.videoSender, b.audioSender} {
+		if sender == nil {
+			continue
+		}
+		for _, ext := range sender.GetParameters().HeaderExtensions {
+			if !slices.Contains(negotiated, ext.URI) {
+				negotiated = append(negotiated, ext.URI)
+			}
+		}
+	}
+	videoSSRC, audioSSRC := b.SSRCs()
+
 	b.logger.Info("SDP negotiation complete",
 		"session_id", b.sessionID,
-		"tracks", len(tracksResp.Tracks))
+		"tracks", len(tracks),
+		"video_ssrc", videoSSRC,
+		"audio_ssrc", audioSSRC,
+		"header_extensions", negotiated)
 
 	// Configure pacer callbacks BEFORE starting (report Section 8.2)
 	b.pacer.SetWriteCallbacks(
@@ -298,23 +603,32 @@ func (b *Bridge) Negotiate(ctx context.Context) error {
 		b.writeAudioSampleDirect, // Audio write function
 	)
 
-	// Start pacer goroutines (they will wait for connection)
+	// Start the pacer (it will wait for connection)
 	// CRITICAL: Pacer must wait for PeerConnectionStateConnected (report Section 2.1)
 	// "WriteRTP does not block waiting for network readiness. If called before
 	// ICE/DTLS ready, packets are silently dropped."
-	go b.startPacerWhenReady()
+	go func() {
+		defer goroutines.Track("bridge.startPacer", b.cameraID)()
+		defer recovery.Recover("bridge.startPacer", b.panicked)
+		b.startPacerWhenReady()
+	}()
+	b.startPeerStats()
 
 	return nil
 }
 
 // WriteVideoRTP writes a video RTP packet to the WebRTC track
 func (b *Bridge) WriteVideoRTP(packet *rtp.Packet) error {
+	if b.videoSample != nil {
+		return fmt.Errorf("video track takes samples, not RTP packets")
+	}
 	if b.videoTrack == nil {
 		return fmt.Errorf("video track not initialized")
 	}
+	packet = b.rebased(packet, b.avsync.videoTS(packet.Timestamp, time.Now()))
 
-	if err := b.videoTrack.WriteRTP(packet); err != nil {
-		if err == io.ErrClosedPipe {
+	if err := b.videoWriter.write(packet); err != nil {
+		if ClassifyWriteError(err) == WriteErrorClosed {
 			return nil // Track closed gracefully
 		}
 		return err
@@ -329,12 +643,55 @@ func (b *Bridge) WriteVideoRTP(packet *rtp.Packet) error {
 //
 // NEW: This now enqueues to the pacer instead of writing directly (Section 8.2)
 func (b *Bridge) WriteVideoSample(data []byte, sourceTimestamp uint32) error {
-	if b.videoTrack == nil {
+	return b.WriteVideoSampleAt(data, sourceTimestamp, time.Time{})
+}
+
+// WriteVideoSampleAt is WriteVideoSample for a frame read from the camera at
+// arrivedAt, so latency stats cover the relay's processing before the bridge.
+// A zero arrivedAt measures from the call.
+func (b *Bridge) WriteVideoSampleAt(data []byte, sourceTimestamp uint32, arrivedAt time.Time) error {
+	if b.videoTrack == nil && b.videoSample == nil {
 		return fmt.Errorf("video track not initialized")
 	}
 
+	// A viewer that just joined gets the cached keyframe ahead of this frame,
+	// one tick after the last frame so timestamps keep increasing
+	now := time.Now()
+	if arrivedAt.IsZero() {
+		sourceTimestamp = b.avsync.videoTS(sourceTimestamp, now)
+	} else {
+		sourceTimestamp = b.avsync.videoTS(sourceTimestamp, arrivedAt)
+	}
+	keyframe := isKeyframe(b.videoCodec, data)
+	forward, changed := b.bandwidth.admit(b.videoCodec, data, keyframe, now)
+	if changed {
+		bw := b.bandwidth.stats()
+		b.logger.Warn("video degrade level changed",
+			"level", bw.Level.String(),
+			"estimate_bps", bw.EstimateBps,
+			"camera_bps", bw.CameraBps,
+			"dropped", bw.Dropped)
+	}
+	if !forward {
+		return nil
+	}
+
+	if keyframe {
+		b.keyframes.store(data, now)
+	} else if keyframe := b.keyframes.take(now); keyframe != nil {
+		b.videoMu.Lock()
+		replayTS := b.lastVideoTS + 1
+		b.videoMu.Unlock()
+
+		b.logger.Debug("replaying cached keyframe for a joining viewer", "size_bytes", len(keyframe))
+		if err := b.enqueueVideo(keyframe, replayTS, now, true); err != nil {
+			return err
+		}
+	}
+
+	// Held only for the diagnostics below: the pacer takes videoMu to write,
+	// so holding it while EnqueueVideo blocks on a full queue deadlocks
 	b.videoMu.Lock()
-	defer b.videoMu.Unlock()
 
 	// Timestamp validation and diagnostics
 	if b.lastVideoTS > 0 {
@@ -360,24 +717,73 @@ func (b *Bridge) WriteVideoSample(data []byte, sourceTimestamp uint32) error {
 	}
 
 	b.lastVideoTS = sourceTimestamp
+	b.videoMu.Unlock()
+
+	return b.enqueueVideo(data, sourceTimestamp, arrivedAt, keyframe)
+}
+
+// enqueueVideo hands a copy of an access unit to the pacer, charged to the
+// camera's memory budget
+func (b *Bridge) enqueueVideo(data []byte, sourceTimestamp uint32, arrivedAt time.Time, keyframe bool) error {
+	if !b.memory.Reserve(len(data)) {
+		if n := b.overBudget.Add(1); n == 1 || n%100 == 0 {
+			b.logger.Warn("memory budget full, dropping video frame",
+				"size_bytes", len(data),
+				"buffered_bytes", b.memory.Used(),
+				"dropped", n)
+		}
+		return nil
+	}
+
+	// The caller may reuse data, so the pacer gets its own pooled copy
+	buf := append(membudget.GetBuffer(len(data)), data...)
 
 	// Enqueue to pacer for smooth transmission (prevents TCP burst forwarding)
 	// The pacer will calculate delays based on RTP timestamp deltas
 	packet := &PacedPacket{
 		Timestamp:  sourceTimestamp,
-		NALUs:      data, // Keep in AVC format for now
+		IsKeyframe: keyframe,
+		Reference:  keyframe || isReference(b.videoCodec, data),
+		NALUs:      buf, // Keep in AVC format for now
 		TrackType:  "video",
 		ReceivedAt: time.Now(),
+		ArrivedAt:  arrivedAt,
+	}
+	packet.Release = func() {
+		b.memory.Release(len(buf))
+		if packet.Dropped {
+			membudget.PutBuffer(buf) // Never reached the payloader
+			return
+		}
+		b.recycleVideo(buf)
 	}
 
-	return b.pacer.EnqueueVideo(packet)
+	if err := b.pacer.EnqueueVideo(packet); err != nil {
+		packet.drop()
+		return err
+	}
+	return nil
+}
+
+// recycleVideo returns the previously paced frame to the pool and holds buf
+// in its place. The H.264 payloader keeps SPS/PPS slices of a frame until
+// the next NALU, so a frame is only reused once its successor was written.
+func (b *Bridge) recycleVideo(buf []byte) {
+	b.heldMu.Lock()
+	prev := b.heldVideo
+	b.heldVideo = buf
+	b.heldMu.Unlock()
+
+	if prev != nil {
+		membudget.PutBuffer(prev)
+	}
 }
 
 // writeVideoSampleDirect is the actual write function called by the pacer
 // This performs the packetization and WriteRTP after pacing delay
-// Note: Mutex must NOT be locked here as this is called from pacer goroutine
+// Note: Mutex must NOT be locked here as this is called from the pacer
 func (b *Bridge) writeVideoSampleDirect(data []byte, sourceTimestamp uint32) error {
-	if b.videoTrack == nil {
+	if b.videoTrack == nil && b.videoSample == nil {
 		return fmt.Errorf("video track not initialized")
 	}
 
@@ -387,6 +793,9 @@ func (b *Bridge) writeVideoSampleDirect(data []byte, sourceTimestamp uint32) err
 	if err != nil {
 		return fmt.Errorf("extract NAL units: %w", err)
 	}
+	if b.videoSample != nil {
+		return b.writeVideoSampleTrack(nalus, sourceTimestamp)
+	}
 
 	// Lock only for sequence number access (minimize lock contention)
 	b.videoMu.Lock()
@@ -397,10 +806,10 @@ func (b *Bridge) writeVideoSampleDirect(data []byte, sourceTimestamp uint32) err
 	timestamp := sourceTimestamp
 
 	// Packetize and send each NAL unit
-	const mtu = 1200 // Safe MTU for WebRTC
+	mtu := uint16(b.config.MTU)
 	for naluIdx, nalu := range nalus {
-		// Use H264Payloader to fragment NAL unit into MTU-sized RTP packets
-		payloads := b.h264Payloader.Payload(mtu, nalu)
+		// Fragment NAL unit into MTU-sized RTP packets (FU-A for H.264, FU for H.265)
+		payloads := b.videoPayloader.Payload(mtu, nalu)
 
 		// Write each fragmented payload as a separate RTP packet
 		for i, payload := range payloads {
@@ -408,7 +817,7 @@ func (b *Bridge) writeVideoSampleDirect(data []byte, sourceTimestamp uint32) err
 			packet := &rtp.Packet{
 				Header: rtp.Header{
 					Version:        2,
-					PayloadType:    96, // H.264 payload type
+					PayloadType:    b.config.VideoPayloadType,
 					SequenceNumber: seqNum,
 					Timestamp:      timestamp, // PASSTHROUGH from source
 					// Mark last packet of last NAL unit in frame
@@ -418,8 +827,8 @@ func (b *Bridge) writeVideoSampleDirect(data []byte, sourceTimestamp uint32) err
 			}
 
 			// Write packet to track
-			if err := b.videoTrack.WriteRTP(packet); err != nil {
-				if err == io.ErrClosedPipe {
+			if err := b.videoWriter.write(packet); err != nil {
+				if ClassifyWriteError(err) == WriteErrorClosed {
 					return nil // Track closed gracefully
 				}
 				b.logger.Error("failed to write RTP packet",
@@ -484,9 +893,14 @@ func (b *Bridge) WriteAudioRTP(packet *rtp.Packet) error {
 	if b.audioTrack == nil {
 		return fmt.Errorf("audio track not initialized")
 	}
+	return b.writeAudioRTP(b.rebased(packet, b.avsync.audioTS(packet.Timestamp, time.Now())))
+}
 
-	if err := b.audioTrack.WriteRTP(packet); err != nil {
-		if err == io.ErrClosedPipe {
+// writeAudioRTP queues an audio packet whose timestamp is already on the
+// track's timeline
+func (b *Bridge) writeAudioRTP(packet *rtp.Packet) error {
+	if err := b.audioWriter.write(packet); err != nil {
+		if ClassifyWriteError(err) == WriteErrorClosed {
 			return nil
 		}
 		return err
@@ -505,11 +919,12 @@ func (b *Bridge) WriteAudioSample(data []byte, sourceTimestamp uint32) error {
 	}
 
 	// Enqueue to pacer for smooth transmission
+	now := time.Now()
 	packet := &PacedPacket{
-		Timestamp:  sourceTimestamp,
+		Timestamp:  b.avsync.audioTS(sourceTimestamp, now),
 		NALUs:      data,
 		TrackType:  "audio",
-		ReceivedAt: time.Now(),
+		ReceivedAt: now,
 	}
 
 	return b.pacer.EnqueueAudio(packet)
@@ -528,7 +943,7 @@ func (b *Bridge) writeAudioSampleDirect(data []byte, sourceTimestamp uint32) err
 	packet := &rtp.Packet{
 		Header: rtp.Header{
 			Version:        2,
-			PayloadType:    111,
+			PayloadType:    b.config.AudioPayloadType,
 			SequenceNumber: b.audioSeqNum,
 			Timestamp:      sourceTimestamp, // PASSTHROUGH from source (48kHz clock)
 		},
@@ -537,7 +952,18 @@ func (b *Bridge) writeAudioSampleDirect(data []byte, sourceTimestamp uint32) err
 
 	b.audioSeqNum++
 
-	return b.WriteAudioRTP(packet)
+	return b.writeAudioRTP(packet)
+}
+
+// rebased returns packet with its timestamp replaced by ts, copying the
+// header rather than changing a packet the caller may still hold
+func (b *Bridge) rebased(packet *rtp.Packet, ts uint32) *rtp.Packet {
+	if packet.Timestamp == ts {
+		return packet
+	}
+	p := *packet
+	p.Timestamp = ts
+	return &p
 }
 
 // GetSessionID returns the Cloudflare session ID
@@ -553,6 +979,50 @@ func (b *Bridge) GetConnectionState() webrtc.PeerConnectionState {
 	return b.cachedConnState
 }
 
+// Connected returns a channel closed once the peer connection first reaches
+// connected
+func (b *Bridge) Connected() <-chan struct{} {
+	return b.connectedChan
+}
+
+// Quality returns the receiver-reported quality of the video and audio tracks
+func (b *Bridge) Quality() (video, audio TrackQuality) {
+	return b.videoQuality.snapshot(), b.audioQuality.snapshot()
+}
+
+// WriteStalled reports whether a track write has been blocked in the
+// transport for longer than the write timeout, which means the DTLS/ICE
+// transport is hung even if the connection state has not caught up yet
+func (b *Bridge) WriteStalled() bool {
+	now := time.Now()
+	return (b.videoWriter != nil && b.videoWriter.isStalled(now)) ||
+		(b.audioWriter != nil && b.audioWriter.isStalled(now))
+}
+
+// WriteStats returns failed-write counters summed over both tracks
+func (b *Bridge) WriteStats() WriteStats {
+	var stats WriteStats
+	for _, w := range []*trackWriter{b.videoWriter, b.audioWriter} {
+		if w != nil {
+			stats.Stalled += w.stalled.Load()
+			stats.Errors += w.errors.Load()
+		}
+	}
+	stats.OverBudget = b.overBudget.Load()
+	return stats
+}
+
+// Latency returns percentiles of recent per-frame video latency, from camera
+// arrival to the RTP write
+func (b *Bridge) Latency() LatencyStats {
+	return b.pacer.VideoLatency()
+}
+
+// PacerStats returns the pacer's counters and queue depths
+func (b *Bridge) PacerStats() PacerStats {
+	return b.pacer.GetStats()
+}
+
 // startPacerWhenReady waits for PeerConnectionStateConnected before starting pacer
 // Implements the "Decoupled Pacer Pattern" from report Section 7.2
 // This prevents packets from being silently dropped before ICE/DTLS is ready
@@ -576,6 +1046,18 @@ func (b *Bridge) startPacerWhenReady() {
 	}
 }
 
+// panicked reports a recovered panic from one of the bridge's goroutines
+func (b *Bridge) panicked(err *recovery.PanicError) {
+	if b.OnPanic != nil {
+		b.OnPanic(err)
+		return
+	}
+	b.logger.Error("recovered panic in bridge goroutine",
+		"goroutine", err.Goroutine,
+		"panic", fmt.Sprint(err.Value),
+		"stack", string(err.Stack))
+}
+
 // startRTCPReaders spawns goroutines to read RTCP feedback from Cloudflare
 func (b *Bridge) startRTCPReaders() {
 	// Video track RTCP reader
@@ -583,7 +1065,9 @@ func (b *Bridge) startRTCPReaders() {
 		b.wg.Add(1)
 		go func() {
 			defer b.wg.Done()
-			b.readRTCP(b.videoSender, "video")
+			defer goroutines.Track("bridge.rtcp.video", b.cameraID)()
+			defer recovery.Recover("bridge.rtcp.video", b.panicked)
+			b.readRTCP(b.videoSender, "video", b.videoQuality)
 		}()
 	}
 
@@ -592,15 +1076,21 @@ func (b *Bridge) startRTCPReaders() {
 		b.wg.Add(1)
 		go func() {
 			defer b.wg.Done()
-			b.readRTCP(b.audioSender, "audio")
+			defer goroutines.Track("bridge.rtcp.audio", b.cameraID)()
+			defer recovery.Recover("bridge.rtcp.audio", b.panicked)
+			b.readRTCP(b.audioSender, "audio", b.audioQuality)
 		}()
 	}
 }
 
-// readRTCP reads RTCP packets from an RTPSender and logs feedback
-func (b *Bridge) readRTCP(sender *webrtc.RTPSender, trackType string) {
+// readRTCP reads RTCP packets from an RTPSender, logs feedback and feeds
+// receiver reports into the track's quality gauges
+func (b *Bridge) readRTCP(sender *webrtc.RTPSender, trackType string, quality *qualityTracker) {
 	b.logger.Info("[rtcp:reader] started", "track", trackType)
 
+	// Our SSRC, to pick this track's block out of compound receiver reports
+	ssrc := senderSSRC(sender)
+
 	for {
 		// Read RTCP packets with context cancellation check
 		packets, _, err := sender.ReadRTCP()
@@ -627,22 +1117,47 @@ func (b *Bridge) readRTCP(sender *webrtc.RTPSender, trackType string) {
 					"track", trackType,
 					"media_ssrc", pkt.MediaSSRC,
 					"sender_ssrc", pkt.SenderSSRC)
+				b.keyframeRequested(trackType)
 
 			case *rtcp.FullIntraRequest:
 				b.logger.Warn("RTCP FIR received - viewer requesting keyframe",
 					"track", trackType,
 					"media_ssrc", pkt.MediaSSRC)
+				b.keyframeRequested(trackType)
+
+			case *rtcp.TransportLayerNack:
+				// Answered by the NACK responder interceptor; counted here
+				n := 0
+				for _, pair := range pkt.Nacks {
+					n += len(pair.PacketList())
+				}
+				quality.nacked(n)
+				b.logger.Debug("RTCP NACK received",
+					"track", trackType,
+					"packets", n)
 
 			case *rtcp.ReceiverEstimatedMaximumBitrate:
+				b.bandwidth.remb(pkt.Bitrate, time.Now())
 				b.logger.Debug("RTCP REMB received",
 					"track", trackType,
 					"bitrate_bps", pkt.Bitrate)
 
 			case *rtcp.ReceiverReport:
+				now := time.Now()
+				for _, report := range pkt.Reports {
+					if ssrc != 0 && report.SSRC != ssrc {
+						continue
+					}
+					quality.update(report, now)
+				}
+				q := quality.snapshot()
 				b.logger.Debug("RTCP RR received",
 					"track", trackType,
 					"ssrc", pkt.SSRC,
-					"reports", len(pkt.Reports))
+					"reports", len(pkt.Reports),
+					"fraction_lost", q.FractionLost,
+					"jitter", q.Jitter,
+					"rtt", q.RTT)
 
 			default:
 				b.logger.Debug("RTCP packet received",
@@ -653,6 +1168,52 @@ func (b *Bridge) readRTCP(sender *webrtc.RTPSender, trackType string) {
 	}
 }
 
+// keyframeRequested queues a replay of the cached keyframe and forwards a
+// video keyframe request to OnKeyframeRequest
+func (b *Bridge) keyframeRequested(trackType string) {
+	if trackType != "video" {
+		return
+	}
+	b.keyframes.request()
+	if b.OnKeyframeRequest != nil {
+		b.OnKeyframeRequest()
+	}
+}
+
+// KeyframeReplays returns how many cached keyframes were replayed for
+// joining viewers
+func (b *Bridge) KeyframeReplays() uint64 {
+	return b.keyframes.count()
+}
+
+// Bandwidth returns the REMB estimate, camera bitrate and frames dropped to
+// fit one to the other
+func (b *Bridge) Bandwidth() BandwidthStats {
+	return b.bandwidth.stats()
+}
+
+// closeTracks asks backends that support it to end the published tracks
+func (b *Bridge) closeTracks() {
+	closer, ok := b.backend.(sfu.TrackCloser)
+	if !ok || b.sessionID == "" {
+		return
+	}
+	b.tracksMu.RLock()
+	tracks := b.tracks
+	b.tracksMu.RUnlock()
+	if len(tracks) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := closer.CloseTracks(ctx, b.sessionID, tracks); err != nil {
+		b.logger.Warn("error closing SFU tracks", "session_id", b.sessionID, "error", err)
+		return
+	}
+	b.logger.Info("closed SFU tracks", "session_id", b.sessionID, "tracks", len(tracks))
+}
+
 // Close closes the bridge and all resources
 func (b *Bridge) Close() error {
 	b.logger.Info("closing bridge")
@@ -664,6 +1225,10 @@ func (b *Bridge) Close() error {
 
 	b.cancel()
 	b.wg.Wait()
+	b.keyframes.clear()
+
+	// End the tracks on the SFU before the transport goes away
+	b.closeTracks()
 
 	if b.pc != nil {
 		if err := b.pc.Close(); err != nil {
@@ -671,5 +1236,14 @@ func (b *Bridge) Close() error {
 		}
 	}
 
+	// Release the SFU session (bounded so shutdown never hangs on the network)
+	if b.sessionID != "" {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := b.backend.Close(closeCtx, b.sessionID); err != nil {
+			b.logger.Error("error closing SFU session", "session_id", b.sessionID, "error", err)
+		}
+	}
+
 	return nil
 }
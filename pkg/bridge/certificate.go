@@ -0,0 +1,63 @@
+package bridge
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// certificateRenewal is how long before expiry the shared certificate is
+// replaced, so no new PeerConnection starts with one about to lapse. Pion
+// certificates are valid for a month.
+const certificateRenewal = 24 * time.Hour
+
+// certificates hands every bridge the same DTLS certificate, instead of each
+// PeerConnection generating its own key pair at startup
+var certificates certificateProvider
+
+type certificateProvider struct {
+	mu          sync.Mutex
+	cert        *webrtc.Certificate
+	fingerprint string
+}
+
+// get returns the shared certificate and its fingerprint, generating one if
+// there is none or it is close to expiring
+func (p *certificateProvider) get(logger *slog.Logger) (webrtc.Certificate, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cert != nil && time.Until(p.cert.Expires()) > certificateRenewal {
+		return *p.cert, p.fingerprint, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return webrtc.Certificate{}, "", fmt.Errorf("generate DTLS key: %w", err)
+	}
+	cert, err := webrtc.GenerateCertificate(key)
+	if err != nil {
+		return webrtc.Certificate{}, "", fmt.Errorf("generate DTLS certificate: %w", err)
+	}
+	fingerprints, err := cert.GetFingerprints()
+	if err != nil {
+		return webrtc.Certificate{}, "", fmt.Errorf("DTLS certificate fingerprint: %w", err)
+	}
+	var fp []string
+	for _, f := range fingerprints {
+		fp = append(fp, f.Algorithm+" "+f.Value)
+	}
+
+	p.cert, p.fingerprint = cert, strings.Join(fp, ", ")
+	logger.Info("generated shared DTLS certificate",
+		"fingerprint", p.fingerprint,
+		"expires_at", cert.Expires().Format(time.RFC3339))
+	return *p.cert, p.fingerprint, nil
+}
@@ -0,0 +1,24 @@
+package bridge
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestCertificateProviderShares(t *testing.T) {
+	var p certificateProvider
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	first, fp, err := p.get(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, fp2, err := p.get(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Equals(second) || fp != fp2 || fp == "" {
+		t.Errorf("certificate regenerated: %q then %q", fp, fp2)
+	}
+}
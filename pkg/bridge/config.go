@@ -0,0 +1,187 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// Packetization and negotiation defaults. Clock rates are not configurable:
+// H.264 and H.265 always use 90 kHz and Opus 48 kHz (RFC 6184, 7798, 7587).
+const (
+	DefaultMTU              = 1200 // Leaves room for SRTP, DTLS and TURN overhead on a 1500-byte path
+	DefaultVideoPayloadType = 96
+	DefaultAudioPayloadType = 111
+	DefaultH264FmtpLine     = "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=4d001f"
+)
+
+// DefaultICEServers are used when BridgeConfig.ICEServers is empty
+var DefaultICEServers = []webrtc.ICEServer{{URLs: []string{"stun:stun.l.google.com:19302"}}}
+
+// BridgeConfig tunes packetization and SDP negotiation for deployments
+// whose path or SFU needs something other than the defaults. Zero fields
+// use the defaults.
+type BridgeConfig struct {
+	MTU              int    // Largest RTP payload sent, in bytes
+	VideoPayloadType uint8  // Dynamic payload type offered for video
+	AudioPayloadType uint8  // Dynamic payload type offered for Opus
+	H264FmtpLine     string // SDP fmtp offered for H.264
+	ICEServers       []webrtc.ICEServer
+
+	// SampleTrack sends video through a pion TrackLocalStaticSample, which
+	// packetizes frames and numbers packets itself, instead of the bridge's
+	// own payloader. RTP passthrough (WriteVideoRTP) needs it off.
+	SampleTrack bool
+
+	// TURN, when set, supplies TURN servers with short-lived credentials,
+	// fetched for every session and ICE restart and used alongside
+	// ICEServers
+	TURN ICEServerProvider
+}
+
+// ICEServerProvider supplies ICE servers whose credentials expire, such as
+// cloudflare.TURNClient
+type ICEServerProvider interface {
+	ICEServers(ctx context.Context) ([]webrtc.ICEServer, error)
+}
+
+// DefaultBridgeConfig returns the configuration a bridge starts with
+func DefaultBridgeConfig() BridgeConfig {
+	return BridgeConfig{
+		MTU:              DefaultMTU,
+		VideoPayloadType: DefaultVideoPayloadType,
+		AudioPayloadType: DefaultAudioPayloadType,
+		H264FmtpLine:     DefaultH264FmtpLine,
+		ICEServers:       DefaultICEServers,
+	}
+}
+
+// withDefaults fills zero fields from DefaultBridgeConfig
+func (c BridgeConfig) withDefaults() BridgeConfig {
+	def := DefaultBridgeConfig()
+	if c.MTU == 0 {
+		c.MTU = def.MTU
+	}
+	if c.VideoPayloadType == 0 {
+		c.VideoPayloadType = def.VideoPayloadType
+	}
+	if c.AudioPayloadType == 0 {
+		c.AudioPayloadType = def.AudioPayloadType
+	}
+	if c.H264FmtpLine == "" {
+		c.H264FmtpLine = def.H264FmtpLine
+	}
+	if len(c.ICEServers) == 0 {
+		c.ICEServers = def.ICEServers
+	}
+	return c
+}
+
+// Validate rejects settings no SFU could negotiate. Zero fields are
+// checked as their defaults.
+func (c BridgeConfig) Validate() error {
+	c = c.withDefaults()
+	if c.MTU < 200 || c.MTU > 1500 {
+		return fmt.Errorf("MTU %d outside 200-1500", c.MTU)
+	}
+	for _, pt := range []uint8{c.VideoPayloadType, c.AudioPayloadType} {
+		if pt < 96 || pt > 127 {
+			return fmt.Errorf("payload type %d outside the dynamic range 96-127", pt)
+		}
+	}
+	if c.VideoPayloadType == c.AudioPayloadType {
+		return fmt.Errorf("video and audio share payload type %d", c.VideoPayloadType)
+	}
+	return nil
+}
+
+// Pacer defaults, tuned for the 15-30 fps Nest cameras send
+const (
+	DefaultPacerQueueSize        = 10 // Small buffer to absorb micro-bursts
+	DefaultPacerCatchupThreshold = 5
+	DefaultPacerCatchupSpeed     = 1.1 // Gradual enough not to jar viewers
+	DefaultPacerMaxDelay         = 200 * time.Millisecond
+)
+
+// PacerConfig tunes a camera's pacer. High frame rate cameras may want
+// deeper queues; low frame rate doorbells a longer MaxDelay, since their
+// frames are further apart than the default allows. Zero fields use the
+// defaults.
+type PacerConfig struct {
+	VideoQueueSize   int // Packets buffered before the RTSP reader blocks
+	AudioQueueSize   int
+	CatchupThreshold int           // Queue depth at which packets are sent faster than real time
+	CatchupSpeed     float64       // Speed-up while catching up, e.g. 1.1
+	MaxDelay         time.Duration // Longest wait before one packet, against timestamp errors
+
+	// LatencyBudget bounds how much video, by timestamp, may queue in the
+	// pacer; past it the queue skips ahead to its newest keyframe. Zero
+	// disables the budget, leaving only catch-up to drain bursts.
+	LatencyBudget time.Duration
+
+	// Backpressure blocks the producer on a full video queue instead of
+	// dropping frames. Only for sources that can wait, like offline replay;
+	// a live RTSP reader would miss its keepalives.
+	Backpressure bool
+
+	// Bypass turns pacing off: each packet is written as soon as it is
+	// enqueued, with the camera's own timing. For A/B testing whether the
+	// pacer helps on a network, and isolating problems it might cause.
+	Bypass bool
+}
+
+// DefaultPacerConfig returns the configuration a pacer starts with
+func DefaultPacerConfig() PacerConfig {
+	return PacerConfig{
+		VideoQueueSize:   DefaultPacerQueueSize,
+		AudioQueueSize:   DefaultPacerQueueSize,
+		CatchupThreshold: DefaultPacerCatchupThreshold,
+		CatchupSpeed:     DefaultPacerCatchupSpeed,
+		MaxDelay:         DefaultPacerMaxDelay,
+	}
+}
+
+// withDefaults fills zero fields from DefaultPacerConfig
+func (c PacerConfig) withDefaults() PacerConfig {
+	def := DefaultPacerConfig()
+	if c.VideoQueueSize == 0 {
+		c.VideoQueueSize = def.VideoQueueSize
+	}
+	if c.AudioQueueSize == 0 {
+		c.AudioQueueSize = def.AudioQueueSize
+	}
+	if c.CatchupThreshold == 0 {
+		c.CatchupThreshold = def.CatchupThreshold
+	}
+	if c.CatchupSpeed == 0 {
+		c.CatchupSpeed = def.CatchupSpeed
+	}
+	if c.MaxDelay == 0 {
+		c.MaxDelay = def.MaxDelay
+	}
+	return c
+}
+
+// Validate rejects settings the pacer can't run with. Zero fields are
+// checked as their defaults.
+func (c PacerConfig) Validate() error {
+	c = c.withDefaults()
+	if c.VideoQueueSize < 1 || c.AudioQueueSize < 1 {
+		return fmt.Errorf("queue sizes must be positive")
+	}
+	if c.CatchupThreshold < 1 || c.CatchupThreshold > min(c.VideoQueueSize, c.AudioQueueSize) {
+		return fmt.Errorf("catch-up threshold %d outside 1 to the queue size", c.CatchupThreshold)
+	}
+	if c.CatchupSpeed < 1 {
+		return fmt.Errorf("catch-up speed %g below 1", c.CatchupSpeed)
+	}
+	if c.MaxDelay < 0 {
+		return fmt.Errorf("negative max delay %v", c.MaxDelay)
+	}
+	if c.LatencyBudget < 0 {
+		return fmt.Errorf("negative latency budget %v", c.LatencyBudget)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package bridge
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestBridgeConfigValidate(t *testing.T) {
+	if err := (BridgeConfig{}).Validate(); err != nil {
+		t.Errorf("defaults rejected: %v", err)
+	}
+	if got := (BridgeConfig{MTU: 1100}).withDefaults(); got.MTU != 1100 || got.VideoPayloadType != DefaultVideoPayloadType || len(got.ICEServers) == 0 {
+		t.Errorf("withDefaults = %+v", got)
+	}
+	for _, cfg := range []BridgeConfig{
+		{MTU: 9000},
+		{VideoPayloadType: 34},
+		{VideoPayloadType: 111},
+	} {
+		if cfg.Validate() == nil {
+			t.Errorf("%+v accepted", cfg)
+		}
+	}
+}
+
+func TestPacerConfigValidate(t *testing.T) {
+	if err := (PacerConfig{}).Validate(); err != nil {
+		t.Errorf("defaults rejected: %v", err)
+	}
+	if got := (PacerConfig{MaxDelay: time.Second}).withDefaults(); got.MaxDelay != time.Second || got.VideoQueueSize != DefaultPacerQueueSize {
+		t.Errorf("withDefaults = %+v", got)
+	}
+	for _, cfg := range []PacerConfig{
+		{VideoQueueSize: -1},
+		{CatchupThreshold: 11},
+		{VideoQueueSize: 20, AudioQueueSize: 4},
+		{CatchupSpeed: 0.5},
+	} {
+		if cfg.Validate() == nil {
+			t.Errorf("%+v accepted", cfg)
+		}
+	}
+
+	p := NewPacer(t.Context(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := p.SetConfig(PacerConfig{VideoQueueSize: 30}); err != nil || cap(p.video.ch) != 30 || cap(p.audio.ch) != DefaultPacerQueueSize {
+		t.Errorf("SetConfig: %v, queues %d/%d", err, cap(p.video.ch), cap(p.audio.ch))
+	}
+}
@@ -0,0 +1,90 @@
+package bridge
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v4"
+)
+
+// HeaderExtension is an RTP header extension the bridge can offer. It only
+// takes effect if the SFU accepts it in its answer.
+type HeaderExtension string
+
+const (
+	// HeaderExtAbsSendTime stamps every packet with its send time, used by
+	// REMB-style receiver-side bandwidth estimation
+	HeaderExtAbsSendTime HeaderExtension = sdp.ABSSendTimeURI
+
+	// HeaderExtTWCC numbers packets transport-wide so the SFU can send
+	// transport-cc feedback
+	HeaderExtTWCC HeaderExtension = sdp.TransportCCURI
+)
+
+// DefaultHeaderExtensions are offered unless SetHeaderExtensions overrides them
+var DefaultHeaderExtensions = []HeaderExtension{HeaderExtAbsSendTime, HeaderExtTWCC}
+
+// configureHeaderExtensions registers exts with the media engine and adds
+// the interceptors that fill them in on outgoing packets
+func configureHeaderExtensions(m *webrtc.MediaEngine, registry *interceptor.Registry, exts []HeaderExtension) error {
+	for _, ext := range exts {
+		switch ext {
+		case HeaderExtAbsSendTime:
+			for _, kind := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeVideo, webrtc.RTPCodecTypeAudio} {
+				if err := m.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: string(ext)}, kind); err != nil {
+					return fmt.Errorf("register abs-send-time: %w", err)
+				}
+			}
+			registry.Add(absSendTimeFactory{})
+
+		case HeaderExtTWCC:
+			if err := webrtc.ConfigureTWCCHeaderExtensionSender(m, registry); err != nil {
+				return fmt.Errorf("register transport-cc: %w", err)
+			}
+
+		default:
+			return fmt.Errorf("unsupported header extension %q", ext)
+		}
+	}
+	return nil
+}
+
+// absSendTimeFactory creates absSendTimeInterceptors
+type absSendTimeFactory struct{}
+
+func (absSendTimeFactory) NewInterceptor(string) (interceptor.Interceptor, error) {
+	return &absSendTimeInterceptor{}, nil
+}
+
+// absSendTimeInterceptor sets the abs-send-time extension on local streams
+// that negotiated it
+type absSendTimeInterceptor struct {
+	interceptor.NoOp
+}
+
+func (i *absSendTimeInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	var id uint8
+	for _, ext := range info.RTPHeaderExtensions {
+		if ext.URI == sdp.ABSSendTimeURI {
+			id = uint8(ext.ID)
+			break
+		}
+	}
+	if id == 0 {
+		return writer
+	}
+
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		ext, err := rtp.NewAbsSendTimeExtension(time.Now()).Marshal()
+		if err != nil {
+			return 0, err
+		}
+		if err := header.SetExtension(id, ext); err != nil {
+			return 0, err
+		}
+		return writer.Write(header, payload, attributes)
+	})
+}
@@ -0,0 +1,40 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+)
+
+func TestAbsSendTimeInterceptor(t *testing.T) {
+	var written rtp.Header
+	sink := interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, _ interceptor.Attributes) (int, error) {
+		written = *header
+		return len(payload), nil
+	})
+
+	i := &absSendTimeInterceptor{}
+
+	// Not negotiated: packets pass through untouched
+	w := i.BindLocalStream(&interceptor.StreamInfo{}, sink)
+	w.Write(&rtp.Header{}, nil, nil)
+	if written.Extension {
+		t.Fatal("extension set without negotiation")
+	}
+
+	w = i.BindLocalStream(&interceptor.StreamInfo{
+		RTPHeaderExtensions: []interceptor.RTPHeaderExtension{{URI: sdp.TransportCCURI, ID: 3}, {URI: sdp.ABSSendTimeURI, ID: 5}},
+	}, sink)
+	w.Write(&rtp.Header{}, nil, nil)
+
+	payload := written.GetExtension(5)
+	if len(payload) != 3 {
+		t.Fatalf("abs-send-time payload = %x, want 3 bytes at id 5", payload)
+	}
+	var ext rtp.AbsSendTimeExtension
+	if err := ext.Unmarshal(payload); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,72 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pion/webrtc/v4"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/sfu"
+)
+
+// RestartICE renegotiates the session with fresh ICE credentials, keeping
+// the SFU session, tracks and SSRCs. It recovers a connection that went
+// disconnected or failed after a network change in the time one
+// renegotiation takes, where recreating the relay takes a new session and
+// viewers resubscribing. It returns sfu.ErrNotSupported when the backend
+// cannot renegotiate.
+func (b *Bridge) RestartICE(ctx context.Context) error {
+	if b.pc == nil {
+		return fmt.Errorf("peer connection not created")
+	}
+
+	// Credentials fetched for the session may have expired since
+	if b.config.TURN != nil {
+		pcConfig := b.pc.GetConfiguration()
+		pcConfig.ICEServers = b.iceServers(ctx)
+		if err := b.pc.SetConfiguration(pcConfig); err != nil {
+			return fmt.Errorf("update ICE servers: %w", err)
+		}
+	}
+
+	offer, err := b.pc.CreateOffer(&webrtc.OfferOptions{ICERestart: true})
+	if err != nil {
+		return fmt.Errorf("create ICE restart offer: %w", err)
+	}
+	if err := b.pc.SetLocalDescription(offer); err != nil {
+		return fmt.Errorf("set local description: %w", err)
+	}
+	if err := b.gatherCandidates(ctx); err != nil {
+		return err
+	}
+
+	remote, err := b.backend.Renegotiate(ctx, b.sessionID, sfu.Description{
+		Type: "offer",
+		SDP:  b.pc.LocalDescription().SDP,
+	})
+	if err != nil {
+		return fmt.Errorf("renegotiate: %w", err)
+	}
+	if remote.SDP == "" {
+		return fmt.Errorf("SFU returned no answer to the ICE restart offer")
+	}
+
+	b.tracksMu.RLock()
+	tracks := b.tracks
+	b.tracksMu.RUnlock()
+	if err := validateAnswer(remote.SDP, tracks, b.videoCodec); err != nil {
+		return fmt.Errorf("validate SDP answer: %w", err)
+	}
+	if err := b.pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: remote.SDP}); err != nil {
+		return fmt.Errorf("set remote description: %w", err)
+	}
+
+	b.iceRestarts.Add(1)
+	b.logger.Info("ICE restart negotiated", "session_id", b.sessionID)
+	return nil
+}
+
+// ICERestarts returns how many ICE restarts were negotiated
+func (b *Bridge) ICERestarts() uint64 {
+	return b.iceRestarts.Load()
+}
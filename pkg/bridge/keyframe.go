@@ -0,0 +1,108 @@
+package bridge
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/membudget"
+)
+
+const (
+	// keyframeReplayInterval limits replays, since every viewer of the
+	// track receives them, not only the one that asked
+	keyframeReplayInterval = time.Second
+
+	// maxKeyframeAge is the oldest cached keyframe worth replaying. Frames
+	// after an older one reference too much the joining viewer never saw.
+	maxKeyframeAge = 10 * time.Second
+)
+
+// keyframeCache holds the last keyframe written to the video track. When a
+// viewer joins and sends a PLI, the keyframe is replayed ahead of the next
+// frame so the viewer starts decoding at once instead of waiting up to the
+// camera's keyframe interval.
+type keyframeCache struct {
+	mu       sync.Mutex
+	memory   *membudget.Account // Charged for the cached frame
+	frame    []byte             // AVC form, parameter sets included
+	at       time.Time          // When frame was cached
+	wanted   bool               // A keyframe was requested since the last one
+	replayed time.Time
+	replays  uint64
+}
+
+// store caches a keyframe in place of the previous one. One that doesn't
+// fit the memory budget leaves nothing cached.
+func (c *keyframeCache) store(au []byte, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.wanted = false // Viewers get this one anyway
+	c.memory.Release(len(c.frame))
+	if !c.memory.Reserve(len(au)) {
+		c.frame = nil
+		return
+	}
+	c.frame = append(c.frame[:0], au...)
+	c.at = now
+}
+
+// request notes a viewer asking for a keyframe
+func (c *keyframeCache) request() {
+	c.mu.Lock()
+	c.wanted = true
+	c.mu.Unlock()
+}
+
+// take returns the keyframe to replay ahead of the next frame, if one was
+// requested and the cached one is fresh enough. The slice is only valid
+// until the next store.
+func (c *keyframeCache) take(now time.Time) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.wanted || c.frame == nil || now.Sub(c.at) > maxKeyframeAge || now.Sub(c.replayed) < keyframeReplayInterval {
+		return nil
+	}
+	c.wanted = false
+	c.replayed = now
+	c.replays++
+	return c.frame
+}
+
+// count returns how many keyframes were replayed
+func (c *keyframeCache) count() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.replays
+}
+
+// clear drops the cached frame and its memory charge
+func (c *keyframeCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.memory.Release(len(c.frame))
+	c.frame = nil
+}
+
+// isKeyframe reports whether an AVC-form access unit holds an H.264 IDR or
+// H.265 IRAP picture
+func isKeyframe(codec string, au []byte) bool {
+	for len(au) > 4 {
+		size := int(binary.BigEndian.Uint32(au))
+		au = au[4:]
+		if size == 0 || size > len(au) {
+			return false
+		}
+		if codec == "H265" {
+			if t := (au[0] >> 1) & 0x3F; t >= 16 && t <= 23 {
+				return true
+			}
+		} else if au[0]&0x1F == 5 {
+			return true
+		}
+		au = au[size:]
+	}
+	return false
+}
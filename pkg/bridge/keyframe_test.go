@@ -0,0 +1,39 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyframeCache(t *testing.T) {
+	idr := []byte{0, 0, 0, 2, 0x67, 1, 0, 0, 0, 2, 0x65, 2}
+	if !isKeyframe("H264", idr) || isKeyframe("H264", []byte{0, 0, 0, 2, 0x41, 1}) {
+		t.Error("H.264 keyframe detection")
+	}
+	if !isKeyframe("H265", []byte{0, 0, 0, 3, 19 << 1, 1, 0}) || isKeyframe("H265", idr) {
+		t.Error("H.265 keyframe detection")
+	}
+
+	var c keyframeCache
+	now := time.Now()
+	c.request()
+	if c.take(now) != nil {
+		t.Error("replayed with nothing cached")
+	}
+
+	c.store(idr, now)
+	if c.take(now) != nil {
+		t.Error("replayed without a request since the keyframe")
+	}
+	c.request()
+	if got := c.take(now.Add(time.Second)); string(got) != string(idr) {
+		t.Errorf("replayed %x", got)
+	}
+	c.request()
+	if c.take(now.Add(1500*time.Millisecond)) != nil {
+		t.Error("replays not throttled")
+	}
+	if c.take(now.Add(maxKeyframeAge+time.Second)) != nil || c.count() != 1 {
+		t.Errorf("stale keyframe replayed, %d replays", c.count())
+	}
+}
@@ -0,0 +1,91 @@
+package bridge
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencyWindow is how many recent frames percentiles are computed over,
+// about 20 seconds of 30fps video
+const latencyWindow = 600
+
+// LatencyPercentiles summarizes one latency stage over the recent window
+type LatencyPercentiles struct {
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// LatencyStats breaks a frame's time inside the relay into stages. Arrival is
+// when the last RTP packet of the frame was read from the camera.
+type LatencyStats struct {
+	Frames  uint64             // Frames measured since the bridge started
+	Process LatencyPercentiles // Arrival → pacer enqueue (processors, recorders)
+	Queue   LatencyPercentiles // Enqueue → pacer exit (queueing and pacing delay)
+	Send    LatencyPercentiles // Pacer exit → last RTP packet written to the track
+	Total   LatencyPercentiles // Arrival → last RTP packet written
+}
+
+// latencyTracker keeps the most recent per-stage latencies of a track
+type latencyTracker struct {
+	mu      sync.Mutex
+	process [latencyWindow]time.Duration
+	queue   [latencyWindow]time.Duration
+	send    [latencyWindow]time.Duration
+	total   [latencyWindow]time.Duration
+	frames  uint64
+}
+
+// record adds one frame's timeline
+func (t *latencyTracker) record(arrived, enqueued, dequeued, sent time.Time) {
+	if arrived.IsZero() {
+		arrived = enqueued
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	i := t.frames % latencyWindow
+	t.process[i] = enqueued.Sub(arrived)
+	t.queue[i] = dequeued.Sub(enqueued)
+	t.send[i] = sent.Sub(dequeued)
+	t.total[i] = sent.Sub(arrived)
+	t.frames++
+}
+
+// snapshot computes percentiles over the recorded window
+func (t *latencyTracker) snapshot() LatencyStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := int(min(t.frames, latencyWindow))
+	return LatencyStats{
+		Frames:  t.frames,
+		Process: percentiles(t.process[:n]),
+		Queue:   percentiles(t.queue[:n]),
+		Send:    percentiles(t.send[:n]),
+		Total:   percentiles(t.total[:n]),
+	}
+}
+
+// percentiles uses the nearest-rank method on a sorted copy of samples
+func percentiles(samples []time.Duration) LatencyPercentiles {
+	if len(samples) == 0 {
+		return LatencyPercentiles{}
+	}
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+
+	rank := func(p float64) time.Duration {
+		i := int(p*float64(len(sorted))+0.999999) - 1
+		return sorted[max(0, min(i, len(sorted)-1))]
+	}
+	return LatencyPercentiles{
+		P50: rank(0.50),
+		P95: rank(0.95),
+		P99: rank(0.99),
+		Max: sorted[len(sorted)-1],
+	}
+}
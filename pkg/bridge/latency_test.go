@@ -0,0 +1,43 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyTracker(t *testing.T) {
+	var lt latencyTracker
+	base := time.Now()
+
+	// Frames 1..100ms of queueing, 1ms processing and 2ms sending each
+	for i := 1; i <= 100; i++ {
+		arrived := base.Add(time.Duration(i) * time.Second)
+		enqueued := arrived.Add(time.Millisecond)
+		dequeued := enqueued.Add(time.Duration(i) * time.Millisecond)
+		lt.record(arrived, enqueued, dequeued, dequeued.Add(2*time.Millisecond))
+	}
+
+	got := lt.snapshot()
+	if got.Frames != 100 {
+		t.Errorf("frames = %d, want 100", got.Frames)
+	}
+	if got.Queue.P50 != 50*time.Millisecond || got.Queue.P95 != 95*time.Millisecond ||
+		got.Queue.P99 != 99*time.Millisecond || got.Queue.Max != 100*time.Millisecond {
+		t.Errorf("queue = %+v", got.Queue)
+	}
+	if got.Process.P99 != time.Millisecond || got.Send.Max != 2*time.Millisecond {
+		t.Errorf("process = %+v, send = %+v", got.Process, got.Send)
+	}
+	if got.Total.P50 != 53*time.Millisecond {
+		t.Errorf("total p50 = %v, want 53ms", got.Total.P50)
+	}
+
+	// Old samples fall out of the window
+	for i := 0; i < latencyWindow; i++ {
+		now := base.Add(time.Hour)
+		lt.record(now, now, now, now)
+	}
+	if got := lt.snapshot(); got.Total.Max != 0 {
+		t.Errorf("after window rollover max = %v, want 0", got.Total.Max)
+	}
+}
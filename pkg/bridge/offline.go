@@ -0,0 +1,76 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+)
+
+// RTPSink receives the packets an offline bridge would have sent to the SFU
+type RTPSink interface {
+	WriteRTP(packet *rtp.Packet) error
+}
+
+// NewOfflineBridge creates a bridge with no SFU or PeerConnection: frames go
+// through the same pacer, packetizer and track writers as a live bridge, and
+// the resulting RTP is written to video and audio. It starts out connected,
+// so the pacer runs immediately. Used to replay captures deterministically.
+func NewOfflineBridge(ctx context.Context, cameraID string, video, audio RTPSink, logger *slog.Logger) (*Bridge, error) {
+	b, err := NewBridge(ctx, cameraID, nil, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// The tracks are never bound; they only satisfy the write paths' checks
+	b.videoTrack, err = webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000}, b.videoName, "nest-camera-video")
+	if err != nil {
+		return nil, fmt.Errorf("create video track: %w", err)
+	}
+	b.audioTrack, err = webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2}, b.audioName, "nest-camera-audio")
+	if err != nil {
+		return nil, fmt.Errorf("create audio track: %w", err)
+	}
+	b.videoWriter = newTrackWriter(video, "video", b.writeTimeout, b.logger)
+	b.audioWriter = newTrackWriter(audio, "audio", b.writeTimeout, b.logger)
+
+	goroutines.Go("bridge.writer.video", b.cameraID, func() { b.videoWriter.run(b.ctx, b.panicked) })
+	goroutines.Go("bridge.writer.audio", b.cameraID, func() { b.audioWriter.run(b.ctx, b.panicked) })
+
+	b.cachedConnState = webrtc.PeerConnectionStateConnected
+	b.connectedOnce.Do(func() { close(b.connectedChan) })
+	// Replay can wait for the pacer, so no frame is dropped
+	if err := b.pacer.SetConfig(PacerConfig{Backpressure: true}); err != nil {
+		return nil, err
+	}
+	b.pacer.SetWriteCallbacks(b.writeVideoSampleDirect, b.writeAudioSampleDirect)
+	b.pacer.Start()
+
+	return b, nil
+}
+
+// Flush blocks until every queued frame has been paced and written to the
+// tracks, or ctx is done
+func (b *Bridge) Flush(ctx context.Context) error {
+	if err := b.pacer.Flush(ctx); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for _, w := range []*trackWriter{b.videoWriter, b.audioWriter} {
+		for w != nil && w.pending.Load() > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+	}
+	return nil
+}
@@ -2,11 +2,15 @@ package bridge
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+	"github.com/ethan/nest-cloudflare-relay/pkg/recovery"
 	"github.com/pion/rtp"
 )
 
@@ -17,40 +21,69 @@ const (
 	// Audio RTP clock rate (Opus standard)
 	audioClockRate = 48000 // 48kHz
 
-	// Catch-up speed multiplier when draining accumulated packets
-	// 1.1x speed allows gradual catch-up without jarring viewer
-	catchupSpeedMultiplier = 1.1
+	// Longest Stop waits for queued packets to be sent before dropping them
+	drainTimeout = time.Second
 
-	// Threshold for entering catch-up mode (number of queued packets)
-	catchupThreshold = 5
+	// How often each pacer logs its statistics
+	pacerStatsInterval = 30 * time.Second
 
-	// Maximum delay to wait before sending a packet
-	// Prevents infinite delays on timestamp errors
-	maxPacketDelay = 200 * time.Millisecond
+	// Longest a keyframe waits for room in a full video queue before it is
+	// dropped, with the rest of its GOP
+	keyframeEnqueueWait = 100 * time.Millisecond
 )
 
+// ErrPacerStopped is returned for packets enqueued once Stop was called
+var ErrPacerStopped = errors.New("pacer stopped")
+
 // PacedPacket wraps an RTP packet with metadata for pacing
 type PacedPacket struct {
 	Packet       *rtp.Packet
 	Timestamp    uint32 // RTP timestamp (not wall clock)
 	IsKeyframe   bool
+	Reference    bool // Video: later frames may be predicted from it
 	NALUs        []byte // For video: pre-packetized H.264 data
 	TrackType    string // "video" or "audio"
 	ReceivedAt   time.Time
+	ArrivedAt    time.Time // When the frame was read from the camera, for latency stats
 	SourceSeqNum uint16 // Original sequence number from source (for diagnostics)
+	Release      func() // Called once the pacer is done with the packet, sent or not
+	Dropped      bool   // Set before Release when the packet was never sent
+
+	seq uint64 // Video: enqueue order, for latency budget fast-forwards
+}
+
+// release runs the packet's Release hook, if any
+func (pkt *PacedPacket) release() {
+	if pkt.Release != nil {
+		pkt.Release()
+	}
+}
+
+// drop releases a packet that won't be sent
+func (pkt *PacedPacket) drop() {
+	pkt.Dropped = true
+	pkt.release()
 }
 
 // Pacer implements a leaky bucket algorithm to smooth RTP packet transmission
-// Absorbs TCP bursts and drains at nominal frame rate based on RTP timestamps
+// Absorbs TCP bursts and drains at nominal frame rate based on RTP timestamps.
+// Each track sends from a goroutine of its own, woken by the process-wide
+// pacerWheel when its next packet is due, so a write blocked on a full track
+// holds up only this camera.
 type Pacer struct {
 	logger       *slog.Logger
+	cameraID     string // For goroutine accounting
 	ctx          context.Context
 	cancel       context.CancelFunc
-	wg           sync.WaitGroup
+	config       PacerConfig // Queue sizes, catch-up and delay cap
+	started      atomic.Bool
+	stopping     chan struct{} // Closed by Stop: send what is queued, then exit
+	stopOnce     sync.Once
+	wg           sync.WaitGroup // The tracks' goroutines
 
-	// Channels for packet ingress
-	videoChan chan *PacedPacket
-	audioChan chan *PacedPacket
+	// Packet queues, each stepped by the wheel
+	video *pacedTrack
+	audio *pacedTrack
 
 	// Write callbacks (set by Bridge)
 	// Protected by callbackMu for memory visibility
@@ -76,23 +109,78 @@ type Pacer struct {
 	totalVideoDelay      time.Duration
 	totalAudioDelay      time.Duration
 
+	// Drop policy when the video queue is full (see EnqueueVideo)
+	videoGap              bool // A reference frame was dropped; drop until the next keyframe
+	videoDroppedFrames    uint64
+	videoDroppedKeyframes uint64
+	videoDroppedGOP       uint64
+
+	// Latency budget (see fastForward); producer side under statsMu
+	videoSeq          uint64        // Last video sequence assigned
+	videoKeyframeSeq  uint64        // Sequence of the newest keyframe enqueued
+	videoHead         atomic.Int64  // RTP timestamp of the oldest unsent video packet; -1 until one is enqueued
+	videoSkipTo       atomic.Uint64 // Queued video before this sequence is skipped
+	videoJumped       bool          // Frames were skipped; send the next at once. Used only by steps.
+	videoFastForwards uint64
+	videoSkipped      uint64
+
+	// Per-frame video latency through the relay
+	videoLatency latencyTracker
+
+	// Packets enqueued but not yet paced, for Flush
+	pending atomic.Int64
+
 	// Mutex for stats
 	statsMu sync.RWMutex
+
+	// OnPanic receives panics recovered while pacing; the track that hit it
+	// stops. Set before Start.
+	OnPanic func(err *recovery.PanicError)
 }
 
 // NewPacer creates a new RTP packet pacer
 func NewPacer(ctx context.Context, logger *slog.Logger) *Pacer {
 	ctx, cancel := context.WithCancel(ctx)
+	config := DefaultPacerConfig()
 
-	return &Pacer{
+	p := &Pacer{
 		logger:           logger.With("component", "pacer"),
 		ctx:              ctx,
 		cancel:           cancel,
-		videoChan:        make(chan *PacedPacket, 10), // Small buffer to absorb micro-bursts
-		audioChan:        make(chan *PacedPacket, 10),
+		config:           config,
+		stopping:         make(chan struct{}),
 		firstVideoPacket: true,
 		firstAudioPacket: true,
 	}
+	p.videoHead.Store(-1)
+	p.video = &pacedTrack{
+		name:  "video",
+		ch:    make(chan *PacedPacket, config.VideoQueueSize),
+		wake:  make(chan struct{}, 1),
+		admit: p.admitVideo,
+		delay: p.videoDelay,
+		send:  p.sendVideoPacket,
+	}
+	p.audio = &pacedTrack{
+		name:  "audio",
+		ch:    make(chan *PacedPacket, config.AudioQueueSize),
+		wake:  make(chan struct{}, 1),
+		delay: p.audioDelay,
+		send:  p.sendAudioPacket,
+	}
+	return p
+}
+
+// SetConfig replaces the pacer's tuning. The queues are reallocated, so it
+// MUST be called before Start and before anything is enqueued.
+func (p *Pacer) SetConfig(cfg PacerConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid pacer config: %w", err)
+	}
+	p.config = cfg.withDefaults()
+	p.video.ch = make(chan *PacedPacket, p.config.VideoQueueSize)
+	p.audio.ch = make(chan *PacedPacket, p.config.AudioQueueSize)
+	return nil
 }
 
 // SetWriteCallbacks configures the output functions for paced packets
@@ -107,72 +195,194 @@ func (p *Pacer) SetWriteCallbacks(
 	p.writeAudio = writeAudio
 }
 
-// Start begins the pacer goroutines
+// Start begins pacing queued packets
 func (p *Pacer) Start() {
-	p.logger.Info("starting pacer goroutines")
-
-	// Video pacer goroutine
-	p.wg.Add(1)
-	go func() {
-		defer p.wg.Done()
-		p.videoPacerLoop()
-	}()
-
-	// Audio pacer goroutine
-	p.wg.Add(1)
-	go func() {
-		defer p.wg.Done()
-		p.audioPacerLoop()
-	}()
-
-	// Stats logging goroutine
-	p.wg.Add(1)
-	go func() {
-		defer p.wg.Done()
-		p.statsLoop()
-	}()
-}
-
-// Stop gracefully stops the pacer
+	p.logger.Info("starting pacer", "bypass", p.config.Bypass)
+	if !p.config.Bypass {
+		for _, t := range []*pacedTrack{p.video, p.audio} {
+			p.wg.Add(1)
+			goroutines.Go("pacer."+t.name, p.cameraID, func() {
+				defer p.wg.Done()
+				p.run(t)
+			})
+		}
+	}
+	p.started.Store(true)
+	p.kick(p.video)
+	p.kick(p.audio)
+	p.scheduleStats()
+}
+
+// Flush blocks until every enqueued packet has been paced, or ctx is done
+func (p *Pacer) Flush(ctx context.Context) error {
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for p.pending.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Stop gracefully stops the pacer. Packets already queued are sent without
+// pacing delays, so viewers aren't left with a torn GOP; whatever hasn't gone
+// out after drainTimeout is dropped. No packet is written once Stop returns.
 func (p *Pacer) Stop() {
-	p.logger.Info("stopping pacer")
+	p.logger.Info("stopping pacer", "video_queued", len(p.video.ch), "audio_queued", len(p.audio.ch))
+	p.stopOnce.Do(func() { close(p.stopping) })
+
+	if p.started.Load() {
+		ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+		if err := p.Flush(ctx); err != nil {
+			p.logger.Warn("pacer drain timed out, dropping queued packets",
+				"video_queued", len(p.video.ch),
+				"audio_queued", len(p.audio.ch))
+		}
+		cancel()
+	}
 	p.cancel()
+
+	// The tracks' goroutines see the cancelled context after their current
+	// write
 	p.wg.Wait()
+
+	// Release packets that were queued or held but never paced
+	for _, t := range []*pacedTrack{p.video, p.audio} {
+		t.mu.Lock()
+		held := t.held
+		t.held = nil
+		t.mu.Unlock()
+		if held != nil {
+			held.drop()
+			p.pending.Add(-1)
+		}
+		for len(t.ch) > 0 {
+			(<-t.ch).drop()
+			p.pending.Add(-1)
+		}
+	}
 }
 
-// EnqueueVideo queues a video packet for paced transmission
+// EnqueueVideo queues a video packet for paced transmission. A full queue
+// means the track can't keep up, and blocking would stall the RTSP reader and
+// its keepalives, so frames are dropped instead: non-keyframes at once, and
+// keyframes after waiting keyframeEnqueueWait for room. Once a reference
+// frame is dropped, the rest of its GOP can't decode and is dropped too.
+// PacerConfig.Backpressure blocks instead.
 func (p *Pacer) EnqueueVideo(packet *PacedPacket) error {
+	if err := p.ctx.Err(); err != nil {
+		return err // Stopped; nothing would drain the channel
+	}
+	if p.isStopping() {
+		return ErrPacerStopped
+	}
+	if p.config.Bypass {
+		return p.bypass(p.video, packet)
+	}
+
+	p.statsMu.Lock()
+	if packet.IsKeyframe {
+		p.videoGap = false
+	} else if p.videoGap {
+		p.videoDroppedGOP++
+		p.statsMu.Unlock()
+		packet.drop()
+		return nil
+	}
+	p.fastForward(packet)
+	p.statsMu.Unlock()
+
+	p.pending.Add(1)
 	select {
-	case p.videoChan <- packet:
+	case p.video.ch <- packet:
+		p.kick(p.video)
 		return nil
 	case <-p.ctx.Done():
+		p.pending.Add(-1)
 		return p.ctx.Err()
 	default:
-		// Channel full - log burst absorption
-		p.statsMu.Lock()
-		p.videoBurstsAbsorbed++
-		p.statsMu.Unlock()
+	}
 
-		p.logger.Warn("video channel full - burst detected, blocking until space available",
-			"queue_depth", len(p.videoChan),
-			"bursts_absorbed", p.videoBurstsAbsorbed)
+	p.statsMu.Lock()
+	p.videoBurstsAbsorbed++
+	p.statsMu.Unlock()
 
-		// Block until space available (backpressure to RTSP reader)
+	if p.config.Backpressure {
 		select {
-		case p.videoChan <- packet:
+		case p.video.ch <- packet:
+			p.kick(p.video)
 			return nil
 		case <-p.ctx.Done():
+			p.pending.Add(-1)
 			return p.ctx.Err()
+		case <-p.stopping:
+			p.pending.Add(-1)
+			return ErrPacerStopped
 		}
 	}
+
+	if packet.IsKeyframe {
+		timer := time.NewTimer(keyframeEnqueueWait)
+		defer timer.Stop()
+		select {
+		case p.video.ch <- packet:
+			p.kick(p.video)
+			return nil
+		case <-p.ctx.Done():
+			p.pending.Add(-1)
+			return p.ctx.Err()
+		case <-p.stopping:
+			p.pending.Add(-1)
+			return ErrPacerStopped
+		case <-timer.C:
+		}
+	}
+
+	p.pending.Add(-1)
+	p.statsMu.Lock()
+	if packet.IsKeyframe {
+		p.videoDroppedKeyframes++
+	} else {
+		p.videoDroppedFrames++
+	}
+	if packet.IsKeyframe || packet.Reference {
+		p.videoGap = true
+	}
+	dropped := p.videoDroppedFrames + p.videoDroppedKeyframes
+	p.statsMu.Unlock()
+
+	if dropped == 1 || dropped%100 == 0 {
+		p.logger.Warn("video queue full, dropping frame",
+			"keyframe", packet.IsKeyframe,
+			"reference", packet.Reference,
+			"queue_depth", len(p.video.ch),
+			"dropped", dropped)
+	}
+	packet.drop()
+	return nil
 }
 
 // EnqueueAudio queues an audio packet for paced transmission
 func (p *Pacer) EnqueueAudio(packet *PacedPacket) error {
+	if err := p.ctx.Err(); err != nil {
+		return err // Stopped; nothing would drain the channel
+	}
+	if p.isStopping() {
+		return ErrPacerStopped
+	}
+	if p.config.Bypass {
+		return p.bypass(p.audio, packet)
+	}
+	p.pending.Add(1)
 	select {
-	case p.audioChan <- packet:
+	case p.audio.ch <- packet:
+		p.kick(p.audio)
 		return nil
 	case <-p.ctx.Done():
+		p.pending.Add(-1)
 		return p.ctx.Err()
 	default:
 		// Channel full - log burst absorption
@@ -181,74 +391,239 @@ func (p *Pacer) EnqueueAudio(packet *PacedPacket) error {
 		p.statsMu.Unlock()
 
 		p.logger.Warn("audio channel full - burst detected, blocking until space available",
-			"queue_depth", len(p.audioChan),
+			"queue_depth", len(p.audio.ch),
 			"bursts_absorbed", p.audioBurstsAbsorbed)
 
 		// Block until space available
 		select {
-		case p.audioChan <- packet:
+		case p.audio.ch <- packet:
+			p.kick(p.audio)
 			return nil
 		case <-p.ctx.Done():
+			p.pending.Add(-1)
 			return p.ctx.Err()
+		case <-p.stopping:
+			p.pending.Add(-1)
+			return ErrPacerStopped
 		}
 	}
 }
 
-// videoPacerLoop is the main video pacing goroutine
-// Implements the leaky bucket algorithm from Section 8.2
-func (p *Pacer) videoPacerLoop() {
-	p.logger.Info("[pacer:video] started")
+// pacedTrack is one track's queue and its place on the pacer wheel. Its
+// steps run one at a time on its goroutine, so packets go out in order.
+type pacedTrack struct {
+	name  string // "video" or "audio"
+	ch    chan *PacedPacket
+	wake  chan struct{} // Signals the track's goroutine to step
+	admit func(*PacedPacket) bool // Whether a dequeued packet is still to be sent; nil sends all
+	delay func(*PacedPacket) time.Duration
+	send  func(*PacedPacket, time.Duration) error
+
+	mu        sync.Mutex
+	scheduled bool         // A step is on the wheel, signalled or running
+	held      *PacedPacket // Dequeued, waiting on the wheel for its send time
+	heldDelay time.Duration
+}
+
+// signal wakes the track's goroutine. It never blocks, so the wheel's
+// workers stay free for every other camera.
+func (t *pacedTrack) signal() {
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
 
+// run steps a track each time it is signalled, until the pacer stops
+func (p *Pacer) run(t *pacedTrack) {
 	for {
 		select {
 		case <-p.ctx.Done():
-			p.logger.Info("[pacer:video] stopped (context cancelled)")
 			return
+		case <-t.wake:
+			p.step(t)
+		}
+	}
+}
+
+// kick wakes an idle track with queued packets. Called after every enqueue,
+// and by Start.
+func (p *Pacer) kick(t *pacedTrack) {
+	if !p.started.Load() || p.ctx.Err() != nil {
+		return
+	}
+	t.mu.Lock()
+	if t.scheduled {
+		t.mu.Unlock()
+		return
+	}
+	t.scheduled = true
+	t.mu.Unlock()
+	t.signal()
+}
 
-		case packet := <-p.videoChan:
-			if err := p.paceVideoPacket(packet); err != nil {
-				p.logger.Error("[pacer:video] failed to pace packet",
-					"timestamp", packet.Timestamp,
-					"keyframe", packet.IsKeyframe,
-					"error", err)
+// step implements the leaky bucket from Section 8.2: it sends the track's
+// held packet, then every queued packet that is already due, and files the
+// track on the wheel for the first one that isn't. An empty queue leaves the
+// track idle until the next kick.
+func (p *Pacer) step(t *pacedTrack) {
+	t.mu.Lock()
+	packet, delay := t.held, t.heldDelay
+	t.held = nil
+	t.mu.Unlock()
+	defer recovery.Recover("pacer."+t.name, p.OnPanic)
+
+	for {
+		if packet != nil {
+			if p.ctx.Err() != nil {
+				packet.drop()
+			} else {
+				if err := t.send(packet, delay); err != nil {
+					p.logger.Error("[pacer:"+t.name+"] failed to pace packet",
+						"timestamp", packet.Timestamp,
+						"keyframe", packet.IsKeyframe,
+						"error", err)
+				}
+				packet.release()
+			}
+			p.pending.Add(-1)
+			packet = nil
+		}
+		if p.ctx.Err() != nil {
+			t.mu.Lock()
+			t.scheduled = false
+			t.mu.Unlock()
+			return
+		}
+
+		select {
+		case packet = <-t.ch:
+			if t.admit != nil && !t.admit(packet) {
+				packet.drop()
+				p.pending.Add(-1)
+				packet = nil
+				continue
+			}
+			// CRITICAL: wait out the delay to pace the packet transmission.
+			// This smooths out TCP bursts by restoring timing based on RTP
+			// timestamps.
+			if delay = t.delay(packet); delay > 0 {
+				t.mu.Lock()
+				t.held, t.heldDelay = packet, delay
+				t.mu.Unlock()
+				pacerWheel.schedule(delay, t.signal)
+				return
 			}
+		default:
+			t.mu.Lock()
+			t.scheduled = false
+			t.mu.Unlock()
+			// An enqueue that saw the step still scheduled didn't kick
+			if len(t.ch) > 0 {
+				p.kick(t)
+			}
+			return
 		}
 	}
 }
 
-// paceVideoPacket implements the core pacing logic for a single video packet
-func (p *Pacer) paceVideoPacket(packet *PacedPacket) error {
-	now := time.Now()
-
-	// First packet - send immediately to establish timeline
-	if p.firstVideoPacket {
-		p.firstVideoPacket = false
-		p.lastVideoTS = packet.Timestamp
-		p.lastVideoSendAt = now
+// bypass writes a packet on the caller's goroutine, skipping the queue and
+// its delays (PacerConfig.Bypass). Until Start the track isn't connected, so
+// packets are dropped. It holds the track's lock while writing, so Stop
+// waits for the write and none follows it.
+func (p *Pacer) bypass(t *pacedTrack, packet *PacedPacket) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := p.ctx.Err(); err != nil {
+		return err
+	}
+	if p.isStopping() {
+		return ErrPacerStopped
+	}
+	if !p.started.Load() {
+		packet.drop()
+		return nil
+	}
 
-		p.logger.Info("[pacer:video] first packet - establishing timeline",
+	if err := t.send(packet, 0); err != nil {
+		p.logger.Error("[pacer:"+t.name+"] failed to write packet",
 			"timestamp", packet.Timestamp,
-			"keyframe", packet.IsKeyframe)
+			"keyframe", packet.IsKeyframe,
+			"error", err)
+	}
+	packet.release()
+	return nil
+}
 
-		// Get callback with proper synchronization
-		p.callbackMu.RLock()
-		writeVideoFn := p.writeVideo
-		p.callbackMu.RUnlock()
+// isStopping reports whether Stop has been called
+func (p *Pacer) isStopping() bool {
+	select {
+	case <-p.stopping:
+		return true
+	default:
+		return false
+	}
+}
 
-		// Check for nil callback (should never happen, but defensive)
-		if writeVideoFn == nil {
-			return fmt.Errorf("writeVideo callback not set")
-		}
+// fastForward assigns a video packet its sequence and, when the queued video
+// spans more than LatencyBudget, skips the queue ahead to the newest queued
+// keyframe: the frames before it are dropped as they are dequeued, so
+// glass-to-glass latency stays bounded after a large TCP burst. Without a
+// queued keyframe it waits for the next one. Called with statsMu held.
+func (p *Pacer) fastForward(packet *PacedPacket) {
+	p.videoSeq++
+	packet.seq = p.videoSeq
+	if packet.IsKeyframe {
+		p.videoKeyframeSeq = packet.seq
+	}
+	head := p.videoHead.Load()
+	if head < 0 {
+		p.videoHead.Store(int64(packet.Timestamp))
+		return
+	}
 
-		if err := writeVideoFn(packet.NALUs, packet.Timestamp); err != nil {
-			return fmt.Errorf("write first video packet: %w", err)
-		}
+	budget := p.config.LatencyBudget
+	if budget <= 0 || p.videoKeyframeSeq <= p.videoSkipTo.Load() {
+		return
+	}
+	queued := time.Duration(int32(packet.Timestamp-uint32(head))) * time.Second / videoClockRate
+	if queued <= budget {
+		return
+	}
+	p.videoSkipTo.Store(p.videoKeyframeSeq)
+	p.videoFastForwards++
+	p.logger.Warn("[pacer:video] queued video over latency budget, skipping to the newest keyframe",
+		"queued_ms", queued.Milliseconds(),
+		"budget_ms", budget.Milliseconds(),
+		"queue_depth", len(p.video.ch),
+		"fast_forwards", p.videoFastForwards)
+}
 
+// admitVideo drops dequeued frames a fast-forward skipped past, and tracks
+// the oldest unsent timestamp for the next latency check
+func (p *Pacer) admitVideo(packet *PacedPacket) bool {
+	if packet.seq < p.videoSkipTo.Load() {
 		p.statsMu.Lock()
-		p.videoPacketsSent++
+		p.videoSkipped++
 		p.statsMu.Unlock()
+		p.videoJumped = true
+		return false
+	}
+	p.videoHead.Store(int64(packet.Timestamp))
+	return true
+}
 
-		return nil
+// videoDelay is how long a dequeued video packet waits before it is sent:
+// its RTP timestamp spacing from the last one, shortened in catch-up mode
+func (p *Pacer) videoDelay(packet *PacedPacket) time.Duration {
+	// First packet - send immediately to establish timeline; likewise the
+	// keyframe a fast-forward skipped to
+	if p.firstVideoPacket {
+		return 0
+	}
+	if p.videoJumped {
+		p.videoJumped = false
+		return 0
 	}
 
 	// Calculate delay based on RTP timestamp delta
@@ -256,17 +631,17 @@ func (p *Pacer) paceVideoPacket(packet *PacedPacket) error {
 	delay := p.calculateVideoDelay(packet.Timestamp)
 
 	// Check for catch-up mode
-	queueDepth := len(p.videoChan)
-	if queueDepth >= catchupThreshold {
-		// Enter catch-up mode: drain at 1.1x speed
-		delay = time.Duration(float64(delay) / catchupSpeedMultiplier)
+	queueDepth := len(p.video.ch)
+	if queueDepth >= p.config.CatchupThreshold {
+		// Enter catch-up mode: drain at CatchupSpeed
+		delay = time.Duration(float64(delay) / p.config.CatchupSpeed)
 
 		p.statsMu.Lock()
 		p.videoCatchupEvents++
 		p.statsMu.Unlock()
 
 		if p.videoCatchupEvents%10 == 1 {
-			originalDelay := time.Duration(float64(delay) * catchupSpeedMultiplier)
+			originalDelay := time.Duration(float64(delay) * p.config.CatchupSpeed)
 			p.logger.Info("[pacer:video] catch-up mode activated",
 				"queue_depth", queueDepth,
 				"original_delay_ms", originalDelay/time.Millisecond,
@@ -276,12 +651,12 @@ func (p *Pacer) paceVideoPacket(packet *PacedPacket) error {
 	}
 
 	// Cap delay to prevent infinite waits on timestamp errors
-	if delay > maxPacketDelay {
+	if delay > p.config.MaxDelay {
 		p.logger.Warn("[pacer:video] capping excessive delay",
 			"calculated_delay_ms", delay/time.Millisecond,
-			"max_delay_ms", maxPacketDelay/time.Millisecond,
+			"max_delay_ms", p.config.MaxDelay/time.Millisecond,
 			"timestamp_delta", packet.Timestamp-p.lastVideoTS)
-		delay = maxPacketDelay
+		delay = p.config.MaxDelay
 	}
 
 	// Negative delay means timestamp went backwards - log but send immediately
@@ -293,20 +668,53 @@ func (p *Pacer) paceVideoPacket(packet *PacedPacket) error {
 		delay = 0
 	}
 
+	// Draining on Stop: send the rest of the GOP at once
+	if p.isStopping() {
+		delay = 0
+	}
+
 	// Track total delay for statistics
 	p.statsMu.Lock()
 	p.totalVideoDelay += delay
 	p.statsMu.Unlock()
 
-	// CRITICAL: Sleep to pace the packet transmission
-	// This smooths out TCP bursts by restoring timing based on RTP timestamps
-	if delay > 0 {
-		select {
-		case <-time.After(delay):
-			// Delay completed
-		case <-p.ctx.Done():
-			return p.ctx.Err()
+	return delay
+}
+
+// sendVideoPacket writes a video packet once its delay has passed
+func (p *Pacer) sendVideoPacket(packet *PacedPacket, delay time.Duration) error {
+	now := time.Now()
+
+	// First packet - send immediately to establish timeline
+	if p.firstVideoPacket {
+		p.firstVideoPacket = false
+		p.lastVideoTS = packet.Timestamp
+		p.lastVideoSendAt = now
+
+		p.logger.Info("[pacer:video] first packet - establishing timeline",
+			"timestamp", packet.Timestamp,
+			"keyframe", packet.IsKeyframe)
+
+		// Get callback with proper synchronization
+		p.callbackMu.RLock()
+		writeVideoFn := p.writeVideo
+		p.callbackMu.RUnlock()
+
+		// Check for nil callback (should never happen, but defensive)
+		if writeVideoFn == nil {
+			return fmt.Errorf("writeVideo callback not set")
+		}
+
+		if err := writeVideoFn(packet.NALUs, packet.Timestamp); err != nil {
+			return fmt.Errorf("write first video packet: %w", err)
 		}
+		p.videoLatency.record(packet.ArrivedAt, packet.ReceivedAt, now, time.Now())
+
+		p.statsMu.Lock()
+		p.videoPacketsSent++
+		p.statsMu.Unlock()
+
+		return nil
 	}
 
 	// Send the packet
@@ -326,6 +734,7 @@ func (p *Pacer) paceVideoPacket(packet *PacedPacket) error {
 		return fmt.Errorf("write video packet: %w", err)
 	}
 	sendDuration := time.Since(sendStart)
+	p.videoLatency.record(packet.ArrivedAt, packet.ReceivedAt, sendStart, sendStart.Add(sendDuration))
 
 	// Update state
 	p.lastVideoTS = packet.Timestamp
@@ -347,7 +756,7 @@ func (p *Pacer) paceVideoPacket(packet *PacedPacket) error {
 			"packets_sent", packetsSent,
 			"delay_ms", delay/time.Millisecond,
 			"send_duration_ms", sendDuration/time.Millisecond,
-			"queue_depth", queueDepth,
+			"queue_depth", len(p.video.ch),
 			"keyframe", packet.IsKeyframe)
 	}
 
@@ -382,28 +791,47 @@ func (p *Pacer) calculateVideoDelay(currentTS uint32) time.Duration {
 	return delay
 }
 
-// audioPacerLoop is the main audio pacing goroutine
-func (p *Pacer) audioPacerLoop() {
-	p.logger.Info("[pacer:audio] started")
+// audioDelay is videoDelay for audio packets
+func (p *Pacer) audioDelay(packet *PacedPacket) time.Duration {
+	// First packet - send immediately
+	if p.firstAudioPacket {
+		return 0
+	}
 
-	for {
-		select {
-		case <-p.ctx.Done():
-			p.logger.Info("[pacer:audio] stopped (context cancelled)")
-			return
+	// Calculate delay based on RTP timestamp delta
+	delay := p.calculateAudioDelay(packet.Timestamp)
 
-		case packet := <-p.audioChan:
-			if err := p.paceAudioPacket(packet); err != nil {
-				p.logger.Error("[pacer:audio] failed to pace packet",
-					"timestamp", packet.Timestamp,
-					"error", err)
-			}
-		}
+	// Check for catch-up mode
+	queueDepth := len(p.audio.ch)
+	if queueDepth >= p.config.CatchupThreshold {
+		delay = time.Duration(float64(delay) / p.config.CatchupSpeed)
+
+		p.statsMu.Lock()
+		p.audioCatchupEvents++
+		p.statsMu.Unlock()
+	}
+
+	// Cap delay
+	if delay > p.config.MaxDelay {
+		p.logger.Warn("[pacer:audio] capping excessive delay",
+			"calculated_delay_ms", delay/time.Millisecond,
+			"max_delay_ms", p.config.MaxDelay/time.Millisecond)
+		delay = p.config.MaxDelay
+	}
+
+	if delay < 0 || p.isStopping() {
+		delay = 0
 	}
+
+	p.statsMu.Lock()
+	p.totalAudioDelay += delay
+	p.statsMu.Unlock()
+
+	return delay
 }
 
-// paceAudioPacket implements the core pacing logic for a single audio packet
-func (p *Pacer) paceAudioPacket(packet *PacedPacket) error {
+// sendAudioPacket writes an audio packet once its delay has passed
+func (p *Pacer) sendAudioPacket(packet *PacedPacket, delay time.Duration) error {
 	now := time.Now()
 
 	// First packet - send immediately
@@ -436,44 +864,6 @@ func (p *Pacer) paceAudioPacket(packet *PacedPacket) error {
 		return nil
 	}
 
-	// Calculate delay based on RTP timestamp delta
-	delay := p.calculateAudioDelay(packet.Timestamp)
-
-	// Check for catch-up mode
-	queueDepth := len(p.audioChan)
-	if queueDepth >= catchupThreshold {
-		delay = time.Duration(float64(delay) / catchupSpeedMultiplier)
-
-		p.statsMu.Lock()
-		p.audioCatchupEvents++
-		p.statsMu.Unlock()
-	}
-
-	// Cap delay
-	if delay > maxPacketDelay {
-		p.logger.Warn("[pacer:audio] capping excessive delay",
-			"calculated_delay_ms", delay/time.Millisecond,
-			"max_delay_ms", maxPacketDelay/time.Millisecond)
-		delay = maxPacketDelay
-	}
-
-	if delay < 0 {
-		delay = 0
-	}
-
-	p.statsMu.Lock()
-	p.totalAudioDelay += delay
-	p.statsMu.Unlock()
-
-	// Sleep to pace transmission
-	if delay > 0 {
-		select {
-		case <-time.After(delay):
-		case <-p.ctx.Done():
-			return p.ctx.Err()
-		}
-	}
-
 	// Send the packet
 	// Get callback with proper synchronization
 	p.callbackMu.RLock()
@@ -524,19 +914,16 @@ func (p *Pacer) calculateAudioDelay(currentTS uint32) time.Duration {
 	return delay
 }
 
-// statsLoop periodically logs pacer statistics
-func (p *Pacer) statsLoop() {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-p.ctx.Done():
+// scheduleStats logs the pacer's statistics every pacerStatsInterval until
+// it stops
+func (p *Pacer) scheduleStats() {
+	pacerWheel.schedule(pacerStatsInterval, func() {
+		if p.ctx.Err() != nil || p.isStopping() {
 			return
-		case <-ticker.C:
-			p.logStats()
 		}
-	}
+		p.logStats()
+		p.scheduleStats()
+	})
 }
 
 // logStats logs current pacer statistics
@@ -559,10 +946,15 @@ func (p *Pacer) logStats() {
 		"audio_bursts_absorbed", p.audioBurstsAbsorbed,
 		"video_catchup_events", p.videoCatchupEvents,
 		"audio_catchup_events", p.audioCatchupEvents,
+		"video_dropped_frames", p.videoDroppedFrames,
+		"video_dropped_keyframes", p.videoDroppedKeyframes,
+		"video_dropped_gop", p.videoDroppedGOP,
+		"video_fast_forwards", p.videoFastForwards,
+		"video_skipped", p.videoSkipped,
 		"avg_video_delay_ms", avgVideoDelay/time.Millisecond,
 		"avg_audio_delay_ms", avgAudioDelay/time.Millisecond,
-		"video_queue_depth", len(p.videoChan),
-		"audio_queue_depth", len(p.audioChan))
+		"video_queue_depth", len(p.video.ch),
+		"audio_queue_depth", len(p.audio.ch))
 }
 
 // GetStats returns current pacer statistics
@@ -571,19 +963,33 @@ func (p *Pacer) GetStats() PacerStats {
 	defer p.statsMu.RUnlock()
 
 	return PacerStats{
+		Bypass:              p.config.Bypass,
 		VideoPacketsSent:    p.videoPacketsSent,
 		AudioPacketsSent:    p.audioPacketsSent,
 		VideoBurstsAbsorbed: p.videoBurstsAbsorbed,
 		AudioBurstsAbsorbed: p.audioBurstsAbsorbed,
 		VideoCatchupEvents:  p.videoCatchupEvents,
 		AudioCatchupEvents:  p.audioCatchupEvents,
-		VideoQueueDepth:     len(p.videoChan),
-		AudioQueueDepth:     len(p.audioChan),
+		VideoQueueDepth:     len(p.video.ch),
+		AudioQueueDepth:     len(p.audio.ch),
+
+		VideoDroppedFrames:    p.videoDroppedFrames,
+		VideoDroppedKeyframes: p.videoDroppedKeyframes,
+		VideoDroppedGOP:       p.videoDroppedGOP,
+
+		VideoFastForwards: p.videoFastForwards,
+		VideoSkipped:      p.videoSkipped,
 	}
 }
 
+// VideoLatency returns percentiles of recent per-frame video latency
+func (p *Pacer) VideoLatency() LatencyStats {
+	return p.videoLatency.snapshot()
+}
+
 // PacerStats contains pacer statistics
 type PacerStats struct {
+	Bypass              bool // Pacing is off (PacerConfig.Bypass)
 	VideoPacketsSent    uint64
 	AudioPacketsSent    uint64
 	VideoBurstsAbsorbed uint64
@@ -592,4 +998,12 @@ type PacerStats struct {
 	AudioCatchupEvents  uint64
 	VideoQueueDepth     int
 	AudioQueueDepth     int
+
+	// Video frames dropped because the queue was full, by type
+	VideoDroppedFrames    uint64 // Non-keyframes
+	VideoDroppedKeyframes uint64 // Keyframes that found no room within keyframeEnqueueWait
+	VideoDroppedGOP       uint64 // Frames after a dropped reference frame, up to the next keyframe
+
+	VideoFastForwards uint64 // Times queued video exceeded the latency budget
+	VideoSkipped      uint64 // Frames skipped to reach the newest keyframe
 }
@@ -0,0 +1,221 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPacerStopDrains(t *testing.T) {
+	p := NewPacer(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	var sent, released atomic.Int32
+	write := func(data []byte, timestamp uint32) error {
+		sent.Add(1)
+		return nil
+	}
+	p.SetWriteCallbacks(write, write)
+	p.Start()
+
+	// Frames 100ms apart: paced, these would take most of a second
+	for i := range 8 {
+		pkt := &PacedPacket{Timestamp: uint32(i * 9000), TrackType: "video", Release: func() { released.Add(1) }}
+		if err := p.EnqueueVideo(pkt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Now()
+	p.Stop()
+	if elapsed := time.Since(start); elapsed > drainTimeout/2 {
+		t.Errorf("Stop took %v; queued packets were paced", elapsed)
+	}
+	if sent.Load() != 8 || released.Load() != 8 {
+		t.Errorf("sent %d and released %d of 8 packets", sent.Load(), released.Load())
+	}
+	if err := p.EnqueueVideo(&PacedPacket{}); err == nil {
+		t.Error("enqueue accepted after Stop")
+	} else if !errors.Is(err, ErrPacerStopped) && !errors.Is(err, context.Canceled) {
+		t.Errorf("enqueue after Stop: %v", err)
+	}
+}
+
+func TestPacerDropPolicy(t *testing.T) {
+	// Not started, so nothing drains the two-packet queue
+	p := NewPacer(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := p.SetConfig(PacerConfig{VideoQueueSize: 2, CatchupThreshold: 1}); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	var dropped atomic.Int32
+	enqueue := func(keyframe, reference bool) {
+		t.Helper()
+		pkt := &PacedPacket{IsKeyframe: keyframe, Reference: reference}
+		pkt.Release = func() {
+			if pkt.Dropped {
+				dropped.Add(1)
+			}
+		}
+		start := time.Now()
+		if err := p.EnqueueVideo(pkt); err != nil {
+			t.Fatal(err)
+		}
+		if time.Since(start) > 2*keyframeEnqueueWait {
+			t.Fatal("enqueue blocked on a full queue")
+		}
+	}
+
+	enqueue(true, true)
+	enqueue(false, true)
+	enqueue(false, false) // Full: non-reference frame dropped, GOP intact
+	enqueue(false, true)  // Full: reference frame dropped
+	enqueue(false, false) // Rest of the GOP dropped, though nothing was taken
+	enqueue(true, true)   // Keyframe waits, then is dropped too
+
+	st := p.GetStats()
+	if st.VideoDroppedFrames != 2 || st.VideoDroppedGOP != 1 || st.VideoDroppedKeyframes != 1 || dropped.Load() != 4 {
+		t.Errorf("stats %+v, %d released as dropped", st, dropped.Load())
+	}
+
+	// Room again: the next keyframe starts a new GOP
+	<-p.video.ch
+	p.pending.Add(-1)
+	enqueue(true, true)
+	if st := p.GetStats(); st.VideoDroppedKeyframes != 1 || len(p.video.ch) != 2 {
+		t.Errorf("keyframe not queued after room opened: %+v", st)
+	}
+}
+
+func TestPacerLatencyBudget(t *testing.T) {
+	p := NewPacer(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := p.SetConfig(PacerConfig{LatencyBudget: 100 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	var sent []uint32
+	write := func(data []byte, timestamp uint32) error {
+		sent = append(sent, timestamp)
+		return nil
+	}
+	p.SetWriteCallbacks(write, write)
+
+	// A burst of 30fps frames queued before the pacer runs: the second
+	// keyframe arrives 133ms after the first
+	for i := range 6 {
+		pkt := &PacedPacket{Timestamp: uint32(i * 3000), IsKeyframe: i%4 == 0, Reference: true}
+		if err := p.EnqueueVideo(pkt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := p.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	p.Stop()
+
+	if want := []uint32{12000, 15000}; !slices.Equal(sent, want) {
+		t.Errorf("sent %v, want %v", sent, want)
+	}
+	if st := p.GetStats(); st.VideoFastForwards != 1 || st.VideoSkipped != 4 {
+		t.Errorf("stats %+v", st)
+	}
+}
+
+func TestPacerBypass(t *testing.T) {
+	p := NewPacer(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := p.SetConfig(PacerConfig{Bypass: true}); err != nil {
+		t.Fatal(err)
+	}
+	var sent atomic.Int32
+	write := func(data []byte, timestamp uint32) error {
+		sent.Add(1)
+		return nil
+	}
+	p.SetWriteCallbacks(write, write)
+
+	// Before Start there is nowhere to write
+	pkt := &PacedPacket{}
+	if err := p.EnqueueVideo(pkt); err != nil || !pkt.Dropped {
+		t.Fatalf("enqueue before Start: err %v, dropped %v", err, pkt.Dropped)
+	}
+
+	p.Start()
+	defer p.Stop()
+
+	// Frames a second apart are written on enqueue, not paced
+	start := time.Now()
+	for i := range 5 {
+		if err := p.EnqueueVideo(&PacedPacket{Timestamp: uint32(i * videoClockRate)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.EnqueueAudio(&PacedPacket{Timestamp: uint32(i * audioClockRate)}); err != nil {
+			t.Fatal(err)
+		}
+		if got := sent.Load(); got != int32(2*(i+1)) {
+			t.Fatalf("%d packets written after enqueuing %d", got, 2*(i+1))
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("bypass took %v", elapsed)
+	}
+	if st := p.GetStats(); st.VideoPacketsSent != 5 || st.AudioPacketsSent != 5 {
+		t.Errorf("stats %+v", st)
+	}
+}
+
+func TestPacerStalledWriteIsolated(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// More stalled cameras than the wheel has workers
+	unblock := make(chan struct{})
+	var stalled []*Pacer
+	for range pacerWheel.workers + 1 {
+		p := NewPacer(context.Background(), logger)
+		block := func(data []byte, timestamp uint32) error {
+			<-unblock
+			return nil
+		}
+		p.SetWriteCallbacks(block, block)
+		p.Start()
+		if err := p.EnqueueVideo(&PacedPacket{}); err != nil {
+			t.Fatal(err)
+		}
+		stalled = append(stalled, p)
+	}
+	defer func() {
+		close(unblock)
+		for _, p := range stalled {
+			p.Stop()
+		}
+	}()
+
+	p := NewPacer(context.Background(), logger)
+	sent := make(chan uint32, 8)
+	write := func(data []byte, timestamp uint32) error {
+		sent <- timestamp
+		return nil
+	}
+	p.SetWriteCallbacks(write, write)
+	p.Start()
+	defer p.Stop()
+
+	// Frames 10ms apart still go out on time
+	for i := range 4 {
+		if err := p.EnqueueVideo(&PacedPacket{Timestamp: uint32(i * 900)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range 4 {
+		select {
+		case <-sent:
+		case <-time.After(time.Second):
+			t.Fatalf("%d of 4 packets sent while other cameras stall", i)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package bridge
+
+import (
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+	"github.com/ethan/nest-cloudflare-relay/pkg/recovery"
+	"github.com/pion/webrtc/v4"
+)
+
+// peerStatsInterval is how often the PeerConnection's stats are polled
+const peerStatsInterval = 10 * time.Second
+
+// PeerStats is what actually leaves the host for the SFU: bytes and frames
+// the tracks accepted, and the selected ICE candidate pair from
+// pc.GetStats(). It tells "connected but black video" (no video bytes) apart
+// from problems on the viewer side.
+type PeerStats struct {
+	VideoPacketsSent uint64
+	VideoBytesSent   uint64
+	VideoFramesSent  uint64
+	AudioPacketsSent uint64
+	AudioBytesSent   uint64
+	VideoBitrate     float64 // Bits per second over the last poll interval
+
+	TransportBytesSent uint64        // Everything on the selected pair, including RTCP and DTLS
+	RTT                time.Duration // STUN round trip on the selected pair; zero until known
+	LocalCandidate     string        // Candidate type: host, srflx, prflx or relay
+	RemoteCandidate    string
+	Protocol           string // udp or tcp
+
+	UpdatedAt time.Time // Zero until the first poll while connected
+}
+
+// PeerStats returns the most recent poll
+func (b *Bridge) PeerStats() PeerStats {
+	b.peerStatsMu.RLock()
+	defer b.peerStatsMu.RUnlock()
+	return b.peerStats
+}
+
+// pollPeerStats refreshes PeerStats every interval while connected and warns
+// when the connection is up but no video is leaving
+func (b *Bridge) pollPeerStats() {
+	ticker := time.NewTicker(peerStatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case now := <-ticker.C:
+			if b.GetConnectionState() != webrtc.PeerConnectionStateConnected {
+				continue
+			}
+			b.updatePeerStats(now)
+		}
+	}
+}
+
+// updatePeerStats takes one sample
+func (b *Bridge) updatePeerStats(now time.Time) {
+	stats := selectedPairStats(b.pc.GetStats())
+	if w := b.videoWriter; w != nil {
+		stats.VideoPacketsSent = w.sentPackets.Load()
+		stats.VideoBytesSent = w.sentBytes.Load()
+		stats.VideoFramesSent = w.sentFrames.Load()
+	}
+	if w := b.audioWriter; w != nil {
+		stats.AudioPacketsSent = w.sentPackets.Load()
+		stats.AudioBytesSent = w.sentBytes.Load()
+	}
+	stats.UpdatedAt = now
+
+	b.peerStatsMu.Lock()
+	prev := b.peerStats
+	if !prev.UpdatedAt.IsZero() {
+		elapsed := now.Sub(prev.UpdatedAt).Seconds()
+		stats.VideoBitrate = float64(stats.VideoBytesSent-prev.VideoBytesSent) * 8 / elapsed
+	}
+	b.peerStats = stats
+	wasSilent := b.videoSilent
+	b.videoSilent = !prev.UpdatedAt.IsZero() && !b.audioOnly && stats.VideoBytesSent == prev.VideoBytesSent
+	silent := b.videoSilent
+	b.peerStatsMu.Unlock()
+
+	b.logger.Debug("peer stats",
+		"video_bytes_sent", stats.VideoBytesSent,
+		"video_frames_sent", stats.VideoFramesSent,
+		"video_bitrate", int64(stats.VideoBitrate),
+		"ice_rtt_ms", stats.RTT.Milliseconds(),
+		"local_candidate", stats.LocalCandidate,
+		"remote_candidate", stats.RemoteCandidate,
+		"protocol", stats.Protocol)
+
+	switch {
+	case silent && !wasSilent:
+		b.logger.Warn("connected but no video is being sent",
+			"session_id", b.sessionID,
+			"video_frames_sent", stats.VideoFramesSent,
+			"local_candidate", stats.LocalCandidate)
+	case wasSilent && !silent:
+		b.logger.Info("video is being sent again", "session_id", b.sessionID)
+	}
+}
+
+// VideoSilent reports whether the connection is up but no video left the
+// host during the last poll interval
+func (b *Bridge) VideoSilent() bool {
+	b.peerStatsMu.RLock()
+	defer b.peerStatsMu.RUnlock()
+	return b.videoSilent
+}
+
+// selectedPairStats extracts the selected candidate pair, its RTT and the
+// candidate types from a stats report
+func selectedPairStats(report webrtc.StatsReport) PeerStats {
+	var stats PeerStats
+
+	var pairID string
+	for _, s := range report {
+		if t, ok := s.(webrtc.TransportStats); ok {
+			pairID = t.SelectedCandidatePairID
+			stats.TransportBytesSent = t.BytesSent
+			break
+		}
+	}
+
+	// pion doesn't always set SelectedCandidatePairID; fall back to the
+	// nominated pair that succeeded
+	var pair *webrtc.ICECandidatePairStats
+	for _, s := range report {
+		p, ok := s.(webrtc.ICECandidatePairStats)
+		if !ok {
+			continue
+		}
+		if p.ID == pairID || (pairID == "" && p.Nominated && p.State == webrtc.StatsICECandidatePairStateSucceeded) {
+			pair = &p
+			break
+		}
+	}
+	if pair == nil {
+		return stats
+	}
+
+	stats.RTT = time.Duration(pair.CurrentRoundTripTime * float64(time.Second))
+	if local, ok := report[pair.LocalCandidateID].(webrtc.ICECandidateStats); ok {
+		stats.LocalCandidate = local.CandidateType.String()
+		stats.Protocol = local.Protocol
+	}
+	if remote, ok := report[pair.RemoteCandidateID].(webrtc.ICECandidateStats); ok {
+		stats.RemoteCandidate = remote.CandidateType.String()
+	}
+	return stats
+}
+
+// startPeerStats starts the poller; called once negotiation succeeds
+func (b *Bridge) startPeerStats() {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer goroutines.Track("bridge.peerstats", b.cameraID)()
+		defer recovery.Recover("bridge.peerstats", b.panicked)
+		b.pollPeerStats()
+	}()
+}
@@ -0,0 +1,40 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+func TestSelectedPairStats(t *testing.T) {
+	report := webrtc.StatsReport{
+		"iceTransport": webrtc.TransportStats{Type: webrtc.StatsTypeTransport, BytesSent: 1234},
+		"pair-failed": webrtc.ICECandidatePairStats{
+			ID: "pair-failed", LocalCandidateID: "l2", RemoteCandidateID: "r1",
+			State: webrtc.StatsICECandidatePairStateFailed, CurrentRoundTripTime: 9,
+		},
+		"pair-ok": webrtc.ICECandidatePairStats{
+			ID: "pair-ok", LocalCandidateID: "l1", RemoteCandidateID: "r1",
+			State: webrtc.StatsICECandidatePairStateSucceeded, Nominated: true, CurrentRoundTripTime: 0.025,
+		},
+		"l1": webrtc.ICECandidateStats{ID: "l1", CandidateType: webrtc.ICECandidateTypeSrflx, Protocol: "udp"},
+		"l2": webrtc.ICECandidateStats{ID: "l2", CandidateType: webrtc.ICECandidateTypeHost, Protocol: "udp"},
+		"r1": webrtc.ICECandidateStats{ID: "r1", CandidateType: webrtc.ICECandidateTypeHost, Protocol: "udp"},
+	}
+
+	stats := selectedPairStats(report)
+	if stats.RTT != 25*time.Millisecond {
+		t.Errorf("RTT = %v, want 25ms", stats.RTT)
+	}
+	if stats.LocalCandidate != "srflx" || stats.RemoteCandidate != "host" || stats.Protocol != "udp" {
+		t.Errorf("candidates = %s/%s/%s, want srflx/host/udp", stats.LocalCandidate, stats.RemoteCandidate, stats.Protocol)
+	}
+	if stats.TransportBytesSent != 1234 {
+		t.Errorf("TransportBytesSent = %d, want 1234", stats.TransportBytesSent)
+	}
+
+	if empty := selectedPairStats(webrtc.StatsReport{}); empty.RTT != 0 || empty.LocalCandidate != "" {
+		t.Errorf("empty report gave %+v", empty)
+	}
+}
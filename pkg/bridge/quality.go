@@ -0,0 +1,107 @@
+package bridge
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+// qualitySmoothing is the EWMA weight given to each new receiver report.
+// Cloudflare sends RRs roughly once a second, so this averages over ~5s.
+const qualitySmoothing = 0.2
+
+// ntpEpochOffset is the number of seconds between 1900 (NTP) and 1970 (Unix)
+const ntpEpochOffset = 2208988800
+
+// TrackQuality is the receiver's view of one outbound track, taken from
+// RTCP receiver reports. Loss, jitter and RTT are exponentially smoothed.
+type TrackQuality struct {
+	Reports      uint64        // Receiver reports seen for this track
+	FractionLost float64       // 0..1, smoothed
+	TotalLost    uint32        // Cumulative packets lost, as last reported
+	Jitter       time.Duration // Interarrival jitter, smoothed
+	RTT          time.Duration // Round-trip time via LSR/DLSR, zero until known
+	NACKed       uint64        // Packets the receiver asked to have retransmitted
+	UpdatedAt    time.Time     // Zero until the first report
+}
+
+// qualityTracker folds receiver reports for a single track into TrackQuality
+type qualityTracker struct {
+	clockRate uint32
+
+	mu sync.Mutex
+	q  TrackQuality
+}
+
+func newQualityTracker(clockRate uint32) *qualityTracker {
+	return &qualityTracker{clockRate: clockRate}
+}
+
+// update applies one reception report block received at now
+func (t *qualityTracker) update(rr rtcp.ReceptionReport, now time.Time) {
+	lost := float64(rr.FractionLost) / 256
+	jitter := time.Duration(uint64(rr.Jitter) * uint64(time.Second) / uint64(t.clockRate))
+	rtt, rttOK := rttFromReport(now, rr.LastSenderReport, rr.Delay)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	first := t.q.Reports == 0
+	t.q.Reports++
+	t.q.TotalLost = rr.TotalLost
+	t.q.UpdatedAt = now
+	if first {
+		t.q.FractionLost = lost
+		t.q.Jitter = jitter
+	} else {
+		t.q.FractionLost = ewma(t.q.FractionLost, lost)
+		t.q.Jitter = time.Duration(ewma(float64(t.q.Jitter), float64(jitter)))
+	}
+	if rttOK {
+		if t.q.RTT == 0 {
+			t.q.RTT = rtt
+		} else {
+			t.q.RTT = time.Duration(ewma(float64(t.q.RTT), float64(rtt)))
+		}
+	}
+}
+
+// nacked counts n packets requested again by a NACK
+func (t *qualityTracker) nacked(n int) {
+	t.mu.Lock()
+	t.q.NACKed += uint64(n)
+	t.mu.Unlock()
+}
+
+// snapshot returns the current quality
+func (t *qualityTracker) snapshot() TrackQuality {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.q
+}
+
+func ewma(prev, sample float64) float64 {
+	return prev + qualitySmoothing*(sample-prev)
+}
+
+// rttFromReport computes round-trip time per RFC 3550 §6.4.1: the arrival
+// time minus LSR minus DLSR, all in compact NTP (1/65536 s) units. It
+// reports false when no sender report has been echoed back yet.
+func rttFromReport(now time.Time, lsr, dlsr uint32) (time.Duration, bool) {
+	if lsr == 0 {
+		return 0, false
+	}
+	rtt := compactNTP(now) - lsr - dlsr
+	if int32(rtt) < 0 {
+		return 0, false // Clock went backwards or a stale report
+	}
+	return time.Duration(uint64(rtt) * uint64(time.Second) >> 16), true
+}
+
+// compactNTP returns the middle 32 bits of the NTP timestamp for t
+func compactNTP(t time.Time) uint32 {
+	secs := uint64(t.Unix()) + ntpEpochOffset
+	frac := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return uint32(secs<<16 | frac>>16)
+}
@@ -0,0 +1,49 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+func TestRTTFromReport(t *testing.T) {
+	sent := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	lsr := compactNTP(sent)
+	dlsr := uint32(65536 / 2) // Receiver held the SR for 500ms
+
+	rtt, ok := rttFromReport(sent.Add(580*time.Millisecond), lsr, dlsr)
+	if !ok {
+		t.Fatal("rtt not computed")
+	}
+	if d := rtt - 80*time.Millisecond; d < -time.Millisecond || d > time.Millisecond {
+		t.Errorf("rtt = %s, want ~80ms", rtt)
+	}
+
+	if _, ok := rttFromReport(sent, 0, 0); ok {
+		t.Error("rtt computed without a sender report")
+	}
+	if _, ok := rttFromReport(sent.Add(100*time.Millisecond), lsr, dlsr); ok {
+		t.Error("rtt computed for a delay longer than the elapsed time")
+	}
+}
+
+func TestQualityTrackerSmoothing(t *testing.T) {
+	q := newQualityTracker(90000)
+	now := time.Now()
+
+	q.update(rtcp.ReceptionReport{FractionLost: 64, TotalLost: 10, Jitter: 900}, now)
+	got := q.snapshot()
+	if got.FractionLost != 0.25 || got.Jitter != 10*time.Millisecond || got.TotalLost != 10 {
+		t.Fatalf("first report = %+v", got)
+	}
+	if got.RTT != 0 {
+		t.Errorf("rtt = %s without LSR", got.RTT)
+	}
+
+	q.update(rtcp.ReceptionReport{FractionLost: 0, TotalLost: 10, Jitter: 900}, now.Add(time.Second))
+	got = q.snapshot()
+	if got.FractionLost != 0.2 || got.Reports != 2 {
+		t.Errorf("second report = %+v, want loss 0.2", got)
+	}
+}
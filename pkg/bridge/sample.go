@@ -0,0 +1,42 @@
+package bridge
+
+import (
+	"time"
+
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+// defaultFrameDuration is the duration given to the first sample, which has
+// no predecessor to measure from
+const defaultFrameDuration = time.Second / 30
+
+// annexBStartCode precedes each NAL unit in a sample
+var annexBStartCode = []byte{0, 0, 0, 1}
+
+// writeVideoSampleTrack queues an access unit for the sample track. The
+// track derives RTP timestamps from sample durations, so each duration is
+// the source timestamp delta, keeping the camera's timing.
+func (b *Bridge) writeVideoSampleTrack(nalus [][]byte, sourceTimestamp uint32) error {
+	size := 0
+	for _, nalu := range nalus {
+		size += len(annexBStartCode) + len(nalu)
+	}
+	data := make([]byte, 0, size)
+	for _, nalu := range nalus {
+		data = append(data, annexBStartCode...)
+		data = append(data, nalu...)
+	}
+
+	// A jump (camera restart, timestamps going backwards) gets the default
+	duration := defaultFrameDuration
+	if delta := sourceTimestamp - b.lastSampleTS; b.sampleStarted && delta < 5*90000 {
+		duration = time.Duration(delta) * time.Second / 90000
+	}
+	b.sampleStarted, b.lastSampleTS = true, sourceTimestamp
+
+	err := b.videoWriter.writeSample(media.Sample{Data: data, Duration: duration})
+	if ClassifyWriteError(err) == WriteErrorClosed {
+		return nil // Track closed gracefully
+	}
+	return err
+}
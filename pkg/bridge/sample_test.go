@@ -0,0 +1,47 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+// sampleRecorder collects written samples
+type sampleRecorder struct{ samples chan media.Sample }
+
+func (r *sampleRecorder) WriteSample(s media.Sample) error {
+	r.samples <- s
+	return nil
+}
+
+func TestWriteVideoSampleTrack(t *testing.T) {
+	rec := &sampleRecorder{samples: make(chan media.Sample, 4)}
+	b := &Bridge{videoWriter: newSampleTrackWriter(rec, "video", time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)))}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.videoWriter.run(ctx, nil)
+
+	nalus := [][]byte{{0x67, 1}, {0x65, 2, 3}}
+	for _, ts := range []uint32{1000, 4000, 1000} {
+		if err := b.writeVideoSampleTrack(nalus, ts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []time.Duration{defaultFrameDuration, time.Second / 30, defaultFrameDuration}
+	for i, d := range want {
+		s := <-rec.samples
+		if !bytes.Equal(s.Data, []byte{0, 0, 0, 1, 0x67, 1, 0, 0, 0, 1, 0x65, 2, 3}) {
+			t.Fatalf("sample %d = % x, want Annex-B", i, s.Data)
+		}
+		if s.Duration != d {
+			t.Errorf("sample %d duration = %v, want %v", i, s.Duration, d)
+		}
+	}
+}
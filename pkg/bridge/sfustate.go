@@ -0,0 +1,54 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/sfu"
+)
+
+// CheckSFUState asks the SFU which of the bridge's published tracks it holds
+// and returns a description of every track that is missing, errored or
+// inactive there. It returns sfu.ErrNotSupported when the backend cannot
+// report session state.
+func (b *Bridge) CheckSFUState(ctx context.Context) ([]string, error) {
+	reporter, ok := b.backend.(sfu.StateReporter)
+	if !ok {
+		return nil, sfu.ErrNotSupported
+	}
+
+	b.tracksMu.RLock()
+	published := b.tracks
+	b.tracksMu.RUnlock()
+	if len(published) == 0 {
+		return nil, nil // Not negotiated yet
+	}
+
+	states, err := reporter.SessionState(ctx, b.sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("get SFU session state: %w", err)
+	}
+	return stateDrift(published, states), nil
+}
+
+// stateDrift compares published tracks against the SFU's view of them
+func stateDrift(published []sfu.Track, states []sfu.TrackState) []string {
+	byName := make(map[string]sfu.TrackState, len(states))
+	for _, st := range states {
+		byName[st.Name] = st
+	}
+
+	var drift []string
+	for _, t := range published {
+		st, ok := byName[t.Name]
+		switch {
+		case !ok:
+			drift = append(drift, t.Name+": missing on SFU")
+		case st.Error != "":
+			drift = append(drift, t.Name+": "+st.Error)
+		case st.Status == "inactive":
+			drift = append(drift, t.Name+": inactive on SFU")
+		}
+	}
+	return drift
+}
@@ -0,0 +1,33 @@
+package bridge
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/sfu"
+)
+
+func TestStateDrift(t *testing.T) {
+	published := []sfu.Track{{Name: "cam-video"}, {Name: "cam-audio"}}
+
+	if drift := stateDrift(published, []sfu.TrackState{
+		{Name: "cam-video", Status: "active"},
+		{Name: "cam-audio", Status: "waiting"},
+		{Name: "other-video", Status: "inactive"},
+	}); len(drift) != 0 {
+		t.Errorf("healthy session reported drift: %v", drift)
+	}
+
+	drift := stateDrift(published, []sfu.TrackState{
+		{Name: "cam-video", Status: "inactive"},
+	})
+	want := []string{"cam-video: inactive on SFU", "cam-audio: missing on SFU"}
+	if !slices.Equal(drift, want) {
+		t.Errorf("drift = %v, want %v", drift, want)
+	}
+
+	drift = stateDrift(published[:1], []sfu.TrackState{{Name: "cam-video", Error: "track_error: gone"}})
+	if !slices.Equal(drift, []string{"cam-video: track_error: gone"}) {
+		t.Errorf("drift = %v", drift)
+	}
+}
@@ -0,0 +1,80 @@
+package bridge
+
+import (
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// OutboundStats is one sent track as pion's stats interceptor sees it: what
+// went out, what the SFU asked to be repaired, and what its receiver reports
+// say arrived
+type OutboundStats struct {
+	SSRC                 uint32
+	PacketsSent          uint64
+	BytesSent            uint64 // Payload only
+	RetransmittedPackets uint64 // Resent in answer to NACKs
+	NACKs                uint64 // NACK requests received from the SFU
+	PLIs                 uint64
+	FIRs                 uint64
+
+	PacketsLost  int64         // Cumulative, from receiver reports
+	FractionLost float64       // Since the previous receiver report, 0-1
+	Jitter       time.Duration // Interarrival jitter the SFU measured
+	RTT          time.Duration // From the last receiver report's LSR/DLSR; zero until known
+}
+
+// WebRTCStats is GetWebRTCStats' per-track view. It answers "why is this
+// tile freezing" from the server: rising NACKs and loss point at the path,
+// PLIs without loss at the SFU or viewer.
+type WebRTCStats struct {
+	Video OutboundStats
+	Audio OutboundStats
+}
+
+// GetWebRTCStats harvests the outbound-rtp and remote-inbound-rtp entries
+// from pc.GetStats(); zero before a session is created
+func (b *Bridge) GetWebRTCStats() WebRTCStats {
+	if b.pc == nil {
+		return WebRTCStats{}
+	}
+	return outboundStats(b.pc.GetStats())
+}
+
+// outboundStats pairs each kind's outbound-rtp entry with the
+// remote-inbound-rtp entry built from the SFU's receiver reports
+func outboundStats(report webrtc.StatsReport) WebRTCStats {
+	var stats WebRTCStats
+	track := func(kind string) *OutboundStats {
+		switch kind {
+		case "video":
+			return &stats.Video
+		case "audio":
+			return &stats.Audio
+		}
+		return nil
+	}
+
+	for _, s := range report {
+		switch s := s.(type) {
+		case webrtc.OutboundRTPStreamStats:
+			if t := track(s.Kind); t != nil {
+				t.SSRC = uint32(s.SSRC)
+				t.PacketsSent = uint64(s.PacketsSent)
+				t.BytesSent = s.BytesSent
+				t.RetransmittedPackets = s.RetransmittedPacketsSent
+				t.NACKs = uint64(s.NACKCount)
+				t.PLIs = uint64(s.PLICount)
+				t.FIRs = uint64(s.FIRCount)
+			}
+		case webrtc.RemoteInboundRTPStreamStats:
+			if t := track(s.Kind); t != nil {
+				t.PacketsLost = int64(s.PacketsLost)
+				t.FractionLost = s.FractionLost
+				t.Jitter = time.Duration(s.Jitter * float64(time.Second))
+				t.RTT = time.Duration(s.RoundTripTime * float64(time.Second))
+			}
+		}
+	}
+	return stats
+}
@@ -0,0 +1,34 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+func TestOutboundStats(t *testing.T) {
+	report := webrtc.StatsReport{
+		"out-video": webrtc.OutboundRTPStreamStats{
+			Type: webrtc.StatsTypeOutboundRTP, SSRC: 1, Kind: "video",
+			PacketsSent: 500, BytesSent: 600000, RetransmittedPacketsSent: 7, NACKCount: 3, PLICount: 2,
+		},
+		"out-audio": webrtc.OutboundRTPStreamStats{Type: webrtc.StatsTypeOutboundRTP, SSRC: 2, Kind: "audio", PacketsSent: 50},
+		"remote-video": webrtc.RemoteInboundRTPStreamStats{
+			Type: webrtc.StatsTypeRemoteInboundRTP, SSRC: 1, Kind: "video",
+			PacketsLost: 4, FractionLost: 0.01, Jitter: 0.002, RoundTripTime: 0.035,
+		},
+	}
+
+	stats := outboundStats(report)
+	v := stats.Video
+	if v.SSRC != 1 || v.PacketsSent != 500 || v.BytesSent != 600000 || v.RetransmittedPackets != 7 || v.NACKs != 3 || v.PLIs != 2 {
+		t.Errorf("video outbound = %+v", v)
+	}
+	if v.PacketsLost != 4 || v.FractionLost != 0.01 || v.Jitter != 2*time.Millisecond || v.RTT != 35*time.Millisecond {
+		t.Errorf("video remote inbound = %+v", v)
+	}
+	if a := stats.Audio; a.SSRC != 2 || a.PacketsSent != 50 || a.RTT != 0 {
+		t.Errorf("audio = %+v", a)
+	}
+}
@@ -0,0 +1,162 @@
+package bridge
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+)
+
+const (
+	// wheelTick is the timing wheel's resolution, and the most a paced
+	// packet is sent late by the wheel itself
+	wheelTick = time.Millisecond
+
+	// wheelSlots covers 512ms per turn, past the default pacer MaxDelay;
+	// longer timers wait out whole turns
+	wheelSlots = 512
+)
+
+// pacerWheel schedules every pacer in the process: one ticker replaces a
+// time.After per paced packet, and a few shared workers replace each
+// pacer's stats loop. Its callbacks must not block, since a slow one holds
+// up every camera's timers; pacers only wake their tracks' goroutines from
+// it and write packets on those.
+var pacerWheel = newTimingWheel(wheelTick, wheelSlots, max(4, runtime.GOMAXPROCS(0)))
+
+// wheelTimer is a callback waiting in a wheel slot
+type wheelTimer struct {
+	rounds int // Full turns left before it fires
+	fn     func()
+}
+
+// timingWheel is a hashed timing wheel: callbacks are filed into the slot
+// their deadline falls in, and a single ticker advances through the slots,
+// handing due callbacks to a fixed pool of workers. The ticker only runs
+// while timers are pending.
+type timingWheel struct {
+	tick    time.Duration
+	workers int
+
+	mu     sync.Mutex
+	slots  [][]wheelTimer
+	pos    int       // Slot last fired
+	cursor time.Time // When pos was due
+	count  int       // Timers pending
+
+	wake  chan struct{}
+	tasks chan func()
+	once  sync.Once
+}
+
+func newTimingWheel(tick time.Duration, slots, workers int) *timingWheel {
+	return &timingWheel{
+		tick:    tick,
+		workers: workers,
+		slots:   make([][]wheelTimer, slots),
+		wake:    make(chan struct{}, 1),
+		tasks:   make(chan func(), 256),
+	}
+}
+
+// start runs the ticker and workers on first use; they live as long as the
+// process
+func (w *timingWheel) start() {
+	w.once.Do(func() {
+		goroutines.Go("scheduler.wheel", "", w.loop)
+		for range w.workers {
+			goroutines.Go("scheduler.worker", "", func() {
+				for fn := range w.tasks {
+					fn()
+				}
+			})
+		}
+	})
+}
+
+// run calls fn on a worker as soon as one is free
+func (w *timingWheel) run(fn func()) {
+	w.start()
+	w.tasks <- fn
+}
+
+// schedule calls fn on a worker once delay has passed, rounded up to the
+// next tick
+func (w *timingWheel) schedule(delay time.Duration, fn func()) {
+	if delay <= 0 {
+		w.run(fn)
+		return
+	}
+	w.start()
+
+	w.mu.Lock()
+	now := time.Now()
+	if w.count == 0 {
+		w.cursor = now // Idle: the ticker restarts from here
+	}
+	ticks := int((now.Add(delay).Sub(w.cursor) + w.tick - 1) / w.tick)
+	ticks = max(ticks, 1)
+	slot := (w.pos + ticks) % len(w.slots)
+	w.slots[slot] = append(w.slots[slot], wheelTimer{rounds: (ticks - 1) / len(w.slots), fn: fn})
+	w.count++
+	wasIdle := w.count == 1
+	w.mu.Unlock()
+
+	if wasIdle {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// loop advances the wheel every tick while timers are pending
+func (w *timingWheel) loop() {
+	ticker := time.NewTicker(w.tick)
+	defer ticker.Stop()
+
+	for {
+		w.mu.Lock()
+		idle := w.count == 0
+		w.mu.Unlock()
+		if idle {
+			ticker.Stop()
+			<-w.wake
+			ticker.Reset(w.tick)
+			continue
+		}
+
+		<-ticker.C
+		for _, fn := range w.advance(time.Now()) {
+			w.tasks <- fn
+		}
+	}
+}
+
+// advance fires every slot due by now, returning the callbacks to run
+func (w *timingWheel) advance(now time.Time) []func() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var due []func()
+	for w.count > 0 && !w.cursor.Add(w.tick).After(now) {
+		w.pos = (w.pos + 1) % len(w.slots)
+		w.cursor = w.cursor.Add(w.tick)
+
+		slot := w.slots[w.pos]
+		kept := slot[:0]
+		for _, t := range slot {
+			if t.rounds > 0 {
+				t.rounds--
+				kept = append(kept, t)
+				continue
+			}
+			due = append(due, t.fn)
+			w.count--
+		}
+		clear(slot[len(kept):])
+		w.slots[w.pos] = kept
+	}
+	return due
+}
@@ -0,0 +1,48 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimingWheel(t *testing.T) {
+	w := newTimingWheel(time.Millisecond, 16, 2)
+
+	type fired struct {
+		delay time.Duration
+		late  time.Duration
+	}
+	results := make(chan fired, 8)
+	start := time.Now()
+	// 40ms is more than two turns of a 16-slot wheel
+	delays := []time.Duration{40 * time.Millisecond, 0, 5 * time.Millisecond, 20 * time.Millisecond, 5 * time.Millisecond}
+	for _, d := range delays {
+		w.schedule(d, func() { results <- fired{d, time.Since(start) - d} })
+	}
+
+	var order []time.Duration
+	for range delays {
+		select {
+		case f := <-results:
+			if f.late < 0 {
+				t.Errorf("%v timer fired %v early", f.delay, -f.late)
+			}
+			order = append(order, f.delay)
+		case <-time.After(time.Second):
+			t.Fatalf("timers fired: %v", order)
+		}
+	}
+	for i := 1; i < len(order); i++ {
+		if order[i] < order[i-1] {
+			t.Errorf("fired out of order: %v", order)
+		}
+	}
+
+	// Idle wheels restart from the current time
+	time.Sleep(10 * time.Millisecond)
+	start = time.Now()
+	w.schedule(3*time.Millisecond, func() { results <- fired{3 * time.Millisecond, time.Since(start) - 3*time.Millisecond} })
+	if f := <-results; f.late < 0 {
+		t.Errorf("timer after idle fired %v early", -f.late)
+	}
+}
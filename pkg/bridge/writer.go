@@ -0,0 +1,207 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/recovery"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+const (
+	// DefaultWriteTimeout bounds how long a track write may block before the
+	// transport is considered stalled
+	DefaultWriteTimeout = 2 * time.Second
+
+	// writeQueueSize is how many RTP packets a track writer buffers, enough
+	// for a large keyframe
+	writeQueueSize = 256
+)
+
+// ErrWriteStalled is returned when a packet could not be handed to the track
+// within the write timeout because an earlier write is still blocked
+var ErrWriteStalled = errors.New("track write stalled")
+
+// WriteErrorKind classifies a failed track write
+type WriteErrorKind int
+
+const (
+	WriteErrorNone      WriteErrorKind = iota
+	WriteErrorClosed                   // Track or peer connection closed; expected during teardown
+	WriteErrorStalled                  // Transport stopped accepting packets
+	WriteErrorTransport                // Any other error from the SRTP/DTLS/ICE stack
+)
+
+// ClassifyWriteError maps an error from a track write to its kind
+func ClassifyWriteError(err error) WriteErrorKind {
+	switch {
+	case err == nil:
+		return WriteErrorNone
+	case errors.Is(err, io.ErrClosedPipe), errors.Is(err, io.EOF):
+		return WriteErrorClosed
+	case errors.Is(err, ErrWriteStalled), errors.Is(err, context.DeadlineExceeded):
+		return WriteErrorStalled
+	default:
+		return WriteErrorTransport
+	}
+}
+
+// WriteStats counts failed track writes
+type WriteStats struct {
+	Stalled uint64 // Packets dropped because a write was blocked past the timeout
+	Errors  uint64 // Transport errors reported by the track
+
+	OverBudget uint64 // Video frames dropped because the camera's memory budget was full
+}
+
+// rtpWriter is the part of a pion track a trackWriter needs
+type rtpWriter interface {
+	WriteRTP(p *rtp.Packet) error
+}
+
+// sampleWriter is the part of a pion sample track a trackWriter needs
+type sampleWriter interface {
+	WriteSample(s media.Sample) error
+}
+
+// writeItem is a queued packet, or a sample for sample tracks
+type writeItem struct {
+	packet *rtp.Packet
+	sample media.Sample
+}
+
+// trackWriter moves WriteRTP (or WriteSample) off the pacer, so a
+// transport that stops accepting packets blocks only this goroutine and is
+// noticed within the write timeout instead of hanging the pipeline
+type trackWriter struct {
+	track   rtpWriter
+	samples sampleWriter // Set instead of track for sample tracks
+	kind    string
+	logger  *slog.Logger
+	timeout time.Duration
+	packets chan writeItem
+
+	busySince atomic.Int64 // Unix nanoseconds the current write started; zero when idle
+	pending   atomic.Int64 // Queued or in progress, for flush
+	stalled   atomic.Uint64
+	errors    atomic.Uint64
+
+	// Accepted by the track, i.e. what outbound-rtp stats would report
+	sentPackets atomic.Uint64
+	sentBytes   atomic.Uint64
+	sentFrames  atomic.Uint64 // Packets with the marker bit, or samples
+
+	errMu   sync.Mutex
+	lastErr error // Transport error not yet returned to a caller
+}
+
+func newTrackWriter(track rtpWriter, kind string, timeout time.Duration, logger *slog.Logger) *trackWriter {
+	if timeout <= 0 {
+		timeout = DefaultWriteTimeout
+	}
+	return &trackWriter{
+		track:   track,
+		kind:    kind,
+		logger:  logger,
+		timeout: timeout,
+		packets: make(chan writeItem, writeQueueSize),
+	}
+}
+
+// newSampleTrackWriter creates a writer for a track that packetizes samples
+// itself
+func newSampleTrackWriter(track sampleWriter, kind string, timeout time.Duration, logger *slog.Logger) *trackWriter {
+	w := newTrackWriter(nil, kind, timeout, logger)
+	w.samples = track
+	return w
+}
+
+// run writes queued packets until ctx is done. It is not tracked by the
+// bridge's WaitGroup: a write blocked in the transport only returns once the
+// peer connection is closed, which happens after the WaitGroup is drained.
+func (w *trackWriter) run(ctx context.Context, onPanic func(*recovery.PanicError)) {
+	defer recovery.Recover("bridge.writer."+w.kind, onPanic)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case item := <-w.packets:
+			w.busySince.Store(time.Now().UnixNano())
+			var err error
+			if pkt := item.packet; pkt != nil {
+				if err = w.track.WriteRTP(pkt); err == nil {
+					w.sentPackets.Add(1)
+					w.sentBytes.Add(uint64(pkt.MarshalSize()))
+					if pkt.Marker {
+						w.sentFrames.Add(1)
+					}
+				}
+			} else if err = w.samples.WriteSample(item.sample); err == nil {
+				// Packet counts are the track's own; peer stats report them
+				w.sentBytes.Add(uint64(len(item.sample.Data)))
+				w.sentFrames.Add(1)
+			}
+			w.busySince.Store(0)
+			w.pending.Add(-1)
+
+			if ClassifyWriteError(err) == WriteErrorTransport {
+				if n := w.errors.Add(1); n == 1 || n%100 == 0 {
+					w.logger.Error("track write failed", "track", w.kind, "errors", n, "error", err)
+				}
+				w.errMu.Lock()
+				w.lastErr = err
+				w.errMu.Unlock()
+			}
+		}
+	}
+}
+
+// write queues pkt for the track. It fails with ErrWriteStalled when the
+// queue stays full for the write timeout, and returns the last transport
+// error the writer hit since the previous call, if any.
+func (w *trackWriter) write(pkt *rtp.Packet) error {
+	return w.enqueue(writeItem{packet: pkt})
+}
+
+// writeSample queues a sample for a sample track, like write
+func (w *trackWriter) writeSample(sample media.Sample) error {
+	return w.enqueue(writeItem{sample: sample})
+}
+
+// enqueue queues an item for run, waiting up to the write timeout for room
+func (w *trackWriter) enqueue(item writeItem) error {
+	w.pending.Add(1)
+	select {
+	case w.packets <- item:
+	default:
+		timer := time.NewTimer(w.timeout)
+		defer timer.Stop()
+		select {
+		case w.packets <- item:
+		case <-timer.C:
+			w.pending.Add(-1)
+			w.stalled.Add(1)
+			return ErrWriteStalled
+		}
+	}
+
+	w.errMu.Lock()
+	err := w.lastErr
+	w.lastErr = nil
+	w.errMu.Unlock()
+	return err
+}
+
+// isStalled reports whether the write in progress has been blocked longer
+// than the timeout
+func (w *trackWriter) isStalled(now time.Time) bool {
+	since := w.busySince.Load()
+	return since != 0 && now.Sub(time.Unix(0, since)) > w.timeout
+}
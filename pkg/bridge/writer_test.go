@@ -0,0 +1,62 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// blockingTrack blocks every write until release is closed
+type blockingTrack struct{ release chan struct{} }
+
+func (t *blockingTrack) WriteRTP(*rtp.Packet) error {
+	<-t.release
+	return nil
+}
+
+func TestTrackWriterStall(t *testing.T) {
+	track := &blockingTrack{release: make(chan struct{})}
+	w := newTrackWriter(track, "video", 20*time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.run(ctx, nil)
+
+	// One packet blocks in the transport, the rest fill the queue
+	var err error
+	for i := 0; i <= writeQueueSize+1 && err == nil; i++ {
+		err = w.write(&rtp.Packet{})
+	}
+	if !errors.Is(err, ErrWriteStalled) || w.stalled.Load() != 1 {
+		t.Fatalf("write error = %v, stalled = %d; want ErrWriteStalled once", err, w.stalled.Load())
+	}
+	if !w.isStalled(time.Now()) {
+		t.Error("blocked writer not reported stalled")
+	}
+
+	close(track.release)
+	time.Sleep(50 * time.Millisecond)
+	if w.isStalled(time.Now()) {
+		t.Error("writer still stalled after the transport recovered")
+	}
+}
+
+func TestClassifyWriteError(t *testing.T) {
+	for err, want := range map[error]WriteErrorKind{
+		nil:                                  WriteErrorNone,
+		io.ErrClosedPipe:                     WriteErrorClosed,
+		fmt.Errorf("srtp: %w", io.EOF):       WriteErrorClosed,
+		ErrWriteStalled:                      WriteErrorStalled,
+		errors.New("dtls: handshake failed"): WriteErrorTransport,
+	} {
+		if got := ClassifyWriteError(err); got != want {
+			t.Errorf("ClassifyWriteError(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
@@ -0,0 +1,1100 @@
+// Package camsrelay exposes the complete Nest → Cloudflare relay as a single
+// embeddable Service, so other Go programs can run it in-process instead of
+// shelling out to cmd/relay.
+//
+//	svc, err := camsrelay.New(
+//		camsrelay.WithConfig(cfg),
+//		camsrelay.WithCameras("AVPHwEtYJ6..."),
+//		camsrelay.WithListenAddr(":9090"),
+//	)
+//	if err != nil { ... }
+//	if err := svc.Start(ctx); err != nil { ... }
+//	defer svc.Stop(context.Background())
+package camsrelay
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/alerts"
+	"github.com/ethan/nest-cloudflare-relay/pkg/api"
+	"github.com/ethan/nest-cloudflare-relay/pkg/bridge"
+	"github.com/ethan/nest-cloudflare-relay/pkg/cloudflare"
+	"github.com/ethan/nest-cloudflare-relay/pkg/config"
+	"github.com/ethan/nest-cloudflare-relay/pkg/egress"
+	"github.com/ethan/nest-cloudflare-relay/pkg/ha"
+	"github.com/ethan/nest-cloudflare-relay/pkg/httpx"
+	"github.com/ethan/nest-cloudflare-relay/pkg/livekit"
+	"github.com/ethan/nest-cloudflare-relay/pkg/membudget"
+	"github.com/ethan/nest-cloudflare-relay/pkg/nest"
+	"github.com/ethan/nest-cloudflare-relay/pkg/nest/events"
+	"github.com/ethan/nest-cloudflare-relay/pkg/nestrtc"
+	"github.com/ethan/nest-cloudflare-relay/pkg/plugin"
+	"github.com/ethan/nest-cloudflare-relay/pkg/proxy"
+	"github.com/ethan/nest-cloudflare-relay/pkg/recording"
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+	"github.com/ethan/nest-cloudflare-relay/pkg/replay"
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtmpout"
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtsp"
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtspserver"
+	"github.com/ethan/nest-cloudflare-relay/pkg/sfu"
+	"github.com/ethan/nest-cloudflare-relay/pkg/store"
+	"github.com/ethan/nest-cloudflare-relay/pkg/thumbnail"
+	"github.com/ethan/nest-cloudflare-relay/pkg/timelapse"
+	"github.com/ethan/nest-cloudflare-relay/pkg/transcode"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// shutdownRetryAfter is how long viewers wait before reconnecting after a
+// shutdown notice, roughly a restart plus the first cameras coming up
+const shutdownRetryAfter = 15 * time.Second
+
+// Camera describes a camera selected for relaying
+type Camera struct {
+	DeviceID    string
+	Name        string // Display name, after config and API overrides
+	Order       int    // Display position; zero sorts after positioned cameras
+	Audio       bool   // Audio is forwarded, after config and API overrides
+	VideoCodecs []string
+	AudioCodecs []string
+	Online      bool   // Connectivity trait at discovery
+	Static      bool   // Read from the camera's rtsp_url rather than a Nest stream
+	ProjectID   string // Device Access project the camera belongs to; empty for static cameras
+	WebRTC      bool   // Streams only over WebRTC (battery cameras and doorbells)
+
+	nestName string // Name reported by Nest, restored when overrides are cleared
+}
+
+// Service wires the Nest client, stream manager, multi-camera relay and
+// viewer API into one lifecycle.
+type Service struct {
+	opts   options
+	logger *slog.Logger
+
+	httpClient  *http.Client // Shared by the Nest, Cloudflare and LiveKit clients
+	nestClient  *nest.Client
+	nestClients map[string]*nest.Client         // Every project's client by project ID, nestClient's included
+	registries  map[string]*nest.DeviceRegistry // By project ID; nil unless device_refresh is set
+	cfClient    *cloudflare.Client
+	streamMgr   *nest.MultiStreamManager
+	relay       *relay.MultiCameraRelay
+	backend     sfu.Backend
+	store       store.Store
+	apiServer   *api.Server
+	leader      bool
+	startedAt   time.Time
+	rotation    *nest.RotationScheduler
+	dvr         *recording.DVR
+	thumbnails  *thumbnail.Service
+	rtspServer  *rtspserver.Server // Restreams cameras to local NVRs; nil unless rtsp_server_addr is set
+	timelapse   *timelapse.Service
+	alerts      *alerts.Engine
+	events      *events.Subscriber // Pub/Sub camera events; nil unless pubsub_subscription is set
+	memory      *membudget.Budget  // nil when no memory caps are configured
+	egress      *egress.Meter      // Bytes sent to the SFU, against egress_budget
+
+	mu       sync.RWMutex
+	cameras  []Camera
+	notReady error       // Reported on /readyz; nil between Start and Stop
+	closers  []io.Closer // Recorders owned by the service, closed on Stop
+
+	orphaned map[string]bool // Cameras with leftover goroutines at the last leak check; persistLoop only
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New validates the options and constructs a Service. No network calls are
+// made until Start.
+func New(opts ...Option) (*Service, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if err := o.cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if o.logger == nil {
+		o.logger = slog.Default()
+	}
+	if o.cfg.Stagger != "" {
+		o.streamConfig.Stagger = nest.StaggerStrategy(o.cfg.Stagger)
+	}
+	if o.cfg.StaggerInterval > 0 {
+		o.streamConfig.StaggerInterval = o.cfg.StaggerInterval
+	}
+	if o.lock == nil && o.cfg.HALockPath != "" {
+		o.lock = ha.NewFileLock(o.cfg.HALockPath)
+	}
+
+	s := &Service{
+		opts:     o,
+		logger:   o.logger,
+		notReady: errStarting,
+		egress:   egress.NewMeter(uint64(o.cfg.EgressBudget)),
+	}
+
+	s.nestClient = nest.NewClient(
+		o.cfg.Google.ClientID,
+		o.cfg.Google.ClientSecret,
+		o.cfg.Google.RefreshToken,
+		o.logger.With("component", "nest"),
+	)
+	outbound, err := proxy.Parse(o.cfg.Proxy.URL, o.cfg.Proxy.Bypass)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+	if outbound != nil {
+		o.logger.Info("using outbound proxy for API and RTSP connections", "proxy", outbound.String())
+	}
+	s.httpClient = newHTTPClient(o.cfg.HTTP, outbound)
+	s.nestClient.SetHTTPClient(s.httpClient)
+	s.nestClients = map[string]*nest.Client{o.cfg.Google.ProjectID: s.nestClient}
+
+	s.cfClient = cloudflare.NewClient(
+		o.cfg.Cloudflare.AppID,
+		o.cfg.Cloudflare.APIToken,
+		o.logger.With("component", "cloudflare"),
+	)
+	s.cfClient.SetHTTPClient(s.httpClient)
+
+	s.streamMgr = nest.NewMultiStreamManager(
+		s.nestClient,
+		o.cfg.Google.ProjectID,
+		o.streamConfig,
+		o.logger.With("component", "stream_manager"),
+	)
+	s.streamMgr.KeepStreamsOnStop(o.cfg.ResumeStreams)
+	for _, account := range o.cfg.GoogleAccounts()[1:] {
+		client := nest.NewClient(
+			account.ClientID,
+			account.ClientSecret,
+			account.RefreshToken,
+			o.logger.With("component", "nest", "project_id", account.ProjectID),
+		)
+		client.SetHTTPClient(s.httpClient)
+		s.nestClients[account.ProjectID] = client
+		s.streamMgr.AddProject(client, account.ProjectID)
+	}
+	if o.cfg.DeviceRefresh > 0 && o.cfg.NestEnabled() {
+		s.registries = make(map[string]*nest.DeviceRegistry)
+		for _, account := range o.cfg.GoogleAccounts() {
+			registry, err := s.streamMgr.NewDeviceRegistry(account.ProjectID, o.cfg.DeviceRefresh)
+			if err != nil {
+				return nil, err
+			}
+			registry.OnChange(s.deviceChanged)
+			s.registries[account.ProjectID] = registry
+		}
+	}
+
+	s.backend = s.newBackend()
+	s.relay = relay.NewMultiCameraRelay(
+		s.streamMgr,
+		s.backend,
+		o.logger.With("component", "multi_relay"),
+	)
+	for _, rec := range o.recorders {
+		s.relay.AddRecorder(rec)
+	}
+
+	s.relay.SetReorderDepth(o.cfg.ReorderDepth)
+	bc := bridgeConfig(o.cfg.WebRTC)
+	if cf := o.cfg.Cloudflare; cf.TURNKeyID != "" {
+		turn := cloudflare.NewTURNClient(cf.TURNKeyID, cf.TURNAPIToken, o.logger.With("component", "turn"))
+		turn.SetHTTPClient(s.httpClient)
+		bc.TURN = turn
+	}
+	if err := s.relay.SetBridgeConfig(bc); err != nil {
+		return nil, err
+	}
+	// Cameras without RTSP stream to a receiver using the same ICE servers,
+	// which also offers to send talkback audio where configured
+	iceServers := bc.ICEServers
+	s.streamMgr.SetWebRTCPeerFactory(func(cameraID string) (nest.WebRTCPeer, error) {
+		cam := o.cfg.Camera(cameraID)
+		return nestrtc.NewReceiver(nestrtc.Config{ICEServers: iceServers, Talkback: cam != nil && cam.Talkback, CameraID: cameraID},
+			o.logger.With("camera_id", cameraID, "component", "nest_webrtc"))
+	})
+	for deviceID, cam := range o.cfg.Cameras {
+		if err := pacerConfig(cam).Validate(); err != nil {
+			return nil, fmt.Errorf("camera %s: invalid pacer config: %w", deviceID, err)
+		}
+	}
+	s.relay.SetPacerConfig(func(cameraID, deviceID string) bridge.PacerConfig {
+		return pacerConfig(o.cfg.Camera(deviceID))
+	})
+	s.relay.SetRTSPTransport(func(cameraID, deviceID string) rtsp.Transport {
+		if cam := o.cfg.Camera(deviceID); cam != nil {
+			return rtsp.Transport(cam.RTSPTransport)
+		}
+		return rtsp.TransportTCP
+	})
+	for _, id := range o.cfg.StaticCameras() {
+		s.relay.AddStaticSource(id, relay.URLSource(o.cfg.Camera(id).RTSPURL))
+	}
+	rootCAs := make(map[string]*x509.CertPool) // Device ID -> rtsp_tls_ca, read once
+	for deviceID, cam := range o.cfg.Cameras {
+		if cam.RTSPTLSCA == "" {
+			continue
+		}
+		pool, err := rtsp.LoadRootCAs(cam.RTSPTLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("camera %s rtsp_tls_ca: %w", deviceID, err)
+		}
+		rootCAs[deviceID] = pool
+	}
+	var dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	if outbound != nil {
+		dial = outbound.DialContext
+	}
+	s.relay.SetRTSPOptions(func(cameraID, deviceID string) rtsp.ClientOptions {
+		cam := o.cfg.Camera(deviceID)
+		if cam == nil {
+			return rtsp.ClientOptions{Dial: dial}
+		}
+		method, _ := rtsp.ParseKeepaliveMethod(cam.RTSPKeepaliveMethod) // Checked by config
+		minVersion, _ := rtsp.ParseTLSVersion(cam.RTSPTLSMinVersion)    // Checked by config
+		return rtsp.ClientOptions{
+			ReadTimeout:       cam.RTSPReadTimeout,
+			ResponseTimeout:   cam.RTSPResponseTimeout,
+			WriteTimeout:      cam.RTSPWriteTimeout,
+			KeepaliveInterval: cam.RTSPKeepalive,
+			KeepaliveMethod:   method,
+			ReconnectAttempts: cam.RTSPReconnect,
+			TLS: rtsp.TLSOptions{
+				RootCAs:            rootCAs[deviceID],
+				ServerName:         cam.RTSPTLSServerName,
+				InsecureSkipVerify: cam.RTSPTLSInsecure,
+				MinVersion:         minVersion,
+			},
+			Dial: dial,
+		}
+	})
+
+	if mc := o.cfg.Memory; mc.Enabled() {
+		s.memory = membudget.NewBudget(mc.Budget, mc.CameraLimit)
+		s.relay.SetMemoryBudget(s.memory)
+	}
+
+	// Restream cameras that have an RTMP target configured
+	rtmpTargets := make(map[string]string)
+	for deviceID, cam := range o.cfg.Cameras {
+		if target := cam.RTMPTarget(); target != "" {
+			rtmpTargets[deviceID] = target
+		}
+	}
+	if len(rtmpTargets) > 0 {
+		publisher := rtmpout.NewPublisher(rtmpTargets, o.logger.With("component", "rtmp"))
+		s.relay.AddRecorder(publisher)
+		s.closers = append(s.closers, publisher)
+	}
+
+	// Re-publish every camera over RTSP for local NVRs; it listens on Start
+	if rc := o.cfg.RTSPServer; rc.Enabled() {
+		s.rtspServer = rtspserver.New(rtspserver.Config{
+			Addr:     rc.Addr,
+			Username: rc.Username,
+			Password: rc.Password,
+		}, o.logger.With("component", "rtsp_server"))
+		s.relay.AddRecorder(s.rtspServer)
+		s.closers = append(s.closers, s.rtspServer)
+	}
+
+	// Route cameras with transcoding or audio enabled through a supervised
+	// ffmpeg; it exits straight away for Opus cameras relaying video as is
+	s.relay.SetTranscoderFactory(func(cameraID, deviceID string) relay.Transcoder {
+		cam := o.cfg.Camera(deviceID)
+		audio := s.cameraAudio(deviceID)
+		if !cam.Transcodes() && !audio {
+			return nil
+		}
+		tc := transcode.Config{
+			FFmpegPath: o.cfg.FFmpegPath,
+			Audio:      audio,
+		}
+		if cam != nil && !cam.AudioOnly {
+			tc.Video, tc.VideoBitrate = cam.TranscodeVideo, cam.MaxBitrate
+		}
+		return transcode.New(cameraID, tc, o.logger)
+	})
+	s.relay.SetAudioOnly(func(cameraID, deviceID string) bool {
+		cam := o.cfg.Camera(deviceID)
+		return cam != nil && cam.AudioOnly
+	})
+	s.relay.SetAudioEnabled(func(cameraID, deviceID string) bool {
+		return s.cameraAudio(deviceID)
+	})
+
+	if dir := o.cfg.CaptureDir; dir != "" {
+		capture, err := replay.NewCapture(dir, o.cfg.CaptureLimit, o.logger)
+		if err != nil {
+			return nil, fmt.Errorf("setup capture: %w", err)
+		}
+		s.relay.AddPacketTap(capture)
+		s.closers = append(s.closers, capture)
+	}
+
+	if o.cfg.Recording.Enabled() {
+		if err := s.setupRecording(); err != nil {
+			return nil, fmt.Errorf("setup recording: %w", err)
+		}
+	}
+
+	if o.cfg.DVR.Enabled() {
+		s.dvr = recording.NewDVR(recording.DVRConfig{
+			Window:          o.cfg.DVR.Window,
+			SegmentDuration: o.cfg.DVR.SegmentDuration,
+			Budget:          s.memory,
+		})
+		s.relay.AddRecorder(s.dvr)
+	}
+
+	if tc := o.cfg.Thumbnails; tc.Enabled() {
+		s.thumbnails = thumbnail.New(thumbnail.Config{
+			FFmpegPath: o.cfg.FFmpegPath,
+			Interval:   tc.Interval,
+			Width:      tc.Width,
+			Budget:     s.memory,
+		}, o.logger)
+		s.relay.AddRecorder(s.thumbnails)
+		s.closers = append(s.closers, s.thumbnails)
+	}
+
+	if tc := o.cfg.TimeLapse; tc.Enabled() {
+		dir := tc.Dir
+		if dir == "" {
+			dir = "timelapse"
+		}
+		tl, err := timelapse.New(timelapse.Config{
+			Dir:        dir,
+			Interval:   tc.Interval,
+			Retention:  tc.Retention,
+			FFmpegPath: o.cfg.FFmpegPath,
+		}, o.logger)
+		if err != nil {
+			return nil, fmt.Errorf("setup time-lapse: %w", err)
+		}
+		s.timelapse = tl
+		s.relay.AddRecorder(tl)
+		s.closers = append(s.closers, tl)
+	}
+
+	if o.cfg.Alerts.Enabled() {
+		if err := s.setupAlerts(); err != nil {
+			return nil, fmt.Errorf("setup alerts: %w", err)
+		}
+	}
+
+	if o.cfg.Events.Enabled() {
+		s.events = events.NewSubscriber(events.Config{
+			Subscription: o.cfg.Events.Subscription,
+			Retain:       o.cfg.Events.Retain,
+		}, s.nestClient.AccessToken, o.logger.With("component", "events"))
+		s.events.SetHTTPClient(s.httpClient)
+		for _, fn := range o.eventHandlers {
+			s.events.OnEvent(fn)
+		}
+	}
+
+	if err := s.attachPlugins(); err != nil {
+		return nil, err
+	}
+
+	if o.faults != nil {
+		s.cfClient.SetFaultInjector(o.faults)
+		s.streamMgr.SetFaultInjector(o.faults)
+		s.relay.SetFaultInjector(o.faults)
+	}
+
+	if o.listenAddr != "" {
+		s.apiServer = api.NewServer(
+			s.relay,
+			s.cfClient,
+			o.cfg.Cloudflare.AppID,
+			o.logger.With("component", "api"),
+		)
+		if s.dvr != nil {
+			s.apiServer.SetDVR(s.dvr)
+		}
+		if s.thumbnails != nil {
+			s.apiServer.SetThumbnails(s.thumbnails)
+		}
+		if s.timelapse != nil {
+			s.apiServer.SetTimeLapse(s.timelapse)
+		}
+		if o.cfg.API.AdminToken != "" {
+			s.apiServer.SetAdminToken(o.cfg.API.AdminToken)
+		}
+		if key := o.cfg.API.ViewerTokenKey; key != "" {
+			s.apiServer.SetViewerTokenKey([]byte(key), o.cfg.API.ViewerTokenTTL)
+		}
+		s.apiServer.SetViewerSessionLimits(o.cfg.API.ViewerSessionLimit, o.cfg.API.ViewerIdleTimeout)
+		s.apiServer.SetDiagnostics(s.writeDiagnostics)
+		s.apiServer.SetReadiness(s.Ready)
+		s.apiServer.SetStreams(s.liveStreams)
+		s.apiServer.SetCameraNamer(s.SetCameraName)
+		s.apiServer.SetCameraAudio(s.SetCameraAudio)
+		s.apiServer.SetGoroutines(s.goroutineReport)
+		s.apiServer.SetEgress(func() egress.Usage { return s.egress.Usage(time.Now()) })
+		if s.events != nil {
+			s.apiServer.SetCameraEvents(s.events.Recent)
+			s.events.OnEvent(s.apiServer.NotifyCameraEvent)
+		}
+		if s.events != nil || s.thumbnails != nil {
+			s.apiServer.SetSnapshots(s.snapshot)
+		}
+		for _, cam := range o.cfg.Cameras {
+			if cam.Talkback {
+				s.apiServer.SetTalkback(s.Talk)
+				break
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// newHTTPClient builds the API client shared by the Nest, Cloudflare and
+// LiveKit clients, through outbound when it is set
+func newHTTPClient(hc config.HTTPConfig, outbound *proxy.Proxy) *http.Client {
+	transport := httpx.SharedTransport()
+	if hc.MaxConnsPerHost > 0 || outbound != nil {
+		tc := httpx.TransportConfig{MaxConnsPerHost: hc.MaxConnsPerHost}
+		if outbound != nil {
+			tc.Proxy = outbound.HTTPProxy
+		}
+		transport = httpx.NewTransport(tc)
+	}
+
+	timeout := hc.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	var budget *httpx.RetryBudget
+	if hc.RetryBudget > 0 {
+		budget = httpx.NewRetryBudget(hc.RetryBudget, 10)
+	}
+
+	return httpx.NewClient(httpx.Config{
+		Timeout:   timeout,
+		Transport: transport,
+		Retries:   hc.Retries,
+		Budget:    budget,
+	})
+}
+
+// bridgeConfig converts the webrtc_* keys to the bridge's options
+func bridgeConfig(wc config.WebRTCConfig) bridge.BridgeConfig {
+	cfg := bridge.BridgeConfig{
+		MTU:              wc.MTU,
+		VideoPayloadType: wc.VideoPayloadType,
+		AudioPayloadType: wc.AudioPayloadType,
+		H264FmtpLine:     wc.H264Fmtp,
+		SampleTrack:      wc.SampleTrack,
+	}
+	if len(wc.ICEServers) > 0 {
+		cfg.ICEServers = []webrtc.ICEServer{{URLs: wc.ICEServers}}
+	}
+	if len(wc.TURNURLs) > 0 {
+		if len(cfg.ICEServers) == 0 {
+			cfg.ICEServers = bridge.DefaultICEServers
+		}
+		cfg.ICEServers = append(slices.Clip(cfg.ICEServers), webrtc.ICEServer{
+			URLs:       wc.TURNURLs,
+			Username:   wc.TURNUsername,
+			Credential: wc.TURNCredential,
+		})
+	}
+	return cfg
+}
+
+// pacerConfig converts a camera's pacer_* keys to the bridge's options
+func pacerConfig(cam *config.CameraConfig) bridge.PacerConfig {
+	if cam == nil {
+		return bridge.PacerConfig{}
+	}
+	return bridge.PacerConfig{
+		VideoQueueSize:   cam.PacerQueue,
+		AudioQueueSize:   cam.PacerQueue,
+		CatchupThreshold: cam.PacerCatchupThreshold,
+		CatchupSpeed:     cam.PacerCatchupSpeed,
+		MaxDelay:         cam.PacerMaxDelay,
+		LatencyBudget:    cam.PacerLatencyBudget,
+		Bypass:           cam.PacerBypass,
+	}
+}
+
+// newBackend builds the SFU backend selected by the config
+func (s *Service) newBackend() sfu.Backend {
+	if s.opts.backend != nil {
+		return s.opts.backend
+	}
+
+	if s.opts.cfg.SFU.Backend == config.SFULiveKit {
+		lk := s.opts.cfg.SFU.LiveKit
+		client := livekit.NewClient(lk.URL, lk.APIKey, lk.APISecret, s.logger.With("component", "livekit"))
+		client.SetHTTPClient(s.httpClient)
+		return livekit.NewBackend(client, lk.Room)
+	}
+
+	return cloudflare.NewBackend(s.cfClient)
+}
+
+// attachPlugins hooks compiled-in, WithPlugin and plugin_rpc plugins into
+// every camera relay
+func (s *Service) attachPlugins() error {
+	for _, p := range append(plugin.Registered(), s.opts.plugins...) {
+		if !plugin.Attach(s.relay, p) {
+			return fmt.Errorf("plugin %q implements no relay hooks", p.Name())
+		}
+		s.logger.Info("plugin attached", "plugin", p.Name())
+	}
+
+	for _, addr := range s.opts.cfg.PluginRPC {
+		network, address, _ := strings.Cut(addr, ":")
+		rp := plugin.NewRPC(network, address, s.logger)
+		plugin.Attach(s.relay, rp)
+		s.closers = append(s.closers, rp)
+		s.logger.Info("plugin attached", "plugin", rp.Name())
+	}
+	return nil
+}
+
+// setupRecording writes segments to disk and, when a bucket is configured,
+// archives them to object storage
+func (s *Service) setupRecording() error {
+	rc := s.opts.cfg.Recording
+
+	recorder, err := recording.NewRecorder(recording.Config{
+		Dir:             rc.Dir,
+		SegmentDuration: rc.SegmentDuration,
+	}, s.logger.With("component", "recorder"))
+	if err != nil {
+		return err
+	}
+
+	var uploader *recording.Uploader
+	if rc.Upload.Enabled() {
+		up := rc.Upload
+		storeCfg := recording.S3Config{
+			Endpoint:     up.Endpoint,
+			Region:       up.Region,
+			Bucket:       up.Bucket,
+			AccessKey:    up.AccessKey,
+			SecretKey:    up.SecretKey,
+			StorageClass: up.StorageClass,
+		}
+		if up.Provider == "gcs" {
+			if storeCfg.Endpoint == "" {
+				storeCfg.Endpoint = "https://storage.googleapis.com"
+			}
+			if storeCfg.Region == "" {
+				storeCfg.Region = "auto"
+			}
+		}
+
+		store, err := recording.NewS3Store(storeCfg)
+		if err != nil {
+			return fmt.Errorf("object store: %w", err)
+		}
+
+		uploader = recording.NewUploader(store, rc.Dir, recording.UploaderConfig{
+			Prefix:         up.Prefix,
+			LocalRetention: up.LocalRetention,
+		}, s.logger.With("component", "uploader"))
+		recorder.OnSegment = uploader.Enqueue
+	}
+
+	s.relay.AddRecorder(recorder)
+
+	// Recorder closes first so its final segments are queued (or left on
+	// disk for the next run) before the uploader stops
+	s.closers = append(s.closers, recorder)
+	if uploader != nil {
+		s.closers = append(s.closers, uploader)
+	}
+	return nil
+}
+
+// setupAlerts builds the alert engine with the configured notifiers
+func (s *Service) setupAlerts() error {
+	ac := s.opts.cfg.Alerts
+
+	var notifiers []alerts.Notifier
+	if ac.WebhookURL != "" {
+		notifiers = append(notifiers, alerts.NewWebhookNotifier(ac.WebhookURL))
+	}
+	if ac.MQTTURL != "" {
+		topic := ac.MQTTTopic
+		if topic == "" {
+			topic = "camsrelay/alerts"
+		}
+		n, err := alerts.NewMQTTNotifier(ac.MQTTURL, topic)
+		if err != nil {
+			return err
+		}
+		notifiers = append(notifiers, n)
+	}
+	if ac.SMTPAddr != "" {
+		notifiers = append(notifiers, alerts.NewEmailNotifier(ac.SMTPAddr, ac.SMTPUser, ac.SMTPPass, ac.EmailFrom, ac.EmailTo))
+	}
+
+	th := alerts.DefaultThresholds()
+	if ac.DegradedFor > 0 {
+		th.DegradedFor = ac.DegradedFor
+	}
+	if ac.MinFPS > 0 {
+		th.MinFPS = ac.MinFPS
+	}
+	if ac.KeyframeTimeout > 0 {
+		th.KeyframeTimeout = ac.KeyframeTimeout
+	}
+	if ac.QuotaUsage > 0 {
+		th.QuotaUsage = ac.QuotaUsage
+	}
+
+	s.alerts = alerts.NewEngine(
+		s.streamMgr,
+		s.relay,
+		alerts.DefaultRules(th),
+		notifiers,
+		s.logger.With("component", "alerts"),
+	)
+	return nil
+}
+
+// Start discovers cameras, starts the HTTP server and relay, and kicks off
+// staggered stream generation in the background. It returns once the
+// pipeline is running; cameras come online over the following minutes.
+//
+// With a leader lock configured, Start first blocks (until ctx is done)
+// while another instance is active. With the self-test enabled, Start also
+// waits for the first camera to reach the SFU and fails if it doesn't; call
+// Stop to release what was started.
+func (s *Service) Start(ctx context.Context) error {
+	if err := s.becomeLeader(ctx); err != nil {
+		return err
+	}
+
+	// Opened after election so a standby can share the leader's state file
+	st, err := s.openStore()
+	if err != nil {
+		return err
+	}
+	s.store = st
+	s.startedAt = time.Now().UTC()
+	s.restoreEgress(s.startedAt)
+	s.restoreTokens(s.startedAt)
+
+	if s.opts.cfg.SelfTest {
+		if err := s.selfTestAPIs(ctx); err != nil {
+			return fmt.Errorf("self-test: %w", err)
+		}
+	}
+
+	cameras, err := s.discoverCameras(ctx)
+	if err != nil {
+		return err
+	}
+	cameras = s.takeOver(ctx, cameras)
+	s.applyNames(cameras)
+
+	var cameraIDs []string // Nest cameras, which the stream manager starts
+	perProject := make(map[string]int)
+	for _, cam := range cameras {
+		if !cam.Static {
+			cameraIDs = append(cameraIDs, cam.DeviceID)
+			perProject[cam.ProjectID]++
+		}
+	}
+	// Each project has its own quota
+	for _, account := range s.opts.cfg.GoogleAccounts() {
+		if err := s.checkStartupPlan(account.ProjectID, perProject[account.ProjectID]); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.cameras = cameras
+	s.mu.Unlock()
+
+	// Start HTTP server before cameras so the viewer is available immediately
+	if s.apiServer != nil {
+		s.apiServer.SetStore(st)
+		for _, cam := range cameras {
+			s.apiServer.SetCameraName(cam.DeviceID, cam.Name)
+			s.apiServer.SetCameraOrder(cam.DeviceID, cam.Order)
+		}
+		if err := s.apiServer.Start(ctx, s.opts.listenAddr); err != nil {
+			return fmt.Errorf("start API server: %w", err)
+		}
+	}
+
+	if s.rtspServer != nil {
+		if err := s.rtspServer.Start(); err != nil {
+			return fmt.Errorf("start RTSP server: %w", err)
+		}
+	}
+
+	if err := s.relay.Start(ctx); err != nil {
+		return fmt.Errorf("start relay: %w", err)
+	}
+
+	if s.alerts != nil {
+		for _, cam := range cameras {
+			s.alerts.SetCameraName(cam.DeviceID, cam.Name)
+		}
+		s.alerts.Start()
+	}
+
+	if s.events != nil {
+		s.events.Start()
+	}
+
+	for _, cam := range cameras {
+		if cam.WebRTC && !cam.Static {
+			s.streamMgr.UseWebRTC(cam.DeviceID)
+		}
+		if !cam.Online && !cam.Static {
+			s.streamMgr.MarkOffline(cam.DeviceID)
+		}
+	}
+
+	startCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go s.persistLoop(startCtx)
+
+	if rc := s.opts.cfg.Rotation; rc.Slots > 0 && len(cameraIDs) > rc.Slots {
+		s.startRotation(cameraIDs)
+	} else {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if err := s.streamMgr.StartCameras(startCtx, cameraIDs); err != nil && startCtx.Err() == nil {
+				s.logger.Error("failed to start cameras", "error", err)
+			}
+		}()
+	}
+
+	for _, registry := range s.registries {
+		registry.Start()
+	}
+
+	if s.opts.cfg.SelfTest {
+		if err := s.selfTestPipeline(ctx); err != nil {
+			s.setNotReady(fmt.Errorf("self-test failed: %w", err))
+			return fmt.Errorf("self-test: %w", err)
+		}
+	}
+	s.setNotReady(nil)
+
+	s.recordEvent("", "service_started", fmt.Sprintf("relaying %d cameras", len(cameras)))
+
+	s.logger.Info("relay service started",
+		"cameras", len(cameras),
+		"listen_addr", s.opts.listenAddr,
+		"qpm_limit", s.opts.streamConfig.QPM,
+		"stagger", s.opts.streamConfig.Stagger)
+
+	return nil
+}
+
+// Stop shuts down the service in order: viewers are told the relay is going
+// away, the HTTP server stops, every camera relay closes its SFU tracks and
+// then its PeerConnection, and finally the Nest streams are stopped through
+// the rate-limited command queue; with resume_streams, RTSP streams are
+// left running and saved for the next Start instead.
+func (s *Service) Stop(ctx context.Context) error {
+	s.setNotReady(errShuttingDown)
+	if s.apiServer != nil {
+		s.apiServer.NotifyShutdown("relay shutting down", shutdownRetryAfter)
+	}
+
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+
+	for _, registry := range s.registries {
+		registry.Stop()
+	}
+
+	if s.rotation != nil {
+		s.rotation.Stop()
+	}
+
+	if s.alerts != nil {
+		s.alerts.Stop()
+	}
+
+	if s.events != nil {
+		s.events.Stop()
+	}
+
+	if s.apiServer != nil {
+		if err := s.apiServer.Stop(ctx); err != nil {
+			s.logger.Error("error stopping API server", "error", err)
+		}
+	}
+
+	if err := s.relay.Stop(); err != nil {
+		return fmt.Errorf("stop relay: %w", err)
+	}
+
+	for _, c := range s.closers {
+		if err := c.Close(); err != nil {
+			s.logger.Error("error closing recorder", "error", err)
+		}
+	}
+
+	if s.store != nil {
+		if s.opts.cfg.ResumeStreams {
+			s.persistStreamTokens(time.Now())
+		} else {
+			s.clearStreamTokens()
+		}
+		s.recordEvent("", "service_stopped", "")
+		if s.opts.store == nil {
+			if err := s.store.Close(); err != nil {
+				s.logger.Error("error closing state store", "error", err)
+			}
+		}
+	}
+
+	if s.leader {
+		if err := s.opts.lock.Release(); err != nil {
+			s.logger.Error("error releasing leader lock", "error", err)
+		}
+		s.leader = false
+	}
+
+	s.logger.Info("relay service stopped")
+	return nil
+}
+
+// checkStartupPlan logs how long a project's startup will take and refuses
+// fleets whose stream extensions alone would exceed its SDM quota, unless
+// overridden
+func (s *Service) checkStartupPlan(projectID string, cameras int) error {
+	if rc := s.opts.cfg.Rotation; rc.Slots > 0 && cameras > rc.Slots {
+		cameras = rc.Slots // Only the active set is extended
+	}
+	plan := nest.PlanStartup(cameras, s.opts.streamConfig)
+
+	logger := s.logger.With(
+		"project_id", projectID,
+		"cameras", plan.Cameras,
+		"qpm_limit", plan.QPM,
+		"extension_qpm", fmt.Sprintf("%.1f", plan.ExtensionQPM),
+		"max_cameras", plan.MaxCameras())
+
+	switch {
+	case plan.OverQuota() && !s.opts.cfg.AllowOverQuota:
+		return fmt.Errorf("startup plan: %w (set allow_over_quota=true to start anyway)", plan.Err())
+	case plan.OverQuota():
+		logger.Warn("starting over quota: streams will expire before they can be extended", "error", plan.Err())
+	case plan.Tight():
+		logger.Warn("startup plan leaves little quota for stream recovery",
+			"expected_startup", plan.StartupDuration.Round(time.Second))
+	default:
+		logger.Info("startup plan",
+			"expected_startup", plan.StartupDuration.Round(time.Second))
+	}
+	return nil
+}
+
+// startRotation relays a rotating subset of cameras
+func (s *Service) startRotation(cameraIDs []string) {
+	rc := s.opts.cfg.Rotation
+
+	priority := make(map[string]int)
+	for id, cam := range s.opts.cfg.Cameras {
+		if cam.Priority != 0 {
+			priority[id] = cam.Priority
+		}
+	}
+
+	s.rotation = nest.NewRotationScheduler(s.streamMgr, cameraIDs, nest.RotationConfig{
+		Slots:    rc.Slots,
+		Interval: rc.Interval,
+		Priority: priority,
+	}, s.logger.With("component", "rotation"))
+	s.rotation.Demand = s.opts.demand
+	s.rotation.Start()
+}
+
+// Cameras returns the cameras selected at Start
+func (s *Service) Cameras() []Camera {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Camera(nil), s.cameras...)
+}
+
+// Relay returns the underlying multi-camera relay for stats and recorders
+func (s *Service) Relay() *relay.MultiCameraRelay {
+	return s.relay
+}
+
+// Egress returns the per-camera egress meter
+func (s *Service) Egress() *egress.Meter {
+	return s.egress
+}
+
+// DVR returns the in-memory rewind buffer, or nil when disabled
+func (s *Service) DVR() *recording.DVR {
+	return s.dvr
+}
+
+// Thumbnails returns the camera thumbnail cache, or nil when disabled
+func (s *Service) Thumbnails() *thumbnail.Service {
+	return s.thumbnails
+}
+
+// Alerts returns the alert engine, or nil when no notifier is configured
+func (s *Service) Alerts() *alerts.Engine {
+	return s.alerts
+}
+
+// TimeLapse returns the time-lapse keyframe store, or nil when disabled
+func (s *Service) TimeLapse() *timelapse.Service {
+	return s.timelapse
+}
+
+// Events returns the Pub/Sub camera event subscriber, or nil when
+// pubsub_subscription is not set
+func (s *Service) Events() *events.Subscriber {
+	return s.events
+}
+
+// Store returns the persistent state store, or nil before Start
+func (s *Service) Store() store.Store {
+	return s.store
+}
+
+// MemoryBudget returns the buffer memory budget, or nil when uncapped
+func (s *Service) MemoryBudget() *membudget.Budget {
+	return s.memory
+}
+
+// StreamManager returns the underlying Nest stream manager
+func (s *Service) StreamManager() *nest.MultiStreamManager {
+	return s.streamMgr
+}
+
+// discoverCameras lists the projects' devices and applies the camera filter
+func (s *Service) discoverCameras(ctx context.Context) ([]Camera, error) {
+	cameras, err := s.discoverNestCameras(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range s.opts.cfg.StaticCameras() {
+		cam := Camera{DeviceID: id, Name: id, nestName: id, Online: true, Static: true}
+		cameras = append(cameras, cam)
+		s.logger.Info("static camera available", "device_id", id)
+	}
+
+	if len(cameras) == 0 {
+		return nil, fmt.Errorf("no cameras found")
+	}
+	return cameras, nil
+}
+
+// discoverNestCameras lists the cameras of every configured project; none
+// when only static cameras are configured
+func (s *Service) discoverNestCameras(ctx context.Context) ([]Camera, error) {
+	if !s.opts.cfg.NestEnabled() {
+		return nil, nil
+	}
+
+	wanted := make(map[string]bool, len(s.opts.cameraIDs))
+	for _, id := range s.opts.cameraIDs {
+		wanted[id] = true
+	}
+
+	var cameras []Camera
+	for i, account := range s.opts.cfg.GoogleAccounts() {
+		devices, err := s.nestClients[account.ProjectID].ListDevices(ctx, account.ProjectID)
+		if err != nil {
+			return nil, fmt.Errorf("list devices of project %s: %w", account.ProjectID, err)
+		}
+
+		s.logger.Info("discovered cameras", "project_id", account.ProjectID, "count", len(devices))
+		if registry := s.registries[account.ProjectID]; registry != nil {
+			registry.Seed(devices)
+		}
+
+		for _, device := range devices {
+			if len(wanted) > 0 && !wanted[device.DeviceID] {
+				continue
+			}
+			if s.opts.maxCameras > 0 && len(cameras) >= s.opts.maxCameras {
+				break
+			}
+			if i > 0 {
+				// The first project is the stream manager's default
+				if err := s.streamMgr.AssignCamera(device.DeviceID, account.ProjectID); err != nil {
+					return nil, err
+				}
+			}
+
+			cam := nestCamera(account.ProjectID, device)
+			cameras = append(cameras, cam)
+
+			s.logger.Info("camera available",
+				"index", len(cameras),
+				"device_id", cam.DeviceID,
+				"name", cam.Name,
+				"project_id", cam.ProjectID,
+				"protocols", device.Traits.CameraLiveStream.SupportedProtocols,
+				"video_codecs", cam.VideoCodecs,
+				"audio_codecs", cam.AudioCodecs,
+				"online", cam.Online,
+			)
+		}
+	}
+
+	for id := range wanted {
+		found := false
+		for _, cam := range cameras {
+			if cam.DeviceID == id {
+				found = true
+				break
+			}
+		}
+		if cc := s.opts.cfg.Camera(id); !found && (cc == nil || cc.RTSPURL == "") {
+			s.logger.Warn("requested camera not found in any project", "device_id", id)
+		}
+	}
+
+	return cameras, nil
+}
+
+// nestCamera describes a device listed by projectID
+func nestCamera(projectID string, device nest.Device) Camera {
+	return Camera{
+		DeviceID:    device.DeviceID,
+		Name:        device.DisplayName(),
+		nestName:    device.DisplayName(),
+		VideoCodecs: device.Traits.CameraLiveStream.VideoCodecs,
+		AudioCodecs: device.Traits.CameraLiveStream.AudioCodecs,
+		Online:      device.Online(),
+		ProjectID:   projectID,
+		WebRTC:      device.WebRTCOnly(),
+	}
+}
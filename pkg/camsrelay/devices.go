@@ -0,0 +1,149 @@
+package camsrelay
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/nest"
+	"github.com/ethan/nest-cloudflare-relay/pkg/store"
+)
+
+// deviceChanged applies a change the device registries found: added
+// cameras are started, removed ones stopped, and Nest renames reach viewers
+// unless a configured or saved name overrides them
+func (s *Service) deviceChanged(c nest.DeviceChange) {
+	switch c.Type {
+	case nest.DeviceAdded:
+		s.addCamera(c.ProjectID, c.Device)
+	case nest.DeviceRemoved:
+		s.removeCamera(c.Device.DeviceID)
+	case nest.DeviceRenamed:
+		s.renameCamera(c.Device)
+	}
+}
+
+// addCamera relays a camera added to a project while running, within the
+// camera filter, the camera limit and the project's quota
+func (s *Service) addCamera(projectID string, device nest.Device) {
+	if len(s.opts.cameraIDs) > 0 && !slices.Contains(s.opts.cameraIDs, device.DeviceID) {
+		return
+	}
+	logger := s.logger.With("camera_id", device.DeviceID, "project_id", projectID)
+
+	cam := nestCamera(projectID, device)
+	var saved store.CameraSettings
+	if _, err := s.store.Get(store.BucketCameras, cam.DeviceID, &saved); err != nil {
+		logger.Warn("failed to read camera settings", "error", err)
+	}
+	s.resolveName(&cam, saved)
+
+	s.mu.Lock()
+	relayed, inProject := 0, 0
+	for _, c := range s.cameras {
+		if c.DeviceID == cam.DeviceID {
+			s.mu.Unlock()
+			return
+		}
+		if !c.Static {
+			relayed++
+		}
+		if c.ProjectID == projectID {
+			inProject++
+		}
+	}
+	if s.opts.maxCameras > 0 && relayed >= s.opts.maxCameras {
+		s.mu.Unlock()
+		logger.Warn("camera added to project but the camera limit is reached", "max_cameras", s.opts.maxCameras)
+		return
+	}
+	if plan := nest.PlanStartup(inProject+1, s.opts.streamConfig); plan.OverQuota() && !s.opts.cfg.AllowOverQuota && s.rotation == nil {
+		s.mu.Unlock()
+		logger.Warn("camera added to project but relaying it would exceed the quota", "error", plan.Err())
+		return
+	}
+	s.cameras = append(s.cameras, cam)
+	s.mu.Unlock()
+
+	if projectID != s.opts.cfg.Google.ProjectID {
+		if err := s.streamMgr.AssignCamera(cam.DeviceID, projectID); err != nil {
+			logger.Error("failed to assign camera to its project", "error", err)
+			return
+		}
+	}
+	if cam.WebRTC {
+		s.streamMgr.UseWebRTC(cam.DeviceID)
+	}
+	if !cam.Online {
+		s.streamMgr.MarkOffline(cam.DeviceID)
+	}
+	if s.apiServer != nil {
+		s.apiServer.SetCameraName(cam.DeviceID, cam.Name)
+		s.apiServer.SetCameraOrder(cam.DeviceID, cam.Order)
+	}
+	if s.alerts != nil {
+		s.alerts.SetCameraName(cam.DeviceID, cam.Name)
+	}
+
+	if s.rotation != nil {
+		// The scheduler's camera set is fixed when it starts
+		logger.Info("camera added to project; it joins the rotation on restart", "name", cam.Name)
+	} else {
+		s.streamMgr.StartCamera(cam.DeviceID)
+		logger.Info("camera added to project, starting", "name", cam.Name)
+	}
+	s.recordEvent(cam.DeviceID, "camera_added", cam.Name)
+}
+
+// removeCamera stops relaying a camera removed from its project
+func (s *Service) removeCamera(deviceID string) {
+	s.mu.Lock()
+	idx := slices.IndexFunc(s.cameras, func(c Camera) bool { return c.DeviceID == deviceID && !c.Static })
+	if idx < 0 {
+		s.mu.Unlock()
+		return
+	}
+	s.cameras = slices.Delete(s.cameras, idx, idx+1)
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.streamMgr.StopCamera(ctx, deviceID); err != nil {
+		s.logger.Warn("failed to stop removed camera's stream", "camera_id", deviceID, "error", err)
+	}
+	s.logger.Info("camera removed from project, stopped", "camera_id", deviceID)
+	s.recordEvent(deviceID, "camera_removed", "")
+}
+
+// renameCamera picks up a camera's new Nest name. Names set in the config
+// or through the API still win.
+func (s *Service) renameCamera(device nest.Device) {
+	var saved store.CameraSettings
+	if _, err := s.store.Get(store.BucketCameras, device.DeviceID, &saved); err != nil {
+		s.logger.Warn("failed to read camera settings", "camera_id", device.DeviceID, "error", err)
+	}
+
+	s.mu.Lock()
+	idx := slices.IndexFunc(s.cameras, func(c Camera) bool { return c.DeviceID == device.DeviceID && !c.Static })
+	if idx < 0 {
+		s.mu.Unlock()
+		return
+	}
+	before := s.cameras[idx].Name
+	s.cameras[idx].nestName = device.DisplayName()
+	s.resolveName(&s.cameras[idx], saved)
+	cam := s.cameras[idx]
+	s.mu.Unlock()
+
+	if cam.Name == before {
+		return // Overridden
+	}
+	if s.apiServer != nil {
+		s.apiServer.SetCameraName(cam.DeviceID, cam.Name)
+	}
+	if s.alerts != nil {
+		s.alerts.SetCameraName(cam.DeviceID, cam.Name)
+	}
+	s.logger.Info("camera renamed in Nest", "camera_id", cam.DeviceID, "name", cam.Name)
+	s.recordEvent(cam.DeviceID, "camera_renamed", cam.Name)
+}
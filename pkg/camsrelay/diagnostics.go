@@ -0,0 +1,289 @@
+package camsrelay
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+	"github.com/ethan/nest-cloudflare-relay/pkg/store"
+)
+
+const (
+	diagnosticsStatsWindow  = time.Hour      // Stats history included in a bundle
+	diagnosticsEventsWindow = 24 * time.Hour // Event history included in a bundle
+)
+
+// diagnosticsVersion is version.json in a diagnostic bundle
+type diagnosticsVersion struct {
+	Module      string            `json:"module"`
+	Version     string            `json:"version"`
+	GoVersion   string            `json:"goVersion"`
+	OS          string            `json:"os"`
+	Arch        string            `json:"arch"`
+	Build       map[string]string `json:"build,omitempty"` // vcs.revision, vcs.time, ...
+	StartedAt   time.Time         `json:"startedAt"`
+	Uptime      string            `json:"uptime"`
+	Goroutines  int               `json:"goroutines"`
+	Leader      bool              `json:"leader"`
+	GeneratedAt time.Time         `json:"generatedAt"`
+}
+
+// diagnosticsStream is one entry of streams.json
+type diagnosticsStream struct {
+	CameraID      string    `json:"cameraId"`
+	ProjectID     string    `json:"projectId"`
+	State         string    `json:"state"`
+	FailureCount  int       `json:"failureCount"`
+	LastError     string    `json:"lastError,omitempty"`
+	LastAttempt   time.Time `json:"lastAttempt"`
+	CreatedAt     time.Time `json:"createdAt"`
+	LastExtension time.Time `json:"lastExtension"`
+	StreamExpiry  time.Time `json:"streamExpiry"`
+}
+
+// historyEntry is one line of the history files
+type historyEntry struct {
+	At   time.Time       `json:"at"`
+	Data json.RawMessage `json:"data"`
+}
+
+// writeDiagnostics fills a support bundle: version info, the redacted config,
+// relay and stream state, recent stats and events, and buffered logs
+func (s *Service) writeDiagnostics(zw *zip.Writer) error {
+	now := time.Now().UTC()
+
+	files := []struct {
+		name string
+		v    any
+	}{
+		{"version.json", s.diagnosticsVersion(now)},
+		{"config.json", s.opts.cfg.Redacted()},
+		{"cameras.json", s.Cameras()},
+		{"stats.json", map[string]any{
+			"relays":     s.relay.GetRelayStats(),
+			"aggregate":  s.relay.GetAggregateStats(),
+			"memory":     s.memory.Stats(),
+			"goroutines": s.goroutineReport(),
+		}},
+		{"streams.json", s.diagnosticsStreams()},
+		{"queue.json", s.streamMgr.GetQueueStats()},
+	}
+	for _, f := range files {
+		if err := writeZipJSON(zw, f.name, f.v); err != nil {
+			return err
+		}
+	}
+
+	if s.store != nil {
+		history := []struct {
+			name   string
+			bucket string
+			window time.Duration
+		}{
+			{"history/stats.jsonl", store.BucketStats, diagnosticsStatsWindow},
+			{"history/events.jsonl", store.BucketEvents, diagnosticsEventsWindow},
+		}
+		for _, h := range history {
+			if err := s.writeZipHistory(zw, h.name, h.bucket, now.Add(-h.window), now); err != nil {
+				return err
+			}
+		}
+	}
+
+	w, err := zw.Create("goroutines.txt")
+	if err != nil {
+		return fmt.Errorf("create goroutines.txt: %w", err)
+	}
+	if err := pprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+		return fmt.Errorf("write goroutines.txt: %w", err)
+	}
+
+	if s.opts.logRing != nil {
+		w, err := zw.Create("logs.jsonl")
+		if err != nil {
+			return fmt.Errorf("create logs.jsonl: %w", err)
+		}
+		for _, line := range s.opts.logRing.Lines() {
+			if _, err := w.Write(append(line, '\n')); err != nil {
+				return fmt.Errorf("write logs.jsonl: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// DumpDiagnostics logs a summary of every relay, pacer and stream and the
+// command queue, then writes a full diagnostic bundle (including every
+// goroutine's stack) to dump_dir. It gives operators a snapshot of a
+// misbehaving relay when the admin API isn't reachable; cmd/relay calls it
+// on SIGUSR1. It returns the bundle's path.
+func (s *Service) DumpDiagnostics() (string, error) {
+	now := time.Now().UTC()
+	s.logDiagnostics()
+
+	dir := s.opts.cfg.DumpDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create dump directory: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("camsrelay-diagnostics-%s.zip", now.Format("20060102-150405")))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("create dump: %w", err)
+	}
+
+	zw := zip.NewWriter(f)
+	err = s.writeDiagnostics(zw)
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("write dump: %w", err)
+	}
+	return path, nil
+}
+
+// logDiagnostics logs one line per stream and relay, and the queue
+func (s *Service) logDiagnostics() {
+	logger := s.logger.With("component", "dump")
+
+	agg := s.relay.GetAggregateStats()
+	q := s.streamMgr.GetQueueStats()
+	logger.Info("diagnostics snapshot",
+		"goroutines", runtime.NumGoroutine(),
+		"relays", agg.TotalRelays,
+		"relays_connected", agg.ConnectedRelays,
+		"queue_depth", q.QueueDepth,
+		"queue_executed", q.TotalExecuted,
+		"queue_failed", q.TotalFailed,
+		"queue_avg_wait_ms", q.AvgWaitTime.Milliseconds(),
+		"queue_quota_errors", q.QuotaErrors)
+
+	for _, st := range s.diagnosticsStreams() {
+		logger.Info("diagnostics stream",
+			"camera_id", st.CameraID,
+			"state", st.State,
+			"failure_count", st.FailureCount,
+			"last_error", st.LastError,
+			"stream_expiry", st.StreamExpiry)
+	}
+	for _, rs := range s.relay.GetRelayStats() {
+		logger.Info("diagnostics relay",
+			"camera_id", rs.CameraID,
+			"session_id", rs.SessionID,
+			"generation", rs.Generation,
+			"video_codec", rs.VideoCodec,
+			"webrtc_state", rs.WebRTCState,
+			"uptime", rs.Uptime.Round(time.Second),
+			"video_frames", rs.VideoFrames,
+			"audio_frames", rs.AudioFrames,
+			"last_keyframe", rs.LastKeyframe,
+			"video_silent", rs.VideoSilent,
+			"rtsp_read_timeouts", rs.RTSP.ReadTimeouts,
+			"pacer_video_queue", rs.Pacer.VideoQueueDepth,
+			"pacer_audio_queue", rs.Pacer.AudioQueueDepth,
+			"pacer_video_sent", rs.Pacer.VideoPacketsSent,
+			"pacer_catchups", rs.Pacer.VideoCatchupEvents,
+			"pacer_dropped", rs.Pacer.VideoDroppedFrames+rs.Pacer.VideoDroppedKeyframes+rs.Pacer.VideoDroppedGOP,
+			"stalled_writes", rs.Writes.Stalled,
+			"degrade_level", rs.Bandwidth.Level.String(),
+			"degrade_dropped", rs.Bandwidth.Dropped,
+			"video_nacks", rs.WebRTC.Video.NACKs,
+			"video_packets_lost", rs.WebRTC.Video.PacketsLost,
+			"video_rr_rtt", rs.WebRTC.Video.RTT,
+			"goroutines", goroutines.Camera(rs.CameraID))
+	}
+}
+
+// diagnosticsVersion describes the running binary
+func (s *Service) diagnosticsVersion(now time.Time) diagnosticsVersion {
+	v := diagnosticsVersion{
+		GoVersion:   runtime.Version(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		StartedAt:   s.startedAt,
+		Goroutines:  runtime.NumGoroutine(),
+		Leader:      s.leader,
+		GeneratedAt: now,
+	}
+	if !s.startedAt.IsZero() {
+		v.Uptime = now.Sub(s.startedAt).Round(time.Second).String()
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		v.Module = info.Main.Path
+		v.Version = info.Main.Version
+		v.Build = make(map[string]string)
+		for _, setting := range info.Settings {
+			v.Build[setting.Key] = setting.Value
+		}
+	}
+	return v
+}
+
+// diagnosticsStreams converts stream statuses to a serializable form
+func (s *Service) diagnosticsStreams() []diagnosticsStream {
+	statuses := s.streamMgr.GetStreamStatus()
+	streams := make([]diagnosticsStream, 0, len(statuses))
+	for _, st := range statuses {
+		ds := diagnosticsStream{
+			CameraID:      st.CameraID,
+			ProjectID:     st.ProjectID,
+			State:         st.State.String(),
+			FailureCount:  st.FailureCount,
+			LastAttempt:   st.LastAttempt,
+			CreatedAt:     st.CreatedAt,
+			LastExtension: st.LastExtension,
+			StreamExpiry:  st.StreamExpiry,
+		}
+		if st.LastError != nil {
+			ds.LastError = st.LastError.Error()
+		}
+		streams = append(streams, ds)
+	}
+	return streams
+}
+
+// writeZipHistory copies one history bucket into the bundle as JSON lines
+func (s *Service) writeZipHistory(zw *zip.Writer, name, bucket string, from, to time.Time) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", name, err)
+	}
+	enc := json.NewEncoder(w)
+	err = s.store.Range(bucket, from, to, func(at time.Time, data []byte) error {
+		return enc.Encode(historyEntry{At: at, Data: data})
+	})
+	if err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeZipJSON adds one indented JSON file to the bundle
+func writeZipJSON(zw *zip.Writer, name string, v any) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", name, err)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
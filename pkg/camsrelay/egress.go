@@ -0,0 +1,99 @@
+package camsrelay
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/egress"
+	"github.com/ethan/nest-cloudflare-relay/pkg/store"
+)
+
+const egressDayFormat = "2006-01-02"
+
+// restoreEgress loads this and last month's daily totals from the store
+func (s *Service) restoreEgress(now time.Time) {
+	now = now.UTC()
+	since := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.UTC)
+
+	var days []egress.Day
+	var stale []string
+	err := s.store.List(store.BucketEgress, func(key string, data []byte) error {
+		var d store.EgressDay
+		if err := json.Unmarshal(data, &d); err != nil {
+			s.logger.Warn("skipping unreadable egress total", "key", key, "error", err)
+			return nil
+		}
+		day, err := time.Parse(egressDayFormat, d.Day)
+		if err != nil || day.Before(since) {
+			stale = append(stale, key)
+			return nil
+		}
+		days = append(days, egress.Day{Day: day, CameraID: d.CameraID, Bytes: d.Bytes})
+		return nil
+	})
+	if err != nil {
+		s.logger.Warn("failed to restore egress totals", "error", err)
+		return
+	}
+	for _, key := range stale {
+		if err := s.store.Delete(store.BucketEgress, key); err != nil {
+			s.logger.Warn("failed to prune egress totals", "key", key, "error", err)
+		}
+	}
+	s.egress.Restore(days, now)
+}
+
+// sampleEgress credits each relay's bytes sent since the last sample, saves
+// today's totals and warns as the monthly budget runs out. Bytes a relay
+// sends between its last sample and being stopped are not counted.
+func (s *Service) sampleEgress(now time.Time) {
+	for _, rs := range s.relay.GetRelayStats() {
+		sent := rs.Peer.VideoBytesSent + rs.Peer.AudioBytesSent
+		s.egress.Observe(rs.CameraID, strconv.FormatUint(rs.Generation, 10), sent, now)
+	}
+	s.egress.Prune(now)
+
+	// Yesterday's total can still change in the first sample after midnight
+	for _, d := range s.egress.Days(now.UTC().Add(-24 * time.Hour).Truncate(24 * time.Hour)) {
+		day := d.Day.Format(egressDayFormat)
+		err := s.store.Put(store.BucketEgress, day+"/"+d.CameraID, store.EgressDay{
+			Day:      day,
+			CameraID: d.CameraID,
+			Bytes:    d.Bytes,
+		})
+		if err != nil {
+			s.logger.Warn("failed to persist egress totals", "camera_id", d.CameraID, "error", err)
+		}
+	}
+
+	for _, w := range s.egress.CheckBudget(now) {
+		var msg string
+		if w.Threshold > 0 {
+			msg = fmt.Sprintf("egress reached %.0f%% of the monthly budget: %s of %s", w.Threshold*100, formatBytes(w.Bytes), formatBytes(w.Budget))
+		} else {
+			msg = fmt.Sprintf("egress is on course to exceed the monthly budget: %s projected, budget %s", formatBytes(w.Projected), formatBytes(w.Budget))
+		}
+		s.logger.Warn(msg,
+			"bytes", w.Bytes,
+			"projected_bytes", w.Projected,
+			"budget_bytes", w.Budget)
+		s.recordEvent("", "egress_budget", msg)
+	}
+}
+
+// formatBytes renders n in binary units, e.g. 12.3 GiB
+func formatBytes(n uint64) string {
+	const units = "KMGTPE"
+	if n < 1<<10 {
+		return strconv.FormatUint(n, 10) + " B"
+	}
+	v, i := float64(n)/(1<<10), 0
+	for v >= 1<<10 && i < len(units)-1 {
+		v /= 1 << 10
+		i++
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", v), ".0") + " " + units[i:i+1] + "iB"
+}
@@ -0,0 +1,139 @@
+package camsrelay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/sfu"
+	"github.com/ethan/nest-cloudflare-relay/pkg/store"
+)
+
+// sessionTimeout bounds each check or cleanup of a session left by a
+// previous leader
+const sessionTimeout = 10 * time.Second
+
+// becomeLeader blocks until this instance holds the leader lock. Without a
+// lock the instance is always the leader.
+func (s *Service) becomeLeader(ctx context.Context) error {
+	if s.opts.lock == nil {
+		return nil
+	}
+
+	s.logger.Info("standby: waiting for leader lock")
+	start := time.Now()
+	if err := s.opts.lock.Acquire(ctx); err != nil {
+		return fmt.Errorf("acquire leader lock: %w", err)
+	}
+	s.leader = true
+
+	s.logger.Info("acquired leader lock", "waited", time.Since(start).Round(time.Second))
+	return nil
+}
+
+// takeOver reads the session mappings persisted by the previous leader (or
+// by this instance before a restart). Sessions the SFU still reports live,
+// with their tracks, are handed to the cameras' first relays to resume; the
+// rest are released. Cameras that were live move to the front of the
+// startup order so viewers of active feeds get them back first.
+func (s *Service) takeOver(ctx context.Context, cameras []Camera) []Camera {
+	var previous []store.SessionMapping
+	err := s.store.List(store.BucketSessions, func(key string, data []byte) error {
+		var m store.SessionMapping
+		if err := json.Unmarshal(data, &m); err != nil {
+			s.logger.Warn("skipping unreadable session mapping", "camera_id", key, "error", err)
+			return nil
+		}
+		previous = append(previous, m)
+		return nil
+	})
+	if err != nil {
+		s.logger.Warn("failed to read session mappings", "error", err)
+		return cameras
+	}
+	if len(previous) == 0 {
+		return cameras
+	}
+
+	known := make(map[string]bool, len(cameras))
+	for _, cam := range cameras {
+		known[cam.DeviceID] = true
+	}
+
+	var resumed, released int
+	wasLive := make(map[string]time.Time, len(previous))
+	for _, m := range previous {
+		wasLive[m.CameraID] = m.UpdatedAt
+
+		switch {
+		case m.Backend != s.backend.Name():
+			// Another backend's session can't be resumed or released here
+		case known[m.CameraID] && s.sessionLive(ctx, m):
+			s.relay.ResumeSession(m.CameraID, m.SessionID)
+			resumed++
+			continue // The camera's relay persists the mapping again
+		default:
+			// Stale sessions hold SFU resources (LiveKit ingresses) until released
+			closeCtx, cancel := context.WithTimeout(ctx, sessionTimeout)
+			if err := s.backend.Close(closeCtx, m.SessionID); err != nil {
+				s.logger.Warn("failed to release previous session",
+					"camera_id", m.CameraID,
+					"session_id", m.SessionID,
+					"error", err)
+			}
+			cancel()
+			released++
+		}
+		s.store.Delete(store.BucketSessions, m.CameraID)
+	}
+
+	// Most recently live first; the rest keep discovery order
+	ordered := append([]Camera(nil), cameras...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ti, iLive := wasLive[ordered[i].DeviceID]
+		tj, jLive := wasLive[ordered[j].DeviceID]
+		if iLive != jLive {
+			return iLive
+		}
+		return ti.After(tj)
+	})
+
+	s.logger.Info("took over from previous leader",
+		"previous_sessions", len(previous),
+		"resumed", resumed,
+		"released", released)
+	s.recordEvent("", "failover", fmt.Sprintf("took over %d previous sessions: %d resumed, %d released",
+		len(previous), resumed, released))
+	return ordered
+}
+
+// sessionLive reports whether the SFU still has the mapping's session with
+// its tracks published and none errored. Backends that can't report session
+// state never resume.
+func (s *Service) sessionLive(ctx context.Context, m store.SessionMapping) bool {
+	reporter, ok := s.backend.(sfu.StateReporter)
+	if !ok {
+		return false
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, sessionTimeout)
+	defer cancel()
+	tracks, err := reporter.SessionState(checkCtx, m.SessionID)
+	if err != nil {
+		s.logger.Info("previous session is gone",
+			"camera_id", m.CameraID,
+			"session_id", m.SessionID,
+			"error", err)
+		return false
+	}
+	if len(tracks) == 0 {
+		return false
+	}
+	for _, t := range tracks {
+		if t.Error != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package camsrelay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+	"github.com/ethan/nest-cloudflare-relay/pkg/sfu"
+	"github.com/ethan/nest-cloudflare-relay/pkg/store"
+)
+
+// stateBackend reports the sessions in live and records the ones closed
+type stateBackend struct {
+	live   map[string][]sfu.TrackState
+	closed []string
+}
+
+func (b *stateBackend) Name() string { return "fake" }
+
+func (b *stateBackend) CreateSession(ctx context.Context, cameraID string) (string, error) {
+	return "new-" + cameraID, nil
+}
+
+func (b *stateBackend) PublishTracks(ctx context.Context, sessionID string, offer sfu.Description, tracks []sfu.Track) (sfu.Description, error) {
+	return sfu.Description{}, sfu.ErrNotSupported
+}
+
+func (b *stateBackend) Renegotiate(ctx context.Context, sessionID string, desc sfu.Description) (sfu.Description, error) {
+	return sfu.Description{}, sfu.ErrNotSupported
+}
+
+func (b *stateBackend) Close(ctx context.Context, sessionID string) error {
+	b.closed = append(b.closed, sessionID)
+	return nil
+}
+
+func (b *stateBackend) SessionState(ctx context.Context, sessionID string) ([]sfu.TrackState, error) {
+	tracks, ok := b.live[sessionID]
+	if !ok {
+		return nil, errors.New("session not found")
+	}
+	return tracks, nil
+}
+
+func TestTakeOver(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	backend := &stateBackend{live: map[string][]sfu.TrackState{
+		"s-live":    {{Name: "video", Status: "active"}, {Name: "audio", Status: "active"}},
+		"s-errored": {{Name: "video", Error: "track not found"}},
+		"s-empty":   {},
+		"s-removed": {{Name: "video", Status: "active"}},
+	}}
+	st := store.NewMemory()
+	s := &Service{
+		logger:  logger,
+		backend: backend,
+		store:   st,
+		relay:   relay.NewMultiCameraRelay(nil, backend, logger),
+	}
+
+	now := time.Now()
+	for i, m := range []store.SessionMapping{
+		{CameraID: "live", Backend: "fake", SessionID: "s-live"},
+		{CameraID: "gone", Backend: "fake", SessionID: "s-gone"},
+		{CameraID: "errored", Backend: "fake", SessionID: "s-errored"},
+		{CameraID: "empty", Backend: "fake", SessionID: "s-empty"},
+		{CameraID: "removed", Backend: "fake", SessionID: "s-removed"},
+		{CameraID: "other", Backend: "livekit", SessionID: "s-other"},
+	} {
+		m.UpdatedAt = now.Add(-time.Duration(i) * time.Minute)
+		if err := st.Put(store.BucketSessions, m.CameraID, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cameras := []Camera{{DeviceID: "idle"}, {DeviceID: "other"}, {DeviceID: "errored"},
+		{DeviceID: "live"}, {DeviceID: "gone"}, {DeviceID: "empty"}}
+	ordered := s.takeOver(context.Background(), cameras)
+
+	var order []string
+	for _, cam := range ordered {
+		order = append(order, cam.DeviceID)
+	}
+	if want := []string{"live", "gone", "errored", "empty", "other", "idle"}; !slices.Equal(order, want) {
+		t.Errorf("startup order = %v, want %v", order, want)
+	}
+
+	// The live session is resumed, not closed; every other session of this
+	// backend is released, and the other backend's is left alone
+	slices.Sort(backend.closed)
+	if want := []string{"s-empty", "s-errored", "s-gone", "s-removed"}; !slices.Equal(backend.closed, want) {
+		t.Errorf("closed = %v, want %v", backend.closed, want)
+	}
+	var kept []string
+	st.List(store.BucketSessions, func(key string, data []byte) error {
+		kept = append(kept, key)
+		return nil
+	})
+	if want := []string{"live"}; !slices.Equal(kept, want) {
+		t.Errorf("mappings kept = %v, want %v", kept, want)
+	}
+
+	var events []store.Event
+	st.Range(store.BucketEvents, now.Add(-time.Hour), now.Add(time.Hour), func(at time.Time, data []byte) error {
+		var e store.Event
+		if err := json.Unmarshal(data, &e); err != nil {
+			return err
+		}
+		events = append(events, e)
+		return nil
+	})
+	if len(events) != 1 || !strings.Contains(events[0].Message, "1 resumed, 4 released") {
+		t.Errorf("events = %+v, want one reporting 1 resumed, 4 released", events)
+	}
+}
@@ -0,0 +1,49 @@
+package camsrelay
+
+import (
+	"github.com/ethan/nest-cloudflare-relay/pkg/api"
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+)
+
+// goroutineReport is served on /api/admin/goroutines
+func (s *Service) goroutineReport() api.GoroutineReport {
+	return api.GoroutineReport{
+		Snapshot: goroutines.Take(),
+		Orphaned: goroutines.Orphaned(s.cameraLive),
+	}
+}
+
+// cameraLive reports whether the stream manager or relay still owns the
+// camera, so goroutines running for it are expected
+func (s *Service) cameraLive(cameraID string) bool {
+	if s.streamMgr.GetStream(cameraID) != nil {
+		return true
+	}
+	for _, st := range s.streamMgr.GetStreamStatus() {
+		if st.CameraID == cameraID {
+			return true
+		}
+	}
+	for _, rs := range s.relay.GetRelayStats() {
+		if rs.CameraID == cameraID {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGoroutineLeaks warns about cameras whose goroutines outlived them.
+// Stopping goroutines can lag their camera by a few seconds, so a camera is
+// only reported once it is orphaned on two checks in a row.
+func (s *Service) checkGoroutineLeaks() {
+	orphaned := make(map[string]bool)
+	for _, cameraID := range goroutines.Orphaned(s.cameraLive) {
+		orphaned[cameraID] = true
+		if s.orphaned[cameraID] {
+			s.logger.Warn("goroutines still running for a camera the relay no longer owns",
+				"camera_id", cameraID,
+				"goroutines", goroutines.Camera(cameraID))
+		}
+	}
+	s.orphaned = orphaned
+}
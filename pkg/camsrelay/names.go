@@ -0,0 +1,143 @@
+package camsrelay
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/api"
+	"github.com/ethan/nest-cloudflare-relay/pkg/store"
+)
+
+// applyNames resolves each camera's display name and position. Overrides
+// saved through the API win over camera.<id>.name/order in the config, which
+// win over the Nest custom or room name.
+func (s *Service) applyNames(cameras []Camera) {
+	for i := range cameras {
+		var saved store.CameraSettings
+		if _, err := s.store.Get(store.BucketCameras, cameras[i].DeviceID, &saved); err != nil {
+			s.logger.Warn("failed to read camera settings", "camera_id", cameras[i].DeviceID, "error", err)
+		}
+		s.resolveName(&cameras[i], saved)
+	}
+}
+
+// resolveName sets cam's Name, Order and Audio from saved, the config and
+// Nest
+func (s *Service) resolveName(cam *Camera, saved store.CameraSettings) {
+	cc := s.opts.cfg.Camera(cam.DeviceID)
+	cam.Name, cam.Order, cam.Audio = cam.nestName, 0, cc.AudioEnabled()
+	if cc != nil {
+		if cc.Name != "" {
+			cam.Name = cc.Name
+		}
+		cam.Order = cc.Order
+	}
+	if saved.Name != "" {
+		cam.Name = saved.Name
+	}
+	if saved.Order != 0 {
+		cam.Order = saved.Order
+	}
+	if saved.Audio != nil {
+		cam.Audio = *saved.Audio
+	}
+}
+
+// updateSettings applies update to a camera's saved overrides, stores them
+// and re-resolves the camera. Unknown cameras return api.ErrUnknownCamera.
+func (s *Service) updateSettings(cameraID string, update func(*store.CameraSettings)) (Camera, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := -1
+	for i, cam := range s.cameras {
+		if cam.DeviceID == cameraID {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return Camera{}, fmt.Errorf("%w: %s", api.ErrUnknownCamera, cameraID)
+	}
+
+	var settings store.CameraSettings
+	if _, err := s.store.Get(store.BucketCameras, cameraID, &settings); err != nil {
+		return Camera{}, fmt.Errorf("read camera settings: %w", err)
+	}
+	update(&settings)
+	settings.CameraID = cameraID
+	settings.UpdatedAt = time.Now().UTC()
+
+	var err error
+	if settings.Empty() {
+		err = s.store.Delete(store.BucketCameras, cameraID)
+	} else {
+		err = s.store.Put(store.BucketCameras, cameraID, settings)
+	}
+	if err != nil {
+		return Camera{}, fmt.Errorf("save camera settings: %w", err)
+	}
+	s.resolveName(&s.cameras[idx], settings)
+	return s.cameras[idx], nil
+}
+
+// SetCameraName renames and repositions a camera while running and saves
+// the override in the state store. An empty name and zero order clear the
+// override, reverting to the config file or Nest. Unknown cameras return
+// api.ErrUnknownCamera.
+func (s *Service) SetCameraName(cameraID, name string, order int) error {
+	cam, err := s.updateSettings(cameraID, func(settings *store.CameraSettings) {
+		settings.Name, settings.Order = name, order
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.apiServer != nil {
+		s.apiServer.SetCameraName(cam.DeviceID, cam.Name)
+		s.apiServer.SetCameraOrder(cam.DeviceID, cam.Order)
+	}
+	if s.alerts != nil {
+		s.alerts.SetCameraName(cam.DeviceID, cam.Name)
+	}
+
+	s.logger.Info("camera renamed", "camera_id", cam.DeviceID, "name", cam.Name, "order", cam.Order)
+	s.recordEvent(cam.DeviceID, "camera_renamed", cam.Name)
+	return nil
+}
+
+// SetCameraAudio switches a camera's audio forwarding while running and
+// saves the override in the state store. A nil enabled clears the override,
+// reverting to camera.<id>.audio in the config. Unknown cameras return
+// api.ErrUnknownCamera.
+func (s *Service) SetCameraAudio(cameraID string, enabled *bool) error {
+	cam, err := s.updateSettings(cameraID, func(settings *store.CameraSettings) {
+		settings.Audio = enabled
+	})
+	if err != nil {
+		return err
+	}
+
+	s.relay.SetCameraAudio(cam.DeviceID, cam.Audio)
+	s.logger.Info("camera audio switched", "camera_id", cam.DeviceID, "enabled", cam.Audio)
+	s.recordEvent(cam.DeviceID, "camera_audio", fmt.Sprint(cam.Audio))
+	return nil
+}
+
+// cameraAudio reports whether a camera's audio is forwarded, falling back to
+// the config for cameras not yet discovered. Audio-only cameras always are.
+func (s *Service) cameraAudio(deviceID string) bool {
+	cc := s.opts.cfg.Camera(deviceID)
+	if cc != nil && cc.AudioOnly {
+		return true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, cam := range s.cameras {
+		if cam.DeviceID == deviceID {
+			return cam.Audio
+		}
+	}
+	return cc.AudioEnabled()
+}
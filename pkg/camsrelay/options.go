@@ -0,0 +1,308 @@
+package camsrelay
+
+import (
+	"log/slog"
+	"maps"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/config"
+	"github.com/ethan/nest-cloudflare-relay/pkg/faults"
+	"github.com/ethan/nest-cloudflare-relay/pkg/ha"
+	"github.com/ethan/nest-cloudflare-relay/pkg/logger"
+	"github.com/ethan/nest-cloudflare-relay/pkg/nest"
+	"github.com/ethan/nest-cloudflare-relay/pkg/nest/events"
+	"github.com/ethan/nest-cloudflare-relay/pkg/plugin"
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+	"github.com/ethan/nest-cloudflare-relay/pkg/sfu"
+	"github.com/ethan/nest-cloudflare-relay/pkg/store"
+)
+
+// DefaultListenAddr is the address the viewer and API are served on unless
+// WithListenAddr overrides it.
+const DefaultListenAddr = ":8080"
+
+// DefaultMaxCameras caps discovery so a single Google project stays within
+// the 10 QPM SDM budget (see nest.DefaultMultiStreamConfig).
+const DefaultMaxCameras = 20
+
+// Option configures a Service
+type Option func(*options)
+
+type options struct {
+	cfg           config.Config
+	cameraIDs     []string
+	maxCameras    int
+	listenAddr    string
+	streamConfig  nest.MultiStreamConfig
+	recorders     []relay.Recorder
+	eventHandlers []func(events.Event)
+	plugins       []plugin.Plugin
+	faults        *faults.Injector
+	backend       sfu.Backend
+	store         store.Store
+	lock          ha.Lock
+	demand        func(cameraID string) int
+	logger        *slog.Logger
+	logRing       *logger.Ring
+}
+
+func defaultOptions() options {
+	return options{
+		maxCameras:   DefaultMaxCameras,
+		listenAddr:   DefaultListenAddr,
+		streamConfig: nest.DefaultMultiStreamConfig(),
+	}
+}
+
+// WithConfig copies Google and Cloudflare credentials from a loaded config,
+// typically the result of config.Load(".env").
+func WithConfig(cfg *config.Config) Option {
+	return func(o *options) {
+		o.cfg = *cfg
+	}
+}
+
+// WithGoogleCredentials sets the OAuth2 client and SDM project used to reach
+// the Nest cameras.
+func WithGoogleCredentials(clientID, clientSecret, refreshToken, projectID string) Option {
+	return func(o *options) {
+		o.cfg.Google = config.GoogleConfig{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RefreshToken: refreshToken,
+			ProjectID:    projectID,
+		}
+	}
+}
+
+// WithGoogleAccount adds a further Device Access project, e.g. for cameras
+// in a second Google home. Its cameras are relayed alongside the main
+// project's, with their own command quota.
+func WithGoogleAccount(name, clientID, clientSecret, refreshToken, projectID string) Option {
+	return func(o *options) {
+		accounts := make(map[string]*config.GoogleConfig, len(o.cfg.Accounts)+1)
+		maps.Copy(accounts, o.cfg.Accounts)
+		accounts[name] = &config.GoogleConfig{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RefreshToken: refreshToken,
+			ProjectID:    projectID,
+		}
+		o.cfg.Accounts = accounts
+	}
+}
+
+// WithCloudflareApp sets the Cloudflare Calls application streams are published to.
+func WithCloudflareApp(appID, apiToken string) Option {
+	return func(o *options) {
+		o.cfg.Cloudflare = config.CloudflareConfig{
+			AppID:    appID,
+			APIToken: apiToken,
+		}
+	}
+}
+
+// WithLiveKit publishes cameras to a LiveKit room instead of Cloudflare Calls.
+// The bundled web viewer only understands Cloudflare sessions; LiveKit users
+// watch through their own LiveKit clients.
+func WithLiveKit(serverURL, apiKey, apiSecret, room string) Option {
+	return func(o *options) {
+		o.cfg.SFU = config.SFUConfig{
+			Backend: config.SFULiveKit,
+			LiveKit: config.LiveKitConfig{
+				URL:       serverURL,
+				APIKey:    apiKey,
+				APISecret: apiSecret,
+				Room:      room,
+			},
+		}
+	}
+}
+
+// WithSFUBackend publishes cameras to a custom SFU backend, overriding the
+// sfu_backend selection in the config.
+func WithSFUBackend(backend sfu.Backend) Option {
+	return func(o *options) {
+		o.backend = backend
+	}
+}
+
+// WithCameras restricts the service to the given device IDs. Without it,
+// every camera in the project is relayed (up to the max camera limit).
+func WithCameras(deviceIDs ...string) Option {
+	return func(o *options) {
+		o.cameraIDs = append(o.cameraIDs, deviceIDs...)
+	}
+}
+
+// WithMaxCameras overrides the discovery cap (default 20).
+func WithMaxCameras(n int) Option {
+	return func(o *options) {
+		o.maxCameras = n
+	}
+}
+
+// WithListenAddr sets the HTTP address for the viewer and API.
+// An empty address disables the HTTP server entirely.
+func WithListenAddr(addr string) Option {
+	return func(o *options) {
+		o.listenAddr = addr
+	}
+}
+
+// WithStreamConfig overrides the rate-limiting and recovery settings of the
+// underlying nest.MultiStreamManager.
+func WithStreamConfig(cfg nest.MultiStreamConfig) Option {
+	return func(o *options) {
+		o.streamConfig = cfg
+	}
+}
+
+// WithRecorder registers a relay.Recorder that receives a copy of every
+// camera's media. It may be given multiple times.
+func WithRecorder(rec relay.Recorder) Option {
+	return func(o *options) {
+		o.recorders = append(o.recorders, rec)
+	}
+}
+
+// WithEventHandler registers fn to be called with each camera event from
+// the Pub/Sub subscription set by pubsub_subscription, e.g. to start a
+// recording on motion. It may be given multiple times.
+func WithEventHandler(fn func(events.Event)) Option {
+	return func(o *options) {
+		o.eventHandlers = append(o.eventHandlers, fn)
+	}
+}
+
+// WithPlugin attaches a plugin to every camera in addition to those
+// registered globally with plugin.Register. It must implement at least one of
+// relay.FrameProcessor, relay.Recorder or relay.EventHandler.
+func WithPlugin(p plugin.Plugin) Option {
+	return func(o *options) {
+		o.plugins = append(o.plugins, p)
+	}
+}
+
+// WithDVR keeps the last window of footage per camera in memory and serves
+// it as HLS under /api/dvr/, independent of disk recording.
+func WithDVR(window time.Duration) Option {
+	return func(o *options) {
+		o.cfg.DVR.Window = window
+	}
+}
+
+// WithThumbnails refreshes a JPEG thumbnail of every camera each interval
+// and serves them under /api/thumbnails/. Overrides thumbnail_interval.
+func WithThumbnails(interval time.Duration) Option {
+	return func(o *options) {
+		o.cfg.Thumbnails.Interval = interval
+	}
+}
+
+// WithTimeLapse keeps one keyframe per camera every interval below dir and
+// serves time-lapse MP4s under /api/timelapse/. Overrides timelapse_dir and
+// timelapse_interval.
+func WithTimeLapse(dir string, interval time.Duration) Option {
+	return func(o *options) {
+		o.cfg.TimeLapse.Dir = dir
+		o.cfg.TimeLapse.Interval = interval
+	}
+}
+
+// WithStore supplies the persistent state store, overriding state_path.
+// The caller keeps ownership: Stop does not close it.
+func WithStore(st store.Store) Option {
+	return func(o *options) {
+		o.store = st
+	}
+}
+
+// WithLeaderLock runs the service as one member of an active/standby group:
+// Start blocks until the lock is held, and Stop releases it. Overrides
+// ha_lock_path.
+func WithLeaderLock(lock ha.Lock) Option {
+	return func(o *options) {
+		o.lock = lock
+	}
+}
+
+// WithRotation relays at most slots cameras at a time, rotating the active
+// set every interval when the fleet is larger. Overrides rotation_slots and
+// rotation_interval.
+func WithRotation(slots int, interval time.Duration) Option {
+	return func(o *options) {
+		o.cfg.Rotation = config.RotationConfig{Slots: slots, Interval: interval}
+	}
+}
+
+// WithAllowOverQuota starts the service even when the startup plan shows
+// stream extensions exceeding the SDM quota; streams will then expire and
+// flap. Overrides allow_over_quota.
+func WithAllowOverQuota() Option {
+	return func(o *options) {
+		o.cfg.AllowOverQuota = true
+	}
+}
+
+// WithSelfTest makes Start verify the Google credentials, the SFU and one
+// camera's full pipeline before returning, so misconfigurations fail
+// startup instead of showing up later as degraded cameras. Overrides
+// self_test.
+func WithSelfTest() Option {
+	return func(o *options) {
+		o.cfg.SelfTest = true
+	}
+}
+
+// WithResumeStreams leaves the Nest RTSP streams running on Stop and, on
+// the next Start, adopts those still live instead of generating new ones,
+// so a quick restart costs no stream commands. Needs a persistent store
+// (state_path or WithStore). Overrides resume_streams.
+func WithResumeStreams() Option {
+	return func(o *options) {
+		o.cfg.ResumeStreams = true
+	}
+}
+
+// WithDeviceRefresh re-lists the projects' cameras every interval, starting
+// cameras added to them, stopping removed ones and picking up new names.
+// Overrides device_refresh.
+func WithDeviceRefresh(interval time.Duration) Option {
+	return func(o *options) {
+		o.cfg.DeviceRefresh = interval
+	}
+}
+
+// WithViewerDemand reports how many viewers watch a camera, so rotation
+// keeps watched cameras active.
+func WithViewerDemand(demand func(cameraID string) int) Option {
+	return func(o *options) {
+		o.demand = demand
+	}
+}
+
+// WithLogger sets the structured logger. Defaults to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithLogRing includes the ring's recent records in diagnostic bundles
+// (GET /api/admin/diagnostics). The ring must be fed by the service's logger,
+// see logger.Ring.Handler.
+func WithLogRing(ring *logger.Ring) Option {
+	return func(o *options) {
+		o.logRing = ring
+	}
+}
+
+// WithFaultInjector enables chaos mode: RTSP disconnects, Cloudflare API
+// delays and extension failures are injected per the injector's config.
+// Intended for exercising recovery logic, never for production.
+func WithFaultInjector(inj *faults.Injector) Option {
+	return func(o *options) {
+		o.faults = inj
+	}
+}
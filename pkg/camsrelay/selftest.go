@@ -0,0 +1,107 @@
+package camsrelay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/nest"
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+)
+
+const (
+	// selfTestAPITimeout bounds the credential and SFU checks
+	selfTestAPITimeout = 30 * time.Second
+
+	// selfTestPipelineTimeout bounds the wait for the first camera to reach
+	// viewers; it covers stream generation, the RTSP handshake and ICE
+	selfTestPipelineTimeout = 2 * time.Minute
+)
+
+var (
+	errStarting     = errors.New("starting")
+	errShuttingDown = errors.New("shutting down")
+)
+
+// Ready returns nil once Start has completed, including the self-test when
+// enabled, and the reason the service isn't ready otherwise. It backs
+// GET /readyz.
+func (s *Service) Ready() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.notReady
+}
+
+func (s *Service) setNotReady(err error) {
+	s.mu.Lock()
+	s.notReady = err
+	s.mu.Unlock()
+}
+
+// selfTestAPIs refreshes the Google access token and opens (then closes) an
+// SFU session, so bad credentials fail startup with a clear error
+func (s *Service) selfTestAPIs(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, selfTestAPITimeout)
+	defer cancel()
+
+	if s.opts.cfg.NestEnabled() {
+		for projectID, client := range s.nestClients {
+			err := client.RefreshToken(ctx)
+			if errors.Is(err, nest.ErrUnauthorized) {
+				return fmt.Errorf("google token refresh for project %s: %w (run `relay auth` for a new refresh token)", projectID, err)
+			}
+			if err != nil {
+				return fmt.Errorf("google token refresh for project %s: %w", projectID, err)
+			}
+		}
+		s.logger.Info("self-test: google credentials ok")
+	}
+
+	sessionID, err := s.backend.CreateSession(ctx, "self-test")
+	if err != nil {
+		return fmt.Errorf("%s session: %w", s.backend.Name(), err)
+	}
+	if err := s.backend.Close(ctx, sessionID); err != nil {
+		s.logger.Warn("self-test: failed to close SFU session", "session_id", sessionID, "error", err)
+	}
+	s.logger.Info("self-test: SFU session ok", "backend", s.backend.Name())
+	return nil
+}
+
+// selfTestPipeline waits until one camera relays media over a connected
+// PeerConnection, proving the Nest stream, RTSP, bridge and SFU path end to
+// end. Cameras start staggered, so the first to come up is enough.
+func (s *Service) selfTestPipeline(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, selfTestPipelineTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		for _, stats := range s.relay.GetRelayStats() {
+			if pipelineUp(stats) {
+				s.logger.Info("self-test: camera pipeline ok", "camera_id", stats.CameraID)
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("no camera relayed media within %s: %w", selfTestPipelineTimeout, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// pipelineUp reports whether a relay is delivering media to the SFU
+func pipelineUp(stats relay.RelayStats) bool {
+	if stats.WebRTCState != "connected" {
+		return false
+	}
+	if stats.AudioOnly {
+		return stats.AudioFrames > 0
+	}
+	return stats.VideoFrames > 0
+}
@@ -0,0 +1,35 @@
+package camsrelay
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/api"
+)
+
+// eventImageWindow is how long after an event Nest still generates its image
+const eventImageWindow = 30 * time.Second
+
+// snapshot is served on /api/cameras/{id}/snapshot: the image of the
+// camera's latest event while Nest still generates it, else its latest
+// thumbnail, which width doesn't scale
+func (s *Service) snapshot(ctx context.Context, cameraID string, width int) ([]byte, error) {
+	if s.events != nil {
+		if recent := s.events.Recent(cameraID, 1); len(recent) > 0 && time.Since(recent[0].Timestamp) < eventImageWindow {
+			jpeg, err := s.streamMgr.EventImage(ctx, cameraID, recent[0].ID, width)
+			if err == nil {
+				return jpeg, nil
+			}
+			s.logger.Warn("failed to get event image, trying thumbnail",
+				"camera_id", cameraID,
+				"event_id", recent[0].ID,
+				"error", err)
+		}
+	}
+	if s.thumbnails != nil {
+		if thumb, ok := s.thumbnails.Get(cameraID); ok {
+			return thumb.JPEG, nil
+		}
+	}
+	return nil, api.ErrNoSnapshot
+}
@@ -0,0 +1,264 @@
+package camsrelay
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/api"
+	"github.com/ethan/nest-cloudflare-relay/pkg/bridge"
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+	"github.com/ethan/nest-cloudflare-relay/pkg/nest"
+	"github.com/ethan/nest-cloudflare-relay/pkg/store"
+)
+
+const (
+	statsInterval  = time.Minute        // How often stats history is sampled
+	statsRetention = 7 * 24 * time.Hour // How long stats and events are kept
+)
+
+// CameraStatsSample is one camera's entry in the persisted stats history
+type CameraStatsSample struct {
+	CameraID    string  `json:"cameraId"`
+	State       string  `json:"state"`
+	WebRTCState string  `json:"webrtcState,omitempty"`
+	VideoFrames uint64  `json:"videoFrames"`
+	AudioFrames uint64  `json:"audioFrames"`
+	VideoLoss   float64 `json:"videoLoss,omitempty"` // Smoothed fraction lost, 0..1
+	VideoJitter float64 `json:"videoJitterMs,omitempty"`
+	VideoRTT    float64 `json:"videoRttMs,omitempty"`
+	LatencyP50  float64 `json:"latencyP50Ms,omitempty"` // Camera arrival → track write
+	LatencyP95  float64 `json:"latencyP95Ms,omitempty"`
+	LatencyP99  float64 `json:"latencyP99Ms,omitempty"`
+
+	VideoBitrate float64 `json:"videoBitrate,omitempty"` // Bits per second leaving the host
+	ICERTT       float64 `json:"iceRttMs,omitempty"`
+	Candidate    string  `json:"candidate,omitempty"` // Selected pair, local/remote type
+}
+
+// openStore opens the configured state store, falling back to memory
+func (s *Service) openStore() (store.Store, error) {
+	if s.opts.store != nil {
+		return s.opts.store, nil
+	}
+	if s.opts.cfg.StatePath == "" {
+		return store.NewMemory(), nil
+	}
+	return store.OpenBolt(s.opts.cfg.StatePath)
+}
+
+// recordEvent appends to the event log, logging (not returning) failures
+func (s *Service) recordEvent(cameraID, eventType, message string) {
+	if s.store == nil {
+		return
+	}
+	err := s.store.Append(store.BucketEvents, time.Now(), store.Event{
+		CameraID: cameraID,
+		Type:     eventType,
+		Message:  message,
+	})
+	if err != nil {
+		s.logger.Warn("failed to record event", "type", eventType, "error", err)
+	}
+}
+
+// persistLoop periodically saves session mappings and stats history
+func (s *Service) persistLoop(ctx context.Context) {
+	defer s.wg.Done()
+	defer goroutines.Track("service.persist", "")()
+
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.persist(now)
+			s.checkGoroutineLeaks()
+		}
+	}
+}
+
+// persist writes one stats sample and the current session mappings
+func (s *Service) persist(now time.Time) {
+	states := make(map[string]string)
+	for _, st := range s.streamMgr.GetStreamStatus() {
+		states[st.CameraID] = st.State.String()
+	}
+
+	var samples []CameraStatsSample
+	for _, rs := range s.relay.GetRelayStats() {
+		samples = append(samples, CameraStatsSample{
+			CameraID:    rs.CameraID,
+			State:       states[rs.CameraID],
+			WebRTCState: rs.WebRTCState,
+			VideoFrames: rs.VideoFrames,
+			AudioFrames: rs.AudioFrames,
+			VideoLoss:   rs.VideoQuality.FractionLost,
+			VideoJitter: float64(rs.VideoQuality.Jitter) / float64(time.Millisecond),
+			VideoRTT:    float64(rs.VideoQuality.RTT) / float64(time.Millisecond),
+			LatencyP50:  float64(rs.VideoLatency.Total.P50) / float64(time.Millisecond),
+			LatencyP95:  float64(rs.VideoLatency.Total.P95) / float64(time.Millisecond),
+			LatencyP99:  float64(rs.VideoLatency.Total.P99) / float64(time.Millisecond),
+
+			VideoBitrate: rs.Peer.VideoBitrate,
+			ICERTT:       float64(rs.Peer.RTT) / float64(time.Millisecond),
+			Candidate:    candidatePair(rs.Peer),
+		})
+
+		if rs.SessionID == "" {
+			continue
+		}
+		err := s.store.Put(store.BucketSessions, rs.CameraID, store.SessionMapping{
+			CameraID:  rs.CameraID,
+			Backend:   s.backend.Name(),
+			SessionID: rs.SessionID,
+			UpdatedAt: now,
+		})
+		if err != nil {
+			s.logger.Warn("failed to persist session mapping", "camera_id", rs.CameraID, "error", err)
+		}
+	}
+
+	s.persistStreamTokens(now)
+	s.sampleEgress(now)
+
+	if err := s.store.Append(store.BucketStats, now, samples); err != nil {
+		s.logger.Warn("failed to persist stats", "error", err)
+	}
+
+	cutoff := now.Add(-statsRetention)
+	for _, bucket := range []string{store.BucketStats, store.BucketEvents} {
+		if err := s.store.Prune(bucket, cutoff); err != nil {
+			s.logger.Warn("failed to prune history", "bucket", bucket, "error", err)
+		}
+	}
+}
+
+// liveStreams returns every camera's current, unexpired Nest stream
+func (s *Service) liveStreams() []api.StreamInfo {
+	now := time.Now()
+	var streams []api.StreamInfo
+	for _, st := range s.streamMgr.GetStreamStatus() {
+		stream := s.streamMgr.GetStream(st.CameraID)
+		if stream == nil || stream.URL == "" || !stream.ExpiresAt.After(now) {
+			continue
+		}
+		streams = append(streams, api.StreamInfo{
+			CameraID:  st.CameraID,
+			RTSPURL:   stream.URL,
+			ExpiresAt: stream.ExpiresAt,
+		})
+	}
+	return streams
+}
+
+// persistStreamTokens mirrors the live streams into the store, so tools
+// reading the state file see the same URLs as GET /api/admin/streams
+func (s *Service) persistStreamTokens(now time.Time) {
+	live := make(map[string]bool)
+	for _, st := range s.liveStreams() {
+		live[st.CameraID] = true
+		rec := store.StreamToken{
+			CameraID:  st.CameraID,
+			URL:       st.RTSPURL,
+			ExpiresAt: st.ExpiresAt,
+			UpdatedAt: now,
+		}
+		if stream := s.streamMgr.GetStream(st.CameraID); stream != nil {
+			rec.Token, rec.ExtensionToken = stream.Token, stream.ExtensionToken
+		}
+		err := s.store.Put(store.BucketStreams, st.CameraID, rec)
+		if err != nil {
+			s.logger.Warn("failed to persist stream token", "camera_id", st.CameraID, "error", err)
+		}
+	}
+
+	var stale []string
+	s.store.List(store.BucketStreams, func(key string, _ []byte) error {
+		if !live[key] {
+			stale = append(stale, key)
+		}
+		return nil
+	})
+	for _, key := range stale {
+		s.store.Delete(store.BucketStreams, key)
+	}
+}
+
+// restoreTokens seeds each project's Nest client with the access token an
+// earlier run saved, and saves each token it refreshes from now on. With
+// resume_streams, the streams the last run left up are offered to the
+// stream manager too, so their cameras start without generating new ones.
+func (s *Service) restoreTokens(now time.Time) {
+	cfg := s.opts.cfg
+	for _, account := range cfg.GoogleAccounts() {
+		client := s.nestClients[account.ProjectID]
+		var saved store.OAuthToken
+		if ok, err := s.store.Get(store.BucketTokens, account.ProjectID, &saved); err != nil {
+			s.logger.Warn("failed to read saved access token", "project_id", account.ProjectID, "error", err)
+		} else if ok && saved.ClientID == account.ClientID && saved.Expiry.After(now) {
+			client.SetAccessToken(saved.AccessToken, saved.Expiry)
+			s.logger.Info("reusing saved access token",
+				"project_id", account.ProjectID,
+				"expires_at", saved.Expiry.Format(time.RFC3339))
+		}
+		client.OnTokenRefresh(func(token string, expiry time.Time) {
+			err := s.store.Put(store.BucketTokens, account.ProjectID, store.OAuthToken{
+				ClientID:    account.ClientID,
+				AccessToken: token,
+				Expiry:      expiry,
+				UpdatedAt:   time.Now(),
+			})
+			if err != nil {
+				s.logger.Warn("failed to save access token", "project_id", account.ProjectID, "error", err)
+			}
+		})
+	}
+
+	if !cfg.ResumeStreams {
+		return
+	}
+	resumed := 0
+	s.store.List(store.BucketStreams, func(key string, data []byte) error {
+		var rec store.StreamToken
+		if err := json.Unmarshal(data, &rec); err != nil || rec.ExtensionToken == "" || !rec.ExpiresAt.After(now) {
+			return nil
+		}
+		s.streamMgr.ResumeStream(rec.CameraID, &nest.RTSPStream{
+			URL:            rec.URL,
+			Token:          rec.Token,
+			ExtensionToken: rec.ExtensionToken,
+			ExpiresAt:      rec.ExpiresAt,
+			DeviceID:       rec.CameraID,
+		})
+		resumed++
+		return nil
+	})
+	if resumed > 0 {
+		s.logger.Info("resuming streams left up by the last run", "streams", resumed)
+	}
+}
+
+// clearStreamTokens forgets persisted streams once they have been stopped
+func (s *Service) clearStreamTokens() {
+	var keys []string
+	s.store.List(store.BucketStreams, func(key string, _ []byte) error {
+		keys = append(keys, key)
+		return nil
+	})
+	for _, key := range keys {
+		s.store.Delete(store.BucketStreams, key)
+	}
+}
+
+// candidatePair formats the selected ICE pair as "local/remote", or "" before
+// the first poll
+func candidatePair(p bridge.PeerStats) string {
+	if p.LocalCandidate == "" {
+		return ""
+	}
+	return p.LocalCandidate + "/" + p.RemoteCandidate
+}
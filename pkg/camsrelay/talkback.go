@@ -0,0 +1,50 @@
+package camsrelay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/api"
+	"github.com/ethan/nest-cloudflare-relay/pkg/nestrtc"
+)
+
+// Talk plays Ogg Opus audio through a camera's speaker, returning once it
+// has played or ctx ends. The camera must stream over WebRTC with
+// camera.<id>.talkback set. Errors wrap the api package's, for the API to
+// report.
+func (s *Service) Talk(ctx context.Context, cameraID string, audio io.Reader) error {
+	s.mu.RLock()
+	known := slices.ContainsFunc(s.cameras, func(c Camera) bool { return c.DeviceID == cameraID })
+	s.mu.RUnlock()
+	if !known {
+		return fmt.Errorf("%w: %s", api.ErrUnknownCamera, cameraID)
+	}
+	if cc := s.opts.cfg.Camera(cameraID); cc == nil || !cc.Talkback {
+		return fmt.Errorf("%w: camera.%s.talkback is not set", api.ErrTalkbackUnavailable, cameraID)
+	}
+	var receiver *nestrtc.Receiver
+	if stream := s.streamMgr.GetWebRTCStream(cameraID); stream != nil {
+		receiver, _ = stream.Peer.(*nestrtc.Receiver)
+	}
+	if receiver == nil {
+		return fmt.Errorf("%w: camera has no WebRTC stream", api.ErrTalkbackUnavailable)
+	}
+
+	s.logger.Info("talkback started", "camera_id", cameraID)
+	err := receiver.Talk(ctx, audio)
+	switch {
+	case errors.Is(err, nestrtc.ErrNoTalkback), errors.Is(err, nestrtc.ErrTalkbackBusy):
+		return fmt.Errorf("%w: %w", api.ErrTalkbackUnavailable, err)
+	case errors.Is(err, nestrtc.ErrInvalidTalkback):
+		return fmt.Errorf("%w: %w", api.ErrInvalidAudio, err)
+	case err != nil:
+		s.logger.Warn("talkback ended early", "camera_id", cameraID, "error", err)
+		return err
+	}
+	s.logger.Info("talkback played", "camera_id", cameraID)
+	s.recordEvent(cameraID, "talkback", "")
+	return nil
+}
@@ -0,0 +1,125 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/sfu"
+)
+
+// Backend adapts the Cloudflare Calls client to sfu.Backend
+type Backend struct {
+	client *Client
+}
+
+// NewBackend wraps a Cloudflare Calls client as an SFU backend
+func NewBackend(client *Client) *Backend {
+	return &Backend{client: client}
+}
+
+// Name returns "cloudflare"
+func (b *Backend) Name() string {
+	return "cloudflare"
+}
+
+// CreateSession creates a new Cloudflare Calls session
+func (b *Backend) CreateSession(ctx context.Context, cameraID string) (string, error) {
+	session, err := b.client.CreateSession(ctx)
+	if err != nil {
+		return "", fmt.Errorf("create Cloudflare session: %w", err)
+	}
+	return session.SessionID, nil
+}
+
+// PublishTracks pushes the local tracks to the session via tracks/new
+func (b *Backend) PublishTracks(ctx context.Context, sessionID string, offer sfu.Description, tracks []sfu.Track) (sfu.Description, error) {
+	tracksReq := &TracksRequest{
+		SessionDescription: &SessionDescription{
+			SDP:  offer.SDP,
+			Type: offer.Type,
+		},
+	}
+	for _, t := range tracks {
+		tracksReq.Tracks = append(tracksReq.Tracks, TrackObject{
+			Location:  "local",
+			Mid:       t.Mid,
+			TrackName: t.Name,
+		})
+	}
+
+	tracksResp, err := b.client.AddTracksWithRetry(ctx, sessionID, tracksReq, 3)
+	if err != nil {
+		return sfu.Description{}, fmt.Errorf("add tracks to Cloudflare: %w", err)
+	}
+
+	if tracksResp.SessionDescription == nil {
+		return sfu.Description{}, fmt.Errorf("Cloudflare did not return SDP answer")
+	}
+
+	return sfu.Description{
+		Type: tracksResp.SessionDescription.Type,
+		SDP:  tracksResp.SessionDescription.SDP,
+	}, nil
+}
+
+// Renegotiate sends an updated description to the session
+func (b *Backend) Renegotiate(ctx context.Context, sessionID string, desc sfu.Description) (sfu.Description, error) {
+	resp, err := b.client.Renegotiate(ctx, sessionID, &RenegotiateRequest{
+		SessionDescription: SessionDescription{
+			SDP:  desc.SDP,
+			Type: desc.Type,
+		},
+	})
+	if err != nil {
+		return sfu.Description{}, err
+	}
+
+	if resp.SessionDescription == nil {
+		return sfu.Description{}, nil
+	}
+	return sfu.Description{
+		Type: resp.SessionDescription.Type,
+		SDP:  resp.SessionDescription.SDP,
+	}, nil
+}
+
+// SessionState reports the local (published) tracks Cloudflare holds for
+// the session
+func (b *Backend) SessionState(ctx context.Context, sessionID string) ([]sfu.TrackState, error) {
+	resp, err := b.client.GetSessionState(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []sfu.TrackState
+	for _, t := range resp.Tracks {
+		if t.Location != "" && t.Location != "local" {
+			continue
+		}
+		state := sfu.TrackState{Name: t.TrackName, Kind: t.Kind, Status: t.Status}
+		if t.ErrorCode != "" {
+			state.Error = t.ErrorCode + ": " + t.ErrorDesc
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// CloseTracks force-closes the session's published tracks, which needs no
+// renegotiation
+func (b *Backend) CloseTracks(ctx context.Context, sessionID string, tracks []sfu.Track) error {
+	req := &CloseTracksRequest{Force: true}
+	for _, t := range tracks {
+		req.Tracks = append(req.Tracks, CloseTrackObject{Mid: t.Mid})
+	}
+	if len(req.Tracks) == 0 {
+		return nil
+	}
+	_, err := b.client.CloseTracks(ctx, sessionID, req)
+	return err
+}
+
+// Close is a no-op: Cloudflare reaps sessions once the PeerConnection closes
+func (b *Backend) Close(ctx context.Context, sessionID string) error {
+	return nil
+}
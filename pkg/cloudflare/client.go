@@ -9,6 +9,9 @@ import (
 	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/faults"
+	"github.com/ethan/nest-cloudflare-relay/pkg/httpx"
 )
 
 const (
@@ -21,18 +24,35 @@ type Client struct {
 	apiToken   string
 	httpClient *http.Client
 	logger     *slog.Logger
+	faults     *faults.Injector
 }
 
 // NewClient creates a new Cloudflare Calls API client
 func NewClient(appID, apiToken string, logger *slog.Logger) *Client {
 	return &Client{
-		appID:    appID,
-		apiToken: apiToken,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		logger: logger,
+		appID:      appID,
+		apiToken:   apiToken,
+		httpClient: httpx.NewClient(httpx.Config{Timeout: 30 * time.Second}),
+		logger:     logger,
+	}
+}
+
+// SetHTTPClient replaces the client used for Calls API requests
+func (c *Client) SetHTTPClient(hc *http.Client) {
+	c.httpClient = hc
+}
+
+// SetFaultInjector enables chaos-mode delays on API requests (nil disables)
+func (c *Client) SetFaultInjector(inj *faults.Injector) {
+	c.faults = inj
+}
+
+// do sends an API request, applying any injected delay first
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if err := c.faults.DelayCloudflare(req.Context(), req.Method+" "+req.URL.Path); err != nil {
+		return nil, err
 	}
+	return c.httpClient.Do(req)
 }
 
 // CreateSession creates a new WebRTC session
@@ -46,7 +66,7 @@ func (c *Client) CreateSession(ctx context.Context) (*NewSessionResponse, error)
 	req.Header.Set("Authorization", "Bearer "+c.apiToken)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("create session request: %w", err)
 	}
@@ -91,7 +111,7 @@ func (c *Client) AddTracks(ctx context.Context, sessionID string, req *TracksReq
 	httpReq.Header.Set("Authorization", "Bearer "+c.apiToken)
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("add tracks request: %w", err)
 	}
@@ -140,7 +160,7 @@ func (c *Client) Renegotiate(ctx context.Context, sessionID string, req *Renegot
 	httpReq.Header.Set("Authorization", "Bearer "+c.apiToken)
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("renegotiate request: %w", err)
 	}
@@ -192,7 +212,7 @@ func (c *Client) CloseTracks(ctx context.Context, sessionID string, req *CloseTr
 	httpReq.Header.Set("Authorization", "Bearer "+c.apiToken)
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("close tracks request: %w", err)
 	}
@@ -234,7 +254,7 @@ func (c *Client) GetSessionState(ctx context.Context, sessionID string) (*GetSes
 	}
 	req.Header.Set("Authorization", "Bearer "+c.apiToken)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("get session state request: %w", err)
 	}
@@ -259,7 +279,8 @@ func (c *Client) GetSessionState(ctx context.Context, sessionID string) (*GetSes
 			stateResp.ErrorCode, stateResp.ErrorDesc)
 	}
 
-	c.logger.Info("retrieved session state",
+	// Debug: polled periodically by the relay's session state check
+	c.logger.Debug("retrieved session state",
 		"session_id", sessionID,
 		"track_count", len(stateResp.Tracks))
 
@@ -282,7 +303,7 @@ func (c *Client) UpdateTracks(ctx context.Context, sessionID string, req *Update
 	httpReq.Header.Set("Authorization", "Bearer "+c.apiToken)
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("update tracks request: %w", err)
 	}
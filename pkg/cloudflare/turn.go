@@ -0,0 +1,119 @@
+package cloudflare
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/httpx"
+)
+
+const (
+	// turnCredentialTTL is the lifetime requested for TURN credentials
+	turnCredentialTTL = 24 * time.Hour
+
+	// turnRefreshMargin is how long before expiry cached credentials are
+	// replaced, so a connection never starts with ones about to lapse
+	turnRefreshMargin = time.Hour
+)
+
+// TURNClient issues short-lived credentials for Cloudflare's TURN service
+// (https://developers.cloudflare.com/calls/turn/), caching them until they
+// are close to expiring
+type TURNClient struct {
+	keyID      string
+	apiToken   string
+	httpClient *http.Client
+	logger     *slog.Logger
+
+	mu      sync.Mutex
+	servers []webrtc.ICEServer
+	expires time.Time
+}
+
+// NewTURNClient creates a client for a TURN key and its API token
+func NewTURNClient(keyID, apiToken string, logger *slog.Logger) *TURNClient {
+	return &TURNClient{
+		keyID:      keyID,
+		apiToken:   apiToken,
+		httpClient: httpx.NewClient(httpx.Config{Timeout: 30 * time.Second}),
+		logger:     logger,
+	}
+}
+
+// SetHTTPClient replaces the client used for TURN API requests
+func (c *TURNClient) SetHTTPClient(hc *http.Client) {
+	c.httpClient = hc
+}
+
+// turnCredentialsResponse is the body of a generate credentials response
+type turnCredentialsResponse struct {
+	ICEServers struct {
+		URLs       []string `json:"urls"`
+		Username   string   `json:"username"`
+		Credential string   `json:"credential"`
+	} `json:"iceServers"`
+}
+
+// ICEServers returns the TURN servers with valid credentials, generating
+// new ones when the cached set is missing or about to expire
+func (c *TURNClient) ICEServers(ctx context.Context) ([]webrtc.ICEServer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.servers != nil && time.Until(c.expires) > turnRefreshMargin {
+		return c.servers, nil
+	}
+
+	url := fmt.Sprintf("%s/turn/keys/%s/credentials/generate", baseURL, c.keyID)
+	bodyBytes, err := json.Marshal(map[string]int64{"ttl": int64(turnCredentialTTL / time.Second)})
+	if err != nil {
+		return nil, fmt.Errorf("marshal TURN credentials request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("TURN credentials request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("generate TURN credentials failed: %s (status %d)", body, resp.StatusCode)
+	}
+
+	var credResp turnCredentialsResponse
+	if err := json.Unmarshal(body, &credResp); err != nil {
+		return nil, fmt.Errorf("decode TURN credentials response: %w", err)
+	}
+	if len(credResp.ICEServers.URLs) == 0 {
+		return nil, fmt.Errorf("TURN credentials response has no server URLs")
+	}
+
+	c.servers = []webrtc.ICEServer{{
+		URLs:       credResp.ICEServers.URLs,
+		Username:   credResp.ICEServers.Username,
+		Credential: credResp.ICEServers.Credential,
+	}}
+	c.expires = time.Now().Add(turnCredentialTTL)
+	c.logger.Info("generated TURN credentials", "urls", credResp.ICEServers.URLs, "expires_at", c.expires)
+	return c.servers, nil
+}
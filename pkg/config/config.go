@@ -5,13 +5,152 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Config holds all credentials and configuration for the relay
 type Config struct {
 	Google     GoogleConfig
+	Accounts   map[string]*GoogleConfig // google.<name>.<key>: further Device Access projects, keyed by name
 	Cloudflare CloudflareConfig
+	SFU        SFUConfig
+	Recording  RecordingConfig
+	DVR        DVRConfig
+	TimeLapse  TimeLapseConfig
+	Alerts     AlertsConfig
+	API        APIConfig
+	Cameras    map[string]*CameraConfig // Keyed by device ID
+	StatePath  string                   // bbolt file for persistent state; empty keeps state in memory
+	HALockPath string                   // Leader lock file shared by active/standby instances
+	Rotation   RotationConfig
+	Thumbnails ThumbnailConfig
+	Memory     MemoryConfig
+	HTTP       HTTPConfig
+	Proxy      ProxyConfig
+	Events     EventsConfig
+	WebRTC     WebRTCConfig
+	RTSPServer RTSPServerConfig
+	FFmpegPath string   // ffmpeg binary for cameras with transcoding enabled
+	PluginRPC  []string // Out-of-process plugin addresses, "unix:/path" or "tcp:host:port"
+
+	CameraLogPath string // camera_log_path: per-camera log file template, e.g. logs/{camera_id}.log
+
+	CaptureDir   string // capture_dir: write each camera's incoming RTP to pcap files for cmd/replay
+	CaptureLimit int64  // capture_limit: bytes per camera capture file, e.g. 256MB
+
+	EgressBudget int64 // egress_budget: monthly bytes sent to the SFU before warnings, e.g. 500GB
+
+	DumpDir string // dump_dir: where SIGUSR1 writes diagnostic bundles; defaults to the temp directory
+
+	ReorderDepth int // reorder_depth: RTP packets held past a sequence gap (default 8, -1 disables reordering)
+
+	AllowOverQuota  bool          // allow_over_quota: start even when stream extensions would exceed the SDM quota
+	SelfTest        bool          // self_test: check credentials, the SFU and one camera's pipeline before reporting ready
+	ResumeStreams   bool          // resume_streams: leave Nest streams up on shutdown and adopt them on the next start
+	Stagger         string        // stagger: camera startup pacing, "adaptive" (default) or "fixed"
+	StaggerInterval time.Duration // stagger_interval: delay between cameras with fixed stagger
+	DeviceRefresh   time.Duration // device_refresh: how often the projects' cameras are re-listed to pick up added, removed and renamed ones; zero lists once
+}
+
+// RotationConfig enables rotation when the fleet exceeds what the SDM quota
+// or uplink can relay at once. Rotation is off unless Slots is set.
+type RotationConfig struct {
+	Slots    int           // rotation_slots: cameras relayed simultaneously
+	Interval time.Duration // rotation_interval: how often the active set changes
+}
+
+// ThumbnailConfig enables periodic camera thumbnails. It is off unless
+// Interval is set.
+type ThumbnailConfig struct {
+	Interval time.Duration // thumbnail_interval: how often thumbnails are refreshed, e.g. 30s
+	Width    int           // thumbnail_width: pixels (default 320)
+}
+
+// Enabled reports whether thumbnails are configured
+func (t ThumbnailConfig) Enabled() bool {
+	return t.Interval > 0
+}
+
+// MemoryConfig caps the bytes relays buffer (pacer queues, DVR segments,
+// pending thumbnails). Zero leaves a cap off.
+type MemoryConfig struct {
+	Budget      int64 // memory_budget: across all cameras, e.g. 512MB
+	CameraLimit int64 // memory_camera_limit: per camera, e.g. 32MB
+}
+
+// Enabled reports whether either cap is configured
+func (m MemoryConfig) Enabled() bool {
+	return m.Budget > 0 || m.CameraLimit > 0
+}
+
+// HTTPConfig tunes the outbound clients for the Google, Cloudflare and
+// LiveKit APIs. Zero values use the httpx defaults.
+type HTTPConfig struct {
+	Timeout         time.Duration // http_timeout: per request (default 30s)
+	MaxConnsPerHost int           // http_max_conns_per_host: connection pool size per API host (default 64)
+	Retries         int           // http_retries: retries of idempotent requests after 5xx or connection errors (default 0)
+	RetryBudget     float64       // http_retry_budget: fraction of requests that may be retried, e.g. 0.1
+}
+
+// ProxyConfig sends the API clients and RTSP connections through an
+// outbound proxy. It is off unless URL is set; WebRTC media never uses it.
+type ProxyConfig struct {
+	URL    string // proxy_url: http://, https://, socks5:// or socks5h://, with optional user:password
+	Bypass string // proxy_bypass: comma-separated hosts, .domain suffixes and CIDRs reached directly, e.g. local cameras
+}
+
+// EventsConfig subscribes to the Device Access project's Pub/Sub topic for
+// camera motion, person, sound and doorbell events. It is off unless
+// Subscription is set.
+type EventsConfig struct {
+	Subscription string // pubsub_subscription: projects/{gcp-project}/subscriptions/{name}
+	Retain       int    // events_retain: events kept per camera for /api/events (default 50)
+}
+
+// Enabled reports whether the event subscription is configured
+func (e EventsConfig) Enabled() bool {
+	return e.Subscription != ""
+}
+
+// WebRTCConfig tunes packetization and SDP negotiation with the SFU. Zero
+// values use the bridge defaults.
+type WebRTCConfig struct {
+	MTU              int      // webrtc_mtu: largest RTP payload in bytes (default 1200); lower it behind VPNs
+	VideoPayloadType uint8    // webrtc_video_pt: payload type offered for video (default 96)
+	AudioPayloadType uint8    // webrtc_audio_pt: payload type offered for Opus (default 111)
+	H264Fmtp         string   // webrtc_h264_fmtp: SDP fmtp line offered for H.264
+	ICEServers       []string // webrtc_ice_servers: comma-separated stun: or turn: URLs
+	SampleTrack      bool     // webrtc_sample_track: let pion packetize video (TrackLocalStaticSample) instead of the bridge
+
+	TURNURLs       []string // webrtc_turn_urls: comma-separated turn: or turns: URLs, for relays behind symmetric NAT
+	TURNUsername   string   // webrtc_turn_username
+	TURNCredential string   // webrtc_turn_credential
+}
+
+// RTSPServerConfig enables the built-in RTSP server that re-publishes each
+// camera for local NVRs. It is off unless Addr is set.
+type RTSPServerConfig struct {
+	Addr     string // rtsp_server_addr: listen address, e.g. :8554
+	Username string // rtsp_server_username: Basic auth; empty leaves the server open
+	Password string // rtsp_server_password
+}
+
+// Enabled reports whether the RTSP server is configured
+func (r RTSPServerConfig) Enabled() bool {
+	return r.Addr != ""
+}
+
+// APIConfig secures the HTTP API and viewer
+type APIConfig struct {
+	AdminToken     string        // admin_token: bearer token for privileged endpoints
+	ViewerTokenKey string        // viewer_token_key: HMAC key; when set, viewers need a signed token
+	ViewerTokenTTL time.Duration // viewer_token_ttl: lifetime of minted viewer tokens (default 1h)
+
+	ViewerSessionLimit int           // viewer_session_limit: open viewer sessions per client (default 8)
+	ViewerIdleTimeout  time.Duration // viewer_idle_timeout: close viewer sessions idle this long (default 30m)
 }
 
 // GoogleConfig holds Google OAuth2 and SDM API credentials
@@ -26,6 +165,394 @@ type GoogleConfig struct {
 type CloudflareConfig struct {
 	AppID    string
 	APIToken string
+
+	// Cloudflare TURN key; when set, relays get short-lived TURN credentials
+	TURNKeyID    string // turn_key_id
+	TURNAPIToken string // turn_api_token
+}
+
+// SFU backend names accepted by the sfu_backend key
+const (
+	SFUCloudflare = "cloudflare"
+	SFULiveKit    = "livekit"
+)
+
+// SFUConfig selects which SFU camera tracks are published to
+type SFUConfig struct {
+	Backend string // "cloudflare" (default) or "livekit"
+	LiveKit LiveKitConfig
+}
+
+// LiveKitConfig holds LiveKit server API credentials
+type LiveKitConfig struct {
+	URL       string
+	APIKey    string
+	APISecret string
+	Room      string
+}
+
+// CameraConfig holds per-camera settings, written in the .env file as
+// camera.<device_id>.<option>=value
+type CameraConfig struct {
+	Name  string // Display name; overrides the Nest custom or room name
+	Order int    // Display position; lower sorts first, unset (zero) after all others
+
+	RTMPURL string // RTMP ingest server, e.g. rtmp://a.rtmp.youtube.com/live2
+	RTMPKey string // Stream key appended to RTMPURL
+
+	Priority int // Rotation priority; higher stays active longer
+
+	TranscodeVideo bool // Re-encode video to H.264 with ffmpeg
+	TranscodeAudio bool // Transcode AAC to Opus with ffmpeg
+	MaxBitrate     int  // Video bitrate cap in bits/s; implies TranscodeVideo
+
+	AudioOnly bool // Relay only audio (baby monitors, intercoms); implies TranscodeAudio
+	Audio     bool // Forward audio; off by default, implied by TranscodeAudio and AudioOnly
+	Talkback  bool // Offer to send audio to the camera's speaker (WebRTC cameras only)
+
+	// Pacer tuning; zero uses the bridge defaults
+	PacerQueue            int           // Packets buffered per track
+	PacerCatchupThreshold int           // Queue depth that starts catch-up
+	PacerCatchupSpeed     float64       // Speed-up while catching up, e.g. 1.1
+	PacerMaxDelay         time.Duration // Longest wait before one packet
+	PacerLatencyBudget    time.Duration // Most video queued before skipping to a keyframe; zero is unbounded
+	PacerBypass           bool          // Write packets as they arrive, without pacing
+
+	RTSPTransport string // "tcp" (interleaved, the default), "udp", or "http" (tunnelled)
+
+	// RTSP client timeouts; zero uses the client defaults
+	RTSPReadTimeout     time.Duration // Media silence before the read loop warns
+	RTSPResponseTimeout time.Duration // Wait for a response to a request
+	RTSPWriteTimeout    time.Duration // Each write to the connection
+	RTSPKeepalive       time.Duration // Most time between keepalives
+
+	RTSPKeepaliveMethod string // "options", "get_parameter", or "" to follow the server's Public header
+	RTSPReconnect       int    // Times the client re-dials a dropped session before the relay is recreated
+
+	// RTSPURL reads the camera from a fixed RTSP URL (a local ONVIF camera,
+	// mediamtx) instead of Nest; the camera's ID is then any name
+	RTSPURL string
+
+	// rtsps:// settings; zero verifies the server against the system roots
+	RTSPTLSCA         string // PEM file of extra trusted roots
+	RTSPTLSServerName string // SNI and verified name instead of the URL's host
+	RTSPTLSInsecure   bool   // Skip certificate verification (lab cameras only)
+	RTSPTLSMinVersion string // "1.2", "1.3", ...; empty is the Go default
+}
+
+// AudioEnabled reports whether the camera's audio is forwarded. Audio is off
+// unless a camera opts in, since AAC and G.711 cost an ffmpeg process each.
+func (c *CameraConfig) AudioEnabled() bool {
+	return c != nil && (c.Audio || c.TranscodeAudio || c.AudioOnly)
+}
+
+// Transcodes reports whether the camera needs the ffmpeg stage
+func (c *CameraConfig) Transcodes() bool {
+	return c != nil && (c.TranscodeVideo || c.TranscodeAudio || c.MaxBitrate > 0 || c.AudioOnly)
+}
+
+// RTMPTarget returns the full RTMP publish URL, or "" when restreaming is off
+func (c *CameraConfig) RTMPTarget() string {
+	if c == nil || c.RTMPURL == "" {
+		return ""
+	}
+	if c.RTMPKey == "" {
+		return c.RTMPURL
+	}
+	return strings.TrimSuffix(c.RTMPURL, "/") + "/" + c.RTMPKey
+}
+
+// Camera returns the settings for a device, or nil if none are configured
+func (c *Config) Camera(deviceID string) *CameraConfig {
+	return c.Cameras[deviceID]
+}
+
+// StaticCameras returns the IDs of the cameras read from an rtsp_url rather
+// than Nest, sorted
+func (c *Config) StaticCameras() []string {
+	var ids []string
+	for id, cam := range c.Cameras {
+		if cam.RTSPURL != "" {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// GoogleAccounts returns the credentials of every Device Access project:
+// Google first, then Accounts in name order
+func (c *Config) GoogleAccounts() []GoogleConfig {
+	accounts := []GoogleConfig{c.Google}
+	names := make([]string, 0, len(c.Accounts))
+	for name := range c.Accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		accounts = append(accounts, *c.Accounts[name])
+	}
+	return accounts
+}
+
+// setAccountOption applies a google.<name>.<key> key, whose keys are those
+// of the main Google credentials
+func (c *Config) setAccountOption(key, value string) error {
+	parts := strings.SplitN(key, ".", 3)
+	if len(parts) != 3 || parts[1] == "" {
+		return fmt.Errorf("invalid %s: want google.<name>.<key>", key)
+	}
+
+	if c.Accounts == nil {
+		c.Accounts = make(map[string]*GoogleConfig)
+	}
+	account, ok := c.Accounts[parts[1]]
+	if !ok {
+		account = &GoogleConfig{}
+		c.Accounts[parts[1]] = account
+	}
+
+	switch parts[2] {
+	case "client_id":
+		account.ClientID = value
+	case "client_secret":
+		account.ClientSecret = value
+	case "project_id":
+		account.ProjectID = value
+	case "refresh_token":
+		account.RefreshToken = value
+	default:
+		return fmt.Errorf("unknown option %s", key)
+	}
+	return nil
+}
+
+// NestEnabled reports whether Nest cameras are relayed: always, unless every
+// camera has an rtsp_url and no Google credentials are set. Further
+// accounts need the main credentials too.
+func (c *Config) NestEnabled() bool {
+	return c.Google != (GoogleConfig{}) || len(c.Accounts) > 0 || len(c.StaticCameras()) == 0
+}
+
+// setCameraOption applies a camera.<device_id>.<option> key
+func (c *Config) setCameraOption(key, value string) error {
+	parts := strings.SplitN(key, ".", 3)
+	if len(parts) != 3 || parts[1] == "" {
+		return nil
+	}
+
+	if c.Cameras == nil {
+		c.Cameras = make(map[string]*CameraConfig)
+	}
+	cam, ok := c.Cameras[parts[1]]
+	if !ok {
+		cam = &CameraConfig{}
+		c.Cameras[parts[1]] = cam
+	}
+
+	switch parts[2] {
+	case "name":
+		cam.Name = value
+	case "order":
+		o, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		cam.Order = o
+	case "rtmp_url":
+		cam.RTMPURL = value
+	case "rtmp_key":
+		cam.RTMPKey = value
+	case "priority":
+		p, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		cam.Priority = p
+	case "transcode":
+		for _, track := range strings.Split(value, ",") {
+			switch strings.TrimSpace(track) {
+			case "video":
+				cam.TranscodeVideo = true
+			case "audio":
+				cam.TranscodeAudio = true
+			case "", "none":
+			default:
+				return fmt.Errorf("invalid %s: unknown track %q", key, track)
+			}
+		}
+	case "max_bitrate":
+		b, err := parseBitrate(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		cam.MaxBitrate = b
+	case "audio_only":
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		cam.AudioOnly = v
+	case "audio":
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		cam.Audio = v
+	case "talkback":
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		cam.Talkback = v
+	case "pacer_queue":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		cam.PacerQueue = n
+	case "pacer_catchup_threshold":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		cam.PacerCatchupThreshold = n
+	case "pacer_catchup_speed":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		cam.PacerCatchupSpeed = f
+	case "pacer_max_delay":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		cam.PacerMaxDelay = d
+	case "pacer_latency_budget":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		cam.PacerLatencyBudget = d
+	case "pacer_bypass":
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		cam.PacerBypass = v
+	case "rtsp_transport":
+		switch v := strings.ToLower(value); v {
+		case "tcp", "udp", "http":
+			cam.RTSPTransport = v
+		default:
+			return fmt.Errorf("invalid %s: want tcp, udp or http, got %q", key, value)
+		}
+	case "rtsp_keepalive_method":
+		switch v := strings.ToLower(value); v {
+		case "auto":
+			cam.RTSPKeepaliveMethod = ""
+		case "options", "get_parameter":
+			cam.RTSPKeepaliveMethod = v
+		default:
+			return fmt.Errorf("invalid %s: want auto, options or get_parameter, got %q", key, value)
+		}
+	case "rtsp_url":
+		u, err := url.Parse(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		if u.Scheme != "rtsp" && u.Scheme != "rtsps" {
+			return fmt.Errorf("invalid %s: want an rtsp:// or rtsps:// URL", key)
+		}
+		cam.RTSPURL = value
+	case "rtsp_tls_ca":
+		cam.RTSPTLSCA = value // Read when the service is built
+	case "rtsp_tls_server_name":
+		cam.RTSPTLSServerName = value
+	case "rtsp_tls_insecure":
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		cam.RTSPTLSInsecure = v
+	case "rtsp_tls_min_version":
+		switch v := strings.TrimPrefix(strings.ToLower(value), "tls"); v {
+		case "1.0", "1.1", "1.2", "1.3":
+			cam.RTSPTLSMinVersion = v
+		default:
+			return fmt.Errorf("invalid %s: want 1.0, 1.1, 1.2 or 1.3, got %q", key, value)
+		}
+	case "rtsp_reconnect":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		cam.RTSPReconnect = n
+	case "rtsp_read_timeout", "rtsp_response_timeout", "rtsp_write_timeout", "rtsp_keepalive":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		if d < 0 {
+			return fmt.Errorf("invalid %s: negative duration", key)
+		}
+		switch parts[2] {
+		case "rtsp_read_timeout":
+			cam.RTSPReadTimeout = d
+		case "rtsp_response_timeout":
+			cam.RTSPResponseTimeout = d
+		case "rtsp_write_timeout":
+			cam.RTSPWriteTimeout = d
+		default:
+			cam.RTSPKeepalive = d
+		}
+	}
+	return nil
+}
+
+// parseBitrate parses bits/s with an optional k or M suffix, e.g. "1500k"
+func parseBitrate(s string) (int, error) {
+	mult := 1
+	switch {
+	case strings.HasSuffix(s, "k"), strings.HasSuffix(s, "K"):
+		mult, s = 1000, s[:len(s)-1]
+	case strings.HasSuffix(s, "M"), strings.HasSuffix(s, "m"):
+		mult, s = 1000000, s[:len(s)-1]
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("bitrate must be positive")
+	}
+	return n * mult, nil
+}
+
+// parseBytes parses a byte count with an optional K, M or G suffix (powers
+// of 1024, "B" and "iB" accepted), e.g. "512MB"
+func parseBytes(s string) (int64, error) {
+	s = strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(s), "B"), "I")
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult, s = 1<<10, s[:len(s)-1]
+	case strings.HasSuffix(s, "M"):
+		mult, s = 1<<20, s[:len(s)-1]
+	case strings.HasSuffix(s, "G"):
+		mult, s = 1<<30, s[:len(s)-1]
+	case strings.HasSuffix(s, "T"):
+		mult, s = 1<<40, s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("size must be positive")
+	}
+	return n * mult, nil
 }
 
 // Load reads configuration from a .env file
@@ -36,33 +563,16 @@ func Load(envPath string) (*Config, error) {
 	}
 	defer file.Close()
 
-	cfg := &Config{}
+	cfg := &Config{SFU: SFUConfig{Backend: SFUCloudflare}}
 	scanner := bufio.NewScanner(file)
 
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		// Parse key=value
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
+		key, decodedValue, ok := parseLine(scanner.Text())
+		if !ok {
 			continue
 		}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-
-		// URL decode values that might be encoded
-		decodedValue, err := url.QueryUnescape(value)
-		if err != nil {
-			// If decode fails, use original value
-			decodedValue = value
-		}
-
+		var err error
 		switch key {
 		case "client_id":
 			cfg.Google.ClientID = decodedValue
@@ -76,6 +586,206 @@ func Load(envPath string) (*Config, error) {
 			cfg.Cloudflare.AppID = decodedValue
 		case "api_token":
 			cfg.Cloudflare.APIToken = decodedValue
+		case "turn_key_id":
+			cfg.Cloudflare.TURNKeyID = decodedValue
+		case "turn_api_token":
+			cfg.Cloudflare.TURNAPIToken = decodedValue
+		case "sfu_backend":
+			cfg.SFU.Backend = strings.ToLower(decodedValue)
+		case "livekit_url":
+			cfg.SFU.LiveKit.URL = decodedValue
+		case "livekit_api_key":
+			cfg.SFU.LiveKit.APIKey = decodedValue
+		case "livekit_api_secret":
+			cfg.SFU.LiveKit.APISecret = decodedValue
+		case "livekit_room":
+			cfg.SFU.LiveKit.Room = decodedValue
+		case "state_path":
+			cfg.StatePath = decodedValue
+		case "ha_lock_path":
+			cfg.HALockPath = decodedValue
+		case "admin_token":
+			cfg.API.AdminToken = decodedValue
+		case "viewer_token_key":
+			cfg.API.ViewerTokenKey = decodedValue
+		case "viewer_session_limit":
+			if cfg.API.ViewerSessionLimit, err = strconv.Atoi(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid viewer_session_limit: %w", err)
+			}
+		case "viewer_idle_timeout":
+			if cfg.API.ViewerIdleTimeout, err = time.ParseDuration(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid viewer_idle_timeout: %w", err)
+			}
+		case "viewer_token_ttl":
+			if cfg.API.ViewerTokenTTL, err = time.ParseDuration(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid viewer_token_ttl: %w", err)
+			}
+		case "ffmpeg_path":
+			cfg.FFmpegPath = decodedValue
+		case "plugin_rpc":
+			for _, addr := range strings.Split(decodedValue, ",") {
+				if addr = strings.TrimSpace(addr); addr == "" {
+					continue
+				}
+				if network, _, ok := strings.Cut(addr, ":"); !ok || (network != "unix" && network != "tcp") {
+					return nil, fmt.Errorf("invalid plugin_rpc address %q: want unix:/path or tcp:host:port", addr)
+				}
+				cfg.PluginRPC = append(cfg.PluginRPC, addr)
+			}
+		case "rotation_slots":
+			if cfg.Rotation.Slots, err = strconv.Atoi(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid rotation_slots: %w", err)
+			}
+		case "rotation_interval":
+			if cfg.Rotation.Interval, err = time.ParseDuration(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid rotation_interval: %w", err)
+			}
+		case "thumbnail_interval":
+			if cfg.Thumbnails.Interval, err = time.ParseDuration(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid thumbnail_interval: %w", err)
+			}
+		case "thumbnail_width":
+			if cfg.Thumbnails.Width, err = strconv.Atoi(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid thumbnail_width: %w", err)
+			}
+		case "allow_over_quota":
+			if cfg.AllowOverQuota, err = strconv.ParseBool(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid allow_over_quota: %w", err)
+			}
+		case "self_test":
+			if cfg.SelfTest, err = strconv.ParseBool(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid self_test: %w", err)
+			}
+		case "resume_streams":
+			if cfg.ResumeStreams, err = strconv.ParseBool(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid resume_streams: %w", err)
+			}
+		case "device_refresh":
+			if cfg.DeviceRefresh, err = time.ParseDuration(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid device_refresh: %w", err)
+			}
+		case "camera_log_path":
+			cfg.CameraLogPath = decodedValue
+		case "capture_dir":
+			cfg.CaptureDir = decodedValue
+		case "capture_limit":
+			if cfg.CaptureLimit, err = parseBytes(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid capture_limit: %w", err)
+			}
+		case "webrtc_mtu":
+			if cfg.WebRTC.MTU, err = strconv.Atoi(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid webrtc_mtu: %w", err)
+			}
+		case "webrtc_video_pt":
+			pt, err := strconv.ParseUint(decodedValue, 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid webrtc_video_pt: %w", err)
+			}
+			cfg.WebRTC.VideoPayloadType = uint8(pt)
+		case "webrtc_audio_pt":
+			pt, err := strconv.ParseUint(decodedValue, 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid webrtc_audio_pt: %w", err)
+			}
+			cfg.WebRTC.AudioPayloadType = uint8(pt)
+		case "webrtc_sample_track":
+			if cfg.WebRTC.SampleTrack, err = strconv.ParseBool(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid webrtc_sample_track: %w", err)
+			}
+		case "webrtc_h264_fmtp":
+			cfg.WebRTC.H264Fmtp = decodedValue
+		case "webrtc_turn_urls":
+			for _, u := range strings.Split(decodedValue, ",") {
+				if u = strings.TrimSpace(u); u != "" {
+					cfg.WebRTC.TURNURLs = append(cfg.WebRTC.TURNURLs, u)
+				}
+			}
+		case "webrtc_turn_username":
+			cfg.WebRTC.TURNUsername = decodedValue
+		case "webrtc_turn_credential":
+			cfg.WebRTC.TURNCredential = decodedValue
+		case "webrtc_ice_servers":
+			for _, u := range strings.Split(decodedValue, ",") {
+				if u = strings.TrimSpace(u); u != "" {
+					cfg.WebRTC.ICEServers = append(cfg.WebRTC.ICEServers, u)
+				}
+			}
+		case "reorder_depth":
+			if cfg.ReorderDepth, err = strconv.Atoi(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid reorder_depth: %w", err)
+			}
+		case "dump_dir":
+			cfg.DumpDir = decodedValue
+		case "egress_budget":
+			if cfg.EgressBudget, err = parseBytes(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid egress_budget: %w", err)
+			}
+		case "memory_budget":
+			if cfg.Memory.Budget, err = parseBytes(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid memory_budget: %w", err)
+			}
+		case "memory_camera_limit":
+			if cfg.Memory.CameraLimit, err = parseBytes(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid memory_camera_limit: %w", err)
+			}
+		case "http_timeout":
+			if cfg.HTTP.Timeout, err = time.ParseDuration(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid http_timeout: %w", err)
+			}
+		case "http_max_conns_per_host":
+			if cfg.HTTP.MaxConnsPerHost, err = strconv.Atoi(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid http_max_conns_per_host: %w", err)
+			}
+		case "http_retries":
+			if cfg.HTTP.Retries, err = strconv.Atoi(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid http_retries: %w", err)
+			}
+		case "http_retry_budget":
+			if cfg.HTTP.RetryBudget, err = strconv.ParseFloat(decodedValue, 64); err != nil {
+				return nil, fmt.Errorf("invalid http_retry_budget: %w", err)
+			}
+		case "proxy_url":
+			cfg.Proxy.URL = decodedValue
+		case "proxy_bypass":
+			cfg.Proxy.Bypass = decodedValue
+		case "pubsub_subscription":
+			cfg.Events.Subscription = decodedValue
+		case "events_retain":
+			if cfg.Events.Retain, err = strconv.Atoi(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid events_retain: %w", err)
+			}
+		case "rtsp_server_addr":
+			cfg.RTSPServer.Addr = decodedValue
+		case "rtsp_server_username":
+			cfg.RTSPServer.Username = decodedValue
+		case "rtsp_server_password":
+			cfg.RTSPServer.Password = decodedValue
+		case "stagger":
+			cfg.Stagger = decodedValue
+		case "stagger_interval":
+			if cfg.StaggerInterval, err = time.ParseDuration(decodedValue); err != nil {
+				return nil, fmt.Errorf("invalid stagger_interval: %w", err)
+			}
+		default:
+			switch {
+			case strings.HasPrefix(key, "google."):
+				if err := cfg.setAccountOption(key, decodedValue); err != nil {
+					return nil, err
+				}
+			case strings.HasPrefix(key, "camera."):
+				if err := cfg.setCameraOption(key, decodedValue); err != nil {
+					return nil, err
+				}
+			case strings.HasPrefix(key, "record_"), strings.HasPrefix(key, "upload_"),
+				strings.HasPrefix(key, "dvr_"), strings.HasPrefix(key, "timelapse_"):
+				if err := cfg.setRecordingOption(key, decodedValue); err != nil {
+					return nil, err
+				}
+			case strings.HasPrefix(key, "alert_"):
+				if err := cfg.setAlertOption(key, decodedValue); err != nil {
+					return nil, err
+				}
+			}
 		}
 	}
 
@@ -93,23 +803,108 @@ func Load(envPath string) (*Config, error) {
 
 // Validate checks that all required configuration fields are present
 func (c *Config) Validate() error {
-	if c.Google.ClientID == "" {
-		return fmt.Errorf("missing client_id")
+	if c.NestEnabled() {
+		if c.Google.ClientID == "" {
+			return fmt.Errorf("missing client_id")
+		}
+		if c.Google.ClientSecret == "" {
+			return fmt.Errorf("missing client_secret")
+		}
+		if c.Google.ProjectID == "" {
+			return fmt.Errorf("missing project_id")
+		}
+		if c.Google.RefreshToken == "" {
+			return fmt.Errorf("missing refresh_token")
+		}
 	}
-	if c.Google.ClientSecret == "" {
-		return fmt.Errorf("missing client_secret")
+	projects := map[string]bool{c.Google.ProjectID: true}
+	names := make([]string, 0, len(c.Accounts))
+	for name := range c.Accounts {
+		names = append(names, name)
 	}
-	if c.Google.ProjectID == "" {
-		return fmt.Errorf("missing project_id")
+	sort.Strings(names)
+	for _, name := range names {
+		account := c.Accounts[name]
+		for _, f := range []struct{ key, value string }{
+			{"client_id", account.ClientID},
+			{"client_secret", account.ClientSecret},
+			{"project_id", account.ProjectID},
+			{"refresh_token", account.RefreshToken},
+		} {
+			if f.value == "" {
+				return fmt.Errorf("missing google.%s.%s", name, f.key)
+			}
+		}
+		if projects[account.ProjectID] {
+			return fmt.Errorf("google.%s.project_id: project %s is configured twice", name, account.ProjectID)
+		}
+		projects[account.ProjectID] = true
 	}
-	if c.Google.RefreshToken == "" {
-		return fmt.Errorf("missing refresh_token")
+
+	switch c.SFU.Backend {
+	case "", SFUCloudflare:
+		if c.Cloudflare.AppID == "" {
+			return fmt.Errorf("missing app_id")
+		}
+		if c.Cloudflare.APIToken == "" {
+			return fmt.Errorf("missing api_token")
+		}
+	case SFULiveKit:
+		if c.SFU.LiveKit.URL == "" {
+			return fmt.Errorf("missing livekit_url")
+		}
+		if c.SFU.LiveKit.APIKey == "" {
+			return fmt.Errorf("missing livekit_api_key")
+		}
+		if c.SFU.LiveKit.APISecret == "" {
+			return fmt.Errorf("missing livekit_api_secret")
+		}
+		if c.SFU.LiveKit.Room == "" {
+			return fmt.Errorf("missing livekit_room")
+		}
+	default:
+		return fmt.Errorf("unknown sfu_backend %q", c.SFU.Backend)
 	}
-	if c.Cloudflare.AppID == "" {
-		return fmt.Errorf("missing app_id")
+
+	if (c.Cloudflare.TURNKeyID == "") != (c.Cloudflare.TURNAPIToken == "") {
+		return fmt.Errorf("turn_key_id and turn_api_token must be set together")
 	}
-	if c.Cloudflare.APIToken == "" {
-		return fmt.Errorf("missing api_token")
+
+	if (c.RTSPServer.Username == "") != (c.RTSPServer.Password == "") {
+		return fmt.Errorf("rtsp_server_username and rtsp_server_password must be set together")
 	}
-	return nil
+
+	if c.Proxy.URL != "" {
+		u, err := url.Parse(c.Proxy.URL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy_url: %w", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("invalid proxy_url: want an http, https, socks5 or socks5h URL, got %q", u.Redacted())
+		}
+	}
+
+	if sub := c.Events.Subscription; sub != "" {
+		project, name, ok := strings.Cut(strings.TrimPrefix(sub, "projects/"), "/subscriptions/")
+		if !strings.HasPrefix(sub, "projects/") || !ok || project == "" || name == "" {
+			return fmt.Errorf("invalid pubsub_subscription %q: want projects/{project}/subscriptions/{name}", sub)
+		}
+	}
+
+	switch c.Stagger {
+	case "", "adaptive", "fixed":
+	default:
+		return fmt.Errorf("unknown stagger %q (want adaptive or fixed)", c.Stagger)
+	}
+
+	if k := c.API.ViewerTokenKey; k != "" && len(k) < 16 {
+		return fmt.Errorf("viewer_token_key must be at least 16 characters")
+	}
+
+	if err := c.validateRecording(); err != nil {
+		return err
+	}
+	return c.validateAlerts()
 }
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStaticCameras(t *testing.T) {
+	cfg := &Config{Cloudflare: CloudflareConfig{AppID: "app", APIToken: "token"}}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("config without cameras or Google credentials validated")
+	}
+
+	for key, value := range map[string]string{
+		"camera.porch.rtsp_url":  "rtsp://user:pw@192.168.1.20/stream1",
+		"camera.garage.rtsp_url": "rtsps://mediamtx.local:8322/garage",
+		"camera.porch.name":      "Porch",
+	} {
+		if err := cfg.setCameraOption(key, value); err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+	}
+	if err := cfg.setCameraOption("camera.attic.rtsp_url", "http://192.168.1.30/"); err == nil {
+		t.Error("accepted an http:// rtsp_url")
+	}
+
+	if ids := cfg.StaticCameras(); !slices.Equal(ids, []string{"garage", "porch"}) {
+		t.Errorf("static cameras %v", ids)
+	}
+	if cfg.NestEnabled() {
+		t.Error("Nest enabled without Google credentials")
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("static-only config: %v", err)
+	}
+
+	// Any Google credential means Nest is wanted, and needs the rest
+	cfg.Google.ClientID = "client-id"
+	if err := cfg.Validate(); err == nil {
+		t.Error("partial Google credentials validated")
+	}
+}
+
+func TestGoogleAccounts(t *testing.T) {
+	cfg := &Config{
+		Cloudflare: CloudflareConfig{AppID: "app", APIToken: "token"},
+		Google:     GoogleConfig{ClientID: "id", ClientSecret: "secret", ProjectID: "main", RefreshToken: "refresh"},
+	}
+	set := func(key, value string) {
+		t.Helper()
+		if err := cfg.setAccountOption(key, value); err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+	}
+	for _, name := range []string{"cabin", "beach"} {
+		set("google."+name+".client_id", name+"-id")
+		set("google."+name+".client_secret", name+"-secret")
+		set("google."+name+".refresh_token", name+"-refresh")
+	}
+	if err := cfg.setAccountOption("google.cabin.api_key", "x"); err == nil {
+		t.Error("accepted an unknown account option")
+	}
+	if err := cfg.setAccountOption("google..client_id", "x"); err == nil {
+		t.Error("accepted an account without a name")
+	}
+
+	if err := cfg.Validate(); err == nil || err.Error() != "missing google.beach.project_id" {
+		t.Errorf("Validate = %v, want a missing project_id", err)
+	}
+	set("google.beach.project_id", "beach-project")
+	set("google.cabin.project_id", "main")
+	if err := cfg.Validate(); err == nil {
+		t.Error("validated a project configured twice")
+	}
+	set("google.cabin.project_id", "cabin-project")
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var projects []string
+	for _, account := range cfg.GoogleAccounts() {
+		projects = append(projects, account.ProjectID)
+	}
+	if want := []string{"main", "beach-project", "cabin-project"}; !slices.Equal(projects, want) {
+		t.Errorf("accounts %v, want %v", projects, want)
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// parseLine splits a .env line into its key and URL-decoded value; blank
+// lines, comments and lines without '=' have none
+func parseLine(line string) (key, value string, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+	key, value, ok = strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+	// URL decode values that might be encoded
+	if decoded, err := url.QueryUnescape(value); err == nil {
+		value = decoded
+	}
+	return key, value, true
+}
+
+// ReadValues returns a .env file's raw settings by key, without the
+// validation Load applies, for tools that run before the file is complete
+func ReadValues(envPath string) (map[string]string, error) {
+	data, err := os.ReadFile(envPath)
+	if err != nil {
+		return nil, fmt.Errorf("read env file: %w", err)
+	}
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if key, value, ok := parseLine(scanner.Text()); ok {
+			values[key] = value
+		}
+	}
+	return values, scanner.Err()
+}
+
+// SetValues writes settings into a .env file, replacing each key's existing
+// line in place and appending keys it doesn't have; comments and other
+// lines are kept. The file is created, readable only by its owner, if
+// missing.
+func SetValues(envPath string, values map[string]string) error {
+	data, err := os.ReadFile(envPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read env file: %w", err)
+	}
+
+	var lines []string
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+	written := make(map[string]bool, len(values))
+	for i, line := range lines {
+		key, _, ok := parseLine(line)
+		if value, set := values[key]; ok && set {
+			lines[i] = key + "=" + encodeValue(value)
+			written[key] = true
+		}
+	}
+	for key, value := range values {
+		if !written[key] {
+			lines = append(lines, key+"="+encodeValue(value))
+		}
+	}
+
+	mode := os.FileMode(0o600)
+	if info, err := os.Stat(envPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp := envPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(lines, "\n")+"\n"), mode); err != nil {
+		return fmt.Errorf("write env file: %w", err)
+	}
+	if err := os.Rename(tmp, envPath); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("replace env file: %w", err)
+	}
+	return nil
+}
+
+// encodeValue escapes a value only if Load would otherwise decode it into
+// something else, e.g. a '+' or '%'
+func encodeValue(value string) string {
+	if decoded, err := url.QueryUnescape(value); err == nil && decoded == value {
+		return value
+	}
+	return url.QueryEscape(value)
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	orig := "## Google API ##\nclient_id=abc\nrefresh_token=old\n\n# keep me\napp_id=x\n"
+	if err := os.WriteFile(path, []byte(orig), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	err := SetValues(path, map[string]string{
+		"refresh_token": "1//0g-new+token",
+		"project_id":    "proj",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	got := string(data)
+	for _, want := range []string{"## Google API ##\nclient_id=abc\nrefresh_token=", "\n\n# keep me\napp_id=x\nproject_id=proj\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("file lacks %q:\n%s", want, got)
+		}
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o640 {
+		t.Errorf("mode = %v, want 0640 kept", info.Mode().Perm())
+	}
+
+	// Values read back as written, '+' included
+	values, err := ReadValues(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["refresh_token"] != "1//0g-new+token" || values["client_id"] != "abc" {
+		t.Errorf("values = %v", values)
+	}
+}
+
+func TestSetValuesCreates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := SetValues(path, map[string]string{"refresh_token": "tok"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "refresh_token=tok\n" {
+		t.Errorf("file = %q", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RecordingConfig enables writing camera footage to local segment files.
+// Recording is off unless Dir is set.
+type RecordingConfig struct {
+	Dir             string        // record_dir: root directory for segments
+	SegmentDuration time.Duration // record_segment: target segment length (default 1m)
+	Upload          UploadConfig
+}
+
+// UploadConfig ships closed segments to S3-compatible object storage.
+// Uploading is off unless Bucket is set.
+type UploadConfig struct {
+	Provider       string        // upload_provider: "s3" (default) or "gcs"
+	Endpoint       string        // upload_endpoint: override for MinIO/R2 etc.
+	Region         string        // upload_region (default us-east-1; "auto" for GCS)
+	Bucket         string        // upload_bucket
+	Prefix         string        // upload_prefix: key prefix, e.g. "nest/"
+	AccessKey      string        // upload_access_key (HMAC key for GCS)
+	SecretKey      string        // upload_secret_key
+	StorageClass   string        // upload_storage_class, e.g. STANDARD_IA / NEARLINE
+	LocalRetention time.Duration // upload_local_retention: keep local copies this long after upload (0 = delete)
+}
+
+// DVRConfig enables the in-memory rewind buffer. It is off unless Window is set.
+type DVRConfig struct {
+	Window          time.Duration // dvr_window: footage kept per camera, e.g. 2m
+	SegmentDuration time.Duration // dvr_segment: HLS segment length (default 2s)
+}
+
+// TimeLapseConfig keeps one keyframe per camera every Interval for
+// time-lapses. It is off unless Interval is set.
+type TimeLapseConfig struct {
+	Dir       string        // timelapse_dir: where keyframes are kept (default "timelapse")
+	Interval  time.Duration // timelapse_interval: e.g. 5m
+	Retention time.Duration // timelapse_retention: how long keyframes are kept (default 168h)
+}
+
+// Enabled reports whether time-lapse capture is configured
+func (t TimeLapseConfig) Enabled() bool {
+	return t.Interval > 0
+}
+
+// Enabled reports whether the DVR buffer is configured
+func (d DVRConfig) Enabled() bool {
+	return d.Window > 0
+}
+
+// Enabled reports whether recording is configured
+func (r RecordingConfig) Enabled() bool {
+	return r.Dir != ""
+}
+
+// Enabled reports whether uploading is configured
+func (u UploadConfig) Enabled() bool {
+	return u.Bucket != ""
+}
+
+// setRecordingOption applies a record_*, upload_*, dvr_* or timelapse_* key
+func (c *Config) setRecordingOption(key, value string) error {
+	rec := &c.Recording
+	up := &rec.Upload
+
+	var err error
+	switch key {
+	case "record_dir":
+		rec.Dir = value
+	case "record_segment":
+		rec.SegmentDuration, err = time.ParseDuration(value)
+	case "upload_provider":
+		up.Provider = strings.ToLower(value)
+	case "upload_endpoint":
+		up.Endpoint = value
+	case "upload_region":
+		up.Region = value
+	case "upload_bucket":
+		up.Bucket = value
+	case "upload_prefix":
+		up.Prefix = value
+	case "upload_access_key":
+		up.AccessKey = value
+	case "upload_secret_key":
+		up.SecretKey = value
+	case "upload_storage_class":
+		up.StorageClass = value
+	case "upload_local_retention":
+		up.LocalRetention, err = time.ParseDuration(value)
+	case "dvr_window":
+		c.DVR.Window, err = time.ParseDuration(value)
+	case "dvr_segment":
+		c.DVR.SegmentDuration, err = time.ParseDuration(value)
+	case "timelapse_dir":
+		c.TimeLapse.Dir = value
+	case "timelapse_interval":
+		c.TimeLapse.Interval, err = time.ParseDuration(value)
+	case "timelapse_retention":
+		c.TimeLapse.Retention, err = time.ParseDuration(value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return nil
+}
+
+// validateRecording checks upload settings when uploading is enabled
+func (c *Config) validateRecording() error {
+	up := c.Recording.Upload
+	if !up.Enabled() {
+		return nil
+	}
+	if !c.Recording.Enabled() {
+		return fmt.Errorf("upload_bucket requires record_dir")
+	}
+	switch up.Provider {
+	case "", "s3", "gcs":
+	default:
+		return fmt.Errorf("unknown upload_provider %q", up.Provider)
+	}
+	if up.AccessKey == "" || up.SecretKey == "" {
+		return fmt.Errorf("missing upload_access_key or upload_secret_key")
+	}
+	return nil
+}
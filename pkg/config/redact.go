@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// redactedValue replaces credentials in Redacted output
+const redactedValue = "[redacted]"
+
+// Redacted returns the configuration as a generic tree with every credential
+// replaced, safe to include in diagnostic bundles. String fields whose name
+// mentions a secret, token, key, password, credential or webhook are
+// blanked, and credentials embedded in URLs are stripped.
+func (c *Config) Redacted() map[string]any {
+	m, _ := redact("", reflect.ValueOf(*c)).(map[string]any)
+	return m
+}
+
+func redact(name string, v reflect.Value) any {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redact(name, v.Elem())
+
+	case reflect.Struct:
+		m := make(map[string]any, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.IsExported() {
+				m[f.Name] = redact(f.Name, v.Field(i))
+			}
+		}
+		return m
+
+	case reflect.Map:
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = redact(name, iter.Value())
+		}
+		return m
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = redact(name, v.Index(i))
+		}
+		return list
+
+	case reflect.String:
+		s := v.String()
+		switch {
+		case s == "":
+			return s
+		case isSensitive(name):
+			return redactedValue
+		case strings.HasSuffix(name, "URL"):
+			return redactURL(s)
+		}
+		return s
+	}
+
+	return v.Interface()
+}
+
+// isSensitive reports whether a field name suggests it holds a credential
+func isSensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range []string{"secret", "token", "key", "pass", "credential", "webhook"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL drops user info and query parameters, which commonly carry credentials
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return redactedValue
+	}
+	if u.User != nil {
+		u.User = url.User(redactedValue)
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = redactedValue
+	}
+	return u.String()
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedacted(t *testing.T) {
+	cfg := &Config{
+		Google:     GoogleConfig{ClientID: "client-id", ClientSecret: "s3cret", RefreshToken: "refresh"},
+		Cloudflare: CloudflareConfig{AppID: "app", APIToken: "cf-token"},
+		API:        APIConfig{AdminToken: "admin", ViewerTokenKey: "0123456789abcdef", ViewerTokenTTL: time.Hour},
+		Alerts:     AlertsConfig{MQTTURL: "mqtt://user:pw@broker:1883", WebhookURL: "https://hooks.example/T0/B0/xyz", SMTPPass: "smtp"},
+		Cameras:    map[string]*CameraConfig{"cam1": {RTMPURL: "rtmp://a.example/live2", RTMPKey: "stream-key"}},
+		WebRTC:     WebRTCConfig{TURNUsername: "turn-user", TURNCredential: "turn-pw"},
+	}
+
+	data, err := json.Marshal(cfg.Redacted())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+
+	for _, secret := range []string{"s3cret", "refresh", "cf-token", "admin", "0123456789abcdef", "pw@", "xyz", "smtp\"", "stream-key", "turn-pw"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("redacted config contains %q: %s", secret, out)
+		}
+	}
+	for _, kept := range []string{"client-id", `"AppID":"app"`, "rtmp://a.example/live2", "broker:1883", `"ViewerTokenTTL":"1h0m0s"`, "turn-user"} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("redacted config lost %q: %s", kept, out)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Package egress totals the bytes each camera sends to the SFU by hour, day
+// and calendar month (UTC), and checks the month against a budget.
+// Cloudflare Calls bills by egress, so the totals show where the bill is
+// heading before it arrives.
+package egress
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hourlyRetention is how long hourly buckets are kept; daily buckets are
+// kept for the current and previous month
+const hourlyRetention = 48 * time.Hour
+
+// Thresholds are the fractions of the budget that trigger a warning
+var Thresholds = []float64{0.5, 0.8, 1}
+
+// Meter turns per-camera cumulative byte counters into hourly and daily
+// totals. It is safe for concurrent use.
+type Meter struct {
+	budget uint64 // Monthly bytes; zero is no budget
+
+	mu        sync.Mutex
+	last      map[string]counter              // Last counter seen per camera
+	hours     map[string]map[time.Time]uint64 // Camera → hour start → bytes
+	days      map[string]map[time.Time]uint64 // Camera → day start → bytes
+	warned    map[time.Time]int               // Month start → thresholds warned about
+	projected map[time.Time]bool              // Months warned about a projected overrun
+}
+
+// counter is a camera's cumulative byte counter as last observed
+type counter struct {
+	source string
+	total  uint64
+}
+
+// NewMeter creates a meter with a monthly budget in bytes; zero disables
+// budget warnings
+func NewMeter(budget uint64) *Meter {
+	return &Meter{
+		budget:    budget,
+		last:      make(map[string]counter),
+		hours:     make(map[string]map[time.Time]uint64),
+		days:      make(map[string]map[time.Time]uint64),
+		warned:    make(map[time.Time]int),
+		projected: make(map[time.Time]bool),
+	}
+}
+
+// Budget returns the monthly budget in bytes
+func (m *Meter) Budget() uint64 {
+	return m.budget
+}
+
+// Observe records the cumulative bytes a camera has sent as of at, and
+// credits the growth since the previous observation to at's hour. source
+// identifies the counter, such as the relay generation: a new source, or a
+// counter that went backwards, started again from zero.
+func (m *Meter) Observe(cameraID, source string, total uint64, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delta := total
+	if prev, ok := m.last[cameraID]; ok && prev.source == source && total >= prev.total {
+		delta = total - prev.total
+	}
+	m.last[cameraID] = counter{source: source, total: total}
+	if delta == 0 {
+		return
+	}
+
+	at = at.UTC()
+	add(m.hours, cameraID, at.Truncate(time.Hour), delta)
+	add(m.days, cameraID, startOfDay(at), delta)
+}
+
+// Prune drops hourly totals older than two days and daily totals from
+// before the previous month
+func (m *Meter) Prune(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now = now.UTC()
+	prune(m.hours, now.Add(-hourlyRetention))
+	prune(m.days, startOfMonth(now).AddDate(0, -1, 0))
+	for month := range m.warned {
+		if month.Before(startOfMonth(now)) {
+			delete(m.warned, month)
+			delete(m.projected, month)
+		}
+	}
+}
+
+// Bucket is the bytes sent in one hour or day
+type Bucket struct {
+	Start time.Time `json:"start"`
+	Bytes uint64    `json:"bytes"`
+}
+
+// CameraUsage is one camera's egress
+type CameraUsage struct {
+	CameraID string   `json:"cameraId"`
+	Hour     uint64   `json:"hour"` // The current clock hour
+	Today    uint64   `json:"today"`
+	Month    uint64   `json:"month"`
+	Hourly   []Bucket `json:"hourly"` // The last 24 hours with traffic, oldest first
+	Daily    []Bucket `json:"daily"`  // Each day of the month with traffic, oldest first
+}
+
+// Usage is the egress of the month containing a point in time
+type Usage struct {
+	Month     time.Time     `json:"month"` // Start of the calendar month, UTC
+	Bytes     uint64        `json:"bytes"` // Every camera, month to date
+	Projected uint64        `json:"projected"`
+	Budget    uint64        `json:"budget,omitempty"`
+	Cameras   []CameraUsage `json:"cameras"` // Sorted by camera ID
+}
+
+// Usage reports the month to date as of now. Projected extrapolates the
+// month's average rate so far to the whole month.
+func (m *Meter) Usage(now time.Time) Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now = now.UTC()
+	hour, today, month := now.Truncate(time.Hour), startOfDay(now), startOfMonth(now)
+	u := Usage{Month: month, Budget: m.budget, Cameras: []CameraUsage{}}
+
+	cameras := make(map[string]bool)
+	for cameraID := range m.hours {
+		cameras[cameraID] = true
+	}
+	for cameraID := range m.days {
+		cameras[cameraID] = true
+	}
+	for cameraID := range cameras {
+		cu := CameraUsage{CameraID: cameraID, Hourly: []Bucket{}, Daily: []Bucket{}}
+		for start, n := range m.hours[cameraID] {
+			if start.After(hour.Add(-24 * time.Hour)) {
+				cu.Hourly = append(cu.Hourly, Bucket{Start: start, Bytes: n})
+			}
+			if start.Equal(hour) {
+				cu.Hour = n
+			}
+		}
+		for start, n := range m.days[cameraID] {
+			if start.Before(month) {
+				continue
+			}
+			cu.Daily = append(cu.Daily, Bucket{Start: start, Bytes: n})
+			cu.Month += n
+			if start.Equal(today) {
+				cu.Today = n
+			}
+		}
+		sortBuckets(cu.Hourly)
+		sortBuckets(cu.Daily)
+		u.Bytes += cu.Month
+		u.Cameras = append(u.Cameras, cu)
+	}
+	slices.SortFunc(u.Cameras, func(a, b CameraUsage) int { return strings.Compare(a.CameraID, b.CameraID) })
+
+	u.Projected = project(u.Bytes, month, now)
+	return u
+}
+
+// Warning reports the month's egress crossing a budget threshold
+type Warning struct {
+	Threshold float64 // Fraction of the budget crossed; zero for a projected overrun
+	Bytes     uint64  // Month to date
+	Projected uint64
+	Budget    uint64
+}
+
+// CheckBudget returns the thresholds crossed since the previous check, each
+// once a month. Once a day of the month has passed it also warns, once,
+// when the month is projected to exceed the budget.
+func (m *Meter) CheckBudget(now time.Time) []Warning {
+	if m.budget == 0 {
+		return nil
+	}
+	u := m.Usage(now)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var warnings []Warning
+	for i := m.warned[u.Month]; i < len(Thresholds); i++ {
+		if float64(u.Bytes) < Thresholds[i]*float64(m.budget) {
+			break
+		}
+		warnings = append(warnings, Warning{Threshold: Thresholds[i], Bytes: u.Bytes, Projected: u.Projected, Budget: m.budget})
+		m.warned[u.Month] = i + 1
+	}
+	if !m.projected[u.Month] && now.Sub(u.Month) >= 24*time.Hour && u.Projected > m.budget && u.Bytes < m.budget {
+		warnings = append(warnings, Warning{Bytes: u.Bytes, Projected: u.Projected, Budget: m.budget})
+		m.projected[u.Month] = true
+	}
+	return warnings
+}
+
+// Day is one camera's total for one UTC day, the unit persisted across
+// restarts
+type Day struct {
+	Day      time.Time
+	CameraID string
+	Bytes    uint64
+}
+
+// Days returns the daily totals of days starting at or after since
+func (m *Meter) Days(since time.Time) []Day {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var days []Day
+	for cameraID, byDay := range m.days {
+		for start, n := range byDay {
+			if !start.Before(since) {
+				days = append(days, Day{Day: start, CameraID: cameraID, Bytes: n})
+			}
+		}
+	}
+	return days
+}
+
+// Restore adds persisted daily totals, typically at startup. Thresholds the
+// restored month has already crossed as of now are not warned about again.
+func (m *Meter) Restore(days []Day, now time.Time) {
+	m.mu.Lock()
+	for _, d := range days {
+		add(m.days, d.CameraID, startOfDay(d.Day.UTC()), d.Bytes)
+	}
+	m.mu.Unlock()
+
+	m.CheckBudget(now)
+}
+
+// add credits n bytes to a camera's bucket
+func add(buckets map[string]map[time.Time]uint64, cameraID string, start time.Time, n uint64) {
+	b, ok := buckets[cameraID]
+	if !ok {
+		b = make(map[time.Time]uint64)
+		buckets[cameraID] = b
+	}
+	b[start] += n
+}
+
+// prune drops buckets that start before cutoff, and cameras left empty
+func prune(buckets map[string]map[time.Time]uint64, cutoff time.Time) {
+	for cameraID, b := range buckets {
+		for start := range b {
+			if start.Before(cutoff) {
+				delete(b, start)
+			}
+		}
+		if len(b) == 0 {
+			delete(buckets, cameraID)
+		}
+	}
+}
+
+// project extrapolates the bytes sent since month began to the whole month.
+// The first hour is too short to extrapolate from.
+func project(bytes uint64, month, now time.Time) uint64 {
+	elapsed := now.Sub(month)
+	if elapsed < time.Hour {
+		return bytes
+	}
+	length := month.AddDate(0, 1, 0).Sub(month)
+	return uint64(float64(bytes) * float64(length) / float64(elapsed))
+}
+
+func sortBuckets(b []Bucket) {
+	slices.SortFunc(b, func(x, y Bucket) int { return x.Start.Compare(y.Start) })
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
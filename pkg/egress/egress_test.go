@@ -0,0 +1,70 @@
+package egress
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMeterCounters(t *testing.T) {
+	m := NewMeter(0)
+	at := time.Date(2026, 3, 10, 14, 20, 0, 0, time.UTC)
+
+	m.Observe("cam1", "1", 1000, at)
+	m.Observe("cam1", "1", 1500, at.Add(time.Minute))
+	m.Observe("cam1", "2", 200, at.Add(2*time.Minute))        // Relay recreated, counter restarted
+	m.Observe("cam1", "2", 100, at.Add(3*time.Minute))        // Counter reset
+	m.Observe("cam1", "2", 100, at.Add(time.Hour))            // No growth
+	m.Observe("cam2", "1", 4000, at.Add(-24*time.Hour))       // Yesterday
+	m.Observe("cam2", "1", 4000+50, at.Add(-40*24*time.Hour)) // Before last month
+
+	u := m.Usage(at.Add(4 * time.Minute))
+	if len(u.Cameras) != 2 || u.Cameras[0].CameraID != "cam1" {
+		t.Fatalf("cameras = %+v", u.Cameras)
+	}
+	cam1 := u.Cameras[0]
+	if cam1.Hour != 1800 || cam1.Today != 1800 || cam1.Month != 1800 || len(cam1.Hourly) != 1 {
+		t.Errorf("cam1 = %+v", cam1)
+	}
+	cam2 := u.Cameras[1]
+	if cam2.Today != 0 || cam2.Month != 4000 || len(cam2.Daily) != 1 {
+		t.Errorf("cam2 = %+v", cam2)
+	}
+	if u.Bytes != 5800 {
+		t.Errorf("month = %d, want 5800", u.Bytes)
+	}
+
+	m.Prune(at)
+	if days := m.Days(time.Time{}); len(days) != 2 {
+		t.Errorf("days after prune = %+v", days)
+	}
+}
+
+func TestMeterBudget(t *testing.T) {
+	month := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC) // 30 days
+	m := NewMeter(3000)
+
+	// 100 bytes on day one projects to exactly the budget
+	m.Observe("cam1", "1", 100, month.Add(time.Hour))
+	if w := m.CheckBudget(month.Add(24 * time.Hour)); len(w) != 0 {
+		t.Errorf("warnings at 100 bytes = %+v", w)
+	}
+
+	// 1700 bytes by day two crosses 50% and projects an overrun
+	m.Observe("cam1", "1", 1700, month.Add(36*time.Hour))
+	w := m.CheckBudget(month.Add(48 * time.Hour))
+	if len(w) != 2 || w[0].Threshold != 0.5 || w[1].Threshold != 0 || w[1].Projected != 25500 {
+		t.Errorf("warnings at 1700 bytes = %+v", w)
+	}
+	if w := m.CheckBudget(month.Add(49 * time.Hour)); len(w) != 0 {
+		t.Errorf("repeated warnings = %+v", w)
+	}
+
+	// A restarted meter restores the month without warning again
+	restored := NewMeter(3000)
+	restored.Restore(m.Days(month), month.Add(50*time.Hour))
+	restored.Observe("cam1", "1", 1400, month.Add(51*time.Hour))
+	w = restored.CheckBudget(month.Add(52 * time.Hour))
+	if len(w) != 2 || w[0].Threshold != 0.8 || w[1].Threshold != 1 || w[1].Bytes != 3100 {
+		t.Errorf("warnings after restore = %+v", w)
+	}
+}
@@ -0,0 +1,123 @@
+// Package faults provides a chaos-testing fault injector used to exercise
+// the recovery paths in MultiStreamManager and MultiCameraRelay.
+//
+// All Injector methods are safe to call on a nil *Injector, which never
+// injects anything. Production wiring therefore passes nil unless the
+// operator explicitly enables chaos mode.
+package faults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjected is wrapped by every error the injector fabricates
+var ErrInjected = errors.New("injected fault")
+
+// Config controls how often each fault fires. Rates are probabilities in
+// [0, 1] evaluated at every opportunity (see the individual methods).
+type Config struct {
+	// RTSPKillRate is the chance a relay's RTSP connection is forcibly
+	// closed on each monitor tick (every 5s per camera).
+	RTSPKillRate float64
+
+	// CloudflareDelayRate is the chance a Cloudflare API request is held
+	// back by CloudflareDelay before being sent.
+	CloudflareDelayRate float64
+	CloudflareDelay     time.Duration
+
+	// ExtendFailRate is the chance a stream extension fails without
+	// contacting the SDM API.
+	ExtendFailRate float64
+
+	// Seed makes runs reproducible; zero uses the current time.
+	Seed int64
+}
+
+// Enabled reports whether any fault has a non-zero rate
+func (c Config) Enabled() bool {
+	return c.RTSPKillRate > 0 || c.CloudflareDelayRate > 0 || c.ExtendFailRate > 0
+}
+
+// Injector decides when to inject faults
+type Injector struct {
+	cfg    Config
+	logger *slog.Logger
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// New creates an injector. It returns nil when cfg has no faults enabled.
+func New(cfg Config, logger *slog.Logger) *Injector {
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	logger.Warn("fault injection enabled",
+		"rtsp_kill_rate", cfg.RTSPKillRate,
+		"cloudflare_delay_rate", cfg.CloudflareDelayRate,
+		"cloudflare_delay", cfg.CloudflareDelay,
+		"extend_fail_rate", cfg.ExtendFailRate,
+		"seed", seed)
+
+	return &Injector{
+		cfg:    cfg,
+		logger: logger,
+		rng:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// roll returns true with the given probability
+func (i *Injector) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rng.Float64() < rate
+}
+
+// KillRTSP reports whether the caller should drop its RTSP connection now
+func (i *Injector) KillRTSP(cameraID string) bool {
+	if i == nil || !i.roll(i.cfg.RTSPKillRate) {
+		return false
+	}
+	i.logger.Warn("injecting RTSP disconnect", "camera_id", cameraID)
+	return true
+}
+
+// DelayCloudflare blocks for the configured delay when the fault fires.
+// It returns early with the context error if ctx is cancelled first.
+func (i *Injector) DelayCloudflare(ctx context.Context, op string) error {
+	if i == nil || i.cfg.CloudflareDelay <= 0 || !i.roll(i.cfg.CloudflareDelayRate) {
+		return nil
+	}
+	i.logger.Warn("injecting Cloudflare API delay", "operation", op, "delay", i.cfg.CloudflareDelay)
+
+	select {
+	case <-time.After(i.cfg.CloudflareDelay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ExtendFailure returns a non-nil error when a stream extension should fail
+func (i *Injector) ExtendFailure(cameraID string) error {
+	if i == nil || !i.roll(i.cfg.ExtendFailRate) {
+		return nil
+	}
+	i.logger.Warn("injecting stream extension failure", "camera_id", cameraID)
+	return fmt.Errorf("extend stream: %w", ErrInjected)
+}
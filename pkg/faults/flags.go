@@ -0,0 +1,51 @@
+package faults
+
+import (
+	"flag"
+	"time"
+)
+
+// Flags holds the chaos-mode command-line flags
+type Flags struct {
+	Chaos               bool
+	RTSPKillRate        float64
+	CloudflareDelayRate float64
+	CloudflareDelay     time.Duration
+	ExtendFailRate      float64
+	Seed                int64
+}
+
+// RegisterFlags registers fault injection flags with the given FlagSet
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+
+	fs.BoolVar(&f.Chaos, "chaos", false,
+		"Enable fault injection (for testing recovery logic only)")
+	fs.Float64Var(&f.RTSPKillRate, "chaos-rtsp-kill-rate", 0.01,
+		"Probability of killing a camera's RTSP connection per 5s monitor tick")
+	fs.Float64Var(&f.CloudflareDelayRate, "chaos-cf-delay-rate", 0.1,
+		"Probability of delaying each Cloudflare API request")
+	fs.DurationVar(&f.CloudflareDelay, "chaos-cf-delay", 5*time.Second,
+		"Delay applied to Cloudflare API requests when the fault fires")
+	fs.Float64Var(&f.ExtendFailRate, "chaos-extend-fail-rate", 0.1,
+		"Probability of failing each stream extension")
+	fs.Int64Var(&f.Seed, "chaos-seed", 0,
+		"Random seed for reproducible fault sequences (0 = time-based)")
+
+	return f
+}
+
+// ToConfig converts Flags to a fault Config. Without -chaos every rate is
+// zero, so New returns a nil (inactive) injector.
+func (f *Flags) ToConfig() Config {
+	if !f.Chaos {
+		return Config{}
+	}
+	return Config{
+		RTSPKillRate:        f.RTSPKillRate,
+		CloudflareDelayRate: f.CloudflareDelayRate,
+		CloudflareDelay:     f.CloudflareDelay,
+		ExtendFailRate:      f.ExtendFailRate,
+		Seed:                f.Seed,
+	}
+}
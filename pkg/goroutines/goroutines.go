@@ -0,0 +1,177 @@
+// Package goroutines counts the long-lived goroutines each subsystem has
+// running, per camera where there is one. Loops that outlive their camera
+// (a monitor loop left behind by a removed relay, an RTCP reader on a closed
+// sender) show up as counts that never return to zero, long before they are
+// visible in runtime.NumGoroutine.
+//
+// Counting is process-wide, like runtime.NumGoroutine, so the loops spread
+// across relay, bridge, rtsp and nest need no plumbing:
+//
+//	go func() {
+//		defer goroutines.Track("relay.monitorLoop", r.cameraID)()
+//		r.monitorLoop()
+//	}()
+package goroutines
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// key identifies one kind of goroutine for one camera
+type key struct {
+	name     string
+	cameraID string
+}
+
+var (
+	mu      sync.Mutex
+	running = make(map[key]int64)
+	started = make(map[string]uint64)
+)
+
+// Track counts a goroutine named "subsystem.loop" (e.g. "bridge.peerstats")
+// as running for cameraID, which may be empty, until the returned function
+// is called. Defer it first thing in the goroutine.
+func Track(name, cameraID string) (done func()) {
+	k := key{name, cameraID}
+	mu.Lock()
+	running[k]++
+	started[name]++
+	mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if running[k]--; running[k] <= 0 {
+				delete(running, k)
+			}
+		})
+	}
+}
+
+// Go runs fn in a new goroutine counted by Track
+func Go(name, cameraID string, fn func()) {
+	done := Track(name, cameraID)
+	go func() {
+		defer done()
+		fn()
+	}()
+}
+
+// Snapshot is the current goroutine accounting
+type Snapshot struct {
+	Tracked    int64             `json:"tracked"`    // Goroutines counted by Track
+	Runtime    int               `json:"runtime"`    // runtime.NumGoroutine, including untracked ones
+	Subsystems map[string]int64  `json:"subsystems"` // Running, by name prefix: relay, pacer, bridge, ...
+	Loops      map[string]int64  `json:"loops"`      // Running, by full name
+	Cameras    map[string]int64  `json:"cameras"`    // Running per camera; unlabelled goroutines are omitted
+	Started    map[string]uint64 `json:"started"`    // Started since process start, by full name
+}
+
+// Take returns the current counts
+func Take() Snapshot {
+	s := Snapshot{
+		Runtime:    runtime.NumGoroutine(),
+		Subsystems: make(map[string]int64),
+		Loops:      make(map[string]int64),
+		Cameras:    make(map[string]int64),
+		Started:    make(map[string]uint64),
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for k, n := range running {
+		s.Tracked += n
+		s.Subsystems[subsystem(k.name)] += n
+		s.Loops[k.name] += n
+		if k.cameraID != "" {
+			s.Cameras[k.cameraID] += n
+		}
+	}
+	for name, n := range started {
+		s.Started[name] = n
+	}
+	return s
+}
+
+// Camera returns the goroutines running for cameraID, by name
+func Camera(cameraID string) map[string]int64 {
+	mu.Lock()
+	defer mu.Unlock()
+	loops := make(map[string]int64)
+	for k, n := range running {
+		if k.cameraID == cameraID {
+			loops[k.name] += n
+		}
+	}
+	return loops
+}
+
+// Orphaned returns the cameras with goroutines still running that live does
+// not report as alive, sorted. Goroutines take a moment to exit after their
+// camera stops, so a camera is only worth flagging if it stays orphaned.
+func Orphaned(live func(cameraID string) bool) []string {
+	var orphans []string
+	for cameraID := range Take().Cameras {
+		if !live(cameraID) {
+			orphans = append(orphans, cameraID)
+		}
+	}
+	slices.Sort(orphans)
+	return orphans
+}
+
+// Settle waits until no goroutine whose name starts with one of prefixes is
+// running (every tracked goroutine without prefixes), and otherwise returns
+// an error listing those still running once ctx is done. Tests call it after
+// shutdown to assert nothing leaked.
+func Settle(ctx context.Context, prefixes ...string) error {
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		left := remaining(prefixes)
+		if len(left) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("goroutines still running: %s", strings.Join(left, ", "))
+		case <-ticker.C:
+		}
+	}
+}
+
+// remaining describes the running goroutines matching prefixes, sorted
+func remaining(prefixes []string) []string {
+	mu.Lock()
+	defer mu.Unlock()
+	var left []string
+	for k, n := range running {
+		if len(prefixes) > 0 && !slices.ContainsFunc(prefixes, func(p string) bool { return strings.HasPrefix(k.name, p) }) {
+			continue
+		}
+		if k.cameraID != "" {
+			left = append(left, fmt.Sprintf("%s[%s]×%d", k.name, k.cameraID, n))
+		} else {
+			left = append(left, fmt.Sprintf("%s×%d", k.name, n))
+		}
+	}
+	slices.Sort(left)
+	return left
+}
+
+// subsystem is the part of name before the first dot
+func subsystem(name string) string {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
@@ -0,0 +1,41 @@
+package goroutines
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrackAndSettle(t *testing.T) {
+	stop := make(chan struct{})
+	for range 2 {
+		Go("test.loop", "cam1", func() { <-stop })
+	}
+	Go("test.other", "", func() { <-stop })
+
+	s := Take()
+	if s.Loops["test.loop"] != 2 || s.Subsystems["test"] != 3 || s.Cameras["cam1"] != 2 {
+		t.Errorf("snapshot = %+v", s)
+	}
+	if got := Orphaned(func(id string) bool { return id != "cam1" }); len(got) != 1 || got[0] != "cam1" {
+		t.Errorf("orphaned = %v", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	err := Settle(ctx, "test.")
+	cancel()
+	if err == nil || !strings.Contains(err.Error(), "test.loop[cam1]×2") {
+		t.Errorf("settle while running = %v", err)
+	}
+
+	close(stop)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := Settle(ctx, "test."); err != nil {
+		t.Fatal(err)
+	}
+	if s := Take(); len(s.Cameras) != 0 || s.Started["test.loop"] != 2 {
+		t.Errorf("after settle = %+v", s)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !unix
+
+package ha
+
+import (
+	"context"
+	"errors"
+)
+
+// FileLock is unavailable on this platform
+type FileLock struct{}
+
+// NewFileLock creates a lock that always fails to acquire
+func NewFileLock(path string) *FileLock {
+	return &FileLock{}
+}
+
+// Acquire implements Lock
+func (l *FileLock) Acquire(ctx context.Context) error {
+	return errors.New("file locks are not supported on this platform")
+}
+
+// Release implements Lock
+func (l *FileLock) Release() error {
+	return nil
+}
@@ -0,0 +1,74 @@
+//go:build unix
+
+package ha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// FileLock elects a leader with flock(2) on a file. Place the file on storage
+// every instance can reach (local disk for instances on one host, or a
+// network filesystem with working advisory locks).
+type FileLock struct {
+	path string
+	file *os.File
+}
+
+// NewFileLock creates a lock on path; the file is created if needed
+func NewFileLock(path string) *FileLock {
+	return &FileLock{path: path}
+}
+
+// Acquire implements Lock
+func (l *FileLock) Acquire(ctx context.Context) error {
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("open lock file: %w", err)
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			f.Close()
+			return fmt.Errorf("lock %s: %w", l.path, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	// Record the holder for operators; the lock itself is the flock
+	f.Truncate(0)
+	f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+
+	l.file = f
+	return nil
+}
+
+// Release implements Lock
+func (l *FileLock) Release() error {
+	if l.file == nil {
+		return nil
+	}
+	f := l.file
+	l.file = nil
+
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	return f.Close()
+}
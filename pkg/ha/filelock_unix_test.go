@@ -0,0 +1,59 @@
+//go:build unix
+
+package ha
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileLockHandoff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leader.lock")
+	leader, standby := NewFileLock(path), NewFileLock(path)
+
+	if err := leader.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- standby.Acquire(context.Background()) }()
+
+	select {
+	case err := <-acquired:
+		t.Fatalf("standby acquired a held lock: %v", err)
+	case <-time.After(2 * pollInterval):
+	}
+
+	if err := leader.Release(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * pollInterval):
+		t.Fatal("standby did not take over the released lock")
+	}
+	if err := standby.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileLockAcquireCanceled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leader.lock")
+	leader := NewFileLock(path)
+	if err := leader.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer leader.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := NewFileLock(path).Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire = %v, want DeadlineExceeded", err)
+	}
+}
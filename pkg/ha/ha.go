@@ -0,0 +1,24 @@
+// Package ha provides leader election for active/standby relay pairs.
+//
+// Only one instance may relay a camera fleet at a time: two publishers would
+// double the SDM command budget and fight over SFU sessions. Instances
+// sharing a Lock elect a leader; the others block in Acquire until it is
+// released, which for the file lock happens automatically when the leader's
+// process exits or its host dies.
+package ha
+
+import (
+	"context"
+	"time"
+)
+
+// pollInterval is how often a standby retries a held lock
+const pollInterval = time.Second
+
+// Lock is a cluster-wide mutual exclusion primitive
+type Lock interface {
+	// Acquire blocks until the lock is held or ctx is done
+	Acquire(ctx context.Context) error
+	// Release gives up the lock
+	Release() error
+}
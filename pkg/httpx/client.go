@@ -0,0 +1,33 @@
+package httpx
+
+import (
+	"net/http"
+	"time"
+)
+
+// Config describes one API client
+type Config struct {
+	Timeout   time.Duration     // Whole request including the body; 0 is none
+	Transport http.RoundTripper // nil uses SharedTransport
+
+	// Retries is how many times an idempotent request is retried after a
+	// connection error or 502/503/504. Budget, when set, caps retries across
+	// every client sharing it.
+	Retries int
+	Budget  *RetryBudget
+}
+
+// NewClient creates an http.Client from cfg
+func NewClient(cfg Config) *http.Client {
+	rt := cfg.Transport
+	if rt == nil {
+		rt = SharedTransport()
+	}
+	if cfg.Retries > 0 {
+		rt = &retryTransport{base: rt, retries: cfg.Retries, budget: cfg.Budget}
+	}
+	return &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: rt,
+	}
+}
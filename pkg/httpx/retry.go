@@ -0,0 +1,121 @@
+package httpx
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// retryBackoff is the delay before the first retry; it doubles per attempt
+const retryBackoff = 100 * time.Millisecond
+
+// RetryBudget limits retries to a fraction of requests, so an outage of an
+// API host doesn't multiply the load on it. Each request earns ratio tokens
+// up to a cap and each retry spends one. A nil budget is unlimited.
+type RetryBudget struct {
+	ratio float64
+	max   float64
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+// NewRetryBudget allows retries for ratio of requests (e.g. 0.1 for 10%),
+// plus a reserve of minRetries for when traffic is light
+func NewRetryBudget(ratio float64, minRetries int) *RetryBudget {
+	return &RetryBudget{
+		ratio:  ratio,
+		max:    float64(minRetries),
+		tokens: float64(minRetries),
+	}
+}
+
+// deposit credits the budget for one request
+func (b *RetryBudget) deposit() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.tokens = min(b.tokens+b.ratio, max(b.max, 1))
+	b.mu.Unlock()
+}
+
+// withdraw spends a token for a retry, reporting false when none are left
+func (b *RetryBudget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// retryTransport retries idempotent requests within a budget
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+	budget  *RetryBudget
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.budget.deposit()
+
+	resp, err := t.base.RoundTrip(req)
+	for attempt := 0; attempt < t.retries && shouldRetry(req, resp, err); attempt++ {
+		if !t.budget.withdraw() {
+			break
+		}
+		if req.Body != nil && req.Body != http.NoBody {
+			body, gerr := req.GetBody()
+			if gerr != nil {
+				break
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096)) // Let the connection be reused
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(retryBackoff << attempt)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		resp, err = t.base.RoundTrip(req)
+	}
+	return resp, err
+}
+
+// shouldRetry reports whether a request can safely be sent again after the
+// given outcome
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+// Package httpx builds the HTTP clients used for the Google, Cloudflare and
+// LiveKit APIs. They share one tuned transport so a large fleet reuses a
+// small pool of keep-alive connections and TLS sessions instead of dialing
+// per request, which hurts tail latency and can exhaust ephemeral ports.
+package httpx
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Transport defaults, sized for a few hundred cameras talking to a handful
+// of API hosts
+const (
+	DefaultMaxConnsPerHost     = 64
+	DefaultMaxIdleConnsPerHost = 16
+	DefaultIdleConnTimeout     = 90 * time.Second
+	DefaultDialTimeout         = 10 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+)
+
+// TransportConfig tunes connection pooling and handshakes. Zero fields use
+// the defaults above.
+type TransportConfig struct {
+	MaxConnsPerHost     int           // Dialing, active and idle connections per host
+	MaxIdleConnsPerHost int           // Keep-alive connections kept per host
+	IdleConnTimeout     time.Duration // How long an idle connection is kept
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+
+	// Proxy picks the proxy for each request, as http.Transport.Proxy;
+	// nil uses the HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment
+	Proxy func(*http.Request) (*url.URL, error)
+}
+
+func (c *TransportConfig) setDefaults() {
+	if c.MaxConnsPerHost <= 0 {
+		c.MaxConnsPerHost = DefaultMaxConnsPerHost
+	}
+	if c.MaxIdleConnsPerHost <= 0 {
+		c.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if c.MaxIdleConnsPerHost > c.MaxConnsPerHost {
+		c.MaxIdleConnsPerHost = c.MaxConnsPerHost
+	}
+	if c.IdleConnTimeout <= 0 {
+		c.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = DefaultDialTimeout
+	}
+	if c.TLSHandshakeTimeout <= 0 {
+		c.TLSHandshakeTimeout = DefaultTLSHandshakeTimeout
+	}
+	if c.Proxy == nil {
+		c.Proxy = http.ProxyFromEnvironment
+	}
+}
+
+// NewTransport creates a pooled transport that attempts HTTP/2 and resumes
+// TLS sessions
+func NewTransport(cfg TransportConfig) *http.Transport {
+	cfg.setDefaults()
+
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	return &http.Transport{
+		Proxy:                 cfg.Proxy,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true, // Needed because TLSClientConfig is set
+		TLSClientConfig:       &tls.Config{ClientSessionCache: tls.NewLRUClientSessionCache(0)},
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		MaxIdleConns:          0, // Bounded per host instead
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+var (
+	sharedOnce      sync.Once
+	sharedTransport *http.Transport
+)
+
+// SharedTransport returns the process-wide transport with default settings,
+// used by clients that are not given one
+func SharedTransport() *http.Transport {
+	sharedOnce.Do(func() {
+		sharedTransport = NewTransport(TransportConfig{})
+	})
+	return sharedTransport
+}
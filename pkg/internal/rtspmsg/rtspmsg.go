@@ -0,0 +1,179 @@
+// Package rtspmsg reads RTSP requests and formats responses for the relay's
+// RTSP servers: the restreaming server in rtspserver and the test server in
+// rtsp/rtsptest. Only what both need is here; RTP, sessions and SDP stay
+// with each server.
+package rtspmsg
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Request is an RTSP request. Header keys are canonicalized ("CSeq",
+// "Transport"), since clients differ in case.
+type Request struct {
+	Method string
+	URL    string
+	Header map[string]string
+}
+
+// Limits on a request, which servers read before the client authenticates
+const (
+	maxLineSize    = 4 << 10  // Request line or header line, CRLF included
+	maxHeaders     = 64       // Header lines per request
+	maxContentSize = 64 << 10 // Body, which is discarded
+)
+
+// ErrTooLarge is returned by ReadRequest for a request over the limits
+var ErrTooLarge = errors.New("rtsp request too large")
+
+// ReadRequest parses an RTSP request line, headers and (ignored) body
+func ReadRequest(r *bufio.Reader) (Request, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return Request{}, err
+	}
+	parts := strings.Fields(line)
+	if len(parts) != 3 || !strings.HasPrefix(parts[2], "RTSP/") {
+		return Request{}, fmt.Errorf("invalid request line %q", line)
+	}
+
+	req := Request{Method: parts[0], URL: parts[1], Header: make(map[string]string)}
+	for n := 0; ; n++ {
+		line, err := readLine(r)
+		if err != nil {
+			return Request{}, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if n == maxHeaders {
+			return Request{}, fmt.Errorf("%w: over %d headers", ErrTooLarge, maxHeaders)
+		}
+		if k, v, ok := strings.Cut(line, ":"); ok {
+			req.Header[canonicalHeader(strings.TrimSpace(k))] = strings.TrimSpace(v)
+		}
+	}
+
+	if cl := req.Header["Content-Length"]; cl != "" {
+		n, err := strconv.Atoi(cl)
+		if err != nil || n < 0 {
+			return Request{}, fmt.Errorf("invalid Content-Length %q", cl)
+		}
+		if n > maxContentSize {
+			return Request{}, fmt.Errorf("%w: %d-byte body", ErrTooLarge, n)
+		}
+		if _, err := r.Discard(n); err != nil {
+			return Request{}, err
+		}
+	}
+	return req, nil
+}
+
+// readLine reads a line of at most maxLineSize bytes, so a client can't
+// make the server buffer an endless one
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxLineSize {
+			return "", fmt.Errorf("%w: line over %d bytes", ErrTooLarge, maxLineSize)
+		}
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return string(line), err
+		}
+	}
+}
+
+// canonicalHeader normalizes header case, since clients differ ("Cseq")
+func canonicalHeader(k string) string {
+	switch strings.ToLower(k) {
+	case "cseq":
+		return "CSeq"
+	case "www-authenticate":
+		return "WWW-Authenticate"
+	}
+	parts := strings.Split(strings.ToLower(k), "-")
+	for i, p := range parts {
+		if p != "" {
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// Response formats an RTSP response echoing the request's CSeq
+func Response(req Request, status int, header map[string]string, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "RTSP/1.0 %d %s\r\n", status, StatusText(status))
+	fmt.Fprintf(&b, "CSeq: %s\r\n", req.Header["CSeq"])
+	for k, v := range header {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+	}
+	if body != "" {
+		fmt.Fprintf(&b, "Content-Length: %d\r\n", len(body))
+	}
+	b.WriteString("\r\n")
+	b.WriteString(body)
+	return []byte(b.String())
+}
+
+// StatusText returns the reason phrase for the status codes the servers send
+func StatusText(status int) string {
+	switch status {
+	case 200:
+		return "OK"
+	case 401:
+		return "Unauthorized"
+	case 404:
+		return "Not Found"
+	case 415:
+		return "Unsupported Media Type"
+	case 454:
+		return "Session Not Found"
+	case 455:
+		return "Method Not Valid in This State"
+	case 457:
+		return "Invalid Range"
+	case 461:
+		return "Unsupported Transport"
+	case 500:
+		return "Internal Server Error"
+	case 503:
+		return "Service Unavailable"
+	}
+	return "Not Implemented"
+}
+
+// ParseRange extracts a range such as "interleaved=lo-hi" or
+// "client_port=lo-hi" from a Transport header; hi defaults to lo+1. ok is
+// false when the parameter is absent or malformed.
+func ParseRange(transport, prefix string) (lo, hi int, ok bool) {
+	for _, param := range strings.Split(transport, ";") {
+		v, found := strings.CutPrefix(strings.TrimSpace(param), prefix)
+		if !found {
+			continue
+		}
+		a, b, _ := strings.Cut(v, "-")
+		var err error
+		if lo, err = strconv.Atoi(a); err != nil {
+			return 0, 0, false
+		}
+		if hi, err = strconv.Atoi(b); err != nil {
+			hi = lo + 1
+		}
+		return lo, hi, true
+	}
+	return 0, 0, false
+}
+
+// SessionID strips parameters such as ";timeout=60" from a Session header
+func SessionID(header string) string {
+	id, _, _ := strings.Cut(header, ";")
+	return strings.TrimSpace(id)
+}
@@ -0,0 +1,101 @@
+package rtspmsg
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReadRequest(t *testing.T) {
+	raw := "SETUP rtsp://relay/cam/trackID=0 RTSP/1.0\r\n" +
+		"Cseq: 3\r\n" +
+		"transport: RTP/AVP/TCP;unicast;interleaved=2-3\r\n" +
+		"Content-Length: 4\r\n" +
+		"\r\n" +
+		"body" +
+		"OPTIONS * RTSP/1.0\r\nCSeq: 4\r\n\r\n"
+	r := bufio.NewReader(strings.NewReader(raw))
+
+	req, err := ReadRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "SETUP" || req.URL != "rtsp://relay/cam/trackID=0" {
+		t.Errorf("request line = %s %s", req.Method, req.URL)
+	}
+	if req.Header["CSeq"] != "3" || req.Header["Transport"] != "RTP/AVP/TCP;unicast;interleaved=2-3" {
+		t.Errorf("headers = %v", req.Header)
+	}
+
+	// The body is skipped, leaving the next request
+	if req, err = ReadRequest(r); err != nil || req.Method != "OPTIONS" {
+		t.Errorf("next request = %+v, %v", req, err)
+	}
+
+	if _, err := ReadRequest(bufio.NewReader(strings.NewReader("GET / HTTP/1.1\r\n\r\n"))); err == nil {
+		t.Error("HTTP request line accepted")
+	}
+}
+
+func TestReadRequestLimits(t *testing.T) {
+	long := "OPTIONS rtsp://relay/" + strings.Repeat("a", maxLineSize) + " RTSP/1.0\r\n\r\n"
+	endless := "OPTIONS * RTSP/1.0\r\nX-Pad: " + strings.Repeat("a", 10*maxLineSize)
+	manyHeaders := "OPTIONS * RTSP/1.0\r\n" + strings.Repeat("X-Pad: a\r\n", maxHeaders+1) + "\r\n"
+	body := fmt.Sprintf("ANNOUNCE * RTSP/1.0\r\nContent-Length: %d\r\n\r\n", maxContentSize+1)
+
+	for name, raw := range map[string]string{
+		"long request line": long,
+		"endless header":    endless,
+		"too many headers":  manyHeaders,
+		"large body":        body,
+	} {
+		_, err := ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+		if !errors.Is(err, ErrTooLarge) {
+			t.Errorf("%s: err = %v, want ErrTooLarge", name, err)
+		}
+	}
+
+	// Just under the limits is fine
+	raw := "OPTIONS * RTSP/1.0\r\n" + strings.Repeat("X-Pad: a\r\n", maxHeaders-1) +
+		"X-Long: " + strings.Repeat("a", maxLineSize-len("X-Long: \r\n")) + "\r\n\r\n"
+	if _, err := ReadRequest(bufio.NewReader(strings.NewReader(raw))); err != nil {
+		t.Errorf("request at the limits: %v", err)
+	}
+}
+
+func TestResponse(t *testing.T) {
+	req := Request{Header: map[string]string{"CSeq": "7"}}
+	got := string(Response(req, 454, map[string]string{"Session": "abc"}, "x"))
+	want := "RTSP/1.0 454 Session Not Found\r\nCSeq: 7\r\nSession: abc\r\nContent-Length: 1\r\n\r\nx"
+	if got != want {
+		t.Errorf("Response = %q, want %q", got, want)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	for _, tt := range []struct {
+		transport, prefix string
+		lo, hi            int
+		ok                bool
+	}{
+		{"RTP/AVP/TCP;unicast;interleaved=2-3", "interleaved=", 2, 3, true},
+		{"RTP/AVP/TCP;interleaved=4", "interleaved=", 4, 5, true},
+		{"RTP/AVP;unicast;client_port=5000-5001", "client_port=", 5000, 5001, true},
+		{"RTP/AVP/TCP;unicast", "interleaved=", 0, 0, false},
+		{"RTP/AVP/TCP;interleaved=x-1", "interleaved=", 0, 0, false},
+	} {
+		lo, hi, ok := ParseRange(tt.transport, tt.prefix)
+		if lo != tt.lo || hi != tt.hi || ok != tt.ok {
+			t.Errorf("ParseRange(%q, %q) = %d, %d, %v; want %d, %d, %v",
+				tt.transport, tt.prefix, lo, hi, ok, tt.lo, tt.hi, tt.ok)
+		}
+	}
+}
+
+func TestSessionID(t *testing.T) {
+	if got := SessionID(" 12345678;timeout=60"); got != "12345678" {
+		t.Errorf("SessionID = %q", got)
+	}
+}
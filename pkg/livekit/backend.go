@@ -0,0 +1,87 @@
+package livekit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/sfu"
+)
+
+// Backend publishes each camera to a LiveKit room through a dedicated WHIP
+// ingress. The ingress ID doubles as the bridge session ID.
+type Backend struct {
+	client *Client
+	room   string
+
+	mu        sync.Mutex
+	ingresses map[string]*IngressInfo // ingressID -> ingress
+}
+
+// NewBackend creates an SFU backend publishing into the given room
+func NewBackend(client *Client, room string) *Backend {
+	return &Backend{
+		client:    client,
+		room:      room,
+		ingresses: make(map[string]*IngressInfo),
+	}
+}
+
+// Name returns "livekit"
+func (b *Backend) Name() string {
+	return "livekit"
+}
+
+// CreateSession creates a WHIP ingress whose participant identity is the camera ID
+func (b *Backend) CreateSession(ctx context.Context, cameraID string) (string, error) {
+	info, err := b.client.CreateIngress(ctx, &CreateIngressRequest{
+		InputType:           "WHIP_INPUT",
+		Name:                cameraID,
+		RoomName:            b.room,
+		ParticipantIdentity: cameraID,
+		ParticipantName:     cameraID,
+		// Nest already produces H.264; forward it untouched
+		EnableTranscoding: false,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	b.mu.Lock()
+	b.ingresses[info.IngressID] = info
+	b.mu.Unlock()
+
+	return info.IngressID, nil
+}
+
+// PublishTracks sends the offer to the ingress WHIP endpoint
+func (b *Backend) PublishTracks(ctx context.Context, sessionID string, offer sfu.Description, tracks []sfu.Track) (sfu.Description, error) {
+	b.mu.Lock()
+	info, ok := b.ingresses[sessionID]
+	b.mu.Unlock()
+
+	if !ok {
+		return sfu.Description{}, fmt.Errorf("unknown LiveKit ingress %s", sessionID)
+	}
+
+	answer, err := b.client.WHIPPublish(ctx, info.URL, info.StreamKey, offer.SDP)
+	if err != nil {
+		return sfu.Description{}, fmt.Errorf("publish to LiveKit: %w", err)
+	}
+	return sfu.Description{Type: "answer", SDP: answer}, nil
+}
+
+// Renegotiate is not supported: a WHIP session is fixed once published, so
+// callers must delete and recreate the ingress instead.
+func (b *Backend) Renegotiate(ctx context.Context, sessionID string, desc sfu.Description) (sfu.Description, error) {
+	return sfu.Description{}, sfu.ErrNotSupported
+}
+
+// Close deletes the ingress so the camera leaves the room
+func (b *Backend) Close(ctx context.Context, sessionID string) error {
+	b.mu.Lock()
+	delete(b.ingresses, sessionID)
+	b.mu.Unlock()
+
+	return b.client.DeleteIngress(ctx, sessionID)
+}
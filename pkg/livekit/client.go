@@ -0,0 +1,207 @@
+// Package livekit publishes camera tracks to a self-hosted (or cloud) LiveKit
+// SFU. Each camera becomes a WHIP ingress whose participant joins the
+// configured room, so LiveKit's own clients can subscribe to the feeds.
+//
+// Only the standard library is used: server API calls go through LiveKit's
+// Twirp JSON endpoints, authenticated with HS256 access tokens.
+package livekit
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/httpx"
+)
+
+// tokenTTL bounds the lifetime of the admin tokens minted per request
+const tokenTTL = 10 * time.Minute
+
+// Client talks to the LiveKit server API
+type Client struct {
+	baseURL    string
+	apiKey     string
+	apiSecret  string
+	httpClient *http.Client
+	logger     *slog.Logger
+}
+
+// IngressInfo is the subset of livekit.IngressInfo the relay needs
+type IngressInfo struct {
+	IngressID string `json:"ingress_id"`
+	Name      string `json:"name"`
+	StreamKey string `json:"stream_key"`
+	URL       string `json:"url"`
+	RoomName  string `json:"room_name"`
+}
+
+// CreateIngressRequest mirrors livekit.CreateIngressRequest for WHIP input
+type CreateIngressRequest struct {
+	InputType           string `json:"input_type"`
+	Name                string `json:"name"`
+	RoomName            string `json:"room_name"`
+	ParticipantIdentity string `json:"participant_identity"`
+	ParticipantName     string `json:"participant_name"`
+	EnableTranscoding   bool   `json:"enable_transcoding"`
+}
+
+// NewClient creates a LiveKit server API client. serverURL may use the
+// ws(s):// scheme that LiveKit SDKs expect; it is rewritten to http(s).
+func NewClient(serverURL, apiKey, apiSecret string, logger *slog.Logger) *Client {
+	baseURL := strings.TrimRight(serverURL, "/")
+	baseURL = strings.Replace(baseURL, "wss://", "https://", 1)
+	baseURL = strings.Replace(baseURL, "ws://", "http://", 1)
+
+	return &Client{
+		baseURL:    baseURL,
+		apiKey:     apiKey,
+		apiSecret:  apiSecret,
+		httpClient: httpx.NewClient(httpx.Config{Timeout: 30 * time.Second}),
+		logger:     logger,
+	}
+}
+
+// SetHTTPClient replaces the client used for server API requests
+func (c *Client) SetHTTPClient(hc *http.Client) {
+	c.httpClient = hc
+}
+
+// CreateIngress creates a WHIP ingress that publishes into roomName
+func (c *Client) CreateIngress(ctx context.Context, req *CreateIngressRequest) (*IngressInfo, error) {
+	var info IngressInfo
+	if err := c.twirp(ctx, "Ingress", "CreateIngress", req, &info); err != nil {
+		return nil, fmt.Errorf("create ingress: %w", err)
+	}
+
+	c.logger.Info("created LiveKit ingress",
+		"ingress_id", info.IngressID,
+		"room", info.RoomName,
+		"url", info.URL)
+	return &info, nil
+}
+
+// DeleteIngress removes an ingress and disconnects its participant
+func (c *Client) DeleteIngress(ctx context.Context, ingressID string) error {
+	req := map[string]string{"ingress_id": ingressID}
+	if err := c.twirp(ctx, "Ingress", "DeleteIngress", req, nil); err != nil {
+		return fmt.Errorf("delete ingress: %w", err)
+	}
+
+	c.logger.Info("deleted LiveKit ingress", "ingress_id", ingressID)
+	return nil
+}
+
+// WHIPPublish posts an SDP offer to a WHIP endpoint and returns the answer.
+// LiveKit authenticates WHIP publishers with the ingress stream key.
+func (c *Client) WHIPPublish(ctx context.Context, endpoint, streamKey, offerSDP string) (string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(offerSDP))
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+streamKey)
+	httpReq.Header.Set("Content-Type", "application/sdp")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("WHIP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("WHIP publish failed: %s (status %d)", body, resp.StatusCode)
+	}
+
+	return string(body), nil
+}
+
+// twirp calls a LiveKit Twirp JSON method
+func (c *Client) twirp(ctx context.Context, service, method string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/twirp/livekit.%s/%s", c.baseURL, service, method)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	token, err := c.adminToken()
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("%s request: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s failed: %s (status %d)", method, respBody, resp.StatusCode)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("decode %s response: %w", method, err)
+	}
+	return nil
+}
+
+// adminToken mints a short-lived access token with the ingressAdmin grant
+func (c *Client) adminToken() (string, error) {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": c.apiKey,
+		"nbf": now.Unix(),
+		"exp": now.Add(tokenTTL).Unix(),
+		"video": map[string]interface{}{
+			"ingressAdmin": true,
+		},
+	}
+	return signHS256(claims, c.apiSecret)
+}
+
+// signHS256 encodes and signs a JWT with HMAC-SHA256
+func signHS256(claims map[string]interface{}, secret string) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	if err != nil {
+		return "", fmt.Errorf("marshal token header: %w", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("marshal token claims: %w", err)
+	}
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signingInput))
+
+	return signingInput + "." + enc.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,310 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// CameraIDPlaceholder is replaced with the camera's device ID in a
+	// CameraFiles path template
+	CameraIDPlaceholder = "{camera_id}"
+
+	DefaultCameraLogMaxSize    = 10 << 20 // Bytes before a camera log is rotated
+	DefaultCameraLogMaxBackups = 3        // Rotated files kept per camera
+)
+
+// CameraFiles writes each camera's log records to its own rotating file,
+// named by a template such as logs/{camera_id}.log. Records belong to a
+// camera when they carry a top-level camera_id attribute, usually added with
+// logger.With("camera_id", id).
+type CameraFiles struct {
+	template   string
+	maxSize    int64
+	maxBackups int
+
+	mu    sync.Mutex
+	files map[string]*cameraFile // Keyed by camera ID
+}
+
+// cameraFile is one camera's rotating file and the JSON handler writing to it
+type cameraFile struct {
+	out     *rotatingFile
+	handler slog.Handler
+}
+
+// NewCameraFiles creates per-camera log files from template, which must
+// contain {camera_id}. Files are opened on a camera's first record.
+func NewCameraFiles(template string) (*CameraFiles, error) {
+	if !strings.Contains(template, CameraIDPlaceholder) {
+		return nil, fmt.Errorf("camera log path %q has no %s placeholder", template, CameraIDPlaceholder)
+	}
+	return &CameraFiles{
+		template:   template,
+		maxSize:    DefaultCameraLogMaxSize,
+		maxBackups: DefaultCameraLogMaxBackups,
+		files:      make(map[string]*cameraFile),
+	}, nil
+}
+
+// Path returns the log file of a camera
+func (c *CameraFiles) Path(cameraID string) string {
+	return strings.ReplaceAll(c.template, CameraIDPlaceholder, fileSafeID(cameraID))
+}
+
+// Handler returns a handler that sends every record to next and also writes
+// records carrying a camera_id at level and above to that camera's file
+func (c *CameraFiles) Handler(next slog.Handler, level slog.Leveler) slog.Handler {
+	return &cameraHandler{next: next, files: c, level: level}
+}
+
+// Close closes every open camera log
+func (c *CameraFiles) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var firstErr error
+	for id, f := range c.files {
+		if err := f.out.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(c.files, id)
+	}
+	return firstErr
+}
+
+// handler returns the base JSON handler of a camera's file, opening it on
+// first use
+func (c *CameraFiles) handler(cameraID string) (slog.Handler, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if f, ok := c.files[cameraID]; ok {
+		return f.handler, nil
+	}
+	out, err := openRotatingFile(c.Path(cameraID), c.maxSize, c.maxBackups)
+	if err != nil {
+		return nil, err
+	}
+	f := &cameraFile{
+		out:     out,
+		handler: slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug}),
+	}
+	c.files[cameraID] = f
+	return f.handler, nil
+}
+
+// fileSafeID shortens a full device path to its device ID and replaces
+// characters that don't belong in file names
+func fileSafeID(cameraID string) string {
+	cameraID = cameraID[strings.LastIndex(cameraID, "/")+1:]
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, cameraID)
+}
+
+// handlerStep is one WithAttrs or WithGroup call, replayed onto a camera's
+// file handler
+type handlerStep struct {
+	group string
+	attrs []slog.Attr
+}
+
+// cameraHandler tees records to the primary handler and the camera files
+type cameraHandler struct {
+	next     slog.Handler
+	files    *CameraFiles
+	level    slog.Leveler
+	steps    []handlerStep
+	grouped  bool   // A group is open, so later attrs are not top-level
+	cameraID string // From WithAttrs; empty until a camera_id is seen
+
+	once   sync.Once
+	cached slog.Handler // File handler with steps applied, for cameraID
+}
+
+func (h *cameraHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level) || level >= h.level.Level()
+}
+
+func (h *cameraHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if rec.Level >= h.level.Level() {
+		h.writeCamera(ctx, rec)
+	}
+	if h.next.Enabled(ctx, rec.Level) {
+		return h.next.Handle(ctx, rec)
+	}
+	return nil
+}
+
+// writeCamera writes rec to its camera's file, if it has a camera. Failures
+// to open or write the file never affect the primary log.
+func (h *cameraHandler) writeCamera(ctx context.Context, rec slog.Record) {
+	if h.cameraID != "" {
+		h.once.Do(func() { h.cached = h.fileHandler(h.cameraID) })
+		if h.cached != nil {
+			h.cached.Handle(ctx, rec.Clone())
+		}
+		return
+	}
+	if h.grouped {
+		return
+	}
+
+	var cameraID string
+	rec.Attrs(func(a slog.Attr) bool {
+		if a.Key == "camera_id" {
+			cameraID = a.Value.String()
+			return false
+		}
+		return true
+	})
+	if cameraID == "" {
+		return
+	}
+	if fh := h.fileHandler(cameraID); fh != nil {
+		fh.Handle(ctx, rec.Clone())
+	}
+}
+
+// fileHandler returns the camera's file handler with this handler's
+// attributes and groups applied, or nil if the file can't be opened
+func (h *cameraHandler) fileHandler(cameraID string) slog.Handler {
+	fh, err := h.files.handler(cameraID)
+	if err != nil {
+		return nil
+	}
+	for _, s := range h.steps {
+		if s.group != "" {
+			fh = fh.WithGroup(s.group)
+		} else {
+			fh = fh.WithAttrs(s.attrs)
+		}
+	}
+	return fh
+}
+
+func (h *cameraHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	child := &cameraHandler{
+		next:     h.next.WithAttrs(attrs),
+		files:    h.files,
+		level:    h.level,
+		steps:    append(h.steps[:len(h.steps):len(h.steps)], handlerStep{attrs: attrs}),
+		grouped:  h.grouped,
+		cameraID: h.cameraID,
+	}
+	if !h.grouped {
+		for _, a := range attrs {
+			if a.Key == "camera_id" {
+				child.cameraID = a.Value.String()
+			}
+		}
+	}
+	return child
+}
+
+func (h *cameraHandler) WithGroup(name string) slog.Handler {
+	return &cameraHandler{
+		next:     h.next.WithGroup(name),
+		files:    h.files,
+		level:    h.level,
+		steps:    append(h.steps[:len(h.steps):len(h.steps)], handlerStep{group: name}),
+		grouped:  true,
+		cameraID: h.cameraID,
+	}
+}
+
+// rotatingFile is an append-only file that is renamed to path.1 (shifting
+// older backups up) once it would grow past maxSize
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create log directory: %w", err)
+	}
+	r := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat log file: %w", err)
+	}
+	r.f = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts path.N-1 → path.N ... path → path.1, dropping the oldest
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return fmt.Errorf("close log file: %w", err)
+	}
+	r.f = nil
+
+	if r.maxBackups > 0 {
+		for i := r.maxBackups - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return fmt.Errorf("rotate log file: %w", err)
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return fmt.Errorf("rotate log file: %w", err)
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCameraFiles(t *testing.T) {
+	dir := t.TempDir()
+	files, err := NewCameraFiles(filepath.Join(dir, "logs", "{camera_id}.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer files.Close()
+
+	logger := slog.New(files.Handler(slog.NewJSONHandler(io.Discard, nil), slog.LevelInfo))
+	logger.With("camera_id", "enterprises/e1/devices/camA", "component", "relay").Info("relay started")
+	logger.Info("stream extended", "camera_id", "camB")
+	logger.Info("service started")
+	logger.With("camera_id", "camA").Debug("below level")
+
+	read := func(id string) string {
+		data, err := os.ReadFile(files.Path(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if a := read("camA"); strings.Count(a, "\n") != 1 || !strings.Contains(a, `"msg":"relay started"`) || !strings.Contains(a, `"component":"relay"`) {
+		t.Errorf("camA log = %q", a)
+	}
+	if b := read("camB"); strings.Count(b, "\n") != 1 || !strings.Contains(b, "stream extended") {
+		t.Errorf("camB log = %q", b)
+	}
+
+	if _, err := NewCameraFiles("logs/relay.log"); err == nil {
+		t.Error("template without placeholder accepted")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cam.log")
+	r, err := openRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range map[string]string{path: "fourth\n", path + ".1": "third\n", path + ".2": "second\n"} {
+		if data, _ := os.ReadFile(name); string(data) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(name), data, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("more backups kept than configured")
+	}
+}
@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// DefaultRingSize is the number of records a Ring keeps unless NewRing is
+// given another size
+const DefaultRingSize = 5000
+
+// Ring keeps the most recent log records as JSON lines, so they can be
+// included in diagnostic bundles without reading log files back from disk
+type Ring struct {
+	mu    sync.Mutex
+	lines [][]byte
+	next  int
+	full  bool
+}
+
+// NewRing creates a ring holding up to size records
+func NewRing(size int) *Ring {
+	if size <= 0 {
+		size = DefaultRingSize
+	}
+	return &Ring{lines: make([][]byte, size)}
+}
+
+// Write stores one record. slog's JSON handler issues exactly one Write per
+// record, so each call is kept as one line.
+func (r *Ring) Write(p []byte) (int, error) {
+	line := bytes.TrimRight(p, "\n")
+	line = append(make([]byte, 0, len(line)), line...)
+
+	r.mu.Lock()
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+	r.mu.Unlock()
+	return len(p), nil
+}
+
+// Lines returns the buffered records, oldest first
+func (r *Ring) Lines() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([][]byte(nil), r.lines[:r.next]...)
+	}
+	out := make([][]byte, 0, len(r.lines))
+	out = append(out, r.lines[r.next:]...)
+	return append(out, r.lines[:r.next]...)
+}
+
+// Handler returns a handler that sends every record to next and also keeps
+// a JSON copy in the ring. The ring captures records at level and above,
+// independent of next's level.
+func (r *Ring) Handler(next slog.Handler, level slog.Leveler) slog.Handler {
+	return &teeHandler{
+		next: next,
+		ring: slog.NewJSONHandler(r, &slog.HandlerOptions{Level: level}),
+	}
+}
+
+// teeHandler fans records out to the primary handler and the ring
+type teeHandler struct {
+	next slog.Handler
+	ring slog.Handler
+}
+
+func (h *teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level) || h.ring.Enabled(ctx, level)
+}
+
+func (h *teeHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if h.ring.Enabled(ctx, rec.Level) {
+		h.ring.Handle(ctx, rec.Clone())
+	}
+	if h.next.Enabled(ctx, rec.Level) {
+		return h.next.Handle(ctx, rec)
+	}
+	return nil
+}
+
+func (h *teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &teeHandler{next: h.next.WithAttrs(attrs), ring: h.ring.WithAttrs(attrs)}
+}
+
+func (h *teeHandler) WithGroup(name string) slog.Handler {
+	return &teeHandler{next: h.next.WithGroup(name), ring: h.ring.WithGroup(name)}
+}
@@ -0,0 +1,167 @@
+// Package membudget caps the bytes relays keep buffered, per camera and
+// across the process, so one pathological stream can't exhaust the memory of
+// a small host running many cameras.
+package membudget
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Budget is the global memory budget. Each camera draws from it through its
+// own Account, which may also have a per-camera cap. A nil Budget is
+// unlimited.
+type Budget struct {
+	total     int64 // Bytes across all cameras; 0 is unlimited
+	perCamera int64 // Bytes per camera; 0 is unlimited
+
+	used     atomic.Int64
+	rejected atomic.Uint64
+
+	mu       sync.Mutex
+	accounts map[string]*Account
+}
+
+// NewBudget creates a budget of total bytes with at most perCamera bytes per
+// camera. Zero disables either cap.
+func NewBudget(total, perCamera int64) *Budget {
+	return &Budget{
+		total:     total,
+		perCamera: perCamera,
+		accounts:  make(map[string]*Account),
+	}
+}
+
+// Account returns the camera's account, creating it on first use. It
+// returns nil, which never refuses a reservation, for a nil Budget.
+func (b *Budget) Account(cameraID string) *Account {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	a, ok := b.accounts[cameraID]
+	if !ok {
+		a = &Account{budget: b, cameraID: cameraID, limit: b.perCamera}
+		b.accounts[cameraID] = a
+	}
+	return a
+}
+
+// Stats returns current usage
+func (b *Budget) Stats() Stats {
+	if b == nil {
+		return Stats{}
+	}
+
+	b.mu.Lock()
+	cameras := make(map[string]int64, len(b.accounts))
+	for id, a := range b.accounts {
+		cameras[id] = a.Used()
+	}
+	b.mu.Unlock()
+
+	return Stats{
+		Total:     b.total,
+		PerCamera: b.perCamera,
+		Used:      b.used.Load(),
+		Rejected:  b.rejected.Load(),
+		Cameras:   cameras,
+	}
+}
+
+// reserve takes n bytes from the global budget
+func (b *Budget) reserve(n int64) bool {
+	if b.total <= 0 {
+		b.used.Add(n)
+		return true
+	}
+	for {
+		used := b.used.Load()
+		if used+n > b.total {
+			return false
+		}
+		if b.used.CompareAndSwap(used, used+n) {
+			return true
+		}
+	}
+}
+
+// Stats reports a budget's usage
+type Stats struct {
+	Total     int64            `json:"total"`     // Configured global cap; 0 is unlimited
+	PerCamera int64            `json:"perCamera"` // Configured per-camera cap; 0 is unlimited
+	Used      int64            `json:"used"`      // Bytes currently reserved
+	Rejected  uint64           `json:"rejected"`  // Reservations refused by either cap
+	Cameras   map[string]int64 `json:"cameras"`   // Bytes reserved per camera
+}
+
+// Account is one camera's share of a Budget. Buffers reserve bytes before
+// holding data and release them once it is gone. All methods are safe on a
+// nil Account, which is unlimited.
+type Account struct {
+	budget   *Budget
+	cameraID string
+	limit    int64
+
+	used     atomic.Int64
+	rejected atomic.Uint64
+}
+
+// Reserve takes n bytes from the account and the global budget, reporting
+// false without taking anything if either would go over its cap
+func (a *Account) Reserve(n int) bool {
+	if a == nil || n <= 0 {
+		return true
+	}
+	size := int64(n)
+
+	for {
+		used := a.used.Load()
+		if a.limit > 0 && used+size > a.limit {
+			a.reject()
+			return false
+		}
+		if a.used.CompareAndSwap(used, used+size) {
+			break
+		}
+	}
+	if !a.budget.reserve(size) {
+		a.used.Add(-size)
+		a.reject()
+		return false
+	}
+	return true
+}
+
+// Release returns n previously reserved bytes
+func (a *Account) Release(n int) {
+	if a == nil || n <= 0 {
+		return
+	}
+	a.used.Add(-int64(n))
+	a.budget.used.Add(-int64(n))
+}
+
+// Used returns the bytes the camera currently holds
+func (a *Account) Used() int64 {
+	if a == nil {
+		return 0
+	}
+	return a.used.Load()
+}
+
+// Rejected returns how many reservations the account has refused
+func (a *Account) Rejected() uint64 {
+	if a == nil {
+		return 0
+	}
+	return a.rejected.Load()
+}
+
+func (a *Account) reject() {
+	a.rejected.Add(1)
+	a.budget.rejected.Add(1)
+}
@@ -0,0 +1,66 @@
+package membudget
+
+import "testing"
+
+func TestAccountCaps(t *testing.T) {
+	b := NewBudget(100, 60)
+	cam1, cam2 := b.Account("cam1"), b.Account("cam2")
+
+	if !cam1.Reserve(60) {
+		t.Fatal("reservation within both caps refused")
+	}
+	if cam1.Reserve(1) {
+		t.Error("per-camera cap not enforced")
+	}
+	if cam2.Reserve(50) {
+		t.Error("global cap not enforced")
+	}
+	if !cam2.Reserve(40) {
+		t.Fatal("reservation up to the global cap refused")
+	}
+
+	cam1.Release(30)
+	if !cam2.Reserve(20) {
+		t.Error("released bytes not returned to the global budget")
+	}
+
+	stats := b.Stats()
+	if stats.Used != 90 || stats.Cameras["cam1"] != 30 || stats.Cameras["cam2"] != 60 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if stats.Rejected != 2 || cam1.Rejected() != 1 || cam2.Rejected() != 1 {
+		t.Errorf("rejected = %d (cam1 %d, cam2 %d), want 2 (1, 1)", stats.Rejected, cam1.Rejected(), cam2.Rejected())
+	}
+	if b.Account("cam1") != cam1 {
+		t.Error("Account returned a new account for a known camera")
+	}
+}
+
+func TestNilBudgetUnlimited(t *testing.T) {
+	var b *Budget
+	a := b.Account("cam1")
+	if !a.Reserve(1 << 40) {
+		t.Error("nil account refused a reservation")
+	}
+	a.Release(1 << 40)
+	if a.Used() != 0 || b.Stats().Used != 0 {
+		t.Error("nil budget reported usage")
+	}
+}
+
+func TestBufferPoolSizeClasses(t *testing.T) {
+	for _, n := range []int{0, 1, minPooledSize, minPooledSize + 1, 100 << 10, maxPooledSize} {
+		buf := GetBuffer(n)
+		if len(buf) != 0 || cap(buf) < n {
+			t.Errorf("GetBuffer(%d): len %d cap %d", n, len(buf), cap(buf))
+		}
+		if c := cap(buf); c&(c-1) != 0 {
+			t.Errorf("GetBuffer(%d): capacity %d is not a size class", n, c)
+		}
+		PutBuffer(buf)
+	}
+
+	if buf := GetBuffer(maxPooledSize + 1); cap(buf) != maxPooledSize+1 {
+		t.Errorf("oversized buffer capacity %d", cap(buf))
+	}
+}
@@ -0,0 +1,54 @@
+package membudget
+
+import (
+	"math/bits"
+	"sync"
+)
+
+const (
+	minPooledShift = 12 // 4KiB: smallest pooled buffer; audio frames and P-frames fit
+	maxPooledShift = 22 // 4MiB: larger buffers are left to the garbage collector
+
+	minPooledSize = 1 << minPooledShift
+	maxPooledSize = 1 << maxPooledShift
+)
+
+// pools holds one sync.Pool per power-of-two size class from minPooledSize
+// to maxPooledSize
+var pools [maxPooledShift - minPooledShift + 1]sync.Pool
+
+// sizeClass returns the pool index for a buffer of capacity n, or -1 when n
+// is outside the pooled range
+func sizeClass(n int) int {
+	if n > maxPooledSize {
+		return -1
+	}
+	if n <= minPooledSize {
+		return 0
+	}
+	return bits.Len(uint(n-1)) - minPooledShift
+}
+
+// GetBuffer returns a zero-length buffer with capacity for at least n bytes,
+// reusing one returned by PutBuffer when possible
+func GetBuffer(n int) []byte {
+	class := sizeClass(n)
+	if class < 0 {
+		return make([]byte, 0, n)
+	}
+	if buf, ok := pools[class].Get().(*[]byte); ok {
+		return (*buf)[:0]
+	}
+	return make([]byte, 0, minPooledSize<<class)
+}
+
+// PutBuffer returns a buffer from GetBuffer for reuse. The caller must not
+// touch buf afterwards.
+func PutBuffer(buf []byte) {
+	c := cap(buf)
+	if c < minPooledSize || c > maxPooledSize || c&(c-1) != 0 {
+		return // Not one of ours
+	}
+	buf = buf[:0]
+	pools[sizeClass(c)].Put(&buf)
+}
@@ -0,0 +1,119 @@
+package nest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// partnerConnectionsURL is the Device Access consent page, which lets the
+// user pick the homes and devices the project may access
+const partnerConnectionsURL = "https://nestservices.google.com/partnerconnections"
+
+// OAuth scopes the relay can use
+const (
+	ScopeSDM    = "https://www.googleapis.com/auth/sdm.service"
+	ScopePubSub = "https://www.googleapis.com/auth/pubsub" // For camera events
+)
+
+// PKCE is a proof key for one authorization: the challenge goes in the
+// consent URL and the verifier with the code exchange
+type PKCE struct {
+	Verifier  string
+	Challenge string
+}
+
+// NewPKCE creates a random S256 proof key
+func NewPKCE() (PKCE, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return PKCE{}, fmt.Errorf("generate code verifier: %w", err)
+	}
+	verifier := base64.RawURLEncoding.EncodeToString(b)
+	sum := sha256.Sum256([]byte(verifier))
+	return PKCE{
+		Verifier:  verifier,
+		Challenge: base64.RawURLEncoding.EncodeToString(sum[:]),
+	}, nil
+}
+
+// AuthCodeURL returns the consent page for the project's Device Access,
+// which redirects to redirectURI with an authorization code and state. It
+// asks for offline access so the code yields a refresh token.
+func (c *Client) AuthCodeURL(projectID, redirectURI, state string, pkce PKCE, scopes ...string) string {
+	if len(scopes) == 0 {
+		scopes = []string{ScopeSDM}
+	}
+	q := url.Values{
+		"client_id":             {c.clientID},
+		"redirect_uri":          {redirectURI},
+		"response_type":         {"code"},
+		"scope":                 {strings.Join(scopes, " ")},
+		"access_type":           {"offline"},
+		"prompt":                {"consent"},
+		"state":                 {state},
+		"code_challenge":        {pkce.Challenge},
+		"code_challenge_method": {"S256"},
+	}
+	return fmt.Sprintf("%s/%s/auth?%s", partnerConnectionsURL, url.PathEscape(projectID), q.Encode())
+}
+
+// ExchangeCode trades an authorization code for a refresh token. The
+// access token issued with it is cached, so the client is ready to use.
+func (c *Client) ExchangeCode(ctx context.Context, code, redirectURI string, pkce PKCE) (string, error) {
+	data := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {c.clientID},
+		"client_secret": {c.clientSecret},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {pkce.Verifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", googleTokenURL,
+		bytes.NewBufferString(data.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("code exchange failed: %s (status %d)", body, resp.StatusCode)
+	}
+
+	var tokenResp struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("decode token response: %w", err)
+	}
+	if tokenResp.RefreshToken == "" {
+		return "", fmt.Errorf("no refresh token in response; revoke the app's access and authorize again")
+	}
+
+	c.mu.Lock()
+	c.refreshToken = tokenResp.RefreshToken
+	c.accessToken = tokenResp.AccessToken
+	c.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	c.mu.Unlock()
+
+	return tokenResp.RefreshToken, nil
+}
@@ -0,0 +1,74 @@
+package nest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAuthCodeURL(t *testing.T) {
+	c := NewClient("client", "secret", "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	pkce, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(pkce.Verifier))
+	if pkce.Challenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		t.Error("challenge is not the verifier's S256")
+	}
+
+	u, err := url.Parse(c.AuthCodeURL("proj", "http://localhost:8085/callback", "st", pkce, ScopeSDM, ScopePubSub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "nestservices.google.com" || u.Path != "/partnerconnections/proj/auth" {
+		t.Errorf("URL = %s", u)
+	}
+	q := u.Query()
+	if q.Get("client_id") != "client" || q.Get("state") != "st" || q.Get("access_type") != "offline" ||
+		q.Get("code_challenge") != pkce.Challenge || q.Get("code_challenge_method") != "S256" ||
+		q.Get("scope") != ScopeSDM+" "+ScopePubSub {
+		t.Errorf("query = %v", q)
+	}
+}
+
+func TestExchangeCode(t *testing.T) {
+	pkce := PKCE{Verifier: "verifier", Challenge: "challenge"}
+	c := newTestClient(func(req *http.Request) (int, string) {
+		if req.URL.String() == googleTokenURL {
+			body, _ := io.ReadAll(req.Body)
+			form, _ := url.ParseQuery(string(body))
+			if form.Get("grant_type") != "authorization_code" || form.Get("code") != "code" || form.Get("code_verifier") != "verifier" {
+				t.Errorf("form = %v", form)
+			}
+			return 200, `{"access_token":"access","refresh_token":"refresh-new","expires_in":3600}`
+		}
+		if got := req.Header.Get("Authorization"); got != "Bearer access" {
+			t.Errorf("Authorization = %q", got)
+		}
+		return 200, `{"devices":[]}`
+	})
+
+	token, err := c.ExchangeCode(t.Context(), "code", "http://localhost:8085/callback", pkce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "refresh-new" {
+		t.Errorf("refresh token = %q", token)
+	}
+	if _, err := c.ListDevices(t.Context(), "proj"); err != nil {
+		t.Fatal(err)
+	}
+
+	c = newTestClient(func(req *http.Request) (int, string) {
+		return 200, `{"access_token":"access","expires_in":3600}`
+	})
+	if _, err := c.ExchangeCode(t.Context(), "code", "http://localhost:8085/callback", pkce); err == nil || !strings.Contains(err.Error(), "no refresh token") {
+		t.Errorf("err = %v, want no refresh token", err)
+	}
+}
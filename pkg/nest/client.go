@@ -11,6 +11,8 @@ import (
 	"net/url"
 	"sync"
 	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/httpx"
 )
 
 const (
@@ -30,6 +32,7 @@ type Client struct {
 	mu          sync.RWMutex
 	accessToken string
 	tokenExpiry time.Time
+	onToken     func(token string, expiry time.Time) // Called after each refresh
 }
 
 // NewClient creates a new Nest API client
@@ -38,11 +41,35 @@ func NewClient(clientID, clientSecret, refreshToken string, logger *slog.Logger)
 		clientID:     clientID,
 		clientSecret: clientSecret,
 		refreshToken: refreshToken,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		logger: logger,
+		httpClient:   httpx.NewClient(httpx.Config{Timeout: 30 * time.Second}),
+		logger:       logger,
+	}
+}
+
+// SetHTTPClient replaces the client used for OAuth and SDM requests
+func (c *Client) SetHTTPClient(hc *http.Client) {
+	c.httpClient = hc
+}
+
+// SetAccessToken seeds the token cache with a token saved by an earlier
+// run, so a restart doesn't spend a refresh. Expired tokens are ignored.
+func (c *Client) SetAccessToken(token string, expiry time.Time) {
+	if token == "" || !time.Now().Add(30 * time.Second).Before(expiry) {
+		return
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accessToken = token
+	c.tokenExpiry = expiry
+}
+
+// OnTokenRefresh sets fn to be called with each newly refreshed access
+// token, e.g. to persist it. It runs with the token cache locked and must
+// not call back into the client.
+func (c *Client) OnTokenRefresh(fn func(token string, expiry time.Time)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onToken = fn
 }
 
 // Device represents a Nest camera device
@@ -64,6 +91,27 @@ type Traits struct {
 		AudioCodecs        []string `json:"audioCodecs"`
 		SupportedProtocols []string `json:"supportedProtocols"`
 	} `json:"sdm.devices.traits.CameraLiveStream"`
+	Connectivity struct {
+		Status string `json:"status"` // "ONLINE" or "OFFLINE"
+	} `json:"sdm.devices.traits.Connectivity"`
+}
+
+// DisplayName picks the most descriptive name available: the custom name,
+// else the room's, else the device ID
+func (d Device) DisplayName() string {
+	if d.Traits.Info.CustomName != "" {
+		return d.Traits.Info.CustomName
+	}
+	if len(d.Relations) > 0 && d.Relations[0].DisplayName != "" {
+		return d.Relations[0].DisplayName
+	}
+	return d.DeviceID
+}
+
+// Online reports whether the device is reachable. Devices without a
+// Connectivity trait are assumed online.
+func (d Device) Online() bool {
+	return d.Traits.Connectivity.Status != "OFFLINE"
 }
 
 // Parent represents parent relations (rooms, structures)
@@ -97,6 +145,23 @@ func (c *Client) getAccessToken(ctx context.Context) (string, error) {
 	return c.refreshAccessToken(ctx)
 }
 
+// RefreshToken exchanges the refresh token for a new access token even when
+// the cached one is still valid, so bad credentials surface immediately
+func (c *Client) RefreshToken(ctx context.Context) error {
+	c.mu.Lock()
+	c.tokenExpiry = time.Time{}
+	c.mu.Unlock()
+
+	_, err := c.refreshAccessToken(ctx)
+	return err
+}
+
+// AccessToken returns a valid access token for other Google APIs the OAuth
+// client is authorized for, such as the project's Pub/Sub subscription
+func (c *Client) AccessToken(ctx context.Context) (string, error) {
+	return c.getAccessToken(ctx)
+}
+
 // refreshAccessToken obtains a new access token using the refresh token
 func (c *Client) refreshAccessToken(ctx context.Context) (string, error) {
 	c.mu.Lock()
@@ -131,7 +196,11 @@ func (c *Client) refreshAccessToken(ctx context.Context) (string, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("token refresh failed: %s (status %d)", body, resp.StatusCode)
+		e := apiError("token refresh failed", body, resp.StatusCode, resp.Header)
+		if resp.StatusCode == http.StatusBadRequest {
+			e.Err = ErrUnauthorized // invalid_grant: the refresh token was revoked or has expired
+		}
+		return "", e
 	}
 
 	var tokenResp struct {
@@ -150,6 +219,9 @@ func (c *Client) refreshAccessToken(ctx context.Context) (string, error) {
 
 	c.logger.Info("access token refreshed",
 		"expires_at", c.tokenExpiry.Format(time.RFC3339))
+	if c.onToken != nil {
+		c.onToken(c.accessToken, c.tokenExpiry)
+	}
 
 	return c.accessToken, nil
 }
@@ -176,7 +248,7 @@ func (c *Client) ListDevices(ctx context.Context, projectID string) ([]Device, e
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("list devices failed: %s (status %d)", body, resp.StatusCode)
+		return nil, apiError("list devices failed", body, resp.StatusCode, resp.Header)
 	}
 
 	var devicesResp struct {
@@ -210,6 +282,40 @@ func (c *Client) ListDevices(ctx context.Context, projectID string) ([]Device, e
 	return cameras, nil
 }
 
+// GetDevice retrieves a single device, including its current connectivity
+func (c *Client) GetDevice(ctx context.Context, projectID, deviceID string) (*Device, error) {
+	token, err := c.getAccessToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get access token: %w", err)
+	}
+
+	uri := fmt.Sprintf("%s/enterprises/%s/devices/%s", sdmBaseURL, projectID, deviceID)
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get device request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, apiError("get device failed", body, resp.StatusCode, resp.Header)
+	}
+
+	var device Device
+	if err := json.NewDecoder(resp.Body).Decode(&device); err != nil {
+		return nil, fmt.Errorf("decode device response: %w", err)
+	}
+	device.DeviceID = extractDeviceID(device.Name)
+
+	return &device, nil
+}
+
 // GenerateRTSPStream generates an RTSP stream URL for a camera
 func (c *Client) GenerateRTSPStream(ctx context.Context, projectID, deviceID string) (*RTSPStream, error) {
 	token, err := c.getAccessToken(ctx)
@@ -245,7 +351,7 @@ func (c *Client) GenerateRTSPStream(ctx context.Context, projectID, deviceID str
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("generate stream failed: %s (status %d)", body, resp.StatusCode)
+		return nil, commandError("generate stream failed", body, resp.StatusCode, resp.Header)
 	}
 
 	var streamResp struct {
@@ -319,7 +425,7 @@ func (c *Client) ExtendRTSPStream(ctx context.Context, stream *RTSPStream) error
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("extend stream failed: %s (status %d)", body, resp.StatusCode)
+		return commandError("extend stream failed", body, resp.StatusCode, resp.Header)
 	}
 
 	var extendResp struct {
@@ -383,7 +489,7 @@ func (c *Client) StopRTSPStream(ctx context.Context, stream *RTSPStream) error {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("stop stream failed: %s (status %d)", body, resp.StatusCode)
+		return commandError("stop stream failed", body, resp.StatusCode, resp.Header)
 	}
 
 	c.logger.Info("stopped RTSP stream", "device_id", stream.DeviceID)
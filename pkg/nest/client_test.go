@@ -0,0 +1,96 @@
+package nest
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCommandErrorOffline(t *testing.T) {
+	tests := []struct {
+		body    string
+		offline bool
+	}{
+		{`{"error":{"code":400,"message":"The camera is offline.","status":"FAILED_PRECONDITION"}}`, true},
+		{`{"error":{"code":503,"message":"Device is currently unavailable.","status":"UNAVAILABLE"}}`, true},
+		{`{"error":{"code":503,"message":"The service is currently unavailable.","status":"UNAVAILABLE"}}`, false},
+		{`{"error":{"code":429,"message":"Rate limited","status":"RESOURCE_EXHAUSTED"}}`, false},
+		{`not json`, false},
+	}
+
+	for _, tt := range tests {
+		err := commandError("generate stream failed", []byte(tt.body), 400, nil)
+		if got := errors.Is(err, ErrDeviceOffline); got != tt.offline {
+			t.Errorf("%s: offline = %v, want %v", tt.body, got, tt.offline)
+		}
+	}
+}
+
+func TestDeviceOnline(t *testing.T) {
+	var d Device
+	if !d.Online() {
+		t.Error("device without Connectivity trait should be online")
+	}
+	d.Traits.Connectivity.Status = "OFFLINE"
+	if d.Online() {
+		t.Error("OFFLINE device reported online")
+	}
+}
+
+func TestSavedAccessToken(t *testing.T) {
+	refreshes := 0
+	c := NewClient("id", "secret", "refresh", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	c.SetHTTPClient(&http.Client{Transport: sdmFunc(func(req *http.Request) (int, string) {
+		refreshes++
+		return 200, `{"access_token":"fresh","expires_in":3600}`
+	})})
+
+	var saved string
+	c.OnTokenRefresh(func(token string, expiry time.Time) { saved = token })
+
+	// An expired saved token is ignored
+	c.SetAccessToken("stale", time.Now().Add(-time.Minute))
+	if token, err := c.AccessToken(t.Context()); err != nil || token != "fresh" || saved != "fresh" {
+		t.Errorf("token %q, saved %q, err %v", token, saved, err)
+	}
+
+	c.SetAccessToken("saved", time.Now().Add(time.Hour))
+	if token, _ := c.AccessToken(t.Context()); token != "saved" {
+		t.Errorf("token = %q, want the saved one", token)
+	}
+	if refreshes != 1 {
+		t.Errorf("%d refreshes, want 1", refreshes)
+	}
+}
+
+func TestCommandErrorQuota(t *testing.T) {
+	body := []byte(`{"error":{"code":429,"message":"Rate limited","status":"RESOURCE_EXHAUSTED",` +
+		`"details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"12s"}]}}`)
+	tests := []struct {
+		name       string
+		status     int
+		header     http.Header
+		retryAfter time.Duration
+	}{
+		{"seconds header", 429, http.Header{"Retry-After": {"30"}}, 30 * time.Second},
+		{"RetryInfo", 429, nil, 12 * time.Second},
+		{"past date header", 429, http.Header{"Retry-After": {"Wed, 21 Oct 2015 07:28:00 GMT"}}, 0},
+		{"status in body only", 400, nil, 12 * time.Second},
+	}
+
+	for _, tt := range tests {
+		err := commandError("extend stream failed", body, tt.status, tt.header)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("%s: %v is not a quota error", tt.name, err)
+			continue
+		}
+		if apiErr.RetryAfter != tt.retryAfter {
+			t.Errorf("%s: RetryAfter = %v, want %v", tt.name, apiErr.RetryAfter, tt.retryAfter)
+		}
+	}
+
+}
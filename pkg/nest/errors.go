@@ -0,0 +1,145 @@
+package nest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Causes of failed API requests. Errors from the client wrap at most one,
+// so callers can branch with errors.Is; errors.As with an *APIError gives
+// the details of the response.
+var (
+	// ErrStreamExpired: a stream command found its stream gone, usually
+	// because it expired; a new one must be generated
+	ErrStreamExpired = errors.New("stream expired")
+
+	// ErrQuotaExceeded: the project has spent its SDM quota for now (429
+	// RESOURCE_EXHAUSTED)
+	ErrQuotaExceeded = errors.New("quota exceeded")
+
+	// ErrUnauthorized: the OAuth credentials were refused, or don't grant
+	// access to the project or device; retrying won't help
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrDeviceOffline: SDM reports the camera is offline or unreachable;
+	// retrying before it reconnects only burns quota
+	ErrDeviceOffline = errors.New("device offline")
+)
+
+// APIError is a request a Google API refused
+type APIError struct {
+	Op         string        // What failed, e.g. "extend stream failed"
+	StatusCode int           // HTTP status
+	Status     string        // API status, e.g. NOT_FOUND; empty when the body has none
+	Message    string        // API error message
+	Body       []byte        // Response body as received
+	RetryAfter time.Duration // How long a quota error asked callers to wait; zero when it didn't say
+	Err        error         // The cause, one of the Err variables above; nil when unknown
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s: %s (status %d)", e.Op, e.Body, e.StatusCode)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the cause
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// commandError builds the error for a failed executeCommand. A command's
+// 404 means the stream it names is gone.
+func commandError(op string, body []byte, status int, header http.Header) error {
+	e := apiError(op, body, status, header)
+	if e.Err == nil && (status == http.StatusNotFound || e.Status == "NOT_FOUND" ||
+		strings.Contains(strings.ToLower(e.Message), "expired")) {
+		e.Err = ErrStreamExpired
+	}
+	return e
+}
+
+// apiError builds the error for a refused request from its response,
+// classifying its cause
+func apiError(op string, body []byte, status int, header http.Header) *APIError {
+	sdmErr, _ := parseErrorBody(body)
+	e := &APIError{
+		Op:         op,
+		StatusCode: status,
+		Status:     sdmErr.Status,
+		Message:    sdmErr.Message,
+		Body:       body,
+	}
+	switch {
+	case status == http.StatusTooManyRequests || sdmErr.Status == "RESOURCE_EXHAUSTED":
+		e.Err = ErrQuotaExceeded
+		e.RetryAfter = retryAfter(header, sdmErr)
+	case isOffline(sdmErr):
+		e.Err = ErrDeviceOffline
+	case status == http.StatusUnauthorized || status == http.StatusForbidden ||
+		sdmErr.Status == "UNAUTHENTICATED" || sdmErr.Status == "PERMISSION_DENIED":
+		e.Err = ErrUnauthorized
+	}
+	return e
+}
+
+// errorBody is the error status of a failed SDM request
+type errorBody struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Details []struct {
+		Type       string `json:"@type"`
+		RetryDelay string `json:"retryDelay"` // google.rpc.RetryInfo, e.g. "30s"
+	} `json:"details"`
+}
+
+// parseErrorBody reads the error status of an SDM error response
+func parseErrorBody(body []byte) (errorBody, bool) {
+	var resp struct {
+		Error errorBody `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return errorBody{}, false
+	}
+	return resp.Error, true
+}
+
+// retryAfter returns how long a refused request asked callers to wait:
+// the Retry-After header, in seconds or as a date, else the body's
+// RetryInfo; zero when neither is given
+func retryAfter(header http.Header, sdmErr errorBody) time.Duration {
+	if v := header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			return time.Duration(secs) * time.Second
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return max(time.Until(t), 0)
+		}
+	}
+	for _, d := range sdmErr.Details {
+		if !strings.HasSuffix(d.Type, "google.rpc.RetryInfo") {
+			continue
+		}
+		if delay, err := time.ParseDuration(d.RetryDelay); err == nil && delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// isOffline reports whether an SDM error describes an offline or
+// unreachable camera rather than a problem with the request
+func isOffline(sdmErr errorBody) bool {
+	msg := strings.ToLower(sdmErr.Message)
+	if strings.Contains(msg, "offline") || strings.Contains(msg, "not reachable") || strings.Contains(msg, "unreachable") {
+		return true
+	}
+	return sdmErr.Status == "UNAVAILABLE" && strings.Contains(msg, "device")
+}
@@ -0,0 +1,57 @@
+package nest
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestAPIErrorCause(t *testing.T) {
+	tests := []struct {
+		name    string
+		command bool
+		status  int
+		body    string
+		cause   error
+	}{
+		{"expired stream", true, 404, `{"error":{"code":404,"message":"Stream not found.","status":"NOT_FOUND"}}`, ErrStreamExpired},
+		{"expired token", true, 400, `{"error":{"code":400,"message":"Stream extension token has expired.","status":"INVALID_ARGUMENT"}}`, ErrStreamExpired},
+		{"unknown project", false, 404, `{"error":{"code":404,"message":"Enterprise not found.","status":"NOT_FOUND"}}`, nil},
+		{"quota", true, 429, `{"error":{"code":429,"message":"Rate limited","status":"RESOURCE_EXHAUSTED"}}`, ErrQuotaExceeded},
+		{"bad token", false, 401, `{"error":{"code":401,"message":"Request had invalid authentication credentials.","status":"UNAUTHENTICATED"}}`, ErrUnauthorized},
+		{"not shared", true, 403, `{"error":{"code":403,"message":"The caller does not have permission","status":"PERMISSION_DENIED"}}`, ErrUnauthorized},
+		{"offline", true, 400, `{"error":{"code":400,"message":"The camera is offline.","status":"FAILED_PRECONDITION"}}`, ErrDeviceOffline},
+		{"server error", true, 500, `{"error":{"code":500,"message":"Internal error.","status":"INTERNAL"}}`, nil},
+		{"not json", true, 502, `bad gateway`, nil},
+	}
+
+	causes := []error{ErrStreamExpired, ErrQuotaExceeded, ErrUnauthorized, ErrDeviceOffline}
+	for _, tt := range tests {
+		var err error
+		if tt.command {
+			err = commandError("extend stream failed", []byte(tt.body), tt.status, nil)
+		} else {
+			err = apiError("list devices failed", []byte(tt.body), tt.status, nil)
+		}
+		for _, cause := range causes {
+			if got, want := errors.Is(err, cause), cause == tt.cause; got != want {
+				t.Errorf("%s: errors.Is(%v) = %v, want %v", tt.name, cause, got, want)
+			}
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+			t.Errorf("%s: %v is not an *APIError with status %d", tt.name, err, tt.status)
+		}
+	}
+}
+
+func TestCommandFailedKeepsCause(t *testing.T) {
+	refused := commandError("", []byte(`{"error":{"code":404,"status":"NOT_FOUND"}}`), http.StatusNotFound, nil)
+	err := commandFailed("extend WebRTC stream failed", refused)
+	if !errors.Is(err, ErrStreamExpired) {
+		t.Errorf("%v lost its cause", err)
+	}
+	if want := `extend WebRTC stream failed: {"error":{"code":404,"status":"NOT_FOUND"}} (status 404): stream expired`; err.Error() != want {
+		t.Errorf("error %q, want %q", err, want)
+	}
+}
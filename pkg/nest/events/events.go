@@ -0,0 +1,344 @@
+// Package events subscribes to the Pub/Sub topic of a Device Access project
+// and reports the cameras' motion, person, sound and doorbell chime events,
+// to Go callbacks and as a short per-camera history. The subscription is
+// pulled over the Pub/Sub REST API with the Nest OAuth client's token, so
+// the client must have been granted the Pub/Sub scope.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+)
+
+const pubsubBaseURL = "https://pubsub.googleapis.com/v1"
+
+// Type is the kind of camera event
+type Type string
+
+const (
+	Motion Type = "motion"
+	Person Type = "person"
+	Sound  Type = "sound"
+	Chime  Type = "chime"
+)
+
+// sdmTypes maps SDM event names to their Type
+var sdmTypes = map[string]Type{
+	"sdm.devices.events.CameraMotion.Motion": Motion,
+	"sdm.devices.events.CameraPerson.Person": Person,
+	"sdm.devices.events.CameraSound.Sound":   Sound,
+	"sdm.devices.events.DoorbellChime.Chime": Chime,
+}
+
+// Event is one camera event
+type Event struct {
+	ID        string    `json:"id"`
+	Type      Type      `json:"type"`
+	DeviceID  string    `json:"deviceId"`
+	SessionID string    `json:"sessionId,omitempty"` // Shared by the events of one occurrence, e.g. motion then person
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// TokenFunc returns an OAuth access token with the Pub/Sub scope
+type TokenFunc func(ctx context.Context) (string, error)
+
+// Config configures a Subscriber; zero fields are defaults
+type Config struct {
+	// Subscription is the full name of the project's Pub/Sub subscription,
+	// projects/{gcp-project}/subscriptions/{name}
+	Subscription string
+	Retain       int // Events kept per camera for Recent (default 50)
+	MaxMessages  int // Messages per pull (default 100)
+}
+
+// Subscriber pulls a Device Access subscription and dispatches its events
+type Subscriber struct {
+	config     Config
+	token      TokenFunc
+	httpClient *http.Client
+	logger     *slog.Logger
+
+	mu       sync.RWMutex
+	handlers []func(Event)
+	recent   map[string][]Event // By device ID, oldest first
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewSubscriber creates a subscriber for config.Subscription. Nothing is
+// pulled until Start.
+func NewSubscriber(config Config, token TokenFunc, logger *slog.Logger) *Subscriber {
+	if config.Retain <= 0 {
+		config.Retain = 50
+	}
+	if config.MaxMessages <= 0 {
+		config.MaxMessages = 100
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Subscriber{
+		config:     config,
+		token:      token,
+		httpClient: http.DefaultClient,
+		logger:     logger,
+		recent:     make(map[string][]Event),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// SetHTTPClient replaces the client used for Pub/Sub requests
+func (s *Subscriber) SetHTTPClient(hc *http.Client) {
+	s.httpClient = hc
+}
+
+// OnEvent registers fn to be called with each new event, from the pull
+// loop; it should return quickly. It may be called multiple times.
+func (s *Subscriber) OnEvent(fn func(Event)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, fn)
+}
+
+// Start begins pulling the subscription
+func (s *Subscriber) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer goroutines.Track("events.pullLoop", "")()
+		s.pullLoop()
+	}()
+	s.logger.Info("event subscriber started", "subscription", s.config.Subscription)
+}
+
+// Stop stops pulling and waits for the loop to exit. Messages pulled but
+// not yet acknowledged are redelivered to the next subscriber.
+func (s *Subscriber) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// Recent returns a camera's latest events, newest first; at most limit
+// when limit is positive. An empty device ID returns every camera's.
+func (s *Subscriber) Recent(deviceID string, limit int) []Event {
+	s.mu.RLock()
+	var events []Event
+	if deviceID != "" {
+		events = append(events, s.recent[deviceID]...)
+	} else {
+		for _, evs := range s.recent {
+			events = append(events, evs...)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.After(events[j].Timestamp)
+	})
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+	return events
+}
+
+// pullLoop pulls until Stop, backing off after failures
+func (s *Subscriber) pullLoop() {
+	const maxBackoff = time.Minute
+	backoff := time.Second
+
+	for s.ctx.Err() == nil {
+		if err := s.pullOnce(); err != nil {
+			if s.ctx.Err() != nil {
+				return
+			}
+			s.logger.Warn("event pull failed", "error", err, "retry_in", backoff)
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+		backoff = time.Second
+	}
+}
+
+// pulledMessage is one message of a pull response
+type pulledMessage struct {
+	AckID   string `json:"ackId"`
+	Message struct {
+		Data      []byte `json:"data"` // Base64 in JSON
+		MessageID string `json:"messageId"`
+	} `json:"message"`
+}
+
+// pullOnce pulls one batch of messages, dispatches their events and
+// acknowledges them. A pull the server holds open without messages until
+// a timeout is not an error.
+func (s *Subscriber) pullOnce() error {
+	ctx, cancel := context.WithTimeout(s.ctx, 90*time.Second)
+	defer cancel()
+
+	var resp struct {
+		ReceivedMessages []pulledMessage `json:"receivedMessages"`
+	}
+	err := s.call(ctx, "pull", map[string]any{"maxMessages": s.config.MaxMessages}, &resp)
+	if err != nil {
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) && s.ctx.Err() == nil || errors.As(err, &netErr) && netErr.Timeout() {
+			return nil
+		}
+		return err
+	}
+	if len(resp.ReceivedMessages) == 0 {
+		return nil
+	}
+
+	ackIDs := make([]string, 0, len(resp.ReceivedMessages))
+	for _, m := range resp.ReceivedMessages {
+		ackIDs = append(ackIDs, m.AckID)
+		events, err := parseMessage(m.Message.Data)
+		if err != nil {
+			// Acknowledged all the same: redelivery won't make it parse
+			s.logger.Warn("ignoring unparsable event message", "message_id", m.Message.MessageID, "error", err)
+			continue
+		}
+		for _, ev := range events {
+			s.dispatch(ev)
+		}
+	}
+
+	ackCtx, ackCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer ackCancel()
+	if err := s.call(ackCtx, "acknowledge", map[string]any{"ackIds": ackIDs}, nil); err != nil {
+		return fmt.Errorf("acknowledge: %w", err)
+	}
+	return nil
+}
+
+// dispatch records an event and calls the handlers, once per event ID:
+// Pub/Sub delivers at least once
+func (s *Subscriber) dispatch(ev Event) {
+	s.mu.Lock()
+	evs := s.recent[ev.DeviceID]
+	for _, seen := range evs {
+		if seen.ID == ev.ID && seen.Type == ev.Type {
+			s.mu.Unlock()
+			return
+		}
+	}
+	evs = append(evs, ev)
+	if len(evs) > s.config.Retain {
+		evs = evs[len(evs)-s.config.Retain:]
+	}
+	s.recent[ev.DeviceID] = evs
+	handlers := s.handlers
+	s.mu.Unlock()
+
+	s.logger.Info("camera event",
+		"device_id", ev.DeviceID,
+		"type", ev.Type,
+		"session_id", ev.SessionID)
+	for _, fn := range handlers {
+		fn(ev)
+	}
+}
+
+// call POSTs a Pub/Sub subscription method, decoding the response into
+// result unless it is nil
+func (s *Subscriber) call(ctx context.Context, method string, body, result any) error {
+	token, err := s.token(ctx)
+	if err != nil {
+		return fmt.Errorf("get access token: %w", err)
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", method, err)
+	}
+
+	uri := fmt.Sprintf("%s/%s:%s", pubsubBaseURL, s.config.Subscription, method)
+	req, err := http.NewRequestWithContext(ctx, "POST", uri, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s failed: %s (status %d)", method, body, resp.StatusCode)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("decode %s response: %w", method, err)
+	}
+	return nil
+}
+
+// parseMessage reads the camera events of an SDM event message. Trait
+// updates and event types other than the camera's carry none.
+func parseMessage(data []byte) ([]Event, error) {
+	var msg struct {
+		EventID        string    `json:"eventId"`
+		Timestamp      time.Time `json:"timestamp"`
+		ResourceUpdate struct {
+			Name   string `json:"name"`
+			Events map[string]struct {
+				EventSessionID string `json:"eventSessionId"`
+				EventID        string `json:"eventId"`
+			} `json:"events"`
+		} `json:"resourceUpdate"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+
+	// Device names are enterprises/{project}/devices/{deviceId}
+	_, deviceID, ok := strings.Cut(msg.ResourceUpdate.Name, "/devices/")
+	if !ok && len(msg.ResourceUpdate.Events) > 0 {
+		return nil, fmt.Errorf("event for %q, not a device", msg.ResourceUpdate.Name)
+	}
+
+	var events []Event
+	for name, e := range msg.ResourceUpdate.Events {
+		typ, ok := sdmTypes[name]
+		if !ok {
+			continue
+		}
+		id := e.EventID
+		if id == "" {
+			id = msg.EventID
+		}
+		events = append(events, Event{
+			ID:        id,
+			Type:      typ,
+			DeviceID:  deviceID,
+			SessionID: e.EventSessionID,
+			Timestamp: msg.Timestamp,
+		})
+	}
+	return events, nil
+}
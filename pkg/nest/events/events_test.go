@@ -0,0 +1,164 @@
+package events
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const motionMessage = `{
+  "eventId": "msg-1",
+  "timestamp": "2030-01-01T00:00:01Z",
+  "resourceUpdate": {
+    "name": "enterprises/proj/devices/cam1",
+    "events": {
+      "sdm.devices.events.CameraMotion.Motion": {"eventSessionId": "sess", "eventId": "ev-1"},
+      "sdm.devices.events.CameraClipPreview.ClipPreview": {"eventSessionId": "sess", "previewUrl": "https://example"}
+    }
+  },
+  "eventThreadState": "STARTED"
+}`
+
+func TestParseMessage(t *testing.T) {
+	events, err := parseMessage([]byte(motionMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1 (clip previews are skipped)", len(events))
+	}
+	want := Event{
+		ID:        "ev-1",
+		Type:      Motion,
+		DeviceID:  "cam1",
+		SessionID: "sess",
+		Timestamp: time.Date(2030, 1, 1, 0, 0, 1, 0, time.UTC),
+	}
+	if events[0] != want {
+		t.Errorf("event = %+v, want %+v", events[0], want)
+	}
+
+	// Trait updates carry no events
+	trait := `{"eventId":"msg-2","resourceUpdate":{"name":"enterprises/proj/devices/cam1","traits":{"sdm.devices.traits.Connectivity":{"status":"OFFLINE"}}}}`
+	if events, err := parseMessage([]byte(trait)); err != nil || len(events) != 0 {
+		t.Errorf("trait update = %v, %v", events, err)
+	}
+}
+
+// pubsubFunc answers Pub/Sub requests in place of the API
+type pubsubFunc func(req *http.Request) (int, string)
+
+func (f pubsubFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	status, body := f(req)
+	return &http.Response{
+		StatusCode: status,
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Header:     make(http.Header),
+		Request:    req,
+	}, nil
+}
+
+func TestSubscriberPullsAndAcknowledges(t *testing.T) {
+	data := base64.StdEncoding.EncodeToString([]byte(motionMessage))
+	pulled := `{"receivedMessages":[` +
+		`{"ackId":"a1","message":{"data":"` + data + `","messageId":"1"}},` +
+		`{"ackId":"a2","message":{"data":"` + data + `","messageId":"2"}}]}` // Redelivered
+
+	var mu sync.Mutex
+	var acked []string
+	pulls := 0
+	sub := NewSubscriber(Config{Subscription: "projects/p/subscriptions/s"},
+		func(context.Context) (string, error) { return "token", nil },
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	sub.SetHTTPClient(&http.Client{Transport: pubsubFunc(func(req *http.Request) (int, string) {
+		switch req.URL.String() {
+		case pubsubBaseURL + "/projects/p/subscriptions/s:pull":
+			mu.Lock()
+			pulls++
+			first := pulls == 1
+			mu.Unlock()
+			if first {
+				return 200, pulled
+			}
+			// Nothing more: hold the pull open as the server would
+			<-req.Context().Done()
+			return 200, `{}`
+		case pubsubBaseURL + "/projects/p/subscriptions/s:acknowledge":
+			var body struct {
+				AckIDs []string `json:"ackIds"`
+			}
+			json.NewDecoder(req.Body).Decode(&body)
+			mu.Lock()
+			acked = append(acked, body.AckIDs...)
+			mu.Unlock()
+			return 200, `{}`
+		}
+		t.Errorf("unexpected request %s", req.URL)
+		return 404, ""
+	})})
+
+	got := make(chan Event, 4)
+	sub.OnEvent(func(ev Event) { got <- ev })
+	sub.Start()
+
+	select {
+	case ev := <-got:
+		if ev.Type != Motion || ev.DeviceID != "cam1" {
+			t.Errorf("event = %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event dispatched")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(acked)
+		mu.Unlock()
+		if n == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	sub.Stop()
+
+	if len(acked) != 2 {
+		t.Errorf("acknowledged %v, want both messages", acked)
+	}
+	select {
+	case ev := <-got:
+		t.Errorf("redelivered event dispatched again: %+v", ev)
+	default:
+	}
+	if recent := sub.Recent("cam1", 0); len(recent) != 1 {
+		t.Errorf("Recent = %v, want the one event", recent)
+	}
+	if recent := sub.Recent("other", 0); len(recent) != 0 {
+		t.Errorf("Recent(other) = %v", recent)
+	}
+}
+
+func TestRecentNewestFirst(t *testing.T) {
+	sub := NewSubscriber(Config{Retain: 2}, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	base := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c"} {
+		sub.dispatch(Event{ID: id, Type: Motion, DeviceID: "cam1", Timestamp: base.Add(time.Duration(i) * time.Second)})
+	}
+	sub.dispatch(Event{ID: "d", Type: Chime, DeviceID: "door", Timestamp: base.Add(10 * time.Second)})
+
+	recent := sub.Recent("cam1", 0)
+	if len(recent) != 2 || recent[0].ID != "c" || recent[1].ID != "b" {
+		t.Errorf("Recent(cam1) = %+v, want c then b", recent)
+	}
+	all := sub.Recent("", 2)
+	if len(all) != 2 || all[0].ID != "d" || all[1].ID != "c" {
+		t.Errorf("Recent(all, 2) = %+v, want d then c", all)
+	}
+}
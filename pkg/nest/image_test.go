@@ -0,0 +1,55 @@
+package nest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestGenerateAndFetchImage(t *testing.T) {
+	c := newTestClient(func(req *http.Request) (int, string) {
+		if req.Method == http.MethodGet {
+			if got := req.URL.String(); got != "https://images.example/ev?a=1&width=480" {
+				t.Errorf("image URL = %s", got)
+			}
+			if got := req.Header.Get("Authorization"); got != "Basic img-token" {
+				t.Errorf("Authorization = %q", got)
+			}
+			return 200, "jpeg"
+		}
+
+		var cmd struct {
+			Command string            `json:"command"`
+			Params  map[string]string `json:"params"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&cmd); err != nil {
+			t.Fatal(err)
+		}
+		if cmd.Command != "sdm.devices.commands.CameraEventImage.GenerateImage" || cmd.Params["eventId"] != "ev-1" {
+			t.Errorf("command = %+v", cmd)
+		}
+		return 200, `{"results":{"url":"https://images.example/ev?a=1","token":"img-token"}}`
+	})
+
+	ctx := t.Context()
+	img, err := c.GenerateImage(ctx, "proj", "dev", "ev-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.FetchImage(ctx, img, 480)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "jpeg" {
+		t.Errorf("image = %q", data)
+	}
+}
+
+func TestFetchImageExpired(t *testing.T) {
+	c := newTestClient(func(req *http.Request) (int, string) {
+		return 403, "expired"
+	})
+	if _, err := c.FetchImage(t.Context(), &EventImage{URL: "https://images.example/ev", Token: "t"}, 0); err == nil {
+		t.Error("fetched an image the server refused")
+	}
+}
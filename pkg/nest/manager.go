@@ -2,17 +2,23 @@ package nest
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
 	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
 )
 
-// StreamManager manages RTSP stream lifecycle and automatic extension
+// StreamManager manages RTSP stream lifecycle and automatic extension.
+// WebRTC streams, for cameras without RTSP, are managed the same way.
 type StreamManager struct {
-	client *Client
-	stream *RTSPStream
-	logger *slog.Logger
+	client   *Client
+	stream   *RTSPStream
+	webrtc   *WebRTCStream // Set instead of stream for a WebRTC camera
+	deviceID string
+	logger   *slog.Logger
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -29,6 +35,7 @@ func NewStreamManager(client *Client, stream *RTSPStream, logger *slog.Logger) *
 	return &StreamManager{
 		client:            client,
 		stream:            stream,
+		deviceID:          stream.DeviceID,
 		logger:            logger,
 		ctx:               ctx,
 		cancel:            cancel,
@@ -36,41 +43,82 @@ func NewStreamManager(client *Client, stream *RTSPStream, logger *slog.Logger) *
 	}
 }
 
+// NewWebRTCStreamManager creates a stream manager for a WebRTC stream.
+// Stopping it also closes the stream's peer.
+func NewWebRTCStreamManager(client *Client, stream *WebRTCStream, logger *slog.Logger) *StreamManager {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &StreamManager{
+		client:            client,
+		webrtc:            stream,
+		deviceID:          stream.DeviceID,
+		logger:            logger,
+		ctx:               ctx,
+		cancel:            cancel,
+		extensionInterval: 60 * time.Second,
+	}
+}
+
 // Start begins the automatic extension loop
 func (m *StreamManager) Start() {
 	m.wg.Add(1)
 	go m.extensionLoop()
 
 	m.logger.Info("stream manager started",
-		"device_id", m.stream.DeviceID,
-		"expires_at", m.stream.ExpiresAt.Format(time.RFC3339))
+		"device_id", m.deviceID,
+		"expires_at", m.GetExpiresAt().Format(time.RFC3339))
 }
 
 // Stop stops the extension loop and waits for cleanup
 func (m *StreamManager) Stop(ctx context.Context) error {
-	m.logger.Info("stopping stream manager", "device_id", m.stream.DeviceID)
+	m.logger.Info("stopping stream manager", "device_id", m.deviceID)
 
 	m.cancel()
 	m.wg.Wait()
 
+	if m.webrtc != nil {
+		// The camera ends the stream either way once the peer goes
+		err := m.client.StopWebRTCStream(ctx, m.webrtc)
+		if m.webrtc.Peer != nil {
+			m.webrtc.Peer.Close()
+		}
+		if err != nil {
+			m.logger.Error("failed to stop WebRTC stream", "error", err)
+			return fmt.Errorf("stop WebRTC stream: %w", err)
+		}
+		m.logger.Info("stream manager stopped", "device_id", m.deviceID)
+		return nil
+	}
+
 	// Stop the RTSP stream
 	if err := m.client.StopRTSPStream(ctx, m.stream); err != nil {
 		m.logger.Error("failed to stop RTSP stream", "error", err)
 		return fmt.Errorf("stop RTSP stream: %w", err)
 	}
 
-	m.logger.Info("stream manager stopped", "device_id", m.stream.DeviceID)
+	m.logger.Info("stream manager stopped", "device_id", m.deviceID)
 	return nil
 }
 
+// Detach stops the extension loop but leaves the stream running, for the
+// next run to resume with ResumeStream
+func (m *StreamManager) Detach() {
+	m.cancel()
+	m.wg.Wait()
+	m.logger.Info("stream manager detached, stream left running",
+		"device_id", m.deviceID,
+		"expires_at", m.GetExpiresAt().Format(time.RFC3339))
+}
+
 // extensionLoop runs the automatic stream extension timer
 func (m *StreamManager) extensionLoop() {
 	defer m.wg.Done()
+	defer goroutines.Track("nest.extensionLoop", m.deviceID)()
 
 	for {
 		// Calculate time until next extension
 		now := time.Now()
-		expiresAt := m.stream.ExpiresAt
+		expiresAt := m.GetExpiresAt()
 		timeUntilExpiry := expiresAt.Sub(now)
 
 		// Extend when we're within the extension interval of expiry
@@ -82,7 +130,7 @@ func (m *StreamManager) extensionLoop() {
 		}
 
 		m.logger.Debug("scheduling next extension",
-			"device_id", m.stream.DeviceID,
+			"device_id", m.deviceID,
 			"time_until_extension", timeUntilExtension.String(),
 			"current_expiry", expiresAt.Format(time.RFC3339))
 
@@ -94,7 +142,7 @@ func (m *StreamManager) extensionLoop() {
 			// Time to extend the stream
 			if err := m.extendWithRetry(); err != nil {
 				m.logger.Error("failed to extend stream after retries",
-					"device_id", m.stream.DeviceID,
+					"device_id", m.deviceID,
 					"error", err)
 				// Continue trying - don't exit the loop
 			}
@@ -102,7 +150,8 @@ func (m *StreamManager) extensionLoop() {
 	}
 }
 
-// extendWithRetry attempts to extend the stream with exponential backoff
+// extendWithRetry attempts to extend the stream with exponential backoff,
+// waiting longer when a quota error asks to
 func (m *StreamManager) extendWithRetry() error {
 	const maxRetries = 3
 	backoff := 1 * time.Second
@@ -111,29 +160,34 @@ func (m *StreamManager) extendWithRetry() error {
 		// Create context with timeout for this extension attempt
 		ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
 
-		err := m.client.ExtendRTSPStream(ctx, m.stream)
+		err := m.Extend(ctx)
 		cancel()
 
 		if err == nil {
 			m.logger.Info("stream extended successfully",
-				"device_id", m.stream.DeviceID,
-				"new_expiry", m.stream.ExpiresAt.Format(time.RFC3339),
+				"device_id", m.deviceID,
+				"new_expiry", m.GetExpiresAt().Format(time.RFC3339),
 				"attempt", attempt+1)
 			return nil
 		}
 
 		m.logger.Warn("stream extension attempt failed",
-			"device_id", m.stream.DeviceID,
+			"device_id", m.deviceID,
 			"attempt", attempt+1,
 			"max_retries", maxRetries,
 			"error", err)
 
 		// If this isn't the last attempt, wait before retrying
 		if attempt < maxRetries-1 {
+			wait := backoff
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+				wait = apiErr.RetryAfter
+			}
 			select {
 			case <-m.ctx.Done():
 				return m.ctx.Err()
-			case <-time.After(backoff):
+			case <-time.After(wait):
 				backoff *= 2 // Exponential backoff
 			}
 		}
@@ -142,17 +196,33 @@ func (m *StreamManager) extendWithRetry() error {
 	return fmt.Errorf("max retries exceeded for stream extension")
 }
 
-// GetStream returns the current stream
+// Extend extends the stream once, whichever protocol it uses
+func (m *StreamManager) Extend(ctx context.Context) error {
+	if m.webrtc != nil {
+		return m.client.ExtendWebRTCStream(ctx, m.webrtc)
+	}
+	return m.client.ExtendRTSPStream(ctx, m.stream)
+}
+
+// GetStream returns the current stream; nil for a WebRTC stream
 func (m *StreamManager) GetStream() *RTSPStream {
 	return m.stream
 }
 
+// GetWebRTCStream returns the current WebRTC stream; nil for an RTSP stream
+func (m *StreamManager) GetWebRTCStream() *WebRTCStream {
+	return m.webrtc
+}
+
 // GetExpiresAt returns when the stream will expire
 func (m *StreamManager) GetExpiresAt() time.Time {
+	if m.webrtc != nil {
+		return m.webrtc.ExpiresAt
+	}
 	return m.stream.ExpiresAt
 }
 
 // GetTimeUntilExpiry returns how long until the stream expires
 func (m *StreamManager) GetTimeUntilExpiry() time.Duration {
-	return time.Until(m.stream.ExpiresAt)
+	return time.Until(m.GetExpiresAt())
 }
@@ -6,7 +6,11 @@ import (
 	"fmt"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/faults"
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
 )
 
 // CameraState represents the lifecycle state of a camera stream
@@ -18,6 +22,7 @@ const (
 	StateFailed                       // Stream failed, attempting recovery
 	StateDegraded                     // Too many failures, reduced retry frequency
 	StateStopped                      // Intentionally stopped
+	StateOffline                      // Device offline; waiting for it to reconnect
 )
 
 // String returns human-readable state
@@ -33,6 +38,8 @@ func (s CameraState) String() string {
 		return "degraded"
 	case StateStopped:
 		return "stopped"
+	case StateOffline:
+		return "offline"
 	default:
 		return "unknown"
 	}
@@ -51,46 +58,86 @@ type CameraStream struct {
 	LastExtension  time.Time
 	StreamExpiry   time.Time
 	RecoveryBackoff time.Duration
+
+	ctx    context.Context    // Scopes the camera's queued commands and loops
+	cancel context.CancelFunc // Called by StopCamera
 }
 
-// MultiStreamManager orchestrates multiple camera streams with rate-limited coordination
+// project is one Device Access project cameras are streamed from. The SDM
+// quota is per project, so each has its own rate-limited command queue.
+type project struct {
+	id     string
+	client *Client
+	queue  *CommandQueue
+}
+
+// MultiStreamManager orchestrates multiple camera streams with rate-limited
+// coordination, across one or more Device Access projects
 type MultiStreamManager struct {
-	client       *Client
-	projectID    string
-	queue        *CommandQueue
-	logger       *slog.Logger
+	projects []*project // The first is the default for unassigned cameras
+	qpm      float64    // Each project's command rate limit
+	logger   *slog.Logger
+	faults   *faults.Injector
+
+	mu       sync.RWMutex
+	streams  map[string]*CameraStream // Key: cameraID
+	assigned map[string]*project      // Cameras of projects other than the first
+	offline  map[string]bool          // Cameras known offline before they were started
+	webrtc   map[string]bool          // Cameras streamed over WebRTC rather than RTSP
+	resume   map[string]*RTSPStream   // Streams a previous run left up, adopted on start
 
-	mu      sync.RWMutex
-	streams map[string]*CameraStream // Key: cameraID
+	keepStreams bool // Stop leaves RTSP streams up for the next run to resume
+
+	newPeer func(cameraID string) (WebRTCPeer, error) // Receives WebRTC cameras' streams
 
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 
 	// Configuration
+	stagger           StaggerStrategy
 	staggerInterval   time.Duration // Delay between camera startups
 	maxFailures       int           // Failures before degraded state
 	degradedRetry     time.Duration // Retry interval for degraded cameras
 	recoveryBaseDelay time.Duration // Base delay for exponential backoff
+	offlineCheck      time.Duration // Connectivity poll interval for offline cameras
 }
 
+// StaggerStrategy decides when StartCameras starts the next camera
+type StaggerStrategy string
+
+const (
+	// StaggerAdaptive starts the next camera as soon as the previous one's
+	// stream request has completed and the command queue has headroom
+	StaggerAdaptive StaggerStrategy = "adaptive"
+	// StaggerFixed waits StaggerInterval between cameras
+	StaggerFixed StaggerStrategy = "fixed"
+)
+
+// adaptivePollInterval is how often an adaptive stagger rechecks the queue
+const adaptivePollInterval = 250 * time.Millisecond
+
 // MultiStreamConfig configures the multi-stream manager
 type MultiStreamConfig struct {
-	QPM               float64       // Queries per minute limit (default: 10)
-	StaggerInterval   time.Duration // Delay between camera startups (default: 12s)
+	QPM               float64         // Queries per minute limit (default: 10)
+	Stagger           StaggerStrategy // Startup pacing (default: adaptive)
+	StaggerInterval   time.Duration   // Delay between camera startups with StaggerFixed (default: 12s)
 	MaxFailures       int           // Failures before degraded (default: 5)
 	DegradedRetry     time.Duration // Retry interval when degraded (default: 5min)
 	RecoveryBaseDelay time.Duration // Base delay for backoff (default: 10s)
+	OfflineCheckInterval time.Duration // Connectivity poll for offline cameras (default: 1min)
 }
 
 // DefaultMultiStreamConfig returns sensible defaults for 20 cameras at 10 QPM
 func DefaultMultiStreamConfig() MultiStreamConfig {
 	return MultiStreamConfig{
 		QPM:               10.0,               // Google's limit
+		Stagger:           StaggerAdaptive,    // Paced by the limiter, not a fixed delay
 		StaggerInterval:   12 * time.Second,   // 20 cameras * 12s = 4 minutes
 		MaxFailures:       5,                  // Degrade after 5 consecutive failures
 		DegradedRetry:     5 * time.Minute,    // Check degraded cameras every 5 minutes
 		RecoveryBaseDelay: 10 * time.Second,   // Start backoff at 10s
+		OfflineCheckInterval: time.Minute,     // Device reads don't count against command QPM
 	}
 }
 
@@ -98,76 +145,211 @@ func DefaultMultiStreamConfig() MultiStreamConfig {
 func NewMultiStreamManager(client *Client, projectID string, config MultiStreamConfig, logger *slog.Logger) *MultiStreamManager {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	queue := NewCommandQueue(config.QPM, logger.With("component", "queue"))
+	queue := NewCommandQueue(config.QPM, logger.With("component", "queue", "project_id", projectID))
+
+	if config.OfflineCheckInterval <= 0 {
+		config.OfflineCheckInterval = time.Minute
+	}
 
 	msm := &MultiStreamManager{
-		client:            client,
-		projectID:         projectID,
-		queue:             queue,
+		projects:          []*project{{id: projectID, client: client, queue: queue}},
+		qpm:               config.QPM,
 		logger:            logger,
 		streams:           make(map[string]*CameraStream),
+		assigned:          make(map[string]*project),
+		offline:           make(map[string]bool),
+		webrtc:            make(map[string]bool),
+		resume:            make(map[string]*RTSPStream),
 		ctx:               ctx,
 		cancel:            cancel,
+		stagger:           config.Stagger,
 		staggerInterval:   config.StaggerInterval,
 		maxFailures:       config.MaxFailures,
 		degradedRetry:     config.DegradedRetry,
 		recoveryBaseDelay: config.RecoveryBaseDelay,
+		offlineCheck:      config.OfflineCheckInterval,
 	}
 
 	logger.Info("multi-stream manager created",
 		"project_id", projectID,
 		"qpm", config.QPM,
+		"stagger", config.Stagger,
 		"stagger_interval", config.StaggerInterval,
 		"max_failures", config.MaxFailures)
 
 	return msm
 }
 
+// AddProject adds a further Device Access project, with its own OAuth
+// client and command queue at the same QPM limit. Call before Start.
+func (msm *MultiStreamManager) AddProject(client *Client, projectID string) {
+	msm.mu.Lock()
+	defer msm.mu.Unlock()
+	queue := NewCommandQueue(msm.qpm, msm.logger.With("component", "queue", "project_id", projectID))
+	msm.projects = append(msm.projects, &project{id: projectID, client: client, queue: queue})
+	msm.logger.Info("added project", "project_id", projectID)
+}
+
+// AssignCamera makes a camera's commands go to projectID, one added with
+// AddProject. Cameras not assigned belong to the first project.
+func (msm *MultiStreamManager) AssignCamera(cameraID, projectID string) error {
+	msm.mu.Lock()
+	defer msm.mu.Unlock()
+	for _, p := range msm.projects {
+		if p.id == projectID {
+			msm.assigned[cameraID] = p
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown project %s", projectID)
+}
+
+// NewDeviceRegistry creates a registry of projectID's devices that
+// refreshes through the project's command queue
+func (msm *MultiStreamManager) NewDeviceRegistry(projectID string, interval time.Duration) (*DeviceRegistry, error) {
+	msm.mu.RLock()
+	defer msm.mu.RUnlock()
+	for _, p := range msm.projects {
+		if p.id == projectID {
+			return NewDeviceRegistry(p.client, p.id, p.queue, interval,
+				msm.logger.With("component", "devices", "project_id", p.id)), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown project %s", projectID)
+}
+
+// project returns the project a camera belongs to
+func (msm *MultiStreamManager) project(cameraID string) *project {
+	msm.mu.RLock()
+	defer msm.mu.RUnlock()
+	return msm.projectLocked(cameraID)
+}
+
+// projectLocked is project for callers holding msm.mu
+func (msm *MultiStreamManager) projectLocked(cameraID string) *project {
+	if p, ok := msm.assigned[cameraID]; ok {
+		return p
+	}
+	return msm.projects[0]
+}
+
+// SetFaultInjector enables chaos-mode extension failures (nil disables)
+func (msm *MultiStreamManager) SetFaultInjector(inj *faults.Injector) {
+	msm.faults = inj
+}
+
+// SetWebRTCPeerFactory sets how WebRTC cameras' streams are received:
+// newPeer is called for each stream generated, and its peer closed when
+// the stream stops
+func (msm *MultiStreamManager) SetWebRTCPeerFactory(newPeer func(cameraID string) (WebRTCPeer, error)) {
+	msm.mu.Lock()
+	defer msm.mu.Unlock()
+	msm.newPeer = newPeer
+}
+
+// UseWebRTC makes a camera's future streams WebRTC streams, for devices
+// without RTSP. It needs a peer factory set with SetWebRTCPeerFactory.
+func (msm *MultiStreamManager) UseWebRTC(cameraID string) {
+	msm.mu.Lock()
+	defer msm.mu.Unlock()
+	msm.webrtc[cameraID] = true
+}
+
+// ResumeStream offers a camera an RTSP stream a previous run left up. When
+// the camera starts, it adopts the stream in place of generating one, if
+// the stream is still far enough from expiry to be extended; a stream that
+// turns out dead is replaced like any other.
+func (msm *MultiStreamManager) ResumeStream(cameraID string, stream *RTSPStream) {
+	msm.mu.Lock()
+	defer msm.mu.Unlock()
+	msm.resume[cameraID] = stream
+}
+
+// KeepStreamsOnStop makes Stop leave RTSP streams running instead of
+// stopping them, so a quick restart can resume them. WebRTC streams end
+// with their peer and are always stopped.
+func (msm *MultiStreamManager) KeepStreamsOnStop(keep bool) {
+	msm.mu.Lock()
+	defer msm.mu.Unlock()
+	msm.keepStreams = keep
+}
+
 // Start begins the multi-stream manager and command queue
 func (msm *MultiStreamManager) Start() error {
-	msm.queue.Start()
-	msm.logger.Info("multi-stream manager started")
+	for _, p := range msm.projects {
+		p.queue.Start()
+	}
+	msm.logger.Info("multi-stream manager started", "projects", len(msm.projects))
 	return nil
 }
 
-// Stop gracefully stops all streams and the command queue
+// Stop gracefully stops all streams and the command queue. Streams are
+// stopped through the rate-limited queue; those still waiting when the
+// shutdown budget runs out are left to expire on their own. With
+// KeepStreamsOnStop, RTSP streams are left running without a command.
 func (msm *MultiStreamManager) Stop() error {
 	msm.logger.Info("stopping multi-stream manager")
 
 	msm.cancel()
+	for _, p := range msm.projects {
+		p.queue.Discard(CmdExtend)
+		p.queue.Discard(CmdGenerate)
+		p.queue.Discard(CmdImage)
+		p.queue.Discard(CmdList)
+	}
 
 	// Stop all stream managers
 	msm.mu.Lock()
 	var stopWg sync.WaitGroup
+	var stopped atomic.Int32
 	stopCtx, stopCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer stopCancel()
 
 	for cameraID, stream := range msm.streams {
-		if stream.Manager != nil {
+		if stream.Manager != nil && msm.keepStreams && stream.Manager.GetStream() != nil {
+			stream.Manager.Detach()
+		} else if stream.Manager != nil {
 			stopWg.Add(1)
-			go func(id string, mgr *StreamManager) {
+			go func(id string, mgr *StreamManager, queue *CommandQueue) {
 				defer stopWg.Done()
-				if err := mgr.Stop(stopCtx); err != nil {
+				defer goroutines.Track("nest.stop", id)()
+				err := queue.SubmitStop(stopCtx, id, func(ctx context.Context) error {
+					return mgr.Stop(ctx)
+				})
+				if err != nil {
 					msm.logger.Error("failed to stop stream manager", "camera_id", id, "error", err)
+					return
 				}
-			}(cameraID, stream.Manager)
+				stopped.Add(1)
+			}(cameraID, stream.Manager, msm.projectLocked(cameraID).queue)
 		}
 		stream.State = StateStopped
 	}
 	msm.mu.Unlock()
 
-	// Wait for all streams to stop
-	stopWg.Wait()
+	// Wait for the queued stops, up to the shutdown budget
+	allStopped := make(chan struct{})
+	go func() {
+		stopWg.Wait()
+		close(allStopped)
+	}()
+	select {
+	case <-allStopped:
+	case <-stopCtx.Done():
+	}
 
 	// Wait for any ongoing operations
 	msm.wg.Wait()
 
-	// Stop the command queue
-	if err := msm.queue.Stop(); err != nil {
-		msm.logger.Error("failed to stop command queue", "error", err)
+	// Stop the command queues, abandoning stops that did not run in time
+	for _, p := range msm.projects {
+		if err := p.queue.Stop(); err != nil {
+			msm.logger.Error("failed to stop command queue", "project_id", p.id, "error", err)
+		}
 	}
+	stopWg.Wait()
 
-	msm.logger.Info("multi-stream manager stopped")
+	msm.logger.Info("multi-stream manager stopped", "streams_stopped", stopped.Load())
 	return nil
 }
 
@@ -175,6 +357,7 @@ func (msm *MultiStreamManager) Stop() error {
 func (msm *MultiStreamManager) StartCameras(ctx context.Context, cameraIDs []string) error {
 	msm.logger.Info("starting cameras with staggered initialization",
 		"count", len(cameraIDs),
+		"stagger", msm.stagger,
 		"stagger_interval", msm.staggerInterval)
 
 	for i, cameraID := range cameraIDs {
@@ -185,32 +368,18 @@ func (msm *MultiStreamManager) StartCameras(ctx context.Context, cameraIDs []str
 		default:
 		}
 
-		// Initialize camera stream tracking
-		msm.mu.Lock()
-		msm.streams[cameraID] = &CameraStream{
-			CameraID:  cameraID,
-			DeviceID:  extractCameraDeviceID(cameraID),
-			State:     StateStarting,
-			CreatedAt: time.Now(),
-		}
-		msm.mu.Unlock()
-
-		// Start stream asynchronously
-		msm.wg.Add(1)
-		go msm.startCameraStream(cameraID)
+		msm.trackCamera(cameraID)
 
 		// Stagger startup (except for last camera)
 		if i < len(cameraIDs)-1 {
-			msm.logger.Debug("waiting before next camera startup",
+			start := time.Now()
+			if err := msm.waitForNextStart(ctx, cameraID); err != nil {
+				return err
+			}
+			msm.logger.Debug("starting next camera",
 				"current", i+1,
 				"total", len(cameraIDs),
-				"wait", msm.staggerInterval)
-
-			select {
-			case <-time.After(msm.staggerInterval):
-			case <-ctx.Done():
-				return ctx.Err()
-			}
+				"waited", time.Since(start).Round(time.Millisecond))
 		}
 	}
 
@@ -218,18 +387,174 @@ func (msm *MultiStreamManager) StartCameras(ctx context.Context, cameraIDs []str
 	return nil
 }
 
+// waitForNextStart blocks until the camera after prev may be started. With
+// the fixed strategy that is StaggerInterval; with the adaptive strategy it
+// is once prev's stream request has completed and the queue could run
+// another command immediately, so startups never pile up behind extensions.
+func (msm *MultiStreamManager) waitForNextStart(ctx context.Context, prev string) error {
+	if msm.stagger == StaggerFixed {
+		select {
+		case <-time.After(msm.staggerInterval):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	ticker := time.NewTicker(adaptivePollInterval)
+	defer ticker.Stop()
+
+	for {
+		if !msm.isStarting(prev) && msm.project(prev).queue.HasHeadroom() {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// isStarting reports whether a camera's initial stream request is still pending
+func (msm *MultiStreamManager) isStarting(cameraID string) bool {
+	msm.mu.RLock()
+	defer msm.mu.RUnlock()
+	stream, exists := msm.streams[cameraID]
+	return exists && stream.State == StateStarting
+}
+
+// StartCamera begins streaming a single camera without staggering. It returns
+// false if the camera is already tracked.
+func (msm *MultiStreamManager) StartCamera(cameraID string) bool {
+	msm.mu.RLock()
+	_, exists := msm.streams[cameraID]
+	msm.mu.RUnlock()
+	if exists {
+		return false
+	}
+
+	msm.trackCamera(cameraID)
+	return true
+}
+
+// MarkOffline records that a camera's Connectivity trait reports it offline.
+// A camera marked before it starts waits for the device to come back instead
+// of spending a generate attempt on it.
+func (msm *MultiStreamManager) MarkOffline(cameraID string) {
+	msm.mu.Lock()
+	msm.offline[cameraID] = true
+	msm.mu.Unlock()
+}
+
+// StopCamera stops a camera's stream and removes it from the manager. Its
+// monitor and recovery loops exit on their next check, and the relay tears
+// down the camera's pipeline on its next reconcile.
+func (msm *MultiStreamManager) StopCamera(ctx context.Context, cameraID string) error {
+	msm.mu.Lock()
+	stream, exists := msm.streams[cameraID]
+	delete(msm.streams, cameraID)
+	msm.mu.Unlock()
+
+	if !exists {
+		return nil
+	}
+	stream.cancel() // Withdraws the camera's queued commands
+
+	msm.logger.Info("stopping camera stream", "camera_id", cameraID)
+	if stream.Manager != nil {
+		return stream.Manager.Stop(ctx)
+	}
+	return nil
+}
+
+// RegenerateStream replaces a running camera's RTSP stream with a fresh one
+// and stops the old stream once the new one is live. Nest ignores upstream
+// RTCP, so a new stream is the only way to force an IDR out of a camera.
+// The call blocks until the queued command has run.
+func (msm *MultiStreamManager) RegenerateStream(cameraID string) error {
+	msm.mu.RLock()
+	stream, exists := msm.streams[cameraID]
+	var old *StreamManager
+	var ctx context.Context
+	running := exists && stream.State == StateRunning
+	if running {
+		old = stream.Manager
+		ctx = stream.ctx
+	}
+	msm.mu.RUnlock()
+
+	if !running {
+		return fmt.Errorf("camera %s is not running", cameraID)
+	}
+
+	msm.logger.Info("regenerating camera stream", "camera_id", cameraID)
+	return msm.project(cameraID).queue.SubmitGenerate(ctx, cameraID, 0, func(ctx context.Context) error {
+		if err := msm.generateStream(ctx, cameraID); err != nil {
+			return err
+		}
+		if old != nil {
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				old.Stop(ctx)
+			}()
+		}
+		return nil
+	})
+}
+
+// trackCamera registers a camera and starts its stream asynchronously
+func (msm *MultiStreamManager) trackCamera(cameraID string) {
+	ctx, cancel := context.WithCancel(msm.ctx)
+
+	msm.mu.Lock()
+	msm.streams[cameraID] = &CameraStream{
+		CameraID:  cameraID,
+		DeviceID:  extractCameraDeviceID(cameraID),
+		State:     StateStarting,
+		CreatedAt: time.Now(),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	msm.mu.Unlock()
+
+	msm.wg.Add(1)
+	go msm.startCameraStream(ctx, cameraID)
+}
+
 // startCameraStream initializes and manages a single camera stream lifecycle
-func (msm *MultiStreamManager) startCameraStream(cameraID string) {
+func (msm *MultiStreamManager) startCameraStream(ctx context.Context, cameraID string) {
 	defer msm.wg.Done()
+	defer goroutines.Track("nest.startStream", cameraID)()
 
 	logger := msm.logger.With("camera_id", cameraID)
-	logger.Info("starting camera stream")
 
-	// Generate initial stream via command queue (LOW priority)
-	err := msm.queue.SubmitGenerate(cameraID, 0, func() error {
-		return msm.generateStream(cameraID)
-	})
+	msm.mu.Lock()
+	knownOffline := msm.offline[cameraID]
+	delete(msm.offline, cameraID)
+	msm.mu.Unlock()
+	if knownOffline {
+		msm.goOffline(ctx, cameraID, ErrDeviceOffline)
+		return
+	}
+
+	var err error
+	if msm.resumeStream(cameraID) {
+		logger.Info("resumed camera stream from previous run")
+	} else {
+		logger.Info("starting camera stream")
+
+		// Generate initial stream via command queue (LOW priority)
+		err = msm.project(cameraID).queue.SubmitGenerate(ctx, cameraID, 0, func(ctx context.Context) error {
+			return msm.generateStream(ctx, cameraID)
+		})
+	}
 
+	if errors.Is(err, ErrDeviceOffline) {
+		msm.goOffline(ctx, cameraID, err)
+		return
+	}
 	if err != nil {
 		msm.updateStreamState(cameraID, func(cs *CameraStream) {
 			cs.State = StateFailed
@@ -241,7 +566,7 @@ func (msm *MultiStreamManager) startCameraStream(cameraID string) {
 
 		// Start recovery loop
 		msm.wg.Add(1)
-		go msm.recoveryLoop(cameraID)
+		go msm.recoveryLoop(ctx, cameraID, false)
 		return
 	}
 
@@ -257,46 +582,141 @@ func (msm *MultiStreamManager) startCameraStream(cameraID string) {
 
 	// Monitor stream health
 	msm.wg.Add(1)
-	go msm.monitorStream(cameraID)
+	go msm.monitorStream(ctx, cameraID)
 }
 
-// generateStream creates a new RTSP stream for a camera
-func (msm *MultiStreamManager) generateStream(cameraID string) error {
-	ctx, cancel := context.WithTimeout(msm.ctx, 30*time.Second)
-	defer cancel()
-
+// generateStream creates a new RTSP stream for a camera. It runs as a
+// queued command, so ctx carries the command's deadline.
+func (msm *MultiStreamManager) generateStream(ctx context.Context, cameraID string) error {
 	deviceID := extractCameraDeviceID(cameraID)
-	stream, err := msm.client.GenerateRTSPStream(ctx, msm.projectID, deviceID)
-	if err != nil {
-		return fmt.Errorf("generate RTSP stream: %w", err)
-	}
+	logger := msm.logger.With("camera_id", cameraID, "component", "stream_manager")
+	p := msm.project(cameraID)
+
+	msm.mu.RLock()
+	useWebRTC, newPeer := msm.webrtc[cameraID], msm.newPeer
+	msm.mu.RUnlock()
 
 	// Create stream manager
-	manager := NewStreamManager(msm.client, stream,
-		msm.logger.With("camera_id", cameraID, "component", "stream_manager"))
+	var manager *StreamManager
+	if useWebRTC {
+		stream, err := msm.generateWebRTCStream(ctx, p, cameraID, deviceID, newPeer)
+		if err != nil {
+			return err
+		}
+		manager = NewWebRTCStreamManager(p.client, stream, logger)
+	} else {
+		stream, err := p.client.GenerateRTSPStream(ctx, p.id, deviceID)
+		if err != nil {
+			return fmt.Errorf("generate RTSP stream: %w", err)
+		}
+		manager = NewStreamManager(p.client, stream, logger)
+	}
 
+	tracked := false
 	msm.updateStreamState(cameraID, func(cs *CameraStream) {
+		tracked = true
 		cs.Manager = manager
-		cs.StreamExpiry = stream.ExpiresAt
+		cs.StreamExpiry = manager.GetExpiresAt()
 	})
 
+	// StopCamera ran while the command was queued; don't leak the stream
+	if !tracked {
+		go func() {
+			stopCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			manager.Stop(stopCtx)
+		}()
+		return errors.New("camera stopped during stream generation")
+	}
+
 	// Start manager (will handle extensions via queue integration)
 	manager.Start()
 
 	return nil
 }
 
+// resumeStream adopts the camera's stream from ResumeStream, if it has one
+// that won't expire before the monitor's first chance to extend it. The
+// stream is offered once.
+func (msm *MultiStreamManager) resumeStream(cameraID string) bool {
+	msm.mu.Lock()
+	stream := msm.resume[cameraID]
+	delete(msm.resume, cameraID)
+	useWebRTC := msm.webrtc[cameraID]
+	msm.mu.Unlock()
+
+	if stream == nil || useWebRTC || time.Until(stream.ExpiresAt) < 2*extendCheckInterval {
+		return false
+	}
+	p := msm.project(cameraID)
+	if stream.ProjectID == "" {
+		stream.ProjectID = p.id
+	}
+	if stream.DeviceID == "" {
+		stream.DeviceID = extractCameraDeviceID(cameraID)
+	}
+
+	manager := NewStreamManager(p.client, stream, msm.logger.With("camera_id", cameraID, "component", "stream_manager"))
+	tracked := false
+	msm.updateStreamState(cameraID, func(cs *CameraStream) {
+		tracked = true
+		cs.Manager = manager
+		cs.StreamExpiry = manager.GetExpiresAt()
+	})
+	if !tracked {
+		return false
+	}
+	manager.Start()
+	return true
+}
+
+// generateWebRTCStream offers a new peer's SDP to the camera and applies
+// its answer
+func (msm *MultiStreamManager) generateWebRTCStream(ctx context.Context, p *project, cameraID, deviceID string, newPeer func(string) (WebRTCPeer, error)) (*WebRTCStream, error) {
+	if newPeer == nil {
+		return nil, errors.New("camera streams only over WebRTC and no WebRTC receiver is set")
+	}
+	peer, err := newPeer(cameraID)
+	if err != nil {
+		return nil, fmt.Errorf("create WebRTC peer: %w", err)
+	}
+	offer, err := peer.Offer()
+	if err != nil {
+		peer.Close()
+		return nil, fmt.Errorf("create WebRTC offer: %w", err)
+	}
+
+	stream, err := p.client.GenerateWebRTCStream(ctx, p.id, deviceID, offer)
+	if err != nil {
+		peer.Close()
+		return nil, fmt.Errorf("generate WebRTC stream: %w", err)
+	}
+	stream.Peer = peer
+	if err := peer.Accept(stream.AnswerSDP); err != nil {
+		// Stop the stream the camera started, in the background: ctx is
+		// the queued command's and nearly spent
+		go func() {
+			stopCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			NewWebRTCStreamManager(p.client, stream, msm.logger).Stop(stopCtx)
+		}()
+		return nil, fmt.Errorf("accept WebRTC answer: %w", err)
+	}
+	return stream, nil
+}
+
 // monitorStream watches for stream extension needs and failures
-func (msm *MultiStreamManager) monitorStream(cameraID string) {
+func (msm *MultiStreamManager) monitorStream(ctx context.Context, cameraID string) {
 	defer msm.wg.Done()
+	defer goroutines.Track("nest.monitorStream", cameraID)()
 
 	logger := msm.logger.With("camera_id", cameraID)
-	ticker := time.NewTicker(30 * time.Second) // Check every 30s
+	ticker := time.NewTicker(extendCheckInterval) // Check every 30s
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-msm.ctx.Done():
+		case <-ctx.Done():
 			return
 
 		case <-ticker.C:
@@ -311,17 +731,20 @@ func (msm *MultiStreamManager) monitorStream(cameraID string) {
 
 			// Check if stream needs extension
 			timeUntilExpiry := stream.Manager.GetTimeUntilExpiry()
-			if timeUntilExpiry < 90*time.Second {
+			if timeUntilExpiry < extendThreshold {
 				// Time to extend via queue (HIGH priority)
 				logger.Debug("submitting extension command", "time_until_expiry", timeUntilExpiry)
 
-				err := msm.queue.SubmitExtend(cameraID, func() error {
-					return msm.extendStream(cameraID)
+				err := msm.project(cameraID).queue.SubmitExtend(ctx, cameraID, func(ctx context.Context) error {
+					return msm.extendStream(ctx, cameraID)
 				})
 
 				if err != nil {
 					logger.Error("extension command failed", "error", err)
-					msm.handleExtensionFailure(cameraID, err)
+					if ctx.Err() != nil {
+						return
+					}
+					msm.handleExtensionFailure(ctx, cameraID, err)
 				} else {
 					msm.updateStreamState(cameraID, func(cs *CameraStream) {
 						cs.LastExtension = time.Now()
@@ -334,24 +757,42 @@ func (msm *MultiStreamManager) monitorStream(cameraID string) {
 	}
 }
 
-// extendStream extends an existing RTSP stream
-func (msm *MultiStreamManager) extendStream(cameraID string) error {
-	ctx, cancel := context.WithTimeout(msm.ctx, 30*time.Second)
-	defer cancel()
-
+// extendStream extends an existing stream. It runs as a queued
+// command, so ctx carries the command's deadline.
+func (msm *MultiStreamManager) extendStream(ctx context.Context, cameraID string) error {
 	msm.mu.RLock()
 	stream, exists := msm.streams[cameraID]
 	msm.mu.RUnlock()
 
 	if !exists || stream.Manager == nil {
-		return errors.New("stream manager not found")
+		return fmt.Errorf("stream manager not found: %w", ErrStreamExpired)
 	}
 
-	return msm.client.ExtendRTSPStream(ctx, stream.Manager.GetStream())
+	if err := msm.faults.ExtendFailure(cameraID); err != nil {
+		return err
+	}
+
+	return stream.Manager.Extend(ctx)
 }
 
 // handleExtensionFailure processes extension failures and triggers recovery
-func (msm *MultiStreamManager) handleExtensionFailure(cameraID string, err error) {
+func (msm *MultiStreamManager) handleExtensionFailure(ctx context.Context, cameraID string, err error) {
+	if errors.Is(err, ErrDeviceOffline) {
+		msm.goOffline(ctx, cameraID, err)
+		return
+	}
+	if errors.Is(err, ErrQuotaExceeded) {
+		// The stream is fine; the queue holds commands until quota is back
+		// and the next check extends it again
+		msm.updateStreamState(cameraID, func(cs *CameraStream) {
+			cs.LastError = err
+			cs.LastAttempt = time.Now()
+		})
+		msm.logger.Warn("stream extension refused for quota, retrying",
+			"camera_id", cameraID)
+		return
+	}
+
 	msm.updateStreamState(cameraID, func(cs *CameraStream) {
 		cs.FailureCount++
 		cs.LastError = err
@@ -378,12 +819,80 @@ func (msm *MultiStreamManager) handleExtensionFailure(cameraID string, err error
 
 	// Start recovery loop if needed
 	msm.wg.Add(1)
-	go msm.recoveryLoop(cameraID)
+	go msm.recoveryLoop(ctx, cameraID, false)
+}
+
+// goOffline parks a camera until its device reports online again, so no
+// generate attempts are spent on it in the meantime
+func (msm *MultiStreamManager) goOffline(ctx context.Context, cameraID string, err error) {
+	msm.updateStreamState(cameraID, func(cs *CameraStream) {
+		cs.State = StateOffline
+		cs.LastError = err
+		cs.LastAttempt = time.Now()
+	})
+	msm.logger.Warn("camera offline, waiting for it to reconnect",
+		"camera_id", cameraID,
+		"check_interval", msm.offlineCheck,
+		"error", err)
+
+	msm.wg.Add(1)
+	go msm.offlineLoop(ctx, cameraID)
+}
+
+// offlineLoop polls an offline camera's Connectivity trait and schedules an
+// immediate recovery once it is back online. Device reads go straight to the
+// API: they are not executeCommand calls and don't count against the QPM.
+func (msm *MultiStreamManager) offlineLoop(ctx context.Context, cameraID string) {
+	defer msm.wg.Done()
+	defer goroutines.Track("nest.offlineLoop", cameraID)()
+
+	logger := msm.logger.With("camera_id", cameraID)
+	ticker := time.NewTicker(msm.offlineCheck)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		msm.mu.RLock()
+		stream, exists := msm.streams[cameraID]
+		offline := exists && stream.State == StateOffline
+		msm.mu.RUnlock()
+		if !offline {
+			return
+		}
+
+		getCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		p := msm.project(cameraID)
+		device, err := p.client.GetDevice(getCtx, p.id, extractCameraDeviceID(cameraID))
+		cancel()
+		if err != nil {
+			logger.Debug("connectivity check failed", "error", err)
+			continue
+		}
+		if !device.Online() {
+			continue
+		}
+
+		logger.Info("camera back online, scheduling recovery")
+		msm.updateStreamState(cameraID, func(cs *CameraStream) {
+			cs.State = StateFailed
+			cs.FailureCount = 0
+		})
+		msm.wg.Add(1)
+		go msm.recoveryLoop(ctx, cameraID, true)
+		return
+	}
 }
 
-// recoveryLoop attempts to recover failed/degraded streams
-func (msm *MultiStreamManager) recoveryLoop(cameraID string) {
+// recoveryLoop attempts to recover failed/degraded streams. With immediate
+// the first attempt is queued without a backoff delay.
+func (msm *MultiStreamManager) recoveryLoop(ctx context.Context, cameraID string, immediate bool) {
 	defer msm.wg.Done()
+	defer goroutines.Track("nest.recoveryLoop", cameraID)()
 
 	logger := msm.logger.With("camera_id", cameraID)
 
@@ -414,30 +923,35 @@ func (msm *MultiStreamManager) recoveryLoop(cameraID string) {
 			}
 		}
 
+		if immediate {
+			delay = 0
+			immediate = false
+		}
+
 		logger.Info("scheduling recovery attempt",
 			"state", stream.State.String(),
 			"failure_count", stream.FailureCount,
 			"delay", delay)
 
 		select {
-		case <-msm.ctx.Done():
+		case <-ctx.Done():
 			return
 		case <-time.After(delay):
 		}
 
 		// Attempt recovery via queue (LOW priority for regeneration)
 		attempt := stream.FailureCount
-		err := msm.queue.SubmitGenerate(cameraID, attempt, func() error {
+		err := msm.project(cameraID).queue.SubmitGenerate(ctx, cameraID, attempt, func(ctx context.Context) error {
 			// Clean up old manager if exists
 			msm.mu.Lock()
 			if stream.Manager != nil {
-				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-				_ = stream.Manager.Stop(ctx)
+				stopCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+				_ = stream.Manager.Stop(stopCtx)
 				cancel()
 			}
 			msm.mu.Unlock()
 
-			return msm.generateStream(cameraID)
+			return msm.generateStream(ctx, cameraID)
 		})
 
 		if err == nil {
@@ -450,7 +964,15 @@ func (msm *MultiStreamManager) recoveryLoop(cameraID string) {
 
 			// Restart monitoring
 			msm.wg.Add(1)
-			go msm.monitorStream(cameraID)
+			go msm.monitorStream(ctx, cameraID)
+			return
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, ErrDeviceOffline) {
+			msm.goOffline(ctx, cameraID, err)
 			return
 		}
 
@@ -458,10 +980,14 @@ func (msm *MultiStreamManager) recoveryLoop(cameraID string) {
 			"attempt", attempt,
 			"error", err)
 
+		quota := errors.Is(err, ErrQuotaExceeded)
 		msm.updateStreamState(cameraID, func(cs *CameraStream) {
-			cs.FailureCount++
 			cs.LastError = err
 			cs.LastAttempt = time.Now()
+			if quota {
+				return // Not the camera's fault: retry once the queue resumes
+			}
+			cs.FailureCount++
 
 			if cs.FailureCount >= msm.maxFailures {
 				cs.State = StateDegraded
@@ -482,6 +1008,7 @@ func (msm *MultiStreamManager) GetStreamStatus() []StreamStatus {
 		status := StreamStatus{
 			CameraID:       stream.CameraID,
 			DeviceID:       stream.DeviceID,
+			ProjectID:      msm.projectLocked(stream.CameraID).id,
 			State:          stream.State,
 			FailureCount:   stream.FailureCount,
 			LastError:      stream.LastError,
@@ -505,6 +1032,7 @@ func (msm *MultiStreamManager) GetStreamStatus() []StreamStatus {
 type StreamStatus struct {
 	CameraID        string
 	DeviceID        string
+	ProjectID       string
 	State           CameraState
 	FailureCount    int
 	LastError       error
@@ -515,9 +1043,40 @@ type StreamStatus struct {
 	TimeUntilExpiry time.Duration
 }
 
-// GetQueueStats returns command queue statistics
+// GetQueueStats returns command queue statistics, summed across projects:
+// QPM is then the combined limit and AvgWaitTime the slowest queue's
 func (msm *MultiStreamManager) GetQueueStats() QueueStats {
-	return msm.queue.GetStats()
+	var total QueueStats
+	for _, stats := range msm.GetProjectQueueStats() {
+		total.QueueDepth += stats.QueueDepth
+		total.TotalEnqueued += stats.TotalEnqueued
+		total.TotalExecuted += stats.TotalExecuted
+		total.TotalFailed += stats.TotalFailed
+		total.ExtendCount += stats.ExtendCount
+		total.GenerateCount += stats.GenerateCount
+		total.AvgWaitTime = max(total.AvgWaitTime, stats.AvgWaitTime)
+		total.QPM += stats.QPM
+		total.EffectiveQPM += stats.EffectiveQPM
+		total.QuotaErrors += stats.QuotaErrors
+		if stats.PausedUntil.After(total.PausedUntil) {
+			total.PausedUntil = stats.PausedUntil
+		}
+	}
+	return total
+}
+
+// GetProjectQueueStats returns each project's command queue statistics,
+// by project ID
+func (msm *MultiStreamManager) GetProjectQueueStats() map[string]QueueStats {
+	msm.mu.RLock()
+	projects := msm.projects
+	msm.mu.RUnlock()
+
+	stats := make(map[string]QueueStats, len(projects))
+	for _, p := range projects {
+		stats[p.id] = p.queue.GetStats()
+	}
+	return stats
 }
 
 // GetStream returns the RTSP stream for a specific camera
@@ -531,6 +1090,35 @@ func (msm *MultiStreamManager) GetStream(cameraID string) *RTSPStream {
 	return nil
 }
 
+// GetWebRTCStream returns the WebRTC stream for a specific camera; nil
+// unless the camera streams over WebRTC and has a stream
+func (msm *MultiStreamManager) GetWebRTCStream(cameraID string) *WebRTCStream {
+	msm.mu.RLock()
+	defer msm.mu.RUnlock()
+
+	if stream, exists := msm.streams[cameraID]; exists && stream.Manager != nil {
+		return stream.Manager.GetWebRTCStream()
+	}
+	return nil
+}
+
+// EventImage generates and downloads the image of a camera event through
+// the command queue, at a lower priority than any stream command. Width is
+// as for Client.FetchImage.
+func (msm *MultiStreamManager) EventImage(ctx context.Context, cameraID, eventID string, width int) ([]byte, error) {
+	p := msm.project(cameraID)
+	var img *EventImage
+	err := p.queue.SubmitImage(ctx, cameraID, func(ctx context.Context) error {
+		var err error
+		img, err = p.client.GenerateImage(ctx, p.id, extractCameraDeviceID(cameraID), eventID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p.client.FetchImage(ctx, img, width)
+}
+
 // updateStreamState safely updates stream state with a mutation function
 func (msm *MultiStreamManager) updateStreamState(cameraID string, fn func(*CameraStream)) {
 	msm.mu.Lock()
@@ -552,13 +1140,10 @@ func extractCameraDeviceID(cameraID string) string {
 	return cameraID
 }
 
-// isStreamExpiredError checks if error indicates stream expiration (404)
+// isStreamExpiredError checks if error indicates stream expiration, so the
+// stream must be regenerated rather than extended again
 func isStreamExpiredError(err error) bool {
-	if err == nil {
-		return false
-	}
-	errStr := err.Error()
-	return contains(errStr, "404") || contains(errStr, "not found") || contains(errStr, "expired")
+	return errors.Is(err, ErrStreamExpired)
 }
 
 // contains checks if a string contains a substring (case-insensitive helper)
@@ -1,7 +1,14 @@
 package nest
 
 import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestExtractCameraDeviceID(t *testing.T) {
@@ -41,3 +48,107 @@ func TestExtractCameraDeviceID(t *testing.T) {
 		})
 	}
 }
+
+func TestResumeStream(t *testing.T) {
+	var mu sync.Mutex
+	var commands []string
+	c := newTestClient(func(req *http.Request) (int, string) {
+		var cmd struct {
+			Command string `json:"command"`
+		}
+		json.NewDecoder(req.Body).Decode(&cmd)
+		mu.Lock()
+		commands = append(commands, cmd.Command)
+		mu.Unlock()
+		return 500, `{}`
+	})
+	msm := NewMultiStreamManager(c, "proj", DefaultMultiStreamConfig(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	msm.KeepStreamsOnStop(true)
+	msm.Start()
+
+	resumed := &RTSPStream{URL: "rtsps://cam1", ExtensionToken: "ext", ExpiresAt: time.Now().Add(4 * time.Minute)}
+	msm.ResumeStream("cam1", resumed)
+	msm.StartCamera("cam1")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for msm.GetStream("cam1") == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := msm.GetStream("cam1"); got != resumed {
+		t.Fatalf("stream = %+v, want the resumed one", got)
+	}
+	if resumed.ProjectID != "proj" || resumed.DeviceID != "cam1" {
+		t.Errorf("resumed stream not completed: %+v", resumed)
+	}
+
+	msm.Stop()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(commands) != 0 {
+		t.Errorf("commands sent: %v, want none", commands)
+	}
+}
+
+func TestResumeStreamNearExpiry(t *testing.T) {
+	msm := NewMultiStreamManager(newTestClient(nil), "proj", DefaultMultiStreamConfig(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	msm.ResumeStream("cam1", &RTSPStream{ExtensionToken: "ext", ExpiresAt: time.Now().Add(20 * time.Second)})
+	msm.mu.Lock()
+	msm.streams["cam1"] = &CameraStream{CameraID: "cam1"}
+	msm.mu.Unlock()
+
+	if msm.resumeStream("cam1") {
+		t.Error("adopted a stream too close to expiry to extend")
+	}
+	if msm.resumeStream("cam1") {
+		t.Error("stream offered twice")
+	}
+}
+
+func TestAssignCameraRoutesToProject(t *testing.T) {
+	var mu sync.Mutex
+	var mainURLs, cabinURLs []string
+	record := func(urls *[]string) sdmFunc {
+		return func(req *http.Request) (int, string) {
+			mu.Lock()
+			*urls = append(*urls, req.URL.String())
+			mu.Unlock()
+			if req.Method == "GET" {
+				return 200, "jpeg"
+			}
+			return 200, `{"results":{"url":"https://image.example/ev","token":"t"}}`
+		}
+	}
+	msm := NewMultiStreamManager(newTestClient(record(&mainURLs)), "main", DefaultMultiStreamConfig(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	msm.AddProject(newTestClient(record(&cabinURLs)), "cabin")
+	if err := msm.AssignCamera("cam2", "cabin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := msm.AssignCamera("cam3", "nowhere"); err == nil {
+		t.Error("assigned a camera to an unknown project")
+	}
+	msm.Start()
+	defer msm.Stop()
+
+	ctx := context.Background()
+	if _, err := msm.EventImage(ctx, "cam1", "ev", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := msm.EventImage(ctx, "cam2", "ev", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(mainURLs) != 2 || mainURLs[0] != sdmBaseURL+"/enterprises/main/devices/cam1:executeCommand" {
+		t.Errorf("main project requests %v", mainURLs)
+	}
+	if len(cabinURLs) != 2 || cabinURLs[0] != sdmBaseURL+"/enterprises/cabin/devices/cam2:executeCommand" {
+		t.Errorf("cabin project requests %v", cabinURLs)
+	}
+	if stats := msm.GetProjectQueueStats(); stats["main"].TotalExecuted != 1 || stats["cabin"].TotalExecuted != 1 {
+		t.Errorf("per-project stats %+v", stats)
+	}
+	if stats := msm.GetQueueStats(); stats.TotalExecuted != 2 {
+		t.Errorf("total executed %d, want 2", stats.TotalExecuted)
+	}
+}
@@ -0,0 +1,93 @@
+package nest
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// StreamTTL is how long a generated or extended Nest RTSP stream lives
+	StreamTTL = 5 * time.Minute
+
+	extendCheckInterval = 30 * time.Second // How often monitorStream looks at expiry
+	extendThreshold     = 90 * time.Second // Streams closer than this to expiry are extended
+
+	// quotaWarnRatio is the steady-state load above which too little quota is
+	// left for recovery (stream regeneration after failures)
+	quotaWarnRatio = 0.8
+)
+
+// ExtensionInterval is the shortest expected time between two extensions of
+// one stream: a check sees just under extendThreshold left. Checks run every
+// extendCheckInterval, so the real interval is up to that much longer.
+func ExtensionInterval() time.Duration {
+	return StreamTTL - extendThreshold
+}
+
+// StartupPlan estimates the SDM command load of bringing up and keeping a
+// fleet of cameras streaming under a QPM limit
+type StartupPlan struct {
+	Cameras           int
+	QPM               float64
+	StaggerInterval   time.Duration // Minimum wait between cameras; zero when adaptive
+	ExtensionInterval time.Duration // Between extensions of one stream
+	ExtensionQPM      float64       // Steady-state extension load with every camera running
+	StartupDuration   time.Duration // Until the last camera's stream is requested; zero when over quota
+}
+
+// PlanStartup computes the plan for starting cameras with cfg. Each started
+// camera adds extension load, so later cameras wait for whatever quota
+// remains; with StaggerFixed never less than the stagger interval.
+func PlanStartup(cameras int, cfg MultiStreamConfig) StartupPlan {
+	p := StartupPlan{
+		Cameras:           cameras,
+		QPM:               cfg.QPM,
+		ExtensionInterval: ExtensionInterval(),
+	}
+	if cfg.Stagger == StaggerFixed {
+		p.StaggerInterval = cfg.StaggerInterval
+	}
+	perCamera := float64(time.Minute) / float64(p.ExtensionInterval) // Extensions per minute
+	p.ExtensionQPM = perCamera * float64(cameras)
+
+	if p.OverQuota() {
+		return p
+	}
+	for started := 1; started < cameras; started++ {
+		available := p.QPM - perCamera*float64(started)
+		wait := time.Duration(float64(time.Minute) / available)
+		p.StartupDuration += max(wait, p.StaggerInterval)
+	}
+	return p
+}
+
+// OverQuota reports whether extensions alone would exceed the QPM limit, in
+// which case streams expire faster than they can be extended
+func (p StartupPlan) OverQuota() bool {
+	return p.ExtensionQPM >= p.QPM
+}
+
+// Tight reports whether steady-state load leaves little quota for recovery
+func (p StartupPlan) Tight() bool {
+	return p.ExtensionQPM >= p.QPM*quotaWarnRatio
+}
+
+// MaxCameras is the largest fleet whose extensions fit within the QPM limit
+func (p StartupPlan) MaxCameras() int {
+	perCamera := float64(time.Minute) / float64(p.ExtensionInterval)
+	n := int(math.Floor(p.QPM / perCamera))
+	if float64(n)*perCamera >= p.QPM {
+		n--
+	}
+	return n
+}
+
+// Err describes an over-quota plan, nil otherwise
+func (p StartupPlan) Err() error {
+	if !p.OverQuota() {
+		return nil
+	}
+	return fmt.Errorf("%d cameras need %.1f stream extensions per minute but the quota is %.0f QPM (at most %d cameras); enable rotation or reduce the camera count",
+		p.Cameras, p.ExtensionQPM, p.QPM, p.MaxCameras())
+}
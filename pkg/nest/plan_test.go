@@ -0,0 +1,45 @@
+package nest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlanStartup(t *testing.T) {
+	cfg := DefaultMultiStreamConfig()
+	cfg.Stagger = StaggerFixed
+
+	// Extended every 3.5 minutes → 20 cameras need ~5.7 extensions per minute
+	p := PlanStartup(20, cfg)
+	if p.ExtensionInterval != 210*time.Second || p.ExtensionQPM < 5.7 || p.ExtensionQPM > 5.72 {
+		t.Fatalf("interval %v, extension QPM %v; want 3m30s, 5.71", p.ExtensionInterval, p.ExtensionQPM)
+	}
+	if p.OverQuota() || p.Tight() {
+		t.Errorf("20 cameras at 10 QPM flagged over quota or tight")
+	}
+	// 19 waits; the last few are paced by quota rather than the 12s stagger
+	if p.StartupDuration <= 19*12*time.Second || p.StartupDuration > 19*14*time.Second {
+		t.Errorf("startup duration = %v, want just over 3m48s", p.StartupDuration)
+	}
+
+	if p := PlanStartup(30, cfg); !p.Tight() || p.OverQuota() {
+		t.Errorf("30 cameras: tight=%v over=%v, want tight only", p.Tight(), p.OverQuota())
+	}
+
+	p = PlanStartup(35, cfg)
+	if !p.OverQuota() || p.Err() == nil || p.StartupDuration != 0 {
+		t.Errorf("35 cameras should be over quota: %+v", p)
+	}
+	if p.MaxCameras() != 34 {
+		t.Errorf("max cameras = %d, want 34", p.MaxCameras())
+	}
+
+	// Adaptive stagger is paced only by the limiter as quota fills up
+	cfg.Stagger = StaggerAdaptive
+	if p := PlanStartup(20, cfg); p.StartupDuration >= 19*12*time.Second {
+		t.Errorf("adaptive startup = %v, want under the fixed 3m48s", p.StartupDuration)
+	}
+	if p := PlanStartup(2, cfg); p.StartupDuration <= 6*time.Second || p.StartupDuration >= 7*time.Second {
+		t.Errorf("unstaggered startup = %v, want 60s/9.71", p.StartupDuration)
+	}
+}
@@ -9,15 +9,29 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
 	"golang.org/x/time/rate"
 )
 
+// commandTimeout bounds a single command's execution, on top of any deadline
+// the submitter's context already carries
+const commandTimeout = 30 * time.Second
+
+const (
+	quotaPause    = 30 * time.Second // Pause after a quota error that didn't say how long to wait
+	quotaCooldown = 5 * time.Minute  // How long the rate stays reduced after the pause
+	minRateShare  = 0.25             // The rate is never reduced below this share of the QPM
+)
+
 // CommandType defines the priority of API commands
 type CommandType int
 
 const (
 	CmdExtend   CommandType = iota // Priority 0 (HIGH) - keep streams alive
 	CmdGenerate                    // Priority 1 (LOW) - stream recovery
+	CmdStop                        // Priority 2 - release streams on shutdown
+	CmdImage                       // Priority 3 - event snapshots for viewers
+	CmdList                        // Priority 4 (LOWEST) - device registry refreshes
 )
 
 // String returns human-readable command type
@@ -27,6 +41,12 @@ func (c CommandType) String() string {
 		return "extend"
 	case CmdGenerate:
 		return "generate"
+	case CmdStop:
+		return "stop"
+	case CmdImage:
+		return "image"
+	case CmdList:
+		return "list"
 	default:
 		return "unknown"
 	}
@@ -39,7 +59,8 @@ type CommandTicket struct {
 	Attempt    int           // Retry attempt number (for backoff calculation)
 	Timestamp  time.Time     // When ticket was created
 	Response   chan error    // Caller blocks on this until command executes
-	ExecuteFn  func() error  // Function to execute the actual command
+	ExecuteFn  func(ctx context.Context) error // Function to execute the actual command
+	ctx        context.Context // Submitter's context; canceling it withdraws the command
 	priority   int           // Internal priority value for heap
 	index      int           // Internal heap index
 }
@@ -85,6 +106,7 @@ func (h *ticketHeap) Pop() interface{} {
 type CommandQueue struct {
 	logger  *slog.Logger
 	limiter *rate.Limiter
+	qpm     float64
 
 	mu     sync.Mutex
 	heap   ticketHeap
@@ -92,6 +114,10 @@ type CommandQueue struct {
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 
+	// Quota backoff, guarded by mu
+	pausedUntil    time.Time // No commands run before this, after a quota error
+	throttledUntil time.Time // The limiter runs below qpm until this; zero when it doesn't
+
 	// Metrics
 	stats struct {
 		mu             sync.RWMutex
@@ -100,6 +126,7 @@ type CommandQueue struct {
 		totalFailed    int64
 		extendCount    int64
 		generateCount  int64
+		quotaErrors    int64
 		avgWaitTime    time.Duration
 	}
 }
@@ -115,6 +142,7 @@ func NewCommandQueue(qpm float64, logger *slog.Logger) *CommandQueue {
 	cq := &CommandQueue{
 		logger:  logger,
 		limiter: rate.NewLimiter(qps, 1), // Smooth pacing, no bursts
+		qpm:     qpm,
 		ctx:     ctx,
 		cancel:  cancel,
 		heap:    make(ticketHeap, 0),
@@ -161,18 +189,66 @@ func (cq *CommandQueue) Stop() error {
 	return nil
 }
 
+// Discard removes queued commands of the given type, failing their callers
+// with context.Canceled. Used on shutdown so queued extensions and
+// generations don't spend quota ahead of stream stops.
+func (cq *CommandQueue) Discard(cmdType CommandType) int {
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+
+	kept := cq.heap[:0]
+	discarded := 0
+	for _, ticket := range cq.heap {
+		if ticket.Type != cmdType {
+			kept = append(kept, ticket)
+			continue
+		}
+		ticket.Response <- context.Canceled
+		close(ticket.Response)
+		discarded++
+	}
+	for i := len(kept); i < len(cq.heap); i++ {
+		cq.heap[i] = nil
+	}
+	cq.heap = kept
+	heap.Init(&cq.heap)
+	return discarded
+}
+
 // SubmitExtend submits a stream extension command (HIGH priority)
-func (cq *CommandQueue) SubmitExtend(cameraID string, executeFn func() error) error {
-	return cq.submit(CmdExtend, cameraID, 0, executeFn)
+func (cq *CommandQueue) SubmitExtend(ctx context.Context, cameraID string, executeFn func(ctx context.Context) error) error {
+	return cq.submit(ctx, CmdExtend, cameraID, 0, executeFn)
 }
 
 // SubmitGenerate submits a stream generation command (LOW priority)
-func (cq *CommandQueue) SubmitGenerate(cameraID string, attempt int, executeFn func() error) error {
-	return cq.submit(CmdGenerate, cameraID, attempt, executeFn)
+func (cq *CommandQueue) SubmitGenerate(ctx context.Context, cameraID string, attempt int, executeFn func(ctx context.Context) error) error {
+	return cq.submit(ctx, CmdGenerate, cameraID, attempt, executeFn)
+}
+
+// SubmitStop submits a stream stop command (LOWEST priority)
+func (cq *CommandQueue) SubmitStop(ctx context.Context, cameraID string, executeFn func(ctx context.Context) error) error {
+	return cq.submit(ctx, CmdStop, cameraID, 0, executeFn)
+}
+
+// SubmitImage submits an event image command: snapshots never delay the
+// commands that keep streams up
+func (cq *CommandQueue) SubmitImage(ctx context.Context, cameraID string, executeFn func(ctx context.Context) error) error {
+	return cq.submit(ctx, CmdImage, cameraID, 0, executeFn)
+}
+
+// SubmitList submits a device listing (LOWEST priority)
+func (cq *CommandQueue) SubmitList(ctx context.Context, executeFn func(ctx context.Context) error) error {
+	return cq.submit(ctx, CmdList, "", 0, executeFn)
 }
 
-// submit enqueues a command ticket and waits for execution
-func (cq *CommandQueue) submit(cmdType CommandType, cameraID string, attempt int, executeFn func() error) error {
+// submit enqueues a command ticket and waits for execution. Canceling ctx
+// removes a still-queued command without spending quota on it; a command
+// already running sees ctx through its execute function.
+func (cq *CommandQueue) submit(ctx context.Context, cmdType CommandType, cameraID string, attempt int, executeFn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	ticket := &CommandTicket{
 		Type:      cmdType,
 		CameraID:  cameraID,
@@ -180,6 +256,7 @@ func (cq *CommandQueue) submit(cmdType CommandType, cameraID string, attempt int
 		Timestamp: time.Now(),
 		Response:  make(chan error, 1),
 		ExecuteFn: executeFn,
+		ctx:       ctx,
 		priority:  int(cmdType), // Map enum to heap priority
 	}
 
@@ -190,9 +267,10 @@ func (cq *CommandQueue) submit(cmdType CommandType, cameraID string, attempt int
 
 	cq.updateStats(func() {
 		cq.stats.totalEnqueued++
-		if cmdType == CmdExtend {
+		switch cmdType {
+		case CmdExtend:
 			cq.stats.extendCount++
-		} else {
+		case CmdGenerate:
 			cq.stats.generateCount++
 		}
 	})
@@ -217,14 +295,33 @@ func (cq *CommandQueue) submit(cmdType CommandType, cameraID string, attempt int
 			}
 		})
 		return err
+	case <-ctx.Done():
+		cq.withdraw(ticket)
+		return ctx.Err()
 	case <-cq.ctx.Done():
 		return context.Canceled
 	}
 }
 
+// withdraw removes a ticket whose submitter gave up, if it is still queued
+func (cq *CommandQueue) withdraw(ticket *CommandTicket) {
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+
+	if ticket.index < 0 || ticket.index >= cq.heap.Len() || cq.heap[ticket.index] != ticket {
+		return // Already popped by the worker
+	}
+	heap.Remove(&cq.heap, ticket.index)
+	close(ticket.Response)
+	cq.logger.Debug("command withdrawn",
+		"type", ticket.Type.String(),
+		"camera_id", ticket.CameraID)
+}
+
 // workerLoop processes commands from the priority queue with rate limiting
 func (cq *CommandQueue) workerLoop() {
 	defer cq.wg.Done()
+	defer goroutines.Track("nest.queueWorker", "")()
 
 	ticker := time.NewTicker(100 * time.Millisecond) // Check queue every 100ms
 	defer ticker.Stop()
@@ -243,6 +340,16 @@ func (cq *CommandQueue) workerLoop() {
 // processNextCommand pops highest priority ticket and executes with rate limiting
 func (cq *CommandQueue) processNextCommand() {
 	cq.mu.Lock()
+	now := time.Now()
+	if now.Before(cq.pausedUntil) {
+		cq.mu.Unlock()
+		return
+	}
+	if !cq.throttledUntil.IsZero() && now.After(cq.throttledUntil) {
+		cq.throttledUntil = time.Time{}
+		cq.limiter.SetLimit(rate.Limit(cq.qpm / 60.0))
+		cq.logger.Info("command rate restored after quota cooldown", "qpm", cq.qpm)
+	}
 	if cq.heap.Len() == 0 {
 		cq.mu.Unlock()
 		return
@@ -252,9 +359,15 @@ func (cq *CommandQueue) processNextCommand() {
 	queueDepth := cq.heap.Len()
 	cq.mu.Unlock()
 
-	// Apply rate limiting BEFORE execution
-	if err := cq.limiter.Wait(cq.ctx); err != nil {
-		// Context canceled during rate limit wait
+	// The command runs under the submitter's context, and stops with the queue
+	ctx, cancel := context.WithCancel(ticket.ctx)
+	defer cancel()
+	stop := context.AfterFunc(cq.ctx, cancel)
+	defer stop()
+
+	// Apply rate limiting BEFORE execution. A submitter that gives up while
+	// waiting here doesn't spend the token.
+	if err := cq.limiter.Wait(ctx); err != nil {
 		ticket.Response <- err
 		close(ticket.Response)
 		return
@@ -262,7 +375,7 @@ func (cq *CommandQueue) processNextCommand() {
 
 	// Execute the command
 	executeStart := time.Now()
-	err := cq.executeCommand(ticket)
+	err := cq.executeCommand(ctx, ticket)
 	executeDuration := time.Since(executeStart)
 
 	cq.updateStats(func() {
@@ -271,6 +384,11 @@ func (cq *CommandQueue) processNextCommand() {
 			cq.stats.totalFailed++
 		}
 	})
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && errors.Is(apiErr, ErrQuotaExceeded) {
+		cq.updateStats(func() { cq.stats.quotaErrors++ })
+		cq.throttle(apiErr.RetryAfter)
+	}
 
 	cq.logger.Info("command executed",
 		"type", ticket.Type.String(),
@@ -286,34 +404,70 @@ func (cq *CommandQueue) processNextCommand() {
 	close(ticket.Response)
 }
 
-// executeCommand runs the ticket's execute function with timeout
-func (cq *CommandQueue) executeCommand(ticket *CommandTicket) error {
+// executeCommand runs the ticket's execute function with timeout. The
+// function is given the deadline; the select only guards against one that
+// ignores it.
+func (cq *CommandQueue) executeCommand(ctx context.Context, ticket *CommandTicket) error {
 	if ticket.ExecuteFn == nil {
 		return errors.New("execute function is nil")
 	}
 
-	// Create timeout context for individual command execution
-	ctx, cancel := context.WithTimeout(cq.ctx, 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
 	defer cancel()
 
 	// Execute in goroutine to respect timeout
 	errChan := make(chan error, 1)
 	go func() {
-		errChan <- ticket.ExecuteFn()
+		errChan <- ticket.ExecuteFn(ctx)
 	}()
 
 	select {
 	case err := <-errChan:
 		return err
 	case <-ctx.Done():
-		return fmt.Errorf("command timeout after 30s: %w", ctx.Err())
+		return fmt.Errorf("command %s: %w", ticket.Type, ctx.Err())
+	}
+}
+
+// throttle backs off after SDM refused a command for quota: no command
+// runs for retryAfter (quotaPause when SDM didn't say), and the rate is
+// halved, down to minRateShare of the QPM, until quotaCooldown after that.
+// Commands keep their place in the queue meanwhile.
+func (cq *CommandQueue) throttle(retryAfter time.Duration) {
+	if retryAfter <= 0 {
+		retryAfter = quotaPause
 	}
+
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+	cq.pausedUntil = time.Now().Add(retryAfter)
+	cq.throttledUntil = cq.pausedUntil.Add(quotaCooldown)
+	limit := max(cq.limiter.Limit()/2, rate.Limit(cq.qpm/60.0*minRateShare))
+	cq.limiter.SetLimit(limit)
+
+	cq.logger.Warn("quota exceeded, pausing commands and reducing rate",
+		"retry_after", retryAfter,
+		"qpm", float64(limit)*60,
+		"cooldown_until", cq.throttledUntil.Format(time.RFC3339))
+}
+
+// HasHeadroom reports whether a command submitted now would run without
+// waiting: nothing is queued, no quota pause is in effect and the limiter
+// has a token available
+func (cq *CommandQueue) HasHeadroom() bool {
+	cq.mu.Lock()
+	queued := cq.heap.Len()
+	paused := time.Now().Before(cq.pausedUntil)
+	cq.mu.Unlock()
+
+	return queued == 0 && !paused && cq.limiter.Tokens() >= 1
 }
 
 // GetStats returns current queue statistics
 func (cq *CommandQueue) GetStats() QueueStats {
 	cq.mu.Lock()
 	queueDepth := cq.heap.Len()
+	pausedUntil := cq.pausedUntil
 	cq.mu.Unlock()
 
 	cq.stats.mu.RLock()
@@ -327,6 +481,10 @@ func (cq *CommandQueue) GetStats() QueueStats {
 		ExtendCount:   cq.stats.extendCount,
 		GenerateCount: cq.stats.generateCount,
 		AvgWaitTime:   cq.stats.avgWaitTime,
+		QPM:           cq.qpm,
+		EffectiveQPM:  float64(cq.limiter.Limit()) * 60,
+		QuotaErrors:   cq.stats.quotaErrors,
+		PausedUntil:   pausedUntil,
 	}
 }
 
@@ -339,6 +497,10 @@ type QueueStats struct {
 	ExtendCount   int64
 	GenerateCount int64
 	AvgWaitTime   time.Duration
+	QPM           float64   // Configured rate limit
+	EffectiveQPM  float64   // Current rate: below QPM while recovering from quota errors
+	QuotaErrors   int64     // Commands SDM refused for quota
+	PausedUntil   time.Time // No commands run before this, after a quota error
 }
 
 // updateStats safely updates internal stats
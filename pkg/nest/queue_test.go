@@ -0,0 +1,135 @@
+package nest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+)
+
+func TestCommandQueueDiscard(t *testing.T) {
+	cq := NewCommandQueue(60, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// Not started, so both commands stay queued
+	extendErr := make(chan error, 1)
+	noop := func(context.Context) error { return nil }
+	go func() { extendErr <- cq.SubmitExtend(context.Background(), "cam1", noop) }()
+	go cq.SubmitStop(context.Background(), "cam2", noop)
+
+	for deadline := time.Now().Add(time.Second); cq.GetStats().QueueDepth < 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("commands not queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if n := cq.Discard(CmdExtend); n != 1 {
+		t.Fatalf("discarded %d, want 1", n)
+	}
+	if err := <-extendErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("discarded extend returned %v", err)
+	}
+	if depth := cq.GetStats().QueueDepth; depth != 1 {
+		t.Errorf("queue depth %d after discard, want the stop left", depth)
+	}
+	cq.Stop()
+}
+
+func TestCommandQueueWithdrawOnCancel(t *testing.T) {
+	cq := NewCommandQueue(60, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// Not started, so the command stays queued until its submitter gives up
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := false
+	errc := make(chan error, 1)
+	go func() {
+		errc <- cq.SubmitGenerate(ctx, "cam1", 0, func(context.Context) error {
+			ran = true
+			return nil
+		})
+	}()
+
+	for deadline := time.Now().Add(time.Second); cq.GetStats().QueueDepth < 1; {
+		if time.Now().After(deadline) {
+			t.Fatal("command not queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled submit returned %v", err)
+	}
+	if depth := cq.GetStats().QueueDepth; depth != 0 {
+		t.Errorf("queue depth %d after cancel, want 0", depth)
+	}
+
+	cq.Start()
+	time.Sleep(200 * time.Millisecond)
+	cq.Stop()
+	settle, done := context.WithTimeout(context.Background(), time.Second)
+	defer done()
+	if err := goroutines.Settle(settle, "nest.queueWorker"); err != nil {
+		t.Error(err)
+	}
+	if ran {
+		t.Error("withdrawn command was executed")
+	}
+}
+
+func TestCommandQueueExecuteContext(t *testing.T) {
+	cq := NewCommandQueue(600, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	cq.Start()
+	defer cq.Stop()
+
+	type key struct{}
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), key{}, "v"), time.Minute)
+	defer cancel()
+
+	err := cq.SubmitExtend(ctx, "cam1", func(ctx context.Context) error {
+		if ctx.Value(key{}) != "v" {
+			return errors.New("execute context does not derive from the submitter's")
+		}
+		if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > commandTimeout {
+			return errors.New("execute context missing the command timeout")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommandQueueQuotaBackoff(t *testing.T) {
+	cq := NewCommandQueue(600, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	cq.Start()
+	defer cq.Stop()
+
+	refused := func(context.Context) error {
+		return &APIError{Op: "extend stream failed", StatusCode: 429, RetryAfter: 500 * time.Millisecond, Err: ErrQuotaExceeded}
+	}
+	if err := cq.SubmitExtend(context.Background(), "cam1", refused); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("err = %v, want ErrQuotaExceeded", err)
+	}
+	refusedAt := time.Now()
+
+	stats := cq.GetStats()
+	if stats.QuotaErrors != 1 || stats.EffectiveQPM != 300 || stats.PausedUntil.IsZero() {
+		t.Errorf("stats after quota error %+v, want one error at half the rate", stats)
+	}
+	if cq.HasHeadroom() {
+		t.Error("headroom reported while paused")
+	}
+
+	// The next command waits out the Retry-After
+	if err := cq.SubmitExtend(context.Background(), "cam1", func(context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(refusedAt); waited < 500*time.Millisecond {
+		t.Errorf("command ran %v after the quota error, want after Retry-After", waited)
+	}
+}
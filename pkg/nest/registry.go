@@ -0,0 +1,228 @@
+package nest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+)
+
+// DeviceChangeType is what a registry refresh found changed about a device
+type DeviceChangeType string
+
+const (
+	DeviceAdded   DeviceChangeType = "added"
+	DeviceRemoved DeviceChangeType = "removed"
+	DeviceRenamed DeviceChangeType = "renamed"
+)
+
+// DeviceChange is one change a registry refresh found
+type DeviceChange struct {
+	Type      DeviceChangeType
+	ProjectID string
+	Device    Device // As now listed; as last listed for DeviceRemoved
+	OldName   string // DisplayName before a DeviceRenamed
+}
+
+// DeviceRegistry caches a project's cameras and re-lists them every
+// interval, through the project's command queue, reporting the cameras
+// added to, removed from or renamed in the project since
+type DeviceRegistry struct {
+	client    *Client
+	projectID string
+	queue     *CommandQueue // nil lists directly
+	interval  time.Duration
+	logger    *slog.Logger
+
+	mu        sync.RWMutex
+	devices   map[string]Device // By device ID; nil until the first listing
+	refreshed time.Time
+	handlers  []func(DeviceChange)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDeviceRegistry creates a registry of projectID's cameras. Nothing is
+// listed until Seed, Refresh or Start.
+func NewDeviceRegistry(client *Client, projectID string, queue *CommandQueue, interval time.Duration, logger *slog.Logger) *DeviceRegistry {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &DeviceRegistry{
+		client:    client,
+		projectID: projectID,
+		queue:     queue,
+		interval:  interval,
+		logger:    logger,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Seed fills the cache from a listing made elsewhere, such as discovery's,
+// without reporting changes
+func (r *DeviceRegistry) Seed(devices []Device) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.devices = make(map[string]Device, len(devices))
+	for _, d := range devices {
+		r.devices[d.DeviceID] = d
+	}
+	r.refreshed = time.Now()
+}
+
+// OnChange registers fn to be called with each change a refresh finds,
+// from the refresh loop. It may be called multiple times.
+func (r *DeviceRegistry) OnChange(fn func(DeviceChange)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers = append(r.handlers, fn)
+}
+
+// Devices returns the cached cameras, by device ID
+func (r *DeviceRegistry) Devices() []Device {
+	r.mu.RLock()
+	devices := make([]Device, 0, len(r.devices))
+	for _, d := range r.devices {
+		devices = append(devices, d)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i].DeviceID < devices[j].DeviceID })
+	return devices
+}
+
+// Device returns a cached camera
+func (r *DeviceRegistry) Device(deviceID string) (Device, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.devices[deviceID]
+	return d, ok
+}
+
+// LastRefresh returns when the cache was last filled; zero before
+func (r *DeviceRegistry) LastRefresh() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.refreshed
+}
+
+// Start refreshes the cache every interval until Stop. A registry that
+// was never seeded is listed right away, without reporting changes.
+func (r *DeviceRegistry) Start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer goroutines.Track("nest.deviceRegistry", r.projectID)()
+		r.loop()
+	}()
+	r.logger.Info("device registry started", "interval", r.interval)
+}
+
+// Stop stops refreshing and waits for a refresh in progress
+func (r *DeviceRegistry) Stop() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+// loop refreshes every interval until Stop
+func (r *DeviceRegistry) loop() {
+	if r.LastRefresh().IsZero() {
+		if _, err := r.Refresh(r.ctx); err != nil && r.ctx.Err() == nil {
+			r.logger.Warn("device listing failed", "error", err)
+		}
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := r.Refresh(r.ctx); err != nil && r.ctx.Err() == nil {
+			r.logger.Warn("device refresh failed", "error", err)
+		}
+	}
+}
+
+// Refresh lists the project's cameras, updates the cache and reports what
+// changed to the OnChange handlers, returning the changes too. The first
+// listing of a registry never seeded reports nothing.
+func (r *DeviceRegistry) Refresh(ctx context.Context) ([]DeviceChange, error) {
+	var devices []Device
+	list := func(ctx context.Context) error {
+		var err error
+		devices, err = r.client.ListDevices(ctx, r.projectID)
+		return err
+	}
+	var err error
+	if r.queue != nil {
+		err = r.queue.SubmitList(ctx, list)
+	} else {
+		err = list(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+
+	listed := make(map[string]Device, len(devices))
+	for _, d := range devices {
+		listed[d.DeviceID] = d
+	}
+
+	r.mu.Lock()
+	previous := r.devices
+	r.devices = listed
+	r.refreshed = time.Now()
+	handlers := r.handlers
+	r.mu.Unlock()
+
+	if previous == nil {
+		return nil, nil
+	}
+	changes := diffDevices(r.projectID, previous, listed)
+	for _, c := range changes {
+		r.logger.Info("device changed",
+			"change", c.Type,
+			"device_id", c.Device.DeviceID,
+			"name", c.Device.DisplayName(),
+			"old_name", c.OldName)
+		for _, fn := range handlers {
+			fn(c)
+		}
+	}
+	return changes, nil
+}
+
+// diffDevices compares two listings, by device ID: removals first, then
+// additions and renames
+func diffDevices(projectID string, previous, listed map[string]Device) []DeviceChange {
+	var changes []DeviceChange
+	for id, d := range previous {
+		if _, ok := listed[id]; !ok {
+			changes = append(changes, DeviceChange{Type: DeviceRemoved, ProjectID: projectID, Device: d})
+		}
+	}
+	for id, d := range listed {
+		old, ok := previous[id]
+		switch {
+		case !ok:
+			changes = append(changes, DeviceChange{Type: DeviceAdded, ProjectID: projectID, Device: d})
+		case old.DisplayName() != d.DisplayName():
+			changes = append(changes, DeviceChange{Type: DeviceRenamed, ProjectID: projectID, Device: d, OldName: old.DisplayName()})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		if (changes[i].Type == DeviceRemoved) != (changes[j].Type == DeviceRemoved) {
+			return changes[i].Type == DeviceRemoved
+		}
+		return changes[i].Device.DeviceID < changes[j].Device.DeviceID
+	})
+	return changes
+}
@@ -0,0 +1,71 @@
+package nest
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+func TestDeviceRegistryRefresh(t *testing.T) {
+	camera := func(id, name string) string {
+		return `{"name":"enterprises/proj/devices/` + id + `","traits":{` +
+			`"sdm.devices.traits.Info":{"customName":"` + name + `"},` +
+			`"sdm.devices.traits.CameraLiveStream":{"supportedProtocols":["RTSP"]}}}`
+	}
+	listing := `{"devices":[` + camera("cam1", "Porch") + `,` + camera("cam2", "Garage") + `]}`
+	c := newTestClient(func(req *http.Request) (int, string) {
+		if want := sdmBaseURL + "/enterprises/proj/devices"; req.URL.String() != want {
+			t.Errorf("URL = %s, want %s", req.URL, want)
+		}
+		return 200, listing
+	})
+	cq := NewCommandQueue(600, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	cq.Start()
+	defer cq.Stop()
+	r := NewDeviceRegistry(c, "proj", cq, 0, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var reported []DeviceChange
+	r.OnChange(func(c DeviceChange) { reported = append(reported, c) })
+
+	// The first listing only fills the cache
+	ctx := context.Background()
+	if changes, err := r.Refresh(ctx); err != nil || len(changes) != 0 {
+		t.Fatalf("first refresh = %v, %v; want no changes", changes, err)
+	}
+	if devices := r.Devices(); len(devices) != 2 || devices[0].DeviceID != "cam1" {
+		t.Errorf("devices %+v", devices)
+	}
+
+	listing = `{"devices":[` + camera("cam2", "Driveway") + `,` + camera("cam3", "Yard") + `]}`
+	changes, err := r.Refresh(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		typ     DeviceChangeType
+		id, old string
+	}{
+		{DeviceRemoved, "cam1", ""},
+		{DeviceRenamed, "cam2", "Garage"},
+		{DeviceAdded, "cam3", ""},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("changes %+v, want %d", changes, len(want))
+	}
+	for i, w := range want {
+		if c := changes[i]; c.Type != w.typ || c.Device.DeviceID != w.id || c.OldName != w.old || c.ProjectID != "proj" {
+			t.Errorf("change %d = %+v, want %v %s", i, c, w.typ, w.id)
+		}
+	}
+	if len(reported) != len(changes) {
+		t.Errorf("handler saw %d changes, want %d", len(reported), len(changes))
+	}
+	if d, ok := r.Device("cam2"); !ok || d.DisplayName() != "Driveway" {
+		t.Errorf("cam2 = %+v, %v", d, ok)
+	}
+	if stats := cq.GetStats(); stats.TotalExecuted != 2 {
+		t.Errorf("queue executed %d, want both listings", stats.TotalExecuted)
+	}
+}
@@ -0,0 +1,191 @@
+package nest
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+)
+
+// DefaultRotationInterval is how long a rotated-in camera stays active
+const DefaultRotationInterval = 10 * time.Minute
+
+// RotationConfig configures a RotationScheduler
+type RotationConfig struct {
+	Slots    int            // Cameras relayed at once
+	Interval time.Duration  // How often the active set is re-evaluated
+	Priority map[string]int // Per-camera priority; higher wins a slot first
+}
+
+// RotationScheduler relays a subset of a fleet that is larger than the QPM
+// or egress budget allows, cycling cameras through a fixed number of slots.
+//
+// Each interval the scheduler ranks every camera by priority, then viewer
+// demand, then how long it has been waiting, and makes the top Slots
+// cameras active. Cameras of equal priority and demand therefore take
+// turns; a camera with viewers keeps its slot while they watch.
+type RotationScheduler struct {
+	msm     *MultiStreamManager
+	cameras []string
+	cfg     RotationConfig
+	logger  *slog.Logger
+
+	// Demand optionally reports how many viewers are watching a camera.
+	// Set it before Start.
+	Demand func(cameraID string) int
+
+	mu         sync.Mutex
+	active     map[string]bool
+	lastActive map[string]time.Time // When each camera last held a slot
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRotationScheduler creates a scheduler for cameraIDs
+func NewRotationScheduler(msm *MultiStreamManager, cameraIDs []string, cfg RotationConfig, logger *slog.Logger) *RotationScheduler {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultRotationInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &RotationScheduler{
+		msm:        msm,
+		cameras:    append([]string(nil), cameraIDs...),
+		cfg:        cfg,
+		logger:     logger,
+		active:     make(map[string]bool),
+		lastActive: make(map[string]time.Time),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Start activates the first set of cameras and begins rotating
+func (rs *RotationScheduler) Start() {
+	rs.logger.Info("camera rotation enabled",
+		"cameras", len(rs.cameras),
+		"slots", rs.cfg.Slots,
+		"interval", rs.cfg.Interval)
+
+	rs.rotate(time.Now())
+
+	rs.wg.Add(1)
+	go rs.loop()
+}
+
+// Stop halts rotation. Active cameras keep streaming until the stream
+// manager itself is stopped.
+func (rs *RotationScheduler) Stop() {
+	rs.cancel()
+	rs.wg.Wait()
+}
+
+// Active returns the cameras currently holding a slot
+func (rs *RotationScheduler) Active() []string {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	ids := make([]string, 0, len(rs.active))
+	for id := range rs.active {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (rs *RotationScheduler) loop() {
+	defer rs.wg.Done()
+	defer goroutines.Track("nest.rotation", "")()
+
+	ticker := time.NewTicker(rs.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rs.ctx.Done():
+			return
+		case now := <-ticker.C:
+			rs.rotate(now)
+		}
+	}
+}
+
+// rotate applies the next active set: cameras leaving are stopped first so
+// their slots (and quota) are free before replacements are generated
+func (rs *RotationScheduler) rotate(now time.Time) {
+	rs.mu.Lock()
+	demand := make(map[string]int, len(rs.cameras))
+	if rs.Demand != nil {
+		for _, id := range rs.cameras {
+			demand[id] = rs.Demand(id)
+		}
+	}
+	for id := range rs.active {
+		rs.lastActive[id] = now
+	}
+	next := selectActive(rs.cameras, rs.cfg.Slots, rs.cfg.Priority, demand, rs.lastActive)
+
+	var leaving, entering []string
+	for id := range rs.active {
+		if !next[id] {
+			leaving = append(leaving, id)
+		}
+	}
+	for _, id := range rs.cameras {
+		if next[id] && !rs.active[id] {
+			entering = append(entering, id)
+		}
+	}
+	rs.active = next
+	rs.mu.Unlock()
+
+	if len(leaving) == 0 && len(entering) == 0 {
+		return
+	}
+
+	rs.logger.Info("rotating cameras", "stopping", len(leaving), "starting", len(entering))
+
+	for _, id := range leaving {
+		ctx, cancel := context.WithTimeout(rs.ctx, 30*time.Second)
+		if err := rs.msm.StopCamera(ctx, id); err != nil {
+			rs.logger.Warn("failed to stop rotated-out camera", "camera_id", id, "error", err)
+		}
+		cancel()
+	}
+
+	// Generation goes through the rate-limited command queue, which paces it
+	for _, id := range entering {
+		rs.msm.StartCamera(id)
+	}
+}
+
+// selectActive ranks cameras by priority, then demand, then longest wait
+// (never-active cameras first), and returns the top slots
+func selectActive(cameras []string, slots int, priority map[string]int, demand map[string]int, lastActive map[string]time.Time) map[string]bool {
+	ranked := append([]string(nil), cameras...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if priority[a] != priority[b] {
+			return priority[a] > priority[b]
+		}
+		if demand[a] != demand[b] {
+			return demand[a] > demand[b]
+		}
+		return lastActive[a].Before(lastActive[b])
+	})
+
+	if slots > len(ranked) || slots <= 0 {
+		slots = len(ranked)
+	}
+
+	selected := make(map[string]bool, slots)
+	for _, id := range ranked[:slots] {
+		selected[id] = true
+	}
+	return selected
+}
@@ -0,0 +1,55 @@
+package nest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSelectActive(t *testing.T) {
+	cameras := []string{"a", "b", "c", "d"}
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		priority   map[string]int
+		demand     map[string]int
+		lastActive map[string]time.Time
+		expected   []string
+	}{
+		{
+			name:     "Never-active cameras in discovery order",
+			expected: []string{"a", "b"},
+		},
+		{
+			name:       "Longest waiting rotates in",
+			lastActive: map[string]time.Time{"a": now, "b": now, "c": now.Add(-time.Hour)},
+			expected:   []string{"c", "d"},
+		},
+		{
+			name:       "Priority beats waiting time",
+			priority:   map[string]int{"a": 1},
+			lastActive: map[string]time.Time{"a": now, "b": now},
+			expected:   []string{"a", "c"},
+		},
+		{
+			name:       "Watched camera keeps its slot",
+			demand:     map[string]int{"b": 2},
+			lastActive: map[string]time.Time{"a": now, "b": now},
+			expected:   []string{"b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := selectActive(cameras, 2, tt.priority, tt.demand, tt.lastActive)
+			if len(result) != len(tt.expected) {
+				t.Fatalf("selected %v, expected %v", result, tt.expected)
+			}
+			for _, id := range tt.expected {
+				if !result[id] {
+					t.Errorf("selected %v, expected %v", result, tt.expected)
+				}
+			}
+		})
+	}
+}
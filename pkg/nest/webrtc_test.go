@@ -0,0 +1,122 @@
+package nest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sdmFunc answers SDM requests in place of the API
+type sdmFunc func(req *http.Request) (int, string)
+
+func (f sdmFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	status, body := f(req)
+	return &http.Response{
+		StatusCode: status,
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Header:     make(http.Header),
+		Request:    req,
+	}, nil
+}
+
+// newTestClient is a client with a valid token whose requests f answers
+func newTestClient(f sdmFunc) *Client {
+	c := NewClient("id", "secret", "refresh", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	c.accessToken = "token"
+	c.tokenExpiry = time.Now().Add(time.Hour)
+	c.SetHTTPClient(&http.Client{Transport: f})
+	return c
+}
+
+func TestWebRTCStreamCommands(t *testing.T) {
+	var commands []string
+	c := newTestClient(func(req *http.Request) (int, string) {
+		if want := sdmBaseURL + "/enterprises/proj/devices/dev:executeCommand"; req.URL.String() != want {
+			t.Errorf("URL = %s, want %s", req.URL, want)
+		}
+		var cmd struct {
+			Command string            `json:"command"`
+			Params  map[string]string `json:"params"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&cmd); err != nil {
+			t.Fatal(err)
+		}
+		commands = append(commands, cmd.Command)
+
+		switch cmd.Command {
+		case "sdm.devices.commands.CameraLiveStream.GenerateWebRtcStream":
+			if cmd.Params["offerSdp"] != "offer" {
+				t.Errorf("offerSdp = %q", cmd.Params["offerSdp"])
+			}
+			return 200, `{"results":{"answerSdp":"answer","mediaSessionId":"s1","expiresAt":"2030-01-01T00:00:00Z"}}`
+		case "sdm.devices.commands.CameraLiveStream.ExtendWebRtcStream":
+			if cmd.Params["mediaSessionId"] != "s1" {
+				t.Errorf("extend mediaSessionId = %q", cmd.Params["mediaSessionId"])
+			}
+			return 200, `{"results":{"mediaSessionId":"s2","expiresAt":"2030-01-01T00:05:00Z"}}`
+		case "sdm.devices.commands.CameraLiveStream.StopWebRtcStream":
+			if cmd.Params["mediaSessionId"] != "s2" {
+				t.Errorf("stop mediaSessionId = %q", cmd.Params["mediaSessionId"])
+			}
+			return 200, `{}`
+		}
+		return 400, `{"error":{"message":"unknown command"}}`
+	})
+
+	ctx := t.Context()
+	stream, err := c.GenerateWebRTCStream(ctx, "proj", "dev", "offer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stream.AnswerSDP != "answer" || stream.MediaSessionID != "s1" || stream.DeviceID != "dev" {
+		t.Errorf("stream = %+v", stream)
+	}
+
+	if err := c.ExtendWebRTCStream(ctx, stream); err != nil {
+		t.Fatal(err)
+	}
+	if stream.MediaSessionID != "s2" || !stream.ExpiresAt.Equal(time.Date(2030, 1, 1, 0, 5, 0, 0, time.UTC)) {
+		t.Errorf("extended stream = %+v", stream)
+	}
+
+	if err := c.StopWebRTCStream(ctx, stream); err != nil {
+		t.Fatal(err)
+	}
+	if len(commands) != 3 {
+		t.Errorf("commands = %v", commands)
+	}
+}
+
+func TestGenerateWebRTCStreamOffline(t *testing.T) {
+	c := newTestClient(func(req *http.Request) (int, string) {
+		return 400, `{"error":{"code":400,"message":"The camera is offline.","status":"FAILED_PRECONDITION"}}`
+	})
+	_, err := c.GenerateWebRTCStream(t.Context(), "proj", "dev", "offer")
+	if !errors.Is(err, ErrDeviceOffline) {
+		t.Errorf("err = %v, want ErrDeviceOffline", err)
+	}
+}
+
+func TestDeviceWebRTCOnly(t *testing.T) {
+	tests := []struct {
+		protocols []string
+		want      bool
+	}{
+		{[]string{"RTSP"}, false},
+		{[]string{"WEB_RTC"}, true},
+		{[]string{"RTSP", "WEB_RTC"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		var d Device
+		d.Traits.CameraLiveStream.SupportedProtocols = tt.protocols
+		if got := d.WebRTCOnly(); got != tt.want {
+			t.Errorf("%v: WebRTCOnly = %v, want %v", tt.protocols, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package nestrtc
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	rtspClient "github.com/ethan/nest-cloudflare-relay/pkg/rtsp"
+	"github.com/pion/rtp"
+)
+
+// errConnClosed ends ReadPackets for a relay that closed or was replaced
+var errConnClosed = errors.New("WebRTC stream detached from relay")
+
+// conn is one relay's view of a Receiver's stream
+type conn struct {
+	receiver *Receiver
+
+	tracks      map[byte]*rtspClient.Channel // The receiver's; SkipMedia removes from it
+	onPacket    func(channel byte, packet *rtp.Packet)
+	onReconnect func()
+
+	playing   atomic.Bool // Packets are queued only once Play is called
+	packets   chan received
+	stats     map[byte]*trackStats // Fixed when the conn is made, so Stats needs no lock
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// received is a packet and the channel it came in on
+type received struct {
+	channel byte
+	packet  *rtp.Packet
+}
+
+// trackStats counts one track's packets
+type trackStats struct {
+	packets atomic.Uint64
+	bytes   atomic.Uint64
+}
+
+// Connect waits for the camera's peer connection to come up
+func (c *conn) Connect(ctx context.Context) error {
+	r := c.receiver
+	select {
+	case <-r.connected:
+	case <-r.failed:
+		return errors.New("camera peer connection failed")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Tracks returns the camera's tracks from its answer
+func (c *conn) Tracks() map[byte]*rtspClient.Channel {
+	return c.tracks
+}
+
+// Probe describes the tracks the camera answered with
+func (c *conn) Probe() *rtspClient.ProbeResult {
+	ids := make([]int, 0, len(c.tracks))
+	for id := range c.tracks {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	result := &rtspClient.ProbeResult{ProbedAt: time.Now()}
+	for _, id := range ids {
+		ch := c.tracks[byte(id)]
+		result.Media = append(result.Media, rtspClient.MediaCapability{
+			MediaType:   ch.MediaType,
+			PayloadType: ch.PayloadType,
+			Codec:       ch.Codec,
+			ClockRate:   ch.ClockRate,
+			Channels:    ch.Channels,
+			Fmtp:        ch.FmtpParams(),
+		})
+	}
+	return result
+}
+
+func (c *conn) AudioCodec() string { return c.codec("audio") }
+func (c *conn) VideoCodec() string { return c.codec("video") }
+
+func (c *conn) codec(mediaType string) string {
+	for _, ch := range c.tracks {
+		if ch.MediaType == mediaType {
+			return ch.Codec
+		}
+	}
+	return ""
+}
+
+// VideoParameterSets is nil: WebRTC carries the SPS and PPS in band
+func (c *conn) VideoParameterSets() [][]byte {
+	return nil
+}
+
+// SkipMedia drops a media type's tracks; their packets are discarded
+func (c *conn) SkipMedia(mediaType string) {
+	for id, ch := range c.tracks {
+		if ch.MediaType == mediaType {
+			delete(c.tracks, id)
+		}
+	}
+}
+
+// SetHandlers sets the packet and reconnect callbacks. The stream never
+// restarts under a relay, so onReconnect is not called.
+func (c *conn) SetHandlers(onPacket func(channel byte, packet *rtp.Packet), onReconnect func()) {
+	c.onPacket = onPacket
+	c.onReconnect = onReconnect
+}
+
+// SetupTracks has nothing to do: the answer set the tracks up
+func (c *conn) SetupTracks(ctx context.Context) error {
+	return nil
+}
+
+// Play starts queueing the camera's packets for ReadPackets, asking for a
+// keyframe to start from
+func (c *conn) Play(ctx context.Context) error {
+	c.playing.Store(true)
+	c.receiver.requestKeyframe()
+	return nil
+}
+
+// deliver queues a packet from the receiver's track readers
+func (c *conn) deliver(channel byte, packet *rtp.Packet) {
+	if !c.playing.Load() {
+		return
+	}
+	select {
+	case c.packets <- received{channel, packet}:
+	default: // The relay fell packetQueue behind; loss it recovers from as from the network's
+	}
+}
+
+// ReadPackets hands queued packets to the packet handler until ctx ends,
+// the conn closes or the camera's peer connection fails
+func (c *conn) ReadPackets(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.done:
+			return errConnClosed
+		case <-c.receiver.failed:
+			return errors.New("camera peer connection failed")
+		case p := <-c.packets:
+			if _, ok := c.tracks[p.channel]; !ok {
+				continue
+			}
+			if s := c.stats[p.channel]; s != nil {
+				s.packets.Add(1)
+				s.bytes.Add(uint64(p.packet.MarshalSize()))
+			}
+			if c.onPacket != nil {
+				c.onPacket(p.channel, p.packet)
+			}
+		}
+	}
+}
+
+// Stats returns each track's packet and byte counts
+func (c *conn) Stats() rtspClient.ClientStats {
+	stats := rtspClient.ClientStats{Channels: make(map[byte]rtspClient.ChannelStats, len(c.stats))}
+	for id, s := range c.stats {
+		mediaType := "audio"
+		if id == videoChannel {
+			mediaType = "video"
+		}
+		stats.Channels[id] = rtspClient.ChannelStats{
+			MediaType: mediaType,
+			Packets:   s.packets.Load(),
+			Bytes:     s.bytes.Load(),
+		}
+	}
+	return stats
+}
+
+// Abort drops the camera's peer connection, as losing the camera would,
+// so the stream is regenerated
+func (c *conn) Abort() error {
+	return c.receiver.Close()
+}
+
+// Close detaches the relay, leaving the stream up for the next one
+func (c *conn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.receiver.current.CompareAndSwap(c, nil)
+	})
+	return nil
+}
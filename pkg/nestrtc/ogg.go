@@ -0,0 +1,128 @@
+package nestrtc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Ogg page layout (RFC 3533): a 27-byte header, then one lacing value per
+// segment, then the segments
+const (
+	oggHeaderLen    = 27
+	oggContinued    = 0x01 // Header type flag: the page's first packet began on the last
+	maxOggPacket    = 64 << 10
+	opusHeadMinSize = 19 // Without a channel mapping table
+)
+
+// oggReader splits an Ogg Opus stream into its Opus packets. Pages are read
+// as needed, so a live upload plays as it arrives.
+type oggReader struct {
+	r       io.Reader
+	packets [][]byte // Complete packets of the last page, not yet returned
+	partial []byte   // A packet the last page left to continue on the next
+}
+
+func newOggReader(r io.Reader) *oggReader {
+	return &oggReader{r: r}
+}
+
+// readHeaders reads the OpusHead and OpusTags packets that begin the stream
+func (o *oggReader) readHeaders() error {
+	head, err := o.next()
+	if err == io.EOF {
+		return fmt.Errorf("%w: empty", ErrInvalidTalkback)
+	}
+	if err != nil {
+		return err
+	}
+	if len(head) < opusHeadMinSize || !bytes.HasPrefix(head, []byte("OpusHead")) {
+		return fmt.Errorf("%w: no OpusHead", ErrInvalidTalkback)
+	}
+	if channels := head[9]; channels == 0 || channels > 2 {
+		return fmt.Errorf("%w: %d channels", ErrInvalidTalkback, channels)
+	}
+	tags, err := o.next()
+	if err == io.EOF {
+		return fmt.Errorf("%w: no OpusTags", ErrInvalidTalkback)
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(tags, []byte("OpusTags")) {
+		return fmt.Errorf("%w: no OpusTags", ErrInvalidTalkback)
+	}
+	return nil
+}
+
+// next returns the stream's next packet; io.EOF once it ends
+func (o *oggReader) next() ([]byte, error) {
+	for len(o.packets) == 0 {
+		if err := o.readPage(); err != nil {
+			return nil, err
+		}
+	}
+	packet := o.packets[0]
+	o.packets = o.packets[1:]
+	return packet, nil
+}
+
+// readPage reads one page, splitting its segments into packets. A lacing
+// value of 255 continues the packet into the next segment.
+func (o *oggReader) readPage() error {
+	header := make([]byte, oggHeaderLen)
+	if _, err := io.ReadFull(o.r, header); err != nil {
+		if err == io.EOF && o.partial == nil {
+			return io.EOF
+		}
+		return readError(err)
+	}
+	if !bytes.Equal(header[:4], []byte("OggS")) || header[4] != 0 {
+		return fmt.Errorf("%w: bad page header", ErrInvalidTalkback)
+	}
+	if header[5]&oggContinued == 0 && o.partial != nil {
+		return fmt.Errorf("%w: packet not continued", ErrInvalidTalkback)
+	}
+
+	lacing := make([]byte, header[26])
+	if _, err := io.ReadFull(o.r, lacing); err != nil {
+		return readError(err)
+	}
+	size := 0
+	for _, n := range lacing {
+		size += int(n)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(o.r, body); err != nil {
+		return readError(err)
+	}
+
+	packet := o.partial
+	o.partial = nil
+	for _, n := range lacing {
+		packet = append(packet, body[:n]...)
+		body = body[n:]
+		if len(packet) > maxOggPacket {
+			return fmt.Errorf("%w: packet over %d bytes", ErrInvalidTalkback, maxOggPacket)
+		}
+		if n < 255 {
+			if len(packet) > 0 {
+				o.packets = append(o.packets, packet)
+			}
+			packet = nil
+		}
+	}
+	if packet != nil {
+		o.partial = packet
+	}
+	return nil
+}
+
+// readError reports a stream that ends mid-page as invalid, and other read
+// errors as they are
+func readError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: truncated page", ErrInvalidTalkback)
+	}
+	return fmt.Errorf("read talkback audio: %w", err)
+}
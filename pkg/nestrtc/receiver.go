@@ -0,0 +1,316 @@
+// Package nestrtc receives Nest cameras' WebRTC streams, for battery cameras
+// and doorbells that offer no RTSP. A Receiver makes the offer the stream is
+// generated with, as the stream's nest.WebRTCPeer, and hands the camera's
+// RTP to each relay that reads the stream as its relay.MediaConn.
+package nestrtc
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+	rtspClient "github.com/ethan/nest-cloudflare-relay/pkg/rtsp"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v4"
+)
+
+// Channels the camera's tracks are delivered on, as RTSP interleaves them
+const (
+	videoChannel byte = 0
+	audioChannel byte = 2
+)
+
+// packetQueue is how many packets a relay may fall behind before they drop
+const packetQueue = 512
+
+// DefaultICEServers are used when Config.ICEServers is empty
+var DefaultICEServers = []webrtc.ICEServer{{URLs: []string{"stun:stun.l.google.com:19302"}}}
+
+// Config configures a Receiver; zero fields are defaults
+type Config struct {
+	ICEServers    []webrtc.ICEServer
+	GatherTimeout time.Duration // ICE candidate gathering for the offer (default: 10s)
+	Talkback      bool          // Offer to send audio too, for Talk
+	CameraID      string        // For goroutine accounting
+}
+
+// Receiver is the local end of one Nest WebRTC stream
+type Receiver struct {
+	pc     *webrtc.PeerConnection
+	config Config
+	logger *slog.Logger
+
+	tracks    map[byte]*rtspClient.Channel // From the camera's answer
+	videoSSRC atomic.Uint32                // Set when the camera's video arrives, for PLIs
+
+	// Talkback; speaker is nil unless Config.Talkback, and unset by an
+	// answer that takes no audio
+	speaker *webrtc.TrackLocalStaticSample
+	talking atomic.Bool // A Talk is playing
+
+	current   atomic.Pointer[conn] // The relay reading the stream, if any
+	connected chan struct{}        // Closed when the peer connection first connects
+	failed    chan struct{}        // Closed when it fails or is closed
+	connOnce  sync.Once
+	failOnce  sync.Once
+}
+
+// NewReceiver creates a receiver for one stream, ready to make its offer
+func NewReceiver(config Config, logger *slog.Logger) (*Receiver, error) {
+	if len(config.ICEServers) == 0 {
+		config.ICEServers = DefaultICEServers
+	}
+	if config.GatherTimeout <= 0 {
+		config.GatherTimeout = 10 * time.Second
+	}
+
+	// Nest cameras send H.264 and Opus
+	m := &webrtc.MediaEngine{}
+	for _, codec := range []webrtc.RTPCodecParameters{
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000,
+			SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f"}, PayloadType: 102},
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000,
+			SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=4d001f"}, PayloadType: 103},
+	} {
+		if err := m.RegisterCodec(codec, webrtc.RTPCodecTypeVideo); err != nil {
+			return nil, fmt.Errorf("register H264 codec: %w", err)
+		}
+	}
+	if err := m.RegisterCodec(webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2},
+		PayloadType:        111,
+	}, webrtc.RTPCodecTypeAudio); err != nil {
+		return nil, fmt.Errorf("register Opus codec: %w", err)
+	}
+
+	// NACKs and receiver reports keep the camera's sender informed of loss
+	registry := &interceptor.Registry{}
+	if err := webrtc.RegisterDefaultInterceptors(m, registry); err != nil {
+		return nil, fmt.Errorf("register interceptors: %w", err)
+	}
+
+	api := webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithInterceptorRegistry(registry))
+	pc, err := api.NewPeerConnection(webrtc.Configuration{ICEServers: config.ICEServers})
+	if err != nil {
+		return nil, fmt.Errorf("create peer connection: %w", err)
+	}
+
+	r := &Receiver{
+		pc:        pc,
+		config:    config,
+		logger:    logger,
+		connected: make(chan struct{}),
+		failed:    make(chan struct{}),
+	}
+
+	// Nest wants audio, video and a data channel, in that order
+	if config.Talkback {
+		if err := r.addSpeaker(); err != nil {
+			pc.Close()
+			return nil, err
+		}
+	}
+	for _, kind := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeAudio, webrtc.RTPCodecTypeVideo} {
+		if kind == webrtc.RTPCodecTypeAudio && r.speaker != nil {
+			continue
+		}
+		if _, err := pc.AddTransceiverFromKind(kind, webrtc.RTPTransceiverInit{
+			Direction: webrtc.RTPTransceiverDirectionRecvonly,
+		}); err != nil {
+			pc.Close()
+			return nil, fmt.Errorf("add %s transceiver: %w", kind, err)
+		}
+	}
+	if _, err := pc.CreateDataChannel("dataSendChannel", nil); err != nil {
+		pc.Close()
+		return nil, fmt.Errorf("create data channel: %w", err)
+	}
+
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		r.logger.Info("camera peer connection state changed", "state", state.String())
+		switch state {
+		case webrtc.PeerConnectionStateConnected:
+			r.connOnce.Do(func() { close(r.connected) })
+		case webrtc.PeerConnectionStateFailed, webrtc.PeerConnectionStateClosed:
+			r.failOnce.Do(func() { close(r.failed) })
+		}
+	})
+	pc.OnTrack(r.readTrack)
+
+	return r, nil
+}
+
+// Offer creates the SDP offer with every ICE candidate gathered
+func (r *Receiver) Offer() (string, error) {
+	offer, err := r.pc.CreateOffer(nil)
+	if err != nil {
+		return "", fmt.Errorf("create offer: %w", err)
+	}
+	gathered := webrtc.GatheringCompletePromise(r.pc)
+	if err := r.pc.SetLocalDescription(offer); err != nil {
+		return "", fmt.Errorf("set local description: %w", err)
+	}
+
+	select {
+	case <-gathered:
+	case <-time.After(r.config.GatherTimeout):
+		r.logger.Warn("ICE gathering timed out, offering the candidates found so far")
+	}
+	return r.pc.LocalDescription().SDP, nil
+}
+
+// Accept applies the camera's answer and learns its tracks from it
+func (r *Receiver) Accept(answerSDP string) error {
+	tracks, err := tracksFromAnswer(answerSDP)
+	if err != nil {
+		return err
+	}
+	if err := r.pc.SetRemoteDescription(webrtc.SessionDescription{
+		Type: webrtc.SDPTypeAnswer,
+		SDP:  answerSDP,
+	}); err != nil {
+		return fmt.Errorf("set remote description: %w", err)
+	}
+	r.tracks = tracks
+	if r.speaker != nil && !answerTakesAudio(answerSDP) {
+		r.logger.Info("camera declined talkback audio")
+		r.speaker = nil
+	}
+	return nil
+}
+
+// Close ends the stream's peer connection and any relay reading it
+func (r *Receiver) Close() error {
+	return r.pc.Close()
+}
+
+// Media attaches a relay to the stream. A relay that attaches later takes
+// over, and closing a relay's conn leaves the stream up for the next one.
+func (r *Receiver) Media() relay.MediaConn {
+	c := &conn{
+		receiver: r,
+		tracks:   make(map[byte]*rtspClient.Channel, len(r.tracks)),
+		packets:  make(chan received, packetQueue),
+		done:     make(chan struct{}),
+		stats:    make(map[byte]*trackStats, len(r.tracks)),
+	}
+	for id, ch := range r.tracks {
+		copied := *ch
+		c.tracks[id] = &copied
+		c.stats[id] = &trackStats{}
+	}
+	if old := r.current.Swap(c); old != nil {
+		old.Close()
+	}
+	return c
+}
+
+// readTrack delivers one of the camera's tracks to the current relay
+func (r *Receiver) readTrack(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+	channel := audioChannel
+	if track.Kind() == webrtc.RTPCodecTypeVideo {
+		channel = videoChannel
+		r.videoSSRC.Store(uint32(track.SSRC()))
+		r.requestKeyframe()
+	}
+	r.logger.Info("receiving camera track",
+		"kind", track.Kind().String(),
+		"codec", track.Codec().MimeType)
+
+	for {
+		packet, _, err := track.ReadRTP()
+		if err != nil {
+			return
+		}
+		if c := r.current.Load(); c != nil {
+			c.deliver(channel, packet)
+		}
+	}
+}
+
+// requestKeyframe asks the camera for an IDR, so a relay starts decoding
+// without waiting for the next one
+func (r *Receiver) requestKeyframe() {
+	ssrc := r.videoSSRC.Load()
+	if ssrc == 0 {
+		return
+	}
+	if err := r.pc.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: ssrc}}); err != nil {
+		r.logger.Debug("failed to request keyframe", "error", err)
+	}
+}
+
+// tracksFromAnswer reads the camera's audio and video from its answer: the
+// first format of each media section it accepted
+func tracksFromAnswer(answerSDP string) (map[byte]*rtspClient.Channel, error) {
+	var sd sdp.SessionDescription
+	if err := sd.UnmarshalString(answerSDP); err != nil {
+		return nil, fmt.Errorf("parse answer: %w", err)
+	}
+
+	tracks := make(map[byte]*rtspClient.Channel)
+	for _, md := range sd.MediaDescriptions {
+		var channel byte
+		switch md.MediaName.Media {
+		case "video":
+			channel = videoChannel
+		case "audio":
+			channel = audioChannel
+		default:
+			continue
+		}
+		if md.MediaName.Port.Value == 0 || len(md.MediaName.Formats) == 0 {
+			continue // Rejected
+		}
+		if _, ok := md.Attribute("inactive"); ok {
+			continue
+		}
+
+		format := md.MediaName.Formats[0]
+		pt, err := strconv.ParseUint(format, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("answer %s payload type %q", md.MediaName.Media, format)
+		}
+		ch := &rtspClient.Channel{
+			ID:          channel,
+			MediaType:   md.MediaName.Media,
+			PayloadType: uint8(pt),
+		}
+		for _, attr := range md.Attributes {
+			value, ok := strings.CutPrefix(attr.Value, format+" ")
+			if !ok {
+				continue
+			}
+			switch attr.Key {
+			case "rtpmap":
+				// encoding/clock rate[/channels]
+				parts := strings.Split(value, "/")
+				ch.Codec = strings.ToUpper(parts[0])
+				if len(parts) > 1 {
+					ch.ClockRate, _ = strconv.Atoi(parts[1])
+				}
+				if len(parts) > 2 {
+					ch.Channels, _ = strconv.Atoi(parts[2])
+				}
+			case "fmtp":
+				ch.Fmtp = value
+			}
+		}
+		if ch.Codec == "" {
+			return nil, fmt.Errorf("answer %s has no rtpmap for payload type %d", md.MediaName.Media, pt)
+		}
+		tracks[channel] = ch
+	}
+	if len(tracks) == 0 {
+		return nil, errors.New("camera answered with no audio or video")
+	}
+	return tracks, nil
+}
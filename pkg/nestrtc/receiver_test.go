@@ -0,0 +1,97 @@
+package nestrtc
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/pion/sdp/v3"
+)
+
+func TestOfferMediaOrder(t *testing.T) {
+	r, err := NewReceiver(Config{GatherTimeout: 2 * time.Second}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	offer, err := r.Offer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sd sdp.SessionDescription
+	if err := sd.UnmarshalString(offer); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nest rejects offers whose media come in another order
+	want := []string{"audio", "video", "application"}
+	if len(sd.MediaDescriptions) != len(want) {
+		t.Fatalf("offer has %d media sections, want %d", len(sd.MediaDescriptions), len(want))
+	}
+	for i, md := range sd.MediaDescriptions {
+		if md.MediaName.Media != want[i] {
+			t.Errorf("media %d = %s, want %s", i, md.MediaName.Media, want[i])
+		}
+		if i < 2 {
+			if _, ok := md.Attribute("recvonly"); !ok {
+				t.Errorf("%s is not recvonly", md.MediaName.Media)
+			}
+		}
+	}
+}
+
+func TestTracksFromAnswer(t *testing.T) {
+	answer := "v=0\r\n" +
+		"o=- 0 0 IN IP4 127.0.0.1\r\n" +
+		"s=-\r\n" +
+		"t=0 0\r\n" +
+		"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+		"c=IN IP4 0.0.0.0\r\n" +
+		"a=rtpmap:111 opus/48000/2\r\n" +
+		"a=fmtp:111 minptime=10;useinbandfec=1\r\n" +
+		"a=sendonly\r\n" +
+		"m=video 9 UDP/TLS/RTP/SAVPF 102 103\r\n" +
+		"c=IN IP4 0.0.0.0\r\n" +
+		"a=rtpmap:102 H264/90000\r\n" +
+		"a=fmtp:102 packetization-mode=1;profile-level-id=42e01f\r\n" +
+		"a=rtpmap:103 H264/90000\r\n" +
+		"a=sendonly\r\n" +
+		"m=application 9 UDP/DTLS/SCTP webrtc-datachannel\r\n" +
+		"c=IN IP4 0.0.0.0\r\n"
+
+	tracks, err := tracksFromAnswer(answer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 2 {
+		t.Fatalf("got %d tracks, want 2", len(tracks))
+	}
+
+	video := tracks[videoChannel]
+	if video == nil || video.Codec != "H264" || video.PayloadType != 102 || video.ClockRate != 90000 {
+		t.Errorf("video = %+v", video)
+	}
+	if video != nil && video.FmtpParam("packetization-mode") != "1" {
+		t.Errorf("video fmtp = %q", video.Fmtp)
+	}
+	audio := tracks[audioChannel]
+	if audio == nil || audio.Codec != "OPUS" || audio.ClockRate != 48000 || audio.Channels != 2 {
+		t.Errorf("audio = %+v", audio)
+	}
+}
+
+func TestTracksFromAnswerRejected(t *testing.T) {
+	answer := "v=0\r\n" +
+		"o=- 0 0 IN IP4 127.0.0.1\r\n" +
+		"s=-\r\n" +
+		"t=0 0\r\n" +
+		"m=audio 0 UDP/TLS/RTP/SAVPF 111\r\n" +
+		"c=IN IP4 0.0.0.0\r\n" +
+		"a=rtpmap:111 opus/48000/2\r\n"
+
+	if _, err := tracksFromAnswer(answer); err == nil {
+		t.Error("answer without accepted media parsed")
+	}
+}
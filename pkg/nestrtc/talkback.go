@@ -0,0 +1,156 @@
+package nestrtc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+// Talkback errors
+var (
+	ErrNoTalkback      = errors.New("stream has no talkback audio")
+	ErrTalkbackBusy    = errors.New("talkback already playing")
+	ErrInvalidTalkback = errors.New("talkback audio is not Ogg Opus")
+)
+
+// addSpeaker adds the audio transceiver with a track for Talk, sending to
+// the camera's speaker as well as receiving its microphone
+func (r *Receiver) addSpeaker() error {
+	track, err := webrtc.NewTrackLocalStaticSample(
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2},
+		"audio", "talkback")
+	if err != nil {
+		return fmt.Errorf("create talkback track: %w", err)
+	}
+	transceiver, err := r.pc.AddTransceiverFromTrack(track, webrtc.RTPTransceiverInit{
+		Direction: webrtc.RTPTransceiverDirectionSendrecv,
+	})
+	if err != nil {
+		return fmt.Errorf("add audio transceiver: %w", err)
+	}
+
+	// The camera's receiver reports must be read for the interceptors
+	goroutines.Go("nestrtc.rtcp", r.config.CameraID, func() {
+		for {
+			if _, _, err := transceiver.Sender().ReadRTCP(); err != nil {
+				return
+			}
+		}
+	})
+	r.speaker = track
+	return nil
+}
+
+// Talk plays Ogg Opus audio through the camera's speaker, in real time,
+// returning once it has played, ctx ends or the stream fails. The stream
+// needs Config.Talkback and a camera that took audio in its answer.
+func (r *Receiver) Talk(ctx context.Context, audio io.Reader) error {
+	if r.speaker == nil {
+		return ErrNoTalkback
+	}
+	if !r.talking.CompareAndSwap(false, true) {
+		return ErrTalkbackBusy
+	}
+	defer r.talking.Store(false)
+
+	packets := newOggReader(audio)
+	if err := packets.readHeaders(); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	next := time.Now()
+	for {
+		packet, err := packets.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		duration := opusDuration(packet)
+		if duration == 0 {
+			return fmt.Errorf("%w: bad Opus packet", ErrInvalidTalkback)
+		}
+
+		// Packets are sent as the camera plays them, not as fast as they
+		// are uploaded
+		timer.Reset(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.failed:
+			return errors.New("camera peer connection failed")
+		}
+		if err := r.speaker.WriteSample(media.Sample{Data: packet, Duration: duration}); err != nil {
+			return fmt.Errorf("send talkback audio: %w", err)
+		}
+		next = next.Add(duration)
+	}
+}
+
+// answerTakesAudio reports whether the camera's answer receives the audio
+// the offer sends: its audio is sendrecv or recvonly
+func answerTakesAudio(answerSDP string) bool {
+	var sd sdp.SessionDescription
+	if err := sd.UnmarshalString(answerSDP); err != nil {
+		return false
+	}
+	for _, md := range sd.MediaDescriptions {
+		if md.MediaName.Media != "audio" || md.MediaName.Port.Value == 0 {
+			continue
+		}
+		for _, direction := range []string{"sendonly", "inactive"} {
+			if _, ok := md.Attribute(direction); ok {
+				return false
+			}
+		}
+		return true // sendrecv is the default
+	}
+	return false
+}
+
+// opusDuration returns how much audio an Opus packet holds, from its TOC
+// byte (RFC 6716 section 3.1); zero for a malformed packet
+func opusDuration(packet []byte) time.Duration {
+	if len(packet) == 0 {
+		return 0
+	}
+	toc := packet[0]
+	config := toc >> 3
+
+	var frame time.Duration
+	switch {
+	case config < 12: // SILK
+		frame = [...]time.Duration{10, 20, 40, 60}[config%4] * time.Millisecond
+	case config < 16: // Hybrid
+		frame = [...]time.Duration{10, 20}[config%2] * time.Millisecond
+	default: // CELT
+		frame = [...]time.Duration{2500, 5000, 10000, 20000}[config%4] * time.Microsecond
+	}
+
+	frames := 1
+	switch toc & 0x03 {
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(packet) < 2 {
+			return 0
+		}
+		frames = int(packet[1] & 0x3f)
+	}
+	duration := time.Duration(frames) * frame
+	if duration > 120*time.Millisecond {
+		return 0 // RFC 6716 caps a packet at 120 ms
+	}
+	return duration
+}
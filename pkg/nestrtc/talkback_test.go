@@ -0,0 +1,155 @@
+package nestrtc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/sdp/v3"
+)
+
+// oggPage builds an Ogg page holding segments, laced as given
+func oggPage(headerType byte, lacing []byte, body []byte) []byte {
+	header := make([]byte, oggHeaderLen)
+	copy(header, "OggS")
+	header[5] = headerType
+	header[26] = byte(len(lacing))
+	return append(append(header, lacing...), body...)
+}
+
+// opusHead is a stereo 48 kHz OpusHead packet
+func opusHead() []byte {
+	return append([]byte("OpusHead"), 1, 2, 0x38, 0x01, 0x80, 0xbb, 0, 0, 0, 0, 0)
+}
+
+func TestOggReader(t *testing.T) {
+	celt20 := []byte{0xf8, 1, 2, 3}            // CELT 20 ms, one frame
+	long := bytes.Repeat([]byte{0xfc, 9}, 150) // 300 bytes, laced 255+45
+	split := bytes.Repeat([]byte{0xf8}, 260)   // Continued onto the next page
+
+	var stream []byte
+	stream = append(stream, oggPage(0x02, []byte{19}, opusHead())...)
+	stream = append(stream, oggPage(0, []byte{16}, []byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00"))...)
+	stream = append(stream, oggPage(0, []byte{4, 255, 45, 255},
+		append(append(append([]byte{}, celt20...), long...), split[:255]...))...)
+	stream = append(stream, oggPage(oggContinued, []byte{5, 4}, append(append([]byte{}, split[255:]...), celt20...))...)
+
+	o := newOggReader(bytes.NewReader(stream))
+	if err := o.readHeaders(); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range [][]byte{celt20, long, split, celt20} {
+		got, err := o.next()
+		if err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("packet %d = %d bytes, want %d", i, len(got), len(want))
+		}
+	}
+	if _, err := o.next(); err != io.EOF {
+		t.Errorf("after the last packet: %v, want EOF", err)
+	}
+}
+
+func TestOggReaderInvalid(t *testing.T) {
+	for name, stream := range map[string][]byte{
+		"empty":     nil,
+		"not ogg":   []byte("RIFF....WAVEfmt chunk of a wav file"),
+		"not opus":  oggPage(0x02, []byte{30}, append([]byte("\x01vorbis"), make([]byte, 23)...)),
+		"truncated": oggPage(0x02, []byte{19}, opusHead())[:30],
+	} {
+		err := newOggReader(bytes.NewReader(stream)).readHeaders()
+		if !errors.Is(err, ErrInvalidTalkback) {
+			t.Errorf("%s: %v, want ErrInvalidTalkback", name, err)
+		}
+	}
+}
+
+func TestOpusDuration(t *testing.T) {
+	for _, tt := range []struct {
+		packet []byte
+		want   time.Duration
+	}{
+		{[]byte{0xf8}, 20 * time.Millisecond},       // CELT 20 ms
+		{[]byte{0xe0}, 2500 * time.Microsecond},     // CELT 2.5 ms
+		{[]byte{0x08}, 20 * time.Millisecond},       // SILK 20 ms
+		{[]byte{0x18}, 60 * time.Millisecond},       // SILK 60 ms
+		{[]byte{0x79}, 40 * time.Millisecond},       // Hybrid 20 ms, two frames
+		{[]byte{0xfb, 0x03}, 60 * time.Millisecond}, // CELT 20 ms, three frames
+		{[]byte{0xfb, 0x07}, 0},                     // 140 ms is too long
+		{[]byte{0xfb}, 0},                           // Frame count missing
+		{nil, 0},
+	} {
+		if got := opusDuration(tt.packet); got != tt.want {
+			t.Errorf("opusDuration(%x) = %v, want %v", tt.packet, got, tt.want)
+		}
+	}
+}
+
+func TestTalkbackOffer(t *testing.T) {
+	r, err := NewReceiver(Config{GatherTimeout: 2 * time.Second, Talkback: true}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	offer, err := r.Offer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sd sdp.SessionDescription
+	if err := sd.UnmarshalString(offer); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"audio", "video", "application"}
+	for i, md := range sd.MediaDescriptions {
+		if i < len(want) && md.MediaName.Media != want[i] {
+			t.Errorf("media %d = %s, want %s", i, md.MediaName.Media, want[i])
+		}
+	}
+	if _, ok := sd.MediaDescriptions[0].Attribute("sendrecv"); !ok {
+		t.Error("talkback audio is not sendrecv")
+	}
+}
+
+func TestTalkWithoutTalkback(t *testing.T) {
+	r, err := NewReceiver(Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if err := r.Talk(context.Background(), strings.NewReader("")); !errors.Is(err, ErrNoTalkback) {
+		t.Errorf("Talk = %v, want ErrNoTalkback", err)
+	}
+}
+
+func TestAnswerTakesAudio(t *testing.T) {
+	answer := func(direction string) string {
+		return "v=0\r\n" +
+			"o=- 0 0 IN IP4 127.0.0.1\r\n" +
+			"s=-\r\n" +
+			"t=0 0\r\n" +
+			"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+			"c=IN IP4 0.0.0.0\r\n" +
+			"a=rtpmap:111 opus/48000/2\r\n" +
+			direction
+	}
+	for direction, want := range map[string]bool{
+		"a=sendrecv\r\n": true,
+		"a=recvonly\r\n": true,
+		"":               true,
+		"a=sendonly\r\n": false,
+		"a=inactive\r\n": false,
+	} {
+		if got := answerTakesAudio(answer(direction)); got != want {
+			t.Errorf("answer %q takes audio = %v, want %v", direction, got, want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Package plugin lets third-party code extend the relay without forking it.
+//
+// Compiled-in plugins implement Plugin plus any of relay.FrameProcessor
+// (observe or rewrite access units), relay.Recorder (observe a copy) and
+// relay.EventHandler (lifecycle events), and call Register from an init
+// function:
+//
+//	func init() { plugin.Register(&detector{}) }
+//
+// Out-of-process plugins are served with Serve and connected with NewRPC;
+// they receive events and keyframes asynchronously.
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+)
+
+// Plugin is a named relay extension
+type Plugin interface {
+	Name() string
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]Plugin)
+)
+
+// Register makes a compiled-in plugin available to every relay service in
+// the process. It panics if the name is already registered, so duplicate
+// imports fail loudly at startup.
+func Register(p Plugin) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, dup := registry[p.Name()]; dup {
+		panic(fmt.Sprintf("plugin: Register called twice for %q", p.Name()))
+	}
+	registry[p.Name()] = p
+}
+
+// Registered returns the registered plugins sorted by name
+func Registered() []Plugin {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	plugins := make([]Plugin, 0, len(registry))
+	for _, p := range registry {
+		plugins = append(plugins, p)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name() < plugins[j].Name() })
+	return plugins
+}
+
+// Attach registers p with every hook it implements and reports whether it
+// implemented any
+func Attach(mcr *relay.MultiCameraRelay, p Plugin) bool {
+	attached := false
+	if fp, ok := p.(relay.FrameProcessor); ok {
+		mcr.AddFrameProcessor(fp)
+		attached = true
+	}
+	if rec, ok := p.(relay.Recorder); ok {
+		mcr.AddRecorder(rec)
+		attached = true
+	}
+	if h, ok := p.(relay.EventHandler); ok {
+		mcr.AddEventHandler(h)
+		attached = true
+	}
+	return attached
+}
@@ -0,0 +1,215 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+)
+
+const (
+	rpcService     = "Plugin"
+	rpcQueueSize   = 64              // Calls buffered before dropping
+	rpcCallTimeout = 5 * time.Second // A plugin slower than this is disconnected
+	rpcRedialDelay = 5 * time.Second // Minimum gap between connection attempts
+	rpcLogEvery    = 100             // Log every Nth dropped call
+)
+
+// Frame is a keyframe delivered to an out-of-process plugin: an H.264 access
+// unit in AVC (length-prefixed) form with SPS/PPS ahead of the IDR slice
+type Frame struct {
+	CameraID  string
+	Timestamp uint32 // 90 kHz RTP timestamp
+	Data      []byte
+}
+
+// Handler is implemented by out-of-process plugins and served with Serve
+type Handler interface {
+	HandleEvent(ev relay.Event) error
+	HandleKeyframe(f Frame) error
+}
+
+// Serve answers relay calls on l until Accept fails. Plugin binaries call it
+// with a unix or TCP listener the relay's plugin_rpc setting points at.
+func Serve(l net.Listener, h Handler) error {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(rpcService, &service{h: h}); err != nil {
+		return fmt.Errorf("register service: %w", err)
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go srv.ServeConn(conn)
+	}
+}
+
+// service adapts Handler to net/rpc's method signature
+type service struct {
+	h Handler
+}
+
+func (s *service) HandleEvent(ev relay.Event, ack *bool) error {
+	*ack = true
+	return s.h.HandleEvent(ev)
+}
+
+func (s *service) HandleKeyframe(f Frame, ack *bool) error {
+	*ack = true
+	return s.h.HandleKeyframe(f)
+}
+
+// rpcCall is one queued call to the plugin process
+type rpcCall struct {
+	method string
+	args   any
+}
+
+// RPC is the relay side of an out-of-process plugin. Calls are queued and
+// made from a single goroutine so a slow or absent plugin never stalls a
+// camera; when the queue is full, calls are dropped.
+type RPC struct {
+	name    string
+	network string
+	addr    string
+	logger  *slog.Logger
+
+	calls   chan rpcCall
+	dropped atomic.Uint64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRPC connects to a plugin served at network/addr (e.g. "unix",
+// "/run/detector.sock"). The connection is made lazily and re-established
+// after failures.
+func NewRPC(network, addr string, logger *slog.Logger) *RPC {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &RPC{
+		name:    network + ":" + addr,
+		network: network,
+		addr:    addr,
+		logger:  logger.With("component", "plugin", "plugin", network+":"+addr),
+		calls:   make(chan rpcCall, rpcQueueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	p.wg.Add(1)
+	go p.run()
+	return p
+}
+
+// Name identifies the plugin by its address
+func (p *RPC) Name() string {
+	return p.name
+}
+
+// HandleEvent forwards a lifecycle event
+func (p *RPC) HandleEvent(ev relay.Event) {
+	p.enqueue(rpcCall{method: rpcService + ".HandleEvent", args: ev})
+}
+
+// RecordVideo forwards keyframes; other frames are not sent over RPC
+func (p *RPC) RecordVideo(cameraID string, au []byte, timestamp uint32, keyframe bool) {
+	if !keyframe {
+		return
+	}
+	p.enqueue(rpcCall{
+		method: rpcService + ".HandleKeyframe",
+		args:   Frame{CameraID: cameraID, Timestamp: timestamp, Data: append([]byte(nil), au...)},
+	})
+}
+
+// RecordAudio is a no-op; audio is not sent to RPC plugins
+func (p *RPC) RecordAudio(string, []byte, uint32) {}
+
+// Close stops delivering calls and disconnects
+func (p *RPC) Close() error {
+	p.cancel()
+	p.wg.Wait()
+	return nil
+}
+
+func (p *RPC) enqueue(c rpcCall) {
+	select {
+	case p.calls <- c:
+	default:
+		if n := p.dropped.Add(1); n%rpcLogEvery == 1 {
+			p.logger.Warn("plugin queue full, dropping calls", "dropped", n)
+		}
+	}
+}
+
+// run delivers queued calls, (re)connecting as needed
+func (p *RPC) run() {
+	defer p.wg.Done()
+
+	var client *rpc.Client
+	var lastDial time.Time
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+	}()
+
+	for {
+		var c rpcCall
+		select {
+		case <-p.ctx.Done():
+			return
+		case c = <-p.calls:
+		}
+
+		if client == nil {
+			if time.Since(lastDial) < rpcRedialDelay {
+				p.dropped.Add(1)
+				continue
+			}
+			lastDial = time.Now()
+
+			conn, err := net.DialTimeout(p.network, p.addr, rpcCallTimeout)
+			if err != nil {
+				p.logger.Warn("plugin unreachable", "error", err)
+				p.dropped.Add(1)
+				continue
+			}
+			client = rpc.NewClient(conn)
+			p.logger.Info("plugin connected")
+		}
+
+		var ack bool
+		var err error
+		call := client.Go(c.method, c.args, &ack, nil)
+		select {
+		case <-call.Done:
+			err = call.Error
+		case <-time.After(rpcCallTimeout):
+			err = fmt.Errorf("timed out after %s", rpcCallTimeout)
+		case <-p.ctx.Done():
+			return
+		}
+
+		var serverErr rpc.ServerError
+		switch {
+		case err == nil:
+		case errors.As(err, &serverErr):
+			// The plugin rejected this call; the connection is still good
+			p.logger.Warn("plugin call failed", "method", c.method, "error", err)
+		default:
+			p.logger.Warn("plugin connection lost", "method", c.method, "error", err)
+			client.Close()
+			client = nil
+		}
+	}
+}
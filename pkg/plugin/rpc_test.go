@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+)
+
+type recordingHandler struct {
+	events    chan relay.Event
+	keyframes chan Frame
+}
+
+func (h *recordingHandler) HandleEvent(ev relay.Event) error {
+	h.events <- ev
+	return nil
+}
+
+func (h *recordingHandler) HandleKeyframe(f Frame) error {
+	h.keyframes <- f
+	return nil
+}
+
+func TestRPCDeliversEventsAndKeyframes(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	h := &recordingHandler{events: make(chan relay.Event, 1), keyframes: make(chan Frame, 1)}
+	go Serve(l, h)
+
+	p := NewRPC("tcp", l.Addr().String(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer p.Close()
+
+	p.HandleEvent(relay.Event{CameraID: "cam1", Type: relay.EventRelayStarted})
+	p.RecordVideo("cam1", []byte{1, 2, 3}, 100, false) // Not a keyframe, not sent
+	p.RecordVideo("cam1", []byte{0, 0, 0, 1, 0x65}, 200, true)
+
+	select {
+	case ev := <-h.events:
+		if ev.CameraID != "cam1" || ev.Type != relay.EventRelayStarted {
+			t.Errorf("event = %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not delivered")
+	}
+
+	select {
+	case f := <-h.keyframes:
+		if f.Timestamp != 200 || len(f.Data) != 5 {
+			t.Errorf("keyframe = %+v", f)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("keyframe not delivered")
+	}
+}
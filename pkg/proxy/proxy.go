@@ -0,0 +1,334 @@
+// Package proxy reaches the Google and Cloudflare APIs and RTSP servers
+// through an outbound HTTP CONNECT or SOCKS5 proxy, for deployments whose
+// network only lets traffic out that way. API clients take it as their
+// transport's Proxy func; the RTSP client dials through DialContext.
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dialTimeout bounds reaching the proxy and its handshake
+const dialTimeout = 10 * time.Second
+
+// Proxy is an outbound proxy and the hosts that bypass it. A nil *Proxy
+// connects directly.
+type Proxy struct {
+	url    *url.URL
+	bypass []string // Hosts, .domain suffixes and CIDRs reached directly
+	dialer net.Dialer
+}
+
+// Parse parses a proxy URL — http://, https:// (TLS to the proxy),
+// socks5:// or socks5h:// with optional user:password — and a
+// comma-separated list of hosts, .domain suffixes and CIDRs that bypass it,
+// as NO_PROXY lists them. An empty URL is no proxy, and nil.
+func Parse(rawURL, bypass string) (*Proxy, error) {
+	if rawURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (want http, https, socks5 or socks5h)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", rawURL)
+	}
+
+	p := &Proxy{
+		url:    u,
+		dialer: net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second},
+	}
+	for _, h := range strings.Split(bypass, ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			if _, _, err := net.ParseCIDR(h); err != nil && strings.Contains(h, "/") {
+				return nil, fmt.Errorf("invalid proxy bypass %q: %w", h, err)
+			}
+			p.bypass = append(p.bypass, h)
+		}
+	}
+	return p, nil
+}
+
+// String is the proxy URL without its password, for logs
+func (p *Proxy) String() string {
+	if p == nil {
+		return "direct"
+	}
+	return p.url.Redacted()
+}
+
+// Bypassed reports whether host is reached directly
+func (p *Proxy) Bypassed(host string) bool {
+	if p == nil {
+		return true
+	}
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, b := range p.bypass {
+		switch {
+		case b == "*" || b == host:
+			return true
+		case strings.HasPrefix(b, "."):
+			if strings.HasSuffix(host, b) || host == b[1:] {
+				return true
+			}
+		case strings.Contains(b, "/"):
+			if _, n, _ := net.ParseCIDR(b); ip != nil && n.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HTTPProxy is an http.Transport Proxy func: the proxy URL, or nil for
+// bypassed hosts
+func (p *Proxy) HTTPProxy(req *http.Request) (*url.URL, error) {
+	if p.Bypassed(req.URL.Hostname()) {
+		return nil, nil
+	}
+	return p.url, nil
+}
+
+// DialContext connects to addr through the proxy, or directly for bypassed
+// hosts. Only TCP can be proxied.
+func (p *Proxy) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if p.Bypassed(host) {
+		var d net.Dialer
+		if p != nil {
+			d = p.dialer
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+	if network != "tcp" && network != "tcp4" && network != "tcp6" {
+		return nil, fmt.Errorf("proxy: cannot dial %s", network)
+	}
+
+	conn, err := p.dialProxy(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("proxy %s: %w", p, err)
+	}
+	// The handshake is bounded by ctx as well as dialTimeout
+	deadline := time.Now().Add(dialTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	if strings.HasPrefix(p.url.Scheme, "socks5") {
+		err = p.socks5Connect(conn, addr)
+	} else {
+		conn, err = p.httpConnect(conn, addr)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %w", p, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// dialProxy connects to the proxy itself
+func (p *Proxy) dialProxy(ctx context.Context) (net.Conn, error) {
+	port := p.url.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[p.url.Scheme]
+		if port == "" {
+			port = "1080"
+		}
+	}
+	addr := net.JoinHostPort(p.url.Hostname(), port)
+	if p.url.Scheme == "https" {
+		d := tls.Dialer{NetDialer: &p.dialer, Config: &tls.Config{ServerName: p.url.Hostname()}}
+		return d.DialContext(ctx, "tcp", addr)
+	}
+	return p.dialer.DialContext(ctx, "tcp", addr)
+}
+
+// httpConnect opens a tunnel to addr with CONNECT
+func (p *Proxy) httpConnect(conn net.Conn, addr string) (net.Conn, error) {
+	req := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr)
+	if u := p.url.User; u != nil {
+		password, _ := u.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req += "Proxy-Authorization: Basic " + auth + "\r\n"
+	}
+	if _, err := io.WriteString(conn, req+"\r\n"); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CONNECT %s: %s", addr, resp.Status)
+	}
+	if r.Buffered() > 0 {
+		// The server spoke first; keep what the reader already took
+		return &bufferedConn{Conn: conn, r: r}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn reads what a handshake's reader buffered before the rest
+// of the connection
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) { return c.r.Read(b) }
+
+// SOCKS5 protocol values (RFC 1928, RFC 1929)
+const (
+	socks5Version      = 5
+	socks5NoAuth       = 0
+	socks5PasswordAuth = 2
+	socks5Connect      = 1
+	socks5IPv4         = 1
+	socks5Domain       = 3
+	socks5IPv6         = 4
+)
+
+// socks5Connect asks a SOCKS5 proxy to connect to addr. Host names are
+// resolved by the proxy, which may know names this host can't.
+func (p *Proxy) socks5Connect(conn net.Conn, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", portStr)
+	}
+
+	methods := []byte{socks5NoAuth}
+	if p.url.User != nil {
+		methods = append(methods, socks5PasswordAuth)
+	}
+	if _, err := conn.Write(append([]byte{socks5Version, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("not a SOCKS5 proxy")
+	}
+	switch reply[1] {
+	case socks5NoAuth:
+	case socks5PasswordAuth:
+		if p.url.User == nil {
+			return errors.New("SOCKS5 proxy requires a username and password")
+		}
+		user := p.url.User.Username()
+		password, _ := p.url.User.Password()
+		if len(user) > 255 || len(password) > 255 {
+			return errors.New("SOCKS5 username or password too long")
+		}
+		auth := append([]byte{1, byte(len(user))}, user...)
+		auth = append(append(auth, byte(len(password))), password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return errors.New("SOCKS5 authentication failed")
+		}
+	default:
+		return errors.New("SOCKS5 proxy accepts none of our authentication methods")
+	}
+
+	req := []byte{socks5Version, socks5Connect, 0}
+	if ip := net.ParseIP(host); ip.To4() != nil {
+		req = append(append(req, socks5IPv4), ip.To4()...)
+	} else if ip != nil {
+		req = append(append(req, socks5IPv6), ip.To16()...)
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("host name %q too long", host)
+		}
+		req = append(append(req, socks5Domain, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	// Reply: version, status, reserved, then the bound address
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return err
+	}
+	if head[1] != 0 {
+		return fmt.Errorf("SOCKS5 connect to %s failed: %s", addr, socks5Status(head[1]))
+	}
+	var skip int
+	switch head[3] {
+	case socks5IPv4:
+		skip = net.IPv4len
+	case socks5IPv6:
+		skip = net.IPv6len
+	case socks5Domain:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return err
+		}
+		skip = int(n[0])
+	default:
+		return fmt.Errorf("SOCKS5 reply with address type %d", head[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip+2)) // Bound address and port
+	return err
+}
+
+// socks5Status describes a SOCKS5 reply code
+func socks5Status(code byte) string {
+	switch code {
+	case 1:
+		return "general failure"
+	case 2:
+		return "not allowed by ruleset"
+	case 3:
+		return "network unreachable"
+	case 4:
+		return "host unreachable"
+	case 5:
+		return "connection refused"
+	case 6:
+		return "TTL expired"
+	case 7:
+		return "command not supported"
+	case 8:
+		return "address type not supported"
+	}
+	return fmt.Sprintf("code %d", code)
+}
@@ -0,0 +1,198 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoServer answers each connection by echoing it back
+func echoServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() { io.Copy(conn, conn); conn.Close() }()
+		}
+	}()
+	return ln
+}
+
+// proxyServer runs handshake on each connection, then splices it to the
+// address the handshake returned
+func proxyServer(t *testing.T, handshake func(conn net.Conn, r *bufio.Reader) string) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				addr := handshake(conn, r)
+				if addr == "" {
+					return
+				}
+				target, err := net.Dial("tcp", addr)
+				if err != nil {
+					return
+				}
+				defer target.Close()
+				go io.Copy(target, r)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+	return ln
+}
+
+func roundTrip(t *testing.T, p *Proxy, addr string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := p.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatalf("DialContext: %v", err)
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, "ping"); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("echo %q, %v", buf, err)
+	}
+}
+
+func TestHTTPConnect(t *testing.T) {
+	echo := echoServer(t)
+	defer echo.Close()
+
+	auth := make(chan string, 1)
+	ln := proxyServer(t, func(conn net.Conn, r *bufio.Reader) string {
+		req, err := http.ReadRequest(r)
+		if err != nil || req.Method != http.MethodConnect {
+			return ""
+		}
+		auth <- req.Header.Get("Proxy-Authorization")
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		return req.Host
+	})
+	defer ln.Close()
+
+	p, err := Parse("http://user:secret@"+ln.Addr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, p, echo.Addr().String())
+	if got := <-auth; got != "Basic dXNlcjpzZWNyZXQ=" {
+		t.Errorf("Proxy-Authorization = %q", got)
+	}
+	if s := p.String(); strings.Contains(s, "secret") {
+		t.Errorf("String() leaks the password: %s", s)
+	}
+}
+
+func TestSOCKS5(t *testing.T) {
+	echo := echoServer(t)
+	defer echo.Close()
+
+	ln := proxyServer(t, func(conn net.Conn, r *bufio.Reader) string {
+		head := make([]byte, 2)
+		io.ReadFull(r, head)
+		io.ReadFull(r, make([]byte, head[1]))
+		conn.Write([]byte{5, socks5PasswordAuth})
+
+		// Username/password subnegotiation
+		io.ReadFull(r, head)
+		user := make([]byte, head[1])
+		io.ReadFull(r, user)
+		n, _ := r.ReadByte()
+		password := make([]byte, n)
+		io.ReadFull(r, password)
+		if string(user) != "user" || string(password) != "secret" {
+			conn.Write([]byte{1, 1})
+			return ""
+		}
+		conn.Write([]byte{1, 0})
+
+		req := make([]byte, 4)
+		io.ReadFull(r, req)
+		if req[3] != socks5Domain {
+			return ""
+		}
+		n, _ = r.ReadByte()
+		host := make([]byte, n)
+		io.ReadFull(r, host)
+		port := make([]byte, 2)
+		io.ReadFull(r, port)
+		conn.Write([]byte{5, 0, 0, socks5IPv4, 0, 0, 0, 0, 0, 0})
+		if string(host) != "localhost" {
+			return ""
+		}
+		return net.JoinHostPort("127.0.0.1", strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	})
+	defer ln.Close()
+
+	p, err := Parse("socks5://user:secret@"+ln.Addr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(echo.Addr().String())
+	roundTrip(t, p, net.JoinHostPort("localhost", port))
+}
+
+func TestBypass(t *testing.T) {
+	p, err := Parse("http://proxy:3128", "localhost, .lan,10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for host, want := range map[string]bool{
+		"localhost":          true,
+		"camera.lan":         true,
+		"lan":                true,
+		"10.1.2.3":           true,
+		"11.1.2.3":           false,
+		"www.googleapis.com": false,
+	} {
+		if got := p.Bypassed(host); got != want {
+			t.Errorf("Bypassed(%q) = %v, want %v", host, got, want)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://www.googleapis.com/", nil)
+	if u, _ := p.HTTPProxy(req); u == nil || u.Host != "proxy:3128" {
+		t.Errorf("HTTPProxy = %v", u)
+	}
+	var direct *Proxy
+	if u, _ := direct.HTTPProxy(req); u != nil {
+		t.Errorf("nil proxy routes through %v", u)
+	}
+
+	for _, bad := range []string{"ftp://proxy", "http://", "http://proxy:port"} {
+		if _, err := Parse(bad, ""); err == nil {
+			t.Errorf("Parse(%q) accepted", bad)
+		}
+	}
+	if _, err := Parse("http://proxy", "10.0.0.0/33"); err == nil {
+		t.Error("Parse accepted an invalid CIDR")
+	}
+}
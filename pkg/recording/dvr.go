@@ -0,0 +1,279 @@
+package recording
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/membudget"
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+	"github.com/ethan/nest-cloudflare-relay/pkg/tsmux"
+)
+
+const (
+	// DefaultDVRWindow is how much footage a DVR keeps when unconfigured
+	DefaultDVRWindow = 2 * time.Minute
+
+	// DefaultDVRSegment is the target length of in-memory HLS segments
+	DefaultDVRSegment = 2 * time.Second
+)
+
+// DVRConfig sizes the in-memory rewind buffer
+type DVRConfig struct {
+	Window          time.Duration
+	SegmentDuration time.Duration
+	Budget          *membudget.Budget // Segments are charged to each camera's account; nil is unlimited
+}
+
+// DVR keeps a rolling window of MPEG-TS segments per camera in memory so
+// viewers can rewind briefly without recording to disk. Muxing is cheap and
+// never touches I/O, so frames are processed inline on the relay's read
+// goroutine under a per-camera lock.
+type DVR struct {
+	window      time.Duration
+	segDuration time.Duration
+	budget      *membudget.Budget
+
+	mu      sync.RWMutex
+	cameras map[string]*dvrCamera
+}
+
+// DVRSegment describes a buffered segment on the timeline
+type DVRSegment struct {
+	Sequence uint64
+	Start    time.Time
+	Duration time.Duration
+	Size     int
+}
+
+// dvrCamera is one camera's ring of completed segments plus the segment
+// currently being written
+type dvrCamera struct {
+	mu       sync.RWMutex
+	memory   *membudget.Account
+	info     relay.MediaInfo
+	segments []*dvrSegment // Oldest first
+	nextSeq  uint64
+
+	cur     *dvrSegment
+	mux     *tsmux.Muxer
+	startTS uint32
+}
+
+type dvrSegment struct {
+	DVRSegment
+	data bytes.Buffer
+}
+
+// NewDVR creates an empty DVR
+func NewDVR(cfg DVRConfig) *DVR {
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultDVRWindow
+	}
+	if cfg.SegmentDuration <= 0 {
+		cfg.SegmentDuration = DefaultDVRSegment
+	}
+	return &DVR{
+		window:      cfg.Window,
+		segDuration: cfg.SegmentDuration,
+		budget:      cfg.Budget,
+		cameras:     make(map[string]*dvrCamera),
+	}
+}
+
+// RecordMediaInfo stores codec parameters; the next segment uses them
+func (d *DVR) RecordMediaInfo(cameraID string, info relay.MediaInfo) {
+	c := d.camera(cameraID, true)
+	c.mu.Lock()
+	c.info = info
+	c.abandon() // Restart at the next keyframe with the new codecs
+	c.mu.Unlock()
+}
+
+// RecordVideo appends an H.264 access unit, cutting a segment on keyframes
+func (d *DVR) RecordVideo(cameraID string, au []byte, timestamp uint32, keyframe bool) {
+	c := d.camera(cameraID, true)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if keyframe && c.shouldCut(timestamp, d.segDuration) {
+		c.finish(timestamp)
+		c.start(timestamp)
+		c.trim(d.window)
+	}
+	if c.cur == nil {
+		return
+	}
+
+	c.write(c.mux.Video(au, timestamp))
+}
+
+// RecordAudio appends an AAC access unit to the current segment
+func (d *DVR) RecordAudio(cameraID string, data []byte, timestamp uint32) {
+	c := d.camera(cameraID, false)
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cur != nil {
+		c.write(c.mux.Audio(data, timestamp))
+	}
+}
+
+// Timeline lists a camera's completed segments, oldest first
+func (d *DVR) Timeline(cameraID string) []DVRSegment {
+	c := d.camera(cameraID, false)
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	timeline := make([]DVRSegment, len(c.segments))
+	for i, seg := range c.segments {
+		timeline[i] = seg.DVRSegment
+	}
+	return timeline
+}
+
+// Segment returns the MPEG-TS bytes of a buffered segment
+func (d *DVR) Segment(cameraID string, seq uint64) ([]byte, bool) {
+	c := d.camera(cameraID, false)
+	if c == nil {
+		return nil, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, seg := range c.segments {
+		if seg.Sequence == seq {
+			// Completed segments are never written again, so sharing is safe
+			return seg.data.Bytes(), true
+		}
+	}
+	return nil, false
+}
+
+// Playlist renders a sliding-window HLS media playlist covering the whole
+// buffer. Segment URIs are "<sequence>.ts", relative to the playlist.
+func (d *DVR) Playlist(cameraID string) ([]byte, bool) {
+	timeline := d.Timeline(cameraID)
+	if len(timeline) == 0 {
+		return nil, false
+	}
+
+	target := 1.0
+	for _, seg := range timeline {
+		target = math.Max(target, math.Ceil(seg.Duration.Seconds()))
+	}
+
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(target))
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", timeline[0].Sequence)
+	for _, seg := range timeline {
+		fmt.Fprintf(&b, "#EXT-X-PROGRAM-DATE-TIME:%s\n", seg.Start.UTC().Format("2006-01-02T15:04:05.000Z"))
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%d.ts\n", seg.Duration.Seconds(), seg.Sequence)
+	}
+	return b.Bytes(), true
+}
+
+// camera returns the state for a camera, creating it if asked
+func (d *DVR) camera(cameraID string, create bool) *dvrCamera {
+	d.mu.RLock()
+	c := d.cameras[cameraID]
+	d.mu.RUnlock()
+	if c != nil || !create {
+		return c
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if c = d.cameras[cameraID]; c == nil {
+		c = &dvrCamera{memory: d.budget.Account(cameraID)}
+		d.cameras[cameraID] = c
+	}
+	return c
+}
+
+// shouldCut reports whether a keyframe at ts should begin a new segment
+func (c *dvrCamera) shouldCut(ts uint32, target time.Duration) bool {
+	if c.cur == nil {
+		return true
+	}
+	elapsed := time.Duration(int32(ts-c.startTS)) * time.Second / 90000
+	return elapsed >= target || elapsed < 0
+}
+
+// start opens a new in-progress segment at ts
+func (c *dvrCamera) start(ts uint32) {
+	c.cur = &dvrSegment{DVRSegment: DVRSegment{
+		Sequence: c.nextSeq,
+		Start:    time.Now(),
+	}}
+	c.nextSeq++
+	c.mux = tsmux.New(c.info)
+	c.startTS = ts
+	c.write(c.mux.Header())
+}
+
+// write appends muxed bytes to the in-progress segment. When the camera's
+// memory account is full the oldest segments are evicted early, ahead of
+// the window; with nothing left to evict the segment is abandoned until the
+// next keyframe.
+func (c *dvrCamera) write(p []byte) {
+	for !c.memory.Reserve(len(p)) {
+		if len(c.segments) == 0 {
+			c.abandon()
+			return
+		}
+		c.evictOldest()
+	}
+	c.cur.data.Write(p)
+}
+
+// abandon drops the in-progress segment
+func (c *dvrCamera) abandon() {
+	if c.cur != nil {
+		c.memory.Release(c.cur.data.Len())
+	}
+	c.cur, c.mux = nil, nil
+}
+
+// evictOldest drops the oldest completed segment
+func (c *dvrCamera) evictOldest() {
+	c.memory.Release(c.segments[0].Size)
+	c.segments[0] = nil
+	c.segments = c.segments[1:]
+}
+
+// finish moves the in-progress segment, which ends at the keyframe at ts,
+// onto the timeline
+func (c *dvrCamera) finish(ts uint32) {
+	if c.cur == nil {
+		return
+	}
+	c.cur.Duration = time.Since(c.cur.Start)
+	if d := time.Duration(int32(ts-c.startTS)) * time.Second / 90000; d > 0 && d < 2*c.cur.Duration {
+		c.cur.Duration = d // Prefer media time unless the RTSP session restarted
+	}
+	c.cur.Size = c.cur.data.Len()
+	c.segments = append(c.segments, c.cur)
+	c.cur = nil
+}
+
+// trim drops the oldest segments once the buffer exceeds the window
+func (c *dvrCamera) trim(window time.Duration) {
+	var total time.Duration
+	for _, seg := range c.segments {
+		total += seg.Duration
+	}
+	for len(c.segments) > 1 && total > window {
+		total -= c.segments[0].Duration
+		c.evictOldest()
+	}
+}
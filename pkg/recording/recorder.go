@@ -0,0 +1,339 @@
+// Package recording writes relayed camera media to local MPEG-TS segments
+// and optionally ships closed segments to S3-compatible object storage.
+//
+// A Recorder is a relay.MediaInfoRecorder: frames are copied off the relay's
+// read goroutine into a bounded per-camera queue and written by a dedicated
+// goroutine, so slow disks never stall the WebRTC path. Segments are cut on
+// keyframes once the target duration has elapsed, written as .ts.part and
+// renamed to .ts when complete.
+package recording
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+	"github.com/ethan/nest-cloudflare-relay/pkg/tsmux"
+)
+
+const (
+	// DefaultSegmentDuration is used when Config.SegmentDuration is zero
+	DefaultSegmentDuration = time.Minute
+
+	queueSize      = 512        // Frames buffered per camera before dropping
+	segmentExt     = ".ts"      // Extension of completed segments
+	partialExt     = ".ts.part" // Extension while a segment is being written
+	filenameLayout = "20060102T150405Z"
+)
+
+// Config controls where and how segments are written
+type Config struct {
+	Dir             string
+	SegmentDuration time.Duration
+}
+
+// Segment describes a completed segment file
+type Segment struct {
+	CameraID string
+	Path     string
+	Start    time.Time
+	Duration time.Duration
+	Size     int64
+}
+
+// Recorder records every camera it receives frames for
+type Recorder struct {
+	dir         string
+	segDuration time.Duration
+	logger      *slog.Logger
+
+	// OnSegment is called from the camera's writer goroutine after each
+	// segment is closed. Set it before frames start flowing.
+	OnSegment func(Segment)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	cameras map[string]*camera
+}
+
+// frameKind distinguishes queued items
+type frameKind int
+
+const (
+	kindVideo frameKind = iota
+	kindAudio
+	kindInfo
+)
+
+// frame is a copy of one access unit (or a codec update) queued for the writer
+type frame struct {
+	kind      frameKind
+	data      []byte
+	timestamp uint32
+	keyframe  bool
+	info      relay.MediaInfo
+}
+
+// camera is the recording state for one camera
+type camera struct {
+	id      string
+	dir     string
+	logger  *slog.Logger
+	frames  chan frame
+	dropped atomic.Uint64
+
+	// Owned by the writer goroutine
+	info    relay.MediaInfo
+	file    *os.File
+	buf     *bufio.Writer
+	mux     *tsmux.Muxer
+	path    string
+	start   time.Time
+	startTS uint32
+	lastTS  uint32
+	size    int64
+	reopen  bool // Codec parameters changed; cut at the next keyframe
+}
+
+// NewRecorder creates a recorder writing below cfg.Dir
+func NewRecorder(cfg Config, logger *slog.Logger) (*Recorder, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("recording directory not set")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create recording directory: %w", err)
+	}
+	if cfg.SegmentDuration <= 0 {
+		cfg.SegmentDuration = DefaultSegmentDuration
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Recorder{
+		dir:         cfg.Dir,
+		segDuration: cfg.SegmentDuration,
+		logger:      logger,
+		ctx:         ctx,
+		cancel:      cancel,
+		cameras:     make(map[string]*camera),
+	}, nil
+}
+
+// Close stops all writers and finalizes any open segments
+func (r *Recorder) Close() error {
+	r.cancel()
+	r.wg.Wait()
+	return nil
+}
+
+// RecordMediaInfo queues a codec update; the next segment uses it
+func (r *Recorder) RecordMediaInfo(cameraID string, info relay.MediaInfo) {
+	r.enqueue(cameraID, frame{kind: kindInfo, info: info}, nil)
+}
+
+// RecordVideo queues an H.264 access unit
+func (r *Recorder) RecordVideo(cameraID string, au []byte, timestamp uint32, keyframe bool) {
+	r.enqueue(cameraID, frame{kind: kindVideo, timestamp: timestamp, keyframe: keyframe}, au)
+}
+
+// RecordAudio queues an AAC access unit
+func (r *Recorder) RecordAudio(cameraID string, data []byte, timestamp uint32) {
+	r.enqueue(cameraID, frame{kind: kindAudio, timestamp: timestamp}, data)
+}
+
+// enqueue copies the payload and hands it to the camera's writer without blocking
+func (r *Recorder) enqueue(cameraID string, f frame, data []byte) {
+	c := r.camera(cameraID)
+	if c == nil {
+		return
+	}
+
+	if data != nil {
+		f.data = append([]byte(nil), data...)
+	}
+	select {
+	case c.frames <- f:
+	default:
+		if n := c.dropped.Add(1); n%100 == 1 {
+			c.logger.Warn("recording queue full, dropping frames", "dropped", n)
+		}
+	}
+}
+
+// camera returns the writer for a camera, starting it on first use
+func (r *Recorder) camera(cameraID string) *camera {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ctx.Err() != nil {
+		return nil
+	}
+
+	c, ok := r.cameras[cameraID]
+	if !ok {
+		c = &camera{
+			id:     cameraID,
+			dir:    filepath.Join(r.dir, safeName(cameraID)),
+			logger: r.logger.With("camera_id", cameraID),
+			frames: make(chan frame, queueSize),
+		}
+		r.cameras[cameraID] = c
+
+		r.wg.Add(1)
+		go r.run(c)
+	}
+	return c
+}
+
+// run writes one camera's frames until Close
+func (r *Recorder) run(c *camera) {
+	defer r.wg.Done()
+	defer r.closeSegment(c)
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case f := <-c.frames:
+			if err := r.write(c, f); err != nil {
+				c.logger.Error("recording write failed, waiting for next keyframe", "error", err)
+				r.closeSegment(c)
+			}
+		}
+	}
+}
+
+// write handles one queued item, rotating segments on keyframes
+func (r *Recorder) write(c *camera, f frame) error {
+	switch f.kind {
+	case kindInfo:
+		c.info = f.info
+		c.reopen = true
+		return nil
+
+	case kindVideo:
+		if f.keyframe && r.shouldRotate(c, f.timestamp) {
+			r.closeSegment(c)
+			if err := r.openSegment(c, f.timestamp); err != nil {
+				return err
+			}
+		}
+		if c.file == nil {
+			return nil // Wait for a keyframe
+		}
+		c.lastTS = f.timestamp
+		return c.writeBytes(c.mux.Video(f.data, f.timestamp))
+
+	case kindAudio:
+		if c.file == nil {
+			return nil
+		}
+		return c.writeBytes(c.mux.Audio(f.data, f.timestamp))
+	}
+	return nil
+}
+
+// shouldRotate reports whether a keyframe at ts should start a new segment
+func (r *Recorder) shouldRotate(c *camera, ts uint32) bool {
+	if c.file == nil || c.reopen {
+		return true
+	}
+	elapsed := time.Duration(int32(ts-c.startTS)) * time.Second / 90000
+	// Negative or very large jumps mean the RTSP session restarted
+	return elapsed >= r.segDuration || elapsed < 0 || elapsed > 2*r.segDuration
+}
+
+// openSegment starts a new .ts.part file for the camera
+func (r *Recorder) openSegment(c *camera, ts uint32) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("create camera directory: %w", err)
+	}
+
+	start := time.Now().UTC()
+	path := filepath.Join(c.dir, start.Format(filenameLayout)+partialExt)
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create segment: %w", err)
+	}
+
+	c.file = file
+	c.buf = bufio.NewWriterSize(file, 256*1024)
+	c.mux = tsmux.New(c.info)
+	c.path = path
+	c.start = start
+	c.startTS = ts
+	c.lastTS = ts
+	c.size = 0
+	c.reopen = false
+
+	return c.writeBytes(c.mux.Header())
+}
+
+// closeSegment flushes and renames the current segment and reports it
+func (r *Recorder) closeSegment(c *camera) {
+	if c.file == nil {
+		return
+	}
+
+	file, path := c.file, c.path
+	err := c.buf.Flush()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	c.file, c.buf, c.mux = nil, nil, nil
+
+	if err != nil {
+		c.logger.Error("failed to close segment", "path", path, "error", err)
+		return
+	}
+
+	final := strings.TrimSuffix(path, partialExt) + segmentExt
+	if err := os.Rename(path, final); err != nil {
+		c.logger.Error("failed to finalize segment", "path", path, "error", err)
+		return
+	}
+
+	seg := Segment{
+		CameraID: c.id,
+		Path:     final,
+		Start:    c.start,
+		Duration: time.Duration(int32(c.lastTS-c.startTS)) * time.Second / 90000,
+		Size:     c.size,
+	}
+	c.logger.Debug("segment closed", "path", final, "duration", seg.Duration, "bytes", seg.Size)
+
+	if r.OnSegment != nil {
+		r.OnSegment(seg)
+	}
+}
+
+// writeBytes appends muxed packets to the open segment
+func (c *camera) writeBytes(b []byte) error {
+	n, err := c.buf.Write(b)
+	c.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write segment: %w", err)
+	}
+	return nil
+}
+
+// safeName makes a camera ID usable as a directory name
+func safeName(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' {
+			return '_'
+		}
+		return r
+	}, id)
+}
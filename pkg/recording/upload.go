@@ -0,0 +1,295 @@
+package recording
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	uploadedExt      = ".uploaded"      // Marker written next to archived segments
+	minUploadBackoff = 5 * time.Second  // First retry delay after a failed upload
+	maxUploadBackoff = 5 * time.Minute  // Retry delay cap
+	janitorInterval  = time.Minute      // How often expired local copies are removed
+	uploadPutTimeout = 10 * time.Minute // Upper bound for a single PUT
+)
+
+// UploaderConfig controls how segments are archived
+type UploaderConfig struct {
+	Prefix string // Object key prefix, e.g. "nest/"
+
+	// LocalRetention keeps local copies this long after a successful upload.
+	// Zero deletes them immediately.
+	LocalRetention time.Duration
+}
+
+// Uploader archives closed segments to an ObjectStore. Segments stay on disk
+// until their upload succeeds, so nothing is lost across restarts or outages:
+// on startup every .ts file without an .uploaded marker is queued again.
+type Uploader struct {
+	store  ObjectStore
+	dir    string
+	cfg    UploaderConfig
+	logger *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	pending []string // Segment paths, oldest first
+	queued  map[string]bool
+	notify  chan struct{}
+}
+
+// NewUploader creates an uploader for segments below dir and starts it
+func NewUploader(store ObjectStore, dir string, cfg UploaderConfig, logger *slog.Logger) *Uploader {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	u := &Uploader{
+		store:  store,
+		dir:    dir,
+		cfg:    cfg,
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+		queued: make(map[string]bool),
+		notify: make(chan struct{}, 1),
+	}
+
+	u.scan()
+
+	u.wg.Add(2)
+	go u.run()
+	go u.janitor()
+
+	return u
+}
+
+// Close stops uploading. Segments still queued are picked up on next start.
+func (u *Uploader) Close() error {
+	u.cancel()
+	u.wg.Wait()
+	return nil
+}
+
+// Enqueue schedules a closed segment for upload. It matches Recorder.OnSegment.
+func (u *Uploader) Enqueue(seg Segment) {
+	u.add(seg.Path)
+}
+
+// Pending returns the number of segments waiting to be uploaded
+func (u *Uploader) Pending() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.pending)
+}
+
+func (u *Uploader) add(p string) {
+	u.mu.Lock()
+	if !u.queued[p] {
+		u.queued[p] = true
+		u.pending = append(u.pending, p)
+	}
+	u.mu.Unlock()
+
+	select {
+	case u.notify <- struct{}{}:
+	default:
+	}
+}
+
+// scan queues segments left over from previous runs
+func (u *Uploader) scan() {
+	var found []string
+	filepath.WalkDir(u.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, segmentExt) {
+			return nil
+		}
+		if _, err := os.Stat(p + uploadedExt); err == nil {
+			return nil
+		}
+		found = append(found, p)
+		return nil
+	})
+
+	// Filenames are timestamps, so lexical order uploads oldest first per camera
+	sort.Strings(found)
+	for _, p := range found {
+		u.add(p)
+	}
+
+	if len(found) > 0 {
+		u.logger.Info("queued segments from previous run", "count", len(found))
+	}
+}
+
+// run uploads queued segments one at a time, backing off on failure
+func (u *Uploader) run() {
+	defer u.wg.Done()
+
+	backoff := minUploadBackoff
+	for {
+		p, ok := u.next()
+		if !ok {
+			select {
+			case <-u.notify:
+				continue
+			case <-u.ctx.Done():
+				return
+			}
+		}
+
+		if err := u.upload(p); err != nil {
+			if u.ctx.Err() != nil {
+				return
+			}
+			u.logger.Error("segment upload failed, retrying", "path", p, "error", err, "backoff", backoff)
+
+			select {
+			case <-time.After(backoff):
+			case <-u.ctx.Done():
+				return
+			}
+
+			backoff *= 2
+			if backoff > maxUploadBackoff {
+				backoff = maxUploadBackoff
+			}
+			continue
+		}
+
+		backoff = minUploadBackoff
+		u.done(p)
+	}
+}
+
+// next returns the oldest pending segment without removing it
+func (u *Uploader) next() (string, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.pending) == 0 {
+		return "", false
+	}
+	return u.pending[0], true
+}
+
+// done removes an uploaded segment from the queue
+func (u *Uploader) done(p string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.pending) > 0 && u.pending[0] == p {
+		u.pending = u.pending[1:]
+	}
+	delete(u.queued, p)
+}
+
+// upload sends one segment and applies the local retention policy
+func (u *Uploader) upload(p string) error {
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil // Removed by hand; nothing to archive
+	}
+	if err != nil {
+		return fmt.Errorf("open segment: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat segment: %w", err)
+	}
+
+	key, err := u.objectKey(p)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(u.ctx, uploadPutTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := u.store.Put(ctx, key, f, info.Size()); err != nil {
+		return err
+	}
+
+	u.logger.Info("segment uploaded",
+		"key", key,
+		"bytes", info.Size(),
+		"duration", time.Since(start).Round(time.Millisecond))
+
+	if u.cfg.LocalRetention <= 0 {
+		if err := os.Remove(p); err != nil {
+			u.logger.Warn("failed to remove uploaded segment", "path", p, "error", err)
+		}
+		return nil
+	}
+
+	// The marker's mtime records when the upload finished
+	if err := os.WriteFile(p+uploadedExt, nil, 0o644); err != nil {
+		u.logger.Warn("failed to mark segment uploaded", "path", p, "error", err)
+	}
+	return nil
+}
+
+// objectKey maps a local segment path to prefix/<camera>/<file>
+func (u *Uploader) objectKey(p string) (string, error) {
+	rel, err := filepath.Rel(u.dir, p)
+	if err != nil {
+		return "", fmt.Errorf("segment outside recording directory: %w", err)
+	}
+	return path.Join(u.cfg.Prefix, filepath.ToSlash(rel)), nil
+}
+
+// janitor deletes local copies whose retention has expired
+func (u *Uploader) janitor() {
+	defer u.wg.Done()
+
+	if u.cfg.LocalRetention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-u.ctx.Done():
+			return
+		case <-ticker.C:
+			u.expire()
+		}
+	}
+}
+
+// expire removes uploaded segments older than the retention period
+func (u *Uploader) expire() {
+	cutoff := time.Now().Add(-u.cfg.LocalRetention)
+
+	filepath.WalkDir(u.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, uploadedExt) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+
+		segment := strings.TrimSuffix(p, uploadedExt)
+		if err := os.Remove(segment); err != nil && !os.IsNotExist(err) {
+			u.logger.Warn("failed to remove expired segment", "path", segment, "error", err)
+			return nil
+		}
+		os.Remove(p)
+		u.logger.Debug("removed expired local segment", "path", segment)
+		return nil
+	})
+}
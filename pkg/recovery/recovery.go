@@ -0,0 +1,38 @@
+// Package recovery turns panics in per-camera goroutines into errors, so one
+// malformed stream restarts its own camera instead of crashing the process
+// and every other relay with it.
+package recovery
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is a recovered panic with the stack of the goroutine it hit
+type PanicError struct {
+	Goroutine string // Which loop panicked, e.g. "relay.readLoop"
+	Value     any    // The value passed to panic
+	Stack     []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in %s: %v", e.Goroutine, e.Value)
+}
+
+// Recover must be deferred directly by the goroutine it protects:
+//
+//	defer recovery.Recover("relay.readLoop", r.panicked)
+//
+// A panic stops the goroutine as usual, but instead of crashing the process
+// it is passed to handle. Deferred calls registered before Recover (such as
+// wg.Done) still run.
+func Recover(goroutine string, handle func(*PanicError)) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	err := &PanicError{Goroutine: goroutine, Value: v, Stack: debug.Stack()}
+	if handle != nil {
+		handle(err)
+	}
+}
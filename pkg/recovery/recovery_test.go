@@ -0,0 +1,30 @@
+package recovery
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	var got *PanicError
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer Recover("test.loop", func(err *PanicError) { got = err })
+		var m map[string]int
+		m["boom"]++ // Nil map write
+	}()
+	wg.Wait()
+
+	if got == nil {
+		t.Fatal("panic was not recovered")
+	}
+	if got.Goroutine != "test.loop" || !strings.Contains(got.Error(), "nil map") {
+		t.Errorf("err = %v", got)
+	}
+	if !strings.Contains(string(got.Stack), "TestRecover") {
+		t.Errorf("stack does not include the panicking function:\n%s", got.Stack)
+	}
+}
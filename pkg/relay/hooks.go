@@ -0,0 +1,93 @@
+package relay
+
+import (
+	"time"
+
+	pionRTP "github.com/pion/rtp"
+)
+
+// FrameProcessor can observe or rewrite a camera's access units before they
+// reach recorders, the transcoder and the WebRTC bridge — the hook for
+// compiled-in analytics or overlays (object detection, watermarking).
+//
+// Processors run in registration order on the camera's RTSP read goroutine;
+// each receives the previous processor's output. Returning nil drops the
+// frame, returning the input unchanged passes it through. The returned slice
+// is only read until the next frame, so processors may reuse a buffer.
+type FrameProcessor interface {
+	// ProcessVideo receives an H.264 access unit in AVC (length-prefixed) form
+	ProcessVideo(cameraID string, au []byte, timestamp uint32, keyframe bool) []byte
+
+	// ProcessAudio receives a raw AAC access unit
+	ProcessAudio(cameraID string, frame []byte, timestamp uint32) []byte
+}
+
+// PacketTap sees every RTP packet read from a camera before it is
+// depacketized, e.g. to capture streams for replay. TapRTP is called on the
+// camera's RTSP read goroutine and must return quickly; the packet is only
+// valid during the call.
+type PacketTap interface {
+	TapRTP(cameraID, mediaType string, packet *pionRTP.Packet)
+}
+
+// EventType identifies a camera relay lifecycle event
+type EventType string
+
+const (
+	EventRelayStarted     EventType = "relay_started"     // RTSP playing, WebRTC connected
+	EventRelayStopped     EventType = "relay_stopped"     // Relay torn down (any reason)
+	EventRTSPDisconnect   EventType = "rtsp_disconnect"   // RTSP read failed; relay will be recreated
+	EventRTSPReconnect    EventType = "rtsp_reconnect"    // RTSP session dropped, then resumed by the client on the same URL
+	EventWebRTCDisconnect EventType = "webrtc_disconnect" // Peer connection lost; relay will be recreated
+	EventICERestart       EventType = "ice_restart"       // Peer connection lost, then recovered by an ICE restart
+	EventStateDrift       EventType = "sfu_state_drift"   // SFU lost or errored our tracks; relay will be recreated
+	EventRelayPanic       EventType = "relay_panic"       // A relay goroutine panicked; relay will be recreated
+)
+
+// Event is a camera relay lifecycle notification
+type Event struct {
+	CameraID string
+	Type     EventType
+	Time     time.Time
+	Error    string // Set for disconnects, state drift and panics
+}
+
+// EventHandler receives relay lifecycle events. HandleEvent is called
+// synchronously from relay goroutines and must return quickly.
+type EventHandler interface {
+	HandleEvent(ev Event)
+}
+
+// processVideo runs the video processors, returning nil if one dropped the frame
+func (r *CameraRelay) processVideo(au []byte, timestamp uint32, keyframe bool) []byte {
+	for _, p := range r.processors {
+		if au = p.ProcessVideo(r.cameraID, au, timestamp, keyframe); au == nil {
+			return nil
+		}
+	}
+	return au
+}
+
+// processAudio runs the audio processors, returning nil if one dropped the frame
+func (r *CameraRelay) processAudio(frame []byte, timestamp uint32) []byte {
+	for _, p := range r.processors {
+		if frame = p.ProcessAudio(r.cameraID, frame, timestamp); frame == nil {
+			return nil
+		}
+	}
+	return frame
+}
+
+// emit delivers an event to every registered handler
+func (r *CameraRelay) emit(typ EventType, err error) {
+	if len(r.events) == 0 {
+		return
+	}
+	ev := Event{CameraID: r.cameraID, Type: typ, Time: time.Now()}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	for _, h := range r.events {
+		h.HandleEvent(ev)
+	}
+}
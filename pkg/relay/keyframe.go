@@ -0,0 +1,103 @@
+package relay
+
+import (
+	"sync"
+	"time"
+)
+
+// Keyframe starvation thresholds. Nest cameras send an IDR every few
+// seconds, so PLIs that stay unanswered for longer mean the SFU's decoders
+// are stuck. Regenerating the stream costs an SDM command, hence the cooldown.
+const (
+	starvationRequests = 3                // PLI/FIR received without an IDR
+	starvationGrace    = 10 * time.Second // Since the first unanswered request
+	starvationCooldown = 2 * time.Minute  // Between regenerations per camera
+
+	// A camera starved again this soon after a fresh RTSP session has its
+	// stream regenerated instead
+	starvationReconnectWindow = 5 * time.Minute
+)
+
+// keyframeWatch tracks keyframe requests from the SFU against keyframes
+// from the camera and decides when the stream must be regenerated
+type keyframeWatch struct {
+	minRequests int
+	grace       time.Duration
+	cooldown    time.Duration
+
+	mu           sync.Mutex
+	requests     int
+	firstRequest time.Time
+	lastFired    time.Time
+}
+
+func newKeyframeWatch() *keyframeWatch {
+	return &keyframeWatch{
+		minRequests: starvationRequests,
+		grace:       starvationGrace,
+		cooldown:    starvationCooldown,
+	}
+}
+
+// request records a PLI/FIR at now and reports whether the camera is
+// starved: enough requests have gone unanswered for longer than the grace
+// period, and the last regeneration is outside the cooldown
+func (w *keyframeWatch) request(now time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.requests == 0 {
+		w.firstRequest = now
+	}
+	w.requests++
+
+	if w.requests < w.minRequests || now.Sub(w.firstRequest) < w.grace {
+		return false
+	}
+	if !w.lastFired.IsZero() && now.Sub(w.lastFired) < w.cooldown {
+		return false
+	}
+
+	w.lastFired = now
+	w.requests = 0
+	return true
+}
+
+// keyframe records an IDR from the camera, answering outstanding requests
+func (w *keyframeWatch) keyframe() {
+	w.mu.Lock()
+	w.requests = 0
+	w.mu.Unlock()
+}
+
+// keyframeRecovery escalates a camera's keyframe starvation across its
+// relays. A fresh RTSP session comes first: Nest starts every new session
+// with an IDR, and a re-DESCRIBE costs no SDM command. A camera that starves
+// again soon after has its stream regenerated.
+type keyframeRecovery struct {
+	mu          sync.Mutex
+	window      time.Duration
+	reconnected map[string]time.Time // Last fresh session per camera
+}
+
+// regenerate reports whether a starved camera needs its stream regenerated
+// rather than a fresh RTSP session
+func (k *keyframeRecovery) regenerate(cameraID string, now time.Time) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	window := k.window
+	if window == 0 {
+		window = starvationReconnectWindow
+	}
+	if at, ok := k.reconnected[cameraID]; ok && now.Sub(at) < window {
+		delete(k.reconnected, cameraID)
+		return true
+	}
+
+	if k.reconnected == nil {
+		k.reconnected = make(map[string]time.Time)
+	}
+	k.reconnected[cameraID] = now
+	return false
+}
@@ -0,0 +1,59 @@
+package relay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyframeWatch(t *testing.T) {
+	w := newKeyframeWatch()
+	start := time.Now()
+
+	// Requests answered by a keyframe never fire
+	for i := 0; i < 5; i++ {
+		if w.request(start.Add(time.Duration(i) * 5 * time.Second)) {
+			t.Fatalf("fired on request %d", i)
+		}
+		w.keyframe()
+	}
+
+	// Unanswered requests fire once the grace period has passed
+	if w.request(start) || w.request(start.Add(time.Second)) || w.request(start.Add(2*time.Second)) {
+		t.Fatal("fired within the grace period")
+	}
+	if !w.request(start.Add(starvationGrace)) {
+		t.Fatal("did not fire after the grace period")
+	}
+
+	// Cooldown suppresses the next regeneration
+	at := start.Add(starvationGrace)
+	for i := 1; i <= 5; i++ {
+		if w.request(at.Add(time.Duration(i) * starvationGrace)) {
+			t.Fatalf("fired during cooldown at request %d", i)
+		}
+	}
+	if !w.request(at.Add(starvationCooldown)) {
+		t.Fatal("did not fire after the cooldown")
+	}
+}
+
+func TestKeyframeRecovery(t *testing.T) {
+	var k keyframeRecovery
+	now := time.Now()
+
+	if k.regenerate("door", now) {
+		t.Fatal("regenerated before trying a fresh session")
+	}
+	if k.regenerate("yard", now) {
+		t.Fatal("cameras share escalation state")
+	}
+	if !k.regenerate("door", now.Add(time.Minute)) {
+		t.Fatal("starved again after a fresh session but not regenerated")
+	}
+	if k.regenerate("door", now.Add(2*time.Minute)) {
+		t.Fatal("escalation not restarted after regenerating")
+	}
+	if k.regenerate("yard", now.Add(starvationReconnectWindow)) {
+		t.Fatal("regenerated long after the last fresh session")
+	}
+}
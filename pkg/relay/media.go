@@ -0,0 +1,77 @@
+package relay
+
+import (
+	"context"
+
+	rtspClient "github.com/ethan/nest-cloudflare-relay/pkg/rtsp"
+	pionRTP "github.com/pion/rtp"
+)
+
+// MediaConn is the camera end of a relay, where its RTP comes from. The
+// RTSP client is one; a Source whose camera isn't read over RTSP provides
+// its own through MediaSource. Calls come in the order Connect, SetHandlers,
+// SetupTracks, Play, then ReadPackets until Close.
+type MediaConn interface {
+	// Connect learns the camera's media, filling Tracks
+	Connect(ctx context.Context) error
+
+	// Tracks are the camera's media, keyed by the channel packets arrive on
+	Tracks() map[byte]*rtspClient.Channel
+
+	// Probe is what Connect learned of the camera, for metadata lookups; nil
+	// when it has nothing to add to Tracks
+	Probe() *rtspClient.ProbeResult
+
+	AudioCodec() string
+	VideoCodec() string
+
+	// VideoParameterSets are the video track's out-of-band SPS and PPS
+	VideoParameterSets() [][]byte
+
+	// SkipMedia drops a media type's tracks before SetupTracks
+	SkipMedia(mediaType string)
+
+	// SetHandlers sets the callbacks ReadPackets makes: onPacket with each
+	// RTP packet, which is reused once it returns, and onReconnect when RTP
+	// resumes with new sequence numbers and timestamps
+	SetHandlers(onPacket func(channel byte, packet *pionRTP.Packet), onReconnect func())
+
+	SetupTracks(ctx context.Context) error
+	Play(ctx context.Context) error
+
+	// ReadPackets delivers RTP until ctx ends or the camera is lost
+	ReadPackets(ctx context.Context) error
+
+	// Stats are the reception counters of each track
+	Stats() rtspClient.ClientStats
+
+	// Abort drops the connection so ReadPackets fails, as a lost camera does
+	Abort() error
+
+	Close() error
+}
+
+// MediaSource is a Source read through its own MediaConn rather than over
+// RTSP from its URL
+type MediaSource interface {
+	Source
+
+	// Media returns the connection a new relay reads; called once per relay
+	Media() MediaConn
+}
+
+// rtspMedia reads a camera over RTSP
+type rtspMedia struct {
+	*rtspClient.Client
+}
+
+// Tracks returns the client's channels
+func (m rtspMedia) Tracks() map[byte]*rtspClient.Channel {
+	return m.Channels
+}
+
+// SetHandlers sets the client's OnRTPPacket and OnReconnect
+func (m rtspMedia) SetHandlers(onPacket func(channel byte, packet *pionRTP.Packet), onReconnect func()) {
+	m.OnRTPPacket = onPacket
+	m.OnReconnect = onReconnect
+}
@@ -5,44 +5,257 @@ import (
 	"fmt"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/ethan/nest-cloudflare-relay/pkg/cloudflare"
+	"github.com/ethan/nest-cloudflare-relay/pkg/bridge"
+	"github.com/ethan/nest-cloudflare-relay/pkg/faults"
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+	"github.com/ethan/nest-cloudflare-relay/pkg/membudget"
 	"github.com/ethan/nest-cloudflare-relay/pkg/nest"
+	"github.com/ethan/nest-cloudflare-relay/pkg/recovery"
+	rtspClient "github.com/ethan/nest-cloudflare-relay/pkg/rtsp"
+	"github.com/ethan/nest-cloudflare-relay/pkg/sfu"
 )
 
 // MultiCameraRelay orchestrates relays for multiple cameras with rate-limited coordination
 type MultiCameraRelay struct {
 	streamMgr  *nest.MultiStreamManager
-	cfClient   *cloudflare.Client
+	backend    sfu.Backend
 	logger     *slog.Logger
 
-	mu     sync.RWMutex
-	relays map[string]*CameraRelay // Key: cameraID
+	mu        sync.RWMutex
+	relays    map[string]*CameraRelay // Key: cameraID
+	generations map[string]uint64     // Relays created per camera, for track names
+	videoCodecs map[string]string     // Video codec each camera sent last, offered to its next relay
+	resumed     map[string]string     // SFU session each camera's next relay adopts, left by a previous leader
+	recorders  []Recorder
+	processors []FrameProcessor
+	events     []EventHandler
+	taps       []PacketTap
+	transcoder TranscoderFactory
+	audioOnly  func(cameraID, deviceID string) bool
+	audio      func(cameraID, deviceID string) bool // Audio forwarding per camera; nil forwards all
+	faults     *faults.Injector
+	probes     *rtspClient.ProbeCache // Probes of streams without a relay, for CameraMedia
+	memory     *membudget.Budget      // Caps frames buffered per camera; nil is unlimited
+	reorder    int                    // RTP reorder depth for new relays; zero is the default
+	bridgeCfg  bridge.BridgeConfig    // Packetization and negotiation options for new relays
+	pacer      func(cameraID, deviceID string) bridge.PacerConfig // Pacer tuning per camera; nil uses the defaults
+	transport  func(cameraID, deviceID string) rtspClient.Transport // RTSP transport per camera; nil is TCP
+	rtspOpts   func(cameraID, deviceID string) rtspClient.ClientOptions // RTSP timeouts per camera; nil is the defaults
+	static     map[string]Source // Cameras read from their own source rather than a Nest stream
+
+	stateDrifts atomic.Uint64 // Relays recreated because the SFU disagreed with the bridge
+	starvation  keyframeRecovery
+	panics      atomic.Uint64 // Relays recreated after a recovered panic
 
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 }
 
-// NewMultiCameraRelay creates a multi-camera relay orchestrator
+// NewMultiCameraRelay creates a multi-camera relay orchestrator publishing
+// every camera to the given SFU backend
 func NewMultiCameraRelay(
 	streamMgr *nest.MultiStreamManager,
-	cfClient *cloudflare.Client,
+	backend sfu.Backend,
 	logger *slog.Logger,
 ) *MultiCameraRelay {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &MultiCameraRelay{
 		streamMgr: streamMgr,
-		cfClient:  cfClient,
+		backend:   backend,
 		logger:    logger,
 		relays:    make(map[string]*CameraRelay),
+		generations: make(map[string]uint64),
+		videoCodecs: make(map[string]string),
+		resumed:     make(map[string]string),
+		static:      make(map[string]Source),
+		probes:    rtspClient.NewProbeCache(nest.StreamTTL),
 		ctx:       ctx,
 		cancel:    cancel,
 	}
 }
 
+// AddRecorder registers a Recorder that receives media from every camera.
+// Recorders apply to relays created after the call, so register them before Start.
+func (mcr *MultiCameraRelay) AddRecorder(rec Recorder) {
+	mcr.mu.Lock()
+	defer mcr.mu.Unlock()
+	mcr.recorders = append(mcr.recorders, rec)
+}
+
+// AddFrameProcessor registers a FrameProcessor that sees every camera's
+// frames before recorders and the bridge. Like recorders, processors apply to
+// relays created after the call.
+func (mcr *MultiCameraRelay) AddFrameProcessor(p FrameProcessor) {
+	mcr.mu.Lock()
+	defer mcr.mu.Unlock()
+	mcr.processors = append(mcr.processors, p)
+}
+
+// AddEventHandler registers an EventHandler for relay lifecycle events on
+// relays created after the call
+func (mcr *MultiCameraRelay) AddEventHandler(h EventHandler) {
+	mcr.mu.Lock()
+	defer mcr.mu.Unlock()
+	mcr.events = append(mcr.events, h)
+}
+
+// AddPacketTap registers a PacketTap for the RTP of relays created after
+// the call
+func (mcr *MultiCameraRelay) AddPacketTap(t PacketTap) {
+	mcr.mu.Lock()
+	defer mcr.mu.Unlock()
+	mcr.taps = append(mcr.taps, t)
+}
+
+// SetTranscoderFactory routes cameras through an external transcoder on
+// relays created after the call (nil disables)
+func (mcr *MultiCameraRelay) SetTranscoderFactory(factory TranscoderFactory) {
+	mcr.mu.Lock()
+	defer mcr.mu.Unlock()
+	mcr.transcoder = factory
+}
+
+// SetAudioOnly selects cameras relayed as audio only: their video is never
+// pulled from Nest or published, and audio goes through the transcoder.
+// Applies to relays created after the call.
+func (mcr *MultiCameraRelay) SetAudioOnly(audioOnly func(cameraID, deviceID string) bool) {
+	mcr.mu.Lock()
+	defer mcr.mu.Unlock()
+	mcr.audioOnly = audioOnly
+}
+
+// SetAudioEnabled selects the cameras whose audio is forwarded, on relays
+// created after the call; nil forwards every camera's. Cameras that don't
+// send Opus also need a transcoder that handles audio. Use SetCameraAudio to
+// switch a running camera.
+func (mcr *MultiCameraRelay) SetAudioEnabled(enabled func(cameraID, deviceID string) bool) {
+	mcr.mu.Lock()
+	defer mcr.mu.Unlock()
+	mcr.audio = enabled
+}
+
+// SetCameraAudio turns a running camera's audio forwarding on or off. Opus
+// passthrough switches in place; other cameras' audio goes through the
+// transcoder, so their relay is recreated and the transcoder factory asked
+// again. The audio selection set by SetAudioEnabled should agree, or the
+// next relay for the camera reverts.
+func (mcr *MultiCameraRelay) SetCameraAudio(cameraID string, enabled bool) {
+	mcr.mu.RLock()
+	relay := mcr.relays[cameraID]
+	mcr.mu.RUnlock()
+	if relay == nil || relay.audioEnabled.Swap(enabled) == enabled {
+		return
+	}
+
+	mcr.logger.Info("camera audio switched", "camera_id", cameraID, "enabled", enabled)
+	if !relay.opusAudio && relay.media.AudioCodec() != "" {
+		mcr.removeRelay(cameraID, relay)
+	}
+}
+
+// SetFaultInjector enables chaos-mode RTSP disconnects on relays created
+// after the call (nil disables)
+func (mcr *MultiCameraRelay) SetFaultInjector(inj *faults.Injector) {
+	mcr.mu.Lock()
+	defer mcr.mu.Unlock()
+	mcr.faults = inj
+}
+
+// SetMemoryBudget caps the bytes relays created after the call may buffer,
+// charging each camera's pipeline to its own account (nil is unlimited)
+func (mcr *MultiCameraRelay) SetMemoryBudget(b *membudget.Budget) {
+	mcr.mu.Lock()
+	defer mcr.mu.Unlock()
+	mcr.memory = b
+}
+
+// SetReorderDepth sets how many RTP packets relays created after the call
+// hold past a sequence gap before skipping it; zero uses
+// rtp.DefaultReorderDepth and a negative depth turns reordering off
+func (mcr *MultiCameraRelay) SetReorderDepth(depth int) {
+	mcr.mu.Lock()
+	defer mcr.mu.Unlock()
+	mcr.reorder = depth
+}
+
+// SetBridgeConfig sets the MTU, payload types, H.264 fmtp and ICE servers
+// of relays created after the call. Zero fields use the bridge defaults.
+func (mcr *MultiCameraRelay) SetBridgeConfig(cfg bridge.BridgeConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid bridge config: %w", err)
+	}
+	mcr.mu.Lock()
+	defer mcr.mu.Unlock()
+	mcr.bridgeCfg = cfg
+	return nil
+}
+
+// ResumeSession has the camera's next relay adopt sessionID, an SFU session
+// a previous leader left published, instead of creating one. Relays after
+// it create their own.
+func (mcr *MultiCameraRelay) ResumeSession(cameraID, sessionID string) {
+	mcr.mu.Lock()
+	defer mcr.mu.Unlock()
+	mcr.resumed[cameraID] = sessionID
+}
+
+// SetPacerConfig selects each camera's pacer tuning, on relays created
+// after the call; nil, or a zero PacerConfig, uses the defaults
+func (mcr *MultiCameraRelay) SetPacerConfig(pacer func(cameraID, deviceID string) bridge.PacerConfig) {
+	mcr.mu.Lock()
+	defer mcr.mu.Unlock()
+	mcr.pacer = pacer
+}
+
+// SetRTSPTransport selects each camera's RTSP transport, on relays created
+// after the call; nil, or an empty Transport, uses interleaved TCP
+func (mcr *MultiCameraRelay) SetRTSPTransport(transport func(cameraID, deviceID string) rtspClient.Transport) {
+	mcr.mu.Lock()
+	defer mcr.mu.Unlock()
+	mcr.transport = transport
+}
+
+// SetRTSPOptions selects each camera's RTSP timeouts, on relays created
+// after the call; nil, or zero fields, use the client defaults
+func (mcr *MultiCameraRelay) SetRTSPOptions(opts func(cameraID, deviceID string) rtspClient.ClientOptions) {
+	mcr.mu.Lock()
+	defer mcr.mu.Unlock()
+	mcr.rtspOpts = opts
+}
+
+// AddStaticSource relays a camera from a source of its own instead of a
+// Nest stream, e.g. a URLSource for a local RTSP camera. Its relay is
+// created at the next reconciliation and recreated whenever it fails; the
+// camera ID doubles as its device ID.
+func (mcr *MultiCameraRelay) AddStaticSource(cameraID string, source Source) {
+	mcr.mu.Lock()
+	defer mcr.mu.Unlock()
+	mcr.static[cameraID] = source
+}
+
+// source returns where a camera is read from: its static source, or its
+// current Nest RTSP or WebRTC stream; nil when it has neither
+func (mcr *MultiCameraRelay) source(cameraID string) Source {
+	mcr.mu.RLock()
+	static := mcr.static[cameraID]
+	mcr.mu.RUnlock()
+	if static != nil {
+		return static
+	}
+	if stream := mcr.streamMgr.GetStream(cameraID); stream != nil {
+		return NestSource(stream)
+	}
+	if stream := mcr.streamMgr.GetWebRTCStream(cameraID); stream != nil {
+		return NestWebRTCSource(stream)
+	}
+	return nil
+}
+
 // Start initializes relays for all cameras managed by the stream manager
 func (mcr *MultiCameraRelay) Start(ctx context.Context) error {
 	mcr.logger.Info("starting multi-camera relay")
@@ -56,6 +269,12 @@ func (mcr *MultiCameraRelay) Start(ctx context.Context) error {
 	mcr.wg.Add(1)
 	go mcr.monitorStreamsLoop()
 
+	// Catch sessions the SFU dropped while the peer connection stayed up
+	if _, ok := mcr.backend.(sfu.StateReporter); ok {
+		mcr.wg.Add(1)
+		go mcr.sfuStateLoop()
+	}
+
 	mcr.logger.Info("multi-camera relay started")
 	return nil
 }
@@ -74,6 +293,7 @@ func (mcr *MultiCameraRelay) Stop() error {
 		stopWg.Add(1)
 		go func(id string, r *CameraRelay) {
 			defer stopWg.Done()
+			defer goroutines.Track("relay.stop", id)()
 			if err := r.Stop(); err != nil {
 				mcr.logger.Error("failed to stop relay", "camera_id", id, "error", err)
 			}
@@ -100,12 +320,16 @@ func (mcr *MultiCameraRelay) Stop() error {
 // monitorStreamsLoop periodically checks stream statuses and creates/removes relays
 func (mcr *MultiCameraRelay) monitorStreamsLoop() {
 	defer mcr.wg.Done()
+	defer goroutines.Track("relay.monitorStreams", "")()
 
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
 
 	mcr.logger.Info("stream monitoring loop started")
 
+	// Static sources need no stream first
+	mcr.reconcileRelays()
+
 	for {
 		select {
 		case <-mcr.ctx.Done():
@@ -117,6 +341,7 @@ func (mcr *MultiCameraRelay) monitorStreamsLoop() {
 }
 
 // reconcileRelays ensures relays match the stream manager's active streams
+// and the static sources
 func (mcr *MultiCameraRelay) reconcileRelays() {
 	statuses := mcr.streamMgr.GetStreamStatus()
 
@@ -139,6 +364,7 @@ func (mcr *MultiCameraRelay) reconcileRelays() {
 					"state", status.State.String())
 
 				go func(r *CameraRelay) {
+					defer goroutines.Track("relay.stop", cameraID)()
 					if err := r.Stop(); err != nil {
 						mcr.logger.Error("failed to stop relay", "camera_id", cameraID, "error", err)
 					}
@@ -158,8 +384,21 @@ func (mcr *MultiCameraRelay) reconcileRelays() {
 		}
 	}
 
+	// Static sources are always wanted
+	for cameraID := range mcr.static {
+		if _, exists := mcr.relays[cameraID]; !exists {
+			toCreate = append(toCreate, struct {
+				cameraID string
+				deviceID string
+			}{cameraID, cameraID})
+		}
+	}
+
 	// Remove relays for cameras no longer managed by stream manager
 	for cameraID, relay := range mcr.relays {
+		if _, static := mcr.static[cameraID]; static {
+			continue
+		}
 		found := false
 		for _, status := range statuses {
 			if status.CameraID == cameraID {
@@ -172,6 +411,7 @@ func (mcr *MultiCameraRelay) reconcileRelays() {
 			mcr.logger.Info("camera removed from stream manager, stopping relay", "camera_id", cameraID)
 
 			go func(r *CameraRelay) {
+				defer goroutines.Track("relay.stop", cameraID)()
 				if err := r.Stop(); err != nil {
 					mcr.logger.Error("failed to stop relay", "camera_id", cameraID, "error", err)
 				}
@@ -192,10 +432,20 @@ func (mcr *MultiCameraRelay) reconcileRelays() {
 }
 
 // createRelayForStream creates and starts a relay for a specific camera
-func (mcr *MultiCameraRelay) createRelayForStream(cameraID, deviceID string) error {
-	// Get stream from stream manager
-	stream := mcr.streamMgr.GetStream(cameraID)
-	if stream == nil {
+func (mcr *MultiCameraRelay) createRelayForStream(cameraID, deviceID string) (err error) {
+	// A panic while starting (e.g. parsing a malformed SDP) fails only this camera
+	defer recovery.Recover("relay.start", func(p *recovery.PanicError) {
+		mcr.panics.Add(1)
+		mcr.logger.Error("panic while starting relay",
+			"camera_id", cameraID,
+			"panic", fmt.Sprint(p.Value),
+			"stack", string(p.Stack))
+		err = p
+	})
+
+	// Get the static source, or the stream from the stream manager
+	source := mcr.source(cameraID)
+	if source == nil {
 		return fmt.Errorf("no stream found for camera %s", cameraID)
 	}
 
@@ -203,17 +453,75 @@ func (mcr *MultiCameraRelay) createRelayForStream(cameraID, deviceID string) err
 	relay := NewCameraRelay(
 		cameraID,
 		deviceID,
-		stream,
-		mcr.cfClient,
+		source,
+		mcr.backend,
 		mcr.logger.With("camera_id", cameraID),
 	)
 
+	mcr.mu.Lock()
+	mcr.generations[cameraID]++
+	relay.generation = mcr.generations[cameraID]
+	relay.videoCodec = mcr.videoCodecs[cameraID]
+	relay.resumeID = mcr.resumed[cameraID]
+	delete(mcr.resumed, cameraID) // Only the first relay may adopt it
+	mcr.mu.Unlock()
+
+	mcr.mu.RLock()
+	relay.recorders = append([]Recorder(nil), mcr.recorders...)
+	relay.processors = append([]FrameProcessor(nil), mcr.processors...)
+	relay.taps = append([]PacketTap(nil), mcr.taps...)
+	relay.events = append([]EventHandler(nil), mcr.events...)
+	relay.faults = mcr.faults
+	relay.memory = mcr.memory.Account(cameraID)
+	relay.reorderDepth = mcr.reorder
+	relay.bridgeConfig = mcr.bridgeCfg
+	factory := mcr.transcoder
+	audioOnly := mcr.audioOnly
+	audio := mcr.audio
+	pacer := mcr.pacer
+	transport := mcr.transport
+	rtspOpts := mcr.rtspOpts
+	_, static := mcr.static[cameraID] // No stream to regenerate
+	mcr.mu.RUnlock()
+
+	if pacer != nil {
+		relay.pacerConfig = pacer(cameraID, deviceID)
+	}
+	if transport != nil {
+		relay.rtspTransport = transport(cameraID, deviceID)
+	}
+	if rtspOpts != nil {
+		relay.rtspOptions = rtspOpts(cameraID, deviceID)
+	}
+
+	if audioOnly != nil {
+		relay.audioOnly = audioOnly(cameraID, deviceID)
+	}
+	relay.audioEnabled.Store(audio == nil || relay.audioOnly || audio(cameraID, deviceID))
+
+	if factory != nil {
+		relay.transcoder = factory(cameraID, deviceID)
+	}
+
 	// Setup error handlers
 	relay.OnRTSPDisconnect = func(camID string, err error) {
 		mcr.logger.Error("RTSP disconnect detected",
 			"camera_id", camID,
 			"error", err)
-		// Stream manager will handle regeneration via its monitoring loop
+
+		// Drop the relay so the next reconciliation reconnects to the stream.
+		// If the stream itself is gone, the stream manager regenerates it first.
+		mcr.removeRelay(camID, relay)
+	}
+
+	relay.OnVideoCodec = func(camID, codec string) {
+		mcr.logger.Warn("camera changed video codec, recreating relay",
+			"camera_id", camID,
+			"codec", codec)
+
+		mcr.mu.Lock()
+		mcr.videoCodecs[camID] = codec
+		mcr.mu.Unlock()
 	}
 
 	relay.OnWebRTCDisconnect = func(camID string, err error) {
@@ -221,21 +529,42 @@ func (mcr *MultiCameraRelay) createRelayForStream(cameraID, deviceID string) err
 			"camera_id", camID,
 			"error", err)
 
-		// Recreate the relay (new Cloudflare session)
-		mcr.mu.Lock()
-		if existingRelay, exists := mcr.relays[camID]; exists {
-			delete(mcr.relays, camID)
-			mcr.mu.Unlock()
+		// Recreate the relay (new Cloudflare session) in the next reconciliation loop
+		mcr.removeRelay(camID, relay)
+	}
+
+	relay.OnKeyframeStarved = func(camID string) {
+		if static || !mcr.starvation.regenerate(camID, time.Now()) {
+			mcr.logger.Warn("camera starved of keyframes, reconnecting RTSP", "camera_id", camID)
+			mcr.removeRelay(camID, relay)
+			return
+		}
 
-			// Stop old relay
-			if err := existingRelay.Stop(); err != nil {
-				mcr.logger.Error("failed to stop old relay", "camera_id", camID, "error", err)
+		// Regeneration waits on the command queue; never block the RTCP reader
+		mcr.logger.Warn("camera starved of keyframes again, regenerating stream", "camera_id", camID)
+		go func() {
+			defer goroutines.Track("relay.regenerate", camID)()
+			if err := mcr.streamMgr.RegenerateStream(camID); err != nil {
+				mcr.logger.Error("failed to regenerate stream for keyframe",
+					"camera_id", camID,
+					"error", err)
+				return
 			}
 
-			// Recreate relay (will be done in next reconciliation loop)
-		} else {
-			mcr.mu.Unlock()
-		}
+			// Reconnect to the new stream in the next reconciliation loop
+			mcr.removeRelay(camID, relay)
+		}()
+	}
+
+	relay.OnPanic = func(camID string, err error) {
+		total := mcr.panics.Add(1)
+		mcr.logger.Error("camera relay panicked, recreating",
+			"camera_id", camID,
+			"error", err,
+			"total_panics", total)
+
+		// Recreate the relay in the next reconciliation loop
+		mcr.removeRelay(camID, relay)
 	}
 
 	// Start relay
@@ -243,6 +572,7 @@ func (mcr *MultiCameraRelay) createRelayForStream(cameraID, deviceID string) err
 	defer cancel()
 
 	if err := relay.Start(startCtx); err != nil {
+		relay.Stop() // Release the bridge and any transcoder process
 		return fmt.Errorf("start relay: %w", err)
 	}
 
@@ -255,6 +585,26 @@ func (mcr *MultiCameraRelay) createRelayForStream(cameraID, deviceID string) err
 	return nil
 }
 
+// removeRelay forgets a relay and stops it in the background. Callbacks run on
+// the relay's own goroutines, so Stop (which waits for them) must not be
+// called synchronously.
+func (mcr *MultiCameraRelay) removeRelay(cameraID string, relay *CameraRelay) {
+	mcr.mu.Lock()
+	if existing, exists := mcr.relays[cameraID]; !exists || existing != relay {
+		mcr.mu.Unlock()
+		return
+	}
+	delete(mcr.relays, cameraID)
+	mcr.mu.Unlock()
+
+	go func() {
+		defer goroutines.Track("relay.stop", cameraID)()
+		if err := relay.Stop(); err != nil {
+			mcr.logger.Error("failed to stop old relay", "camera_id", cameraID, "error", err)
+		}
+	}()
+}
+
 // GetRelayStats returns statistics for all active relays
 func (mcr *MultiCameraRelay) GetRelayStats() []RelayStats {
 	mcr.mu.RLock()
@@ -275,6 +625,8 @@ func (mcr *MultiCameraRelay) GetAggregateStats() AggregateStats {
 
 	agg := AggregateStats{
 		TotalRelays: len(mcr.relays),
+		StateDrifts: mcr.stateDrifts.Load(),
+		Panics:      mcr.panics.Load(),
 	}
 
 	for _, relay := range mcr.relays {
@@ -283,6 +635,15 @@ func (mcr *MultiCameraRelay) GetAggregateStats() AggregateStats {
 		agg.TotalVideoFrames += stats.VideoFrames
 		agg.TotalAudioPackets += stats.AudioPackets
 		agg.TotalAudioFrames += stats.AudioFrames
+		agg.MaxVideoLoss = max(agg.MaxVideoLoss, stats.VideoQuality.FractionLost)
+		agg.MaxVideoRTT = max(agg.MaxVideoRTT, stats.VideoQuality.RTT)
+		agg.MaxVideoLatencyP95 = max(agg.MaxVideoLatencyP95, stats.VideoLatency.Total.P95)
+		agg.StalledWrites += stats.Writes.Stalled
+		agg.OverBudgetFrames += stats.Writes.OverBudget
+		agg.MaxICERTT = max(agg.MaxICERTT, stats.Peer.RTT)
+		if stats.VideoSilent {
+			agg.SilentRelays++
+		}
 
 		// Count by WebRTC state
 		switch stats.WebRTCState {
@@ -311,4 +672,13 @@ type AggregateStats struct {
 	TotalVideoFrames    uint64
 	TotalAudioPackets   uint64
 	TotalAudioFrames    uint64
+	MaxVideoLoss        float64       // Worst smoothed fraction lost across relays
+	MaxVideoRTT         time.Duration // Worst smoothed RTT across relays
+	MaxVideoLatencyP95  time.Duration // Worst p95 camera-to-track latency across relays
+	StateDrifts         uint64        // Relays recreated after SFU session state drift
+	Panics              uint64        // Relays recreated after a recovered panic
+	StalledWrites       uint64        // Packets dropped on stalled transports by current relays
+	OverBudgetFrames    uint64        // Video frames dropped by current relays' memory budgets
+	SilentRelays        int           // Connected relays that sent no video in the last poll
+	MaxICERTT           time.Duration // Worst selected-pair STUN RTT across relays
 }
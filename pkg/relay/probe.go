@@ -0,0 +1,50 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	rtspClient "github.com/ethan/nest-cloudflare-relay/pkg/rtsp"
+)
+
+// ErrNoStream is returned by CameraMedia for a camera with no active stream
+var ErrNoStream = errors.New("camera has no active stream")
+
+// CameraMedia returns a camera's RTSP capabilities without starting
+// playback: the DESCRIBE of its running relay when there is one, otherwise a
+// cached or fresh OPTIONS/DESCRIBE probe of its source
+func (mcr *MultiCameraRelay) CameraMedia(ctx context.Context, cameraID string) (*rtspClient.ProbeResult, error) {
+	mcr.mu.RLock()
+	relay := mcr.relays[cameraID]
+	mcr.mu.RUnlock()
+	if relay != nil {
+		if probe := relay.Probe(); probe != nil {
+			return probe, nil
+		}
+	}
+
+	source := mcr.source(cameraID)
+	if source == nil {
+		return nil, fmt.Errorf("camera %s: %w", cameraID, ErrNoStream)
+	}
+	if _, ok := source.(MediaSource); ok {
+		// Only the relay reading the stream knows its media
+		return nil, fmt.Errorf("camera %s has no running relay: %w", cameraID, ErrNoStream)
+	}
+
+	// Static sources are their own device
+	deviceID := cameraID
+	for _, status := range mcr.streamMgr.GetStreamStatus() {
+		if status.CameraID == cameraID {
+			deviceID = status.DeviceID
+		}
+	}
+	var opts rtspClient.ClientOptions
+	mcr.mu.RLock()
+	if mcr.rtspOpts != nil {
+		opts = mcr.rtspOpts(cameraID, deviceID)
+	}
+	mcr.mu.RUnlock()
+	return mcr.probes.Probe(ctx, source.URL(), opts, mcr.logger.With("camera_id", cameraID, "component", "rtsp_probe"))
+}
@@ -0,0 +1,61 @@
+package relay
+
+import (
+	"encoding/hex"
+
+	rtspClient "github.com/ethan/nest-cloudflare-relay/pkg/rtsp"
+)
+
+// Recorder receives a copy of the media each CameraRelay forwards to the
+// WebRTC bridge. It is the hook embedders use to archive or re-publish
+// camera feeds alongside the live Cloudflare relay.
+//
+// Methods are invoked synchronously from the camera's RTSP read goroutine,
+// so implementations must return quickly and must not retain the byte
+// slices beyond the call without copying them.
+type Recorder interface {
+	// RecordVideo receives one H.264 access unit in AVC (length-prefixed)
+	// form. Keyframes carry SPS/PPS ahead of the IDR slice.
+	RecordVideo(cameraID string, au []byte, timestamp uint32, keyframe bool)
+
+	// RecordAudio receives one raw AAC access unit (no ADTS header). Opus
+	// audio is relayed untouched and not delivered here.
+	RecordAudio(cameraID string, frame []byte, timestamp uint32)
+}
+
+// MediaInfo describes the codecs negotiated for a camera's RTSP session
+type MediaInfo struct {
+	VideoCodec     string // e.g. "H264"
+	AudioCodec     string // e.g. "MPEG4-GENERIC" (AAC) or "OPUS"; empty without audio
+	AudioClockRate int
+	AudioChannels  int
+	AudioConfig    []byte // AAC AudioSpecificConfig from the SDP fmtp "config"
+	AudioOnly      bool   // Video was not set up; only audio frames follow
+}
+
+// MediaInfoRecorder is an optional Recorder extension for sinks that need
+// codec parameters up front (e.g. to write container headers). It is called
+// each time a camera's RTSP session is established, before its first frame.
+type MediaInfoRecorder interface {
+	Recorder
+	RecordMediaInfo(cameraID string, info MediaInfo)
+}
+
+// mediaInfoFromChannels extracts MediaInfo from the RTSP session's channels
+func mediaInfoFromChannels(channels map[byte]*rtspClient.Channel) MediaInfo {
+	var info MediaInfo
+	for _, ch := range channels {
+		switch ch.MediaType {
+		case "video":
+			info.VideoCodec = ch.Codec
+		case "audio":
+			info.AudioCodec = ch.Codec
+			info.AudioClockRate = ch.ClockRate
+			info.AudioChannels = ch.Channels
+			if cfg, err := hex.DecodeString(ch.FmtpParam("config")); err == nil {
+				info.AudioConfig = cfg
+			}
+		}
+	}
+	return info
+}
@@ -2,6 +2,7 @@ package relay
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
@@ -9,27 +10,64 @@ import (
 	"time"
 
 	"github.com/ethan/nest-cloudflare-relay/pkg/bridge"
-	"github.com/ethan/nest-cloudflare-relay/pkg/cloudflare"
-	"github.com/ethan/nest-cloudflare-relay/pkg/nest"
+	"github.com/ethan/nest-cloudflare-relay/pkg/faults"
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+	"github.com/ethan/nest-cloudflare-relay/pkg/membudget"
+	"github.com/ethan/nest-cloudflare-relay/pkg/recovery"
 	"github.com/ethan/nest-cloudflare-relay/pkg/rtp"
+	"github.com/ethan/nest-cloudflare-relay/pkg/sfu"
 	rtspClient "github.com/ethan/nest-cloudflare-relay/pkg/rtsp"
 	pionRTP "github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
 )
 
+// iceRestartTimeout bounds an ICE restart, from the renegotiation to the
+// connection coming back
+const iceRestartTimeout = 15 * time.Second
+
+// ErrVideoCodecChanged fails Start when the camera sends a different video
+// codec than the track was offered with; the next relay offers the new one
+var ErrVideoCodecChanged = errors.New("camera video codec changed")
+
 // CameraRelay manages the complete pipeline for a single camera:
-// Nest RTSP stream → RTP processors → WebRTC bridge → Cloudflare
+// Nest RTSP stream → RTP processors → WebRTC bridge → SFU (Cloudflare by default)
 type CameraRelay struct {
 	cameraID  string
 	deviceID  string
-	stream    *nest.RTSPStream
-	cfClient  *cloudflare.Client
+	source    Source
+	backend   sfu.Backend
 	logger    *slog.Logger
 
 	// Pipeline components
-	rtspConn  *rtspClient.Client
-	h264Proc  *rtp.H264Processor
-	aacProc   *rtp.AACProcessor
+	media        MediaConn // The camera: its RTSP client, or its source's own
+	rtspTransport rtspClient.Transport // Interleaved TCP unless set
+	rtspOptions   rtspClient.ClientOptions // Zero fields use the client defaults
+	videoProc    rtp.VideoProcessor
+	videoReorder *rtp.ReorderBuffer
+	audioReorder *rtp.ReorderBuffer
+	reorderDepth int // Packets held past a sequence gap; zero is rtp.DefaultReorderDepth
+	aacProc      *rtp.AACProcessor
+	g711Proc     *rtp.G711Processor // Set instead of aacProc for G.711 cameras
 	webrtcBridge *bridge.Bridge
+	bridgeConfig bridge.BridgeConfig // Packetization and negotiation options; zero fields are defaults
+	pacerConfig  bridge.PacerConfig  // The camera's pacer tuning; zero fields are defaults
+	recorders    []Recorder
+	transcoder   Transcoder
+	processors   []FrameProcessor
+	events       []EventHandler
+	taps         []PacketTap
+	faults       *faults.Injector
+	memory       *membudget.Account // The camera's share of the memory budget
+	keyframes    *keyframeWatch
+	audioOnly    bool // Relay only audio (Opus or through the transcoder); video is never set up
+	opusAudio    bool // The camera sends Opus, forwarded to the audio track untouched
+	audioEnabled atomic.Bool // Audio is forwarded; toggles Opus passthrough in place
+	videoCodec   string // The camera's video codec; before Start, the one it sent last time
+	generation   uint64 // The camera's nth relay in this process; names its SFU tracks
+	resumeID     string // SFU session left by a previous leader, adopted instead of creating one
+	driftStrikes int  // Consecutive SFU state checks that disagreed with the bridge
+	connectOnce  sync.Once
+	connectErr   error // Set when the peer connection never came up for the first write
 
 	// Lifecycle management
 	ctx    context.Context
@@ -41,19 +79,25 @@ type CameraRelay struct {
 	audioPacketCount atomic.Uint64
 	videoFrameCount  atomic.Uint64
 	audioFrameCount  atomic.Uint64
+	lastKeyframe     atomic.Int64 // Unix nanoseconds
+	probe            atomic.Pointer[rtspClient.ProbeResult] // The camera's DESCRIBE, kept for metadata lookups
 	startTime        time.Time
+	started          atomic.Bool
 
 	// Callbacks for error recovery
 	OnRTSPDisconnect   func(cameraID string, err error) // Trigger stream regeneration
 	OnWebRTCDisconnect func(cameraID string, err error) // Trigger session recreation
+	OnKeyframeStarved  func(cameraID string)            // Force a new IDR: a fresh RTSP session, or a regenerated stream
+	OnPanic            func(cameraID string, err error) // A relay goroutine panicked; restart the camera
+	OnVideoCodec       func(cameraID, codec string)     // The camera sent another codec than was published; Start fails
 }
 
-// NewCameraRelay creates a relay for a single camera
+// NewCameraRelay creates a relay for a single camera read from source
 func NewCameraRelay(
 	cameraID string,
 	deviceID string,
-	stream *nest.RTSPStream,
-	cfClient *cloudflare.Client,
+	source Source,
+	backend sfu.Backend,
 	logger *slog.Logger,
 ) *CameraRelay {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -61,29 +105,157 @@ func NewCameraRelay(
 	return &CameraRelay{
 		cameraID:  cameraID,
 		deviceID:  deviceID,
-		stream:    stream,
-		cfClient:  cfClient,
+		source:    source,
+		backend:   backend,
 		logger:    logger.With("camera_id", cameraID, "component", "relay"),
 		ctx:       ctx,
 		cancel:    cancel,
+		keyframes: newKeyframeWatch(),
 		startTime: time.Now(),
 	}
 }
 
 // Start initializes the complete relay pipeline and begins streaming
 func (r *CameraRelay) Start(ctx context.Context) error {
+	started := time.Now()
 	r.logger.Info("starting camera relay",
-		"stream_url", r.stream.URL,
-		"expires_at", r.stream.ExpiresAt.Format(time.RFC3339))
+		"stream_url", redactedURL(r.source),
+		"expires_at", r.source.ExpiresAt())
 
-	// Create WebRTC bridge to Cloudflare with unique camera ID for track naming
+	// Create WebRTC bridge to the SFU with unique camera ID for track naming
 	var err error
-	r.webrtcBridge, err = bridge.NewBridge(r.ctx, r.cameraID, r.cfClient, r.logger.With("component", "bridge"))
+	r.webrtcBridge, err = bridge.NewBridge(r.ctx, r.cameraID, r.backend, r.logger.With("component", "bridge"))
 	if err != nil {
 		return fmt.Errorf("create bridge: %w", err)
 	}
+	r.webrtcBridge.OnKeyframeRequest = r.keyframeRequested
+	r.webrtcBridge.SetGeneration(r.generation)
+	r.webrtcBridge.OnPanic = r.panicked
+	r.webrtcBridge.SetMemoryAccount(r.memory)
+	bridgeConfig := r.bridgeConfig
+	if r.transcoder != nil && r.transcoder.TranscodesVideo() {
+		bridgeConfig.SampleTrack = false // Transcoded video arrives as RTP
+	}
+	if err := r.webrtcBridge.SetConfig(bridgeConfig); err != nil {
+		return err
+	}
+	if err := r.webrtcBridge.SetPacerConfig(r.pacerConfig); err != nil {
+		return err
+	}
+	if r.resumeID != "" {
+		r.webrtcBridge.ResumeSession(r.resumeID)
+	}
+
+	if r.audioOnly {
+		r.webrtcBridge.SetAudioOnly()
+	}
+
+	// With both tracks relayed, keep them on one timeline; audio turned on
+	// later gets sync with the next relay
+	r.webrtcBridge.SetAVSync(r.audioEnabled.Load() && !r.audioOnly)
+
+	// The track is offered before the camera's DESCRIBE arrives, so it uses
+	// the codec the camera sent last time. Transcoded video is always H.264.
+	videoCodec := r.videoCodec
+	if videoCodec == "" || (r.transcoder != nil && r.transcoder.TranscodesVideo()) {
+		videoCodec = "H264"
+	}
+	if err := r.webrtcBridge.SetVideoCodec(videoCodec); err != nil {
+		return fmt.Errorf("set video codec: %w", err)
+	}
+
+	// The SFU negotiation and the RTSP handshake don't depend on each other,
+	// so they run concurrently. Playback starts once both are done; only the
+	// first track write waits for ICE to connect (see awaitConnection).
+	setupCtx, cancelSetup := context.WithCancel(ctx)
+	defer cancelSetup()
+
+	var (
+		setupErr  error
+		setupOnce sync.Once
+	)
+	fail := func(err error) {
+		setupOnce.Do(func() {
+			setupErr = err
+			cancelSetup() // Abandon the other half
+		})
+	}
+
+	negotiated := make(chan struct{})
+	goroutines.Go("relay.negotiate", r.cameraID, func() {
+		defer close(negotiated)
+		if err := r.negotiate(setupCtx); err != nil {
+			fail(err)
+		}
+	})
+	if err := r.connectRTSP(setupCtx); err != nil {
+		fail(err)
+	}
+	<-negotiated
+	if setupErr != nil {
+		return setupErr
+	}
+
+	// Give recorders the codec parameters before the first frame arrives
+	info := mediaInfoFromChannels(r.media.Tracks())
+	info.AudioOnly = r.audioOnly
+	for _, rec := range r.recorders {
+		if mir, ok := rec.(MediaInfoRecorder); ok {
+			mir.RecordMediaInfo(r.cameraID, info)
+		}
+	}
+
+	if r.transcoder != nil {
+		out := TranscodeOutput{
+			Video: r.webrtcBridge.WriteVideoRTP,
+			Audio: r.webrtcBridge.WriteAudioRTP,
+		}
+		if err := r.transcoder.Start(info, out); err != nil {
+			return fmt.Errorf("start transcoder: %w", err)
+		}
+	}
+
+	// Start playing
+	if err := r.media.Play(ctx); err != nil {
+		return fmt.Errorf("start playback: %w", err)
+	}
+
+	r.logger.Info("RTSP playback started - relay is active",
+		"webrtc_state", r.webrtcBridge.GetConnectionState().String(),
+		"setup_time", time.Since(started).Round(time.Millisecond))
 
-	// Create Cloudflare session
+	// Start monitoring goroutines
+	r.wg.Add(2)
+	go func() {
+		defer r.wg.Done()
+		defer goroutines.Track("relay.statsLoop", r.cameraID)()
+		defer recovery.Recover("relay.statsLoop", r.panicked)
+		r.statsLoop()
+	}()
+	go func() {
+		defer r.wg.Done()
+		defer goroutines.Track("relay.monitorLoop", r.cameraID)()
+		defer recovery.Recover("relay.monitorLoop", r.panicked)
+		r.monitorLoop()
+	}()
+
+	// Start reading packets
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer goroutines.Track("relay.readLoop", r.cameraID)()
+		defer recovery.Recover("relay.readLoop", r.panicked) // Also covers processors and recorders
+		r.readLoop()
+	}()
+
+	r.started.Store(true)
+	r.emit(EventRelayStarted, nil)
+	return nil
+}
+
+// negotiate creates the SFU session and publishes the tracks
+func (r *CameraRelay) negotiate(ctx context.Context) error {
+	// Create SFU session
 	if err := r.webrtcBridge.CreateSession(ctx); err != nil {
 		return fmt.Errorf("create session: %w", err)
 	}
@@ -96,35 +268,96 @@ func (r *CameraRelay) Start(ctx context.Context) error {
 	r.logger.Info("WebRTC bridge established",
 		"session_id", r.webrtcBridge.GetSessionID(),
 		"state", r.webrtcBridge.GetConnectionState().String())
+	return nil
+}
 
-	// Wait for PeerConnection to reach "connected" state before starting RTSP
-	// This ensures ICE connectivity is fully established before we start sending RTP packets
-	// Without this, we may send packets before the peer connection is ready, causing them to be dropped
-	r.logger.Info("waiting for WebRTC connection to be established")
-	if err := r.waitForConnection(ctx); err != nil {
-		return fmt.Errorf("wait for WebRTC connection: %w", err)
+// connectRTSP connects to the camera, wires the RTP processors and sets up
+// the tracks, stopping short of PLAY
+func (r *CameraRelay) connectRTSP(ctx context.Context) error {
+	// Create RTSP client, unless the source reads its camera another way
+	if ms, ok := r.source.(MediaSource); ok {
+		if r.media = ms.Media(); r.media == nil {
+			return errors.New("source has no media to read")
+		}
+	} else {
+		client := rtspClient.NewClient(r.source.URL(), r.logger.With("component", "rtsp"))
+		client.SetTransport(r.rtspTransport)
+		client.SetOptions(r.rtspOptions)
+		r.media = rtspMedia{client}
 	}
-	r.logger.Info("WebRTC connection established, starting RTSP stream")
-
-	// Create RTSP client
-	r.rtspConn = rtspClient.NewClient(r.stream.URL, r.logger.With("component", "rtsp"))
 
 	// Connect to RTSP server
-	if err := r.rtspConn.Connect(ctx); err != nil {
+	if err := r.media.Connect(ctx); err != nil {
 		return fmt.Errorf("connect RTSP: %w", err)
 	}
+	r.probe.Store(r.media.Probe())
+	r.opusAudio = r.media.AudioCodec() == "OPUS"
+	if r.opusAudio {
+		r.logger.Info("camera offers Opus audio, passing it through")
+	}
+	if r.audioOnly {
+		r.media.SkipMedia("video")
+		if len(r.media.Tracks()) == 0 {
+			return fmt.Errorf("audio-only relay: camera has no audio track")
+		}
+		// Audio reaches WebRTC only as Opus: the camera's own, or AAC
+		// through the transcoder
+		if !r.opusAudio && (r.transcoder == nil || !r.transcoder.TranscodesAudio()) {
+			return fmt.Errorf("audio-only relay requires Opus audio or audio transcoding")
+		}
+	}
+
+	// A camera that switched codecs needs a track offered with the new one
+	r.videoCodec = r.media.VideoCodec()
+	if r.videoCodec == "" {
+		r.videoCodec = "H264" // No video set up; the processor is never fed
+	}
+	transcoded := r.transcoder != nil && r.transcoder.TranscodesVideo()
+	if !r.audioOnly && !transcoded && r.videoCodec != r.webrtcBridge.VideoCodec() {
+		if r.OnVideoCodec != nil {
+			r.OnVideoCodec(r.cameraID, r.videoCodec)
+		}
+		return fmt.Errorf("camera sends %s, track offered as %s: %w", r.videoCodec, r.webrtcBridge.VideoCodec(), ErrVideoCodecChanged)
+	}
 
 	// Setup RTP processors
-	r.h264Proc = rtp.NewH264Processor()
 	r.aacProc = rtp.NewAACProcessor()
 
-	// Setup H.264 frame handler
-	r.h264Proc.OnFrame = func(nalus []byte, timestamp uint32, keyframe bool) {
+	// Setup video frame handler
+	onVideoFrame := func(nalus []byte, timestamp uint32, keyframe bool) {
+		arrivedAt := time.Now() // Last packet of the frame was just read
+		if nalus = r.processVideo(nalus, timestamp, keyframe); nalus == nil {
+			return
+		}
+
 		r.videoFrameCount.Add(1)
 		frameCount := r.videoFrameCount.Load()
+		if keyframe {
+			r.lastKeyframe.Store(time.Now().UnixNano())
+			r.keyframes.keyframe()
+		}
+
+		for _, rec := range r.recorders {
+			rec.RecordVideo(r.cameraID, nalus, timestamp, keyframe)
+		}
+
+		// Playback starts while ICE may still be connecting; the first frame
+		// holds the read loop until the connection can carry it
+		if !r.awaitConnection() {
+			return
+		}
+
+		// The transcoder sees both tracks so its MPEG-TS input carries every
+		// stream ffmpeg probes for, even when only one is re-encoded
+		if r.transcoder != nil {
+			r.transcoder.RecordVideo(r.cameraID, nalus, timestamp, keyframe)
+			if r.transcoder.TranscodesVideo() {
+				return
+			}
+		}
 
 		// Write to WebRTC bridge with original RTSP timestamp (passthrough)
-		if err := r.webrtcBridge.WriteVideoSample(nalus, timestamp); err != nil {
+		if err := r.webrtcBridge.WriteVideoSampleAt(nalus, timestamp, arrivedAt); err != nil {
 			r.logger.Error("failed to write video sample",
 				"frame_count", frameCount,
 				"timestamp", timestamp,
@@ -137,6 +370,7 @@ func (r *CameraRelay) Start(ctx context.Context) error {
 		// Log successful writes periodically
 		if frameCount == 1 {
 			r.logger.Info("first video frame written successfully",
+				"time_to_first_frame", time.Since(r.startTime).Round(time.Millisecond),
 				"keyframe", keyframe,
 				"timestamp", timestamp,
 				"size_bytes", len(nalus),
@@ -151,55 +385,103 @@ func (r *CameraRelay) Start(ctx context.Context) error {
 		}
 	}
 
-	// Setup AAC frame handler (audio not transcoded yet)
+	var err error
+	if r.videoProc, err = rtp.NewVideoProcessor(r.videoCodec, onVideoFrame); err != nil {
+		return fmt.Errorf("camera video: %w", err)
+	}
+	if seeder, ok := r.videoProc.(rtp.ParameterSetSeeder); ok {
+		if sets := r.media.VideoParameterSets(); len(sets) > 0 {
+			seeder.SetParameterSets(sets)
+			r.logger.Info("seeded video parameter sets from SDP", "count", len(sets))
+		}
+	}
+
+	// G.711 cameras (generic RTSP sources) reach WebRTC only through the
+	// transcoder, which takes the samples raw; like Opus, recorders and
+	// frame processors never see them
+	if codec := r.media.AudioCodec(); rtp.IsG711(codec) {
+		if r.g711Proc, err = rtp.NewG711Processor(codec); err != nil {
+			return fmt.Errorf("camera audio: %w", err)
+		}
+		r.g711Proc.OnFrame = func(samples []byte, timestamp uint32) {
+			r.audioFrameCount.Add(1)
+			if r.transcoder != nil && r.transcoder.TranscodesAudio() && r.awaitConnection() {
+				r.transcoder.RecordAudio(r.cameraID, samples, timestamp)
+			}
+		}
+	}
+
+	// Setup AAC frame handler (audio reaches WebRTC only through a transcoder)
 	r.aacProc.OnFrame = func(frame []byte, timestamp uint32) {
+		if frame = r.processAudio(frame, timestamp); frame == nil {
+			return
+		}
+
 		r.audioFrameCount.Add(1)
-		// TODO: Transcode AAC to Opus for Cloudflare
-		// For now, we just count the frames
-		// When audio is enabled, call: r.webrtcBridge.WriteAudioSample(frame, timestamp)
+		for _, rec := range r.recorders {
+			rec.RecordAudio(r.cameraID, frame, timestamp)
+		}
+		if r.transcoder != nil && r.awaitConnection() {
+			r.transcoder.RecordAudio(r.cameraID, frame, timestamp)
+		}
 	}
 
+	// Packets are put back in sequence order before depacketization
+	r.videoReorder = rtp.NewReorderBuffer(r.reorderDepth, func(packet *pionRTP.Packet) {
+		if err := r.videoProc.ProcessPacket(packet); err != nil {
+			r.logger.Warn("failed to process video packet", "codec", r.videoCodec, "error", err)
+		}
+	})
+	r.audioReorder = rtp.NewReorderBuffer(r.reorderDepth, func(packet *pionRTP.Packet) {
+		if r.opusAudio {
+			r.forwardOpus(packet)
+			return
+		}
+		if r.g711Proc != nil {
+			if err := r.g711Proc.ProcessPacket(packet); err != nil {
+				r.logger.Warn("failed to process G.711 packet", "error", err)
+			}
+			return
+		}
+		if err := r.aacProc.ProcessPacket(packet); err != nil {
+			r.logger.Warn("failed to process AAC packet", "error", err)
+		}
+	})
+
 	// Setup RTP packet handler
-	r.rtspConn.OnRTPPacket = func(channel byte, packet *pionRTP.Packet) {
-		ch, ok := r.rtspConn.Channels[channel]
+	onPacket := func(channel byte, packet *pionRTP.Packet) {
+		ch, ok := r.media.Tracks()[channel]
 		if !ok {
 			return
 		}
+		for _, t := range r.taps {
+			t.TapRTP(r.cameraID, ch.MediaType, packet)
+		}
 
 		if ch.MediaType == "video" {
 			r.videoPacketCount.Add(1)
-			if err := r.h264Proc.ProcessPacket(packet); err != nil {
-				r.logger.Warn("failed to process H.264 packet", "error", err)
-			}
+			r.videoReorder.Push(packet)
 		} else if ch.MediaType == "audio" {
+			// The client reuses the packet once this returns. Video is
+			// copied as it is depacketized; audio payloads reach the
+			// recorders and the pacer as they are, so they get their own.
 			r.audioPacketCount.Add(1)
-			if err := r.aacProc.ProcessPacket(packet); err != nil {
-				r.logger.Warn("failed to process AAC packet", "error", err)
-			}
+			r.audioReorder.Push(packet.Clone())
 		}
 	}
 
+	// A resumed session numbers its packets afresh
+	r.media.SetHandlers(onPacket, func() {
+		r.videoReorder.Reset()
+		r.audioReorder.Reset()
+		r.logger.Info("RTSP session resumed without regenerating the stream")
+		r.emit(EventRTSPReconnect, nil)
+	})
+
 	// Setup all tracks
-	if err := r.rtspConn.SetupTracks(ctx); err != nil {
+	if err := r.media.SetupTracks(ctx); err != nil {
 		return fmt.Errorf("setup tracks: %w", err)
 	}
-
-	// Start playing
-	if err := r.rtspConn.Play(ctx); err != nil {
-		return fmt.Errorf("start playback: %w", err)
-	}
-
-	r.logger.Info("RTSP playback started - relay is active")
-
-	// Start monitoring goroutines
-	r.wg.Add(2)
-	go r.statsLoop()
-	go r.monitorLoop()
-
-	// Start reading packets
-	r.wg.Add(1)
-	go r.readLoop()
-
 	return nil
 }
 
@@ -216,6 +498,8 @@ func (r *CameraRelay) waitForConnection(ctx context.Context) error {
 		case <-waitCtx.Done():
 			return fmt.Errorf("timeout waiting for connection (state=%s): %w",
 				r.webrtcBridge.GetConnectionState().String(), waitCtx.Err())
+		case <-r.webrtcBridge.Connected():
+			return nil
 		case <-ticker.C:
 			state := r.webrtcBridge.GetConnectionState()
 			r.logger.Debug("checking connection state", "state", state.String())
@@ -232,6 +516,45 @@ func (r *CameraRelay) waitForConnection(ctx context.Context) error {
 	}
 }
 
+// forwardOpus writes a camera's Opus packet straight to the audio track. The
+// track is Opus at 48kHz already, so payload, sequence numbers and timestamps
+// pass through; recorders, frame processors and the transcoder only handle
+// AAC and never see it.
+func (r *CameraRelay) forwardOpus(packet *pionRTP.Packet) {
+	r.audioFrameCount.Add(1) // One Opus frame per packet
+	if !r.audioEnabled.Load() || !r.awaitConnection() {
+		return
+	}
+	if err := r.webrtcBridge.WriteAudioRTP(packet); err != nil {
+		r.logger.Debug("failed to write Opus packet", "error", err)
+	}
+}
+
+// awaitConnection blocks until the peer connection first reaches connected,
+// reporting whether it did. Only the first call waits; a connection that
+// never comes up is reported as a WebRTC disconnect so the relay is recreated,
+// and every later write is dropped.
+func (r *CameraRelay) awaitConnection() bool {
+	r.connectOnce.Do(func() {
+		if state := r.webrtcBridge.GetConnectionState(); state.String() != "connected" {
+			r.logger.Info("waiting for WebRTC connection before the first write", "state", state.String())
+		}
+		if err := r.waitForConnection(r.ctx); err != nil {
+			r.connectErr = err
+			if r.ctx.Err() != nil {
+				return // Stopping
+			}
+			r.logger.Error("WebRTC connection not established", "error", err)
+			r.emit(EventWebRTCDisconnect, err)
+
+			if r.OnWebRTCDisconnect != nil {
+				r.OnWebRTCDisconnect(r.cameraID, err)
+			}
+		}
+	})
+	return r.connectErr == nil
+}
+
 // Stop gracefully stops the relay
 func (r *CameraRelay) Stop() error {
 	r.logger.Info("stopping camera relay")
@@ -240,8 +563,8 @@ func (r *CameraRelay) Stop() error {
 	r.cancel()
 
 	// Close RTSP connection (stops packet reading)
-	if r.rtspConn != nil {
-		if err := r.rtspConn.Close(); err != nil {
+	if r.media != nil {
+		if err := r.media.Close(); err != nil {
 			r.logger.Error("error closing RTSP connection", "error", err)
 		}
 	}
@@ -249,6 +572,12 @@ func (r *CameraRelay) Stop() error {
 	// Wait for goroutines to exit
 	r.wg.Wait()
 
+	if r.transcoder != nil {
+		if err := r.transcoder.Close(); err != nil {
+			r.logger.Error("error closing transcoder", "error", err)
+		}
+	}
+
 	// Close WebRTC bridge
 	if r.webrtcBridge != nil {
 		if err := r.webrtcBridge.Close(); err != nil {
@@ -256,6 +585,10 @@ func (r *CameraRelay) Stop() error {
 		}
 	}
 
+	if r.started.Load() {
+		r.emit(EventRelayStopped, nil)
+	}
+
 	r.logger.Info("camera relay stopped",
 		"duration", time.Since(r.startTime),
 		"video_packets", r.videoPacketCount.Load(),
@@ -266,12 +599,11 @@ func (r *CameraRelay) Stop() error {
 
 // readLoop reads RTP packets from RTSP connection
 func (r *CameraRelay) readLoop() {
-	defer r.wg.Done()
-
 	r.logger.Info("starting packet read loop")
 
-	if err := r.rtspConn.ReadPackets(r.ctx); err != nil && r.ctx.Err() == nil {
+	if err := r.media.ReadPackets(r.ctx); err != nil && r.ctx.Err() == nil {
 		r.logger.Error("RTSP read error", "error", err)
+		r.emit(EventRTSPDisconnect, err)
 
 		// Notify about RTSP disconnect for recovery
 		if r.OnRTSPDisconnect != nil {
@@ -284,8 +616,6 @@ func (r *CameraRelay) readLoop() {
 
 // statsLoop periodically logs relay statistics
 func (r *CameraRelay) statsLoop() {
-	defer r.wg.Done()
-
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
@@ -308,18 +638,35 @@ func (r *CameraRelay) statsLoop() {
 
 // monitorLoop monitors WebRTC connection state
 func (r *CameraRelay) monitorLoop() {
-	defer r.wg.Done()
-
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
 	lastState := r.webrtcBridge.GetConnectionState()
+	lastStalled := r.webrtcBridge.WriteStats().Stalled
+	stallReported := false
+	var restartDeadline time.Time // Set while an ICE restart has yet to reconnect
+
+	lost := func(err error) {
+		r.logger.Error("WebRTC connection lost", "error", err)
+		r.emit(EventWebRTCDisconnect, err)
+
+		if r.OnWebRTCDisconnect != nil {
+			r.OnWebRTCDisconnect(r.cameraID, err)
+		}
+	}
 
 	for {
 		select {
 		case <-r.ctx.Done():
 			return
 		case <-ticker.C:
+			// Chaos mode: drop the RTSP connection so readLoop reports a disconnect
+			if r.faults.KillRTSP(r.cameraID) {
+				if err := r.media.Abort(); err != nil {
+					r.logger.Debug("error closing RTSP connection for injected fault", "error", err)
+				}
+			}
+
 			currentState := r.webrtcBridge.GetConnectionState()
 
 			// Detect state changes
@@ -328,34 +675,150 @@ func (r *CameraRelay) monitorLoop() {
 					"from", lastState.String(),
 					"to", currentState.String())
 
-				// Handle disconnections
-				if currentState.String() == "failed" || currentState.String() == "disconnected" {
-					r.logger.Error("WebRTC connection lost", "state", currentState.String())
-
-					if r.OnWebRTCDisconnect != nil {
-						r.OnWebRTCDisconnect(r.cameraID, fmt.Errorf("WebRTC state: %s", currentState.String()))
+				// Handle disconnections: an ICE restart first, recreating the
+				// relay only if that can't be negotiated or doesn't reconnect
+				switch {
+				case currentState == webrtc.PeerConnectionStateConnected && !restartDeadline.IsZero():
+					restartDeadline = time.Time{}
+					r.logger.Info("WebRTC reconnected after ICE restart")
+					r.emit(EventICERestart, nil)
+				case currentState == webrtc.PeerConnectionStateFailed || currentState == webrtc.PeerConnectionStateDisconnected:
+					if !restartDeadline.IsZero() {
+						break // Restart in progress; the deadline below decides
 					}
+					if err := r.restartICE(); err != nil {
+						r.logger.Warn("ICE restart failed, recreating relay", "state", currentState.String(), "error", err)
+						lost(fmt.Errorf("WebRTC state: %s", currentState.String()))
+						break
+					}
+					restartDeadline = time.Now().Add(iceRestartTimeout)
 				}
 
 				lastState = currentState
 			}
+
+			if !restartDeadline.IsZero() && time.Now().After(restartDeadline) {
+				restartDeadline = time.Time{}
+				lost(fmt.Errorf("no reconnect %s after ICE restart (state %s)", iceRestartTimeout, currentState.String()))
+			}
+
+			// A hung transport can block writes long before ICE consent
+			// checks move the connection to disconnected
+			stalled := r.webrtcBridge.WriteStats().Stalled
+			if !stallReported && (r.webrtcBridge.WriteStalled() || stalled > lastStalled) {
+				stallReported = true
+				err := fmt.Errorf("track writes stalled (%d dropped, state %s)", stalled, currentState.String())
+				r.logger.Error("WebRTC transport stalled", "stalled_writes", stalled, "state", currentState.String())
+				r.emit(EventWebRTCDisconnect, err)
+
+				if r.OnWebRTCDisconnect != nil {
+					r.OnWebRTCDisconnect(r.cameraID, err)
+				}
+			}
+			lastStalled = stalled
 		}
 	}
 }
 
+// restartICE renegotiates the bridge's connection with fresh ICE credentials
+func (r *CameraRelay) restartICE() error {
+	r.logger.Warn("WebRTC connection lost, restarting ICE")
+	ctx, cancel := context.WithTimeout(r.ctx, iceRestartTimeout)
+	defer cancel()
+	return r.webrtcBridge.RestartICE(ctx)
+}
+
+// keyframeRequested handles a PLI/FIR from the SFU. Nest honors no RTCP, so
+// when requests keep going unanswered the only remedy is a new stream.
+func (r *CameraRelay) keyframeRequested() {
+	if !r.keyframes.request(time.Now()) {
+		return
+	}
+
+	r.logger.Warn("SFU keyframe requests unanswered by camera, requesting a fresh stream")
+	if r.OnKeyframeStarved != nil {
+		r.OnKeyframeStarved(r.cameraID)
+	}
+}
+
+// panicked handles a panic recovered in one of the camera's goroutines. The
+// goroutine is gone, so the relay is reported failed and restarted as a whole;
+// other cameras are unaffected.
+func (r *CameraRelay) panicked(err *recovery.PanicError) {
+	r.logger.Error("recovered panic, restarting camera",
+		"goroutine", err.Goroutine,
+		"panic", fmt.Sprint(err.Value),
+		"stack", string(err.Stack))
+	r.emit(EventRelayPanic, err)
+
+	if r.OnPanic != nil {
+		r.OnPanic(r.cameraID, err)
+	}
+}
+
+// AudioEnabled reports whether the relay forwards the camera's audio
+func (r *CameraRelay) AudioEnabled() bool {
+	return r.audioEnabled.Load()
+}
+
+// Probe returns the capabilities the camera advertised when the relay
+// connected, or nil before that
+func (r *CameraRelay) Probe() *rtspClient.ProbeResult {
+	return r.probe.Load()
+}
+
 // GetStats returns current relay statistics
 func (r *CameraRelay) GetStats() RelayStats {
+	var lastKeyframe time.Time
+	if ns := r.lastKeyframe.Load(); ns != 0 {
+		lastKeyframe = time.Unix(0, ns)
+	}
+	videoQuality, audioQuality := r.webrtcBridge.Quality()
+	videoTrack, audioTrack := r.webrtcBridge.TrackNames()
+	if r.audioOnly {
+		videoTrack = ""
+	}
+	var videoFormat *rtp.SPSInfo
+	if sp, ok := r.videoProc.(rtp.SPSInfoProvider); ok {
+		if info, ok := sp.SPSInfo(); ok {
+			videoFormat = &info
+		}
+	}
+
 	return RelayStats{
 		CameraID:         r.cameraID,
 		DeviceID:         r.deviceID,
+		VideoCodec:       r.videoCodec,
+		VideoFormat:      videoFormat,
 		SessionID:        r.webrtcBridge.GetSessionID(),
+		Generation:       r.generation,
+		VideoTrack:       videoTrack,
+		AudioTrack:       audioTrack,
 		Uptime:           time.Since(r.startTime),
 		VideoPackets:     r.videoPacketCount.Load(),
 		VideoFrames:      r.videoFrameCount.Load(),
 		AudioPackets:     r.audioPacketCount.Load(),
 		AudioFrames:      r.audioFrameCount.Load(),
 		WebRTCState:      r.webrtcBridge.GetConnectionState().String(),
-		StreamExpiresAt:  r.stream.ExpiresAt,
+		StreamExpiresAt:  r.source.ExpiresAt(),
+		LastKeyframe:     lastKeyframe,
+		AudioOnly:        r.audioOnly,
+		AudioEnabled:     r.audioEnabled.Load(),
+		VideoQuality:     videoQuality,
+		AudioQuality:     audioQuality,
+		VideoLatency:     r.webrtcBridge.Latency(),
+		Writes:           r.webrtcBridge.WriteStats(),
+		Peer:             r.webrtcBridge.PeerStats(),
+		VideoSilent:      r.webrtcBridge.VideoSilent(),
+		Pacer:            r.webrtcBridge.PacerStats(),
+		KeyframeReplays:  r.webrtcBridge.KeyframeReplays(),
+		Bandwidth:        r.webrtcBridge.Bandwidth(),
+		ICERestarts:      r.webrtcBridge.ICERestarts(),
+		Sync:             r.webrtcBridge.Sync(),
+		WebRTC:           r.webrtcBridge.GetWebRTCStats(),
+		VideoReorder:     reorderStats(r.videoReorder),
+		AudioReorder:     reorderStats(r.audioReorder),
+		RTSP:             rtspStats(r.media),
 	}
 }
 
@@ -363,12 +826,52 @@ func (r *CameraRelay) GetStats() RelayStats {
 type RelayStats struct {
 	CameraID         string
 	DeviceID         string
+	VideoCodec       string // As sent by the camera, e.g. "H264" or "H265"
+	VideoFormat      *rtp.SPSInfo // Resolution, profile and frame rate from the camera's SPS; nil until one parses (H.264 only)
 	SessionID        string
+	Generation       uint64 // Relays created for the camera so far, this one included
+	VideoTrack       string // Track names on the SFU; VideoTrack is empty for audio-only relays
+	AudioTrack       string
 	Uptime           time.Duration
 	VideoPackets     uint64
 	VideoFrames      uint64
 	AudioPackets     uint64
 	AudioFrames      uint64
 	WebRTCState      string
-	StreamExpiresAt  time.Time
+	StreamExpiresAt  time.Time // Zero for a source that doesn't expire
+	LastKeyframe     time.Time // Zero until the first keyframe
+	AudioOnly        bool      // No video is relayed; frame rate and keyframes don't apply
+	AudioEnabled     bool      // Audio forwarding is on for the camera
+	VideoQuality     bridge.TrackQuality // From SFU receiver reports
+	AudioQuality     bridge.TrackQuality
+	VideoLatency     bridge.LatencyStats // Camera arrival → track write, recent frames
+	Writes           bridge.WriteStats   // Stalled and failed track writes
+	Peer             bridge.PeerStats    // Bytes sent and selected ICE pair, polled from the PeerConnection
+	VideoSilent      bool                // Connected, but no video left the host in the last poll
+	Pacer            bridge.PacerStats
+	KeyframeReplays  uint64           // Cached keyframes replayed for joining viewers
+	Bandwidth        bridge.BandwidthStats // REMB estimate against camera bitrate, and frames dropped to fit
+	ICERestarts      uint64                // ICE restarts negotiated after the connection dropped
+	Sync             bridge.SyncStats      // Audio and video drift from the shared timeline
+	WebRTC           bridge.WebRTCStats    // Bytes sent, NACKs and RR loss/RTT per track, from pion's stats interceptor
+	VideoReorder     rtp.ReorderStats // Out-of-order and lost RTP packets ahead of depacketization
+	AudioReorder     rtp.ReorderStats
+	RTSP             rtspClient.ClientStats // Reception from the camera: bitrate, jitter, loss and read timeouts per channel
+}
+
+// rtspStats returns the camera connection's reception counters; zero before
+// the relay connects
+func rtspStats(c MediaConn) rtspClient.ClientStats {
+	if c == nil {
+		return rtspClient.ClientStats{}
+	}
+	return c.Stats()
+}
+
+// reorderStats returns a reorder buffer's counters; zero before RTSP setup
+func reorderStats(b *rtp.ReorderBuffer) rtp.ReorderStats {
+	if b == nil {
+		return rtp.ReorderStats{}
+	}
+	return b.Stats()
 }
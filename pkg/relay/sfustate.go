@@ -0,0 +1,103 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+)
+
+const (
+	sfuStateInterval = time.Minute      // How often sessions are compared with the SFU's view
+	sfuStateGrace    = 30 * time.Second // Relays younger than this may still be settling
+	sfuStateStrikes  = 2                // Consecutive drifted checks before a relay is recreated
+	sfuStateTimeout  = 10 * time.Second // Per-camera state request timeout
+)
+
+// checkSFUState compares the relay's published tracks with the SFU's view of
+// the session. It returns true once they have disagreed on sfuStateStrikes
+// consecutive checks while the peer connection looked healthy. Only called
+// from the multi-camera state loop.
+func (r *CameraRelay) checkSFUState(ctx context.Context) (bool, error) {
+	if r.webrtcBridge.GetConnectionState().String() != "connected" || time.Since(r.startTime) < sfuStateGrace {
+		r.driftStrikes = 0
+		return false, nil
+	}
+
+	drift, err := r.webrtcBridge.CheckSFUState(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(drift) == 0 {
+		r.driftStrikes = 0
+		return false, nil
+	}
+
+	r.driftStrikes++
+	r.logger.Warn("SFU session state disagrees with bridge",
+		"session_id", r.webrtcBridge.GetSessionID(),
+		"problems", drift,
+		"strikes", r.driftStrikes)
+	if r.driftStrikes < sfuStateStrikes {
+		return false, nil
+	}
+
+	r.emit(EventStateDrift, errors.New(strings.Join(drift, "; ")))
+	return true, nil
+}
+
+// sfuStateLoop periodically checks every relay's session against the SFU
+func (mcr *MultiCameraRelay) sfuStateLoop() {
+	defer mcr.wg.Done()
+	defer goroutines.Track("relay.sfuState", "")()
+
+	ticker := time.NewTicker(sfuStateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-mcr.ctx.Done():
+			return
+		case <-ticker.C:
+			mcr.checkSFUState()
+		}
+	}
+}
+
+// checkSFUState recreates relays whose session drifted from the SFU's view.
+// The bridge believes it is connected, so nothing else would notice.
+func (mcr *MultiCameraRelay) checkSFUState() {
+	mcr.mu.RLock()
+	relays := make(map[string]*CameraRelay, len(mcr.relays))
+	for cameraID, relay := range mcr.relays {
+		relays[cameraID] = relay
+	}
+	mcr.mu.RUnlock()
+
+	for cameraID, relay := range relays {
+		ctx, cancel := context.WithTimeout(mcr.ctx, sfuStateTimeout)
+		drifted, err := relay.checkSFUState(ctx)
+		cancel()
+
+		if err != nil {
+			if mcr.ctx.Err() != nil {
+				return
+			}
+			mcr.logger.Warn("failed to check SFU session state", "camera_id", cameraID, "error", err)
+			continue
+		}
+		if !drifted {
+			continue
+		}
+
+		total := mcr.stateDrifts.Add(1)
+		mcr.logger.Error("SFU session state drift, recreating relay",
+			"camera_id", cameraID,
+			"total_state_drifts", total)
+
+		// Recreated with a new session in the next reconciliation loop
+		mcr.removeRelay(cameraID, relay)
+	}
+}
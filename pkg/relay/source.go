@@ -0,0 +1,81 @@
+package relay
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/nest"
+)
+
+// Source is where a CameraRelay reads its camera: a Nest stream the stream
+// manager generates and extends, or a fixed RTSP URL. A source that isn't
+// read over RTSP also implements MediaSource.
+type Source interface {
+	// URL is the RTSP URL to connect to
+	URL() string
+
+	// ExpiresAt is when URL stops working; zero if it doesn't expire
+	ExpiresAt() time.Time
+}
+
+// NestSource reads a Nest stream. Extensions move its expiry in place, so
+// the source follows them.
+func NestSource(stream *nest.RTSPStream) Source {
+	return nestSource{stream}
+}
+
+type nestSource struct {
+	stream *nest.RTSPStream
+}
+
+func (s nestSource) URL() string          { return s.stream.URL }
+func (s nestSource) ExpiresAt() time.Time { return s.stream.ExpiresAt }
+
+// NestWebRTCSource reads a Nest WebRTC stream, for cameras without RTSP.
+// Its peer must be a MediaPeer; the source has no URL.
+func NestWebRTCSource(stream *nest.WebRTCStream) Source {
+	return nestWebRTCSource{stream}
+}
+
+// MediaPeer is a WebRTC stream's peer that relays can read
+type MediaPeer interface {
+	// Media attaches a new relay to the stream
+	Media() MediaConn
+}
+
+type nestWebRTCSource struct {
+	stream *nest.WebRTCStream
+}
+
+func (s nestWebRTCSource) URL() string          { return "" }
+func (s nestWebRTCSource) ExpiresAt() time.Time { return s.stream.ExpiresAt }
+
+// Media reads the stream through its peer; nil if the peer can't be read
+func (s nestWebRTCSource) Media() MediaConn {
+	if p, ok := s.stream.Peer.(MediaPeer); ok {
+		return p.Media()
+	}
+	return nil
+}
+
+// URLSource is an RTSP URL that stays valid, e.g. a local ONVIF camera or
+// a mediamtx path. Credentials go in the URL's userinfo.
+type URLSource string
+
+// URL returns the source itself
+func (s URLSource) URL() string { return string(s) }
+
+// ExpiresAt is zero: the URL doesn't expire
+func (s URLSource) ExpiresAt() time.Time { return time.Time{} }
+
+// redactedURL is a source's URL with any password masked, for logs
+func redactedURL(s Source) string {
+	if _, ok := s.(MediaSource); ok && s.URL() == "" {
+		return "(WebRTC)"
+	}
+	u, err := url.Parse(s.URL())
+	if err != nil {
+		return "(unparsable URL)"
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,38 @@
+package relay
+
+import (
+	pionRTP "github.com/pion/rtp"
+)
+
+// Transcoder is an external re-encoding stage for media the pure-Go pipeline
+// cannot forward as-is (H.265 video, AAC or G.711 audio, uplinks too slow for
+// the camera's bitrate). The relay feeds it every access unit through the
+// Recorder methods, stops writing the transcoded tracks to the bridge itself,
+// and forwards the RTP packets the transcoder hands back instead. Unlike
+// other recorders, it also gets G.711 audio through RecordAudio, as raw
+// samples (MediaInfo.AudioCodec "PCMU" or "PCMA").
+type Transcoder interface {
+	Recorder
+
+	// Start launches the transcoder for one RTSP session. Output packets are
+	// delivered to out until Close.
+	Start(info MediaInfo, out TranscodeOutput) error
+
+	// TranscodesVideo and TranscodesAudio report which tracks are routed
+	// through the transcoder; the others keep the direct path.
+	TranscodesVideo() bool
+	TranscodesAudio() bool
+
+	Close() error
+}
+
+// TranscodeOutput receives the transcoder's packetized output: H.264 for the
+// video track and Opus for the audio track
+type TranscodeOutput struct {
+	Video func(pkt *pionRTP.Packet) error
+	Audio func(pkt *pionRTP.Packet) error
+}
+
+// TranscoderFactory returns the transcoder for a camera, or nil when the
+// camera needs none. It is called each time a relay is created.
+type TranscoderFactory func(cameraID, deviceID string) Transcoder
@@ -0,0 +1,131 @@
+package replay
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// DefaultCaptureLimit caps each camera's capture file
+const DefaultCaptureLimit = 256 << 20
+
+// Capture writes each camera's incoming RTP to <dir>/<camera>-<time>.pcap
+// for later replay. It implements relay.PacketTap. Video goes to VideoPort
+// and audio to AudioPort, so the files replay with default Options.
+type Capture struct {
+	dir    string
+	limit  int64
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	cameras map[string]*captureFile
+}
+
+// captureFile is one camera's open capture
+type captureFile struct {
+	f       *os.File
+	buf     *bufio.Writer
+	pcap    *PcapWriter
+	written int64
+	full    bool // Limit reached; later packets are not captured
+}
+
+// NewCapture captures into dir, creating it if needed. Each camera's file
+// stops growing at limit bytes (zero uses DefaultCaptureLimit).
+func NewCapture(dir string, limit int64, logger *slog.Logger) (*Capture, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create capture directory: %w", err)
+	}
+	if limit <= 0 {
+		limit = DefaultCaptureLimit
+	}
+	return &Capture{
+		dir:     dir,
+		limit:   limit,
+		logger:  logger.With("component", "capture"),
+		cameras: make(map[string]*captureFile),
+	}, nil
+}
+
+// TapRTP implements relay.PacketTap
+func (c *Capture) TapRTP(cameraID, mediaType string, packet *rtp.Packet) {
+	port := uint16(VideoPort)
+	if mediaType == "audio" {
+		port = AudioPort
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cf, err := c.open(cameraID)
+	if err != nil || cf.full {
+		return
+	}
+
+	if err := cf.pcap.WritePacket(time.Now(), port, packet); err != nil {
+		c.logger.Warn("failed to capture packet, capture stopped", "camera_id", cameraID, "error", err)
+		cf.full = true
+		return
+	}
+	cf.written += int64(16 + 28 + packet.MarshalSize())
+	if cf.written >= c.limit {
+		c.logger.Warn("capture size limit reached, capture stopped", "camera_id", cameraID, "bytes", cf.written)
+		cf.full = true
+		cf.buf.Flush()
+	}
+}
+
+// open returns the camera's capture, creating the file on first use. A
+// failure is logged once and leaves a full placeholder. Callers hold c.mu.
+func (c *Capture) open(cameraID string) (*captureFile, error) {
+	if cf, ok := c.cameras[cameraID]; ok {
+		return cf, nil
+	}
+
+	path := filepath.Join(c.dir, fmt.Sprintf("%s-%s.pcap", cameraID, time.Now().UTC().Format("20060102T150405Z")))
+	f, err := os.Create(path)
+	if err != nil {
+		c.logger.Warn("failed to create capture file", "camera_id", cameraID, "error", err)
+		c.cameras[cameraID] = &captureFile{full: true}
+		return nil, err
+	}
+	buf := bufio.NewWriterSize(f, 64<<10)
+	pw, err := NewPcapWriter(buf)
+	if err != nil {
+		f.Close()
+		c.cameras[cameraID] = &captureFile{full: true}
+		return nil, err
+	}
+
+	cf := &captureFile{f: f, buf: buf, pcap: pw, written: 24}
+	c.cameras[cameraID] = cf
+	c.logger.Info("capturing camera RTP", "camera_id", cameraID, "path", path)
+	return cf, nil
+}
+
+// Close flushes and closes every capture file. A camera relayed again
+// afterwards starts a new file.
+func (c *Capture) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var firstErr error
+	for id, cf := range c.cameras {
+		if cf.f != nil {
+			if err := cf.buf.Flush(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			if err := cf.f.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		delete(c.cameras, id)
+	}
+	return firstErr
+}
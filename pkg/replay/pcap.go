@@ -0,0 +1,209 @@
+package replay
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// pcap link types the reader understands
+const (
+	linkEthernet  = 1
+	linkRaw       = 101 // Bare IPv4 or IPv6; what PcapWriter writes
+	linkLinuxSLL  = 113
+	linkIPv4      = 228
+	linkIPv6      = 229
+	linkLinuxSLL2 = 276
+)
+
+const (
+	pcapMagic      = 0xa1b2c3d4 // Microsecond timestamps
+	pcapMagicNanos = 0xa1b23c4d
+	snapLen        = 65535
+	maxRecordSize  = 1 << 18 // Larger records mean a corrupt file
+)
+
+// Packet is one RTP packet from a capture
+type Packet struct {
+	At      time.Time // Capture time
+	DstPort uint16    // UDP destination port, which identifies the media
+	RTP     *rtp.Packet
+}
+
+// ReadPcap reads the UDP RTP packets from a classic pcap file (not pcapng),
+// as written by PcapWriter, tcpdump or Wireshark. Non-UDP packets and UDP
+// payloads that aren't RTP are skipped; IP fragments are not reassembled.
+func ReadPcap(r io.Reader) ([]Packet, error) {
+	var hdr [24]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("read pcap header: %w", err)
+	}
+
+	var order binary.ByteOrder
+	var nanos bool
+	switch {
+	case binary.LittleEndian.Uint32(hdr[0:]) == pcapMagic:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(hdr[0:]) == pcapMagic:
+		order = binary.BigEndian
+	case binary.LittleEndian.Uint32(hdr[0:]) == pcapMagicNanos:
+		order, nanos = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(hdr[0:]) == pcapMagicNanos:
+		order, nanos = binary.BigEndian, true
+	default:
+		return nil, fmt.Errorf("not a pcap file (pcapng is not supported)")
+	}
+	link := order.Uint32(hdr[20:]) & 0x0fffffff
+
+	var packets []Packet
+	var rec [16]byte
+	for {
+		if _, err := io.ReadFull(r, rec[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return packets, nil
+			}
+			return packets, fmt.Errorf("read pcap record: %w", err)
+		}
+		sec, frac, n := order.Uint32(rec[0:]), order.Uint32(rec[4:]), order.Uint32(rec[8:])
+		if n > maxRecordSize {
+			return packets, fmt.Errorf("pcap record of %d bytes", n)
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return packets, fmt.Errorf("read pcap record: %w", err)
+		}
+
+		if !nanos {
+			frac *= 1000
+		}
+		port, payload, ok := udpPayload(link, data)
+		if !ok {
+			continue
+		}
+		pkt := &rtp.Packet{}
+		if err := pkt.Unmarshal(payload); err != nil || pkt.Version != 2 {
+			continue
+		}
+		packets = append(packets, Packet{At: time.Unix(int64(sec), int64(frac)), DstPort: port, RTP: pkt})
+	}
+}
+
+// udpPayload strips the link, IP and UDP headers from a captured frame
+func udpPayload(link uint32, data []byte) (dstPort uint16, payload []byte, ok bool) {
+	var etherType uint16
+	switch link {
+	case linkEthernet:
+		if len(data) < 14 {
+			return 0, nil, false
+		}
+		etherType, data = binary.BigEndian.Uint16(data[12:]), data[14:]
+		for etherType == 0x8100 && len(data) >= 4 { // VLAN tags
+			etherType, data = binary.BigEndian.Uint16(data[2:]), data[4:]
+		}
+	case linkLinuxSLL:
+		if len(data) < 16 {
+			return 0, nil, false
+		}
+		etherType, data = binary.BigEndian.Uint16(data[14:]), data[16:]
+	case linkLinuxSLL2:
+		if len(data) < 20 {
+			return 0, nil, false
+		}
+		etherType, data = binary.BigEndian.Uint16(data[0:]), data[20:]
+	case linkRaw, linkIPv4, linkIPv6:
+	default:
+		return 0, nil, false
+	}
+	if len(data) == 0 {
+		return 0, nil, false
+	}
+
+	switch {
+	case etherType == 0x0800 || (etherType == 0 && data[0]>>4 == 4):
+		ihl := int(data[0]&0x0f) * 4
+		if len(data) < 20 || ihl < 20 || len(data) < ihl || data[9] != 17 {
+			return 0, nil, false
+		}
+		if binary.BigEndian.Uint16(data[6:])&0x1fff != 0 {
+			return 0, nil, false // Not the first fragment
+		}
+		data = data[ihl:]
+	case etherType == 0x86dd || (etherType == 0 && data[0]>>4 == 6):
+		if len(data) < 40 || data[6] != 17 {
+			return 0, nil, false // Extension headers aren't followed
+		}
+		data = data[40:]
+	default:
+		return 0, nil, false
+	}
+
+	if len(data) < 8 {
+		return 0, nil, false
+	}
+	return binary.BigEndian.Uint16(data[2:]), data[8:], true
+}
+
+// PcapWriter writes RTP packets as UDP over IPv4 to a classic pcap file, one
+// destination port per media, from 127.0.0.1 to 127.0.0.1
+type PcapWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewPcapWriter writes the pcap header to w
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:], pcapMagicNanos)
+	binary.LittleEndian.PutUint16(hdr[4:], 2)
+	binary.LittleEndian.PutUint16(hdr[6:], 4)
+	binary.LittleEndian.PutUint32(hdr[16:], snapLen)
+	binary.LittleEndian.PutUint32(hdr[20:], linkRaw)
+	if _, err := w.Write(hdr); err != nil {
+		return nil, fmt.Errorf("write pcap header: %w", err)
+	}
+	return &PcapWriter{w: w}, nil
+}
+
+// WritePacket appends pkt, captured at at, sent to dstPort
+func (pw *PcapWriter) WritePacket(at time.Time, dstPort uint16, pkt *rtp.Packet) error {
+	size := pkt.MarshalSize()
+	if 28+size > snapLen {
+		return fmt.Errorf("RTP packet of %d bytes too large to capture", size)
+	}
+	n := 16 + 28 + size
+	if cap(pw.buf) < n {
+		pw.buf = make([]byte, n)
+	}
+	b := pw.buf[:n]
+	clear(b[:44])
+
+	// Record header
+	binary.LittleEndian.PutUint32(b[0:], uint32(at.Unix()))
+	binary.LittleEndian.PutUint32(b[4:], uint32(at.Nanosecond()))
+	binary.LittleEndian.PutUint32(b[8:], uint32(n-16))
+	binary.LittleEndian.PutUint32(b[12:], uint32(n-16))
+
+	// IPv4, loopback to loopback; checksums are left zero
+	ip := b[16:]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(28+size))
+	ip[8] = 64
+	ip[9] = 17
+	copy(ip[12:], []byte{127, 0, 0, 1})
+	copy(ip[16:], []byte{127, 0, 0, 1})
+
+	udp := ip[20:]
+	binary.BigEndian.PutUint16(udp[0:], dstPort)
+	binary.BigEndian.PutUint16(udp[2:], dstPort)
+	binary.BigEndian.PutUint16(udp[4:], uint16(8+size))
+
+	if _, err := pkt.MarshalTo(udp[8:]); err != nil {
+		return fmt.Errorf("marshal RTP packet: %w", err)
+	}
+	_, err := pw.w.Write(b)
+	return err
+}
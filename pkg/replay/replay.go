@@ -0,0 +1,145 @@
+// Package replay feeds recorded RTP captures through the relay's media
+// pipeline offline: H264Processor → pacer → packetizer → track writers, as
+// a live camera relay would, with the SFU replaced by an RTPSink. Captures
+// are classic pcap files of RTP over UDP, written by Capture or any packet
+// sniffer, so timestamp bugs seen on a user's camera can be reproduced
+// deterministically in tests.
+package replay
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/bridge"
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtp"
+	pionRTP "github.com/pion/rtp"
+)
+
+// Destination ports Capture writes each media to, and Run's defaults
+const (
+	VideoPort = 5000
+	AudioPort = 5002
+)
+
+// Options controls a replay
+type Options struct {
+	VideoPort uint16 // UDP port carrying H.264; defaults to VideoPort
+	AudioPort uint16 // UDP port carrying AAC; defaults to AudioPort
+
+	// Realtime feeds packets at their captured spacing instead of as fast as
+	// the pacer accepts them, reproducing the camera's arrival pattern
+	Realtime bool
+
+	Logger *slog.Logger // Defaults to discarding
+}
+
+// Result summarizes a replay
+type Result struct {
+	VideoPackets uint64 // RTP packets fed to the H.264 processor
+	AudioPackets uint64
+	VideoFrames  uint64 // Access units written to the bridge
+	Keyframes    uint64
+	AudioFrames  uint64 // AAC frames depacketized; audio reaches WebRTC only through a transcoder
+	Skipped      uint64 // Packets on other ports
+	Dropped      uint64 // Oversized NALUs dropped by the H.264 processor
+}
+
+// Run replays packets through a camera pipeline and writes the video RTP the
+// bridge produces to sink. It returns once every frame has been written.
+func Run(ctx context.Context, cameraID string, packets []Packet, sink bridge.RTPSink, opts Options) (Result, error) {
+	if opts.VideoPort == 0 {
+		opts.VideoPort = VideoPort
+	}
+	if opts.AudioPort == 0 {
+		opts.AudioPort = AudioPort
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	logger = logger.With("camera_id", cameraID, "component", "replay")
+
+	b, err := bridge.NewOfflineBridge(ctx, cameraID, sink, discard{}, logger.With("component", "bridge"))
+	if err != nil {
+		return Result{}, fmt.Errorf("create bridge: %w", err)
+	}
+	defer b.Close()
+
+	var res Result
+	var writeErr error
+	h264 := rtp.NewH264Processor()
+	h264.OnFrame = func(nalus []byte, timestamp uint32, keyframe bool) {
+		if writeErr != nil {
+			return
+		}
+		res.VideoFrames++
+		if keyframe {
+			res.Keyframes++
+		}
+		writeErr = b.WriteVideoSample(nalus, timestamp)
+	}
+	aac := rtp.NewAACProcessor()
+	aac.OnFrame = func([]byte, uint32) { res.AudioFrames++ }
+
+	var start, first time.Time
+	for _, pkt := range packets {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		if opts.Realtime {
+			if start.IsZero() {
+				start, first = time.Now(), pkt.At
+			}
+			if d := pkt.At.Sub(first) - time.Since(start); d > 0 {
+				time.Sleep(d)
+			}
+		}
+
+		switch pkt.DstPort {
+		case opts.VideoPort:
+			res.VideoPackets++
+			if err := h264.ProcessPacket(pkt.RTP); err != nil {
+				logger.Warn("failed to process H.264 packet", "sequence", pkt.RTP.SequenceNumber, "error", err)
+			}
+		case opts.AudioPort:
+			res.AudioPackets++
+			if err := aac.ProcessPacket(pkt.RTP); err != nil {
+				logger.Warn("failed to process AAC packet", "sequence", pkt.RTP.SequenceNumber, "error", err)
+			}
+		default:
+			res.Skipped++
+		}
+		if writeErr != nil {
+			return res, fmt.Errorf("write video frame: %w", writeErr)
+		}
+	}
+	res.Dropped = h264.Dropped()
+
+	if err := b.Flush(ctx); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// RunFile replays a pcap file; see Run
+func RunFile(ctx context.Context, cameraID, path string, sink bridge.RTPSink, opts Options) (Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Result{}, fmt.Errorf("open capture: %w", err)
+	}
+	defer f.Close()
+
+	packets, err := ReadPcap(f)
+	if err != nil {
+		return Result{}, err
+	}
+	return Run(ctx, cameraID, packets, sink, opts)
+}
+
+// discard is the audio sink; the replayed pipeline publishes no audio
+type discard struct{}
+
+func (discard) WriteRTP(*pionRTP.Packet) error { return nil }
@@ -0,0 +1,97 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtsp/rtsptest"
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+)
+
+// collector records the RTP the bridge sends
+type collector struct {
+	mu      sync.Mutex
+	packets []*rtp.Packet
+}
+
+func (c *collector) WriteRTP(p *rtp.Packet) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.packets = append(c.packets, p)
+	return nil
+}
+
+func TestReplayPcap(t *testing.T) {
+	const frames, gop = 30, 10
+
+	// Three GOPs at 30 fps, plus a packet on another port
+	var buf bytes.Buffer
+	pw, err := NewPcapWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := rtp.NewPacketizer(1200, 96, 1234, &codecs.H264Payloader{}, rtp.NewFixedSequencer(1), 90000)
+	units := rtsptest.SyntheticGOP(gop)
+	at := time.Unix(1700000000, 0)
+	var captured []uint32
+	for i := 0; i < frames; i++ {
+		pkts := p.Packetize(units[i%gop], 3000)
+		captured = append(captured, pkts[0].Timestamp)
+		for _, pkt := range pkts {
+			if err := pw.WritePacket(at, VideoPort, pkt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		at = at.Add(time.Second / 30)
+	}
+	if err := pw.WritePacket(at, 6000, &rtp.Packet{Header: rtp.Header{Version: 2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	packets, err := ReadPcap(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !packets[0].At.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("first packet at %v", packets[0].At)
+	}
+
+	var sink collector
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res, err := Run(ctx, "cam1", packets, &sink, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Parameter sets arrive in their own STAP-A and join their keyframe's
+	// access unit
+	if res.VideoFrames != frames || res.Keyframes != frames/gop || res.Skipped != 1 {
+		t.Errorf("result = %+v", res)
+	}
+
+	// Every frame leaves in order with its captured timestamp
+	var sent []uint32
+	for _, pkt := range sink.packets {
+		if pkt.Marker && (len(sent) == 0 || sent[len(sent)-1] != pkt.Timestamp) {
+			sent = append(sent, pkt.Timestamp)
+		}
+	}
+	if len(sent) != frames {
+		t.Fatalf("sent %d frames, want %d", len(sent), frames)
+	}
+	for i := range sent {
+		if sent[i] != captured[i] {
+			t.Fatalf("frame %d sent with timestamp %d, captured %d", i, sent[i], captured[i])
+		}
+	}
+
+	// The bridge's pacer and writer loops end with the replay
+	if err := goroutines.Settle(ctx, "pacer.", "bridge."); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,347 @@
+// Package rtmpout restreams cameras to RTMP ingest servers (YouTube Live,
+// Twitch, ...) by muxing the relayed H.264/AAC access units into FLV.
+//
+// A Publisher is a relay.Recorder: it receives frames from the relay's read
+// goroutine, hands them to a per-camera writer goroutine through a bounded
+// queue, and reconnects with backoff when the ingest connection drops.
+package rtmpout
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/flv"
+	"github.com/AlexxIT/go2rtc/pkg/flv/amf"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/rtmp"
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtp"
+)
+
+const (
+	queueSize       = 256              // Frames buffered per camera before dropping
+	minRetryBackoff = 2 * time.Second  // First reconnect delay
+	maxRetryBackoff = 60 * time.Second // Reconnect delay cap
+	maxTimestampGap = 5 * time.Second  // Larger jumps are treated as a new RTSP session
+	maxBaseDelta    = 1 << 30          // RTP ticks after which a clock moves its base forward
+)
+
+// Publisher restreams selected cameras to RTMP
+type Publisher struct {
+	logger *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	streams map[string]*stream // Fixed at construction, read without locking
+}
+
+// frame is a copy of one access unit queued for the writer goroutine
+type frame struct {
+	video     bool
+	data      []byte
+	timestamp uint32
+	keyframe  bool
+}
+
+// stream is the publishing state for one camera
+type stream struct {
+	cameraID string
+	url      string
+	logger   *slog.Logger
+	frames   chan frame
+	dropped  atomic.Uint64
+
+	infoMu sync.Mutex
+	info   relay.MediaInfo
+
+	// Owned by the writer goroutine
+	conn        io.Writer
+	sps, pps    []byte
+	sentAudioHd bool
+	videoClock  clock
+	audioClock  clock
+}
+
+// NewPublisher creates a publisher for the given targets (cameraID →
+// rtmp://host/app/streamkey) and starts one writer goroutine per camera
+func NewPublisher(targets map[string]string, logger *slog.Logger) *Publisher {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := &Publisher{
+		logger:  logger,
+		ctx:     ctx,
+		cancel:  cancel,
+		streams: make(map[string]*stream),
+	}
+
+	for cameraID, url := range targets {
+		s := &stream{
+			cameraID: cameraID,
+			url:      url,
+			logger:   logger.With("camera_id", cameraID),
+			frames:   make(chan frame, queueSize),
+		}
+		p.streams[cameraID] = s
+
+		p.wg.Add(1)
+		go p.run(s)
+	}
+
+	return p
+}
+
+// Close stops all restreams
+func (p *Publisher) Close() error {
+	p.cancel()
+	p.wg.Wait()
+	return nil
+}
+
+// RecordMediaInfo stores the camera's codec parameters for the FLV headers
+func (p *Publisher) RecordMediaInfo(cameraID string, info relay.MediaInfo) {
+	s := p.streams[cameraID]
+	if s == nil {
+		return
+	}
+	s.infoMu.Lock()
+	s.info = info
+	s.infoMu.Unlock()
+}
+
+// RecordVideo queues an H.264 access unit
+func (p *Publisher) RecordVideo(cameraID string, au []byte, timestamp uint32, keyframe bool) {
+	p.enqueue(cameraID, frame{video: true, timestamp: timestamp, keyframe: keyframe}, au)
+}
+
+// RecordAudio queues an AAC access unit
+func (p *Publisher) RecordAudio(cameraID string, data []byte, timestamp uint32) {
+	p.enqueue(cameraID, frame{timestamp: timestamp}, data)
+}
+
+// enqueue copies the payload and hands it to the camera's writer without blocking
+func (p *Publisher) enqueue(cameraID string, f frame, data []byte) {
+	s := p.streams[cameraID]
+	if s == nil {
+		return
+	}
+
+	f.data = append([]byte(nil), data...)
+	select {
+	case s.frames <- f:
+	default:
+		if n := s.dropped.Add(1); n%100 == 1 {
+			s.logger.Warn("RTMP queue full, dropping frames", "dropped", n)
+		}
+	}
+}
+
+// run publishes one camera, reconnecting with exponential backoff
+func (p *Publisher) run(s *stream) {
+	defer p.wg.Done()
+
+	backoff := minRetryBackoff
+	for {
+		err := p.publish(s)
+		if p.ctx.Err() != nil {
+			return
+		}
+
+		s.logger.Error("RTMP publish failed, retrying", "error", err, "backoff", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-p.ctx.Done():
+			return
+		}
+
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// publish runs a single RTMP session until the connection fails or Close
+func (p *Publisher) publish(s *stream) error {
+	// Wait for a keyframe so the session starts with SPS/PPS and an IDR
+	var first frame
+	for {
+		select {
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		case f := <-s.frames:
+			if f.video && f.keyframe {
+				first = f
+			}
+		}
+		if first.data != nil {
+			break
+		}
+	}
+
+	s.sps, s.pps = rtp.ParameterSets(first.data)
+	if s.sps == nil || s.pps == nil {
+		return fmt.Errorf("keyframe without SPS/PPS")
+	}
+
+	conn, err := rtmp.DialPublish(s.url, flv.NewConsumer())
+	if err != nil {
+		return fmt.Errorf("dial RTMP: %w", err)
+	}
+	defer func() {
+		if c, ok := conn.(io.Closer); ok {
+			c.Close()
+		}
+	}()
+	s.conn = conn
+	s.sentAudioHd = false
+
+	s.infoMu.Lock()
+	info := s.info
+	s.infoMu.Unlock()
+
+	if err := s.writeHeader(info); err != nil {
+		return fmt.Errorf("write FLV header: %w", err)
+	}
+
+	s.logger.Info("RTMP publishing started", "url", redact(s.url))
+
+	s.videoClock = clock{}
+	s.audioClock = clock{}
+	epoch := time.Now()
+
+	if err := s.writeFrame(first, info, epoch); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		case f := <-s.frames:
+			if err := s.writeFrame(f, info, epoch); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// writeHeader sends the FLV file header, metadata and codec configuration
+func (s *stream) writeHeader(info relay.MediaInfo) error {
+	hasAudio := len(info.AudioConfig) > 0
+
+	b := []byte{'F', 'L', 'V', 1, flv.FlagsVideo, 0, 0, 0, 9, 0, 0, 0, 0}
+	meta := map[string]any{"videocodecid": flv.CodecH264}
+	if hasAudio {
+		b[4] |= flv.FlagsAudio
+		meta["audiocodecid"] = flv.CodecAAC
+		meta["audiosamplerate"] = info.AudioClockRate
+		meta["stereo"] = info.AudioChannels == 2
+	}
+
+	b = append(b, flv.EncodeTag(flv.TagData, 0, amf.EncodeItems("@setDataFrame", "onMetaData", meta))...)
+	b = append(b, avcSequenceHeader(s.sps, s.pps)...)
+	if hasAudio {
+		b = append(b, flv.EncodeTag(flv.TagAudio, 0, append([]byte{0xAF, 0}, info.AudioConfig...))...)
+		s.sentAudioHd = true
+	}
+
+	_, err := s.conn.Write(b)
+	return err
+}
+
+// writeFrame muxes one access unit into an FLV tag and sends it
+func (s *stream) writeFrame(f frame, info relay.MediaInfo, epoch time.Time) error {
+	var tag []byte
+
+	if f.video {
+		if f.keyframe {
+			// Re-announce parameter sets if the camera changed resolution/profile
+			sps, pps := rtp.ParameterSets(f.data)
+			if sps != nil && pps != nil && (string(sps) != string(s.sps) || string(pps) != string(s.pps)) {
+				s.sps, s.pps = sps, pps
+				if _, err := s.conn.Write(avcSequenceHeader(sps, pps)); err != nil {
+					return fmt.Errorf("write AVC sequence header: %w", err)
+				}
+			}
+		}
+
+		ms := s.videoClock.millis(f.timestamp, 90000, epoch)
+		hdr := []byte{2<<4 | flv.CodecH264, 1, 0, 0, 0}
+		if f.keyframe {
+			hdr[0] = 1<<4 | flv.CodecH264
+		}
+		tag = flv.EncodeTag(flv.TagVideo, ms, append(hdr, f.data...))
+	} else {
+		if !s.sentAudioHd || info.AudioClockRate == 0 {
+			return nil
+		}
+		ms := s.audioClock.millis(f.timestamp, uint32(info.AudioClockRate), epoch)
+		tag = flv.EncodeTag(flv.TagAudio, ms, append([]byte{0xAF, 1}, f.data...))
+	}
+
+	if _, err := s.conn.Write(tag); err != nil {
+		return fmt.Errorf("write FLV tag: %w", err)
+	}
+	return nil
+}
+
+// avcSequenceHeader builds the AVCDecoderConfigurationRecord video tag
+func avcSequenceHeader(sps, pps []byte) []byte {
+	payload := append([]byte{1<<4 | flv.CodecH264, 0, 0, 0, 0}, h264.EncodeConfig(sps, pps)...)
+	return flv.EncodeTag(flv.TagVideo, 0, payload)
+}
+
+// clock converts RTP timestamps to FLV milliseconds on a shared wall-clock
+// epoch, so audio and video that start at different moments stay aligned.
+// RTSP reconnects restart RTP timestamps; large jumps rebase the clock.
+type clock struct {
+	started bool
+	baseTS  uint32
+	baseMS  uint32
+	lastMS  uint32
+}
+
+func (c *clock) millis(ts, clockRate uint32, epoch time.Time) uint32 {
+	if !c.started {
+		c.started = true
+		c.baseTS = ts
+		c.baseMS = uint32(time.Since(epoch).Milliseconds())
+		c.lastMS = c.baseMS
+		return c.baseMS
+	}
+
+	delta := int64(int32(ts - c.baseTS))
+	ms := int64(c.baseMS) + delta*1000/int64(clockRate)
+	if ms < int64(c.lastMS) || ms-int64(c.lastMS) > maxTimestampGap.Milliseconds() {
+		// Discontinuity: continue from the last emitted time
+		c.baseTS = ts
+		c.baseMS = c.lastMS + 1
+		ms = int64(c.baseMS)
+	} else if delta >= maxBaseDelta {
+		// Move the base along before the delta overflows int32, which at
+		// 90 kHz would read a stream over 6.6 hours old as a backwards jump
+		c.baseTS = ts
+		c.baseMS = uint32(ms)
+	}
+
+	c.lastMS = uint32(ms)
+	return c.lastMS
+}
+
+// redact hides the stream key (last path element) when logging
+func redact(url string) string {
+	for i := len(url) - 1; i >= 0; i-- {
+		if url[i] == '/' {
+			return url[:i+1] + "****"
+		}
+	}
+	return url
+}
@@ -0,0 +1,228 @@
+package rtmpout
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/flv"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+)
+
+var (
+	testSPS = []byte{0x67, 0x64, 0x00, 0x1f, 0xac, 0xd9}
+	testPPS = []byte{0x68, 0xee, 0x3c, 0x80}
+	testIDR = []byte{0x65, 0x88, 0x84, 0x00}
+	testP   = []byte{0x41, 0x9a, 0x02}
+)
+
+// avc joins NAL units into a length-prefixed access unit
+func avc(nalus ...[]byte) []byte {
+	var au []byte
+	for _, nalu := range nalus {
+		au = binary.BigEndian.AppendUint32(au, uint32(len(nalu)))
+		au = append(au, nalu...)
+	}
+	return au
+}
+
+// tag is one FLV tag read back from a stream's output
+type tag struct {
+	kind    byte
+	ms      uint32
+	payload []byte
+}
+
+// readTags splits FLV tags (without the file header), checking each tag's
+// trailing size
+func readTags(t *testing.T, b []byte) []tag {
+	t.Helper()
+	var tags []tag
+	for len(b) > 0 {
+		if len(b) < 15 {
+			t.Fatalf("truncated tag: % x", b)
+		}
+		size := int(b[1])<<16 | int(b[2])<<8 | int(b[3])
+		ms := uint32(b[7])<<24 | uint32(b[4])<<16 | uint32(b[5])<<8 | uint32(b[6])
+		if len(b) < 11+size+4 {
+			t.Fatalf("tag of %d bytes truncated", size)
+		}
+		if prev := binary.BigEndian.Uint32(b[11+size:]); prev != uint32(11+size) {
+			t.Errorf("previous tag size = %d, want %d", prev, 11+size)
+		}
+		tags = append(tags, tag{kind: b[0], ms: ms, payload: b[11 : 11+size]})
+		b = b[11+size+4:]
+	}
+	return tags
+}
+
+func TestClockMillis(t *testing.T) {
+	type step struct {
+		ts   uint32
+		want uint32 // Milliseconds after the first frame's
+	}
+	for _, tt := range []struct {
+		name  string
+		rate  uint32
+		steps []step
+	}{
+		{"normal advance", 90000, []step{{1000, 0}, {4000, 33}, {91000, 1000}, {181000, 2000}}},
+		{"audio", 48000, []step{{0, 0}, {1024, 21}, {48000, 1000}}},
+		{"backwards jump", 90000, []step{{90000, 0}, {180000, 1000}, {500, 1001}, {9500, 1101}}},
+		{"gap over maxTimestampGap", 90000, []step{{1000, 0}, {1000 + 6*90000, 1}, {1000 + 7*90000, 1001}}},
+		{"within maxTimestampGap", 90000, []step{{1000, 0}, {1000 + 4*90000, 4000}}},
+		{"RTP timestamp wrap", 90000, []step{{1<<32 - 45000, 0}, {45000, 1000}, {135000, 2000}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var c clock
+			epoch := time.Now()
+			start := c.millis(tt.steps[0].ts, tt.rate, epoch)
+			for _, s := range tt.steps[1:] {
+				if got := c.millis(s.ts, tt.rate, epoch) - start; got != s.want {
+					t.Errorf("millis(%d) = start+%d, want start+%d", s.ts, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestClockStartOffset(t *testing.T) {
+	// A track's first frame is placed at its arrival on the shared epoch
+	var c clock
+	epoch := time.Now().Add(-1500 * time.Millisecond)
+	if got := c.millis(123456, 90000, epoch); got < 1500 || got > 1600 {
+		t.Errorf("first frame at %d ms, want 1500", got)
+	}
+	if got := c.millis(123456+90000, 90000, epoch); got < 2500 || got > 2600 {
+		t.Errorf("frame a second later at %d ms, want 2500", got)
+	}
+}
+
+func TestClockLongRun(t *testing.T) {
+	// Eight hours of 4-second steps: past the 6.6 hours after which the
+	// delta from the first frame overflows int32, and across an RTP
+	// timestamp wrap. The clock must advance steadily throughout.
+	var c clock
+	ts := uint32(1<<32 - 90000*3600) // Wraps an hour in
+	start := c.millis(ts, 90000, time.Now())
+	for i := uint32(1); i <= 8*3600/4; i++ {
+		ts += 4 * 90000
+		if got, want := c.millis(ts, 90000, time.Time{})-start, i*4000; got != want {
+			t.Fatalf("after %v: start+%d ms, want start+%d", time.Duration(i)*4*time.Second, got, want)
+		}
+	}
+}
+
+func TestWriteFrame(t *testing.T) {
+	var out bytes.Buffer
+	s := &stream{
+		conn:        &out,
+		sps:         testSPS,
+		pps:         testPPS,
+		sentAudioHd: true,
+		videoClock:  clock{started: true},
+		audioClock:  clock{started: true},
+	}
+	info := relay.MediaInfo{AudioClockRate: 48000}
+
+	// Keyframe with unchanged parameter sets, then a P frame
+	key := avc(testSPS, testPPS, testIDR)
+	if err := s.writeFrame(frame{video: true, data: key, timestamp: 9000, keyframe: true}, info, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.writeFrame(frame{video: true, data: avc(testP), timestamp: 12000}, info, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	// Audio access unit
+	if err := s.writeFrame(frame{data: []byte{0x21, 0x10}, timestamp: 4800}, info, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tags := readTags(t, out.Bytes())
+	want := []tag{
+		{flv.TagVideo, 100, append([]byte{0x17, 1, 0, 0, 0}, key...)},
+		{flv.TagVideo, 133, append([]byte{0x27, 1, 0, 0, 0}, avc(testP)...)},
+		{flv.TagAudio, 100, []byte{0xAF, 1, 0x21, 0x10}},
+	}
+	if len(tags) != len(want) {
+		t.Fatalf("%d tags, want %d", len(tags), len(want))
+	}
+	for i := range want {
+		if tags[i].kind != want[i].kind || tags[i].ms != want[i].ms || !bytes.Equal(tags[i].payload, want[i].payload) {
+			t.Errorf("tag %d = {%d %d % x}, want {%d %d % x}", i,
+				tags[i].kind, tags[i].ms, tags[i].payload, want[i].kind, want[i].ms, want[i].payload)
+		}
+	}
+}
+
+func TestWriteFrameNewParameterSets(t *testing.T) {
+	var out bytes.Buffer
+	s := &stream{conn: &out, sps: testSPS, pps: testPPS, videoClock: clock{started: true}}
+
+	// The camera changed resolution: its keyframe carries another SPS
+	sps := []byte{0x67, 0x4d, 0x00, 0x28, 0x95}
+	key := avc(sps, testPPS, testIDR)
+	if err := s.writeFrame(frame{video: true, data: key, timestamp: 90000, keyframe: true}, relay.MediaInfo{}, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tags := readTags(t, out.Bytes())
+	if len(tags) != 2 {
+		t.Fatalf("%d tags, want the sequence header and the keyframe", len(tags))
+	}
+	header := append([]byte{0x17, 0, 0, 0, 0}, h264.EncodeConfig(sps, testPPS)...)
+	if tags[0].kind != flv.TagVideo || tags[0].ms != 0 || !bytes.Equal(tags[0].payload, header) {
+		t.Errorf("sequence header = {%d %d % x}, want {%d 0 % x}", tags[0].kind, tags[0].ms, tags[0].payload, flv.TagVideo, header)
+	}
+	if tags[1].ms != 1000 || tags[1].payload[0] != 0x17 || tags[1].payload[1] != 1 {
+		t.Errorf("keyframe = {%d % x}", tags[1].ms, tags[1].payload[:5])
+	}
+	if !bytes.Equal(s.sps, sps) {
+		t.Errorf("stream SPS = % x, want the new one", s.sps)
+	}
+
+	// The same parameter sets again are not re-announced
+	out.Reset()
+	if err := s.writeFrame(frame{video: true, data: key, timestamp: 180000, keyframe: true}, relay.MediaInfo{}, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(readTags(t, out.Bytes())); n != 1 {
+		t.Errorf("%d tags for a keyframe with known parameter sets, want 1", n)
+	}
+}
+
+func TestWriteFrameAudioBeforeHeader(t *testing.T) {
+	var out bytes.Buffer
+	s := &stream{conn: &out, audioClock: clock{started: true}}
+
+	// Without an AudioSpecificConfig sent, audio is dropped
+	if err := s.writeFrame(frame{data: []byte{0x21}, timestamp: 1024}, relay.MediaInfo{AudioClockRate: 48000}, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("wrote %d bytes of audio before its header", out.Len())
+	}
+}
+
+func TestAVCSequenceHeader(t *testing.T) {
+	got := avcSequenceHeader(testSPS, testPPS)
+	tags := readTags(t, got)
+	if len(tags) != 1 || tags[0].kind != flv.TagVideo || tags[0].ms != 0 {
+		t.Fatalf("tags = %+v, want one video tag at 0", tags)
+	}
+	p := tags[0].payload
+	// Keyframe, AVC, packet type 0 (sequence header), composition time 0
+	if !bytes.Equal(p[:5], []byte{0x17, 0, 0, 0, 0}) {
+		t.Errorf("tag header = % x", p[:5])
+	}
+	// AVCDecoderConfigurationRecord: version 1, profile/compat/level from the SPS
+	record := p[5:]
+	if record[0] != 1 || !bytes.Equal(record[1:4], testSPS[1:4]) {
+		t.Errorf("record header = % x", record[:4])
+	}
+	if !bytes.Contains(record, testSPS) || !bytes.Contains(record, testPPS) {
+		t.Errorf("record % x lacks the SPS or PPS", record)
+	}
+}
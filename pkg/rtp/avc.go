@@ -0,0 +1,36 @@
+package rtp
+
+import "encoding/binary"
+
+// SplitAVC splits an AVC (4-byte length-prefixed) access unit, as emitted by
+// H264Processor.OnFrame, into raw NAL units. Truncated trailing data is ignored.
+func SplitAVC(au []byte) [][]byte {
+	var nalus [][]byte
+	for len(au) >= 4 {
+		size := int(binary.BigEndian.Uint32(au))
+		au = au[4:]
+		if size > len(au) {
+			break
+		}
+		nalus = append(nalus, au[:size])
+		au = au[size:]
+	}
+	return nalus
+}
+
+// ParameterSets returns the SPS and PPS carried in an AVC access unit, if any.
+// Keyframes produced by H264Processor always carry both.
+func ParameterSets(au []byte) (sps, pps []byte) {
+	for _, nalu := range SplitAVC(au) {
+		if len(nalu) == 0 {
+			continue
+		}
+		switch nalu[0] & 0x1F {
+		case NALUTypeSPS:
+			sps = nalu
+		case NALUTypePPS:
+			pps = nalu
+		}
+	}
+	return sps, pps
+}
@@ -0,0 +1,108 @@
+package rtp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pion/rtp"
+)
+
+const (
+	// G711ClockRate is the sample rate of PCMU and PCMA (RFC 3551)
+	G711ClockRate = 8000
+
+	// maxG711Gap is the longest gap filled with silence; beyond it the
+	// stream is assumed to have restarted
+	maxG711Gap = G711ClockRate
+
+	// Encoded silence (a zero sample)
+	muLawSilence = 0xFF
+	aLawSilence  = 0xD5
+)
+
+// G711Processor handles PCMU/PCMA (G.711 μ-law and A-law) RTP
+// depacketization. Each payload is one sample per byte, so a frame is simply
+// the payload; gaps left by lost packets are filled with silence so the
+// sample count stays in step with the RTP clock for consumers that have no
+// timestamps, such as a raw ffmpeg input.
+type G711Processor struct {
+	ALaw    bool                                   // PCMA; otherwise PCMU
+	OnFrame func(samples []byte, timestamp uint32) // Called for every packet, and for each silence fill
+
+	started  bool
+	next     uint32 // Timestamp expected of the next packet
+	filled   uint64 // Samples of silence inserted
+	outdated uint64 // Late or duplicate packets dropped
+}
+
+// NewG711Processor creates a processor for an SDP encoding name, "PCMU" or
+// "PCMA"
+func NewG711Processor(codec string) (*G711Processor, error) {
+	switch strings.ToUpper(codec) {
+	case "PCMU":
+		return &G711Processor{}, nil
+	case "PCMA":
+		return &G711Processor{ALaw: true}, nil
+	}
+	return nil, fmt.Errorf("not a G.711 codec: %q", codec)
+}
+
+// IsG711 reports whether an SDP encoding name is PCMU or PCMA
+func IsG711(codec string) bool {
+	codec = strings.ToUpper(codec)
+	return codec == "PCMU" || codec == "PCMA"
+}
+
+// Filled returns how many samples of silence were inserted for lost packets
+func (p *G711Processor) Filled() uint64 {
+	return p.filled
+}
+
+// Outdated returns how many late or duplicate packets were dropped
+func (p *G711Processor) Outdated() uint64 {
+	return p.outdated
+}
+
+// ProcessPacket processes an RTP packet containing G.711 samples
+func (p *G711Processor) ProcessPacket(packet *rtp.Packet) error {
+	if len(packet.Payload) == 0 {
+		return nil
+	}
+
+	if p.started {
+		gap := int32(packet.Timestamp - p.next)
+		if gap < 0 && gap > -maxG711Gap {
+			p.outdated++
+			return nil
+		}
+		if gap > 0 && gap <= maxG711Gap {
+			p.fill(p.next, int(gap))
+		}
+		// Larger jumps either way are a new stream, not loss: resync
+	}
+
+	p.started = true
+	p.next = packet.Timestamp + uint32(len(packet.Payload))
+	if p.OnFrame != nil {
+		p.OnFrame(packet.Payload, packet.Timestamp)
+	}
+	return nil
+}
+
+// fill emits n samples of silence starting at timestamp
+func (p *G711Processor) fill(timestamp uint32, n int) {
+	p.filled += uint64(n)
+	if p.OnFrame == nil {
+		return
+	}
+
+	silence := byte(muLawSilence)
+	if p.ALaw {
+		silence = aLawSilence
+	}
+	samples := make([]byte, n)
+	for i := range samples {
+		samples[i] = silence
+	}
+	p.OnFrame(samples, timestamp)
+}
@@ -0,0 +1,44 @@
+package rtp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pion/rtp"
+)
+
+func TestG711Processor(t *testing.T) {
+	p, err := NewG711Processor("pcma")
+	if err != nil || !p.ALaw {
+		t.Fatalf("NewG711Processor = %+v, %v", p, err)
+	}
+
+	var out []byte
+	var timestamps []uint32
+	p.OnFrame = func(samples []byte, ts uint32) {
+		out = append(out, samples...)
+		timestamps = append(timestamps, ts)
+	}
+	pkt := func(ts uint32, n int) *rtp.Packet {
+		return &rtp.Packet{Header: rtp.Header{Timestamp: ts}, Payload: bytes.Repeat([]byte{1}, n)}
+	}
+
+	p.ProcessPacket(pkt(1000, 160))
+	p.ProcessPacket(pkt(1320, 160))   // One packet lost
+	p.ProcessPacket(pkt(1160, 160))   // ...and arriving late
+	p.ProcessPacket(pkt(900000, 160)) // Stream restarted
+
+	if len(out) != 4*160 || p.Filled() != 160 || p.Outdated() != 1 {
+		t.Fatalf("%d samples, %d filled, %d outdated", len(out), p.Filled(), p.Outdated())
+	}
+	if out[160] != aLawSilence || out[320] != 1 {
+		t.Errorf("gap not filled with silence: % x", out[158:162])
+	}
+	if want := []uint32{1000, 1160, 1320, 900000}; len(timestamps) != 4 || timestamps[1] != want[1] || timestamps[3] != want[3] {
+		t.Errorf("timestamps = %v, want %v", timestamps, want)
+	}
+
+	if _, err := NewG711Processor("MPEG4-GENERIC"); err == nil {
+		t.Error("AAC accepted as G.711")
+	}
+}
@@ -1,8 +1,10 @@
 package rtp
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/pion/rtp"
 )
@@ -18,45 +20,86 @@ const (
 	NALUTypeAUD         = 9
 	NALUTypeSTAPA       = 24 // Single-Time Aggregation Packet
 	NALUTypeFUA         = 28 // Fragmentation Unit A
+
+	// DefaultMaxNALUSize caps FU-A reassembly. Nest IDRs are a few hundred
+	// KB; a NALU growing past this means lost end fragments or a broken
+	// stream, not a real frame.
+	DefaultMaxNALUSize = 4 << 20
+
+	// reassemblyBufferSize is the FU-A buffer kept between NALUs
+	reassemblyBufferSize = 1024 * 1024
 )
 
-// H264Processor handles H.264 RTP depacketization
+// H264Processor handles H.264 RTP depacketization (RFC 6184). NAL units
+// sharing an RTP timestamp are assembled into one access unit, emitted when
+// the timestamp moves on; the marker bit only lets a complete access unit
+// out without waiting for the next packet.
 type H264Processor struct {
 	buffer   []byte // Buffer for accumulating fragmented NALUs
 	sps      []byte
 	pps      []byte
-	OnFrame  func(nalus []byte, timestamp uint32, keyframe bool) // Called when a complete frame is ready
+	spsInfo  atomic.Pointer[SPSInfo] // Parsed from sps, read by stats
+	OnFrame  func(nalus []byte, timestamp uint32, keyframe bool) // Called when a complete access unit is ready
+
+	au          []byte // Access unit being assembled, AVC (length-prefixed) form
+	auTimestamp uint32
+	auKeyframe  bool // The access unit holds an IDR slice
+	auParams    bool // The access unit carries its own SPS
+
+	MaxNALUSize int    // Oversized NALUs are dropped (default DefaultMaxNALUSize)
+	discarding  bool   // Dropping fragments until the next FU-A start
+	dropped     uint64 // NALUs dropped for exceeding MaxNALUSize
 }
 
 // NewH264Processor creates a new H.264 RTP processor
 func NewH264Processor() *H264Processor {
 	return &H264Processor{
-		buffer: make([]byte, 0, 1024*1024), // 1MB initial buffer
+		buffer:      make([]byte, 0, reassemblyBufferSize),
+		MaxNALUSize: DefaultMaxNALUSize,
 	}
 }
 
+// Dropped returns how many NALUs were dropped for exceeding MaxNALUSize
+func (p *H264Processor) Dropped() uint64 {
+	return p.dropped
+}
+
 // ProcessPacket processes an RTP packet containing H.264 data
 func (p *H264Processor) ProcessPacket(packet *rtp.Packet) error {
 	if len(packet.Payload) == 0 {
 		return nil
 	}
 
-	payload := packet.Payload
-	naluType := payload[0] & 0x1F
+	// A new timestamp means the previous access unit is complete even if
+	// its marker was lost. A NALU never spans timestamps, so an unfinished
+	// fragment is abandoned with it.
+	if packet.Timestamp != p.auTimestamp {
+		if len(p.au) > 0 {
+			p.flush()
+		}
+		p.buffer = p.buffer[:0]
+	}
+	p.auTimestamp = packet.Timestamp
 
-	switch naluType {
+	var err error
+	switch packet.Payload[0] & 0x1F {
 	case NALUTypeFUA:
 		// Fragmentation Unit
-		return p.processFUA(packet)
+		err = p.processFUA(packet)
 
 	case NALUTypeSTAPA:
 		// Single-Time Aggregation Packet
-		return p.processSTAPA(packet)
+		err = p.processSTAPA(packet)
 
 	default:
 		// Single NAL Unit
-		return p.processSingleNALU(packet)
+		p.addNALU(packet.Payload)
+	}
+
+	if packet.Marker {
+		p.flush()
 	}
+	return err
 }
 
 // processFUA handles fragmented NAL units (FU-A)
@@ -74,20 +117,33 @@ func (p *H264Processor) processFUA(packet *rtp.Packet) error {
 	naluType := fuHeader & 0x1F
 
 	if start {
-		// Start of fragmented NALU
+		// Start of fragmented NALU. Give back memory an oversized NALU grew.
+		if cap(p.buffer) > reassemblyBufferSize {
+			p.buffer = make([]byte, 0, reassemblyBufferSize)
+		}
 		p.buffer = p.buffer[:0]
+		p.discarding = false
 
 		// Reconstruct NAL header
 		nalHeader := (fuIndicator & 0xE0) | naluType
 		p.buffer = append(p.buffer, nalHeader)
+	} else if p.discarding || len(p.buffer) == 0 {
+		return nil // Start fragment lost or NALU dropped
+	}
+
+	if p.MaxNALUSize > 0 && len(p.buffer)+len(payload) > p.MaxNALUSize {
+		p.discarding = true
+		p.dropped++
+		p.buffer = p.buffer[:0]
+		return fmt.Errorf("FU-A NALU exceeds %d bytes, dropped", p.MaxNALUSize)
 	}
 
 	// Append fragment
 	p.buffer = append(p.buffer, payload...)
 
 	if end {
-		// End of fragmented NALU - emit complete NALU with original timestamp
-		return p.emitNALU(p.buffer, naluType, packet.Timestamp, packet.Marker)
+		p.addNALU(p.buffer)
+		p.buffer = p.buffer[:0]
 	}
 
 	return nil
@@ -97,79 +153,55 @@ func (p *H264Processor) processFUA(packet *rtp.Packet) error {
 func (p *H264Processor) processSTAPA(packet *rtp.Packet) error {
 	payload := packet.Payload[1:] // Skip STAP-A header
 
-	nalus := make([]byte, 0, len(payload)*2)
-
 	for len(payload) > 2 {
 		// Read NALU size (2 bytes, big endian)
 		naluSize := binary.BigEndian.Uint16(payload[:2])
 		payload = payload[2:]
 
-		if len(payload) < int(naluSize) {
+		if naluSize == 0 || len(payload) < int(naluSize) {
 			return fmt.Errorf("STAP-A NALU size exceeds payload")
 		}
 
-		nalu := payload[:naluSize]
+		p.addNALU(payload[:naluSize])
 		payload = payload[naluSize:]
-
-		// Add to aggregated NALUs with length prefix
-		nalus = appendNALU(nalus, nalu)
-
-		// Extract SPS/PPS for later use
-		naluType := nalu[0] & 0x1F
-		if naluType == NALUTypeSPS {
-			p.sps = make([]byte, len(nalu))
-			copy(p.sps, nalu)
-		} else if naluType == NALUTypePPS {
-			p.pps = make([]byte, len(nalu))
-			copy(p.pps, nalu)
-		}
-	}
-
-	if len(nalus) > 0 && p.OnFrame != nil {
-		p.OnFrame(nalus, packet.Timestamp, false)
 	}
 
 	return nil
 }
 
-// processSingleNALU handles single NAL units
-func (p *H264Processor) processSingleNALU(packet *rtp.Packet) error {
-	nalu := packet.Payload
-	naluType := nalu[0] & 0x1F
-
-	return p.emitNALU(nalu, naluType, packet.Timestamp, packet.Marker)
-}
-
-// emitNALU emits a complete NALU with timestamp
-func (p *H264Processor) emitNALU(nalu []byte, naluType uint8, timestamp uint32, marker bool) error {
-	// Store SPS/PPS for later
-	if naluType == NALUTypeSPS {
-		p.sps = make([]byte, len(nalu))
-		copy(p.sps, nalu)
-	} else if naluType == NALUTypePPS {
-		p.pps = make([]byte, len(nalu))
-		copy(p.pps, nalu)
+// addNALU appends a complete NAL unit to the access unit being assembled
+func (p *H264Processor) addNALU(nalu []byte) {
+	switch nalu[0] & 0x1F {
+	case NALUTypeSPS:
+		p.setSPS(nalu)
+		p.auParams = true
+	case NALUTypePPS:
+		p.pps = append(p.pps[:0], nalu...)
+	case NALUTypeIFrame:
+		p.auKeyframe = true
 	}
 
-	// For keyframes, prepend SPS/PPS
-	var frame []byte
-	isKeyframe := naluType == NALUTypeIFrame
+	p.au = appendNALU(p.au, nalu)
+}
 
-	if isKeyframe && len(p.sps) > 0 && len(p.pps) > 0 {
-		frame = make([]byte, 0, len(p.sps)+len(p.pps)+len(nalu)+12)
+// flush emits the assembled access unit. Keyframes without in-band
+// parameter sets get the cached SPS/PPS prepended so they decode alone.
+func (p *H264Processor) flush() {
+	au, keyframe := p.au, p.auKeyframe
+	if keyframe && !p.auParams && len(p.sps) > 0 && len(p.pps) > 0 {
+		frame := make([]byte, 0, len(p.sps)+len(p.pps)+8+len(au))
 		frame = appendNALU(frame, p.sps)
 		frame = appendNALU(frame, p.pps)
-		frame = appendNALU(frame, nalu)
-	} else {
-		frame = make([]byte, 0, len(nalu)+4)
-		frame = appendNALU(frame, nalu)
+		au = append(frame, au...)
 	}
 
-	if p.OnFrame != nil && marker {
-		p.OnFrame(frame, timestamp, isKeyframe)
-	}
+	p.au = nil
+	p.auKeyframe = false
+	p.auParams = false
 
-	return nil
+	if len(au) > 0 && p.OnFrame != nil {
+		p.OnFrame(au, p.auTimestamp, keyframe)
+	}
 }
 
 // appendNALU appends a NALU with length prefix (AVC format)
@@ -185,6 +217,48 @@ func appendNALU(dst, nalu []byte) []byte {
 	return append(dst, nalu...)
 }
 
+// setSPS stores an SPS, parsing it when it changed
+func (p *H264Processor) setSPS(nalu []byte) {
+	if bytes.Equal(p.sps, nalu) {
+		return
+	}
+	p.sps = make([]byte, len(nalu))
+	copy(p.sps, nalu)
+
+	if info, err := ParseSPS(nalu); err == nil {
+		p.spsInfo.Store(&info)
+	} else {
+		p.spsInfo.Store(nil)
+	}
+}
+
+// SetParameterSets seeds the SPS and PPS from sprop-parameter-sets, so the
+// first keyframe decodes even if the camera sends its parameter sets only
+// out of band. In-band ones replace them as they arrive.
+func (p *H264Processor) SetParameterSets(sets [][]byte) {
+	for _, nalu := range sets {
+		if len(nalu) == 0 {
+			continue
+		}
+		switch nalu[0] & 0x1F {
+		case NALUTypeSPS:
+			p.setSPS(nalu)
+		case NALUTypePPS:
+			p.pps = append(p.pps[:0], nalu...)
+		}
+	}
+}
+
+// SPSInfo returns what the last SPS said about the stream, or false before
+// one parsed
+func (p *H264Processor) SPSInfo() (SPSInfo, bool) {
+	info := p.spsInfo.Load()
+	if info == nil {
+		return SPSInfo{}, false
+	}
+	return *info, true
+}
+
 // GetSPS returns the stored SPS
 func (p *H264Processor) GetSPS() []byte {
 	return p.sps
@@ -0,0 +1,87 @@
+package rtp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pion/rtp"
+)
+
+func TestH264Processor(t *testing.T) {
+	type frame struct {
+		nalus    [][]byte
+		ts       uint32
+		keyframe bool
+	}
+	var frames []frame
+	p := NewH264Processor()
+	p.OnFrame = func(au []byte, ts uint32, keyframe bool) {
+		frames = append(frames, frame{SplitAVC(au), ts, keyframe})
+	}
+
+	sps, pps := []byte{0x67, 1}, []byte{0x68, 2}
+	idr := []byte{0x65, 10, 11, 12, 13}
+	pkt := func(ts uint32, marker bool, payload []byte) *rtp.Packet {
+		return &rtp.Packet{Header: rtp.Header{Timestamp: ts, Marker: marker}, Payload: payload}
+	}
+
+	// Parameter sets in a STAP-A, then the IDR in two FU-A fragments
+	stap := []byte{NALUTypeSTAPA}
+	for _, n := range [][]byte{sps, pps} {
+		stap = append(stap, 0, byte(len(n)))
+		stap = append(stap, n...)
+	}
+	fu := func(header byte, body ...byte) []byte {
+		return append([]byte{0x60 | NALUTypeFUA, header | 5}, body...)
+	}
+	packets := []*rtp.Packet{
+		pkt(3000, false, stap),
+		pkt(3000, false, fu(0x80, idr[1:3]...)),
+		pkt(3000, true, fu(0x40, idr[3:]...)),
+		pkt(6000, false, []byte{0x41, 20}), // Marker missing: flushed by the next timestamp
+		pkt(6000, false, []byte{0x41, 21}), // Second slice of the same picture
+		pkt(9000, false, fu(0x80, 30)),     // End fragment lost...
+		pkt(12000, true, fu(0x40, 31)),     // ...and this start fragment
+		pkt(15000, true, []byte{0x41, 40}),
+	}
+	for _, pk := range packets {
+		if err := p.ProcessPacket(pk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(frames) != 3 {
+		t.Fatalf("got %d frames, want 3", len(frames))
+	}
+	if f := frames[0]; !f.keyframe || f.ts != 3000 || len(f.nalus) != 3 || !bytes.Equal(f.nalus[2], idr) {
+		t.Errorf("keyframe = %+v", f)
+	}
+	if f := frames[1]; f.keyframe || f.ts != 6000 || len(f.nalus) != 2 {
+		t.Errorf("second frame = %+v", f)
+	}
+	if f := frames[2]; f.ts != 15000 || len(f.nalus) != 1 {
+		t.Errorf("frame after loss = %+v", f)
+	}
+
+	// A later IDR without in-band parameter sets gets the cached ones
+	p.ProcessPacket(pkt(18000, true, idr))
+	if f := frames[len(frames)-1]; !f.keyframe || len(f.nalus) != 3 || !bytes.Equal(f.nalus[0], sps) {
+		t.Errorf("IDR without parameter sets = %+v", f)
+	}
+}
+
+func TestH264ProcessorSeededParameterSets(t *testing.T) {
+	var au []byte
+	p := NewH264Processor()
+	p.OnFrame = func(frame []byte, _ uint32, _ bool) { au = frame }
+
+	// From sprop-parameter-sets; the camera never sends them in-band
+	sps, pps := []byte{0x67, 1}, []byte{0x68, 2}
+	p.SetParameterSets([][]byte{sps, pps})
+
+	idr := []byte{0x65, 10, 11}
+	p.ProcessPacket(&rtp.Packet{Header: rtp.Header{Timestamp: 3000, Marker: true}, Payload: idr})
+	if gotSPS, gotPPS := ParameterSets(au); !bytes.Equal(gotSPS, sps) || !bytes.Equal(gotPPS, pps) {
+		t.Errorf("first keyframe = %x, want seeded SPS/PPS", au)
+	}
+}
@@ -0,0 +1,210 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pion/rtp"
+)
+
+const (
+	// H.265 NAL unit types
+	H265NALUTypeVPS  = 32
+	H265NALUTypeSPS  = 33
+	H265NALUTypePPS  = 34
+	H265NALUTypeAUD  = 35
+	H265NALUTypeAP   = 48 // Aggregation Packet
+	H265NALUTypeFU   = 49 // Fragmentation Unit
+	H265NALUTypePACI = 50 // Payload Content Information, unsupported
+
+	// IRAP pictures (BLA, IDR, CRA) are the random access points
+	h265IRAPFirst = 16
+	h265IRAPLast  = 23
+)
+
+// H265Processor handles H.265/HEVC RTP depacketization (RFC 7798). NAL
+// units sharing an RTP timestamp are assembled into one access unit, emitted
+// on the marker bit or when the timestamp moves on. Streams using DONL
+// (sprop-max-don-diff > 0) are not supported.
+type H265Processor struct {
+	buffer []byte // Buffer for accumulating fragmented NALUs
+	vps    []byte
+	sps    []byte
+	pps    []byte
+
+	au          []byte // Access unit being assembled, AVC (length-prefixed) form
+	auTimestamp uint32
+	auKeyframe  bool // The access unit holds an IRAP picture
+	auParams    bool // The access unit carries its own SPS
+
+	OnFrame func(nalus []byte, timestamp uint32, keyframe bool) // Called when a complete access unit is ready
+
+	MaxNALUSize int    // Oversized NALUs are dropped (default DefaultMaxNALUSize)
+	discarding  bool   // Dropping fragments until the next FU start
+	dropped     uint64 // NALUs dropped for exceeding MaxNALUSize
+}
+
+// NewH265Processor creates a new H.265 RTP processor
+func NewH265Processor() *H265Processor {
+	return &H265Processor{
+		buffer:      make([]byte, 0, reassemblyBufferSize),
+		MaxNALUSize: DefaultMaxNALUSize,
+	}
+}
+
+// Dropped returns how many NALUs were dropped for exceeding MaxNALUSize
+func (p *H265Processor) Dropped() uint64 {
+	return p.dropped
+}
+
+// h265Type returns the NAL unit type from the first header byte
+func h265Type(b byte) uint8 {
+	return (b >> 1) & 0x3F
+}
+
+// ProcessPacket processes an RTP packet containing H.265 data
+func (p *H265Processor) ProcessPacket(packet *rtp.Packet) error {
+	if len(packet.Payload) == 0 {
+		return nil
+	}
+	if len(packet.Payload) < 2 {
+		return fmt.Errorf("H.265 packet too short")
+	}
+
+	// A new timestamp means the previous access unit is complete even if
+	// its marker was lost
+	if len(p.au) > 0 && packet.Timestamp != p.auTimestamp {
+		p.flush()
+	}
+	p.auTimestamp = packet.Timestamp
+
+	var err error
+	switch h265Type(packet.Payload[0]) {
+	case H265NALUTypeFU:
+		err = p.processFU(packet.Payload)
+	case H265NALUTypeAP:
+		err = p.processAP(packet.Payload)
+	case H265NALUTypePACI:
+		err = fmt.Errorf("PACI packets not supported")
+	default:
+		p.addNALU(packet.Payload)
+	}
+
+	if packet.Marker {
+		p.flush()
+	}
+	return err
+}
+
+// processFU handles fragmented NAL units
+func (p *H265Processor) processFU(payload []byte) error {
+	if len(payload) < 3 {
+		return fmt.Errorf("FU packet too short")
+	}
+
+	fuHeader := payload[2]
+	start := (fuHeader & 0x80) != 0
+	end := (fuHeader & 0x40) != 0
+	naluType := fuHeader & 0x3F
+	fragment := payload[3:]
+
+	if start {
+		// Start of fragmented NALU. Give back memory an oversized NALU grew.
+		if cap(p.buffer) > reassemblyBufferSize {
+			p.buffer = make([]byte, 0, reassemblyBufferSize)
+		}
+		p.buffer = p.buffer[:0]
+		p.discarding = false
+
+		// Reconstruct the two-byte NAL header: F and layer ID bits from the
+		// payload header, type from the FU header
+		p.buffer = append(p.buffer, (payload[0]&0x81)|(naluType<<1), payload[1])
+	} else if p.discarding || len(p.buffer) == 0 {
+		return nil // Start fragment lost or NALU dropped
+	}
+
+	if p.MaxNALUSize > 0 && len(p.buffer)+len(fragment) > p.MaxNALUSize {
+		p.discarding = true
+		p.dropped++
+		p.buffer = p.buffer[:0]
+		return fmt.Errorf("FU NALU exceeds %d bytes, dropped", p.MaxNALUSize)
+	}
+
+	p.buffer = append(p.buffer, fragment...)
+
+	if end {
+		p.addNALU(p.buffer)
+		p.buffer = p.buffer[:0]
+	}
+	return nil
+}
+
+// processAP handles aggregation packets
+func (p *H265Processor) processAP(payload []byte) error {
+	payload = payload[2:] // Skip the payload header
+
+	for len(payload) > 2 {
+		size := int(binary.BigEndian.Uint16(payload[:2]))
+		payload = payload[2:]
+
+		if size < 2 || len(payload) < size {
+			return fmt.Errorf("AP NALU size exceeds payload")
+		}
+		p.addNALU(payload[:size])
+		payload = payload[size:]
+	}
+	return nil
+}
+
+// addNALU appends a complete NAL unit to the access unit being assembled
+func (p *H265Processor) addNALU(nalu []byte) {
+	switch naluType := h265Type(nalu[0]); {
+	case naluType == H265NALUTypeVPS:
+		p.vps = append(p.vps[:0], nalu...)
+	case naluType == H265NALUTypeSPS:
+		p.sps = append(p.sps[:0], nalu...)
+		p.auParams = true
+	case naluType == H265NALUTypePPS:
+		p.pps = append(p.pps[:0], nalu...)
+	case naluType >= h265IRAPFirst && naluType <= h265IRAPLast:
+		p.auKeyframe = true
+	}
+
+	p.au = appendNALU(p.au, nalu)
+}
+
+// flush emits the assembled access unit. Keyframes without in-band
+// parameter sets get the cached VPS/SPS/PPS prepended so they decode alone.
+func (p *H265Processor) flush() {
+	au, keyframe := p.au, p.auKeyframe
+	if keyframe && !p.auParams && len(p.vps) > 0 && len(p.sps) > 0 && len(p.pps) > 0 {
+		frame := make([]byte, 0, len(p.vps)+len(p.sps)+len(p.pps)+12+len(au))
+		frame = appendNALU(frame, p.vps)
+		frame = appendNALU(frame, p.sps)
+		frame = appendNALU(frame, p.pps)
+		au = append(frame, au...)
+	}
+
+	p.au = nil
+	p.auKeyframe = false
+	p.auParams = false
+
+	if len(au) > 0 && p.OnFrame != nil {
+		p.OnFrame(au, p.auTimestamp, keyframe)
+	}
+}
+
+// GetVPS returns the stored VPS
+func (p *H265Processor) GetVPS() []byte {
+	return p.vps
+}
+
+// GetSPS returns the stored SPS
+func (p *H265Processor) GetSPS() []byte {
+	return p.sps
+}
+
+// GetPPS returns the stored PPS
+func (p *H265Processor) GetPPS() []byte {
+	return p.pps
+}
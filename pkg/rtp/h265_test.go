@@ -0,0 +1,69 @@
+package rtp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pion/rtp"
+)
+
+// h265NALU builds a NAL unit of the given type with a two-byte header
+func h265NALU(typ uint8, body ...byte) []byte {
+	return append([]byte{typ << 1, 1}, body...)
+}
+
+func TestH265Processor(t *testing.T) {
+	type frame struct {
+		nalus    [][]byte
+		ts       uint32
+		keyframe bool
+	}
+	var frames []frame
+	p := NewH265Processor()
+	p.OnFrame = func(au []byte, ts uint32, keyframe bool) {
+		frames = append(frames, frame{SplitAVC(au), ts, keyframe})
+	}
+
+	vps, sps, pps := h265NALU(H265NALUTypeVPS, 1), h265NALU(H265NALUTypeSPS, 2), h265NALU(H265NALUTypePPS, 3)
+	idr := h265NALU(19, 10, 11, 12, 13)
+	pkt := func(ts uint32, marker bool, payload []byte) *rtp.Packet {
+		return &rtp.Packet{Header: rtp.Header{Timestamp: ts, Marker: marker}, Payload: payload}
+	}
+
+	// Parameter sets in an AP, then the IDR in two FU fragments
+	ap := h265NALU(H265NALUTypeAP)
+	for _, n := range [][]byte{vps, sps, pps} {
+		ap = append(ap, 0, byte(len(n)))
+		ap = append(ap, n...)
+	}
+	fu := h265NALU(H265NALUTypeFU)
+	packets := []*rtp.Packet{
+		pkt(3000, false, ap),
+		pkt(3000, false, append(append(fu[:2:2], 0x80|19), idr[2:4]...)),
+		pkt(3000, true, append(append(fu[:2:2], 0x40|19), idr[4:]...)),
+		pkt(6000, false, h265NALU(1, 20)), // Marker missing: flushed by the next timestamp
+		pkt(9000, true, h265NALU(1, 21)),
+		pkt(12000, true, append(append(fu[:2:2], 0x40|19), 9)), // Start fragment lost
+	}
+	for _, pk := range packets {
+		if err := p.ProcessPacket(pk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(frames) != 3 {
+		t.Fatalf("got %d frames, want 3", len(frames))
+	}
+	if f := frames[0]; !f.keyframe || f.ts != 3000 || len(f.nalus) != 4 || !bytes.Equal(f.nalus[3], idr) {
+		t.Errorf("keyframe = %+v", f)
+	}
+	if f := frames[1]; f.keyframe || f.ts != 6000 || len(f.nalus) != 1 {
+		t.Errorf("second frame = %+v", f)
+	}
+
+	// A later IRAP without in-band parameter sets gets the cached ones
+	p.ProcessPacket(pkt(15000, true, idr))
+	if f := frames[len(frames)-1]; !f.keyframe || len(f.nalus) != 4 || !bytes.Equal(f.nalus[0], vps) {
+		t.Errorf("IRAP without parameter sets = %+v", f)
+	}
+}
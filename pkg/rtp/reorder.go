@@ -0,0 +1,153 @@
+package rtp
+
+import (
+	"sync/atomic"
+
+	"github.com/pion/rtp"
+)
+
+const (
+	// DefaultReorderDepth is how many packets a ReorderBuffer holds past a
+	// gap before declaring the missing ones lost
+	DefaultReorderDepth = 8
+
+	// reorderResync is the sequence jump treated as a new stream (a camera
+	// restart or SSRC change) rather than loss or reordering
+	reorderResync = 1000
+)
+
+// ReorderStats counts what a ReorderBuffer corrected or gave up on
+type ReorderStats struct {
+	Reordered uint64 // Packets that arrived after a later one and were put back in order
+	Lost      uint64 // Sequence numbers skipped after waiting Depth packets
+	Late      uint64 // Packets dropped for arriving after their slot was skipped, or twice
+}
+
+// ReorderBuffer restores RTP sequence order ahead of a depacketizer. In-order
+// packets pass straight through; after a gap, later packets are held until
+// the missing one arrives or the buffer holds Depth packets, when the gap is
+// skipped. A missing FU-A fragment then costs one NALU instead of splicing
+// two NALUs together.
+type ReorderBuffer struct {
+	OnPacket func(packet *rtp.Packet) // Called with each packet, in order
+
+	depth   int
+	started bool
+	next    uint16 // Sequence number expected next
+	pending map[uint16]*rtp.Packet
+
+	reordered atomic.Uint64
+	lost      atomic.Uint64
+	late      atomic.Uint64
+}
+
+// NewReorderBuffer creates a buffer holding up to depth packets past a gap;
+// zero uses DefaultReorderDepth and a negative depth passes every packet
+// through as it arrives
+func NewReorderBuffer(depth int, onPacket func(packet *rtp.Packet)) *ReorderBuffer {
+	if depth == 0 {
+		depth = DefaultReorderDepth
+	}
+	return &ReorderBuffer{
+		OnPacket: onPacket,
+		depth:    depth,
+		pending:  make(map[uint16]*rtp.Packet),
+	}
+}
+
+// Stats returns the buffer's counters. Safe to call from any goroutine.
+func (b *ReorderBuffer) Stats() ReorderStats {
+	return ReorderStats{
+		Reordered: b.reordered.Load(),
+		Lost:      b.lost.Load(),
+		Late:      b.late.Load(),
+	}
+}
+
+// Push adds a packet, releasing it and any held packets that are now in
+// order. A packet that has to wait is copied, so callers may reuse theirs
+// once Push returns.
+func (b *ReorderBuffer) Push(packet *rtp.Packet) {
+	if b.depth < 0 {
+		b.emit(packet)
+		return
+	}
+
+	seq := packet.SequenceNumber
+	if !b.started {
+		b.started, b.next = true, seq
+	}
+
+	switch diff := int16(seq - b.next); {
+	case diff > reorderResync || diff < -reorderResync:
+		// A new stream: release what's held and follow the new numbering
+		b.Flush()
+		b.next = seq
+		fallthrough
+	case diff == 0:
+		if len(b.pending) > 0 {
+			b.reordered.Add(1)
+		}
+		b.next = seq + 1
+		b.emit(packet)
+		b.drain()
+	case diff < 0:
+		b.late.Add(1)
+	default:
+		if _, dup := b.pending[seq]; dup {
+			b.late.Add(1)
+			return
+		}
+		b.pending[seq] = packet.Clone()
+		if len(b.pending) > b.depth {
+			b.skipGap()
+		}
+	}
+}
+
+// Flush releases every held packet in sequence order, skipping gaps, e.g.
+// before the stream ends
+func (b *ReorderBuffer) Flush() {
+	for len(b.pending) > 0 {
+		b.skipGap()
+	}
+}
+
+// Reset releases every held packet and follows the next packet's
+// numbering, e.g. after the source reconnected with a new stream
+func (b *ReorderBuffer) Reset() {
+	b.Flush()
+	b.started = false
+}
+
+// skipGap gives up on the missing packets before the earliest held one
+func (b *ReorderBuffer) skipGap() {
+	first, found := uint16(0), false
+	for seq := range b.pending {
+		if !found || int16(seq-first) < 0 {
+			first, found = seq, true
+		}
+	}
+	b.lost.Add(uint64(first - b.next))
+	b.next = first
+	b.drain()
+}
+
+// drain releases held packets continuing from next
+func (b *ReorderBuffer) drain() {
+	for {
+		packet, ok := b.pending[b.next]
+		if !ok {
+			return
+		}
+		delete(b.pending, b.next)
+		b.next++
+		b.emit(packet)
+	}
+}
+
+func (b *ReorderBuffer) emit(packet *rtp.Packet) {
+	if b.OnPacket != nil {
+		b.OnPacket(packet)
+	}
+}
@@ -0,0 +1,49 @@
+package rtp
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/pion/rtp"
+)
+
+func TestReorderBuffer(t *testing.T) {
+	var out []uint16
+	b := NewReorderBuffer(3, func(p *rtp.Packet) { out = append(out, p.SequenceNumber) })
+	push := func(seqs ...uint16) {
+		for _, seq := range seqs {
+			b.Push(&rtp.Packet{Header: rtp.Header{SequenceNumber: seq}})
+		}
+	}
+
+	push(65534, 0, 65535, 1) // Swapped across the wrap
+	push(3, 4, 5, 6)         // 2 never arrives: skipped once 3 packets wait
+	push(2, 6)               // Too late, and a duplicate
+	push(40000, 40001)       // Camera restarted
+	b.Flush()
+
+	want := []uint16{65534, 65535, 0, 1, 3, 4, 5, 6, 40000, 40001}
+	if !slices.Equal(out, want) {
+		t.Errorf("order = %v, want %v", out, want)
+	}
+	if s := b.Stats(); s != (ReorderStats{Reordered: 1, Lost: 1, Late: 2}) {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestReorderBufferCopiesHeldPackets(t *testing.T) {
+	var out []byte
+	b := NewReorderBuffer(3, func(p *rtp.Packet) { out = append(out, p.Payload...) })
+
+	// The caller reuses one packet and payload, as the RTSP client does
+	payload := []byte{0}
+	p := &rtp.Packet{Payload: payload}
+	for _, seq := range []uint16{10, 12, 11} {
+		p.SequenceNumber, payload[0] = seq, byte(seq)
+		b.Push(p)
+	}
+
+	if want := []byte{10, 11, 12}; !slices.Equal(out, want) {
+		t.Errorf("payloads = %v, want %v", out, want)
+	}
+}
@@ -0,0 +1,263 @@
+package rtp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SPSInfo is what an H.264 sequence parameter set says about the stream
+type SPSInfo struct {
+	Width       int
+	Height      int
+	ProfileIDC  uint8
+	Constraints uint8 // constraint_set0..5 flags, high bits first
+	LevelIDC    uint8
+	FrameRate   float64 // From the VUI timing info; zero when the SPS doesn't carry it
+}
+
+// Profile returns the profile name, e.g. "High" or "Constrained Baseline"
+func (s SPSInfo) Profile() string {
+	switch s.ProfileIDC {
+	case 66:
+		if s.Constraints&0x40 != 0 {
+			return "Constrained Baseline"
+		}
+		return "Baseline"
+	case 77:
+		return "Main"
+	case 88:
+		return "Extended"
+	case 100:
+		return "High"
+	case 110:
+		return "High 10"
+	case 122:
+		return "High 4:2:2"
+	case 244:
+		return "High 4:4:4 Predictive"
+	case 44:
+		return "CAVLC 4:4:4 Intra"
+	}
+	return fmt.Sprintf("profile %d", s.ProfileIDC)
+}
+
+// Level returns the level as written in the spec, e.g. "4.1"
+func (s SPSInfo) Level() string {
+	if s.LevelIDC == 11 && s.Constraints&0x10 != 0 && (s.ProfileIDC == 66 || s.ProfileIDC == 77) {
+		return "1b"
+	}
+	return fmt.Sprintf("%d.%d", s.LevelIDC/10, s.LevelIDC%10)
+}
+
+var errSPSTruncated = errors.New("SPS truncated")
+
+// ParseSPS decodes the fields of an H.264 SPS NAL unit (ITU-T H.264 7.3.2.1)
+// needed for SPSInfo
+func ParseSPS(nalu []byte) (SPSInfo, error) {
+	if len(nalu) < 4 || nalu[0]&0x1F != NALUTypeSPS {
+		return SPSInfo{}, fmt.Errorf("not an SPS")
+	}
+
+	r := bitReader{data: unescapeRBSP(nalu[1:])}
+	info := SPSInfo{
+		ProfileIDC:  uint8(r.u(8)),
+		Constraints: uint8(r.u(8)),
+		LevelIDC:    uint8(r.u(8)),
+	}
+	r.ue() // seq_parameter_set_id
+
+	chromaFormat := uint32(1)
+	switch info.ProfileIDC {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chromaFormat = r.ue()
+		if chromaFormat == 3 && r.u(1) == 1 { // separate_colour_plane_flag
+			chromaFormat = 0 // Each plane coded as monochrome
+		}
+		r.ue() // bit_depth_luma_minus8
+		r.ue() // bit_depth_chroma_minus8
+		r.u(1) // qpprime_y_zero_transform_bypass_flag
+
+		// seq_scaling_matrix_present_flag
+		if r.u(1) == 1 {
+			lists := 8
+			if chromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if r.u(1) == 1 {
+					size := 16
+					if i >= 6 {
+						size = 64
+					}
+					r.skipScalingList(size)
+				}
+			}
+		}
+	}
+
+	r.ue() // log2_max_frame_num_minus4
+
+	// pic_order_cnt_type
+	switch r.ue() {
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.u(1) // delta_pic_order_always_zero_flag
+		r.se() // offset_for_non_ref_pic
+		r.se() // offset_for_top_to_bottom_field
+		for n := r.ue(); n > 0 && r.err == nil; n-- {
+			r.se() // offset_for_ref_frame
+		}
+	}
+	r.ue() // max_num_ref_frames
+	r.u(1) // gaps_in_frame_num_value_allowed_flag
+
+	widthMBs := int(r.ue()) + 1
+	heightMapUnits := int(r.ue()) + 1
+	frameMBsOnly := int(r.u(1))
+	if frameMBsOnly == 0 {
+		r.u(1) // mb_adaptive_frame_field_flag
+	}
+	r.u(1) // direct_8x8_inference_flag
+
+	info.Width = widthMBs * 16
+	info.Height = (2 - frameMBsOnly) * heightMapUnits * 16
+	if r.u(1) == 1 { // frame_cropping_flag
+		left, right, top, bottom := int(r.ue()), int(r.ue()), int(r.ue()), int(r.ue())
+		cropX, cropY := 1, 2-frameMBsOnly
+		switch chromaFormat {
+		case 1:
+			cropX, cropY = 2, 2*(2-frameMBsOnly)
+		case 2:
+			cropX = 2
+		}
+		info.Width -= cropX * (left + right)
+		info.Height -= cropY * (top + bottom)
+	}
+
+	if r.u(1) == 1 { // vui_parameters_present_flag
+		info.FrameRate = r.vuiFrameRate()
+	}
+
+	if r.err != nil {
+		return SPSInfo{}, r.err
+	}
+	if info.Width <= 0 || info.Height <= 0 {
+		return SPSInfo{}, fmt.Errorf("SPS has invalid dimensions %dx%d", info.Width, info.Height)
+	}
+	return info, nil
+}
+
+// vuiFrameRate reads the VUI up to its timing info (E.1.1) and returns the
+// frame rate, or zero when there is none
+func (r *bitReader) vuiFrameRate() float64 {
+	if r.u(1) == 1 { // aspect_ratio_info_present_flag
+		if r.u(8) == 255 { // Extended_SAR
+			r.u(16) // sar_width
+			r.u(16) // sar_height
+		}
+	}
+	if r.u(1) == 1 { // overscan_info_present_flag
+		r.u(1) // overscan_appropriate_flag
+	}
+	if r.u(1) == 1 { // video_signal_type_present_flag
+		r.u(3) // video_format
+		r.u(1) // video_full_range_flag
+		if colourDescription := r.u(1); colourDescription == 1 {
+			r.u(24) // colour_primaries, transfer_characteristics, matrix_coefficients
+		}
+	}
+	if r.u(1) == 1 { // chroma_loc_info_present_flag
+		r.ue()
+		r.ue()
+	}
+	if r.u(1) == 0 { // timing_info_present_flag
+		return 0
+	}
+	unitsInTick, timeScale := r.u(32), r.u(32)
+	if r.err != nil || unitsInTick == 0 {
+		return 0
+	}
+	// One frame is two field ticks
+	return float64(timeScale) / float64(2*unitsInTick)
+}
+
+// skipScalingList skips a scaling_list() of the given size (7.3.2.1.1.1)
+func (r *bitReader) skipScalingList(size int) {
+	last, next := int32(8), int32(8)
+	for j := 0; j < size && r.err == nil; j++ {
+		if next != 0 {
+			next = (last + r.se() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
+
+// unescapeRBSP removes emulation prevention bytes (00 00 03 → 00 00)
+func unescapeRBSP(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	zeros := 0
+	for _, c := range b {
+		if zeros >= 2 && c == 3 {
+			zeros = 0
+			continue
+		}
+		out = append(out, c)
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return out
+}
+
+// bitReader reads big-endian bit fields and Exp-Golomb codes. Reading past
+// the end sets err and returns zeros from then on.
+type bitReader struct {
+	data []byte
+	pos  int // In bits
+	err  error
+}
+
+// u reads an n-bit unsigned field, n <= 32
+func (r *bitReader) u(n int) uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos+n > len(r.data)*8 {
+		r.err = errSPSTruncated
+		return 0
+	}
+	var v uint32
+	for i := 0; i < n; i++ {
+		bit := r.data[r.pos/8] >> (7 - r.pos%8) & 1
+		v = v<<1 | uint32(bit)
+		r.pos++
+	}
+	return v
+}
+
+// ue reads an unsigned Exp-Golomb code
+func (r *bitReader) ue() uint32 {
+	zeros := 0
+	for r.u(1) == 0 && r.err == nil {
+		zeros++
+		if zeros > 31 {
+			r.err = fmt.Errorf("invalid Exp-Golomb code")
+			return 0
+		}
+	}
+	return 1<<zeros - 1 + r.u(zeros)
+}
+
+// se reads a signed Exp-Golomb code
+func (r *bitReader) se() int32 {
+	v := r.ue()
+	if v%2 == 1 {
+		return int32(v/2) + 1
+	}
+	return -int32(v / 2)
+}
@@ -0,0 +1,26 @@
+package rtp
+
+import "testing"
+
+func TestParseSPS(t *testing.T) {
+	// High 4.0, 1920x1088 cropped to 1080, VUI timing for 30 fps, with
+	// emulation prevention bytes
+	sps := []byte{
+		0x67, 0x64, 0x00, 0x28, 0xac, 0xd9, 0x40, 0x78, 0x02, 0x27, 0xe5, 0xc0,
+		0x44, 0x00, 0x00, 0x03, 0x00, 0x04, 0x00, 0x00, 0x03, 0x00, 0xf2, 0x10,
+	}
+	info, err := ParseSPS(sps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Width != 1920 || info.Height != 1080 || info.FrameRate != 30 {
+		t.Errorf("got %dx%d at %g fps, want 1920x1080 at 30", info.Width, info.Height, info.FrameRate)
+	}
+	if info.Profile() != "High" || info.Level() != "4.0" {
+		t.Errorf("got %s %s, want High 4.0", info.Profile(), info.Level())
+	}
+
+	if _, err := ParseSPS(sps[:8]); err == nil {
+		t.Error("truncated SPS parsed")
+	}
+}
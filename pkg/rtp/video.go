@@ -0,0 +1,44 @@
+package rtp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pion/rtp"
+)
+
+// VideoProcessor depacketizes one video codec's RTP into access units in
+// AVC (length-prefixed) form
+type VideoProcessor interface {
+	ProcessPacket(packet *rtp.Packet) error
+	Dropped() uint64 // NALUs dropped for exceeding the size limit
+}
+
+// SPSInfoProvider is implemented by video processors that parse the stream's
+// sequence parameter set
+type SPSInfoProvider interface {
+	SPSInfo() (SPSInfo, bool)
+}
+
+// ParameterSetSeeder is implemented by video processors that can start
+// from parameter sets announced out of band, in the SDP, rather than wait
+// for in-band ones
+type ParameterSetSeeder interface {
+	SetParameterSets(sets [][]byte)
+}
+
+// NewVideoProcessor returns the depacketizer for an SDP encoding name
+// ("H264", or "H265"/"HEVC"), delivering access units to onFrame
+func NewVideoProcessor(codec string, onFrame func(au []byte, timestamp uint32, keyframe bool)) (VideoProcessor, error) {
+	switch strings.ToUpper(codec) {
+	case "H264":
+		p := NewH264Processor()
+		p.OnFrame = onFrame
+		return p, nil
+	case "H265", "HEVC":
+		p := NewH265Processor()
+		p.OnFrame = onFrame
+		return p, nil
+	}
+	return nil, fmt.Errorf("unsupported video codec %q", codec)
+}
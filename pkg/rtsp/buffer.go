@@ -0,0 +1,48 @@
+package rtsp
+
+import (
+	"sync"
+
+	"github.com/pion/rtp"
+)
+
+// pooledPacketSize is the capacity of pooled packet buffers: a full-MTU
+// packet with room to spare. The rare larger interleaved frame gets a
+// buffer of its own instead of growing every pooled one to 64 KiB.
+const pooledPacketSize = 2048
+
+// packetBuffer is a read buffer and the RTP packet unmarshalled from it,
+// whose Payload aliases data
+type packetBuffer struct {
+	data   []byte
+	packet rtp.Packet
+}
+
+// packetBuffers recycles packetBuffers between the read loop and the
+// handlers, so a camera's thousands of packets a second don't each allocate
+var packetBuffers = sync.Pool{
+	New: func() any {
+		return &packetBuffer{data: make([]byte, pooledPacketSize)}
+	},
+}
+
+// getPacketBuffer returns a buffer whose data holds size bytes. Release it
+// with putPacketBuffer once nothing refers to its data or packet.
+func getPacketBuffer(size int) *packetBuffer {
+	if size > pooledPacketSize {
+		return &packetBuffer{data: make([]byte, size)}
+	}
+	b := packetBuffers.Get().(*packetBuffer)
+	b.data = b.data[:size]
+	return b
+}
+
+// putPacketBuffer returns b to the pool; oversized buffers are left to the
+// garbage collector
+func putPacketBuffer(b *packetBuffer) {
+	if cap(b.data) != pooledPacketSize {
+		return
+	}
+	b.packet.Payload = nil // Unmarshal overwrites the rest
+	packetBuffers.Put(b)
+}
@@ -0,0 +1,31 @@
+package rtsp
+
+import "testing"
+
+func TestPacketBuffers(t *testing.T) {
+	b := getPacketBuffer(1400)
+	if len(b.data) != 1400 || cap(b.data) != pooledPacketSize {
+		t.Fatalf("len %d cap %d", len(b.data), cap(b.data))
+	}
+	putPacketBuffer(b)
+
+	big := getPacketBuffer(pooledPacketSize + 1)
+	if len(big.data) != pooledPacketSize+1 {
+		t.Fatalf("len %d", len(big.data))
+	}
+	putPacketBuffer(big) // Not pooled
+
+	// Reading and releasing a packet shouldn't allocate once the pool is warm
+	raw := []byte{0x80, 96, 0, 1, 0, 0, 0, 1, 0, 0, 0, 7, 0xAA, 0xBB}
+	allocs := testing.AllocsPerRun(100, func() {
+		b := getPacketBuffer(len(raw))
+		copy(b.data, raw)
+		if err := b.packet.Unmarshal(b.data); err != nil {
+			t.Fatal(err)
+		}
+		putPacketBuffer(b)
+	})
+	if allocs > 0 {
+		t.Errorf("%v allocations per packet", allocs)
+	}
+}
@@ -10,13 +10,17 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"math/rand"
 	"net"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
 	"github.com/pion/rtp"
 )
 
@@ -29,17 +33,45 @@ type Client struct {
 	reader  *bufio.Reader
 	session string
 	cseq    int
+	methods []string // From the OPTIONS Public header
 	Channels map[byte]*Channel // channel ID -> Channel info (exported for access)
 
+	// RTP delivery; UDP tracks have their own sockets, keyed by channel ID
+	transport Transport
+	udp       map[byte]*udpTrack
+
+	// RTCP receiver reports, per RTP channel ID
+	receivers    map[byte]*rtpReceiver
+	receiversMu  sync.Mutex // Held to add receivers, and by Stats to read them
+	rtcpSSRC     uint32
+	rtcpInterval time.Duration
+	rtcpCancel   context.CancelFunc
+
+	readTimeouts atomic.Uint64 // ReadTimeout periods with no RTP, for Stats
+
+	// Timeouts, and the session timeout from SETUP that keepalives must beat
+	opts           ClientOptions
+	sessionTimeout time.Duration
+
 	// Keepalive management
-	keepaliveInterval time.Duration
-	keepaliveCancel   context.CancelFunc
+	keepaliveCancel context.CancelFunc
 
 	// Write synchronization (protect concurrent writes from keepalive goroutine)
 	writeMu sync.Mutex
 
+	closed atomic.Bool // Set by Close; no reconnect after it
+
 	// Callbacks
+
+	// OnRTPPacket is called from ReadPackets with each RTP packet. The
+	// packet and its payload are reused once it returns; a handler that
+	// keeps either must copy it, e.g. with packet.Clone().
 	OnRTPPacket func(channel byte, packet *rtp.Packet)
+
+	// OnReconnect is called from ReadPackets after a dropped session was
+	// re-established; RTP resumes with the new session's sequence numbers
+	// and timestamps
+	OnReconnect func()
 }
 
 // Channel represents an RTP channel setup
@@ -48,15 +80,54 @@ type Channel struct {
 	MediaType   string // "video" or "audio"
 	Control     string
 	PayloadType uint8
+	Codec       string // Encoding name from a=rtpmap (e.g. "H264", "MPEG4-GENERIC")
+	ClockRate   int
+	Channels    int    // Audio channel count from a=rtpmap (0 if absent)
+	Fmtp        string // Raw a=fmtp parameters
+
+	// ParameterSets are the decoded sprop-parameter-sets (H.264 SPS and
+	// PPS) from the fmtp line, nil when the SDP carries none
+	ParameterSets [][]byte
+}
+
+// FmtpParam returns a format parameter (e.g. "config" or
+// "sprop-parameter-sets") from the channel's a=fmtp line
+func (ch *Channel) FmtpParam(key string) string {
+	for _, param := range strings.Split(ch.Fmtp, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if ok && strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// FmtpParams splits the channel's "a=1;b=2" format parameters into a map;
+// nil when the SDP has no a=fmtp line for it
+func (ch *Channel) FmtpParams() map[string]string {
+	if ch.Fmtp == "" {
+		return nil
+	}
+	params := make(map[string]string)
+	for _, param := range strings.Split(ch.Fmtp, ";") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok {
+			params[k] = v
+		}
+	}
+	return params
 }
 
 // NewClient creates a new RTSP client
 func NewClient(rtspURL string, logger *slog.Logger) *Client {
 	return &Client{
-		url:               rtspURL,
-		logger:            logger,
-		Channels:          make(map[byte]*Channel),
-		keepaliveInterval: 25 * time.Second, // Default keepalive interval (go2rtc uses 25s)
+		url:          rtspURL,
+		logger:       logger,
+		Channels:     make(map[byte]*Channel),
+		transport:    TransportTCP,
+		receivers:    make(map[byte]*rtpReceiver),
+		rtcpSSRC:     rand.Uint32(),
+		rtcpInterval: defaultRTCPInterval,
+		opts:         DefaultClientOptions(),
 	}
 }
 
@@ -77,9 +148,12 @@ func (c *Client) Connect(ctx context.Context) error {
 	// Determine port
 	port := u.Port()
 	if port == "" {
-		if u.Scheme == "rtsps" {
-			port = "443" // Standard RTSPS port
-		} else {
+		switch {
+		case u.Scheme == "rtsps":
+			port = "443" // Standard RTSPS port, and HTTPS for a tunnel
+		case c.transport == TransportHTTP:
+			port = "80"
+		default:
 			port = "554"
 		}
 	}
@@ -98,37 +172,58 @@ func (c *Client) Connect(ctx context.Context) error {
 		KeepAlive: 30 * time.Second, // Enable TCP keepalive
 	}
 
-	var conn net.Conn
-	if u.Scheme == "rtsps" {
-		tlsConfig := &tls.Config{
-			ServerName:         host,
-			InsecureSkipVerify: false,
-		}
-		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
-	} else {
-		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	if u.Scheme == "rtsps" && c.opts.TLS.InsecureSkipVerify {
+		c.logger.Warn("RTSP server certificate is not verified")
 	}
-	if err != nil {
-		return fmt.Errorf("dial: %w", err)
+	dialTCP := dialer.DialContext
+	if c.opts.Dial != nil {
+		dialTCP = c.opts.Dial
 	}
-
-	// Enable TCP_NODELAY to disable Nagle's algorithm (important for real-time streaming)
-	// This ensures RTSP requests are sent immediately without buffering
-	if tcpConn, ok := conn.(*net.TCPConn); ok {
-		if err := tcpConn.SetNoDelay(true); err != nil {
-			c.logger.Warn("failed to set TCP_NODELAY", "error", err)
-		} else {
-			c.logger.Debug("TCP_NODELAY enabled")
+	dial := func() (net.Conn, error) {
+		ctx, cancel := context.WithTimeout(ctx, dialer.Timeout)
+		defer cancel()
+		conn, err := dialTCP(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
 		}
-	} else if tlsConn, ok := conn.(*tls.Conn); ok {
-		// For TLS connections, need to get underlying TCP connection
-		if tcpConn, ok := tlsConn.NetConn().(*net.TCPConn); ok {
+		if u.Scheme == "rtsps" {
+			tlsConn := tls.Client(conn, c.opts.TLS.config(host))
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			conn = tlsConn
+		}
+
+		// Enable TCP_NODELAY to disable Nagle's algorithm (important for real-time streaming)
+		// This ensures RTSP requests are sent immediately without buffering
+		if tcpConn, ok := conn.(*net.TCPConn); ok {
 			if err := tcpConn.SetNoDelay(true); err != nil {
-				c.logger.Warn("failed to set TCP_NODELAY on TLS connection", "error", err)
+				c.logger.Warn("failed to set TCP_NODELAY", "error", err)
 			} else {
-				c.logger.Debug("TCP_NODELAY enabled on TLS connection")
+				c.logger.Debug("TCP_NODELAY enabled")
+			}
+		} else if tlsConn, ok := conn.(*tls.Conn); ok {
+			// For TLS connections, need to get underlying TCP connection
+			if tcpConn, ok := tlsConn.NetConn().(*net.TCPConn); ok {
+				if err := tcpConn.SetNoDelay(true); err != nil {
+					c.logger.Warn("failed to set TCP_NODELAY on TLS connection", "error", err)
+				} else {
+					c.logger.Debug("TCP_NODELAY enabled on TLS connection")
+				}
 			}
 		}
+		return conn, nil
+	}
+
+	var conn net.Conn
+	if c.transport == TransportHTTP {
+		conn, err = c.dialTunnel(dial, u)
+	} else {
+		conn, err = dial()
+	}
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
 	}
 
 	c.conn = conn
@@ -151,13 +246,77 @@ func (c *Client) Connect(ctx context.Context) error {
 	return nil
 }
 
-// SetupTracks sets up all available tracks
+// AudioCodec returns the upper-cased encoding name of the audio track from
+// the DESCRIBE SDP (e.g. "MPEG4-GENERIC" for AAC, "OPUS"), or "" when the
+// camera offers no audio. Call after Connect.
+func (c *Client) AudioCodec() string {
+	return c.codec("audio")
+}
+
+// VideoCodec returns the upper-cased encoding name of the video track from
+// the DESCRIBE SDP ("H264" or "H265"), or "" without video. Call after
+// Connect.
+func (c *Client) VideoCodec() string {
+	return c.codec("video")
+}
+
+// codec returns the encoding name the SDP maps the payload type of a media
+// type's track to
+func (c *Client) codec(mediaType string) string {
+	for _, ch := range c.Channels {
+		if ch.MediaType == mediaType {
+			return strings.ToUpper(ch.Codec)
+		}
+	}
+	return ""
+}
+
+// VideoParameterSets returns the SPS and PPS the DESCRIBE SDP announced
+// for the video track, so a depacketizer can be seeded before the first
+// in-band ones arrive; nil without any. Call after Connect.
+func (c *Client) VideoParameterSets() [][]byte {
+	for _, ch := range c.Channels {
+		if ch.MediaType == "video" {
+			return ch.ParameterSets
+		}
+	}
+	return nil
+}
+
+// SkipMedia drops every track of a media type ("video" or "audio") from
+// the session so SetupTracks never requests it. Call after Connect.
+func (c *Client) SkipMedia(mediaType string) {
+	for id, ch := range c.Channels {
+		if ch.MediaType == mediaType {
+			delete(c.Channels, id)
+		}
+	}
+}
+
+// SetupTracks sets up all available tracks, in SDP order. Over TCP the
+// server may answer with other interleaved channels than the ones offered;
+// Channels is then re-keyed by the channels the server confirmed.
 func (c *Client) SetupTracks(ctx context.Context) error {
-	for channelID, ch := range c.Channels {
-		if err := c.setupTrack(ctx, channelID, ch); err != nil {
-			return fmt.Errorf("setup track %d: %w", channelID, err)
+	ids := make([]int, 0, len(c.Channels))
+	for id := range c.Channels {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	confirmed := make(map[byte]*Channel, len(ids))
+	for _, id := range ids {
+		ch := c.Channels[byte(id)]
+		channelID, err := c.setupTrack(ctx, byte(id), ch)
+		if err != nil {
+			return fmt.Errorf("setup track %d: %w", id, err)
+		}
+		if _, taken := confirmed[channelID]; taken {
+			return fmt.Errorf("setup track %d: server assigned interleaved channel %d twice", id, channelID)
 		}
+		ch.ID = channelID
+		confirmed[channelID] = ch
 	}
+	c.Channels = confirmed
 	return nil
 }
 
@@ -166,20 +325,7 @@ func (c *Client) SetupTracks(ctx context.Context) error {
 // The response will be handled in ReadPackets() loop, since the server immediately
 // starts sending RTP packets after the PLAY response.
 func (c *Client) Play(ctx context.Context) error {
-	// Use baseURL (from Content-Base header) for PLAY, not the original URL
-	// This is critical for Nest cameras - the Content-Base URL does NOT include
-	// the ?auth= query parameter, and the server expects PLAY without it
-	playURL := c.baseURL
-
-	// Ensure URL path has trailing slash (matches ffmpeg behavior)
-	if u, err := url.Parse(playURL); err == nil {
-		if !strings.HasSuffix(u.Path, "/") {
-			u.Path = u.Path + "/"
-		}
-		playURL = u.String()
-	}
-
-	req := c.newRequest("PLAY", playURL)
+	req := c.newRequest("PLAY", c.sessionURL())
 
 	// Range header is REQUIRED for Nest cameras to start streaming
 	// Wire protocol analysis shows ffmpeg sends this and receives packets
@@ -196,21 +342,48 @@ func (c *Client) Play(ctx context.Context) error {
 	// This mimics go2rtc's behavior: send periodic OPTIONS to keep session alive
 	c.startKeepalive(ctx)
 
+	// Report reception back to the camera, as RFC 3550 expects of receivers
+	c.startRTCP(ctx)
+
 	return nil
 }
 
-// startKeepalive starts background goroutine that sends periodic OPTIONS requests
-// to keep the RTSP session alive. This is critical for Nest cameras which may
-// not send packets without keepalive signals.
+// sessionURL is the aggregate URL for requests on the whole session
+func (c *Client) sessionURL() string {
+	// Use baseURL (from Content-Base header) for PLAY, not the original URL
+	// This is critical for Nest cameras - the Content-Base URL does NOT include
+	// the ?auth= query parameter, and the server expects PLAY without it
+	sessionURL := c.baseURL
+
+	// Ensure URL path has trailing slash (matches ffmpeg behavior)
+	if u, err := url.Parse(sessionURL); err == nil {
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path = u.Path + "/"
+		}
+		sessionURL = u.String()
+	}
+	return sessionURL
+}
+
+// startKeepalive starts background goroutine that sends periodic OPTIONS
+// (or GET_PARAMETER) requests to keep the RTSP session alive. This is
+// critical for Nest cameras which may not send packets without keepalive
+// signals.
 func (c *Client) startKeepalive(ctx context.Context) {
 	keepaliveCtx, cancel := context.WithCancel(ctx)
 	c.keepaliveCancel = cancel
 
 	go func() {
-		ticker := time.NewTicker(c.keepaliveInterval)
+		defer goroutines.Track("rtsp.keepalive", "")()
+		interval := c.keepaliveInterval()
+		method := c.keepaliveMethod()
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
-		c.logger.Info("keepalive goroutine started", "interval", c.keepaliveInterval)
+		c.logger.Info("keepalive goroutine started",
+			"interval", interval,
+			"method", method,
+			"session_timeout", c.sessionTimeout)
 
 		for {
 			select {
@@ -218,24 +391,50 @@ func (c *Client) startKeepalive(ctx context.Context) {
 				c.logger.Info("keepalive goroutine stopped")
 				return
 			case <-ticker.C:
-				// Send OPTIONS request to keep session alive
-				c.logger.Info("sending keepalive OPTIONS")
-				req := c.newRequest("OPTIONS", c.url)
+				// Send a request on the session to keep it alive
+				c.logger.Info("sending keepalive", "method", method)
+				req := c.newRequest(string(method), c.url)
+				if method == KeepaliveGetParameter {
+					req.URL = c.sessionURL()
+				}
 				if err := c.writeRequest(req); err != nil {
-					c.logger.Warn("keepalive OPTIONS write failed", "error", err)
+					c.logger.Warn("keepalive write failed", "method", method, "error", err)
 					return
 				}
-				c.logger.Info("keepalive OPTIONS sent successfully")
+				c.logger.Info("keepalive sent successfully", "method", method)
 			}
 		}
 	}()
 }
 
-// ReadPackets reads RTP packets from the interleaved stream
+// ReadPackets reads RTP packets until the session ends. With
+// ClientOptions.ReconnectAttempts set, a dropped session is re-established
+// against the same URL and reading resumes; an error is returned only once
+// that fails.
+func (c *Client) ReadPackets(ctx context.Context) error {
+	for {
+		err := c.readPackets(ctx)
+		if ctx.Err() != nil || c.closed.Load() || c.opts.ReconnectAttempts <= 0 {
+			return err
+		}
+		c.logger.Warn("RTSP session dropped, reconnecting", "error", err)
+		if err := c.reconnect(ctx); err != nil {
+			return fmt.Errorf("reconnect: %w", err)
+		}
+		if c.OnReconnect != nil {
+			c.OnReconnect()
+		}
+	}
+}
+
+// readPackets reads RTP packets from the interleaved stream
 // This also handles RTSP responses that may be interleaved with RTP packets
 // Based on go2rtc's handleTCPData implementation
-func (c *Client) ReadPackets(ctx context.Context) error {
-	c.logger.Info("starting packet read loop")
+func (c *Client) readPackets(ctx context.Context) error {
+	c.logger.Info("starting packet read loop", "transport", c.transport)
+	if c.transport == TransportUDP {
+		return c.readUDP(ctx)
+	}
 	packetCount := 0
 	timeoutCount := 0
 	playResponseReceived := false
@@ -247,9 +446,8 @@ func (c *Client) ReadPackets(ctx context.Context) error {
 		default:
 		}
 
-		// Set read deadline for this iteration
-		// Use 10 seconds timeout (RTP packets should arrive frequently)
-		if err := c.conn.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		// Set read deadline for this iteration (RTP packets should arrive frequently)
+		if err := c.conn.SetReadDeadline(time.Now().Add(c.opts.ReadTimeout)); err != nil {
 			return fmt.Errorf("set read deadline: %w", err)
 		}
 
@@ -278,6 +476,7 @@ func (c *Client) ReadPackets(ctx context.Context) error {
 			}
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 				timeoutCount++
+				c.readTimeouts.Add(1)
 				if timeoutCount%6 == 1 { // Log every minute (6 * 10s timeout)
 					c.logger.Warn("read timeout - no data from RTSP server",
 						"timeout_count", timeoutCount,
@@ -348,9 +547,12 @@ func (c *Client) ReadPackets(ctx context.Context) error {
 			return fmt.Errorf("discard header: %w", err)
 		}
 
-		// Read the RTP/RTCP payload
-		payload := make([]byte, size)
+		// Read the RTP/RTCP payload into a pooled buffer, released once
+		// it has been handled
+		buf := getPacketBuffer(int(size))
+		payload := buf.data
 		if _, err := io.ReadFull(c.reader, payload); err != nil {
+			putPacketBuffer(buf)
 			if errors.Is(err, io.EOF) {
 				c.logger.Info("connection closed during packet read", "packets_received", packetCount)
 				return nil
@@ -360,8 +562,9 @@ func (c *Client) ReadPackets(ctx context.Context) error {
 
 		// Process RTP packets (even channels), ignore RTCP (odd channels)
 		if channel%2 == 0 {
-			packet := &rtp.Packet{}
+			packet := &buf.packet
 			if err := packet.Unmarshal(payload); err != nil {
+				putPacketBuffer(buf)
 				c.logger.Warn("failed to unmarshal RTP packet",
 					"channel", channel,
 					"size", size,
@@ -369,10 +572,13 @@ func (c *Client) ReadPackets(ctx context.Context) error {
 				continue
 			}
 
+			c.recordRTP(channel, packet)
+
 			// Call handler if set
 			if c.OnRTPPacket != nil {
 				c.OnRTPPacket(channel, packet)
 			}
+			putPacketBuffer(buf)
 
 			packetCount++
 			if packetCount == 1 {
@@ -386,17 +592,26 @@ func (c *Client) ReadPackets(ctx context.Context) error {
 			c.logger.Debug("RTCP packet received",
 				"channel", channel,
 				"size", size)
+			c.handleRTCP(channel, payload)
+			putPacketBuffer(buf)
 		}
 	}
 }
 
 // Close closes the RTSP connection
 func (c *Client) Close() error {
+	c.closed.Store(true)
+
 	// Stop keepalive goroutine first
 	if c.keepaliveCancel != nil {
 		c.keepaliveCancel()
 		c.keepaliveCancel = nil
 	}
+	if c.rtcpCancel != nil {
+		c.rtcpCancel()
+		c.rtcpCancel = nil
+	}
+	c.closeUDP()
 
 	if c.conn != nil {
 		// Send TEARDOWN
@@ -408,6 +623,16 @@ func (c *Client) Close() error {
 	return nil
 }
 
+// Abort drops the underlying connection without sending TEARDOWN, simulating
+// an abrupt network failure. Any blocked ReadPackets call returns an error.
+func (c *Client) Abort() error {
+	c.closeUDP()
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
 // options sends OPTIONS request
 func (c *Client) options(ctx context.Context) error {
 	req := c.newRequest("OPTIONS", c.url)
@@ -419,6 +644,13 @@ func (c *Client) options(ctx context.Context) error {
 	c.logger.Debug("OPTIONS response",
 		"public", resp.Header["Public"])
 
+	c.methods = c.methods[:0]
+	for _, m := range strings.Split(resp.Header["Public"], ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			c.methods = append(c.methods, m)
+		}
+	}
+
 	return nil
 }
 
@@ -463,68 +695,9 @@ func (c *Client) describe(ctx context.Context, username, password string) error
 	return nil
 }
 
-// parseSDP parses SDP and extracts media information
-func (c *Client) parseSDP(sdp string) error {
-	lines := strings.Split(sdp, "\n")
-	var currentMedia string
-	var currentControl string
-	var channelID byte = 0
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		// Media line: m=video 0 RTP/AVP 96
-		if strings.HasPrefix(line, "m=") {
-			parts := strings.Fields(line)
-			if len(parts) >= 4 {
-				currentMedia = parts[0][2:] // "video" or "audio"
-				currentControl = ""
-
-				// Extract payload type
-				var pt uint8
-				if ptVal, err := strconv.Atoi(parts[3]); err == nil {
-					pt = uint8(ptVal)
-				}
-
-				c.Channels[channelID] = &Channel{
-					ID:          channelID,
-					MediaType:   currentMedia,
-					PayloadType: pt,
-				}
-				channelID += 2 // RTP on even, RTCP on odd
-			}
-		}
-
-		// Control attribute: a=control:track1
-		if strings.HasPrefix(line, "a=control:") {
-			currentControl = strings.TrimPrefix(line, "a=control:")
-			// Update the last added channel
-			if len(c.Channels) > 0 {
-				lastCh := c.Channels[channelID-2]
-				lastCh.Control = currentControl
-			}
-		}
-	}
-
-	c.logger.Info("parsed SDP", "channels", len(c.Channels)/2)
-	for id, ch := range c.Channels {
-		if id%2 == 0 { // Only log RTP channels
-			c.logger.Debug("media track",
-				"channel", id,
-				"type", ch.MediaType,
-				"payload_type", ch.PayloadType,
-				"control", ch.Control)
-		}
-	}
-
-	return nil
-}
-
-// setupTrack sends SETUP request for a specific track
-func (c *Client) setupTrack(ctx context.Context, channelID byte, ch *Channel) error {
+// setupTrack sends SETUP request for a specific track, offering channelID,
+// and returns the channel the server will send the track's RTP on
+func (c *Client) setupTrack(ctx context.Context, channelID byte, ch *Channel) (byte, error) {
 	// Build control URL using baseURL (from Content-Base header)
 	// This is critical for Nest cameras which return a different base URL
 	u, _ := url.Parse(c.baseURL)
@@ -536,25 +709,27 @@ func (c *Client) setupTrack(ctx context.Context, channelID byte, ch *Channel) er
 
 	controlURL := u.String()
 
+	transport := fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d", channelID, channelID+1)
+	if c.transport == TransportUDP {
+		var err error
+		if transport, err = c.setupUDP(channelID); err != nil {
+			return 0, fmt.Errorf("open UDP ports: %w", err)
+		}
+	}
+
 	req := c.newRequest("SETUP", controlURL)
-	req.Header["Transport"] = fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d",
-		channelID, channelID+1)
+	req.Header["Transport"] = transport
 
 	resp, err := c.do(req)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Extract session ID from first SETUP
 	if c.session == "" {
-		session := resp.Header["Session"]
-		if session != "" {
+		if session := resp.Header["Session"]; session != "" {
 			// Session might be "123456;timeout=60"
-			if idx := strings.IndexByte(session, ';'); idx > 0 {
-				c.session = session[:idx]
-			} else {
-				c.session = session
-			}
+			c.session, c.sessionTimeout = parseSession(session)
 		}
 	}
 
@@ -564,18 +739,48 @@ func (c *Client) setupTrack(ctx context.Context, channelID byte, ch *Channel) er
 		"channel", channelID,
 		"type", ch.MediaType,
 		"session", c.session,
-		"transport_request", fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d", channelID, channelID+1),
+		"transport_request", transport,
 		"transport_response", transportResp)
 
-	// Warn if transport doesn't include expected interleaved parameters
-	if transportResp == "" {
+	if c.transport == TransportUDP {
+		if err := c.confirmUDP(channelID, transportResp); err != nil {
+			return 0, err
+		}
+	} else if lo, hi, ok := transportRange(transportResp, "interleaved"); ok {
+		// The server's channels are the ones its packets arrive on
+		if lo < 0 || lo > 254 {
+			return 0, fmt.Errorf("server assigned invalid interleaved channel %d", lo)
+		}
+		if lo != int(channelID) {
+			c.logger.Info("server assigned other interleaved channels",
+				"type", ch.MediaType,
+				"requested", channelID,
+				"assigned", lo)
+			channelID = byte(lo)
+		}
+		if hi != lo+1 {
+			c.logger.Warn("server's RTCP channel does not follow its RTP channel; RTCP will be sent on the next channel",
+				"rtp_channel", lo,
+				"rtcp_channel", hi)
+		}
+	} else if transportResp == "" {
+		// Warn if transport doesn't include expected interleaved parameters
 		c.logger.Warn("server returned empty Transport header - may not support interleaved TCP")
-	} else if !strings.Contains(transportResp, "interleaved") {
+	} else {
 		c.logger.Warn("server Transport response missing 'interleaved' - may have rejected TCP transport",
 			"transport", transportResp)
 	}
 
-	return nil
+	// A reconnect keeps the track's receiver, which restarts on the new SSRC
+	if c.receivers[channelID] == nil {
+		r := newRTPReceiver(ch.ClockRate)
+		r.mediaType = ch.MediaType
+		c.receiversMu.Lock()
+		c.receivers[channelID] = r
+		c.receiversMu.Unlock()
+	}
+
+	return channelID, nil
 }
 
 // newRequest creates a new RTSP request
@@ -619,7 +824,7 @@ func (c *Client) writeRequest(req *Request) error {
 
 	buf.WriteString("\r\n")
 
-	if err := c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second)); err != nil {
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.opts.WriteTimeout)); err != nil {
 		return err
 	}
 
@@ -645,7 +850,7 @@ func (c *Client) writeRequest(req *Request) error {
 // readResponse reads an RTSP response (sets its own deadline)
 // Used by do() method for request/response pairs
 func (c *Client) readResponse() (*Response, error) {
-	if err := c.conn.SetReadDeadline(time.Now().Add(15 * time.Second)); err != nil {
+	if err := c.conn.SetReadDeadline(time.Now().Add(c.opts.ResponseTimeout)); err != nil {
 		return nil, err
 	}
 	return c.readResponseNoDeadline()
@@ -0,0 +1,215 @@
+package rtsp
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtp"
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtsp/rtsptest"
+	pionRTP "github.com/pion/rtp"
+)
+
+func TestClientAgainstTestServer(t *testing.T) {
+	srv := rtsptest.NewServer(rtsptest.Options{FPS: 30})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c := NewClient(srv.URL, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer c.Close()
+
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if err := c.SetupTracks(ctx); err != nil {
+		t.Fatalf("SetupTracks: %v", err)
+	}
+	if ch := c.Channels[0]; ch == nil || ch.Codec != "H264" || ch.ClockRate != 90000 {
+		t.Fatalf("video channel = %+v", ch)
+	}
+	if sets := c.VideoParameterSets(); len(sets) != 2 || sets[0][0]&0x1F != 7 || sets[1][0]&0x1F != 8 {
+		t.Errorf("sprop-parameter-sets = %x, want SPS and PPS", sets)
+	}
+	if codec := c.VideoCodec(); codec != "H264" {
+		t.Errorf("video codec = %q", codec)
+	}
+	if codec := c.AudioCodec(); codec != "" {
+		t.Errorf("audio codec of a video-only stream = %q", codec)
+	}
+	if err := c.Play(ctx); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+
+	keyframes := make(chan []byte, 1)
+	proc := rtp.NewH264Processor()
+	proc.OnFrame = func(au []byte, _ uint32, keyframe bool) {
+		if keyframe {
+			select {
+			case keyframes <- append([]byte(nil), au...):
+			default:
+			}
+		}
+	}
+	c.OnRTPPacket = func(_ byte, pkt *pionRTP.Packet) {
+		proc.ProcessPacket(pkt)
+	}
+	go c.ReadPackets(ctx)
+
+	select {
+	case au := <-keyframes:
+		if sps, pps := rtp.ParameterSets(au); sps == nil || pps == nil {
+			t.Error("keyframe without SPS/PPS")
+		}
+	case <-ctx.Done():
+		t.Fatal("no keyframe received")
+	}
+
+	// SETUP and PLAY must use the Content-Base, which carries no auth token
+	for _, req := range srv.Requests() {
+		if (req.Method == "SETUP" || req.Method == "PLAY") && strings.Contains(req.URL, "auth=") {
+			t.Errorf("%s sent to %s, want Content-Base URL", req.Method, req.URL)
+		}
+	}
+	// Closing the client stops its keepalive loop
+	c.Close()
+	if err := goroutines.Settle(ctx, "rtsp.keepalive"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClientUDP(t *testing.T) {
+	srv := rtsptest.NewServer(rtsptest.Options{FPS: 30, UDP: true})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c := NewClient(srv.URL, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer c.Close()
+	c.SetTransport(TransportUDP)
+
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if err := c.SetupTracks(ctx); err != nil {
+		t.Fatalf("SetupTracks: %v", err)
+	}
+	if err := c.Play(ctx); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+
+	keyframes := make(chan struct{}, 1)
+	proc := rtp.NewH264Processor()
+	proc.OnFrame = func(_ []byte, _ uint32, keyframe bool) {
+		if keyframe {
+			select {
+			case keyframes <- struct{}{}:
+			default:
+			}
+		}
+	}
+	c.OnRTPPacket = func(channel byte, pkt *pionRTP.Packet) {
+		if channel == 0 {
+			proc.ProcessPacket(pkt)
+		}
+	}
+	go c.ReadPackets(ctx)
+
+	select {
+	case <-keyframes:
+	case <-ctx.Done():
+		t.Fatal("no keyframe received over UDP")
+	}
+	for _, req := range srv.Requests() {
+		if req.Method == "SETUP" && !strings.Contains(req.Header["Transport"], "client_port=") {
+			t.Errorf("SETUP Transport %q, want client_port", req.Header["Transport"])
+		}
+	}
+
+	// Closing the client closes the sockets, ending their readers
+	c.Close()
+	if err := goroutines.Settle(ctx, "rtsp.udp", "rtsp.control"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClientHonorsInterleavedChannels(t *testing.T) {
+	srv := rtsptest.NewServer(rtsptest.Options{FPS: 30, Channel: 6})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c := NewClient(srv.URL, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer c.Close()
+
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if err := c.SetupTracks(ctx); err != nil {
+		t.Fatalf("SetupTracks: %v", err)
+	}
+	ch := c.Channels[6]
+	if len(c.Channels) != 1 || ch == nil || ch.ID != 6 || ch.MediaType != "video" {
+		t.Fatalf("channels = %v, want video on the server's channel 6", c.Channels)
+	}
+	if err := c.Play(ctx); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+
+	keyframes := make(chan struct{}, 1)
+	proc := rtp.NewH264Processor()
+	proc.OnFrame = func(_ []byte, _ uint32, keyframe bool) {
+		if keyframe {
+			select {
+			case keyframes <- struct{}{}:
+			default:
+			}
+		}
+	}
+	c.OnRTPPacket = func(channel byte, pkt *pionRTP.Packet) {
+		if c.Channels[channel] == ch {
+			proc.ProcessPacket(pkt)
+		}
+	}
+	go c.ReadPackets(ctx)
+
+	select {
+	case <-keyframes:
+	case <-ctx.Done():
+		t.Fatal("no keyframe received on the assigned channel")
+	}
+}
+
+func TestProbeStream(t *testing.T) {
+	srv := rtsptest.NewServer(rtsptest.Options{FPS: 30})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cache := NewProbeCache(time.Minute)
+	probe, err := cache.Probe(ctx, srv.URL, ClientOptions{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if len(probe.Media) == 0 {
+		t.Fatal("no media")
+	}
+	if v := probe.Media[0]; v.Codec != "H264" || v.ClockRate != 90000 || v.Fmtp["packetization-mode"] != "1" {
+		t.Errorf("video = %+v", v)
+	}
+	if !strings.Contains(strings.Join(probe.Methods, ","), "DESCRIBE") {
+		t.Errorf("methods = %v", probe.Methods)
+	}
+
+	if cached, ok := cache.Get(srv.URL); !ok || cached != probe {
+		t.Error("probe result not cached")
+	}
+}
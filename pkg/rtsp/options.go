@@ -0,0 +1,150 @@
+package rtsp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeepaliveMethod is the request that keeps an RTSP session alive
+type KeepaliveMethod string
+
+const (
+	// KeepaliveAuto uses GET_PARAMETER when the server lists it in its
+	// OPTIONS Public header, and OPTIONS otherwise
+	KeepaliveAuto KeepaliveMethod = ""
+
+	// KeepaliveOptions sends OPTIONS, which some servers answer without
+	// counting it as session activity
+	KeepaliveOptions KeepaliveMethod = "OPTIONS"
+
+	// KeepaliveGetParameter sends an empty GET_PARAMETER on the session URL,
+	// the keepalive RFC 2326 suggests
+	KeepaliveGetParameter KeepaliveMethod = "GET_PARAMETER"
+)
+
+// ParseKeepaliveMethod parses "auto", "options" or "get_parameter"; empty
+// is auto
+func ParseKeepaliveMethod(s string) (KeepaliveMethod, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "", "AUTO":
+		return KeepaliveAuto, nil
+	case "OPTIONS":
+		return KeepaliveOptions, nil
+	case "GET_PARAMETER":
+		return KeepaliveGetParameter, nil
+	}
+	return "", fmt.Errorf("unknown keepalive method %q (want auto, options or get_parameter)", s)
+}
+
+// ClientOptions are the client's timeouts and TLS settings. Zero fields
+// take the defaults.
+type ClientOptions struct {
+	// ReadTimeout is how long the media stream may go quiet before the read
+	// loop warns; the stream keeps waiting after it
+	ReadTimeout time.Duration
+
+	// ResponseTimeout bounds the wait for a response to a request
+	ResponseTimeout time.Duration
+
+	// WriteTimeout bounds each write to the RTSP connection
+	WriteTimeout time.Duration
+
+	// KeepaliveInterval is the most time between keepalives. A server that
+	// announces a shorter session timeout gets them at half of it.
+	KeepaliveInterval time.Duration
+
+	// KeepaliveMethod is the keepalive request; auto by default
+	KeepaliveMethod KeepaliveMethod
+
+	// ReconnectAttempts is how many times ReadPackets re-dials a dropped
+	// session, with backoff, before returning; zero leaves reconnecting to
+	// the caller
+	ReconnectAttempts int
+
+	// TLS configures rtsps:// connections; the zero value verifies the
+	// server against the system roots
+	TLS TLSOptions
+
+	// Dial connects to the RTSP server, e.g. through an outbound proxy;
+	// nil dials directly. TLS for rtsps:// runs over the connection it
+	// returns.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// DefaultClientOptions returns the timeouts Nest streams are tuned for
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{
+		ReadTimeout:       10 * time.Second,
+		ResponseTimeout:   15 * time.Second,
+		WriteTimeout:      5 * time.Second,
+		KeepaliveInterval: 25 * time.Second, // go2rtc uses 25s
+	}
+}
+
+// withDefaults fills zero fields from DefaultClientOptions
+func (o ClientOptions) withDefaults() ClientOptions {
+	d := DefaultClientOptions()
+	if o.ReadTimeout <= 0 {
+		o.ReadTimeout = d.ReadTimeout
+	}
+	if o.ResponseTimeout <= 0 {
+		o.ResponseTimeout = d.ResponseTimeout
+	}
+	if o.WriteTimeout <= 0 {
+		o.WriteTimeout = d.WriteTimeout
+	}
+	if o.KeepaliveInterval <= 0 {
+		o.KeepaliveInterval = d.KeepaliveInterval
+	}
+	return o
+}
+
+// SetOptions sets the client's timeouts and TLS settings. Call before
+// Connect.
+func (c *Client) SetOptions(opts ClientOptions) {
+	c.opts = opts.withDefaults()
+}
+
+// keepaliveInterval is the configured interval, shortened to half the
+// session timeout the server announced
+func (c *Client) keepaliveInterval() time.Duration {
+	interval := c.opts.KeepaliveInterval
+	if c.sessionTimeout > 0 && c.sessionTimeout/2 < interval {
+		interval = c.sessionTimeout / 2
+	}
+	return interval
+}
+
+// keepaliveMethod is the configured method, or for auto the one the
+// server's OPTIONS response suggests
+func (c *Client) keepaliveMethod() KeepaliveMethod {
+	if c.opts.KeepaliveMethod != KeepaliveAuto {
+		return c.opts.KeepaliveMethod
+	}
+	if slices.ContainsFunc(c.methods, func(m string) bool { return strings.EqualFold(m, "GET_PARAMETER") }) {
+		return KeepaliveGetParameter
+	}
+	return KeepaliveOptions
+}
+
+// parseSession splits a Session header, e.g. "123456;timeout=60", into the
+// session ID and its timeout; zero when the server gives none
+func parseSession(header string) (string, time.Duration) {
+	id, params, _ := strings.Cut(header, ";")
+	var timeout time.Duration
+	for _, param := range strings.Split(params, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(k), "timeout") {
+			continue
+		}
+		if secs, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && secs > 0 {
+			timeout = time.Duration(secs) * time.Second
+		}
+	}
+	return strings.TrimSpace(id), timeout
+}
@@ -0,0 +1,63 @@
+package rtsp
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestSessionTimeoutShortensKeepalive(t *testing.T) {
+	for _, tc := range []struct {
+		header  string
+		id      string
+		timeout time.Duration
+	}{
+		{"12345678", "12345678", 0},
+		{"12345678;timeout=60", "12345678", 60 * time.Second},
+		{"12345678; Timeout = 20 ", "12345678", 20 * time.Second},
+		{"12345678;timeout=bogus", "12345678", 0},
+	} {
+		id, timeout := parseSession(tc.header)
+		if id != tc.id || timeout != tc.timeout {
+			t.Errorf("parseSession(%q) = %q, %v", tc.header, id, timeout)
+		}
+	}
+
+	c := NewClient("rtsp://camera/stream", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	c.SetOptions(ClientOptions{KeepaliveInterval: 40 * time.Second})
+	if c.opts.ReadTimeout != DefaultClientOptions().ReadTimeout {
+		t.Errorf("zero ReadTimeout not defaulted: %v", c.opts.ReadTimeout)
+	}
+	for _, tc := range []struct {
+		session, want time.Duration
+	}{
+		{0, 40 * time.Second},
+		{60 * time.Second, 30 * time.Second},
+		{120 * time.Second, 40 * time.Second},
+	} {
+		c.sessionTimeout = tc.session
+		if got := c.keepaliveInterval(); got != tc.want {
+			t.Errorf("session timeout %v: keepalive every %v, want %v", tc.session, got, tc.want)
+		}
+	}
+}
+
+func TestKeepaliveMethod(t *testing.T) {
+	c := NewClient("rtsp://camera/stream", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	c.methods = []string{"OPTIONS", "DESCRIBE", "SETUP", "PLAY", "TEARDOWN"}
+	if got := c.keepaliveMethod(); got != KeepaliveOptions {
+		t.Errorf("without GET_PARAMETER in Public: %q", got)
+	}
+	c.methods = append(c.methods, "get_parameter")
+	if got := c.keepaliveMethod(); got != KeepaliveGetParameter {
+		t.Errorf("with GET_PARAMETER in Public: %q", got)
+	}
+	c.SetOptions(ClientOptions{KeepaliveMethod: KeepaliveOptions})
+	if got := c.keepaliveMethod(); got != KeepaliveOptions {
+		t.Errorf("forced OPTIONS: %q", got)
+	}
+	if _, err := ParseKeepaliveMethod("ping"); err == nil {
+		t.Error("ParseKeepaliveMethod accepted ping")
+	}
+}
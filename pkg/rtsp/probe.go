@@ -0,0 +1,130 @@
+package rtsp
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MediaCapability describes one media section of a stream's SDP
+type MediaCapability struct {
+	MediaType   string            `json:"mediaType"` // "video" or "audio"
+	PayloadType uint8             `json:"payloadType"`
+	Codec       string            `json:"codec"` // e.g. "H264", "MPEG4-GENERIC"
+	ClockRate   int               `json:"clockRate"`
+	Channels    int               `json:"channels,omitempty"`
+	Fmtp        map[string]string `json:"fmtp,omitempty"`
+	Control     string            `json:"control,omitempty"`
+}
+
+// ProbeResult is what a server advertises for a stream before playback
+type ProbeResult struct {
+	Methods  []string          `json:"methods,omitempty"` // RTSP methods from OPTIONS
+	Media    []MediaCapability `json:"media"`
+	ProbedAt time.Time         `json:"probedAt"`
+}
+
+// Media returns the capabilities parsed from the DESCRIBE response, in SDP
+// order. Call after Connect.
+func (c *Client) Media() []MediaCapability {
+	ids := make([]int, 0, len(c.Channels))
+	for id := range c.Channels {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	media := make([]MediaCapability, 0, len(ids))
+	for _, id := range ids {
+		ch := c.Channels[byte(id)]
+		media = append(media, MediaCapability{
+			MediaType:   ch.MediaType,
+			PayloadType: ch.PayloadType,
+			Codec:       ch.Codec,
+			ClockRate:   ch.ClockRate,
+			Channels:    ch.Channels,
+			Fmtp:        ch.FmtpParams(),
+			Control:     ch.Control,
+		})
+	}
+	return media
+}
+
+// Probe returns the OPTIONS and DESCRIBE results of a connected client
+func (c *Client) Probe() *ProbeResult {
+	return &ProbeResult{
+		Methods:  append([]string(nil), c.methods...),
+		Media:    c.Media(),
+		ProbedAt: time.Now(),
+	}
+}
+
+// ProbeStream connects to rtspURL, performs OPTIONS and DESCRIBE only and
+// returns the advertised capabilities. No session is set up, so the camera
+// never starts sending media. opts supply the timeouts and TLS settings.
+func ProbeStream(ctx context.Context, rtspURL string, opts ClientOptions, logger *slog.Logger) (*ProbeResult, error) {
+	c := NewClient(rtspURL, logger)
+	c.SetOptions(opts)
+	defer c.Abort() // No session to TEARDOWN
+
+	if err := c.Connect(ctx); err != nil {
+		return nil, err
+	}
+	return c.Probe(), nil
+}
+
+// ProbeCache remembers probe results per URL, so repeated metadata lookups
+// for the same stream don't open new RTSP connections. Nest stream URLs
+// change with every generated stream, so entries only need to outlive one
+// stream.
+type ProbeCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	results map[string]*ProbeResult
+}
+
+// NewProbeCache creates a cache whose entries expire after ttl
+func NewProbeCache(ttl time.Duration) *ProbeCache {
+	return &ProbeCache{ttl: ttl, results: make(map[string]*ProbeResult)}
+}
+
+// Put records a result obtained elsewhere, e.g. from a relay's own DESCRIBE
+func (pc *ProbeCache) Put(rtspURL string, result *ProbeResult) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	// Drop expired entries while we hold the lock
+	for u, r := range pc.results {
+		if time.Since(r.ProbedAt) > pc.ttl {
+			delete(pc.results, u)
+		}
+	}
+	pc.results[rtspURL] = result
+}
+
+// Get returns a cached, unexpired result
+func (pc *ProbeCache) Get(rtspURL string) (*ProbeResult, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	r, ok := pc.results[rtspURL]
+	if !ok || time.Since(r.ProbedAt) > pc.ttl {
+		return nil, false
+	}
+	return r, true
+}
+
+// Probe returns the cached result for rtspURL or probes the stream with opts
+func (pc *ProbeCache) Probe(ctx context.Context, rtspURL string, opts ClientOptions, logger *slog.Logger) (*ProbeResult, error) {
+	if r, ok := pc.Get(rtspURL); ok {
+		return r, nil
+	}
+	r, err := ProbeStream(ctx, rtspURL, opts, logger)
+	if err != nil {
+		return nil, err
+	}
+	pc.Put(rtspURL, r)
+	return r, nil
+}
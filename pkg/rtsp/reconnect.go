@@ -0,0 +1,115 @@
+package rtsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// reconnectBackoff is the wait before the second reconnect attempt,
+// doubling after each further failure
+const reconnectBackoff = time.Second
+
+// errClientClosed ends a reconnect that Close interrupted
+var errClientClosed = errors.New("client closed")
+
+// reconnect re-establishes a dropped session against the same URL: dial,
+// DESCRIBE, SETUP of the tracks the first session had, and PLAY. The
+// camera must still offer them with the same codecs, since the caller's
+// depacketizers were built for those.
+func (c *Client) reconnect(ctx context.Context) error {
+	codecs := make(map[string]string) // Media type -> codec
+	for _, ch := range c.Channels {
+		codecs[ch.MediaType] = ch.Codec
+	}
+
+	backoff := reconnectBackoff
+	var err error
+	for attempt := 1; attempt <= c.opts.ReconnectAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		if c.closed.Load() {
+			return errClientClosed
+		}
+
+		c.resetSession()
+		if err = c.resume(ctx, codecs); err == nil {
+			c.logger.Info("RTSP session resumed", "attempt", attempt)
+			return nil
+		}
+		c.logger.Warn("RTSP reconnect attempt failed",
+			"attempt", attempt,
+			"max_attempts", c.opts.ReconnectAttempts,
+			"error", err)
+	}
+	c.resetSession()
+	return err
+}
+
+// resume opens a new session for the tracks in codecs
+func (c *Client) resume(ctx context.Context, codecs map[string]string) error {
+	if err := c.Connect(ctx); err != nil {
+		return err
+	}
+
+	found := make(map[string]bool)
+	for id, ch := range c.Channels {
+		codec, ok := codecs[ch.MediaType]
+		if !ok {
+			delete(c.Channels, id) // Skipped in the first session
+			continue
+		}
+		if !strings.EqualFold(codec, ch.Codec) {
+			return fmt.Errorf("%s codec changed from %s to %s", ch.MediaType, codec, ch.Codec)
+		}
+		found[ch.MediaType] = true
+	}
+	for mediaType := range codecs {
+		if !found[mediaType] {
+			return fmt.Errorf("camera no longer offers %s", mediaType)
+		}
+	}
+
+	if err := c.SetupTracks(ctx); err != nil {
+		return err
+	}
+	if err := c.Play(ctx); err != nil {
+		return fmt.Errorf("PLAY: %w", err)
+	}
+	if c.closed.Load() {
+		// Close ran while we dialed and missed the new connection
+		c.resetSession()
+		return errClientClosed
+	}
+	return nil
+}
+
+// resetSession stops the session's background goroutines and closes its
+// connection and sockets without TEARDOWN, leaving the client ready for
+// Connect. Track receivers are kept; they restart on the new SSRC.
+func (c *Client) resetSession() {
+	if c.keepaliveCancel != nil {
+		c.keepaliveCancel()
+		c.keepaliveCancel = nil
+	}
+	if c.rtcpCancel != nil {
+		c.rtcpCancel()
+		c.rtcpCancel = nil
+	}
+	c.closeUDP()
+	c.udp = nil
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	c.session, c.sessionTimeout, c.baseURL = "", 0, ""
+	c.Channels = make(map[byte]*Channel)
+}
@@ -0,0 +1,83 @@
+package rtsp
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtsp/rtsptest"
+	pionRTP "github.com/pion/rtp"
+)
+
+func TestClientReconnects(t *testing.T) {
+	srv := rtsptest.NewServer(rtsptest.Options{FPS: 30})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c := NewClient(srv.URL, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer c.Close()
+	c.SetOptions(ClientOptions{ReconnectAttempts: 2})
+
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if err := c.SetupTracks(ctx); err != nil {
+		t.Fatalf("SetupTracks: %v", err)
+	}
+	if err := c.Play(ctx); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+
+	var packets atomic.Int64
+	reconnected := make(chan struct{}, 1)
+	c.OnRTPPacket = func(_ byte, _ *pionRTP.Packet) { packets.Add(1) }
+	c.OnReconnect = func() { reconnected <- struct{}{} }
+	done := make(chan error, 1)
+	go func() { done <- c.ReadPackets(ctx) }()
+
+	waitPackets := func(n int64) {
+		for packets.Load() < n {
+			if ctx.Err() != nil {
+				t.Fatalf("only %d packets", packets.Load())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitPackets(10)
+
+	srv.CloseClientConnections()
+	select {
+	case <-reconnected:
+	case err := <-done:
+		t.Fatalf("ReadPackets returned %v instead of reconnecting", err)
+	case <-ctx.Done():
+		t.Fatal("no reconnect")
+	}
+	waitPackets(packets.Load() + 10)
+
+	describes := 0
+	for _, req := range srv.Requests() {
+		if req.Method == "DESCRIBE" {
+			describes++
+		}
+	}
+	if describes != 2 {
+		t.Errorf("%d DESCRIBEs, want one per session", describes)
+	}
+
+	// Without a server to return to, the error reaches the caller
+	srv.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("ReadPackets returned nil after reconnecting failed")
+		}
+	case <-ctx.Done():
+		t.Fatal("ReadPackets kept running after reconnecting failed")
+	}
+}
@@ -0,0 +1,310 @@
+package rtsp
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+// defaultRTCPInterval is how often receiver reports are sent: RFC 3550's
+// five second minimum, which at a handful of tracks is also its computed
+// interval
+const defaultRTCPInterval = 5 * time.Second
+
+// ntpEpochOffset is the seconds from the NTP epoch (1900) to the Unix epoch
+const ntpEpochOffset = 2208988800
+
+// SenderReport is the latest RTCP sender report from a track's source: the
+// camera's wall clock at one of its RTP timestamps. It maps every RTP
+// timestamp of the track to the camera's clock, so tracks can be lined up
+// with each other and frames dated by when the camera captured them.
+type SenderReport struct {
+	NTPTime    time.Time // The camera's clock at RTPTime
+	RTPTime    uint32
+	ClockRate  int    // The track's RTP clock rate
+	Packets    uint32 // Sent by the camera so far, by its own count
+	Octets     uint32
+	ReceivedAt time.Time // Our clock when the report arrived
+}
+
+// Wallclock returns the camera's clock at an RTP timestamp of the track.
+// Timestamps within half the 32-bit range of RTPTime map either side of it.
+func (sr SenderReport) Wallclock(ts uint32) time.Time {
+	delta := int64(int32(ts - sr.RTPTime))
+	return sr.NTPTime.Add(time.Duration(delta) * time.Second / time.Duration(sr.ClockRate))
+}
+
+// ntpTime converts a 64-bit NTP timestamp to a time.Time
+func ntpTime(ntp uint64) time.Time {
+	secs := int64(ntp>>32) - ntpEpochOffset
+	nanos := (ntp & 0xFFFFFFFF) * uint64(time.Second) >> 32
+	return time.Unix(secs, int64(nanos))
+}
+
+// rtpReceiver keeps one track's reception statistics (RFC 3550 A.1, A.3
+// and A.8) for the receiver reports sent back to the camera
+type rtpReceiver struct {
+	clockRate float64
+	mediaType string // For Stats
+
+	mu       sync.Mutex
+	ssrc     uint32 // Media source, from its RTP packets
+	started  bool
+	baseSeq  uint32
+	maxSeq   uint16
+	cycles   uint32 // Sequence wraps, shifted left 16
+	received uint32
+
+	expectedPrior uint32 // At the previous report, for fraction lost
+	receivedPrior uint32
+
+	epoch   time.Time // Arrival times are measured from here in RTP units
+	transit uint32    // Arrival minus RTP timestamp of the last packet
+	jitter  float64   // Interarrival jitter in RTP units
+
+	lastSR   uint32    // Middle 32 bits of the last SR's NTP timestamp
+	lastSRAt time.Time // When it arrived
+	sr       SenderReport
+
+	// Totals across source restarts, for Stats
+	packets uint64
+	bytes   uint64
+	gaps    uint64
+	rate    rateMeter
+}
+
+func newRTPReceiver(clockRate int) *rtpReceiver {
+	if clockRate <= 0 {
+		clockRate = 90000
+	}
+	return &rtpReceiver{clockRate: float64(clockRate), sr: SenderReport{ClockRate: clockRate}}
+}
+
+// packet records an RTP packet that arrived at now
+func (r *rtpReceiver) packet(pkt *rtp.Packet, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	size := uint64(pkt.MarshalSize())
+	r.packets++
+	r.bytes += size
+	r.rate.add(size, now)
+
+	seq := pkt.SequenceNumber
+	arrival := uint32(int64(now.Sub(r.epoch).Seconds() * r.clockRate))
+	if !r.started || pkt.SSRC != r.ssrc {
+		// First packet, or the camera restarted its stream, whose earlier
+		// sender report no longer applies
+		if r.started {
+			r.lastSR, r.lastSRAt, r.sr = 0, time.Time{}, SenderReport{ClockRate: r.sr.ClockRate}
+		}
+		r.started, r.ssrc = true, pkt.SSRC
+		r.baseSeq, r.maxSeq, r.cycles = uint32(seq), seq, 0
+		r.received, r.expectedPrior, r.receivedPrior = 1, 0, 0
+		r.epoch, r.transit, r.jitter = now, -pkt.Timestamp, 0
+		return
+	}
+
+	r.received++
+	if delta := seq - r.maxSeq; delta > 0 && delta < 0x8000 {
+		if delta > 1 {
+			r.gaps++
+		}
+		if seq < r.maxSeq {
+			r.cycles += 1 << 16
+		}
+		r.maxSeq = seq
+	}
+
+	transit := arrival - pkt.Timestamp
+	d := float64(int32(transit - r.transit))
+	if d < 0 {
+		d = -d
+	}
+	r.transit = transit
+	r.jitter += (d - r.jitter) / 16
+}
+
+// senderReport records an SR that arrived at now. Reports from another
+// source than the track's RTP are ignored.
+func (r *rtpReceiver) senderReport(sr *rtcp.SenderReport, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started && sr.SSRC != r.ssrc {
+		return
+	}
+	r.lastSR = uint32(sr.NTPTime >> 16)
+	r.lastSRAt = now
+	r.sr.NTPTime = ntpTime(sr.NTPTime)
+	r.sr.RTPTime = sr.RTPTime
+	r.sr.Packets, r.sr.Octets = sr.PacketCount, sr.OctetCount
+	r.sr.ReceivedAt = now
+}
+
+// senderReportSnapshot returns the last SR; false before one arrived
+func (r *rtpReceiver) senderReportSnapshot() (SenderReport, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sr, !r.lastSRAt.IsZero()
+}
+
+// report returns the track's reception report block for an RR sent at now,
+// and starts the next fraction lost interval; false before any packet
+func (r *rtpReceiver) report(now time.Time) (rtcp.ReceptionReport, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.started {
+		return rtcp.ReceptionReport{}, false
+	}
+
+	extendedMax := r.cycles + uint32(r.maxSeq)
+	expected := extendedMax - r.baseSeq + 1
+	lost := int64(expected) - int64(r.received)
+	lost = min(max(lost, 0), 0x7FFFFF) // 24 bits; duplicates can't make it negative
+
+	expectedInterval := expected - r.expectedPrior
+	receivedInterval := r.received - r.receivedPrior
+	r.expectedPrior, r.receivedPrior = expected, r.received
+	var fraction uint8
+	if lostInterval := int64(expectedInterval) - int64(receivedInterval); expectedInterval > 0 && lostInterval > 0 {
+		fraction = uint8(lostInterval << 8 / int64(expectedInterval))
+	}
+
+	var delay uint32
+	if !r.lastSRAt.IsZero() {
+		delay = uint32(now.Sub(r.lastSRAt).Seconds() * 65536)
+	}
+	return rtcp.ReceptionReport{
+		SSRC:               r.ssrc,
+		FractionLost:       fraction,
+		TotalLost:          uint32(lost),
+		LastSequenceNumber: extendedMax,
+		Jitter:             uint32(r.jitter),
+		LastSenderReport:   r.lastSR,
+		Delay:              delay,
+	}, true
+}
+
+// SenderReport returns the latest sender report for the track on an RTP
+// channel, mapping its timestamps to the camera's clock; false until the
+// camera has sent one. Safe to call while ReadPackets runs.
+func (c *Client) SenderReport(channel byte) (SenderReport, bool) {
+	r := c.receivers[channel]
+	if r == nil {
+		return SenderReport{}, false
+	}
+	return r.senderReportSnapshot()
+}
+
+// recordRTP feeds an RTP packet read on a channel to its track's statistics
+func (c *Client) recordRTP(channel byte, pkt *rtp.Packet) {
+	if r := c.receivers[channel]; r != nil {
+		r.packet(pkt, time.Now())
+	}
+}
+
+// handleRTCP reads the compound RTCP packet received on an odd channel,
+// keeping sender reports for the next receiver report's LSR and DLSR
+func (c *Client) handleRTCP(channel byte, payload []byte) {
+	pkts, err := rtcp.Unmarshal(payload)
+	if err != nil {
+		c.logger.Debug("failed to unmarshal RTCP packet", "channel", channel, "size", len(payload), "error", err)
+		return
+	}
+	r := c.receivers[channel-1]
+	for _, pkt := range pkts {
+		if sr, ok := pkt.(*rtcp.SenderReport); ok && r != nil {
+			r.senderReport(sr, time.Now())
+		}
+	}
+}
+
+// startRTCP sends receiver reports for every track each rtcpInterval until
+// Close
+func (c *Client) startRTCP(ctx context.Context) {
+	rtcpCtx, cancel := context.WithCancel(ctx)
+	c.rtcpCancel = cancel
+
+	goroutines.Go("rtsp.rtcp", "", func() {
+		ticker := time.NewTicker(c.rtcpInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-rtcpCtx.Done():
+				return
+			case <-ticker.C:
+				if err := c.sendReceiverReports(); err != nil {
+					c.logger.Warn("receiver report write failed", "error", err)
+					return
+				}
+			}
+		}
+	})
+}
+
+// sendReceiverReports sends each track that has received packets an RR,
+// with the SDES CNAME RFC 3550 requires in every compound packet
+func (c *Client) sendReceiverReports() error {
+	now := time.Now()
+	for channel, r := range c.receivers {
+		report, ok := r.report(now)
+		if !ok {
+			continue
+		}
+		data, err := rtcp.Marshal([]rtcp.Packet{
+			&rtcp.ReceiverReport{SSRC: c.rtcpSSRC, Reports: []rtcp.ReceptionReport{report}},
+			&rtcp.SourceDescription{Chunks: []rtcp.SourceDescriptionChunk{{
+				Source: c.rtcpSSRC,
+				Items:  []rtcp.SourceDescriptionItem{{Type: rtcp.SDESCNAME, Text: c.rtcpCNAME()}},
+			}}},
+		})
+		if err != nil {
+			return fmt.Errorf("marshal receiver report: %w", err)
+		}
+		if err := c.writeRTCP(channel+1, data); err != nil {
+			return err
+		}
+		c.logger.Debug("receiver report sent",
+			"channel", channel+1,
+			"fraction_lost", report.FractionLost,
+			"total_lost", report.TotalLost,
+			"jitter", report.Jitter)
+	}
+	return nil
+}
+
+// writeRTCP sends an RTCP packet on a track's RTCP channel: interleaved in
+// the RTSP connection, or to the server's RTCP port over UDP
+func (c *Client) writeRTCP(channel byte, data []byte) error {
+	if c.transport == TransportUDP {
+		t := c.udp[channel-1]
+		if t == nil || t.rtcpTo == nil {
+			return nil // Server gave no port to report to
+		}
+		_, err := t.rtcp.WriteToUDP(data, t.rtcpTo)
+		return err
+	}
+
+	frame := make([]byte, 4, 4+len(data))
+	frame[0], frame[1] = '$', channel
+	binary.BigEndian.PutUint16(frame[2:], uint16(len(data)))
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.opts.WriteTimeout)); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(append(frame, data...))
+	return err
+}
+
+// rtcpCNAME names the client in its RTCP reports
+func (c *Client) rtcpCNAME() string {
+	return fmt.Sprintf("nest-cloudflare-relay-%08x", c.rtcpSSRC)
+}
@@ -0,0 +1,117 @@
+package rtsp
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtsp/rtsptest"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+func TestRTPReceiverReport(t *testing.T) {
+	r := newRTPReceiver(90000)
+	start := time.Now()
+	now := start
+	send := func(seq uint16) {
+		// One frame per packet at 30 fps, timestamps and arrivals in step
+		frame := uint32(seq - 65530)
+		now = start.Add(time.Duration(frame) * time.Second / 30)
+		r.packet(&rtp.Packet{Header: rtp.Header{SSRC: 42, SequenceNumber: seq, Timestamp: 1000 + frame*3000}}, now)
+	}
+	if _, ok := r.report(now); ok {
+		t.Fatal("report before any packet")
+	}
+
+	// Ten packets across the sequence wrap, one of them lost
+	for seq := uint16(65530); seq != 4; seq++ {
+		if seq != 65534 {
+			send(seq)
+		}
+	}
+	r.senderReport(&rtcp.SenderReport{SSRC: 42, NTPTime: 0x1122334455667788}, now)
+
+	rr, ok := r.report(now.Add(time.Second))
+	if !ok {
+		t.Fatal("no report")
+	}
+	if rr.SSRC != 42 || rr.TotalLost != 1 || rr.FractionLost != 256/10 || rr.LastSequenceNumber != 1<<16+3 {
+		t.Errorf("report %+v", rr)
+	}
+	if rr.LastSenderReport != 0x33445566 || rr.Delay != 65536 {
+		t.Errorf("LSR %#x, DLSR %d", rr.LastSenderReport, rr.Delay)
+	}
+	if rr.Jitter != 0 {
+		t.Errorf("jitter %d for perfectly spaced packets", rr.Jitter)
+	}
+
+	// Fraction lost covers only the interval since the last report
+	send(4)
+	if rr, _ = r.report(now); rr.FractionLost != 0 || rr.TotalLost != 1 {
+		t.Errorf("second report %+v", rr)
+	}
+}
+
+func TestClientSendsReceiverReports(t *testing.T) {
+	srv := rtsptest.NewServer(rtsptest.Options{FPS: 30})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c := NewClient(srv.URL, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer c.Close()
+	c.rtcpInterval = 50 * time.Millisecond
+
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if err := c.SetupTracks(ctx); err != nil {
+		t.Fatalf("SetupTracks: %v", err)
+	}
+	if err := c.Play(ctx); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+	go c.ReadPackets(ctx)
+
+	for ctx.Err() == nil {
+		if reports := srv.ReceiverReports(); len(reports) > 0 {
+			rr := reports[len(reports)-1]
+			if rr.SSRC != c.rtcpSSRC || len(rr.Reports) != 1 || rr.Reports[0].LastSequenceNumber == 0 {
+				t.Errorf("receiver report %+v", rr)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("no receiver report reached the server")
+}
+
+func TestSenderReportWallclock(t *testing.T) {
+	r := newRTPReceiver(90000)
+	if _, ok := r.senderReportSnapshot(); ok {
+		t.Fatal("sender report before any arrived")
+	}
+	r.packet(&rtp.Packet{Header: rtp.Header{SSRC: 42}}, time.Now())
+
+	// 2024-01-01 00:00:00.5 UTC in NTP format
+	camera := time.Date(2024, 1, 1, 0, 0, 0, 500_000_000, time.UTC)
+	ntp := uint64(camera.Unix()+ntpEpochOffset)<<32 | 1<<31
+	r.senderReport(&rtcp.SenderReport{SSRC: 42, NTPTime: ntp, RTPTime: 4_294_000_000, PacketCount: 7}, time.Now())
+	r.senderReport(&rtcp.SenderReport{SSRC: 99, NTPTime: 0}, time.Now()) // Another source
+
+	sr, ok := r.senderReportSnapshot()
+	if !ok || !sr.NTPTime.Equal(camera) || sr.Packets != 7 {
+		t.Fatalf("sender report %+v", sr)
+	}
+	// One second later, across the RTP timestamp wrap, and one before
+	if got := sr.Wallclock(4_294_000_000 + 90000); !got.Equal(camera.Add(time.Second)) {
+		t.Errorf("wallclock +1s = %v", got)
+	}
+	if got := sr.Wallclock(4_294_000_000 - 45000); !got.Equal(camera.Add(-time.Second / 2)) {
+		t.Errorf("wallclock -0.5s = %v", got)
+	}
+}
@@ -0,0 +1,259 @@
+package rtsptest
+
+import "bytes"
+
+// Synthetic stream geometry: 4x3 macroblocks (64x48). Every IDR is coded as
+// I_PCM macroblocks and every P frame as a single skip run, so the stream is
+// fully decodable without an encoder and keyframes are large enough to need
+// FU-A fragmentation.
+const (
+	widthMBs  = 4
+	heightMBs = 3
+)
+
+var startCode = []byte{0, 0, 0, 1}
+
+// SyntheticGOP returns one GOP of Annex-B access units: the first carries
+// SPS, PPS and an IDR slice, the remaining size-1 are P frames. Looping the
+// GOP yields a valid stream because every IDR resets frame_num.
+func SyntheticGOP(size int) [][]byte {
+	if size < 1 {
+		size = 1
+	}
+	sps, pps := syntheticSPS(), syntheticPPS()
+
+	gop := make([][]byte, 0, size)
+	gop = append(gop, annexB(sps, pps, syntheticIDR()))
+	for i := 1; i < size; i++ {
+		gop = append(gop, annexB(syntheticP(i)))
+	}
+	return gop
+}
+
+// AccessUnits splits an Annex-B H.264 stream into access units so a recorded
+// sample can be served frame by frame. A new unit starts at an access unit
+// delimiter, at parameter sets or SEI following a slice, and at a slice whose
+// first_mb_in_slice is zero.
+func AccessUnits(stream []byte) [][]byte {
+	var units [][]byte
+	var cur []byte
+	sawSlice := false
+
+	for _, nal := range splitAnnexB(stream) {
+		typ := nal[0] & 0x1F
+		newUnit := false
+		switch typ {
+		case 9: // AUD
+			newUnit = true
+		case 6, 7, 8: // SEI, SPS, PPS
+			newUnit = sawSlice
+		case 1, 5: // Slices
+			newUnit = sawSlice && len(nal) > 1 && nal[1]&0x80 != 0
+		}
+
+		if newUnit && len(cur) > 0 {
+			units = append(units, cur)
+			cur, sawSlice = nil, false
+		}
+		cur = append(cur, startCode...)
+		cur = append(cur, nal...)
+		if typ == 1 || typ == 5 {
+			sawSlice = true
+		}
+	}
+	if len(cur) > 0 {
+		units = append(units, cur)
+	}
+	return units
+}
+
+// splitAnnexB returns the NAL units between start codes
+func splitAnnexB(b []byte) [][]byte {
+	var nals [][]byte
+	for len(b) > 0 {
+		i := bytes.Index(b, []byte{0, 0, 1})
+		if i < 0 {
+			break
+		}
+		b = b[i+3:]
+		end := bytes.Index(b, []byte{0, 0, 1})
+		if end < 0 {
+			end = len(b)
+		} else if end > 0 && b[end-1] == 0 {
+			end-- // Four-byte start code
+		}
+		if end > 0 {
+			nals = append(nals, b[:end])
+		}
+		b = b[end:]
+	}
+	return nals
+}
+
+// annexB joins NAL units with start codes
+func annexB(nals ...[]byte) []byte {
+	var b []byte
+	for _, nal := range nals {
+		b = append(b, startCode...)
+		b = append(b, nal...)
+	}
+	return b
+}
+
+// syntheticSPS is a constrained-baseline SPS: level 1.0, POC type 2 (no
+// reordering), 4-bit frame_num, one reference frame, no VUI
+func syntheticSPS() []byte {
+	var w bitWriter
+	w.u(8, 66)   // profile_idc: Baseline
+	w.u(8, 0xC0) // constraint_set0/1: Constrained Baseline
+	w.u(8, 10)   // level_idc
+	w.ue(0)      // seq_parameter_set_id
+	w.ue(0)      // log2_max_frame_num_minus4
+	w.ue(2)      // pic_order_cnt_type
+	w.ue(1)      // max_num_ref_frames
+	w.u(1, 0)    // gaps_in_frame_num_value_allowed_flag
+	w.ue(widthMBs - 1)
+	w.ue(heightMBs - 1)
+	w.u(1, 1) // frame_mbs_only_flag
+	w.u(1, 1) // direct_8x8_inference_flag
+	w.u(1, 0) // frame_cropping_flag
+	w.u(1, 0) // vui_parameters_present_flag
+	return nal(0x67, w.trailing())
+}
+
+// syntheticPPS is a CAVLC PPS with all defaults
+func syntheticPPS() []byte {
+	var w bitWriter
+	w.ue(0)   // pic_parameter_set_id
+	w.ue(0)   // seq_parameter_set_id
+	w.u(1, 0) // entropy_coding_mode_flag: CAVLC
+	w.u(1, 0) // bottom_field_pic_order_in_frame_present_flag
+	w.ue(0)   // num_slice_groups_minus1
+	w.ue(0)   // num_ref_idx_l0_default_active_minus1
+	w.ue(0)   // num_ref_idx_l1_default_active_minus1
+	w.u(1, 0) // weighted_pred_flag
+	w.u(2, 0) // weighted_bipred_idc
+	w.se(0)   // pic_init_qp_minus26
+	w.se(0)   // pic_init_qs_minus26
+	w.se(0)   // chroma_qp_index_offset
+	w.u(1, 0) // deblocking_filter_control_present_flag
+	w.u(1, 0) // constrained_intra_pred_flag
+	w.u(1, 0) // redundant_pic_cnt_present_flag
+	return nal(0x68, w.trailing())
+}
+
+// syntheticIDR codes every macroblock as I_PCM with a diagonal luma ramp
+func syntheticIDR() []byte {
+	var w bitWriter
+	w.ue(0)   // first_mb_in_slice
+	w.ue(7)   // slice_type: I (all slices)
+	w.ue(0)   // pic_parameter_set_id
+	w.u(4, 0) // frame_num
+	w.ue(0)   // idr_pic_id
+	w.u(1, 0) // no_output_of_prior_pics_flag
+	w.u(1, 0) // long_term_reference_flag
+	w.se(0)   // slice_qp_delta
+
+	for mb := 0; mb < widthMBs*heightMBs; mb++ {
+		w.ue(25) // mb_type: I_PCM
+		w.align()
+		for i := 0; i < 256; i++ {
+			w.u(8, uint32(16+(mb*16+i%16+i/16)%220)) // Luma, kept in video range
+		}
+		for i := 0; i < 128; i++ {
+			w.u(8, 128) // Neutral chroma
+		}
+	}
+	return nal(0x65, w.trailing())
+}
+
+// syntheticP codes a P frame that skips every macroblock (a repeat of the IDR)
+func syntheticP(frameNum int) []byte {
+	var w bitWriter
+	w.ue(0)                            // first_mb_in_slice
+	w.ue(5)                            // slice_type: P (all slices)
+	w.ue(0)                            // pic_parameter_set_id
+	w.u(4, uint32(frameNum%16))        // frame_num
+	w.u(1, 0)                          // num_ref_idx_active_override_flag
+	w.u(1, 0)                          // ref_pic_list_modification_flag_l0
+	w.u(1, 0)                          // adaptive_ref_pic_marking_mode_flag
+	w.se(0)                            // slice_qp_delta
+	w.ue(uint32(widthMBs * heightMBs)) // mb_skip_run
+	return nal(0x41, w.trailing())
+}
+
+// nal prepends the header byte and inserts emulation prevention bytes
+func nal(header byte, rbsp []byte) []byte {
+	out := []byte{header}
+	zeros := 0
+	for _, b := range rbsp {
+		if zeros >= 2 && b <= 3 {
+			out = append(out, 3)
+			zeros = 0
+		}
+		out = append(out, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return out
+}
+
+// bitWriter writes the fixed-length and Exp-Golomb fields of H.264 syntax
+type bitWriter struct {
+	buf   []byte
+	nbits int
+}
+
+func (w *bitWriter) bit(v uint32) {
+	if w.nbits%8 == 0 {
+		w.buf = append(w.buf, 0)
+	}
+	if v != 0 {
+		w.buf[len(w.buf)-1] |= 0x80 >> (w.nbits % 8)
+	}
+	w.nbits++
+}
+
+// u writes v in n bits, most significant first
+func (w *bitWriter) u(n int, v uint32) {
+	for i := n - 1; i >= 0; i-- {
+		w.bit(v >> i & 1)
+	}
+}
+
+// ue writes an unsigned Exp-Golomb code
+func (w *bitWriter) ue(v uint32) {
+	v++
+	n := 0
+	for x := v; x > 1; x >>= 1 {
+		n++
+	}
+	w.u(n, 0)
+	w.u(n+1, v)
+}
+
+// se writes a signed Exp-Golomb code
+func (w *bitWriter) se(v int32) {
+	if v > 0 {
+		w.ue(uint32(2*v - 1))
+	} else {
+		w.ue(uint32(-2 * v))
+	}
+}
+
+// align pads with zero bits to the next byte boundary
+func (w *bitWriter) align() {
+	for w.nbits%8 != 0 {
+		w.bit(0)
+	}
+}
+
+// trailing appends rbsp_trailing_bits and returns the RBSP
+func (w *bitWriter) trailing() []byte {
+	w.bit(1)
+	w.align()
+	return w.buf
+}
@@ -0,0 +1,36 @@
+package rtsptest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+)
+
+func TestSyntheticGOP(t *testing.T) {
+	gop := SyntheticGOP(5)
+	if len(gop) != 5 {
+		t.Fatalf("len = %d, want 5", len(gop))
+	}
+
+	nals := splitAnnexB(gop[0])
+	if len(nals) != 3 || nals[0][0]&0x1F != 7 || nals[1][0]&0x1F != 8 || nals[2][0]&0x1F != 5 {
+		t.Fatalf("first access unit is not SPS+PPS+IDR: %d NALs", len(nals))
+	}
+
+	sps := h264.DecodeSPS(nals[0])
+	if sps == nil || sps.Width() != widthMBs*16 || sps.Height() != heightMBs*16 {
+		t.Fatalf("SPS = %v, want %dx%d", sps, widthMBs*16, heightMBs*16)
+	}
+
+	// Joining and re-splitting yields the same access units
+	units := AccessUnits(bytes.Join(gop, nil))
+	if len(units) != len(gop) {
+		t.Fatalf("AccessUnits returned %d units, want %d", len(units), len(gop))
+	}
+	for i := range gop {
+		if !bytes.Equal(units[i], gop[i]) {
+			t.Errorf("unit %d differs", i)
+		}
+	}
+}
@@ -0,0 +1,486 @@
+// Package rtsptest provides an in-process RTSP(S) server for tests and demos,
+// in the spirit of net/http/httptest. It serves a looped H.264 stream over
+// interleaved TCP and reproduces the Nest camera behaviors the relay's
+// client depends on:
+//
+//   - the stream URL carries an ?auth= token that DESCRIBE must present, and
+//     the Content-Base returned for SETUP/PLAY omits it
+//   - PLAY without a Range header is rejected
+//   - only interleaved TCP transport is accepted, unless Options.UDP allows
+//     RTP over UDP as generic cameras do
+//   - a session that sends no request (OPTIONS/GET_PARAMETER keepalive)
+//     within SessionTimeout is dropped
+//
+// With Options.HTTPTunnel it also serves RTSP tunnelled over HTTP, as
+// cameras behind web-only firewalls are reached.
+package rtsptest
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/internal/rtspmsg"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+)
+
+const (
+	streamPath  = "/sdm_live_stream/rtsptest"
+	payloadType = 96
+	rtpMTU      = 1200
+)
+
+// Options configures a Server. The zero value serves a 15 fps synthetic
+// stream with Nest's 60 second session timeout.
+type Options struct {
+	Frames         [][]byte      // Annex-B access units, looped; defaults to SyntheticGOP(30)
+	FPS            int           // Frame rate (default 15, Nest's usual rate)
+	Token          string        // Required ?auth= value (default "rtsptest-token")
+	SessionTimeout time.Duration // Idle time before a session is dropped (default 60s)
+	UDP            bool          // Also accept RTP/AVP over UDP (client_port=)
+	Channel        int           // Interleaved RTP channel SETUP assigns instead of the one requested (0 honors the request)
+	HTTPTunnel     bool          // Also accept RTSP tunnelled over HTTP (a GET/POST pair) on the same port
+}
+
+// Request is an RTSP request received by the server, kept for assertions.
+// Header keys are canonicalized ("CSeq", "Transport").
+type Request = rtspmsg.Request
+
+// Server is a running test RTSP server
+type Server struct {
+	URL string // Stream URL including the auth token
+
+	opts Options
+	ln   net.Listener
+	cert *x509.Certificate
+	base string // Content-Base (URL without the token)
+
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	tunnels  map[string]net.Conn // HTTP tunnel GETs awaiting their POST, by x-sessioncookie
+	requests []Request
+	reports  []rtcp.ReceiverReport
+	closed   bool
+
+	wg sync.WaitGroup
+}
+
+// NewServer starts a plain rtsp:// server on a loopback port
+func NewServer(opts Options) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("rtsptest: listen: %v", err))
+	}
+	return start(ln, "rtsp", nil, opts)
+}
+
+// NewTLSServer starts an rtsps:// server with a self-signed certificate for
+// 127.0.0.1. Clients must trust Certificate().
+func NewTLSServer(opts Options) *Server {
+	cert, tlsCert := selfSigned()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{tlsCert}})
+	if err != nil {
+		panic(fmt.Sprintf("rtsptest: listen: %v", err))
+	}
+	return start(ln, "rtsps", cert, opts)
+}
+
+func start(ln net.Listener, scheme string, cert *x509.Certificate, opts Options) *Server {
+	if len(opts.Frames) == 0 {
+		opts.Frames = SyntheticGOP(30)
+	}
+	if opts.FPS <= 0 {
+		opts.FPS = 15
+	}
+	if opts.Token == "" {
+		opts.Token = "rtsptest-token"
+	}
+	if opts.SessionTimeout <= 0 {
+		opts.SessionTimeout = 60 * time.Second
+	}
+
+	s := &Server{
+		opts:    opts,
+		ln:      ln,
+		cert:    cert,
+		base:    fmt.Sprintf("%s://%s%s/", scheme, ln.Addr(), streamPath),
+		conns:   make(map[net.Conn]struct{}),
+		tunnels: make(map[string]net.Conn),
+	}
+	s.URL = fmt.Sprintf("%s://%s%s?auth=%s", scheme, ln.Addr(), streamPath, url.QueryEscape(opts.Token))
+
+	s.wg.Add(1)
+	go s.accept()
+	return s
+}
+
+// Certificate returns the TLS server's certificate, or nil for NewServer
+func (s *Server) Certificate() *x509.Certificate {
+	return s.cert
+}
+
+// Requests returns a copy of every request received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// ReceiverReports returns a copy of every RTCP receiver report clients sent
+// interleaved so far
+func (s *Server) ReceiverReports() []rtcp.ReceiverReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]rtcp.ReceiverReport(nil), s.reports...)
+}
+
+// CloseClientConnections drops every connection, as Nest does when a stream
+// URL expires
+func (s *Server) CloseClientConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+// Close stops the server and waits for connections to finish
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	s.ln.Close()
+	s.CloseClientConnections()
+	s.wg.Wait()
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+// session is one client connection
+type session struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+	id      string
+	channel byte // Interleaved RTP channel chosen by SETUP
+	setup   bool
+	cancel  context.CancelFunc
+
+	// UDP delivery, when SETUP asked for it
+	udp   *net.UDPConn
+	udpTo *net.UDPAddr // Client's RTP port
+}
+
+// writeRTP sends one RTP packet over the session's transport
+func (ss *session) writeRTP(b []byte) error {
+	if ss.udp != nil {
+		_, err := ss.udp.WriteToUDP(b, ss.udpTo)
+		return err
+	}
+	frame := make([]byte, 4, 4+len(b))
+	frame[0], frame[1] = '$', ss.channel
+	binary.BigEndian.PutUint16(frame[2:], uint16(len(b)))
+	return ss.write(append(frame, b...))
+}
+
+func (ss *session) write(b []byte) error {
+	ss.writeMu.Lock()
+	defer ss.writeMu.Unlock()
+	_, err := ss.conn.Write(b)
+	return err
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	r := bufio.NewReader(conn)
+	if s.opts.HTTPTunnel {
+		if b, err := r.Peek(5); err == nil && (string(b[:4]) == "GET " || string(b) == "POST ") {
+			s.serveTunnel(conn, r)
+			return
+		}
+	}
+	s.serveRTSP(conn, r)
+}
+
+// serveRTSP runs an RTSP session on conn, read through r
+func (s *Server) serveRTSP(conn net.Conn, r *bufio.Reader) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ss := &session{conn: conn, id: strconv.FormatUint(randUint64(), 16), cancel: cancel}
+	defer func() {
+		cancel()
+		if ss.udp != nil {
+			ss.udp.Close()
+		}
+	}()
+
+	for {
+		// Nest drops sessions that stop sending keepalives
+		conn.SetReadDeadline(time.Now().Add(s.opts.SessionTimeout))
+
+		b, err := r.Peek(1)
+		if err != nil {
+			return
+		}
+		if b[0] == '$' {
+			// Interleaved RTCP from the client; receiver reports are kept
+			hdr := make([]byte, 4)
+			if _, err := io.ReadFull(r, hdr); err != nil {
+				return
+			}
+			payload := make([]byte, binary.BigEndian.Uint16(hdr[2:]))
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			s.recordRTCP(payload)
+			continue
+		}
+
+		req, err := rtspmsg.ReadRequest(r)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		s.mu.Unlock()
+
+		status, header, body := s.handle(ss, req)
+		if err := ss.write(rtspmsg.Response(req, status, header, body)); err != nil {
+			return
+		}
+
+		switch {
+		case req.Method == "PLAY" && status == 200:
+			s.wg.Add(1)
+			go s.stream(ctx, ss)
+		case req.Method == "TEARDOWN":
+			return
+		}
+	}
+}
+
+// recordRTCP keeps the receiver reports in a compound RTCP packet
+func (s *Server) recordRTCP(payload []byte) {
+	pkts, err := rtcp.Unmarshal(payload)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, pkt := range pkts {
+		if rr, ok := pkt.(*rtcp.ReceiverReport); ok {
+			s.reports = append(s.reports, *rr)
+		}
+	}
+}
+
+// handle answers one request, returning status, headers and body
+func (s *Server) handle(ss *session, req Request) (int, map[string]string, string) {
+	switch req.Method {
+	case "OPTIONS", "GET_PARAMETER":
+		return 200, map[string]string{"Public": "OPTIONS, DESCRIBE, SETUP, PLAY, GET_PARAMETER, TEARDOWN"}, ""
+
+	case "DESCRIBE":
+		u, err := url.Parse(req.URL)
+		if err != nil || u.Query().Get("auth") != s.opts.Token {
+			return 401, nil, ""
+		}
+		return 200, map[string]string{
+			"Content-Base": s.base,
+			"Content-Type": "application/sdp",
+		}, s.sdp()
+
+	case "SETUP":
+		transport := req.Header["Transport"]
+		session := fmt.Sprintf("%s;timeout=%d", ss.id, int(s.opts.SessionTimeout.Seconds()))
+		lo, hi, ok := rtspmsg.ParseRange(transport, "client_port=")
+		if s.opts.UDP && !strings.Contains(transport, "TCP") && ok {
+			reply, err := ss.setupUDP(lo, hi)
+			if err != nil {
+				return 500, nil, ""
+			}
+			ss.setup = true
+			return 200, map[string]string{"Transport": reply, "Session": session}, ""
+		}
+		if lo, hi, ok = rtspmsg.ParseRange(transport, "interleaved="); !ok || !strings.Contains(transport, "TCP") {
+			return 461, nil, "" // Unsupported Transport
+		}
+		if s.opts.Channel > 0 {
+			lo, hi = s.opts.Channel, s.opts.Channel+1
+		}
+		ss.channel = byte(lo)
+		ss.setup = true
+		return 200, map[string]string{
+			"Transport": fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d", lo, hi),
+			"Session":   session,
+		}, ""
+
+	case "PLAY":
+		if !ss.setup || rtspmsg.SessionID(req.Header["Session"]) != ss.id {
+			return 454, nil, "" // Session Not Found
+		}
+		if req.Header["Range"] == "" {
+			return 457, nil, "" // Invalid Range: Nest never starts without one
+		}
+		return 200, map[string]string{"Range": "npt=0.000-", "Session": ss.id}, ""
+
+	case "TEARDOWN":
+		return 200, nil, ""
+	}
+	return 501, nil, ""
+}
+
+// setupUDP opens the socket RTP is sent from, to the client's port pair on
+// the address its RTSP connection came from, and returns the Transport reply
+func (ss *session) setupUDP(lo, hi int) (string, error) {
+	host, _, err := net.SplitHostPort(ss.conn.RemoteAddr().String())
+	if err != nil {
+		return "", err
+	}
+	if ss.udp == nil {
+		if ss.udp, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}); err != nil {
+			return "", err
+		}
+	}
+	ss.udpTo = &net.UDPAddr{IP: net.ParseIP(host), Port: lo}
+	port := ss.udp.LocalAddr().(*net.UDPAddr).Port
+	return fmt.Sprintf("RTP/AVP;unicast;client_port=%d-%d;server_port=%d-%d", lo, hi, port, port+1), nil
+}
+
+// stream sends the looped access units as RTP until the session ends
+func (s *Server) stream(ctx context.Context, ss *session) {
+	defer s.wg.Done()
+
+	step := uint32(90000 / s.opts.FPS)
+	p := rtp.NewPacketizer(rtpMTU, payloadType, uint32(randUint64()), &codecs.H264Payloader{}, rtp.NewRandomSequencer(), 90000)
+
+	ticker := time.NewTicker(time.Second / time.Duration(s.opts.FPS))
+	defer ticker.Stop()
+
+	for i := 0; ; i++ {
+		for _, pkt := range p.Packetize(s.opts.Frames[i%len(s.opts.Frames)], step) {
+			b, err := pkt.Marshal()
+			if err != nil {
+				return
+			}
+			if err := ss.writeRTP(b); err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sdp describes the single H.264 track, including sprop-parameter-sets
+// taken from the first access unit that carries them
+func (s *Server) sdp() string {
+	var sprop []string
+	for _, au := range s.opts.Frames {
+		for _, n := range splitAnnexB(au) {
+			if t := n[0] & 0x1F; t == 7 || t == 8 {
+				sprop = append(sprop, base64.StdEncoding.EncodeToString(n))
+			}
+		}
+		if len(sprop) > 0 {
+			break
+		}
+	}
+
+	fmtp := "packetization-mode=1"
+	if len(sprop) > 0 {
+		fmtp += ";sprop-parameter-sets=" + strings.Join(sprop, ",")
+	}
+
+	return strings.Join([]string{
+		"v=0",
+		"o=- 0 0 IN IP4 127.0.0.1",
+		"s=rtsptest",
+		"t=0 0",
+		fmt.Sprintf("m=video 0 RTP/AVP %d", payloadType),
+		fmt.Sprintf("a=rtpmap:%d H264/90000", payloadType),
+		fmt.Sprintf("a=fmtp:%d %s", payloadType, fmtp),
+		"a=control:trackID=0",
+		"",
+	}, "\r\n")
+}
+
+func randUint64() uint64 {
+	var b [8]byte
+	rand.Read(b[:])
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// selfSigned creates a short-lived certificate for 127.0.0.1
+func selfSigned() (*x509.Certificate, tls.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(fmt.Sprintf("rtsptest: generate key: %v", err))
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: new(big.Int).SetUint64(randUint64()),
+		Subject:      pkix.Name{CommonName: "rtsptest"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		panic(fmt.Sprintf("rtsptest: create certificate: %v", err))
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(fmt.Sprintf("rtsptest: parse certificate: %v", err))
+	}
+	return cert, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}
+}
@@ -0,0 +1,97 @@
+package rtsptest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// serveTunnel serves one half of an RTSP-over-HTTP tunnel. A GET is
+// answered and held open for responses and RTP; the POST with the same
+// x-sessioncookie then carries the base64-encoded requests of a session run
+// across the pair.
+func (s *Server) serveTunnel(conn net.Conn, r *bufio.Reader) {
+	conn.SetReadDeadline(time.Now().Add(s.opts.SessionTimeout))
+	req, err := http.ReadRequest(r)
+	if err != nil {
+		return
+	}
+	cookie := req.Header.Get("x-sessioncookie")
+	if cookie == "" {
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		s.tunnels[cookie] = conn
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			delete(s.tunnels, cookie)
+			s.mu.Unlock()
+		}()
+
+		_, err := conn.Write([]byte("HTTP/1.0 200 OK\r\nContent-Type: application/x-rtsp-tunnelled\r\n" +
+			"Cache-Control: no-cache\r\nPragma: no-cache\r\n\r\n"))
+		if err != nil {
+			return
+		}
+		// Nothing more arrives on the GET; hold it until either end closes it
+		conn.SetReadDeadline(time.Time{})
+		io.Copy(io.Discard, r)
+
+	case http.MethodPost:
+		s.mu.Lock()
+		get := s.tunnels[cookie]
+		delete(s.tunnels, cookie)
+		s.mu.Unlock()
+		if get == nil {
+			return
+		}
+		defer get.Close()
+
+		body := bufio.NewReader(&base64Reader{r: r})
+		s.serveRTSP(&tunnelConn{Conn: get, post: conn, r: body}, body)
+	}
+}
+
+// tunnelConn is the server end of a tunnel: requests are read from the
+// decoded POST, and responses and RTP written to the GET
+type tunnelConn struct {
+	net.Conn // The GET
+	post     net.Conn
+	r        io.Reader
+}
+
+func (t *tunnelConn) Read(b []byte) (int, error) { return t.r.Read(b) }
+
+func (t *tunnelConn) SetReadDeadline(d time.Time) error { return t.post.SetReadDeadline(d) }
+
+// base64Reader decodes a POST body one 4-character quantum at a time, as
+// clients encode and pad each write on its own
+type base64Reader struct {
+	r       io.Reader
+	decoded []byte // Not yet read
+}
+
+func (b *base64Reader) Read(p []byte) (int, error) {
+	for len(b.decoded) == 0 {
+		var quantum [4]byte
+		if _, err := io.ReadFull(b.r, quantum[:]); err != nil {
+			return 0, err
+		}
+		out := make([]byte, 3)
+		n, err := base64.StdEncoding.Decode(out, quantum[:])
+		if err != nil {
+			return 0, err
+		}
+		b.decoded = out[:n]
+	}
+	n := copy(p, b.decoded)
+	b.decoded = b.decoded[n:]
+	return n, nil
+}
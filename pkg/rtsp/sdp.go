@@ -0,0 +1,172 @@
+package rtsp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pion/sdp/v3"
+)
+
+// staticPayloadTypes are the RFC 3551 payload types cameras send without an
+// a=rtpmap line
+var staticPayloadTypes = map[uint8]struct {
+	codec     string
+	clockRate int
+}{
+	0:  {"PCMU", 8000},
+	8:  {"PCMA", 8000},
+	26: {"JPEG", 90000},
+	33: {"MP2T", 90000},
+}
+
+// sessionLineOrder is the order RFC 4566 requires of session-level lines
+const sessionLineOrder = "vosiuepcbtrzka"
+
+// parseSDP reads the DESCRIBE SDP into Channels: one RTP/RTCP channel pair
+// per audio or video media description, in SDP order
+func (c *Client) parseSDP(raw string) error {
+	desc, err := unmarshalSDP(raw)
+	if err != nil {
+		return err
+	}
+
+	var channelID byte
+	for _, md := range desc.MediaDescriptions {
+		media := md.MediaName.Media
+		if media != "video" && media != "audio" {
+			c.logger.Debug("skipping media", "type", media)
+			continue
+		}
+		if len(md.MediaName.Formats) == 0 {
+			continue
+		}
+		pt, err := strconv.ParseUint(md.MediaName.Formats[0], 10, 7)
+		if err != nil {
+			c.logger.Warn("skipping media with invalid payload type", "type", media, "format", md.MediaName.Formats[0])
+			continue
+		}
+
+		ch := &Channel{ID: channelID, MediaType: media, PayloadType: uint8(pt)}
+		ch.Control, _ = md.Attribute("control")
+		if static, ok := staticPayloadTypes[ch.PayloadType]; ok {
+			ch.Codec, ch.ClockRate = static.codec, static.clockRate
+		}
+
+		// rtpmap and fmtp lines name their payload type; others are ignored
+		for _, a := range md.Attributes {
+			value, ok := strings.CutPrefix(a.Value, md.MediaName.Formats[0]+" ")
+			if !ok {
+				continue
+			}
+			switch a.Key {
+			case "rtpmap":
+				// e.g. "MPEG4-GENERIC/48000/2"
+				parts := strings.Split(strings.TrimSpace(value), "/")
+				ch.Codec = strings.ToUpper(parts[0])
+				if len(parts) > 1 {
+					ch.ClockRate, _ = strconv.Atoi(parts[1])
+				}
+				if len(parts) > 2 {
+					ch.Channels, _ = strconv.Atoi(parts[2])
+				}
+			case "fmtp":
+				ch.Fmtp = strings.TrimSpace(value)
+			}
+		}
+
+		if sprop := ch.FmtpParam("sprop-parameter-sets"); sprop != "" {
+			sets, err := decodeParameterSets(sprop)
+			if err != nil {
+				c.logger.Warn("ignoring malformed sprop-parameter-sets", "error", err)
+			}
+			ch.ParameterSets = sets
+		}
+
+		c.Channels[channelID] = ch
+		channelID += 2 // RTP on even, RTCP on odd
+	}
+
+	c.logger.Info("parsed SDP", "channels", len(c.Channels))
+	for id, ch := range c.Channels {
+		c.logger.Debug("media track",
+			"channel", id,
+			"type", ch.MediaType,
+			"payload_type", ch.PayloadType,
+			"codec", ch.Codec,
+			"clock_rate", ch.ClockRate,
+			"control", ch.Control)
+	}
+	return nil
+}
+
+// unmarshalSDP parses an SDP. Cameras often order session-level lines
+// loosely or leave out t=, which RFC 4566 parsers reject; those are put in
+// order before a second attempt.
+func unmarshalSDP(raw string) (*sdp.SessionDescription, error) {
+	var desc sdp.SessionDescription
+	err := desc.Unmarshal([]byte(raw))
+	if err == nil {
+		return &desc, nil
+	}
+	desc = sdp.SessionDescription{}
+	if desc.Unmarshal([]byte(normalizeSDP(raw))) != nil {
+		return nil, err
+	}
+	return &desc, nil
+}
+
+// normalizeSDP sorts the session-level lines into RFC 4566 order, adding
+// "t=0 0" if there is none, and drops blank lines
+func normalizeSDP(raw string) string {
+	var session, media []string
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case len(line) < 2 || line[1] != '=':
+			continue
+		case strings.HasPrefix(line, "m=") || len(media) > 0:
+			media = append(media, line)
+		default:
+			session = append(session, line)
+		}
+	}
+
+	hasTiming := false
+	for _, line := range session {
+		hasTiming = hasTiming || line[0] == 't'
+	}
+	if !hasTiming {
+		session = append(session, "t=0 0")
+	}
+	rank := func(line string) int {
+		if i := strings.IndexByte(sessionLineOrder, line[0]); i >= 0 {
+			return i
+		}
+		return len(sessionLineOrder)
+	}
+	sort.SliceStable(session, func(i, j int) bool { return rank(session[i]) < rank(session[j]) })
+
+	return strings.Join(append(session, media...), "\r\n") + "\r\n"
+}
+
+// decodeParameterSets decodes a comma-separated list of base64 NAL units,
+// as in sprop-parameter-sets (RFC 6184 section 8.1)
+func decodeParameterSets(sprop string) ([][]byte, error) {
+	var sets [][]byte
+	for _, s := range strings.Split(sprop, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		nalu, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("decode %q: %w", s, err)
+		}
+		if len(nalu) > 0 {
+			sets = append(sets, nalu)
+		}
+	}
+	return sets, nil
+}
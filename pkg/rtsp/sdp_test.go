@@ -0,0 +1,50 @@
+package rtsp
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestParseSDP(t *testing.T) {
+	// Session attributes before s=, no t= line, LF endings, a data track,
+	// several formats per m-line and attributes in no particular order
+	const raw = "v=0\n" +
+		"o=- 0 0 IN IP4 127.0.0.1\n" +
+		"a=control:*\n" +
+		"s=Camera\n" +
+		"a=range:npt=0-\n" +
+		"m=video 0 RTP/AVP 102 96\n" +
+		"a=framerate:15\n" +
+		"a=rtpmap:96 H265/90000\n" +
+		"a=fmtp:96 profile-id=1\n" +
+		"a=fmtp:102 packetization-mode=1;sprop-parameter-sets=Z0IAH5WoFAFuQA==,aM48gA==\n" +
+		"a=rtpmap:102 h264/90000\n" +
+		"a=control:trackID=1\n" +
+		"m=application 0 RTP/AVP 107\n" +
+		"a=rtpmap:107 vnd.onvif.metadata/90000\n" +
+		"a=control:trackID=2\n" +
+		"m=audio 0 RTP/AVP 0\n" +
+		"a=control:trackID=3\n"
+
+	c := NewClient("rtsp://camera/stream", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := c.parseSDP(raw); err != nil {
+		t.Fatalf("parseSDP: %v", err)
+	}
+	if len(c.Channels) != 2 {
+		t.Fatalf("got %d channels, want video and audio", len(c.Channels))
+	}
+
+	video := c.Channels[0]
+	if video.MediaType != "video" || video.PayloadType != 102 || video.Codec != "H264" || video.ClockRate != 90000 || video.Control != "trackID=1" {
+		t.Errorf("video %+v", video)
+	}
+	if video.FmtpParam("packetization-mode") != "1" || len(video.ParameterSets) != 2 {
+		t.Errorf("video fmtp %q, %d parameter sets", video.Fmtp, len(video.ParameterSets))
+	}
+
+	audio := c.Channels[2]
+	if audio.MediaType != "audio" || audio.PayloadType != 0 || audio.Codec != "PCMU" || audio.ClockRate != 8000 || audio.Control != "trackID=3" {
+		t.Errorf("audio %+v", audio)
+	}
+}
@@ -0,0 +1,94 @@
+package rtsp
+
+import "time"
+
+// rateWindow is the span Bitrate is measured over
+const rateWindow = time.Second
+
+// ChannelStats is one track's reception as the client saw it: what the
+// camera delivered before anything downstream touched it
+type ChannelStats struct {
+	MediaType string        // "video" or "audio"
+	Packets   uint64        // RTP packets received
+	Bytes     uint64        // RTP bytes received, headers included
+	Bitrate   float64       // Bits per second over the last second
+	Jitter    time.Duration // RFC 3550 interarrival jitter
+	Lost      int64         // Packets missing from the sequence since the camera's stream (re)started
+	Gaps      uint64        // Times the sequence number skipped ahead
+}
+
+// ClientStats is the camera side of a relay, so stream problems can be told
+// apart from the SFU side
+type ClientStats struct {
+	Channels     map[byte]ChannelStats // By RTP channel
+	ReadTimeouts uint64                // ReadTimeout periods that passed without RTP
+}
+
+// Stats returns the reception statistics of each track and the read
+// timeouts so far. Safe to call while ReadPackets runs.
+func (c *Client) Stats() ClientStats {
+	c.receiversMu.Lock()
+	defer c.receiversMu.Unlock()
+
+	stats := ClientStats{
+		Channels:     make(map[byte]ChannelStats, len(c.receivers)),
+		ReadTimeouts: c.readTimeouts.Load(),
+	}
+	now := time.Now()
+	for channel, r := range c.receivers {
+		stats.Channels[channel] = r.stats(now)
+	}
+	return stats
+}
+
+// stats returns the track's counters at now
+func (r *rtpReceiver) stats(now time.Time) ChannelStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var lost int64
+	if r.started {
+		expected := r.cycles + uint32(r.maxSeq) - r.baseSeq + 1
+		lost = max(int64(expected)-int64(r.received), 0)
+	}
+	return ChannelStats{
+		MediaType: r.mediaType,
+		Packets:   r.packets,
+		Bytes:     r.bytes,
+		Bitrate:   r.rate.bitrate(now),
+		Jitter:    time.Duration(r.jitter / r.clockRate * float64(time.Second)),
+		Lost:      lost,
+		Gaps:      r.gaps,
+	}
+}
+
+// rateMeter measures a bitrate over consecutive windows of rateWindow
+type rateMeter struct {
+	start time.Time // Of the current window
+	bytes uint64    // In the current window
+	bps   float64   // Of the last complete window
+}
+
+// add counts bytes that arrived at now, closing the window once it is full
+func (m *rateMeter) add(bytes uint64, now time.Time) {
+	if m.start.IsZero() {
+		m.start = now
+	}
+	m.bytes += bytes
+	if elapsed := now.Sub(m.start); elapsed >= rateWindow {
+		m.bps = float64(m.bytes*8) / elapsed.Seconds()
+		m.start, m.bytes = now, 0
+	}
+}
+
+// bitrate is the last complete window's rate, or the current window's once
+// it has run on for longer, so a stream that stops reads as slowing down
+func (m *rateMeter) bitrate(now time.Time) float64 {
+	if m.start.IsZero() {
+		return 0
+	}
+	if elapsed := now.Sub(m.start); elapsed > 2*rateWindow {
+		return float64(m.bytes*8) / elapsed.Seconds()
+	}
+	return m.bps
+}
@@ -0,0 +1,92 @@
+package rtsp
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtsp/rtsptest"
+	"github.com/pion/rtp"
+)
+
+func TestReceiverStats(t *testing.T) {
+	r := newRTPReceiver(90000)
+	r.mediaType = "video"
+	start := time.Now()
+
+	// 100-byte packets, 10 per 100ms; 3 and 4 are lost
+	var now time.Time
+	for seq := uint16(0); seq < 20; seq++ {
+		if seq == 3 || seq == 4 {
+			continue
+		}
+		now = start.Add(time.Duration(seq) * 10 * time.Millisecond)
+		r.packet(&rtp.Packet{
+			Header:  rtp.Header{SSRC: 7, SequenceNumber: seq, Timestamp: uint32(seq) * 900},
+			Payload: make([]byte, 88),
+		}, now)
+	}
+
+	s := r.stats(now)
+	if s.MediaType != "video" || s.Packets != 18 || s.Bytes != 18*100 || s.Lost != 2 || s.Gaps != 1 {
+		t.Errorf("stats %+v", s)
+	}
+	if s.Jitter != 0 {
+		t.Errorf("jitter %v for perfectly spaced packets", s.Jitter)
+	}
+	if s.Bitrate != 0 {
+		t.Errorf("bitrate %v before a full window", s.Bitrate)
+	}
+
+	// A second on, the first window closes at 100 packets/s of 800 bits
+	for seq := uint16(20); seq <= 100; seq++ {
+		now = start.Add(time.Duration(seq) * 10 * time.Millisecond)
+		r.packet(&rtp.Packet{Header: rtp.Header{SSRC: 7, SequenceNumber: seq}, Payload: make([]byte, 88)}, now)
+	}
+	if s = r.stats(now); s.Bitrate < 75000 || s.Bitrate > 85000 {
+		t.Errorf("bitrate %v, want about 80 kbit/s", s.Bitrate)
+	}
+
+	// A stream that stops reads as slowing down
+	if s = r.stats(now.Add(10 * time.Second)); s.Bitrate != 0 {
+		t.Errorf("bitrate %v after the stream stopped", s.Bitrate)
+	}
+}
+
+func TestClientStats(t *testing.T) {
+	srv := rtsptest.NewServer(rtsptest.Options{FPS: 30})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c := NewClient(srv.URL, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer c.Close()
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if err := c.SetupTracks(ctx); err != nil {
+		t.Fatalf("SetupTracks: %v", err)
+	}
+	if err := c.Play(ctx); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+	go c.ReadPackets(ctx)
+
+	for {
+		s := c.Stats()
+		if ch := s.Channels[0]; ch.Packets >= 10 {
+			if ch.MediaType != "video" || ch.Bytes == 0 || ch.Lost != 0 || s.ReadTimeouts != 0 {
+				t.Errorf("stats %+v", s)
+			}
+			return
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("stats never counted packets: %+v", s)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package rtsp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSOptions configure rtsps:// connections. The zero value verifies the
+// server's certificate against the system roots for the URL's host, as Nest
+// streams need.
+type TLSOptions struct {
+	// RootCAs are the trusted roots, e.g. a local camera's or NVR's own CA;
+	// nil uses the system pool
+	RootCAs *x509.CertPool
+
+	// ServerName is sent as SNI and verified against the certificate, for
+	// cameras reached by IP whose certificate names a host; empty uses the
+	// URL's host
+	ServerName string
+
+	// InsecureSkipVerify accepts any certificate. Only for lab cameras with
+	// self-signed certificates: it leaves the stream open to interception.
+	InsecureSkipVerify bool
+
+	// MinVersion is the oldest TLS version accepted, e.g. tls.VersionTLS13;
+	// zero is crypto/tls's default of TLS 1.2
+	MinVersion uint16
+}
+
+// config builds the tls.Config for a connection to host
+func (o TLSOptions) config(host string) *tls.Config {
+	serverName := o.ServerName
+	if serverName == "" {
+		serverName = host
+	}
+	return &tls.Config{
+		RootCAs:            o.RootCAs,
+		ServerName:         serverName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+		MinVersion:         o.MinVersion,
+	}
+}
+
+// LoadRootCAs reads the PEM certificates in path into a pool for
+// TLSOptions.RootCAs
+func LoadRootCAs(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates in %s", path)
+	}
+	return pool, nil
+}
+
+// ParseTLSVersion parses "1.0", "1.1", "1.2" or "1.3"; empty is zero, the
+// crypto/tls default
+func ParseTLSVersion(s string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "tls") {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version %q (want 1.0, 1.1, 1.2 or 1.3)", s)
+}
@@ -0,0 +1,80 @@
+package rtsp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtsp/rtsptest"
+)
+
+func TestTLSOptions(t *testing.T) {
+	srv := rtsptest.NewTLSServer(rtsptest.Options{})
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, tc := range []struct {
+		name string
+		tls  TLSOptions
+		ok   bool
+	}{
+		{"system roots", TLSOptions{}, false},
+		{"custom root", TLSOptions{RootCAs: roots}, true},
+		{"server name mismatch", TLSOptions{RootCAs: roots, ServerName: "camera.local"}, false},
+		{"insecure", TLSOptions{InsecureSkipVerify: true}, true},
+		{"TLS 1.3", TLSOptions{RootCAs: roots, MinVersion: tls.VersionTLS13}, true},
+	} {
+		_, err := ProbeStream(ctx, srv.URL, ClientOptions{TLS: tc.tls}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: err = %v", tc.name, err)
+		}
+	}
+
+	for in, want := range map[string]uint16{"": 0, "1.2": tls.VersionTLS12, "TLS1.3": tls.VersionTLS13} {
+		if got, err := ParseTLSVersion(in); err != nil || got != want {
+			t.Errorf("ParseTLSVersion(%q) = %x, %v", in, got, err)
+		}
+	}
+	if _, err := ParseTLSVersion("1.4"); err == nil {
+		t.Error("ParseTLSVersion accepted 1.4")
+	}
+}
+
+func TestDialOption(t *testing.T) {
+	srv := rtsptest.NewTLSServer(rtsptest.Options{})
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// TLS runs over whatever Dial returns, e.g. a proxy's tunnel
+	var dialed []string
+	var d net.Dialer
+	opts := ClientOptions{
+		TLS: TLSOptions{RootCAs: roots},
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialed = append(dialed, addr)
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	if _, err := ProbeStream(ctx, srv.URL, opts, slog.New(slog.NewTextHandler(io.Discard, nil))); err != nil {
+		t.Fatalf("ProbeStream: %v", err)
+	}
+	if u, _ := url.Parse(srv.URL); len(dialed) != 1 || dialed[0] != u.Host {
+		t.Errorf("dialed %v, want %s", dialed, u.Host)
+	}
+}
@@ -0,0 +1,128 @@
+package rtsp
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// tunnelContentType marks both halves of an RTSP-over-HTTP tunnel
+const tunnelContentType = "application/x-rtsp-tunnelled"
+
+// tunnelConn is an RTSP connection tunnelled through HTTP, as QuickTime
+// does it: a GET carries the server's responses and interleaved RTP back,
+// and a POST carries requests out, base64-encoded. The server pairs the two
+// by their x-sessioncookie.
+type tunnelConn struct {
+	get  net.Conn
+	r    *bufio.Reader // The GET's response body
+	post net.Conn
+}
+
+// dialTunnel opens both halves of a tunnel to the server dial connects to,
+// for the stream at u
+func (c *Client) dialTunnel(dial func() (net.Conn, error), u *url.URL) (net.Conn, error) {
+	cookie := make([]byte, 16)
+	if _, err := rand.Read(cookie); err != nil {
+		return nil, err
+	}
+	header := fmt.Sprintf("Host: %s\r\nx-sessioncookie: %s\r\nPragma: no-cache\r\nCache-Control: no-cache\r\n",
+		u.Host, hex.EncodeToString(cookie))
+
+	get, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.openTunnelGet(get, u, header)
+	if err != nil {
+		get.Close()
+		return nil, fmt.Errorf("HTTP tunnel GET: %w", err)
+	}
+
+	post, err := dial()
+	if err != nil {
+		get.Close()
+		return nil, err
+	}
+	// The POST is never answered; its body is every request to come, so
+	// the declared length is only a large placeholder
+	req := fmt.Sprintf("POST %s HTTP/1.0\r\n%sContent-Type: %s\r\nContent-Length: 32767\r\nExpires: Sun, 9 Jan 1972 00:00:00 GMT\r\n\r\n",
+		u.RequestURI(), header, tunnelContentType)
+	if err := writeWithDeadline(post, []byte(req), c.opts.WriteTimeout); err != nil {
+		get.Close()
+		post.Close()
+		return nil, fmt.Errorf("HTTP tunnel POST: %w", err)
+	}
+
+	c.logger.Info("RTSP tunnelled over HTTP", "path", u.Path)
+	return &tunnelConn{get: get, r: r, post: post}, nil
+}
+
+// openTunnelGet sends the tunnel's GET and reads the server's answer,
+// returning a reader positioned at the start of the RTSP stream
+func (c *Client) openTunnelGet(conn net.Conn, u *url.URL, header string) (*bufio.Reader, error) {
+	req := fmt.Sprintf("GET %s HTTP/1.0\r\n%sAccept: %s\r\n\r\n", u.RequestURI(), header, tunnelContentType)
+	if err := writeWithDeadline(conn, []byte(req), c.opts.WriteTimeout); err != nil {
+		return nil, err
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(c.opts.ResponseTimeout)); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server answered %s", resp.Status)
+	}
+	// The body runs until the tunnel closes, so it is read from r directly
+	// rather than through resp.Body
+	return r, conn.SetReadDeadline(time.Time{})
+}
+
+// writeWithDeadline writes b to conn within timeout
+func writeWithDeadline(conn net.Conn, b []byte, timeout time.Duration) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	_, err := conn.Write(b)
+	return err
+}
+
+// Read reads the server's RTSP stream from the GET
+func (t *tunnelConn) Read(b []byte) (int, error) {
+	return t.r.Read(b)
+}
+
+// Write sends b base64-encoded on the POST. Each write is encoded on its
+// own, padding included, as servers decode what arrives as it arrives.
+func (t *tunnelConn) Write(b []byte) (int, error) {
+	if _, err := t.post.Write([]byte(base64.StdEncoding.EncodeToString(b))); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close closes both halves of the tunnel
+func (t *tunnelConn) Close() error {
+	return errors.Join(t.post.Close(), t.get.Close())
+}
+
+func (t *tunnelConn) LocalAddr() net.Addr  { return t.get.LocalAddr() }
+func (t *tunnelConn) RemoteAddr() net.Addr { return t.get.RemoteAddr() }
+
+func (t *tunnelConn) SetDeadline(d time.Time) error {
+	return errors.Join(t.get.SetDeadline(d), t.post.SetDeadline(d))
+}
+
+func (t *tunnelConn) SetReadDeadline(d time.Time) error  { return t.get.SetReadDeadline(d) }
+func (t *tunnelConn) SetWriteDeadline(d time.Time) error { return t.post.SetWriteDeadline(d) }
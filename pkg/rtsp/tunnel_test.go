@@ -0,0 +1,64 @@
+package rtsp
+
+import (
+	"context"
+	"crypto/x509"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtsp/rtsptest"
+	pionRTP "github.com/pion/rtp"
+)
+
+func TestClientHTTPTunnel(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		srv  *rtsptest.Server
+	}{
+		{"http", rtsptest.NewServer(rtsptest.Options{FPS: 30, HTTPTunnel: true})},
+		{"https", rtsptest.NewTLSServer(rtsptest.Options{FPS: 30, HTTPTunnel: true})},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := tc.srv
+			defer srv.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			c := NewClient(srv.URL, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			defer c.Close()
+			c.SetTransport(TransportHTTP)
+			if cert := srv.Certificate(); cert != nil {
+				roots := x509.NewCertPool()
+				roots.AddCert(cert)
+				c.SetOptions(ClientOptions{TLS: TLSOptions{RootCAs: roots}})
+			}
+			c.rtcpInterval = 50 * time.Millisecond
+
+			if err := c.Connect(ctx); err != nil {
+				t.Fatalf("Connect: %v", err)
+			}
+			if err := c.SetupTracks(ctx); err != nil {
+				t.Fatalf("SetupTracks: %v", err)
+			}
+			if err := c.Play(ctx); err != nil {
+				t.Fatalf("Play: %v", err)
+			}
+			var packets atomic.Int64
+			c.OnRTPPacket = func(_ byte, _ *pionRTP.Packet) { packets.Add(1) }
+			go c.ReadPackets(ctx)
+
+			// RTP comes back on the GET; receiver reports go out on the POST
+			for ctx.Err() == nil {
+				if packets.Load() > 10 && len(srv.ReceiverReports()) > 0 {
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			t.Fatalf("%d packets and %d receiver reports through the tunnel", packets.Load(), len(srv.ReceiverReports()))
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package rtsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+)
+
+// Transport selects how RTP reaches the client once a track is set up
+type Transport string
+
+const (
+	// TransportTCP interleaves RTP in the RTSP connection. The default, and
+	// the only transport Nest accepts.
+	TransportTCP Transport = "tcp"
+
+	// TransportUDP receives RTP and RTCP as datagrams on a port pair per
+	// track. Often smoother for cameras on the local network, where a lost
+	// packet costs less than TCP's head-of-line blocking.
+	TransportUDP Transport = "udp"
+
+	// TransportHTTP interleaves RTP as TCP does, in an RTSP connection
+	// tunnelled through HTTP (HTTPS for rtsps:// URLs), for networks that
+	// only let web traffic out. Without a port in the URL the tunnel goes
+	// to 80, or 443 for rtsps.
+	TransportHTTP Transport = "http"
+)
+
+const (
+	// udpQueueSize is the datagrams buffered between the socket readers and
+	// ReadPackets; past it packets are dropped, as the network would
+	udpQueueSize = 1024
+
+	// maxDatagram is the largest UDP payload
+	maxDatagram = 65535
+)
+
+// ParseTransport parses "tcp", "udp" or "http"; empty is TCP
+func ParseTransport(s string) (Transport, error) {
+	switch t := Transport(strings.ToLower(strings.TrimSpace(s))); t {
+	case "", TransportTCP:
+		return TransportTCP, nil
+	case TransportUDP, TransportHTTP:
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown RTSP transport %q (want tcp, udp or http)", s)
+}
+
+// SetTransport selects interleaved TCP (the default), UDP delivery, or the
+// HTTP tunnel. Call before SetupTracks, or before Connect for the tunnel.
+func (c *Client) SetTransport(t Transport) {
+	if t == "" {
+		t = TransportTCP
+	}
+	c.transport = t
+}
+
+// udpTrack is one track's RTP and RTCP sockets
+type udpTrack struct {
+	channel byte // Channel ID the track is reported on, as over TCP
+	rtp     *net.UDPConn
+	rtcp    *net.UDPConn
+	server  net.IP       // Datagrams from other hosts are ignored
+	rtcpTo  *net.UDPAddr // Server's RTCP port from the SETUP response, for receiver reports; nil if it gave none
+}
+
+// udpPacket is an RTP packet read from a track's socket
+type udpPacket struct {
+	channel byte
+	buf     *packetBuffer // Holds the packet until the read loop handled it
+}
+
+// listenUDPPair binds an even RTP port and the odd RTCP port above it, as
+// RFC 3550 expects
+func listenUDPPair() (*net.UDPConn, *net.UDPConn, error) {
+	for range 20 {
+		rtpConn, err := net.ListenUDP("udp", &net.UDPAddr{})
+		if err != nil {
+			return nil, nil, fmt.Errorf("listen RTP: %w", err)
+		}
+		port := rtpConn.LocalAddr().(*net.UDPAddr).Port
+		if port%2 != 0 {
+			rtpConn.Close()
+			continue
+		}
+		rtcpConn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port + 1})
+		if err != nil {
+			rtpConn.Close()
+			continue // Odd port taken; try another pair
+		}
+		return rtpConn, rtcpConn, nil
+	}
+	return nil, nil, errors.New("no free UDP port pair")
+}
+
+// setupUDP opens a track's sockets and returns the Transport header
+// offering them
+func (c *Client) setupUDP(channelID byte) (string, error) {
+	rtpConn, rtcpConn, err := listenUDPPair()
+	if err != nil {
+		return "", err
+	}
+	if c.udp == nil {
+		c.udp = make(map[byte]*udpTrack)
+	}
+	c.udp[channelID] = &udpTrack{channel: channelID, rtp: rtpConn, rtcp: rtcpConn, server: c.serverIP()}
+
+	port := rtpConn.LocalAddr().(*net.UDPAddr).Port
+	return fmt.Sprintf("RTP/AVP;unicast;client_port=%d-%d", port, port+1), nil
+}
+
+// confirmUDP checks the server accepted UDP for a track, and notes where it
+// sends from and where its RTCP port is
+func (c *Client) confirmUDP(channelID byte, transport string) error {
+	t := c.udp[channelID]
+	if strings.Contains(transport, "interleaved") || strings.Contains(transport, "/TCP") {
+		return fmt.Errorf("server answered UDP SETUP with %q", transport)
+	}
+	if source, ok := transportValue(transport, "source"); ok {
+		if ip := net.ParseIP(source); ip != nil {
+			t.server = ip
+		}
+	}
+	if _, rtcpPort, ok := transportRange(transport, "server_port"); ok {
+		t.rtcpTo = &net.UDPAddr{IP: t.server, Port: rtcpPort}
+	}
+	return nil
+}
+
+// serverIP is the RTSP server's address, where RTP is expected from
+func (c *Client) serverIP() net.IP {
+	host, _, err := net.SplitHostPort(c.conn.RemoteAddr().String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// readUDP is ReadPackets for UDP tracks. Datagrams are handed to
+// OnRTPPacket from this goroutine alone, as they are over TCP, while the
+// RTSP connection carries only responses to PLAY and keepalives.
+func (c *Client) readUDP(ctx context.Context) error {
+	packets := make(chan udpPacket, udpQueueSize)
+	for _, t := range c.udp {
+		goroutines.Go("rtsp.udp", "", func() { c.readRTP(t, packets) })
+		goroutines.Go("rtsp.udp", "", func() { c.readRTCP(t) })
+	}
+	control := make(chan error, 1)
+	goroutines.Go("rtsp.control", "", func() { control <- c.readControl() })
+
+	// RTP packets should arrive frequently; warn when they stop
+	timeout := time.NewTimer(c.opts.ReadTimeout)
+	defer timeout.Stop()
+	packetCount, timeoutCount := 0, 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case err := <-control:
+			if err == nil {
+				c.logger.Info("connection closed by server (EOF)", "packets_received", packetCount)
+			}
+			return err
+
+		case p := <-packets:
+			if c.OnRTPPacket != nil {
+				c.OnRTPPacket(p.channel, &p.buf.packet)
+			}
+			putPacketBuffer(p.buf)
+			packetCount++
+			if packetCount == 1 {
+				c.logger.Info("received first RTP packet successfully", "transport", TransportUDP)
+			}
+			if packetCount%1000 == 0 {
+				c.logger.Info("packets received", "count", packetCount)
+			}
+			timeout.Reset(c.opts.ReadTimeout)
+
+		case <-timeout.C:
+			timeoutCount++
+			c.readTimeouts.Add(1)
+			if timeoutCount%6 == 1 { // Log every minute
+				c.logger.Warn("read timeout - no RTP datagrams from RTSP server",
+					"timeout_count", timeoutCount,
+					"packets_received", packetCount)
+			}
+			timeout.Reset(c.opts.ReadTimeout)
+		}
+	}
+}
+
+// readRTP reads a track's RTP socket until Close. Packets the read loop
+// has no room for are dropped rather than blocking the socket.
+func (c *Client) readRTP(t *udpTrack, packets chan<- udpPacket) {
+	buf := make([]byte, maxDatagram)
+	var dropped uint64
+	for {
+		n, addr, err := t.rtp.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if !addr.IP.Equal(t.server) {
+			continue
+		}
+
+		pb := getPacketBuffer(n)
+		copy(pb.data, buf[:n])
+		if err := pb.packet.Unmarshal(pb.data); err != nil {
+			putPacketBuffer(pb)
+			c.logger.Warn("failed to unmarshal RTP packet",
+				"channel", t.channel,
+				"size", n,
+				"error", err)
+			continue
+		}
+		c.recordRTP(t.channel, &pb.packet)
+		select {
+		case packets <- udpPacket{channel: t.channel, buf: pb}:
+		default:
+			putPacketBuffer(pb)
+			dropped++
+			if dropped == 1 || dropped%100 == 0 {
+				c.logger.Warn("RTP datagram queue full, dropping packet",
+					"channel", t.channel,
+					"dropped", dropped)
+			}
+		}
+	}
+}
+
+// readRTCP reads a track's RTCP socket until Close
+func (c *Client) readRTCP(t *udpTrack) {
+	buf := make([]byte, maxDatagram)
+	for {
+		n, addr, err := t.rtcp.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if !addr.IP.Equal(t.server) {
+			continue
+		}
+		c.handleRTCP(t.channel+1, buf[:n])
+	}
+}
+
+// readControl reads RTSP responses from the connection until it closes;
+// nil means the server closed it
+func (c *Client) readControl() error {
+	if err := c.conn.SetReadDeadline(time.Time{}); err != nil {
+		return fmt.Errorf("clear read deadline: %w", err)
+	}
+	playResponseReceived := false
+	for {
+		resp, err := c.readResponseNoDeadline()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("read RTSP response: %w", err)
+		}
+		if !playResponseReceived {
+			c.logger.Info("RTSP PLAY response received",
+				"status", resp.StatusCode,
+				"rtp_info", resp.Header["RTP-Info"],
+				"range", resp.Header["Range"])
+			playResponseReceived = true
+			continue
+		}
+		c.logger.Debug("RTSP response on control connection (likely keepalive)", "status", resp.StatusCode)
+	}
+}
+
+// closeUDP closes every track's sockets, ending their readers
+func (c *Client) closeUDP() {
+	for _, t := range c.udp {
+		t.rtp.Close()
+		t.rtcp.Close()
+	}
+}
+
+// transportValue returns a Transport header parameter, e.g. "source"
+func transportValue(transport, key string) (string, bool) {
+	for _, param := range strings.Split(transport, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if ok && strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// transportRange returns a Transport header range parameter such as
+// "server_port=6970-6971"; a single value implies value+1 as the upper end
+func transportRange(transport, key string) (int, int, bool) {
+	v, ok := transportValue(transport, key)
+	if !ok {
+		return 0, 0, false
+	}
+	a, b, hasHi := strings.Cut(v, "-")
+	lo, err := strconv.Atoi(a)
+	if err != nil {
+		return 0, 0, false
+	}
+	if !hasHi {
+		return lo, lo + 1, true
+	}
+	hi, err := strconv.Atoi(b)
+	if err != nil {
+		return 0, 0, false
+	}
+	return lo, hi, true
+}
@@ -0,0 +1,67 @@
+package rtspserver
+
+import "time"
+
+const (
+	videoPayloadType = 96
+	audioPayloadType = 97
+	maxPayload       = 1400            // Bytes per RTP payload; FU-A splits larger NAL units
+	maxTimestampGap  = 5 * time.Second // Larger jumps are treated as a new RTSP session
+)
+
+// packetizeH264 returns the RTP payloads for one NAL unit: the unit itself
+// when it fits, otherwise FU-A fragments (RFC 6184 5.8)
+func packetizeH264(nalu []byte) [][]byte {
+	if len(nalu) == 0 {
+		return nil
+	}
+	if len(nalu) <= maxPayload {
+		return [][]byte{nalu}
+	}
+
+	indicator := nalu[0]&0xE0 | 28 // F and NRI of the unit, type FU-A
+	naluType := nalu[0] & 0x1F
+	data := nalu[1:]
+
+	var payloads [][]byte
+	for first := true; len(data) > 0; first = false {
+		n := min(len(data), maxPayload-2)
+		header := naluType
+		if first {
+			header |= 0x80
+		}
+		if n == len(data) {
+			header |= 0x40
+		}
+		payloads = append(payloads, append([]byte{indicator, header}, data[:n]...))
+		data = data[n:]
+	}
+	return payloads
+}
+
+// clock keeps a client's RTP timestamps continuous. The camera's timestamps
+// restart whenever its relay reconnects, while the client's session goes on;
+// jumps backwards or of more than maxTimestampGap continue from the last
+// timestamp sent instead.
+type clock struct {
+	started bool
+	offset  uint32
+	last    uint32
+}
+
+func (c *clock) timestamp(ts, clockRate uint32) uint32 {
+	if !c.started {
+		c.started = true
+		c.last = ts
+		return ts
+	}
+
+	out := ts + c.offset
+	if delta := int64(int32(out - c.last)); delta < 0 || delta > int64(maxTimestampGap.Seconds())*int64(clockRate) {
+		// Discontinuity: resume one nominal frame (20ms) after the last timestamp
+		out = c.last + clockRate/50
+		c.offset = out - ts
+	}
+	c.last = out
+	return out
+}
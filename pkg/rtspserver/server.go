@@ -0,0 +1,607 @@
+// Package rtspserver re-publishes relayed cameras on a built-in RTSP server,
+// so local NVRs (Frigate, Blue Iris) can read rtsp://relay:8554/<camera>
+// without opening another Nest stream.
+//
+// A Server is a relay.MediaInfoRecorder: it receives the H.264/AAC access
+// units each CameraRelay reads, re-packetizes them as RTP and sends them to
+// every client PLAYing that camera, interleaved on the RTSP connection. A
+// client's session outlives relay restarts; it simply waits for the next
+// frames. Slow clients drop frames rather than stall the relay.
+package rtspserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+	"github.com/ethan/nest-cloudflare-relay/pkg/internal/rtspmsg"
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtp"
+)
+
+const (
+	queueSize      = 256              // Frames buffered per client before dropping
+	sessionTimeout = 60 * time.Second // Clients must send a request (or RTCP) this often
+	writeTimeout   = 10 * time.Second // A client that can't take a write this long is dropped
+)
+
+// Config configures a Server
+type Config struct {
+	Addr     string // Listen address, e.g. ":8554"
+	Username string // Basic auth user; empty leaves the server open
+	Password string
+}
+
+// Server serves each camera at rtsp://<addr>/<camera ID>
+type Server struct {
+	cfg    Config
+	logger *slog.Logger
+
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu      sync.Mutex
+	cameras map[string]*camera
+	conns   map[net.Conn]struct{}
+	closed  bool
+}
+
+// camera is the latest media of one camera and the clients playing it
+type camera struct {
+	mu       sync.Mutex
+	info     relay.MediaInfo
+	hasInfo  bool
+	sps, pps []byte // From the latest keyframe, for the SDP
+	readers  map[*session]struct{}
+}
+
+// frame is one access unit shared by every reader; never modified
+type frame struct {
+	video     bool
+	data      []byte
+	timestamp uint32
+	keyframe  bool
+}
+
+// New creates a server; Start begins listening
+func New(cfg Config, logger *slog.Logger) *Server {
+	return &Server{
+		cfg:     cfg,
+		logger:  logger,
+		cameras: make(map[string]*camera),
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// Start listens on the configured address and serves clients until Close
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+
+	s.logger.Info("RTSP server listening", "addr", ln.Addr().String(), "auth", s.cfg.Username != "")
+
+	s.wg.Add(1)
+	go s.accept(ln)
+	return nil
+}
+
+// Addr returns the address the server listens on, or nil before Start
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Addr()
+}
+
+// Close stops listening, disconnects every client and waits for them
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	if s.ln != nil {
+		s.ln.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// RecordMediaInfo stores the camera's codecs for the SDPs of new clients
+func (s *Server) RecordMediaInfo(cameraID string, info relay.MediaInfo) {
+	cam := s.camera(cameraID)
+	cam.mu.Lock()
+	cam.info = info
+	cam.hasInfo = true
+	cam.mu.Unlock()
+}
+
+// RecordVideo sends an H.264 access unit to the camera's clients
+func (s *Server) RecordVideo(cameraID string, au []byte, timestamp uint32, keyframe bool) {
+	cam := s.camera(cameraID)
+	if keyframe {
+		if sps, pps := rtp.ParameterSets(au); sps != nil && pps != nil {
+			cam.mu.Lock()
+			cam.sps = append(cam.sps[:0:0], sps...)
+			cam.pps = append(cam.pps[:0:0], pps...)
+			cam.mu.Unlock()
+		}
+	}
+	cam.publish(frame{video: true, timestamp: timestamp, keyframe: keyframe}, au)
+}
+
+// RecordAudio sends an AAC access unit to the camera's clients
+func (s *Server) RecordAudio(cameraID string, data []byte, timestamp uint32) {
+	s.camera(cameraID).publish(frame{timestamp: timestamp}, data)
+}
+
+// camera returns the state for a camera, creating it on first use
+func (s *Server) camera(cameraID string) *camera {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cam := s.cameras[cameraID]
+	if cam == nil {
+		cam = &camera{readers: make(map[*session]struct{})}
+		s.cameras[cameraID] = cam
+	}
+	return cam
+}
+
+// lookup returns a camera's state, or nil if it never sent media
+func (s *Server) lookup(cameraID string) *camera {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cameras[cameraID]
+}
+
+// publish copies the payload once and queues it for every reader without
+// blocking
+func (c *camera) publish(f frame, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.readers) == 0 {
+		return
+	}
+
+	f.data = append([]byte(nil), data...)
+	for ss := range c.readers {
+		select {
+		case ss.frames <- f:
+		default:
+			if n := ss.dropped.Add(1); n%100 == 1 {
+				ss.logger.Warn("RTSP client queue full, dropping frames", "dropped", n)
+			}
+		}
+	}
+}
+
+// media returns what a DESCRIBE needs: the codecs and parameter sets
+func (c *camera) media() (relay.MediaInfo, []byte, []byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.info, c.sps, c.pps, c.hasInfo
+}
+
+func (c *camera) addReader(ss *session) {
+	c.mu.Lock()
+	c.readers[ss] = struct{}{}
+	c.mu.Unlock()
+}
+
+func (c *camera) removeReader(ss *session) {
+	c.mu.Lock()
+	delete(c.readers, ss)
+	c.mu.Unlock()
+}
+
+func (s *Server) accept(ln net.Listener) {
+	defer s.wg.Done()
+	defer goroutines.Track("rtspserver.accept", "")()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+// session is one client connection, which plays at most one camera
+type session struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+	id      string
+	logger  *slog.Logger
+
+	cameraID string
+	camera   *camera
+	info     relay.MediaInfo // Codecs described to the client
+	tracks   [2]*track       // Video, audio; nil until SETUP
+	playing  bool
+
+	frames  chan frame
+	dropped atomic.Uint64
+}
+
+// track is one SETUP track's outgoing RTP state
+type track struct {
+	channel   byte // Interleaved RTP channel; RTCP is channel+1
+	ssrc      uint32
+	seq       uint16
+	clockRate uint32
+	clock     clock
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer goroutines.Track("rtspserver.serve", "")()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ss := &session{
+		conn:   conn,
+		id:     strconv.FormatUint(uint64(randUint32())<<32|uint64(randUint32()), 16),
+		logger: s.logger.With("client", conn.RemoteAddr().String()),
+		frames: make(chan frame, queueSize),
+	}
+	var writer sync.WaitGroup
+	defer func() {
+		cancel()
+		conn.Close()
+		writer.Wait()
+		if ss.camera != nil {
+			ss.camera.removeReader(ss)
+		}
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		if ss.playing {
+			ss.logger.Info("RTSP client disconnected")
+		}
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(sessionTimeout))
+
+		b, err := r.Peek(1)
+		if err != nil {
+			return
+		}
+		if b[0] == '$' {
+			// Interleaved RTCP receiver reports; they only prove the client is alive
+			hdr := make([]byte, 4)
+			if _, err := io.ReadFull(r, hdr); err != nil {
+				return
+			}
+			if _, err := r.Discard(int(binary.BigEndian.Uint16(hdr[2:]))); err != nil {
+				return
+			}
+			continue
+		}
+
+		req, err := rtspmsg.ReadRequest(r)
+		if err != nil {
+			return
+		}
+
+		status, header, body := s.handle(ss, req)
+		if header == nil {
+			header = make(map[string]string)
+		}
+		header["Server"] = "camsrelay"
+		if err := ss.write(rtspmsg.Response(req, status, header, body)); err != nil {
+			return
+		}
+
+		switch {
+		case req.Method == "PLAY" && status == 200 && !ss.playing:
+			ss.playing = true
+			ss.camera.addReader(ss)
+			ss.logger.Info("RTSP client playing")
+			writer.Add(1)
+			goroutines.Go("rtspserver.stream", ss.cameraID, func() {
+				defer writer.Done()
+				if err := ss.stream(ctx); err != nil && ctx.Err() == nil {
+					ss.logger.Warn("RTSP client write failed", "error", err)
+					conn.Close()
+				}
+			})
+		case req.Method == "TEARDOWN":
+			return
+		}
+	}
+}
+
+// handle answers one request, returning status, headers and body
+func (s *Server) handle(ss *session, req rtspmsg.Request) (int, map[string]string, string) {
+	switch req.Method {
+	case "OPTIONS", "GET_PARAMETER":
+		return 200, map[string]string{"Public": "OPTIONS, DESCRIBE, SETUP, PLAY, GET_PARAMETER, TEARDOWN"}, ""
+
+	case "DESCRIBE":
+		// SETUP and PLAY only apply to the camera described on the same
+		// connection, so credentials are checked here
+		if ss.playing {
+			return 455, nil, ""
+		}
+		if !s.authorized(req) {
+			return 401, map[string]string{"WWW-Authenticate": `Basic realm="camsrelay"`}, ""
+		}
+		cameraID, _ := splitPath(req.URL)
+		cam := s.lookup(cameraID)
+		if cam == nil {
+			return 404, nil, ""
+		}
+		info, sps, pps, ok := cam.media()
+		if !ok {
+			return 503, nil, "" // Known camera, relay not started yet
+		}
+		if !info.AudioOnly && info.VideoCodec != "H264" {
+			return 415, nil, ""
+		}
+		ss.cameraID, ss.camera, ss.info = cameraID, cam, info
+		ss.logger = s.logger.With("client", ss.conn.RemoteAddr().String(), "camera_id", cameraID)
+		base := strings.TrimSuffix(req.URL, "/") + "/"
+		return 200, map[string]string{
+			"Content-Base": base,
+			"Content-Type": "application/sdp",
+		}, sessionSDP(info, sps, pps)
+
+	case "SETUP":
+		cameraID, control := splitPath(req.URL)
+		if ss.camera == nil || cameraID != ss.cameraID {
+			return 455, nil, "" // Method Not Valid in This State: DESCRIBE first
+		}
+		idx, ok := trackIndex(control)
+		if !ok || (idx == 0 && ss.info.AudioOnly) || (idx == 1 && !hasAudio(ss.info)) {
+			return 404, nil, ""
+		}
+
+		transport := req.Header["Transport"]
+		if !strings.Contains(transport, "TCP") {
+			return 461, nil, "" // Unsupported Transport: interleaved TCP only
+		}
+		lo, hi := 2*idx, 2*idx+1
+		if l, h, ok := rtspmsg.ParseRange(transport, "interleaved="); ok && l >= 0 && l <= 254 {
+			lo, hi = l, h
+		}
+
+		clockRate := uint32(90000)
+		if idx == 1 {
+			clockRate = uint32(ss.info.AudioClockRate)
+		}
+		ss.tracks[idx] = &track{channel: byte(lo), ssrc: randUint32(), seq: uint16(randUint32()), clockRate: clockRate}
+		return 200, map[string]string{
+			"Transport": fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d;ssrc=%08X", lo, hi, ss.tracks[idx].ssrc),
+			"Session":   fmt.Sprintf("%s;timeout=%d", ss.id, int(sessionTimeout.Seconds())),
+		}, ""
+
+	case "PLAY":
+		if rtspmsg.SessionID(req.Header["Session"]) != ss.id || (ss.tracks[0] == nil && ss.tracks[1] == nil) {
+			return 454, nil, "" // Session Not Found
+		}
+		return 200, map[string]string{"Range": "npt=0.000-", "Session": ss.id}, ""
+
+	case "TEARDOWN":
+		return 200, nil, ""
+	}
+	return 501, nil, ""
+}
+
+// authorized checks the request's Basic credentials, when the server has any
+func (s *Server) authorized(req rtspmsg.Request) bool {
+	if s.cfg.Username == "" {
+		return true
+	}
+	encoded, ok := strings.CutPrefix(req.Header["Authorization"], "Basic ")
+	if !ok {
+		return false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return false
+	}
+	user, pass, _ := strings.Cut(string(decoded), ":")
+	return subtle.ConstantTimeCompare([]byte(user), []byte(s.cfg.Username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(pass), []byte(s.cfg.Password)) == 1
+}
+
+// stream writes the queued frames to a playing client until it goes away.
+// Video starts at a keyframe so the decoder has SPS/PPS and an IDR.
+func (ss *session) stream(ctx context.Context) error {
+	waitKeyframe := ss.tracks[0] != nil
+	for {
+		var f frame
+		select {
+		case <-ctx.Done():
+			return nil
+		case f = <-ss.frames:
+		}
+
+		if f.video {
+			t := ss.tracks[0]
+			if t == nil {
+				continue
+			}
+			if waitKeyframe && !f.keyframe {
+				continue
+			}
+			waitKeyframe = false
+			if err := ss.writeVideo(t, f); err != nil {
+				return err
+			}
+		} else if t := ss.tracks[1]; t != nil && !waitKeyframe {
+			if err := ss.writeAudio(t, f); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// writeVideo packetizes an AVC access unit (RFC 6184) and sends it
+func (ss *session) writeVideo(t *track, f frame) error {
+	ts := t.clock.timestamp(f.timestamp, t.clockRate)
+	nalus := rtp.SplitAVC(f.data)
+	for i, nalu := range nalus {
+		payloads := packetizeH264(nalu)
+		for j, payload := range payloads {
+			marker := i == len(nalus)-1 && j == len(payloads)-1
+			if err := ss.writeRTP(t, videoPayloadType, marker, ts, payload); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeAudio sends an AAC access unit with an RFC 3640 AAC-hbr AU header
+func (ss *session) writeAudio(t *track, f frame) error {
+	if len(f.data) >= 1<<13 || t.clockRate == 0 {
+		return nil // Doesn't fit the 13-bit AU size
+	}
+	payload := make([]byte, 4, 4+len(f.data))
+	binary.BigEndian.PutUint16(payload, 16) // AU-headers-length in bits
+	binary.BigEndian.PutUint16(payload[2:], uint16(len(f.data))<<3)
+	payload = append(payload, f.data...)
+	return ss.writeRTP(t, audioPayloadType, true, t.clock.timestamp(f.timestamp, t.clockRate), payload)
+}
+
+// writeRTP sends one RTP packet on the track's interleaved channel
+func (ss *session) writeRTP(t *track, pt byte, marker bool, ts uint32, payload []byte) error {
+	b := make([]byte, 16, 16+len(payload))
+	b[0], b[1] = '$', t.channel
+	binary.BigEndian.PutUint16(b[2:], uint16(12+len(payload)))
+	b[4] = 0x80 // Version 2
+	b[5] = pt
+	if marker {
+		b[5] |= 0x80
+	}
+	binary.BigEndian.PutUint16(b[6:], t.seq)
+	binary.BigEndian.PutUint32(b[8:], ts)
+	binary.BigEndian.PutUint32(b[12:], t.ssrc)
+	t.seq++
+	return ss.write(append(b, payload...))
+}
+
+func (ss *session) write(b []byte) error {
+	ss.writeMu.Lock()
+	defer ss.writeMu.Unlock()
+	ss.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := ss.conn.Write(b)
+	return err
+}
+
+// splitPath returns the camera ID and track control of a request URL such
+// as rtsp://relay:8554/<camera>/trackID=0
+func splitPath(rawURL string) (cameraID, control string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", ""
+	}
+	cameraID, control, _ = strings.Cut(strings.Trim(u.Path, "/"), "/")
+	return cameraID, control
+}
+
+// trackIndex parses a "trackID=N" control
+func trackIndex(control string) (int, bool) {
+	v, ok := strings.CutPrefix(control, "trackID=")
+	if !ok {
+		return 0, false
+	}
+	idx, err := strconv.Atoi(v)
+	if err != nil || idx < 0 || idx > 1 {
+		return 0, false
+	}
+	return idx, true
+}
+
+// hasAudio reports whether the camera's audio can be restreamed: only AAC
+// reaches recorders
+func hasAudio(info relay.MediaInfo) bool {
+	return info.AudioCodec == "MPEG4-GENERIC" && len(info.AudioConfig) > 0 && info.AudioClockRate > 0
+}
+
+// sessionSDP describes the camera's restreamable tracks
+func sessionSDP(info relay.MediaInfo, sps, pps []byte) string {
+	lines := []string{
+		"v=0",
+		"o=- 0 0 IN IP4 0.0.0.0",
+		"s=camsrelay",
+		"c=IN IP4 0.0.0.0",
+		"t=0 0",
+		"a=control:*",
+	}
+
+	if !info.AudioOnly {
+		fmtp := "packetization-mode=1"
+		if len(sps) >= 4 && len(pps) > 0 {
+			fmtp += fmt.Sprintf(";profile-level-id=%s;sprop-parameter-sets=%s,%s",
+				hex.EncodeToString(sps[1:4]),
+				base64.StdEncoding.EncodeToString(sps),
+				base64.StdEncoding.EncodeToString(pps))
+		}
+		lines = append(lines,
+			fmt.Sprintf("m=video 0 RTP/AVP %d", videoPayloadType),
+			fmt.Sprintf("a=rtpmap:%d H264/90000", videoPayloadType),
+			fmt.Sprintf("a=fmtp:%d %s", videoPayloadType, fmtp),
+			"a=control:trackID=0",
+		)
+	}
+
+	if hasAudio(info) {
+		channels := info.AudioChannels
+		if channels == 0 {
+			channels = 1
+		}
+		lines = append(lines,
+			fmt.Sprintf("m=audio 0 RTP/AVP %d", audioPayloadType),
+			fmt.Sprintf("a=rtpmap:%d MPEG4-GENERIC/%d/%d", audioPayloadType, info.AudioClockRate, channels),
+			fmt.Sprintf("a=fmtp:%d streamtype=5;profile-level-id=1;mode=AAC-hbr;sizelength=13;indexlength=3;indexdeltalength=3;config=%s",
+				audioPayloadType, hex.EncodeToString(info.AudioConfig)),
+			"a=control:trackID=1",
+		)
+	}
+
+	return strings.Join(append(lines, ""), "\r\n")
+}
+
+func randUint32() uint32 {
+	var b [4]byte
+	rand.Read(b[:])
+	return binary.BigEndian.Uint32(b[:])
+}
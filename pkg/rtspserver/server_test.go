@@ -0,0 +1,155 @@
+package rtspserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/goroutines"
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtp"
+	rtspClient "github.com/ethan/nest-cloudflare-relay/pkg/rtsp"
+	"github.com/ethan/nest-cloudflare-relay/pkg/rtsp/rtsptest"
+	pionRTP "github.com/pion/rtp"
+)
+
+// avc converts an Annex-B access unit with four-byte start codes to AVC
+func avc(annexB []byte) []byte {
+	var out []byte
+	for _, nalu := range bytes.Split(annexB, []byte{0, 0, 0, 1}) {
+		if len(nalu) == 0 {
+			continue
+		}
+		out = binary.BigEndian.AppendUint32(out, uint32(len(nalu)))
+		out = append(out, nalu...)
+	}
+	return out
+}
+
+func TestRestreamToRTSPClient(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := New(Config{Addr: "127.0.0.1:0"}, logger)
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer srv.Close()
+
+	gop := rtsptest.SyntheticGOP(5)
+	srv.RecordMediaInfo("porch", relay.MediaInfo{VideoCodec: "H264"})
+	srv.RecordVideo("porch", avc(gop[0]), 0, true) // Parameter sets for the SDP
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c := rtspClient.NewClient("rtsp://"+srv.Addr().String()+"/porch", logger)
+	defer c.Close()
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if sets := c.VideoParameterSets(); len(sets) != 2 {
+		t.Errorf("sprop-parameter-sets = %x, want SPS and PPS", sets)
+	}
+	if err := c.SetupTracks(ctx); err != nil {
+		t.Fatalf("SetupTracks: %v", err)
+	}
+	if err := c.Play(ctx); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+
+	type received struct {
+		size      int
+		timestamp uint32
+		keyframe  bool
+	}
+	frames := make(chan received, 16)
+	proc := rtp.NewH264Processor()
+	proc.OnFrame = func(au []byte, ts uint32, keyframe bool) {
+		frames <- received{len(au), ts, keyframe}
+	}
+	c.OnRTPPacket = func(_ byte, pkt *pionRTP.Packet) {
+		proc.ProcessPacket(pkt)
+	}
+	go c.ReadPackets(ctx)
+
+	// A P frame before any keyframe is skipped; a large one is fragmented
+	large := append([]byte{0x41}, bytes.Repeat([]byte{0xAA}, 3*maxPayload)...)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(frames) == 0 && time.Now().Before(deadline) {
+		srv.RecordVideo("porch", avc(gop[1]), 1000, false)
+		srv.RecordVideo("porch", avc(gop[0]), 3000, true)
+		srv.RecordVideo("porch", append(binary.BigEndian.AppendUint32(nil, uint32(len(large))), large...), 9000, false)
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	var first, second received
+	for i, f := range []*received{&first, &second} {
+		select {
+		case *f = <-frames:
+		case <-ctx.Done():
+			t.Fatalf("frame %d not received", i)
+		}
+	}
+	if !first.keyframe || first.size != len(avc(gop[0])) {
+		t.Errorf("first frame %+v, want the %d-byte keyframe", first, len(avc(gop[0])))
+	}
+	if second.keyframe || second.size != 4+len(large) || second.timestamp-first.timestamp != 9000-3000 {
+		t.Errorf("second frame %+v, want the %d-byte P frame 6000 ticks later", second, 4+len(large))
+	}
+
+	// The client's writer is accounted to its camera, and stops with the server
+	if n := goroutines.Camera("porch")["rtspserver.stream"]; n != 1 {
+		t.Errorf("rtspserver.stream goroutines for porch = %d, want 1", n)
+	}
+	srv.Close()
+	if err := goroutines.Settle(ctx, "rtspserver."); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDescribeAuthAndUnknownCamera(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := New(Config{Addr: "127.0.0.1:0", Username: "nvr", Password: "secret"}, logger)
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer srv.Close()
+	srv.RecordMediaInfo("porch", relay.MediaInfo{VideoCodec: "H264"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, tc := range []struct {
+		url string
+		ok  bool
+	}{
+		{"rtsp://nvr:secret@" + srv.Addr().String() + "/porch", true},
+		{"rtsp://nvr:wrong@" + srv.Addr().String() + "/porch", false},
+		{"rtsp://nvr:secret@" + srv.Addr().String() + "/garage", false},
+	} {
+		c := rtspClient.NewClient(tc.url, logger)
+		err := c.Connect(ctx)
+		c.Close()
+		if (err == nil) != tc.ok {
+			t.Errorf("DESCRIBE %s: err = %v", tc.url, err)
+		}
+	}
+}
+
+func TestClockBridgesRestarts(t *testing.T) {
+	var c clock
+	for _, tc := range []struct{ in, want uint32 }{
+		{90000, 90000},
+		{93000, 93000},
+		{1000, 94800},            // Relay reconnected: timestamps restarted
+		{4000, 97800},            // Continues from the new offset
+		{4000 + 10*90000, 99600}, // Jump past maxTimestampGap
+	} {
+		if got := c.timestamp(tc.in, 90000); got != tc.want {
+			t.Errorf("timestamp(%d) = %d, want %d", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Package sfu defines the signaling contract between a camera's WebRTC
+// bridge and the SFU it publishes to. The bridge owns the PeerConnection;
+// a Backend only exchanges session descriptions, so new SFUs (WHIP servers,
+// Janus, mediasoup gateways) plug in without touching relay or bridge code.
+package sfu
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotSupported is returned by optional operations a backend cannot perform
+var ErrNotSupported = errors.New("operation not supported by SFU backend")
+
+// Backend publishes local tracks to an SFU
+type Backend interface {
+	// Name identifies the backend in logs (e.g. "cloudflare", "livekit")
+	Name() string
+
+	// CreateSession allocates a publishing session for the named camera and
+	// returns an identifier viewers can use to locate its tracks.
+	CreateSession(ctx context.Context, cameraID string) (string, error)
+
+	// PublishTracks sends the complete (ICE-gathered) SDP offer announcing
+	// the given tracks and returns the SFU's SDP answer.
+	PublishTracks(ctx context.Context, sessionID string, offer Description, tracks []Track) (Description, error)
+
+	// Renegotiate sends an updated local description (a new offer after an
+	// ICE restart, or an answer to an SFU-initiated offer). The returned
+	// description is the SFU's answer, or zero when none is expected.
+	// Backends that cannot renegotiate return ErrNotSupported.
+	Renegotiate(ctx context.Context, sessionID string, desc Description) (Description, error)
+
+	// Close releases the session. Backends whose sessions expire on their
+	// own may treat this as a no-op.
+	Close(ctx context.Context, sessionID string) error
+}
+
+// Description is an SDP session description
+type Description struct {
+	Type string // "offer" or "answer"
+	SDP  string
+}
+
+// Track identifies one local track in the SDP offer
+type Track struct {
+	Mid  string
+	Name string
+	Kind string // "video" or "audio"
+}
+
+// StateReporter is implemented by backends that can report the SFU's view of
+// a session. The relay compares it with the bridge's published tracks to
+// detect sessions that look connected locally but are broken on the SFU.
+type StateReporter interface {
+	SessionState(ctx context.Context, sessionID string) ([]TrackState, error)
+}
+
+// TrackCloser is implemented by backends that can end published tracks
+// explicitly. The bridge calls it before closing its PeerConnection, so
+// viewers see the tracks end at once instead of waiting for the SFU to time
+// the session out.
+type TrackCloser interface {
+	CloseTracks(ctx context.Context, sessionID string, tracks []Track) error
+}
+
+// TrackState is the SFU's view of one track published in a session
+type TrackState struct {
+	Name   string
+	Kind   string // "video" or "audio", when reported
+	Status string // Backend-specific, e.g. Cloudflare's "active", "inactive", "waiting"
+	Error  string // Set when the SFU reports the track as errored
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that forgets everything on exit. It is used when
+// no state path is configured, so features can rely on a Store being present.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+	logs    map[string][]logEntry
+	closed  bool
+}
+
+type logEntry struct {
+	at   time.Time
+	data []byte
+}
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]map[string][]byte),
+		logs:    make(map[string][]logEntry),
+	}
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(bucket, key string, v any) (bool, error) {
+	s.mu.RLock()
+	data, ok := s.buckets[bucket][key]
+	s.mu.RUnlock()
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("decode %s/%s: %w", bucket, key, err)
+	}
+	return true, nil
+}
+
+// Put implements Store
+func (s *MemoryStore) Put(bucket, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode %s/%s: %w", bucket, key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	if s.buckets[bucket] == nil {
+		s.buckets[bucket] = make(map[string][]byte)
+	}
+	s.buckets[bucket][key] = data
+	return nil
+}
+
+// Delete implements Store
+func (s *MemoryStore) Delete(bucket, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.buckets[bucket], key)
+	return nil
+}
+
+// List implements Store
+func (s *MemoryStore) List(bucket string, fn func(key string, data []byte) error) error {
+	s.mu.RLock()
+	keys := make([]string, 0, len(s.buckets[bucket]))
+	for k := range s.buckets[bucket] {
+		keys = append(keys, k)
+	}
+	values := make(map[string][]byte, len(keys))
+	for _, k := range keys {
+		values[k] = s.buckets[bucket][k]
+	}
+	s.mu.RUnlock()
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := fn(k, values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Append implements Store
+func (s *MemoryStore) Append(bucket string, at time.Time, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode %s entry: %w", bucket, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+
+	// Keep entries sorted; appends are almost always in order
+	log := s.logs[bucket]
+	i := sort.Search(len(log), func(i int) bool { return log[i].at.After(at) })
+	log = append(log, logEntry{})
+	copy(log[i+1:], log[i:])
+	log[i] = logEntry{at: at, data: data}
+	s.logs[bucket] = log
+	return nil
+}
+
+// Range implements Store
+func (s *MemoryStore) Range(bucket string, from, to time.Time, fn func(at time.Time, data []byte) error) error {
+	s.mu.RLock()
+	var entries []logEntry
+	for _, e := range s.logs[bucket] {
+		if !e.at.Before(from) && e.at.Before(to) {
+			entries = append(entries, e)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, e := range entries {
+		if err := fn(e.at, e.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prune implements Store
+func (s *MemoryStore) Prune(bucket string, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	log := s.logs[bucket]
+	i := sort.Search(len(log), func(i int) bool { return !log[i].at.Before(before) })
+	s.logs[bucket] = append([]logEntry(nil), log[i:]...)
+	return nil
+}
+
+// Close implements Store
+func (s *MemoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
@@ -0,0 +1,112 @@
+// Package store is the relay's embedded persistence layer. Features that
+// need to survive restarts (tokens, per-camera settings, SFU session
+// mappings, stats history, event logs) share one Store, backed by a bbolt
+// file in production and by memory when no state path is configured.
+//
+// Values are JSON-encoded. Keyed records live in named buckets; time series
+// are appended to log buckets and read back by time range.
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// Well-known buckets
+const (
+	BucketTokens   = "tokens"   // OAuth tokens keyed by project
+	BucketCameras  = "cameras"  // Per-camera settings keyed by camera ID
+	BucketSessions = "sessions" // SFU session mappings keyed by camera ID
+	BucketStats    = "stats"    // Stats history (log)
+	BucketEvents   = "events"   // Event log (log)
+	BucketLayouts  = "layouts"  // Viewer grid layouts keyed by name
+	BucketStreams  = "streams"  // Live Nest RTSP streams keyed by camera ID
+	BucketEgress   = "egress"   // Daily bytes sent per camera keyed by "<day>/<camera ID>"
+)
+
+// ErrClosed is returned after Close
+var ErrClosed = errors.New("store closed")
+
+// Store persists keyed records and time-ordered logs
+type Store interface {
+	// Get decodes the value at bucket/key into v, reporting whether it exists
+	Get(bucket, key string, v any) (bool, error)
+	// Put encodes v and stores it at bucket/key
+	Put(bucket, key string, v any) error
+	// Delete removes bucket/key; missing keys are not an error
+	Delete(bucket, key string) error
+	// List calls fn for every key in bucket in key order
+	List(bucket string, fn func(key string, data []byte) error) error
+
+	// Append adds v to a log bucket, timestamped at
+	Append(bucket string, at time.Time, v any) error
+	// Range calls fn for log entries with from <= at < to, oldest first
+	Range(bucket string, from, to time.Time, fn func(at time.Time, data []byte) error) error
+	// Prune deletes log entries older than before
+	Prune(bucket string, before time.Time) error
+
+	Close() error
+}
+
+// SessionMapping records which SFU session a camera is published on, so a
+// restarted (or standby) instance can find and reuse or clean it up
+type SessionMapping struct {
+	CameraID  string    `json:"cameraId"`
+	Backend   string    `json:"backend"`
+	SessionID string    `json:"sessionId"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// StreamToken records a camera's live Nest RTSP stream so trusted local
+// tools can reuse it instead of generating their own. The URL embeds the
+// stream token and stops working at ExpiresAt unless the relay extends it.
+// The extension token lets a restarted relay resume the stream.
+type StreamToken struct {
+	CameraID       string    `json:"cameraId"`
+	URL            string    `json:"url"`
+	Token          string    `json:"token,omitempty"`
+	ExtensionToken string    `json:"extensionToken,omitempty"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// OAuthToken is a Google access token saved so a restart can reuse it
+// instead of refreshing. ClientID ties it to the OAuth client it was
+// issued to.
+type OAuthToken struct {
+	ClientID    string    `json:"clientId"`
+	AccessToken string    `json:"accessToken"`
+	Expiry      time.Time `json:"expiry"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// CameraSettings is a camera's display name, position and audio switch set
+// at runtime through the API. They take precedence over the config file and
+// Nest.
+type CameraSettings struct {
+	CameraID  string    `json:"cameraId"`
+	Name      string    `json:"name,omitempty"`
+	Order     int       `json:"order,omitempty"` // Lower sorts first; zero is unset
+	Audio     *bool     `json:"audio,omitempty"` // Nil follows the config file
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Empty reports whether the settings override nothing
+func (c CameraSettings) Empty() bool {
+	return c.Name == "" && c.Order == 0 && c.Audio == nil
+}
+
+// EgressDay is the bytes one camera sent to the SFU on one UTC day, so the
+// monthly egress total survives restarts
+type EgressDay struct {
+	Day      string `json:"day"` // 2006-01-02
+	CameraID string `json:"cameraId"`
+	Bytes    uint64 `json:"bytes"`
+}
+
+// Event is one entry in the event log
+type Event struct {
+	CameraID string `json:"cameraId,omitempty"`
+	Type     string `json:"type"`
+	Message  string `json:"message"`
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestStores runs the same keyed and log operations against both backends
+func TestStores(t *testing.T) {
+	bolt, err := OpenBolt(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, st := range map[string]Store{"bolt": bolt, "memory": NewMemory()} {
+		t.Run(name, func(t *testing.T) {
+			defer st.Close()
+
+			want := SessionMapping{CameraID: "cam1", Backend: "cloudflare", SessionID: "abc"}
+			if err := st.Put(BucketSessions, "cam1", want); err != nil {
+				t.Fatal(err)
+			}
+			var got SessionMapping
+			if ok, err := st.Get(BucketSessions, "cam1", &got); err != nil || !ok || got != want {
+				t.Fatalf("Get = %+v, %v, %v", got, ok, err)
+			}
+			if err := st.Delete(BucketSessions, "cam1"); err != nil {
+				t.Fatal(err)
+			}
+			if ok, _ := st.Get(BucketSessions, "cam1", &got); ok {
+				t.Fatal("Get after Delete found the record")
+			}
+
+			base := time.Unix(1_700_000_000, 0)
+			for i := 0; i < 5; i++ {
+				if err := st.Append(BucketEvents, base.Add(time.Duration(i)*time.Minute), Event{Type: "tick"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := st.Prune(BucketEvents, base.Add(time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+
+			var times []time.Time
+			st.Range(BucketEvents, base, base.Add(4*time.Minute), func(at time.Time, _ []byte) error {
+				times = append(times, at)
+				return nil
+			})
+			if len(times) != 3 || !times[0].Equal(base.Add(time.Minute)) || !times[2].Equal(base.Add(3*time.Minute)) {
+				t.Fatalf("Range returned %v", times)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+// Package thumbnail keeps a recent JPEG of every camera for dashboards and
+// camera grids. It records the latest keyframe of each camera as a
+// relay.Recorder and decodes it with ffmpeg on a fixed interval, so
+// thumbnails are always fresh without viewer requests ever touching ffmpeg.
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/h264/annexb"
+	"github.com/ethan/nest-cloudflare-relay/pkg/membudget"
+)
+
+const (
+	DefaultInterval = 30 * time.Second // How often thumbnails are refreshed
+	DefaultWidth    = 320              // Thumbnail width in pixels; height keeps the aspect ratio
+
+	encodeTimeout = 10 * time.Second
+	jpegQuality   = 5 // ffmpeg -q:v, 2 (best) to 31
+)
+
+// Config controls how thumbnails are produced
+type Config struct {
+	FFmpegPath string        // Defaults to "ffmpeg" on $PATH
+	Interval   time.Duration // Defaults to DefaultInterval
+	Width      int           // Defaults to DefaultWidth
+
+	Budget *membudget.Budget // Pending keyframes are charged to each camera's account; nil is unlimited
+}
+
+// Thumbnail is a camera's most recent JPEG
+type Thumbnail struct {
+	JPEG       []byte
+	CapturedAt time.Time // When the source keyframe arrived
+}
+
+// keyframe is a copy of a camera's latest IDR access unit
+type keyframe struct {
+	au         []byte
+	receivedAt time.Time
+}
+
+// Service caches one thumbnail per camera. It implements relay.Recorder.
+type Service struct {
+	cfg    Config
+	logger *slog.Logger
+
+	mu      sync.RWMutex
+	pending map[string]keyframe // Newest keyframe not yet encoded
+	thumbs  map[string]Thumbnail
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a thumbnail service and starts its refresh loop
+func New(cfg Config, logger *slog.Logger) *Service {
+	if cfg.FFmpegPath == "" {
+		cfg.FFmpegPath = "ffmpeg"
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Width <= 0 {
+		cfg.Width = DefaultWidth
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Service{
+		cfg:     cfg,
+		logger:  logger.With("component", "thumbnail"),
+		pending: make(map[string]keyframe),
+		thumbs:  make(map[string]Thumbnail),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	s.wg.Add(1)
+	go s.loop()
+	return s
+}
+
+// RecordVideo keeps the camera's latest keyframe for the next refresh
+func (s *Service) RecordVideo(cameraID string, au []byte, _ uint32, isKeyframe bool) {
+	if !isKeyframe {
+		return
+	}
+
+	memory := s.cfg.Budget.Account(cameraID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The newer keyframe replaces the pending one, so its bytes come back first
+	if old, ok := s.pending[cameraID]; ok {
+		memory.Release(len(old.au))
+		delete(s.pending, cameraID)
+	}
+	if !memory.Reserve(len(au)) {
+		return // Skip this refresh; the camera's buffers are full
+	}
+	s.pending[cameraID] = keyframe{au: append([]byte(nil), au...), receivedAt: time.Now()}
+}
+
+// RecordAudio is a no-op; thumbnails are video only
+func (s *Service) RecordAudio(string, []byte, uint32) {}
+
+// Get returns the camera's latest thumbnail
+func (s *Service) Get(cameraID string) (Thumbnail, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.thumbs[cameraID]
+	return t, ok
+}
+
+// Close stops the refresh loop. Cached thumbnails remain readable.
+func (s *Service) Close() error {
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
+
+// loop encodes pending keyframes every interval
+func (s *Service) loop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.refresh()
+		}
+	}
+}
+
+// refresh encodes every camera that received a keyframe since the last run.
+// Cameras are encoded one at a time to keep ffmpeg's CPU use flat.
+func (s *Service) refresh() {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[string]keyframe, len(pending))
+	s.mu.Unlock()
+
+	for cameraID, kf := range pending {
+		if s.ctx.Err() != nil {
+			s.cfg.Budget.Account(cameraID).Release(len(kf.au))
+			continue
+		}
+
+		jpeg, err := s.encode(kf.au)
+		s.cfg.Budget.Account(cameraID).Release(len(kf.au))
+		if err != nil {
+			s.logger.Warn("failed to encode thumbnail", "camera_id", cameraID, "error", err)
+			continue
+		}
+
+		s.mu.Lock()
+		s.thumbs[cameraID] = Thumbnail{JPEG: jpeg, CapturedAt: kf.receivedAt}
+		s.mu.Unlock()
+	}
+}
+
+// encode decodes one AVC keyframe and scales it to a JPEG
+func (s *Service) encode(au []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, encodeTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.cfg.FFmpegPath,
+		"-hide_banner", "-loglevel", "error",
+		"-f", "h264", "-i", "pipe:0",
+		"-frames:v", "1",
+		"-vf", "scale="+strconv.Itoa(s.cfg.Width)+":-2",
+		"-q:v", strconv.Itoa(jpegQuality),
+		"-f", "image2", "-c:v", "mjpeg", "pipe:1",
+	)
+	cmd.Stdin = bytes.NewReader(annexb.DecodeAVCC(au, true))
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("ffmpeg produced no image")
+	}
+	return stdout.Bytes(), nil
+}
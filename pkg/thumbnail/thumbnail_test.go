@@ -0,0 +1,73 @@
+package thumbnail
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// fakeFFmpeg writes a script that saves its input and prints a fixed JPEG
+func fakeFFmpeg(t *testing.T) (path, input string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	dir := t.TempDir()
+	input = filepath.Join(dir, "input.h264")
+	path = filepath.Join(dir, "ffmpeg")
+	script := "#!/bin/sh\ncat > '" + input + "'\nprintf 'JPEG'\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path, input
+}
+
+func TestThumbnailCapture(t *testing.T) {
+	ffmpeg, input := fakeFFmpeg(t)
+	s := New(Config{FFmpegPath: ffmpeg, Interval: 10 * time.Millisecond}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer s.Close()
+
+	// Only keyframes are captured
+	s.RecordVideo("door", []byte{0, 0, 0, 2, 0x41, 0x9a}, 0, false)
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := s.Get("door"); ok {
+		t.Fatal("thumbnail from a non-keyframe")
+	}
+
+	before := time.Now()
+	s.RecordVideo("door", []byte{0, 0, 0, 2, 0x65, 0x88}, 0, true)
+
+	deadline := time.Now().Add(2 * time.Second)
+	var thumb Thumbnail
+	for {
+		var ok bool
+		if thumb, ok = s.Get("door"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no thumbnail after a keyframe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if string(thumb.JPEG) != "JPEG" || thumb.CapturedAt.Before(before) {
+		t.Errorf("thumbnail %q captured at %v", thumb.JPEG, thumb.CapturedAt)
+	}
+
+	// ffmpeg is fed Annex B, not the relay's AVCC
+	got, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0, 0, 0, 1, 0x65, 0x88}; !bytes.Equal(got, want) {
+		t.Errorf("ffmpeg input %x, want %x", got, want)
+	}
+
+	if _, ok := s.Get("hall"); ok {
+		t.Error("thumbnail for a camera never recorded")
+	}
+}
@@ -0,0 +1,293 @@
+// Package timelapse saves one keyframe per camera every interval and
+// assembles them into an MP4 on demand. Keyframes are captured off the relay
+// like thumbnails are, kept on disk as AVC access units, and muxed to
+// MPEG-TS with tsmux so ffmpeg only has to remux them (no re-encode).
+package timelapse
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+	"github.com/ethan/nest-cloudflare-relay/pkg/tsmux"
+)
+
+const (
+	DefaultInterval  = 5 * time.Minute    // How often a keyframe is kept per camera
+	DefaultRetention = 7 * 24 * time.Hour // How long kept keyframes stay on disk
+	DefaultFPS       = 24                 // Playback rate of assembled time-lapses
+	MaxFPS           = 60
+
+	queueSize      = 64 // Captures waiting for the writer before dropping
+	frameExt       = ".avc"
+	partialExt     = ".avc.part"
+	filenameLayout = "20060102T150405Z"
+)
+
+// ErrNoFrames is returned when a camera has no kept keyframes in the
+// requested range
+var ErrNoFrames = errors.New("no time-lapse frames in range")
+
+// Config controls capture and retention
+type Config struct {
+	Dir        string        // Root directory; one subdirectory per camera
+	Interval   time.Duration // Defaults to DefaultInterval
+	Retention  time.Duration // Defaults to DefaultRetention
+	FFmpegPath string        // Defaults to "ffmpeg" on $PATH
+}
+
+// Frame is one kept keyframe
+type Frame struct {
+	Time time.Time
+	Path string
+}
+
+// capture is a keyframe copied off the relay, waiting to be written
+type capture struct {
+	cameraID string
+	au       []byte
+	at       time.Time
+}
+
+// Service keeps time-lapse keyframes for every camera. It implements
+// relay.Recorder.
+type Service struct {
+	cfg    Config
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	lastSaved map[string]time.Time
+
+	queue  chan capture
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates the service and starts its writer
+func New(cfg Config, logger *slog.Logger) (*Service, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("time-lapse directory not set")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create time-lapse directory: %w", err)
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = DefaultRetention
+	}
+	if cfg.FFmpegPath == "" {
+		cfg.FFmpegPath = "ffmpeg"
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Service{
+		cfg:       cfg,
+		logger:    logger.With("component", "timelapse"),
+		lastSaved: make(map[string]time.Time),
+		queue:     make(chan capture, queueSize),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+
+	s.wg.Add(1)
+	go s.writer()
+	return s, nil
+}
+
+// RecordVideo keeps the first keyframe of each interval
+func (s *Service) RecordVideo(cameraID string, au []byte, _ uint32, isKeyframe bool) {
+	if !isKeyframe {
+		return
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	if now.Sub(s.lastSaved[cameraID]) < s.cfg.Interval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSaved[cameraID] = now
+	s.mu.Unlock()
+
+	select {
+	case s.queue <- capture{cameraID: cameraID, au: append([]byte(nil), au...), at: now}:
+	default:
+		// Writer is behind; try again with the camera's next keyframe
+		s.mu.Lock()
+		delete(s.lastSaved, cameraID)
+		s.mu.Unlock()
+	}
+}
+
+// RecordAudio is a no-op; time-lapses are video only
+func (s *Service) RecordAudio(string, []byte, uint32) {}
+
+// Close stops the writer. Kept keyframes stay on disk for the next run.
+func (s *Service) Close() error {
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
+
+// writer saves captures and prunes keyframes past retention
+func (s *Service) writer() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case c := <-s.queue:
+			if err := s.save(c); err != nil {
+				s.logger.Warn("failed to save time-lapse frame", "camera_id", c.cameraID, "error", err)
+				continue
+			}
+			s.prune(c.cameraID, c.at.Add(-s.cfg.Retention))
+		}
+	}
+}
+
+// save writes a capture as .avc.part and renames it once complete
+func (s *Service) save(c capture) error {
+	dir := filepath.Join(s.cfg.Dir, c.cameraID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create camera directory: %w", err)
+	}
+
+	path := filepath.Join(dir, c.at.UTC().Format(filenameLayout)+partialExt)
+	if err := os.WriteFile(path, c.au, 0o644); err != nil {
+		return fmt.Errorf("write frame: %w", err)
+	}
+	return os.Rename(path, strings.TrimSuffix(path, partialExt)+frameExt)
+}
+
+// prune deletes the camera's keyframes taken before cutoff
+func (s *Service) prune(cameraID string, cutoff time.Time) {
+	frames, err := s.Frames(cameraID, time.Time{}, cutoff)
+	if err != nil {
+		return
+	}
+	for _, f := range frames {
+		if err := os.Remove(f.Path); err != nil {
+			s.logger.Warn("failed to prune time-lapse frame", "camera_id", cameraID, "path", f.Path, "error", err)
+		}
+	}
+}
+
+// Frames returns the camera's kept keyframes between from and to, oldest
+// first. A zero bound is open.
+func (s *Service) Frames(cameraID string, from, to time.Time) ([]Frame, error) {
+	dir := filepath.Join(s.cfg.Dir, cameraID)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read camera directory: %w", err)
+	}
+
+	// ReadDir sorts by name, which is chronological
+	var frames []Frame
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), frameExt)
+		if !ok || e.IsDir() {
+			continue
+		}
+		at, err := time.Parse(filenameLayout, name)
+		if err != nil {
+			continue
+		}
+		if (!from.IsZero() && at.Before(from)) || (!to.IsZero() && at.After(to)) {
+			continue
+		}
+		frames = append(frames, Frame{Time: at, Path: filepath.Join(dir, e.Name())})
+	}
+	return frames, nil
+}
+
+// Assemble writes an MP4 of the camera's keyframes between from and to,
+// played back at fps, to w. The MP4 is fragmented so it can be streamed as
+// it is produced.
+func (s *Service) Assemble(ctx context.Context, cameraID string, from, to time.Time, fps int, w io.Writer) error {
+	if fps <= 0 {
+		fps = DefaultFPS
+	}
+	fps = min(fps, MaxFPS)
+
+	frames, err := s.Frames(cameraID, from, to)
+	if err != nil {
+		return err
+	}
+	if len(frames) == 0 {
+		return ErrNoFrames
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.cfg.FFmpegPath,
+		"-hide_banner", "-loglevel", "error",
+		"-f", "mpegts", "-i", "pipe:0",
+		"-c:v", "copy",
+		"-movflags", "frag_keyframe+empty_moov+default_base_moof",
+		"-f", "mp4", "pipe:1",
+	)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("ffmpeg stdin: %w", err)
+	}
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start ffmpeg: %w", err)
+	}
+
+	feedErr := make(chan error, 1)
+	go func() {
+		feedErr <- s.feed(stdin, frames, fps)
+		stdin.Close()
+	}()
+
+	waitErr := cmd.Wait()
+	if err := <-feedErr; err != nil && waitErr == nil {
+		return err
+	}
+	if waitErr != nil {
+		return fmt.Errorf("ffmpeg: %w: %s", waitErr, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
+
+// feed muxes the frames to MPEG-TS, one every 1/fps seconds
+func (s *Service) feed(w io.Writer, frames []Frame, fps int) error {
+	mux := tsmux.New(relay.MediaInfo{VideoCodec: "H264"})
+	if _, err := w.Write(mux.Header()); err != nil {
+		return err
+	}
+
+	for i, f := range frames {
+		au, err := os.ReadFile(f.Path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue // Pruned since listing
+			}
+			return fmt.Errorf("read frame: %w", err)
+		}
+		if _, err := w.Write(mux.Video(au, uint32(i*90000/fps))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package timelapse
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestCaptureIntervalAndRetention(t *testing.T) {
+	s, err := New(Config{Dir: t.TempDir(), Interval: time.Hour, Retention: time.Hour}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := s.save(capture{cameraID: "cam1", au: []byte{1}, at: old}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.RecordVideo("cam1", []byte{0, 0, 0, 1, 0x65}, 0, false)
+	s.RecordVideo("cam1", []byte{0, 0, 0, 1, 0x65}, 0, true)
+	s.RecordVideo("cam1", []byte{0, 0, 0, 1, 0x65}, 3000, true) // Same interval
+
+	// The writer saves the queued keyframe, then prunes the old one
+	deadline := time.Now().Add(2 * time.Second)
+	var frames []Frame
+	for time.Now().Before(deadline) {
+		if frames, err = s.Frames("cam1", time.Time{}, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) == 1 && frames[0].Time.After(old) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(frames) != 1 || !frames[0].Time.After(old) {
+		t.Fatalf("frames = %+v, want one recent frame", frames)
+	}
+
+	if got, _ := s.Frames("cam1", time.Now().Add(time.Minute), time.Time{}); len(got) != 0 {
+		t.Errorf("range after the frame returned %d frames", len(got))
+	}
+	if got, _ := s.Frames("cam2", time.Time{}, time.Time{}); len(got) != 0 {
+		t.Errorf("unknown camera returned %d frames", len(got))
+	}
+}
@@ -0,0 +1,477 @@
+// Package transcode is the escape hatch for media the pure-Go pipeline
+// cannot relay directly. A Transcoder runs one supervised ffmpeg process per
+// camera: the relay's access units are muxed into MPEG-TS on ffmpeg's stdin
+// (G.711 audio, which MPEG-TS can't carry, goes raw on a second pipe), and
+// ffmpeg sends H.264 and/or Opus RTP back over loopback UDP, which is
+// forwarded to the camera's WebRTC tracks.
+package transcode
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+	"github.com/ethan/nest-cloudflare-relay/pkg/tsmux"
+	pionRTP "github.com/pion/rtp"
+)
+
+const (
+	queueSize         = 256              // Frames buffered before dropping
+	minRestartBackoff = time.Second      // First restart delay
+	maxRestartBackoff = 30 * time.Second // Restart delay cap
+	stableRun         = time.Minute      // A run this long resets the backoff
+	rtpPacketSize     = 1200             // ffmpeg RTP payload limit, leaves room for SRTP/TURN overhead
+	stderrTailSize    = 2048             // ffmpeg stderr kept for error reports
+
+	videoPayloadType = 96
+	audioPayloadType = 111
+	opusBitrate      = "48k"
+
+	g711WriteTimeout = 100 * time.Millisecond // Longest a G.711 write may block before the samples are dropped
+)
+
+// g711Formats maps G.711 encoding names to ffmpeg's raw input formats
+var g711Formats = map[string]string{"PCMU": "mulaw", "PCMA": "alaw"}
+
+// Config selects what a camera's transcoder re-encodes
+type Config struct {
+	FFmpegPath   string // Defaults to "ffmpeg" on $PATH
+	Video        bool   // Re-encode video to constrained-baseline H.264
+	VideoBitrate int    // Video bitrate cap in bits/s; implies Video
+	Audio        bool   // Transcode AAC or G.711 to Opus so the camera has audio in WebRTC
+}
+
+// Enabled reports whether the config routes any track through ffmpeg
+func (c Config) Enabled() bool {
+	return c.Video || c.VideoBitrate > 0 || c.Audio
+}
+
+// frame is a copy of one access unit queued for the ffmpeg writer
+type frame struct {
+	video     bool
+	data      []byte
+	timestamp uint32
+	keyframe  bool
+}
+
+// Transcoder supervises the ffmpeg process for one camera. It implements
+// relay.Transcoder; a new one is created for every relay session.
+type Transcoder struct {
+	cameraID string
+	cfg      Config
+	logger   *slog.Logger
+
+	frames   chan frame
+	dropped  atomic.Uint64
+	restarts atomic.Uint64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// Set by Start
+	info      relay.MediaInfo
+	audio     bool   // Audio requested and the camera has AAC
+	g711      string // Audio requested and the camera has G.711: ffmpeg's raw format for it
+	idle      bool   // Nothing to transcode (e.g. Opus audio passed through); ffmpeg never runs
+	videoConn *net.UDPConn
+	audioConn *net.UDPConn
+}
+
+// New creates a transcoder for a camera. Nothing runs until Start.
+func New(cameraID string, cfg Config, logger *slog.Logger) *Transcoder {
+	if cfg.FFmpegPath == "" {
+		cfg.FFmpegPath = "ffmpeg"
+	}
+	if cfg.VideoBitrate > 0 {
+		cfg.Video = true
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Transcoder{
+		cameraID: cameraID,
+		cfg:      cfg,
+		logger:   logger.With("camera_id", cameraID, "component", "transcode"),
+		frames:   make(chan frame, queueSize),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// TranscodesVideo reports whether video goes through ffmpeg
+func (t *Transcoder) TranscodesVideo() bool {
+	return t.cfg.Video
+}
+
+// TranscodesAudio reports whether audio goes through ffmpeg
+func (t *Transcoder) TranscodesAudio() bool {
+	return t.cfg.Audio
+}
+
+// Restarts returns how many times ffmpeg has been restarted
+func (t *Transcoder) Restarts() uint64 {
+	return t.restarts.Load()
+}
+
+// Start opens the loopback RTP ports and launches the ffmpeg supervisor
+func (t *Transcoder) Start(info relay.MediaInfo, out relay.TranscodeOutput) error {
+	t.info = info
+	t.audio = t.cfg.Audio && tsmux.New(info).HasAudio()
+	if t.cfg.Audio && !t.audio {
+		t.g711 = g711Formats[strings.ToUpper(info.AudioCodec)]
+	}
+	if t.cfg.Audio && !t.audio && t.g711 == "" && info.AudioCodec != "OPUS" {
+		t.logger.Warn("audio transcoding requested but camera has no AAC or G.711 track")
+	}
+	if !t.cfg.Video && !t.audio && t.g711 == "" {
+		t.idle = true
+		t.logger.Debug("nothing to transcode, ffmpeg not started", "audio_codec", info.AudioCodec)
+		return nil
+	}
+
+	if _, err := exec.LookPath(t.cfg.FFmpegPath); err != nil {
+		return fmt.Errorf("find ffmpeg: %w", err)
+	}
+
+	var err error
+	if t.cfg.Video {
+		if t.videoConn, err = listenLoopback(); err != nil {
+			return fmt.Errorf("listen for video RTP: %w", err)
+		}
+		t.wg.Add(1)
+		go t.readRTP(t.videoConn, "video", newRewriter(90000), out.Video)
+	}
+	if t.audio || t.g711 != "" {
+		if t.audioConn, err = listenLoopback(); err != nil {
+			t.Close()
+			return fmt.Errorf("listen for audio RTP: %w", err)
+		}
+		t.wg.Add(1)
+		go t.readRTP(t.audioConn, "audio", newRewriter(48000), out.Audio)
+	}
+
+	t.wg.Add(1)
+	go t.supervise()
+
+	t.logger.Info("transcoder started",
+		"video", t.cfg.Video,
+		"video_bitrate", t.cfg.VideoBitrate,
+		"audio", t.audio || t.g711 != "",
+		"audio_codec", info.AudioCodec)
+	return nil
+}
+
+// Close stops ffmpeg and the RTP readers
+func (t *Transcoder) Close() error {
+	t.cancel()
+	if t.videoConn != nil {
+		t.videoConn.Close()
+	}
+	if t.audioConn != nil {
+		t.audioConn.Close()
+	}
+	t.wg.Wait()
+	return nil
+}
+
+// RecordVideo queues an H.264 access unit for ffmpeg
+func (t *Transcoder) RecordVideo(_ string, au []byte, timestamp uint32, keyframe bool) {
+	t.enqueue(frame{video: true, timestamp: timestamp, keyframe: keyframe}, au)
+}
+
+// RecordAudio queues an AAC access unit, or G.711 samples, for ffmpeg
+func (t *Transcoder) RecordAudio(_ string, data []byte, timestamp uint32) {
+	t.enqueue(frame{timestamp: timestamp}, data)
+}
+
+// enqueue copies the payload and hands it to the writer without blocking
+func (t *Transcoder) enqueue(f frame, data []byte) {
+	if t.idle {
+		return
+	}
+	f.data = append([]byte(nil), data...)
+	select {
+	case t.frames <- f:
+	default:
+		if n := t.dropped.Add(1); n%100 == 1 {
+			t.logger.Warn("transcoder queue full, dropping frames", "dropped", n)
+		}
+	}
+}
+
+// supervise keeps ffmpeg running, restarting it with exponential backoff
+func (t *Transcoder) supervise() {
+	defer t.wg.Done()
+
+	backoff := minRestartBackoff
+	for {
+		started := time.Now()
+		err := t.run()
+		if t.ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(started) > stableRun {
+			backoff = minRestartBackoff
+		}
+		t.restarts.Add(1)
+		t.logger.Error("ffmpeg exited, restarting", "error", err, "backoff", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-t.ctx.Done():
+			return
+		}
+
+		backoff *= 2
+		if backoff > maxRestartBackoff {
+			backoff = maxRestartBackoff
+		}
+	}
+}
+
+// run feeds one ffmpeg process until it exits or the transcoder is closed
+func (t *Transcoder) run() error {
+	// Start each process on a keyframe so ffmpeg can decode immediately
+	// (audio-only cameras start on any frame)
+	var first frame
+	for first.data == nil {
+		select {
+		case <-t.ctx.Done():
+			return t.ctx.Err()
+		case f := <-t.frames:
+			if (f.video && f.keyframe) || t.info.AudioOnly {
+				first = f
+			}
+		}
+	}
+
+	stderr := &tailBuffer{}
+	cmd := exec.CommandContext(t.ctx, t.cfg.FFmpegPath, t.args()...)
+	cmd.Stderr = stderr
+	cmd.WaitDelay = 5 * time.Second
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("stdin pipe: %w", err)
+	}
+
+	// G.711 goes raw on pipe:3
+	var g711 *os.File
+	if t.g711 != "" {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return fmt.Errorf("G.711 pipe: %w", err)
+		}
+		defer w.Close()
+		cmd.ExtraFiles = []*os.File{r}
+		g711 = w
+	}
+
+	err = cmd.Start()
+	for _, f := range cmd.ExtraFiles {
+		f.Close() // ffmpeg has its own copy
+	}
+	if err != nil {
+		return fmt.Errorf("start ffmpeg: %w", err)
+	}
+
+	var waitErr error
+	exited := make(chan struct{})
+	go func() {
+		waitErr = cmd.Wait()
+		close(exited)
+	}()
+
+	t.logger.Info("ffmpeg started", "pid", cmd.Process.Pid)
+
+	err = t.feed(stdin, g711, first, exited)
+	stdin.Close()
+	if t.ctx.Err() != nil {
+		<-exited
+		return t.ctx.Err()
+	}
+
+	// A write error usually means ffmpeg died; its exit status explains why
+	cmd.Process.Kill()
+	<-exited
+	if waitErr != nil {
+		err = waitErr
+	}
+	return fmt.Errorf("%w: %s", err, stderr.String())
+}
+
+// feed muxes queued frames into ffmpeg's stdin, and writes G.711 samples to
+// g711, until a write fails, ffmpeg exits or the transcoder is closed
+func (t *Transcoder) feed(w io.Writer, g711 *os.File, first frame, exited <-chan struct{}) error {
+	mux := tsmux.New(t.info)
+	if t.tsInput() {
+		if _, err := w.Write(mux.Header()); err != nil {
+			return fmt.Errorf("write TS header: %w", err)
+		}
+	}
+
+	f := first
+	for {
+		var b []byte
+		switch {
+		case !f.video && g711 != nil:
+			t.writeG711(g711, f.data)
+		case !t.tsInput():
+			// Only G.711 is transcoded; video stays on the direct path
+		case f.video:
+			b = mux.Video(f.data, f.timestamp)
+		default:
+			b = mux.Audio(f.data, f.timestamp)
+		}
+		if len(b) > 0 {
+			if _, err := w.Write(b); err != nil {
+				return fmt.Errorf("write to ffmpeg: %w", err)
+			}
+		}
+
+		select {
+		case <-t.ctx.Done():
+			return t.ctx.Err()
+		case <-exited:
+			return fmt.Errorf("ffmpeg exited")
+		case f = <-t.frames:
+		}
+	}
+}
+
+// writeG711 writes samples to ffmpeg's G.711 input. ffmpeg reads its inputs
+// independently, so a write that doesn't complete promptly drops the samples
+// rather than stalling video.
+func (t *Transcoder) writeG711(w *os.File, samples []byte) {
+	w.SetWriteDeadline(time.Now().Add(g711WriteTimeout))
+	if _, err := w.Write(samples); err != nil {
+		if n := t.dropped.Add(1); n%100 == 1 {
+			t.logger.Warn("G.711 input blocked, dropping samples", "dropped", n, "error", err)
+		}
+	}
+}
+
+// tsInput reports whether ffmpeg reads MPEG-TS on stdin: it carries the
+// video and AAC audio, so a camera whose only transcoded track is G.711
+// needs none
+func (t *Transcoder) tsInput() bool {
+	return t.cfg.Video || t.audio
+}
+
+// args builds the ffmpeg command line: MPEG-TS on stdin and raw G.711 on
+// pipe:3 as needed, one RTP output per transcoded track
+func (t *Transcoder) args() []string {
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-fflags", "nobuffer", "-flags", "low_delay",
+	}
+	if t.tsInput() {
+		args = append(args, "-f", "mpegts", "-i", "pipe:0")
+	} else {
+		args = append(args, "-nostdin")
+	}
+	audioInput := "0:a:0"
+	if t.g711 != "" {
+		if t.tsInput() {
+			audioInput = "1:a:0"
+		}
+		args = append(args, "-f", t.g711, "-ar", "8000", "-ac", "1", "-i", "pipe:3")
+	}
+
+	if t.cfg.Video {
+		args = append(args, "-map", "0:v:0",
+			"-c:v", "libx264", "-preset", "veryfast", "-tune", "zerolatency",
+			"-profile:v", "baseline", "-pix_fmt", "yuv420p", "-bf", "0", "-g", "60")
+		if b := t.cfg.VideoBitrate; b > 0 {
+			args = append(args,
+				"-b:v", strconv.Itoa(b),
+				"-maxrate", strconv.Itoa(b),
+				"-bufsize", strconv.Itoa(2*b))
+		}
+		args = append(args, rtpOutput(videoPayloadType, t.videoConn)...)
+	}
+
+	if t.audio || t.g711 != "" {
+		args = append(args, "-map", audioInput,
+			"-c:a", "libopus", "-b:a", opusBitrate, "-ar", "48000", "-ac", "2",
+			"-application", "lowdelay")
+		args = append(args, rtpOutput(audioPayloadType, t.audioConn)...)
+	}
+
+	return args
+}
+
+// rtpOutput returns the ffmpeg output options sending RTP to conn
+func rtpOutput(payloadType int, conn *net.UDPConn) []string {
+	return []string{
+		"-payload_type", strconv.Itoa(payloadType),
+		"-f", "rtp",
+		fmt.Sprintf("rtp://%s?pkt_size=%d", conn.LocalAddr(), rtpPacketSize),
+	}
+}
+
+// readRTP forwards ffmpeg's RTP output to a WebRTC track until the socket closes
+func (t *Transcoder) readRTP(conn *net.UDPConn, kind string, rw *rewriter, write func(*pionRTP.Packet) error) {
+	defer t.wg.Done()
+
+	buf := make([]byte, 1500)
+	var failures uint64
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if t.ctx.Err() == nil {
+				t.logger.Error("RTP read failed", "track", kind, "error", err)
+			}
+			return
+		}
+
+		pkt := &pionRTP.Packet{}
+		if err := pkt.Unmarshal(buf[:n]); err != nil {
+			continue
+		}
+		rw.rewrite(pkt, time.Now())
+
+		if err := write(pkt); err != nil {
+			if failures++; failures%100 == 1 {
+				t.logger.Warn("failed to write transcoded RTP", "track", kind, "failures", failures, "error", err)
+			}
+		}
+	}
+}
+
+// listenLoopback opens a UDP socket on an ephemeral loopback port
+func listenLoopback() (*net.UDPConn, error) {
+	return net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+}
+
+// tailBuffer keeps the last few KB of ffmpeg's stderr
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > stderrTailSize {
+		b.buf = b.buf[len(b.buf)-stderrTailSize:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,50 @@
+package transcode
+
+import (
+	"time"
+
+	pionRTP "github.com/pion/rtp"
+)
+
+// rewriter keeps sequence numbers and timestamps continuous across ffmpeg
+// restarts. Each process starts with a random SSRC, sequence number and
+// timestamp; without rebasing, the browser sees a jump and may stall until
+// the jitter buffer resets.
+type rewriter struct {
+	clockRate uint32
+
+	started   bool
+	ssrc      uint32
+	seqOffset uint16
+	tsOffset  uint32
+	lastSeq   uint16
+	lastTS    uint32
+	lastAt    time.Time
+}
+
+func newRewriter(clockRate uint32) *rewriter {
+	return &rewriter{clockRate: clockRate}
+}
+
+// rewrite rebases pkt in place. A new SSRC marks a restarted process; its
+// first packet continues one sequence number after the last one forwarded,
+// with the timestamp advanced by the wall-clock gap.
+func (w *rewriter) rewrite(pkt *pionRTP.Packet, now time.Time) {
+	if w.started && pkt.SSRC != w.ssrc {
+		gap := uint32(now.Sub(w.lastAt).Seconds() * float64(w.clockRate))
+		if gap == 0 {
+			gap = 1
+		}
+		w.seqOffset = w.lastSeq + 1 - pkt.SequenceNumber
+		w.tsOffset = w.lastTS + gap - pkt.Timestamp
+	}
+	w.started = true
+	w.ssrc = pkt.SSRC
+
+	pkt.SequenceNumber += w.seqOffset
+	pkt.Timestamp += w.tsOffset
+
+	w.lastSeq = pkt.SequenceNumber
+	w.lastTS = pkt.Timestamp
+	w.lastAt = now
+}
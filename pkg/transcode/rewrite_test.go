@@ -0,0 +1,45 @@
+package transcode
+
+import (
+	"testing"
+	"time"
+
+	pionRTP "github.com/pion/rtp"
+)
+
+func TestRewriterContinuesAcrossRestart(t *testing.T) {
+	w := newRewriter(90000)
+	now := time.Unix(1000, 0)
+
+	pkt := func(ssrc uint32, seq uint16, ts uint32) *pionRTP.Packet {
+		return &pionRTP.Packet{Header: pionRTP.Header{SSRC: ssrc, SequenceNumber: seq, Timestamp: ts}}
+	}
+
+	// First process passes through unchanged
+	p := pkt(1, 65535, 4294967000)
+	w.rewrite(p, now)
+	if p.SequenceNumber != 65535 || p.Timestamp != 4294967000 {
+		t.Fatalf("first packet rewritten: seq=%d ts=%d", p.SequenceNumber, p.Timestamp)
+	}
+
+	// Restarted process, one second later; the timestamp wraps past 2^32
+	now = now.Add(time.Second)
+	p = pkt(2, 100, 5000)
+	w.rewrite(p, now)
+	if p.SequenceNumber != 0 {
+		t.Errorf("seq after restart = %d, want 0", p.SequenceNumber)
+	}
+	if want := uint32(89704); p.Timestamp != want {
+		t.Errorf("ts after restart = %d, want %d", p.Timestamp, want)
+	}
+
+	// Later packets from the new process keep their spacing
+	p = pkt(2, 101, 8000)
+	w.rewrite(p, now)
+	if p.SequenceNumber != 1 {
+		t.Errorf("seq = %d, want 1", p.SequenceNumber)
+	}
+	if want := uint32(92704); p.Timestamp != want {
+		t.Errorf("ts = %d, want %d", p.Timestamp, want)
+	}
+}
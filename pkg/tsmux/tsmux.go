@@ -0,0 +1,97 @@
+// Package tsmux packs the relay's H.264 and AAC access units into MPEG-TS.
+// It is shared by the recorders, the DVR buffer and the ffmpeg transcoder.
+package tsmux
+
+import (
+	"strings"
+
+	"github.com/AlexxIT/go2rtc/pkg/aac"
+	"github.com/AlexxIT/go2rtc/pkg/mpegts"
+	"github.com/ethan/nest-cloudflare-relay/pkg/relay"
+)
+
+// Muxer packs H.264 and AAC access units into MPEG-TS packets. Callers
+// start a fresh muxer for every file or process so the output begins at
+// PTS zero with its own PAT/PMT and is independently playable.
+type Muxer struct {
+	mux      *mpegts.Muxer
+	videoPID uint16
+	audioPID uint16
+
+	adts       []byte // ADTS header template, nil when there is no AAC track
+	audioRate  uint32
+	videoClock ptsClock
+	audioClock ptsClock
+}
+
+// New creates a muxer with an H.264 or H.265 video track (unless the camera
+// is relayed audio-only) and, when the camera has AAC audio, an audio track
+func New(info relay.MediaInfo) *Muxer {
+	m := &Muxer{mux: mpegts.NewMuxer()}
+	if !info.AudioOnly {
+		streamType := byte(mpegts.StreamTypeH264)
+		if strings.EqualFold(info.VideoCodec, "H265") {
+			streamType = mpegts.StreamTypeH265
+		}
+		m.videoPID = m.mux.AddTrack(streamType)
+	}
+
+	if len(info.AudioConfig) > 0 && info.AudioClockRate > 0 {
+		if adts := aac.CodecToADTS(aac.ConfigToCodec(info.AudioConfig)); len(adts) == aac.ADTSHeaderSize {
+			m.adts = adts
+			m.audioRate = uint32(info.AudioClockRate)
+			m.audioPID = m.mux.AddTrack(mpegts.StreamTypeAAC)
+		}
+	}
+
+	return m
+}
+
+// HasAudio reports whether the muxer carries an AAC track
+func (m *Muxer) HasAudio() bool {
+	return m.adts != nil
+}
+
+// Header returns the PAT/PMT that must open the stream
+func (m *Muxer) Header() []byte {
+	return m.mux.GetHeader()
+}
+
+// Video muxes a length-prefixed (AVC) access unit with a 90 kHz timestamp
+func (m *Muxer) Video(au []byte, timestamp uint32) []byte {
+	if m.videoPID == 0 {
+		return nil
+	}
+	return m.mux.GetPayload(m.videoPID, m.videoClock.next(timestamp, 90000), au)
+}
+
+// Audio muxes a raw AAC frame, adding the ADTS header MPEG-TS requires
+func (m *Muxer) Audio(frame []byte, timestamp uint32) []byte {
+	if m.adts == nil {
+		return nil
+	}
+
+	b := make([]byte, 0, len(m.adts)+len(frame))
+	b = append(b, m.adts...)
+	b = append(b, frame...)
+	aac.WriteADTSSize(b, uint16(len(b)))
+
+	return m.mux.GetPayload(m.audioPID, m.audioClock.next(timestamp, m.audioRate), b)
+}
+
+// ptsClock rescales RTP timestamps to the 90 kHz MPEG-TS clock. The muxer
+// derives PTS from timestamp deltas and treats zero as "unset", so output
+// starts at 1 and never returns to zero.
+type ptsClock struct {
+	started bool
+	base    uint32
+}
+
+func (c *ptsClock) next(ts, clockRate uint32) uint32 {
+	if !c.started {
+		c.started = true
+		c.base = ts
+	}
+	delta := int64(int32(ts - c.base))
+	return 1 + uint32(delta*90000/int64(clockRate))
+}